@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/api"
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+	"github.com/graaaaa/vrclog-companion/internal/version"
+)
+
+// fanoutGrace is how long the fan-out benchmark waits for subscribers to
+// read the last events after publishing them.
+const fanoutGrace = 5 * time.Second
+
+// benchFanout connects subscribers SSE clients to the real API server over
+// loopback and publishes events to them at rate per second.
+func benchFanout(ctx context.Context, workDir string, subscribers, events int, rate float64) (*fanoutResult, error) {
+	// The stream endpoint needs the events use case for Last-Event-ID replay
+	db, err := store.Open(filepath.Join(workDir, "fanout.sqlite"))
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+	hub := api.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+	srv := api.NewServer("127.0.0.1:0", app.HealthService{Version: version.String()},
+		api.WithEventsUsecase(&app.EventsService{Store: db}),
+		api.WithHub(hub),
+	)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	published := make([]atomic.Int64, events) // unix nanoseconds, by event ID - 1
+	var (
+		mu        sync.Mutex
+		lat       []time.Duration
+		wg        sync.WaitGroup
+		connected = make(chan error, subscribers)
+	)
+	clientCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for range subscribers {
+		wg.Go(func() {
+			got, err := subscribe(clientCtx, ts.Client(), ts.URL, events, published, connected)
+			if err != nil {
+				connected <- err
+			}
+			mu.Lock()
+			lat = append(lat, got...)
+			mu.Unlock()
+		})
+	}
+	for range subscribers {
+		select {
+		case err := <-connected:
+			if err != nil {
+				cancel()
+				wg.Wait()
+				return nil, err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	start := time.Now()
+	for n := range events {
+		if err := pace(ctx, start, n, rate); err != nil {
+			return nil, err
+		}
+		published[n].Store(time.Now().UnixNano())
+		hub.Publish(&event.Event{
+			ID:         int64(n + 1),
+			Ts:         time.Now().UTC(),
+			Type:       event.TypePlayerJoin,
+			PlayerName: event.StringPtr(syntheticPlayerName(n)),
+		})
+	}
+
+	// Subscribers stop after the last event; stragglers are cut off
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(fanoutGrace):
+		cancel()
+		<-finished
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return &fanoutResult{
+		Subscribers: subscribers,
+		Events:      events,
+		Rate:        rate,
+		Expected:    subscribers * events,
+		Delivered:   len(lat),
+		Latency:     summarize(lat),
+	}, nil
+}
+
+// subscribe reads the event stream at baseURL until it has seen events
+// events or the stream ends, and returns the delay of each from its
+// publication time. It sends nil on connected once subscribed.
+func subscribe(ctx context.Context, client *http.Client, baseURL string, events int,
+	published []atomic.Int64, connected chan<- error) ([]time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/v1/stream", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("connect: %s", resp.Status)
+	}
+
+	var lat []time.Duration
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() && len(lat) < events {
+		line := sc.Text()
+		if line == ": connected" {
+			connected <- nil
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var e struct {
+			ID int64 `json:"id"`
+		}
+		if json.Unmarshal([]byte(data), &e) != nil || e.ID < 1 || e.ID > int64(events) {
+			continue
+		}
+		lat = append(lat, time.Since(time.Unix(0, published[e.ID-1].Load())))
+	}
+	return lat, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// logTimestampLayout is the timestamp prefix of VRChat log lines.
+const logTimestampLayout = "2006.01.02 15:04:05"
+
+// linesPerWorld is how many log lines the generator writes per world:
+// joining the world, entering its room, then players joining and leaving.
+const linesPerWorld = 20
+
+// syntheticEvent returns the n-th line of a synthetic VRChat log, stamped
+// with ts, as the source would report it. Every line is distinct, so none
+// is discarded as a duplicate.
+func syntheticEvent(n int, ts time.Time) ingest.Event {
+	world := n / linesPerWorld
+	ev := ingest.Event{Type: event.TypeWorldJoin, Timestamp: ts}
+	var msg string
+	switch k := n % linesPerWorld; {
+	case k == 0:
+		ev.WorldID = syntheticWorldID(world)
+		ev.InstanceID = fmt.Sprintf("%d~region(jp)", 10000+world)
+		msg = "Joining " + ev.WorldID + ":" + ev.InstanceID
+	case k == 1:
+		ev.WorldName = fmt.Sprintf("Bench World %d", world)
+		msg = "Entering Room: " + ev.WorldName
+	case k%2 == 0:
+		ev.Type = event.TypePlayerJoin
+		ev.PlayerName = syntheticPlayerName(n)
+		ev.PlayerID = fmt.Sprintf("usr_%08x-0000-0000-0000-000000000000", n)
+		msg = fmt.Sprintf("OnPlayerJoined %s (%s)", ev.PlayerName, ev.PlayerID)
+	default:
+		ev.Type = event.TypePlayerLeft
+		ev.PlayerName = syntheticPlayerName(n - 1)
+		msg = "OnPlayerLeft " + ev.PlayerName
+	}
+	ev.RawLine = ts.Format(logTimestampLayout) + " Log        -  [Behaviour] " + msg
+	return ev
+}
+
+// syntheticWorldID returns the ID of the n-th synthetic world.
+func syntheticWorldID(n int) string {
+	return fmt.Sprintf("wrld_%08x-0000-0000-0000-000000000000", n)
+}
+
+// syntheticPlayerName returns the name of the player joining on line n.
+func syntheticPlayerName(n int) string {
+	return fmt.Sprintf("Bench%07d", n)
+}
+
+// benchIngest writes lines synthetic log lines at rate per second to a log
+// file that the real source and ingester watch, and waits up to timeout
+// for them to be stored.
+func benchIngest(ctx context.Context, workDir string, lines int, rate float64, timeout time.Duration) (*ingestResult, error) {
+	dir := filepath.Join(workDir, "ingest")
+	logDir := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(dir, "bench.sqlite")
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	// The watcher needs a log file to attach to; start it with a line no
+	// parser matches
+	start := time.Now()
+	f, err := os.Create(filepath.Join(logDir, "output_log_"+start.Format("2006-01-02_15-04-05")+".txt"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "%s Log        -  [vrclog-bench] start\n", start.Format(logTimestampLayout)); err != nil {
+		return nil, err
+	}
+
+	var (
+		written sync.Map // dedupe key -> time the line was written
+		mu      sync.Mutex
+		lat     []time.Duration
+		stored  int
+		last    time.Time
+		done    = make(chan struct{})
+	)
+	source := ingest.NewVRClogSource(start.Add(-time.Minute), ingest.WithLogDir(logDir))
+	ingester := ingest.New(source, db, ingest.WithOnInsert(func(_ context.Context, e *event.Event) {
+		if e.Type == event.TypeSystem {
+			return // the source reporting on itself
+		}
+		now := time.Now()
+		at, ok := written.Load(e.DedupeKey)
+		mu.Lock()
+		defer mu.Unlock()
+		stored++
+		last = now
+		if ok {
+			lat = append(lat, now.Sub(at.(time.Time)))
+		}
+		if stored == lines {
+			close(done)
+		}
+	}))
+	runCtx, cancel := context.WithCancel(ctx)
+	runErr := make(chan error, 1)
+	go func() { runErr <- ingester.Run(runCtx) }()
+	defer func() {
+		cancel()
+		<-runErr
+	}()
+
+	first := time.Now()
+	for n := range lines {
+		if err := pace(ctx, first, n, rate); err != nil {
+			return nil, err
+		}
+		ev := syntheticEvent(n, time.Now())
+		written.Store(ingest.DedupeKey("", ev.RawLine), time.Now())
+		if _, err := f.WriteString(ev.RawLine + "\n"); err != nil {
+			return nil, fmt.Errorf("write log: %w", err)
+		}
+	}
+	writeElapsed := time.Since(first)
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case err := <-runErr:
+		runErr <- err // for the deferred wait
+		return nil, fmt.Errorf("ingester stopped: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	elapsed := last.Sub(first)
+	res := &ingestResult{
+		Lines:         lines,
+		Stored:        stored,
+		TargetRate:    rate,
+		WriteRate:     float64(lines) / writeElapsed.Seconds(),
+		Latency:       summarize(lat),
+		ElapsedSec:    elapsed.Seconds(),
+		DatabaseBytes: fileSize(dbPath) + fileSize(dbPath+"-wal"),
+	}
+	if elapsed > 0 {
+		res.Throughput = float64(stored) / elapsed.Seconds()
+	}
+	return res, nil
+}
+
+// fileSize returns the size of the file at path, 0 if it does not exist.
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
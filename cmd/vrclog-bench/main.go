@@ -0,0 +1,172 @@
+// Package main provides vrclog-bench, a load-test harness for the ingest,
+// SSE and query paths of VRClog Companion.
+//
+// It works on throwaway databases in a temporary directory and never
+// touches the data directory:
+//
+//   - ingest: writes synthetic VRChat log lines to a log file at -rate lines
+//     per second while the real watcher and ingester store them, and
+//     measures throughput and the delay from writing a line to storing it
+//   - fanout: publishes events to -subscribers SSE clients of the real API
+//     server over loopback and measures delivery latency
+//   - query: fills databases of each of -sizes events and measures the
+//     latency of typical queries
+//
+// Usage:
+//
+//	vrclog-bench -lines 50000 -rate 0 -subscribers 100 -sizes 10000,100000 -json report.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/version"
+)
+
+// phases are the benchmarks in the order they run.
+var phases = []string{"ingest", "fanout", "query"}
+
+func main() {
+	only := flag.String("phases", strings.Join(phases, ","), "comma-separated benchmarks to run (ingest, fanout, query)")
+	lines := flag.Int("lines", 20000, "ingest: log lines to write")
+	rate := flag.Float64("rate", 0, "ingest: log lines written per second (0 for as fast as possible)")
+	timeout := flag.Duration("timeout", 2*time.Minute, "ingest: how long to wait for the written lines to be stored")
+	subscribers := flag.Int("subscribers", 50, "fanout: SSE clients")
+	fanoutEvents := flag.Int("fanout-events", 1000, "fanout: events to publish")
+	fanoutRate := flag.Float64("fanout-rate", 500, "fanout: events published per second")
+	sizesFlag := flag.String("sizes", "1000,10000,50000", "query: comma-separated database sizes in events")
+	queries := flag.Int("queries", 200, "query: runs of each query per database size")
+	dir := flag.String("dir", "", "work directory for the benchmark databases and logs (default: a temporary directory, removed afterwards)")
+	jsonOut := flag.String("json", "", "also write the report as JSON to this file")
+	verbose := flag.Bool("v", false, "log the ingester and server as the app would")
+	flag.Parse()
+
+	if !*verbose {
+		slog.SetLogLoggerLevel(slog.LevelWarn)
+	}
+	run := map[string]bool{}
+	for p := range strings.SplitSeq(*only, ",") {
+		p = strings.TrimSpace(p)
+		if !slices.Contains(phases, p) {
+			log.Fatalf("Invalid -phases: unknown benchmark %q", p)
+		}
+		run[p] = true
+	}
+	sizes, err := parseSizes(*sizesFlag)
+	if err != nil {
+		log.Fatalf("Invalid -sizes: %v", err)
+	}
+	if *lines < 1 || *subscribers < 1 || *fanoutEvents < 1 || *queries < 1 {
+		log.Fatal("-lines, -subscribers, -fanout-events and -queries must be at least 1")
+	}
+
+	workDir := *dir
+	if workDir == "" {
+		workDir, err = os.MkdirTemp("", "vrclog-bench-")
+		if err != nil {
+			log.Fatalf("Failed to create work directory: %v", err)
+		}
+		defer os.RemoveAll(workDir)
+	} else if err := os.MkdirAll(workDir, 0o755); err != nil {
+		log.Fatalf("Failed to create work directory: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	rep := report{
+		Version:   version.String(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS + "/" + runtime.GOARCH,
+		CPUs:      runtime.NumCPU(),
+		StartedAt: time.Now().UTC(),
+	}
+	if run["ingest"] {
+		log.Printf("ingest: %d lines at %s", *lines, rateString(*rate))
+		res, err := benchIngest(ctx, workDir, *lines, *rate, *timeout)
+		if err != nil {
+			log.Fatalf("ingest: %v", err)
+		}
+		rep.Ingest = res
+	}
+	if run["fanout"] {
+		log.Printf("fanout: %d events to %d subscribers at %s", *fanoutEvents, *subscribers, rateString(*fanoutRate))
+		res, err := benchFanout(ctx, workDir, *subscribers, *fanoutEvents, *fanoutRate)
+		if err != nil {
+			log.Fatalf("fanout: %v", err)
+		}
+		rep.Fanout = res
+	}
+	if run["query"] {
+		for _, size := range sizes {
+			log.Printf("query: %d events", size)
+			res, err := benchQueries(ctx, workDir, size, *queries)
+			if err != nil {
+				log.Fatalf("query: %v", err)
+			}
+			rep.Queries = append(rep.Queries, res)
+		}
+	}
+	rep.Duration = time.Since(rep.StartedAt).Round(time.Millisecond).String()
+
+	rep.print(os.Stdout)
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(rep, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode report: %v", err)
+		}
+		if err := os.WriteFile(*jsonOut, append(data, '\n'), 0o644); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+	}
+}
+
+// parseSizes parses a comma-separated list of positive database sizes.
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for f := range strings.SplitSeq(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid size %q", f)
+		}
+		sizes = append(sizes, n)
+	}
+	slices.Sort(sizes)
+	return sizes, nil
+}
+
+// rateString describes a per-second rate, 0 meaning unlimited.
+func rateString(rate float64) string {
+	if rate <= 0 {
+		return "full speed"
+	}
+	return fmt.Sprintf("%g/s", rate)
+}
+
+// pace sleeps until n items may have been produced at rate per second since
+// start. A rate of 0 or less never sleeps.
+func pace(ctx context.Context, start time.Time, n int, rate float64) error {
+	if rate <= 0 {
+		return ctx.Err()
+	}
+	due := start.Add(time.Duration(float64(n) / rate * float64(time.Second)))
+	if d := time.Until(due); d > 0 {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
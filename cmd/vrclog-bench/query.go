@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// querySpan is the time span the query benchmark spreads its events over,
+// so that stats ranges cover both daily rollups and raw events.
+const querySpan = 90 * 24 * time.Hour
+
+// benchQueries fills a database with size synthetic events and runs each
+// kind of query runs times.
+func benchQueries(ctx context.Context, workDir string, size, runs int) (queryResult, error) {
+	res := queryResult{Events: size}
+	db, err := store.Open(filepath.Join(workDir, fmt.Sprintf("query-%d.sqlite", size)))
+	if err != nil {
+		return res, fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	end := time.Now().UTC()
+	begin := end.Add(-querySpan)
+	step := querySpan / time.Duration(size)
+	fillStart := time.Now()
+	for n := range size {
+		e := ingest.ToStoreEvent(syntheticEvent(n, begin.Add(time.Duration(n)*step)))
+		if _, _, err := db.InsertEvent(ctx, e); err != nil {
+			return res, fmt.Errorf("fill database: %w", err)
+		}
+	}
+	res.FillRate = float64(size) / time.Since(fillStart).Seconds()
+
+	rng := rand.New(rand.NewPCG(1, uint64(size)))
+	worlds := max(1, size/linesPerWorld)
+	ops := []struct {
+		name string
+		run  func() error
+	}{
+		{"latest page", func() error {
+			_, err := db.QueryEvents(ctx, store.QueryFilter{})
+			return err
+		}},
+		{"by player", func() error {
+			player := syntheticPlayerName(rng.IntN(size))
+			_, err := db.QueryEvents(ctx, store.QueryFilter{Player: &player})
+			return err
+		}},
+		{"by world", func() error {
+			world := syntheticWorldID(rng.IntN(worlds))
+			_, err := db.QueryEvents(ctx, store.QueryFilter{World: &world})
+			return err
+		}},
+		{"one day, oldest first", func() error {
+			since := begin.Add(time.Duration(rng.Int64N(int64(querySpan - 24*time.Hour))))
+			until := since.Add(24 * time.Hour)
+			_, err := db.QueryEvents(ctx, store.QueryFilter{Since: &since, Until: &until, Order: store.QueryOrderAsc})
+			return err
+		}},
+		{"stats, 7 days", func() error {
+			_, err := db.GetBasicStats(ctx, end.Add(-7*24*time.Hour), end, "")
+			return err
+		}},
+		{"stats, 90 days", func() error {
+			_, err := db.GetBasicStats(ctx, begin, end, "")
+			return err
+		}},
+	}
+	for _, op := range ops {
+		ds := make([]time.Duration, 0, runs)
+		for range runs {
+			if err := ctx.Err(); err != nil {
+				return res, err
+			}
+			start := time.Now()
+			if err := op.run(); err != nil {
+				return res, fmt.Errorf("%s: %w", op.name, err)
+			}
+			ds = append(ds, time.Since(start))
+		}
+		res.Operations = append(res.Operations, queryLatency{Name: op.name, Latency: summarize(ds)})
+	}
+	return res, nil
+}
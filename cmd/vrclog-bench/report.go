@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// report is the outcome of a benchmark run.
+type report struct {
+	Version   string        `json:"version"`
+	GoVersion string        `json:"go_version"`
+	OS        string        `json:"os"`
+	CPUs      int           `json:"cpus"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  string        `json:"duration"`
+	Ingest    *ingestResult `json:"ingest,omitempty"`
+	Fanout    *fanoutResult `json:"fanout,omitempty"`
+	Queries   []queryResult `json:"queries,omitempty"`
+}
+
+// ingestResult is the outcome of the ingest benchmark.
+type ingestResult struct {
+	Lines         int       `json:"lines"`
+	Stored        int       `json:"stored"`
+	TargetRate    float64   `json:"target_rate"` // lines per second, 0 for unlimited
+	WriteRate     float64   `json:"write_rate"`  // lines actually written per second
+	Throughput    float64   `json:"throughput"`  // events stored per second, first write to last insert
+	Latency       latencies `json:"latency"`     // from writing a line to storing its event
+	ElapsedSec    float64   `json:"elapsed_sec"` // first write to last insert
+	DatabaseBytes int64     `json:"database_bytes"`
+}
+
+// fanoutResult is the outcome of the SSE fan-out benchmark.
+type fanoutResult struct {
+	Subscribers int       `json:"subscribers"`
+	Events      int       `json:"events"`
+	Rate        float64   `json:"rate"`
+	Expected    int       `json:"expected"`  // subscribers × events
+	Delivered   int       `json:"delivered"` // events received by all subscribers together
+	Latency     latencies `json:"latency"`   // from publishing an event to a client reading it
+}
+
+// queryResult is the outcome of the query benchmark at one database size.
+type queryResult struct {
+	Events     int            `json:"events"`
+	FillRate   float64        `json:"fill_rate"` // events inserted per second while filling
+	Operations []queryLatency `json:"operations"`
+}
+
+// queryLatency is the latency of one kind of query.
+type queryLatency struct {
+	Name    string    `json:"name"`
+	Latency latencies `json:"latency"`
+}
+
+// latencies summarizes a set of durations, in milliseconds.
+type latencies struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P95   float64 `json:"p95_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// summarize returns the latencies of ds, which it sorts.
+func summarize(ds []time.Duration) latencies {
+	if len(ds) == 0 {
+		return latencies{}
+	}
+	slices.Sort(ds)
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	pct := func(p float64) float64 {
+		return ms(ds[min(len(ds)-1, int(p*float64(len(ds))))])
+	}
+	return latencies{
+		Count: len(ds),
+		Mean:  ms(sum / time.Duration(len(ds))),
+		P50:   pct(0.50),
+		P95:   pct(0.95),
+		P99:   pct(0.99),
+		Max:   ms(ds[len(ds)-1]),
+	}
+}
+
+// ms converts d to fractional milliseconds.
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// print writes the report as plain text tables.
+func (r report) print(w io.Writer) {
+	fmt.Fprintf(w, "vrclog-bench %s, %s, %s, %d CPUs, %s\n", r.Version, r.GoVersion, r.OS, r.CPUs, r.Duration)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := func(first string) {
+		fmt.Fprintf(tw, "%s\tcount\tmean ms\tp50 ms\tp95 ms\tp99 ms\tmax ms\t\n", first)
+	}
+	row := func(name string, l latencies) {
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n", name, l.Count, l.Mean, l.P50, l.P95, l.P99, l.Max)
+	}
+
+	if in := r.Ingest; in != nil {
+		fmt.Fprintf(w, "\nIngest: %d of %d lines stored in %.2fs, %.0f events/s (written at %.0f lines/s, target %s), database %d KiB\n",
+			in.Stored, in.Lines, in.ElapsedSec, in.Throughput, in.WriteRate, rateString(in.TargetRate), in.DatabaseBytes/1024)
+		header("")
+		row("write to stored", in.Latency)
+		tw.Flush()
+	}
+	if f := r.Fanout; f != nil {
+		fmt.Fprintf(w, "\nSSE fan-out: %d events to %d subscribers at %s, %d of %d delivered\n",
+			f.Events, f.Subscribers, rateString(f.Rate), f.Delivered, f.Expected)
+		header("")
+		row("publish to read", f.Latency)
+		tw.Flush()
+	}
+	for _, q := range r.Queries {
+		fmt.Fprintf(w, "\nQueries at %d events (filled at %.0f events/s)\n", q.Events, q.FillRate)
+		header("query")
+		for _, op := range q.Operations {
+			row(op.Name, op.Latency)
+		}
+		tw.Flush()
+	}
+}
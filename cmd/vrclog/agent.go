@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/forward"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
+
+// runAgent tails the VRChat logs and forwards events to the instance at
+// target until interrupted.
+func runAgent(cfg config.Config, target, account string) error {
+	client, err := forward.NewClient(target)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The agent keeps no database, so replay the first-run window on every
+	// start; the receiver discards events it already has
+	replaySince := ingest.CalculateReplaySince(time.Time{}, ingest.DefaultFirstRunRollback)
+	source := newEventSource(cfg, replaySince, account)
+
+	slog.Info("Forwarding events", "to", client.Endpoint())
+	err = forward.New(source, client).Run(ctx)
+	if errors.Is(err, context.Canceled) {
+		slog.Info("Agent stopped")
+		return nil
+	}
+	return err
+}
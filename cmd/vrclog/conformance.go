@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/graaaaa/vrclog-companion/internal/conformance"
+)
+
+// runConformance implements "vrclog conformance [-json] <url>": it checks
+// the server at url against the API specification and prints a report.
+// Returns the exit code: 0 if every check passed or was skipped, 1 if any
+// failed and 2 for invalid arguments.
+func runConformance(args []string) int {
+	fs := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vrclog conformance [-json] [-timeout 10s] http://[user:pass@]host:port")
+		fs.PrintDefaults()
+	}
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	timeout := fs.Duration("timeout", conformance.DefaultTimeout, "timeout of each request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	runner, err := conformance.NewRunner(fs.Arg(0), conformance.WithTimeout(*timeout))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rep := runner.Run(ctx)
+
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	} else {
+		fmt.Printf("Conformance of %s\n\n", rep.BaseURL)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, r := range rep.Results {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", strings.ToUpper(string(r.Status)), r.Name, r.Spec, r.Message)
+		}
+		tw.Flush()
+		fmt.Printf("\n%d checks: %d passed, %d failed, %d skipped\n", len(rep.Results), rep.Passed, rep.Failed, rep.Skipped)
+	}
+	if !rep.OK() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/graaaaa/vrclog-companion/internal/appinfo"
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// runMerge implements "vrclog merge <other.sqlite>": it imports the events
+// and parse failures of another database into this install's database.
+func runMerge(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: vrclog merge <other.sqlite>")
+	}
+	otherPath := args[0]
+
+	dataDir, err := config.EnsureDataDir()
+	if err != nil {
+		return fmt.Errorf("ensure data directory: %w", err)
+	}
+	dbPath := filepath.Join(dataDir, appinfo.DatabaseFileName)
+
+	otherInfo, err := os.Stat(otherPath)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dbPath); err == nil && os.SameFile(info, otherInfo) {
+		return errors.New("cannot merge a database into itself")
+	}
+
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	slog.Info("Merging databases", "from", otherPath, "into", dbPath)
+	result, err := db.MergeFrom(context.Background(), otherPath)
+	if err != nil {
+		return err
+	}
+	slog.Info("Merge finished",
+		"events", result.Events, "duplicate_events", result.DuplicateEvents,
+		"parse_failures", result.ParseFailures, "duplicate_parse_failures", result.DuplicateParseFailures)
+	return nil
+}
@@ -3,9 +3,12 @@ module github.com/graaaaa/vrclog-companion
 go 1.25
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/vrclog/vrclog-go v0.0.0-20260114043748-10d90baa8f1b
 	golang.org/x/sys v0.39.0
+	golang.org/x/text v0.32.0
 	golang.org/x/time v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.42.2
 )
 
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Action types
+const (
+	// ActionShowPlayer asks the web UI to open a player's profile page.
+	ActionShowPlayer = "show_player"
+)
+
+// maxActionPlayerIDLen bounds the player of an action; display names and
+// usr_ IDs are far shorter.
+const maxActionPlayerIDLen = 256
+
+// Action is a request to the web UI from outside it, e.g. the "show
+// player" button of a desktop or overlay notification. Actions are routed
+// through the ActionHub to GET /api/v1/actions/stream and not stored; UIs
+// not connected when one is sent never see it.
+type Action struct {
+	Type     string    `json:"type"`
+	PlayerID string    `json:"player_id,omitempty"` // usr_ ID or display name (show_player)
+	Ts       time.Time `json:"ts"`
+}
+
+// handleShowPlayer handles POST /api/v1/actions/show-player/{id}.
+// {id} is a usr_ ID or display name, as for GET /api/v1/players/{id}.
+// Clients other than the web UI must send the X-VRClog-Agent header.
+func (s *Server) handleShowPlayer(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.PathValue("id"))
+	if id == "" || len(id) > maxActionPlayerIDLen {
+		writeError(w, http.StatusBadRequest, "invalid player", nil)
+		return
+	}
+	action := &Action{Type: ActionShowPlayer, PlayerID: id, Ts: time.Now().UTC()}
+	s.actionHub.Publish(action)
+	writeJSON(w, http.StatusAccepted, action)
+}
+
+// handleActionStream handles GET /api/v1/actions/stream (SSE).
+// Streams actions for the web UI to carry out, named after their type
+// (e.g. "event: show_player").
+func (s *Server) handleActionStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported", nil)
+		return
+	}
+	opts, ok := s.parseSSEOptions(w, r)
+	if !ok {
+		return
+	}
+
+	setSSEHeaders(w, opts)
+
+	sub := s.actionHub.Subscribe()
+	defer s.actionHub.Unsubscribe(sub)
+	client := s.streamClients.add(r, StreamActions, "")
+	defer s.streamClients.remove(client)
+
+	opts.writeConnected(w, flusher)
+
+	ticker := time.NewTicker(opts.heartbeat)
+	defer ticker.Stop()
+
+	ctx := r.Context()
+
+	for {
+		select {
+		case a, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			opts.writeFrame(w, "", a.Type, a)
+			flusher.Flush()
+
+		case <-ticker.C:
+			opts.writeHeartbeat(w, flusher)
+
+		case <-ctx.Done():
+			return
+
+		case <-client.kicked:
+			return
+
+		case <-sub.Done():
+			return
+		}
+	}
+}
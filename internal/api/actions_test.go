@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+func TestActions_ShowPlayer(t *testing.T) {
+	actionHub := NewActionHub()
+	go actionHub.Run()
+	defer actionHub.Stop()
+
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithActionHub(actionHub))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/actions/stream")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	if line := <-lines; line != ": connected" {
+		t.Fatalf("first line = %q, want connection comment", line)
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/actions/show-player/%20":      http.StatusBadRequest,
+		"/api/v1/actions/show-player/usr_abc1": http.StatusAccepted,
+	} {
+		post, err := http.Post(ts.URL+target, "application/json", nil)
+		if err != nil {
+			t.Fatalf("POST %s: %v", target, err)
+		}
+		post.Body.Close()
+		if post.StatusCode != want {
+			t.Errorf("POST %s: status %d, want %d", target, post.StatusCode, want)
+		}
+	}
+
+	var got []string
+	timeout := time.After(2 * time.Second)
+	for len(got) < 2 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed early, got %q", got)
+			}
+			if line != "" {
+				got = append(got, line)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for action, got %q", got)
+		}
+	}
+	if got[0] != "event: show_player" {
+		t.Errorf("event line = %q, want event: show_player", got[0])
+	}
+	var a Action
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(got[1], "data: ")), &a); err != nil {
+		t.Fatalf("data line = %q: %v", got[1], err)
+	}
+	if a.Type != ActionShowPlayer || a.PlayerID != "usr_abc1" || a.Ts.IsZero() {
+		t.Errorf("action = %+v", a)
+	}
+}
@@ -17,13 +17,13 @@ type tokenResponse struct {
 // Requires Basic Auth. Issues a short-lived SSE token.
 func (s *Server) handleAuthToken(w http.ResponseWriter, r *http.Request) {
 	if len(s.sseSecret) == 0 {
-		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "SSE tokens not configured"})
+		writeError(w, http.StatusServiceUnavailable, "SSE tokens not configured", nil)
 		return
 	}
 
 	token, err := sseauth.GenerateToken(s.sseSecret, sseauth.ScopeSSE, time.Now())
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to generate token"})
+		writeError(w, http.StatusInternalServerError, "failed to generate token", err)
 		return
 	}
 
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// backupsResponse is the response of GET /api/v1/backups.
+type backupsResponse struct {
+	Items []app.BackupFile `json:"items"`
+}
+
+// handleBackups handles GET /api/v1/backups requests.
+func (s *Server) handleBackups(w http.ResponseWriter, r *http.Request) {
+	files, err := s.backups.ListBackups(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, backupsResponse{Items: files})
+}
+
+// handleBackupDownload handles GET /api/v1/backups/{name} requests.
+// Range requests let an interrupted download resume where it stopped.
+func (s *Server) handleBackupDownload(w http.ResponseWriter, r *http.Request) {
+	f, info, err := s.backups.OpenBackup(r.Context(), r.PathValue("name"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "backup not found", nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	defer f.Close()
+
+	// Backups are never rewritten, so name, size and time identify the
+	// bytes without hashing the whole file on every request
+	etag := strongETag([]byte(fmt.Sprintf("%s:%d:%d", info.Name, info.Size, info.ModTime.UnixNano())))
+	w.Header().Set("Content-Type", "application/gzip")
+	serveDownload(w, r, info.Name, info.ModTime, etag, f)
+}
+
+// serveDownload writes content as an attachment named name. Content-Length
+// is always set, and with the strong etag, Range and If-Range requests are
+// answered with 206 so clients can resume downloads.
+func serveDownload(w http.ResponseWriter, r *http.Request, name string, modtime time.Time, etag string, content io.ReadSeeker) {
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(name, `"`, "")+`"`)
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, modtime, content)
+}
+
+// serveDownloadBytes is serveDownload for content already in memory; its
+// ETag is derived from the content.
+func serveDownloadBytes(w http.ResponseWriter, r *http.Request, name string, data []byte) {
+	serveDownload(w, r, name, time.Time{}, strongETag(data), bytes.NewReader(data))
+}
+
+// strongETag returns a strong ETag for data.
+func strongETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+func TestBackupsEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	const name = "vrclog-backup-20240115-030000.sqlite.gz"
+	content := []byte("0123456789abcdefghij")
+	if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithBackupsUsecase(&app.BackupService{Dir: dir}))
+
+	get := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/backups", nil)
+	var list backupsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != name || list.Items[0].Size != int64(len(content)) {
+		t.Fatalf("list = %+v", list.Items)
+	}
+
+	rec = get("/api/v1/backups/"+name, nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(content) {
+		t.Fatalf("download: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Length") != "20" || rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("download headers = %v", rec.Header())
+	}
+	if len(etag) < 3 || etag[0] != '"' {
+		t.Errorf("ETag = %q, want a strong ETag", etag)
+	}
+
+	// Resume after the first 10 bytes
+	rec = get("/api/v1/backups/"+name, map[string]string{"Range": "bytes=10-", "If-Range": etag})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "abcdefghij" {
+		t.Errorf("range: status %d, body %q, want 206 with the rest", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 10-19/20" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	// A changed file is sent whole
+	rec = get("/api/v1/backups/"+name, map[string]string{"Range": "bytes=10-", "If-Range": `"stale"`})
+	if rec.Code != http.StatusOK || rec.Body.Len() != len(content) {
+		t.Errorf("stale If-Range: status %d, %d bytes, want 200 with the whole file", rec.Code, rec.Body.Len())
+	}
+
+	for _, target := range []string{"/api/v1/backups/missing.gz", "/api/v1/backups/..%2Fsecrets.json"} {
+		if rec := get(target, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", target, rec.Code)
+		}
+	}
+}
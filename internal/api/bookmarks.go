@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// bookmarkRequest is the request body for POST /api/v1/bookmarks.
+type bookmarkRequest struct {
+	EventID int64  `json:"event_id"`
+	Label   string `json:"label"`
+}
+
+// bookmarksResponse is the response body for GET /api/v1/bookmarks.
+type bookmarksResponse struct {
+	Items []app.Bookmark `json:"items"`
+}
+
+// handleEventLaunch handles GET /api/v1/events/{id}/launch.
+func (s *Server) handleEventLaunch(w http.ResponseWriter, r *http.Request) {
+	eventID, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	link, err := s.bookmarks.LaunchLink(r.Context(), eventID)
+	if err != nil {
+		writeBookmarkError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, link)
+}
+
+// handleAddBookmark handles POST /api/v1/bookmarks.
+func (s *Server) handleAddBookmark(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req bookmarkRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.EventID < 1 {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	b, err := s.bookmarks.Add(r.Context(), req.EventID, req.Label)
+	if err != nil {
+		writeBookmarkError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, b)
+}
+
+// handleListBookmarks handles GET /api/v1/bookmarks.
+func (s *Server) handleListBookmarks(w http.ResponseWriter, r *http.Request) {
+	items, err := s.bookmarks.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, bookmarksResponse{Items: items})
+}
+
+// handleDeleteBookmark handles DELETE /api/v1/bookmarks/{id}.
+func (s *Server) handleDeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.bookmarks.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "bookmark not found", nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeBookmarkError maps bookmark use case errors to responses.
+func writeBookmarkError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusNotFound, "event not found", nil)
+	case errors.Is(err, app.ErrNotLaunchable), errors.Is(err, app.ErrInvalidBookmark):
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Names of the SSE streams, as reported by GET /api/v1/stream/clients.
+const (
+	StreamEvents  = "events"
+	StreamDerived = "derived"
+	StreamActions = "actions"
+)
+
+// StreamClient describes a client connected to one of the SSE streams.
+type StreamClient struct {
+	ID          int64     `json:"id"`
+	Stream      string    `json:"stream"`
+	ConnectedAt time.Time `json:"connected_at"`
+	RemoteIP    string    `json:"remote_ip"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	View        string    `json:"view,omitempty"` // saved view filtering the events stream
+}
+
+// streamClient is a registered SSE connection. kicked is closed when an
+// admin disconnects it.
+type streamClient struct {
+	StreamClient
+	kicked chan struct{}
+}
+
+// streamClients tracks the SSE connections of a Server, so that admins
+// can see and disconnect misbehaving overlay clients. The zero value is
+// ready to use.
+type streamClients struct {
+	mu      sync.Mutex
+	nextID  int64
+	clients map[int64]*streamClient
+}
+
+// add registers the connection of r to stream. The caller must call
+// remove when the connection ends.
+func (c *streamClients) add(r *http.Request, stream, view string) *streamClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clients == nil {
+		c.clients = make(map[int64]*streamClient)
+	}
+	c.nextID++
+	sc := &streamClient{
+		StreamClient: StreamClient{
+			ID:          c.nextID,
+			Stream:      stream,
+			ConnectedAt: time.Now().UTC(),
+			RemoteIP:    extractIP(r),
+			UserAgent:   r.UserAgent(),
+			View:        view,
+		},
+		kicked: make(chan struct{}),
+	}
+	c.clients[sc.ID] = sc
+	return sc
+}
+
+// remove unregisters a connection.
+func (c *streamClients) remove(sc *streamClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients, sc.ID)
+}
+
+// list returns the connected clients, oldest first.
+func (c *streamClients) list() []StreamClient {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	items := make([]StreamClient, 0, len(c.clients))
+	for _, sc := range c.clients {
+		items = append(items, sc.StreamClient)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// kick disconnects a client. Returns false if it is not connected.
+func (c *streamClients) kick(id int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sc, ok := c.clients[id]
+	if !ok {
+		return false
+	}
+	delete(c.clients, id)
+	close(sc.kicked)
+	return true
+}
+
+// streamClientsResponse is the response of GET /api/v1/stream/clients.
+type streamClientsResponse struct {
+	Items []StreamClient `json:"items"`
+}
+
+// handleStreamClients handles GET /api/v1/stream/clients.
+// Lists the clients connected to the SSE streams.
+func (s *Server) handleStreamClients(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, streamClientsResponse{Items: s.streamClients.list()})
+}
+
+// handleKickStreamClient handles DELETE /api/v1/stream/clients/{id}.
+// Ends the client's stream. Browsers' EventSource reconnects on its own;
+// denied_ips in the config keeps a client out for good.
+func (s *Server) handleKickStreamClient(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+	if !s.streamClients.kick(id) {
+		writeError(w, http.StatusNotFound, "client not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
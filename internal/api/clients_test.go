@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+func TestStreamClients_ListAndKick(t *testing.T) {
+	derivedHub := NewDerivedHub()
+	go derivedHub.Run()
+	defer derivedHub.Stop()
+
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithDerivedHub(derivedHub))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/stream/derived", nil)
+	req.Header.Set("User-Agent", "overlay/1.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	closed := make(chan struct{})
+	sc := bufio.NewScanner(resp.Body)
+	if !sc.Scan() || sc.Text() != ": connected" {
+		t.Fatalf("first line = %q, want connection comment", sc.Text())
+	}
+	go func() {
+		for sc.Scan() {
+		}
+		close(closed)
+	}()
+
+	list := func() []StreamClient {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream/clients", nil))
+		var body streamClientsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("decode clients: %v", err)
+		}
+		return body.Items
+	}
+	clients := list()
+	if len(clients) != 1 || clients[0].Stream != StreamDerived || clients[0].RemoteIP != "127.0.0.1" ||
+		clients[0].UserAgent != "overlay/1.0" || clients[0].ConnectedAt.IsZero() {
+		t.Fatalf("clients = %+v", clients)
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/stream/clients/abc":                            http.StatusBadRequest,
+		"/api/v1/stream/clients/999":                            http.StatusNotFound,
+		fmt.Sprintf("/api/v1/stream/clients/%d", clients[0].ID): http.StatusNoContent,
+	} {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, target, nil))
+		if rec.Code != want {
+			t.Errorf("DELETE %s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("kicked stream was not closed")
+	}
+	if clients := list(); len(clients) != 0 {
+		t.Errorf("clients after kick = %+v", clients)
+	}
+}
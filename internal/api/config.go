@@ -2,11 +2,23 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/config"
 )
 
+// PassphraseHeader carries the passphrase that encrypts or decrypts the
+// secrets in a settings bundle. A header keeps it out of URLs and logs.
+const PassphraseHeader = "X-VRClog-Passphrase"
+
+// handleUIConfig handles GET /api/v1/ui-config, the branding of the web UI
+// and overlays. It needs no auth: the UI reads it before signing in.
+func (s *Server) handleUIConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.cfg.GetUIConfig(r.Context()))
+}
+
 // handleGetConfig handles GET /api/v1/config requests.
 func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 	if s.cfg == nil {
@@ -25,6 +37,40 @@ func (s *Server) handlePutConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	req, ok := decodeConfigUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := s.cfg.UpdateConfig(r.Context(), req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleValidateConfig handles POST /api/v1/config/validate requests.
+// The body is a ConfigUpdateRequest; nothing is saved. Responds 200 with
+// the validation result whether or not the request is valid.
+func (s *Server) handleValidateConfig(w http.ResponseWriter, r *http.Request) {
+	if s.cfg == nil {
+		writeError(w, http.StatusServiceUnavailable, "config not available", nil)
+		return
+	}
+
+	req, ok := decodeConfigUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.cfg.ValidateConfig(r.Context(), req))
+}
+
+// decodeConfigUpdate strictly decodes a ConfigUpdateRequest body, writing
+// a 400 response and returning false if it is malformed.
+func decodeConfigUpdate(w http.ResponseWriter, r *http.Request) (app.ConfigUpdateRequest, bool) {
 	// Limit request body size to 1MB to prevent DoS
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
 
@@ -33,14 +79,65 @@ func (s *Server) handlePutConfig(w http.ResponseWriter, r *http.Request) {
 	decoder.DisallowUnknownFields() // Strict JSON parsing
 	if err := decoder.Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return req, false
+	}
+	return req, true
+}
+
+// handleExportSettings handles GET /api/v1/config/export requests. The
+// bundle includes secrets only if PassphraseHeader is set. It is served as
+// a download with a content ETag, so Range requests work.
+func (s *Server) handleExportSettings(w http.ResponseWriter, r *http.Request) {
+	if s.cfg == nil {
+		writeError(w, http.StatusServiceUnavailable, "config not available", nil)
 		return
 	}
 
-	result, err := s.cfg.UpdateConfig(r.Context(), req)
+	bundle, err := s.cfg.ExportSettings(r.Context(), r.Header.Get(PassphraseHeader))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		if errors.Is(err, config.ErrWeakPassphrase) {
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	data, err := json.Marshal(bundle)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	serveDownloadBytes(w, r, "vrclog-settings-"+bundle.ExportedAt.Format("20060102")+".json", data)
+}
+
+// handleImportSettings handles POST /api/v1/config/import requests. The
+// body is a bundle from handleExportSettings; its secrets are imported
+// only if PassphraseHeader is set.
+func (s *Server) handleImportSettings(w http.ResponseWriter, r *http.Request) {
+	if s.cfg == nil {
+		writeError(w, http.StatusServiceUnavailable, "config not available", nil)
+		return
+	}
+
+	// Limit request body size to 1MB to prevent DoS
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var bundle config.Bundle
+	if err := json.NewDecoder(r.Body).Decode(&bundle); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	result, err := s.cfg.ImportSettings(r.Context(), bundle, r.Header.Get(PassphraseHeader))
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, result)
+	case errors.Is(err, config.ErrInvalidBundle), errors.Is(err, config.ErrWrongPassphrase),
+		errors.Is(err, config.ErrHandEditedConfig):
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	}
 }
@@ -0,0 +1,28 @@
+package api
+
+import "net/http"
+
+// handleLogPathDiagnostics handles GET /api/v1/diagnostics/logpath.
+func (s *Server) handleLogPathDiagnostics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.diagnostics.LogPath(r.Context()))
+}
+
+// handleDatabaseDiagnostics handles GET /api/v1/diagnostics/db.
+func (s *Server) handleDatabaseDiagnostics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.diagnostics.Database(r.Context()))
+}
+
+// handleParseFailureDiagnostics handles GET /api/v1/diagnostics/parse-failures.
+func (s *Server) handleParseFailureDiagnostics(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.diagnostics.ParseFailures(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// handleRateLimitDiagnostics handles GET /api/v1/diagnostics/ratelimit.
+func (s *Server) handleRateLimitDiagnostics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.rateLimiter.Stats())
+}
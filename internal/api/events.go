@@ -1,13 +1,18 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	"github.com/graaaaa/vrclog-companion/internal/app"
 	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
 	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
@@ -17,6 +22,52 @@ type eventsResponse struct {
 	NextCursor *string       `json:"next_cursor,omitempty"`
 }
 
+// eventChangesResponse represents the response for the event changes endpoint.
+type eventChangesResponse struct {
+	Items      []store.EventChange `json:"items"`
+	NextCursor string              `json:"next_cursor"`
+	HasMore    bool                `json:"has_more"`
+}
+
+// handleEventChanges handles GET /api/v1/events/changes
+func (s *Server) handleEventChanges(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+	}
+
+	result, err := s.events.Changes(r.Context(), q.Get("since_cursor"), limit)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	resp := eventChangesResponse{
+		Items:      result.Items,
+		NextCursor: result.NextCursor,
+		HasMore:    result.HasMore,
+	}
+	if resp.Items == nil {
+		resp.Items = []store.EventChange{}
+	}
+	mask := s.eventMask(r)
+	for i := range resp.Items {
+		resp.Items[i].Event = mask.Apply(resp.Items[i].Event)
+	}
+
+	writeResponse(w, r, http.StatusOK, resp)
+}
+
 // handleEvents handles GET /api/v1/events
 func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	filter, err := parseEventsFilter(r)
@@ -28,7 +79,7 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	result, err := s.events.Query(r.Context(), filter)
 	if err != nil {
 		if errors.Is(err, store.ErrInvalidCursor) {
-			writeError(w, http.StatusBadRequest, "invalid cursor", nil)
+			writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
 			return
 		}
 		writeError(w, http.StatusInternalServerError, "internal error", err)
@@ -44,14 +95,175 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	if resp.Items == nil {
 		resp.Items = []event.Event{}
 	}
+	mask := s.eventMask(r)
+	for i := range resp.Items {
+		resp.Items[i] = *mask.Apply(&resp.Items[i])
+	}
 
-	writeJSON(w, http.StatusOK, resp)
+	writeResponse(w, r, http.StatusOK, resp)
+}
+
+// handleEventsAggregate handles GET /api/v1/events/aggregate, counting the
+// events matching the filter by group_by (type, player or world). The
+// filter parameters are those of GET /api/v1/events; limit caps the
+// groups returned and cursor is ignored.
+func (s *Server) handleEventsAggregate(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventsFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if !store.IsValidGroupBy(groupBy) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid group_by: %q (want type, player or world)", groupBy), nil)
+		return
+	}
+
+	result, err := s.events.Aggregate(r.Context(), filter, groupBy)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	// Player IDs hidden from this client are replaced by the names
+	if groupBy == store.GroupByPlayer && s.eventMask(r).PlayerID {
+		for i := range result.Items {
+			result.Items[i].Key = event.NormalizeName(result.Items[i].Name)
+		}
+	}
+	writeResponse(w, r, http.StatusOK, result)
+}
+
+// streamExportBatchSize is how many events handleEventsStreamExport reads
+// per query before flushing them to the client.
+const streamExportBatchSize = 500
+
+// handleEventsStreamExport handles GET /api/v1/events/stream-export,
+// writing every event matching the filter as one JSON object per line,
+// oldest first. Events are read in batches and each batch is flushed
+// before the next is read, so a slow client slows the reads down instead
+// of the response piling up in memory. The filter parameters are those of
+// GET /api/v1/events, including cursor to start after a page of it;
+// limit is ignored.
+func (s *Server) handleEventsStreamExport(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventsFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	filter.Limit = streamExportBatchSize
+	filter.Order = store.QueryOrderAsc
+
+	// The first batch is read before anything is written, so a bad
+	// cursor or a broken database still gets an error response
+	result, err := s.events.Query(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	mask := s.eventMask(r)
+	n := 0
+	for {
+		for i := range result.Items {
+			if err := enc.Encode(mask.Apply(&result.Items[i])); err != nil {
+				return // client went away
+			}
+		}
+		n += len(result.Items)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if result.NextCursor == nil {
+			return
+		}
+		filter.Cursor = result.NextCursor
+		if result, err = s.events.Query(r.Context(), filter); err != nil {
+			// Too late for an error response; the client sees the stream
+			// end early
+			if r.Context().Err() == nil {
+				slog.Error("events stream export failed", "events", n, "error", err)
+			}
+			return
+		}
+	}
+}
+
+// eventsDeleteRequest is the body of POST /api/v1/events/delete. The
+// filter fields are the query parameters of GET /api/v1/events.
+type eventsDeleteRequest struct {
+	Type         string `json:"type"`
+	Since        string `json:"since"`
+	Until        string `json:"until"`
+	InstanceType string `json:"instance_type"`
+	Region       string `json:"region"`
+	World        string `json:"world"`
+	Player       string `json:"player"`
+	GroupID      string `json:"group_id"`
+	Account      string `json:"account"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
+}
+
+// handleEventsDelete handles POST /api/v1/events/delete. A dry run returns
+// the number of matching events and a confirm token; sending the same
+// filter with the token deletes them.
+func (s *Server) handleEventsDelete(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req eventsDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+	filter, err := parseEventsValues(url.Values{
+		"type":          {req.Type},
+		"since":         {req.Since},
+		"until":         {req.Until},
+		"instance_type": {req.InstanceType},
+		"region":        {req.Region},
+		"world":         {req.World},
+		"player":        {req.Player},
+		"group_id":      {req.GroupID},
+		"account":       {req.Account},
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	result, err := s.eventDelete.DeleteEvents(r.Context(), app.DeleteEventsRequest{
+		Filter:       filter,
+		DryRun:       req.DryRun,
+		ConfirmToken: req.ConfirmToken,
+	})
+	if err != nil {
+		if errors.Is(err, app.ErrConfirmRequired) || errors.Is(err, app.ErrInvalidConfirmToken) {
+			writeErrorCode(w, http.StatusBadRequest, ErrCodeConfirmFailed, err.Error(), nil, nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
 }
 
 // parseEventsFilter parses query parameters into a QueryFilter.
 func parseEventsFilter(r *http.Request) (store.QueryFilter, error) {
+	return parseEventsValues(r.URL.Query())
+}
+
+// parseEventsValues parses the parameters of GET /api/v1/events into a
+// QueryFilter; empty values are ignored.
+func parseEventsValues(q url.Values) (store.QueryFilter, error) {
 	var filter store.QueryFilter
-	q := r.URL.Query()
 
 	// Parse 'since' (RFC3339)
 	if s := q.Get("since"); s != "" {
@@ -73,12 +285,43 @@ func parseEventsFilter(r *http.Request) (store.QueryFilter, error) {
 
 	// Parse 'type'
 	if t := q.Get("type"); t != "" {
-		switch t {
-		case event.TypePlayerJoin, event.TypePlayerLeft, event.TypeWorldJoin:
-			filter.Type = &t
-		default:
+		if !event.IsValidType(t) {
 			return filter, fmt.Errorf("invalid type: %s", t)
 		}
+		filter.Type = &t
+	}
+
+	// Parse 'instance_type'
+	if it := q.Get("instance_type"); it != "" {
+		if !instance.IsValidType(it) {
+			return filter, fmt.Errorf("invalid instance_type: %s", it)
+		}
+		filter.InstanceType = &it
+	}
+
+	// Parse 'region'
+	if rg := q.Get("region"); rg != "" {
+		filter.Region = &rg
+	}
+
+	// Parse 'world' (world ID or exact world name)
+	if wd := q.Get("world"); wd != "" {
+		filter.World = &wd
+	}
+
+	// Parse 'player' (player ID or player name, compared normalized)
+	if p := q.Get("player"); p != "" {
+		filter.Player = &p
+	}
+
+	// Parse 'group_id'
+	if g := q.Get("group_id"); g != "" {
+		filter.GroupID = &g
+	}
+
+	// Parse 'account'
+	if a := q.Get("account"); a != "" {
+		filter.Account = &a
 	}
 
 	// Parse 'limit'
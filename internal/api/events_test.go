@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -10,12 +11,15 @@ import (
 
 	"github.com/graaaaa/vrclog-companion/internal/app"
 	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/msgpack"
 	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
 // MockEventsService implements app.EventsUsecase for testing.
 type MockEventsService struct {
-	QueryFunc func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error)
+	QueryFunc     func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error)
+	ChangesFunc   func(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error)
+	AggregateFunc func(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error)
 }
 
 func (m *MockEventsService) Query(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
@@ -25,6 +29,20 @@ func (m *MockEventsService) Query(ctx context.Context, filter store.QueryFilter)
 	return store.QueryResult{}, nil
 }
 
+func (m *MockEventsService) Changes(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error) {
+	if m.ChangesFunc != nil {
+		return m.ChangesFunc(ctx, sinceCursor, limit)
+	}
+	return store.ChangesResult{}, nil
+}
+
+func (m *MockEventsService) Aggregate(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error) {
+	if m.AggregateFunc != nil {
+		return m.AggregateFunc(ctx, filter, groupBy)
+	}
+	return store.AggregateResult{}, nil
+}
+
 func TestEventsEndpoint_Success(t *testing.T) {
 	now := time.Now().UTC()
 	mockEvents := &MockEventsService{
@@ -115,6 +133,48 @@ func TestEventsEndpoint_InvalidType(t *testing.T) {
 	}
 }
 
+func TestEventsEndpoint_InstanceFilters(t *testing.T) {
+	var capturedFilter store.QueryFilter
+	mockEvents := &MockEventsService{
+		QueryFunc: func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+			capturedFilter = filter
+			return store.QueryResult{Items: []event.Event{}}, nil
+		},
+	}
+
+	health := app.HealthService{Version: "test"}
+	server := NewServer(":8080", health, WithEventsUsecase(mockEvents))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events?instance_type=friends_plus&region=jp&group_id=grp_a", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if capturedFilter.InstanceType == nil || *capturedFilter.InstanceType != "friends_plus" {
+		t.Error("expected instance_type filter to be friends_plus")
+	}
+	if capturedFilter.Region == nil || *capturedFilter.Region != "jp" {
+		t.Error("expected region filter to be jp")
+	}
+	if capturedFilter.GroupID == nil || *capturedFilter.GroupID != "grp_a" {
+		t.Error("expected group_id filter to be grp_a")
+	}
+
+	// Unknown instance types are rejected
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/events?instance_type=bogus", nil)
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if code := errorBody(t, rec).Code; code != ErrCodeValidationFailed {
+		t.Errorf("code = %q, want %q", code, ErrCodeValidationFailed)
+	}
+}
+
 func TestEventsEndpoint_InvalidCursor(t *testing.T) {
 	mockEvents := &MockEventsService{
 		QueryFunc: func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
@@ -133,6 +193,73 @@ func TestEventsEndpoint_InvalidCursor(t *testing.T) {
 	if rec.Code != http.StatusBadRequest {
 		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
 	}
+	body := errorBody(t, rec)
+	if body.Code != ErrCodeInvalidCursor || body.Message != "invalid cursor" || body.Error != body.Message {
+		t.Errorf("body = %+v, want code %s", body, ErrCodeInvalidCursor)
+	}
+}
+
+func TestEventsStreamExportEndpoint(t *testing.T) {
+	now := time.Now().UTC()
+	var filters []store.QueryFilter
+	mockEvents := &MockEventsService{
+		QueryFunc: func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+			filters = append(filters, filter)
+			if filter.Cursor == nil {
+				next := "page2"
+				return store.QueryResult{Items: []event.Event{
+					{ID: 1, Type: event.TypePlayerJoin, Ts: now},
+					{ID: 2, Type: event.TypePlayerJoin, Ts: now},
+				}, NextCursor: &next}, nil
+			}
+			if *filter.Cursor != "page2" {
+				return store.QueryResult{}, store.ErrInvalidCursor
+			}
+			return store.QueryResult{Items: []event.Event{{ID: 3, Type: event.TypePlayerJoin, Ts: now}}}, nil
+		},
+	}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithEventsUsecase(mockEvents))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream-export?type=player_join&limit=1", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %s", ct)
+	}
+	// Every page is read, oldest first, with the filter and a full batch
+	dec := json.NewDecoder(rec.Body)
+	var ids []int64
+	for dec.More() {
+		var e event.Event
+		if err := dec.Decode(&e); err != nil {
+			t.Fatalf("decode line: %v", err)
+		}
+		ids = append(ids, e.ID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("ids = %v, want 1 2 3", ids)
+	}
+	if len(filters) != 2 {
+		t.Fatalf("queries = %d, want 2", len(filters))
+	}
+	for _, f := range filters {
+		if f.Order != store.QueryOrderAsc || f.Limit != streamExportBatchSize || f.Type == nil || *f.Type != event.TypePlayerJoin {
+			t.Errorf("filter = %+v, want ascending player_join batches of %d", f, streamExportBatchSize)
+		}
+	}
+
+	// Errors before the first line get an error response
+	for _, url := range []string{"/api/v1/events/stream-export?cursor=bogus", "/api/v1/events/stream-export?type=bogus"} {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", url, rec.Code)
+		}
+	}
 }
 
 func TestEventsEndpoint_WithTimeFilters(t *testing.T) {
@@ -208,3 +335,127 @@ func TestEventsEndpoint_EmptyResult(t *testing.T) {
 		t.Errorf("expected 0 items, got %d", len(resp.Items))
 	}
 }
+
+func TestEventChangesEndpoint(t *testing.T) {
+	var gotCursor string
+	var gotLimit int
+	mockEvents := &MockEventsService{
+		ChangesFunc: func(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error) {
+			if sinceCursor == "bad" {
+				return store.ChangesResult{}, store.ErrInvalidCursor
+			}
+			gotCursor, gotLimit = sinceCursor, limit
+			return store.ChangesResult{
+				Items: []store.EventChange{
+					{Op: store.ChangeUpsert, ID: 1, Event: &event.Event{ID: 1, Type: event.TypePlayerJoin}},
+					{Op: store.ChangeDelete, ID: 2},
+				},
+				NextCursor: "next",
+			}, nil
+		},
+	}
+
+	health := app.HealthService{Version: "test"}
+	server := NewServer(":8080", health, WithEventsUsecase(mockEvents))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/changes?since_cursor=abc&limit=10", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if gotCursor != "abc" || gotLimit != 10 {
+		t.Errorf("got cursor %q limit %d", gotCursor, gotLimit)
+	}
+	var resp struct {
+		Items []struct {
+			Op    string          `json:"op"`
+			ID    int64           `json:"id"`
+			Event json.RawMessage `json:"event"`
+		} `json:"items"`
+		NextCursor string `json:"next_cursor"`
+		HasMore    bool   `json:"has_more"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Items) != 2 || resp.Items[0].Event == nil || resp.Items[1].Op != "delete" || resp.Items[1].Event != nil {
+		t.Errorf("items = %+v", resp.Items)
+	}
+	if resp.NextCursor != "next" || resp.HasMore {
+		t.Errorf("next_cursor = %q, has_more = %v", resp.NextCursor, resp.HasMore)
+	}
+
+	for _, query := range []string{"?since_cursor=bad", "?limit=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events/changes"+query, nil)
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestEventsAggregateEndpoint(t *testing.T) {
+	var gotFilter store.QueryFilter
+	var gotGroupBy string
+	mockEvents := &MockEventsService{
+		AggregateFunc: func(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error) {
+			gotFilter, gotGroupBy = filter, groupBy
+			return store.AggregateResult{GroupBy: groupBy, Total: 3, Groups: 1, Items: []store.EventGroup{{Key: "usr_a", Name: "Alice", Count: 3}}}, nil
+		},
+	}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithEventsUsecase(mockEvents))
+
+	for target, want := range map[string]int{
+		"/api/v1/events/aggregate?group_by=player&since=2024-06-01T00:00:00Z&type=player_join&limit=5": http.StatusOK,
+		"/api/v1/events/aggregate":                       http.StatusBadRequest,
+		"/api/v1/events/aggregate?group_by=region":       http.StatusBadRequest,
+		"/api/v1/events/aggregate?group_by=type&since=x": http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+
+	if gotGroupBy != store.GroupByPlayer || gotFilter.Since == nil || gotFilter.Type == nil || *gotFilter.Type != event.TypePlayerJoin || gotFilter.Limit != 5 {
+		t.Errorf("got group_by %q, filter %+v", gotGroupBy, gotFilter)
+	}
+}
+
+func TestEventsEndpoint_Msgpack(t *testing.T) {
+	items := []event.Event{{ID: 1, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"), Ts: time.Unix(0, 0).UTC()}}
+	mockEvents := &MockEventsService{
+		QueryFunc: func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+			return store.QueryResult{Items: items}, nil
+		},
+	}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithEventsUsecase(mockEvents))
+
+	for accept, wantType := range map[string]string{
+		"":                                       "application/json",
+		"application/json":                       "application/json",
+		"application/msgpack":                    msgpack.ContentType,
+		"text/html, application/x-msgpack;q=0.9": msgpack.ContentType,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Type"); got != wantType {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", accept, got, wantType)
+			continue
+		}
+		if wantType != msgpack.ContentType {
+			continue
+		}
+		want, _ := msgpack.Marshal(eventsResponse{Items: items})
+		if !bytes.Equal(rec.Body.Bytes(), want) {
+			t.Errorf("Accept %q: body = % x, want % x", accept, rec.Body.Bytes(), want)
+		}
+	}
+}
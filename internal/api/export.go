@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// handleExportEvents handles GET /api/v1/export/events, streaming events
+// as JSONL or Parquet. Query: destination (default "default"),
+// incremental=true for only the events since the last export to that
+// destination, and format (jsonl or parquet).
+func (s *Server) handleExportEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := app.ExportRequest{Destination: q.Get("destination"), Format: q.Get("format")}
+	if mask := s.eventMask(r); mask != (EventMask{}) {
+		req.Mask = mask.Apply
+	}
+	if v := q.Get("incremental"); v != "" {
+		incremental, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid incremental: "+v, nil)
+			return
+		}
+		req.Incremental = incremental
+	}
+
+	dest := req.Destination
+	if dest == "" {
+		dest = app.DefaultExportDestination
+	}
+	contentType, ext := "application/x-ndjson", ".jsonl"
+	if req.Format == app.ExportFormatParquet {
+		contentType, ext = "application/vnd.apache.parquet", ".parquet"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="events-`+dest+`-`+time.Now().Format("20060102-150405")+ext+`"`)
+
+	cw := &countingWriter{w: w}
+	if _, err := s.export.ExportEvents(r.Context(), cw, req); err != nil {
+		if cw.n > 0 {
+			// Too late for an error response; the client sees a short
+			// export, and the cursor was not advanced
+			slog.Error("export failed", "destination", dest, "error", err)
+			return
+		}
+		w.Header().Del("Content-Disposition")
+		if errors.Is(err, app.ErrInvalidDestination) || errors.Is(err, app.ErrInvalidExportFormat) {
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	}
+}
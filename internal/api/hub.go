@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"sync"
 
+	"github.com/graaaaa/vrclog-companion/internal/derive"
 	"github.com/graaaaa/vrclog-companion/internal/event"
 )
 
@@ -13,81 +14,189 @@ const (
 	defaultBroadcastBufferSize  = 64
 )
 
-// Subscriber represents an SSE client connection.
-type Subscriber struct {
-	events chan *event.Event
+// DefaultHubHistorySize is how many recent events NewHub keeps for
+// replaying to reconnecting SSE clients, see Broadcaster.Since.
+const DefaultHubHistorySize = 256
+
+// Hub broadcasts raw events to SSE subscribers.
+type Hub = Broadcaster[event.Event]
+
+// DerivedHub broadcasts derived events to SSE subscribers.
+type DerivedHub = Broadcaster[derive.DerivedEvent]
+
+// ActionHub routes UI actions, such as a notification's "show player"
+// button, to the web UIs listening on the actions stream.
+type ActionHub = Broadcaster[Action]
+
+// Subscriber represents an SSE client connection to a Hub.
+type Subscriber = Subscription[event.Event]
+
+// Subscription represents an SSE client connection.
+type Subscription[T any] struct {
+	events chan *T
 	done   chan struct{}
 }
 
 // Events returns the channel for receiving events.
-func (s *Subscriber) Events() <-chan *event.Event {
+func (s *Subscription[T]) Events() <-chan *T {
 	return s.events
 }
 
 // Done returns a channel that is closed when the subscriber is unsubscribed.
-func (s *Subscriber) Done() <-chan struct{} {
+func (s *Subscription[T]) Done() <-chan struct{} {
 	return s.done
 }
 
-// Hub manages SSE subscribers and broadcasts events.
+// Broadcaster manages SSE subscribers and broadcasts events of type *T.
 // Uses 1 goroutine + channel management pattern for thread safety.
-type Hub struct {
-	register   chan *Subscriber
-	unregister chan *Subscriber
-	broadcast  chan *event.Event
+type Broadcaster[T any] struct {
+	register   chan *Subscription[T]
+	unregister chan *Subscription[T]
+	broadcast  chan *T
 	stop       chan struct{}
 	stopped    chan struct{}
 	stopOnce   sync.Once
 
+	// running is whether Run is active. After a panic Run may be restarted,
+	// so stopped is closed by whichever of Run and Stop sees the other gone
+	runMu       sync.Mutex
+	running     bool
+	stoppedOnce sync.Once
+
+	subscriberBufferSize int
+	logger               *slog.Logger
+	logAttrs             func(*T) []any // identifies dropped events in logs
+
+	// Ring buffer of the last events broadcast, written by Run
+	historyMu    sync.RWMutex
+	history      []*T // capacity is the history size, 0 keeps none
+	historyStart int  // index of the oldest event once the ring is full
+}
+
+// hubConfig holds the settings shared by all Broadcaster types.
+type hubConfig struct {
 	subscriberBufferSize int
 	logger               *slog.Logger
+	historySize          int
 }
 
-// HubOption configures a Hub.
-type HubOption func(*Hub)
+// HubOption configures a Hub, DerivedHub or ActionHub.
+type HubOption func(*hubConfig)
 
 // WithHubSubscriberBufferSize sets the buffer size for subscriber event channels.
 func WithHubSubscriberBufferSize(size int) HubOption {
-	return func(h *Hub) {
+	return func(c *hubConfig) {
 		if size > 0 {
-			h.subscriberBufferSize = size
+			c.subscriberBufferSize = size
 		}
 	}
 }
 
 // WithHubLogger sets the logger for the Hub.
 func WithHubLogger(logger *slog.Logger) HubOption {
-	return func(h *Hub) {
+	return func(c *hubConfig) {
 		if logger != nil {
-			h.logger = logger
+			c.logger = logger
 		}
 	}
 }
 
-// NewHub creates a new SSE hub.
+// WithHubHistory sets how many recent events the hub keeps for replay.
+// 0 keeps none. NewHub keeps DefaultHubHistorySize by default, the other
+// hubs none.
+func WithHubHistory(size int) HubOption {
+	return func(c *hubConfig) {
+		if size >= 0 {
+			c.historySize = size
+		}
+	}
+}
+
+// NewHub creates a new SSE hub for raw events.
 // Call Run() to start the hub's event loop.
 func NewHub(opts ...HubOption) *Hub {
-	h := &Hub{
-		register:             make(chan *Subscriber),
-		unregister:           make(chan *Subscriber),
-		broadcast:            make(chan *event.Event, defaultBroadcastBufferSize),
-		stop:                 make(chan struct{}),
-		stopped:              make(chan struct{}),
+	opts = append([]HubOption{WithHubHistory(DefaultHubHistorySize)}, opts...)
+	return newBroadcaster(func(e *event.Event) []any {
+		return []any{"event_id", e.ID, "event_type", e.Type}
+	}, opts...)
+}
+
+// NewDerivedHub creates a new SSE hub for derived events.
+// Call Run() to start the hub's event loop.
+func NewDerivedHub(opts ...HubOption) *DerivedHub {
+	return newBroadcaster(func(e *derive.DerivedEvent) []any {
+		return []any{"derived_type", e.Type}
+	}, opts...)
+}
+
+// NewActionHub creates a new SSE hub for UI actions.
+// Call Run() to start the hub's event loop.
+func NewActionHub(opts ...HubOption) *ActionHub {
+	return newBroadcaster(func(a *Action) []any {
+		return []any{"action_type", a.Type}
+	}, opts...)
+}
+
+func newBroadcaster[T any](logAttrs func(*T) []any, opts ...HubOption) *Broadcaster[T] {
+	cfg := hubConfig{
 		subscriberBufferSize: defaultSubscriberBufferSize,
 		logger:               slog.Default(),
 	}
 	for _, opt := range opts {
-		opt(h)
+		opt(&cfg)
+	}
+	return &Broadcaster[T]{
+		register:             make(chan *Subscription[T]),
+		unregister:           make(chan *Subscription[T]),
+		broadcast:            make(chan *T, defaultBroadcastBufferSize),
+		stop:                 make(chan struct{}),
+		stopped:              make(chan struct{}),
+		subscriberBufferSize: cfg.subscriberBufferSize,
+		logger:               cfg.logger,
+		logAttrs:             logAttrs,
+		history:              make([]*T, 0, cfg.historySize),
 	}
-	return h
 }
 
 // Run starts the hub's event loop.
 // This method blocks until Stop() is called.
 // Should be called in a goroutine: go hub.Run()
-func (h *Hub) Run() {
-	clients := make(map[*Subscriber]struct{})
-	defer close(h.stopped)
+//
+// If the loop panics, the subscribers are dropped, so their clients
+// reconnect and replay what they missed, and the panic is re-raised. Run
+// may then be called again to restart the hub.
+func (h *Broadcaster[T]) Run() {
+	h.runMu.Lock()
+	select {
+	case <-h.stop:
+		h.runMu.Unlock()
+		h.markStopped()
+		return
+	default:
+	}
+	h.running = true
+	h.runMu.Unlock()
+
+	clients := make(map[*Subscription[T]]struct{})
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		for sub := range clients {
+			close(sub.done)
+			close(sub.events)
+		}
+		h.runMu.Lock()
+		h.running = false
+		h.runMu.Unlock()
+		select {
+		case <-h.stop:
+			h.markStopped()
+		default:
+		}
+		panic(v)
+	}()
 
 	for {
 		select {
@@ -104,16 +213,14 @@ func (h *Hub) Run() {
 			}
 
 		case e := <-h.broadcast:
+			h.record(e)
 			for sub := range clients {
 				select {
 				case sub.events <- e:
 					// Event sent successfully
 				default:
 					// Channel full, drop event for this subscriber
-					h.logger.Warn("subscriber channel full, event dropped",
-						"event_id", e.ID,
-						"event_type", e.Type,
-					)
+					h.logger.Warn("subscriber channel full, event dropped", h.logAttrs(e)...)
 				}
 			}
 
@@ -123,6 +230,7 @@ func (h *Hub) Run() {
 				close(sub.done)
 				close(sub.events)
 			}
+			h.markStopped()
 			return
 		}
 	}
@@ -131,18 +239,29 @@ func (h *Hub) Run() {
 // Stop stops the hub's event loop.
 // Blocks until the hub has fully stopped.
 // Safe to call multiple times (idempotent).
-func (h *Hub) Stop() {
+func (h *Broadcaster[T]) Stop() {
 	h.stopOnce.Do(func() {
 		close(h.stop)
 	})
+	h.runMu.Lock()
+	if !h.running {
+		// Not started, or crashed and not restarted yet
+		h.markStopped()
+	}
+	h.runMu.Unlock()
 	<-h.stopped
 }
 
+// markStopped closes stopped once.
+func (h *Broadcaster[T]) markStopped() {
+	h.stoppedOnce.Do(func() { close(h.stopped) })
+}
+
 // Subscribe creates a new subscriber.
 // The caller must call Unsubscribe when done.
-func (h *Hub) Subscribe() *Subscriber {
-	sub := &Subscriber{
-		events: make(chan *event.Event, h.subscriberBufferSize),
+func (h *Broadcaster[T]) Subscribe() *Subscription[T] {
+	sub := &Subscription[T]{
+		events: make(chan *T, h.subscriberBufferSize),
 		done:   make(chan struct{}),
 	}
 
@@ -158,7 +277,7 @@ func (h *Hub) Subscribe() *Subscriber {
 }
 
 // Unsubscribe removes a subscriber.
-func (h *Hub) Unsubscribe(sub *Subscriber) {
+func (h *Broadcaster[T]) Unsubscribe(sub *Subscription[T]) {
 	if sub == nil {
 		return
 	}
@@ -170,9 +289,46 @@ func (h *Hub) Unsubscribe(sub *Subscriber) {
 	}
 }
 
+// record adds e to the history, replacing the oldest event once full.
+func (h *Broadcaster[T]) record(e *T) {
+	if cap(h.history) == 0 {
+		return
+	}
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	if len(h.history) < cap(h.history) {
+		h.history = append(h.history, e)
+		return
+	}
+	h.history[h.historyStart] = e
+	h.historyStart = (h.historyStart + 1) % len(h.history)
+}
+
+// Since returns the events broadcast after the latest kept one for which
+// isLast returns true, oldest first, e.g. those a reconnecting client has
+// missed since the last event it received. ok is false if no kept event
+// matches, as it has left the history or was never broadcast; the caller
+// then has to replay from the store.
+func (h *Broadcaster[T]) Since(isLast func(*T) bool) (events []*T, ok bool) {
+	h.historyMu.RLock()
+	defer h.historyMu.RUnlock()
+	n := len(h.history)
+	for i := n - 1; i >= 0; i-- {
+		if !isLast(h.history[(h.historyStart+i)%n]) {
+			continue
+		}
+		events = make([]*T, 0, n-1-i)
+		for j := i + 1; j < n; j++ {
+			events = append(events, h.history[(h.historyStart+j)%n])
+		}
+		return events, true
+	}
+	return nil, false
+}
+
 // Publish sends an event to all subscribers.
 // Non-blocking: if the broadcast channel is full, the event is dropped.
-func (h *Hub) Publish(e *event.Event) {
+func (h *Broadcaster[T]) Publish(e *T) {
 	if e == nil {
 		return
 	}
@@ -184,9 +340,6 @@ func (h *Hub) Publish(e *event.Event) {
 		// Hub is stopped
 	default:
 		// Broadcast channel full
-		h.logger.Warn("broadcast channel full, event dropped",
-			"event_id", e.ID,
-			"event_type", e.Type,
-		)
+		h.logger.Warn("broadcast channel full, event dropped", h.logAttrs(e)...)
 	}
 }
@@ -291,3 +291,110 @@ func TestHub_StopIdempotent(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestHub_Since(t *testing.T) {
+	hub := NewHub(WithHubHistory(3))
+	go hub.Run()
+	defer hub.Stop()
+
+	sub := hub.Subscribe()
+	defer hub.Unsubscribe(sub)
+	for id := int64(1); id <= 5; id++ {
+		hub.Publish(&event.Event{ID: id, Type: event.TypePlayerJoin})
+		<-sub.Events() // recorded once delivered
+	}
+
+	since := func(id int64) ([]int64, bool) {
+		events, ok := hub.Since(func(e *event.Event) bool { return e.ID == id })
+		var ids []int64
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		return ids, ok
+	}
+	if ids, ok := since(3); !ok || len(ids) != 2 || ids[0] != 4 || ids[1] != 5 {
+		t.Errorf("Since(3) = %v, %v, want [4 5], true", ids, ok)
+	}
+	if ids, ok := since(5); !ok || len(ids) != 0 {
+		t.Errorf("Since(5) = %v, %v, want [], true", ids, ok)
+	}
+	if _, ok := since(2); ok {
+		t.Error("Since(2) found an event that left the history")
+	}
+
+	off := NewHub(WithHubHistory(0))
+	go off.Run()
+	defer off.Stop()
+	off.Publish(&event.Event{ID: 1})
+	if _, ok := off.Since(func(*event.Event) bool { return true }); ok {
+		t.Error("Since found an event with history disabled")
+	}
+}
+
+func TestHub_RestartAfterPanic(t *testing.T) {
+	// logAttrs runs when a subscriber's channel is full
+	hub := newBroadcaster(func(*int) []any { panic("boom") }, WithHubSubscriberBufferSize(1))
+	crashed := make(chan any, 1)
+	run := func() {
+		defer func() { crashed <- recover() }()
+		hub.Run()
+	}
+	go run()
+
+	sub := hub.Subscribe()
+	one, two := 1, 2
+	hub.Publish(&one)
+	hub.Publish(&two)
+	select {
+	case v := <-crashed:
+		if v != "boom" {
+			t.Fatalf("Run panicked with %v, want boom", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not panic")
+	}
+	select {
+	case <-sub.Done():
+	default:
+		t.Error("subscriber not dropped after the panic")
+	}
+
+	go run()
+	sub = hub.Subscribe()
+	hub.Publish(&one)
+	select {
+	case e := <-sub.Events():
+		if *e != 1 {
+			t.Errorf("event = %d, want 1", *e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restarted hub did not deliver")
+	}
+	hub.Stop()
+}
+
+func TestHub_StopAfterPanic(t *testing.T) {
+	hub := newBroadcaster(func(*int) []any { panic("boom") }, WithHubSubscriberBufferSize(1))
+	crashed := make(chan struct{})
+	go func() {
+		defer func() { recover(); close(crashed) }()
+		hub.Run()
+	}()
+	hub.Subscribe()
+	one := 1
+	hub.Publish(&one)
+	hub.Publish(&one)
+	<-crashed
+
+	// Not restarted; Stop must not wait for it
+	stopped := make(chan struct{})
+	go func() {
+		hub.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked after the hub crashed")
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
+
+// ingestEventsRequest is the request body for POST /api/v1/ingest/events.
+type ingestEventsRequest struct {
+	Events []ingest.Event `json:"events"`
+}
+
+// handleIngestPause handles POST /api/v1/ingest/pause requests.
+func (s *Server) handleIngestPause(w http.ResponseWriter, r *http.Request) {
+	if s.ingest == nil {
+		writeError(w, http.StatusServiceUnavailable, "ingest control not available", nil)
+		return
+	}
+
+	result := s.ingest.Pause(r.Context())
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleIngestResume handles POST /api/v1/ingest/resume requests.
+func (s *Server) handleIngestResume(w http.ResponseWriter, r *http.Request) {
+	if s.ingest == nil {
+		writeError(w, http.StatusServiceUnavailable, "ingest control not available", nil)
+		return
+	}
+
+	result := s.ingest.Resume(r.Context())
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleIngestEvents handles POST /api/v1/ingest/events requests from
+// remote agents (vrclog --forward-to).
+func (s *Server) handleIngestEvents(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 8<<20)
+	var req ingestEventsRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	result, err := s.receive.Receive(r.Context(), req.Events)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidForwardedEvent) {
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
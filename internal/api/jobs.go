@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+)
+
+// jobsResponse is the response of GET /api/v1/jobs.
+type jobsResponse struct {
+	Items []schedule.Status `json:"items"`
+}
+
+// handleJobs handles GET /api/v1/jobs.
+// Lists the scheduled jobs with their last and next runs.
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jobsResponse{Items: s.jobs.Jobs(r.Context())})
+}
+
+// handleRunJob handles POST /api/v1/jobs/{name}/run.
+// Starts the job in the background and returns 202 with its status; poll
+// GET /api/v1/jobs for the outcome.
+func (s *Server) handleRunJob(w http.ResponseWriter, r *http.Request) {
+	status, err := s.jobs.TriggerJob(r.Context(), r.PathValue("name"))
+	switch {
+	case errors.Is(err, schedule.ErrUnknownJob):
+		writeError(w, http.StatusNotFound, "job not found", nil)
+	case errors.Is(err, schedule.ErrJobRunning):
+		writeError(w, http.StatusConflict, err.Error(), nil)
+	case errors.Is(err, schedule.ErrNotRunning):
+		writeError(w, http.StatusServiceUnavailable, err.Error(), nil)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	default:
+		writeJSON(w, http.StatusAccepted, status)
+	}
+}
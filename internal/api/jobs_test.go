@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+)
+
+func TestJobsEndpoints(t *testing.T) {
+	release := make(chan struct{})
+	sched := schedule.New()
+	sched.Add(schedule.Job{Name: "backup", Schedule: schedule.Every(time.Hour), Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithJobsUsecase(app.JobsService{Scheduler: sched}))
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/api/v1/jobs/backup/run"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("run before start: status %d, want 503", rec.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		close(release)
+		cancel()
+		<-done
+	}()
+	for sched.Jobs()[0].NextRun == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := do(http.MethodPost, "/api/v1/jobs/backup/run")
+	var status schedule.Status
+	if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if rec.Code != http.StatusAccepted || status.Name != "backup" || !status.Running {
+		t.Errorf("run: status %d, body %+v, want 202 running", rec.Code, status)
+	}
+	if rec := do(http.MethodPost, "/api/v1/jobs/backup/run"); rec.Code != http.StatusConflict {
+		t.Errorf("run while running: status %d, want 409", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/v1/jobs/nope/run"); rec.Code != http.StatusNotFound {
+		t.Errorf("run unknown: status %d, want 404", rec.Code)
+	}
+
+	rec = do(http.MethodGet, "/api/v1/jobs")
+	var list jobsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Schedule != "every 1h0m0s" || list.Items[0].NextRun == nil {
+		t.Errorf("list = %+v", list.Items)
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/logging"
+)
+
+// handleLogsTail handles GET /api/v1/logs/tail.
+// Query parameters:
+//   - lines: number of records, 1 to app.MaxLogTailLines (default app.DefaultLogTailLines)
+//   - level: minimum level, debug, info, warn or error (default debug)
+func (s *Server) handleLogsTail(w http.ResponseWriter, r *http.Request) {
+	lines := app.DefaultLogTailLines
+	if l := r.URL.Query().Get("lines"); l != "" {
+		var err error
+		lines, err = strconv.Atoi(l)
+		if err != nil || lines < 1 || lines > app.MaxLogTailLines {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid lines: %s (1-%d)", l, app.MaxLogTailLines), nil)
+			return
+		}
+	}
+
+	minLevel := slog.LevelDebug
+	if l := r.URL.Query().Get("level"); l != "" {
+		var err error
+		minLevel, err = logging.ParseLevel(l)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid level: %s", l), nil)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, s.logs.Tail(r.Context(), lines, minLevel))
+}
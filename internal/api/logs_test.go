@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/logging"
+)
+
+func TestLogsTailEndpoint(t *testing.T) {
+	buf := logging.NewBuffer(10)
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	logger.Debug("replaying events")
+	logger.Warn("subscriber channel full", "type", "player_join")
+	logger.Info("server started")
+
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithLogsUsecase(app.LogsService{Buffer: buf, File: "/data/logs/vrclog.log"}),
+		WithBasicAuth("admin", "secret"),
+	)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantMsgs   string
+	}{
+		{"", http.StatusOK, "replaying events,subscriber channel full,server started"},
+		{"?lines=2", http.StatusOK, "subscriber channel full,server started"},
+		{"?level=WARN", http.StatusOK, "subscriber channel full"},
+		{"?lines=0", http.StatusBadRequest, ""},
+		{"?lines=100000", http.StatusBadRequest, ""},
+		{"?level=trace", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/logs/tail"+tt.query, nil)
+			req.SetBasicAuth("admin", "secret")
+			rec := httptest.NewRecorder()
+			server.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp app.LogTail
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var msgs []string
+			for _, e := range resp.Items {
+				msgs = append(msgs, e.Message)
+			}
+			if got := strings.Join(msgs, ","); got != tt.wantMsgs {
+				t.Errorf("messages = %q, want %q", got, tt.wantMsgs)
+			}
+			if resp.File != "/data/logs/vrclog.log" {
+				t.Errorf("file = %q", resp.File)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/logs/tail", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without credentials, got %d", http.StatusUnauthorized, rec.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+// handleVacuumStatus handles GET /api/v1/admin/vacuum.
+func (s *Server) handleVacuumStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.maintenance.VacuumStatus(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleVacuum handles POST /api/v1/admin/vacuum. The request blocks until
+// VACUUM finishes.
+func (s *Server) handleVacuum(w http.ResponseWriter, r *http.Request) {
+	status, err := s.maintenance.Vacuum(r.Context())
+	if err != nil {
+		if errors.Is(err, app.ErrVacuumRunning) {
+			writeError(w, http.StatusConflict, err.Error(), nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
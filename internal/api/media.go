@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// handleMedia handles GET /api/v1/media.
+// Accepts the same filters as /api/v1/events (type is ignored).
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventsFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	page, err := s.media.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []app.MediaPlay{}
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// handleMemories handles GET /api/v1/memories, what happened on this day
+// in earlier years.
+// Query parameters:
+//   - date: YYYY-MM-DD in the server's local time (default today)
+//   - account: restrict to one account
+func (s *Server) handleMemories(w http.ResponseWriter, r *http.Request) {
+	date := time.Now()
+	if d := r.URL.Query().Get("date"); d != "" {
+		var err error
+		date, err = time.ParseInLocation("2006-01-02", d, time.Local)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid date: %s (YYYY-MM-DD)", d), nil)
+			return
+		}
+	}
+
+	result, err := s.memories.OnThisDay(r.Context(), date, r.URL.Query().Get("account"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
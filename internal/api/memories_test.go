@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+type stubMemoriesUsecase struct {
+	gotDate    time.Time
+	gotAccount string
+}
+
+func (s *stubMemoriesUsecase) OnThisDay(ctx context.Context, date time.Time, account string) (app.Memories, error) {
+	s.gotDate, s.gotAccount = date, account
+	return app.Memories{Date: date.Format("2006-01-02")}, nil
+}
+
+func TestMemoriesEndpoint(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantDate   string
+	}{
+		{"", http.StatusOK, time.Now().Format("2006-01-02")},
+		{"?date=2024-06-15&account=alt", http.StatusOK, "2024-06-15"},
+		{"?date=2024-6-15", http.StatusBadRequest, ""},
+		{"?date=2024-02-30", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stub := &stubMemoriesUsecase{}
+			server := NewServer(":8080", app.HealthService{Version: "test"}, WithMemoriesUsecase(stub))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/memories"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			server.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := stub.gotDate.Format("2006-01-02"); got != tt.wantDate || stub.gotDate.Location() != time.Local {
+				t.Errorf("date = %v, want %s local", stub.gotDate, tt.wantDate)
+			}
+		})
+	}
+}
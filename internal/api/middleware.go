@@ -1,10 +1,15 @@
 package api
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
+	"maps"
 	"net/http"
+	"net/netip"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -37,8 +42,8 @@ func corsMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
 			if allowed {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 				w.Header().Set("Vary", "Origin")
-				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+PassphraseHeader+", "+CSRFHeader)
 				if cfg.AllowCredentials {
 					w.Header().Set("Access-Control-Allow-Credentials", "true")
 				}
@@ -60,6 +65,10 @@ func corsMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
 	}
 }
 
+// AgentHeader marks requests from non-browser clients such as remote
+// agents, which send no Origin or Referer header.
+const AgentHeader = "X-VRClog-Agent"
+
 // csrfMiddleware returns a middleware that validates Origin/Referer headers
 // for state-changing requests (POST, PUT, DELETE) to prevent CSRF attacks.
 func csrfMiddleware(allowedHosts []string) func(http.Handler) http.Handler {
@@ -76,7 +85,7 @@ func csrfMiddleware(allowedHosts []string) func(http.Handler) http.Handler {
 			if origin != "" {
 				originURL, err := url.Parse(origin)
 				if err != nil || !isAllowedHost(originURL.Host, allowedHosts) {
-					writeError(w, http.StatusForbidden, "Forbidden: invalid origin", nil)
+					writeErrorCode(w, http.StatusForbidden, ErrCodeCSRFFailed, "Forbidden: invalid origin", nil, nil)
 					return
 				}
 				next.ServeHTTP(w, r)
@@ -88,15 +97,22 @@ func csrfMiddleware(allowedHosts []string) func(http.Handler) http.Handler {
 			if referer != "" {
 				refererURL, err := url.Parse(referer)
 				if err != nil || !isAllowedHost(refererURL.Host, allowedHosts) {
-					writeError(w, http.StatusForbidden, "Forbidden: invalid referer", nil)
+					writeErrorCode(w, http.StatusForbidden, ErrCodeCSRFFailed, "Forbidden: invalid referer", nil, nil)
 					return
 				}
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			// Remote agents send neither; browsers cannot add a custom header
+			// cross-site without a CORS preflight, which is refused
+			if r.Header.Get(AgentHeader) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			// Neither Origin nor Referer present - reject for safety
-			writeError(w, http.StatusForbidden, "Forbidden: missing origin/referer", nil)
+			writeErrorCode(w, http.StatusForbidden, ErrCodeCSRFFailed, "Forbidden: missing origin/referer", nil, nil)
 		})
 	}
 }
@@ -129,43 +145,164 @@ func isAllowedHost(host string, allowedHosts []string) bool {
 	return false
 }
 
-// securityHeadersMiddleware adds security headers to all responses.
-// These headers protect against common web vulnerabilities.
-func securityHeadersMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Prevent MIME type sniffing
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-
-		// Prevent clickjacking
-		w.Header().Set("X-Frame-Options", "DENY")
-
-		// Control referrer information
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-
-		// Content Security Policy
-		// Note: 'unsafe-inline' for style-src is needed for React inline styles
-		csp := strings.Join([]string{
-			"default-src 'self'",
-			"script-src 'self'",
-			"style-src 'self' 'unsafe-inline'",
-			"img-src 'self' data:",
-			"connect-src 'self'",
-			"font-src 'self'",
-			"base-uri 'none'",
-			"frame-ancestors 'none'",
-			"form-action 'self'",
-		}, "; ")
-		w.Header().Set("Content-Security-Policy", csp)
-
-		// Restrict browser features
-		w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-
-		// Prevent cross-origin attacks
-		w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
-		w.Header().Set("Cross-Origin-Resource-Policy", "same-origin")
-
-		next.ServeHTTP(w, r)
-	})
+// IPFilter limits which client IPs may use the server. Denied takes
+// precedence over Allowed, and an empty Allowed allows every IP not
+// denied. Loopback clients are always allowed, so the PC running the
+// server cannot lock itself out.
+type IPFilter struct {
+	Allowed []netip.Prefix
+	Denied  []netip.Prefix
+}
+
+// Permits reports whether a client at addr may use the server.
+func (f IPFilter) Permits(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, p := range f.Denied {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.Allowed) == 0 {
+		return true
+	}
+	for _, p := range f.Allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipFilterMiddleware refuses requests from clients f does not permit
+// before they reach authentication.
+func ipFilterMiddleware(f IPFilter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, err := netip.ParseAddr(extractIP(r))
+			if err != nil || !f.Permits(addr) {
+				writeError(w, http.StatusForbidden, "Forbidden", nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// cspDirective is a directive of the Content Security Policy with its
+// sources.
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// defaultCSP is the Content Security Policy of every response.
+// Note: 'unsafe-inline' for style-src is needed for React inline styles
+var defaultCSP = []cspDirective{
+	{"default-src", []string{"'self'"}},
+	{"script-src", []string{"'self'", cspNoncePlaceholder}},
+	{"style-src", []string{"'self'", "'unsafe-inline'"}},
+	{"img-src", []string{"'self'", "data:"}},
+	{"connect-src", []string{"'self'"}},
+	{"font-src", []string{"'self'"}},
+	{"base-uri", []string{"'none'"}},
+	{"frame-ancestors", []string{"'none'"}},
+	{"form-action", []string{"'self'"}},
+}
+
+// cspNoncePlaceholder stands for the nonce of each response in a policy.
+const cspNoncePlaceholder = "'nonce-{nonce}'"
+
+// CSPSources adds sources to directives of the Content Security Policy,
+// e.g. {"script-src": {"https://widgets.example.com"}} for a custom web UI
+// that embeds a widget. Directives not in the default policy are added.
+type CSPSources map[string][]string
+
+// buildCSP returns the default policy plus extra, with
+// cspNoncePlaceholder in script-src.
+func buildCSP(extra CSPSources) string {
+	seen := make(map[string]bool, len(defaultCSP))
+	var parts []string
+	for _, d := range defaultCSP {
+		seen[d.name] = true
+		parts = append(parts, strings.Join(append(append([]string{d.name}, d.sources...), extra[d.name]...), " "))
+	}
+	names := slices.Sorted(maps.Keys(extra))
+	for _, name := range names {
+		if !seen[name] && len(extra[name]) > 0 {
+			parts = append(parts, strings.Join(append([]string{name}, extra[name]...), " "))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// cspPolicyKey is the context key of the Content Security Policy of a
+// response, with cspNoncePlaceholder in script-src.
+type cspPolicyKey struct{}
+
+// setCSPNonce allows inline scripts carrying the returned nonce in the
+// response to r, for handlers that generate pages: <script nonce="...">.
+// It must be called before the header is written, and returns "" outside
+// securityHeadersMiddleware.
+func setCSPNonce(w http.ResponseWriter, r *http.Request) string {
+	csp, ok := r.Context().Value(cspPolicyKey{}).(string)
+	if !ok {
+		return ""
+	}
+	nonce := rand.Text()
+	w.Header().Set("Content-Security-Policy", strings.Replace(csp, cspNoncePlaceholder, "'nonce-"+nonce+"'", 1))
+	return nonce
+}
+
+// securityHeadersMiddleware returns a middleware that adds security headers
+// to all responses. These headers protect against common web
+// vulnerabilities. The Content Security Policy is the default plus extra;
+// handlers add a nonce for inline scripts with setCSPNonce.
+func securityHeadersMiddleware(extra CSPSources) func(http.Handler) http.Handler {
+	csp := buildCSP(extra)
+	noNonce := strings.Replace(csp, " "+cspNoncePlaceholder, "", 1)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Prevent MIME type sniffing
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+
+			// Prevent clickjacking
+			w.Header().Set("X-Frame-Options", "DENY")
+
+			// Control referrer information
+			w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+
+			// Content Security Policy
+			w.Header().Set("Content-Security-Policy", noNonce)
+
+			// Restrict browser features
+			w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
+
+			// Prevent cross-origin attacks
+			w.Header().Set("Cross-Origin-Opener-Policy", "same-origin")
+			w.Header().Set("Cross-Origin-Resource-Policy", "same-origin")
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cspPolicyKey{}, csp)))
+		})
+	}
+}
+
+// timeoutMiddleware cancels the request context after d, so store queries
+// give up instead of queueing behind a slow one. writeError turns the
+// resulting deadline errors into 503. d <= 0 disables it.
+func timeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 // constantTimeEqualString compares two strings in constant time.
@@ -186,9 +323,7 @@ func basicAuthMiddleware(username, password string, afl *AuthFailureLimiter) fun
 
 			// Check if IP is locked out
 			if afl != nil && afl.IsLocked(ip) {
-				seconds := afl.LockoutSecondsRemaining(ip)
-				w.Header().Set("Retry-After", formatRetryAfter(seconds))
-				writeError(w, http.StatusTooManyRequests, "Too Many Requests", nil)
+				writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
 				return
 			}
 
@@ -210,9 +345,7 @@ func basicAuthMiddleware(username, password string, afl *AuthFailureLimiter) fun
 				if afl != nil {
 					if afl.RecordFailure(ip) < 0 {
 						// IP is now locked out
-						seconds := afl.LockoutSecondsRemaining(ip)
-						w.Header().Set("Retry-After", formatRetryAfter(seconds))
-						writeError(w, http.StatusTooManyRequests, "Too Many Requests", nil)
+						writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
 						return
 					}
 				}
@@ -249,9 +382,7 @@ func sseTokenMiddleware(username, password string, sseSecret []byte, afl *AuthFa
 
 			// Check if IP is locked out
 			if afl != nil && afl.IsLocked(ip) {
-				seconds := afl.LockoutSecondsRemaining(ip)
-				w.Header().Set("Retry-After", formatRetryAfter(seconds))
-				writeError(w, http.StatusTooManyRequests, "Too Many Requests", nil)
+				writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
 				return
 			}
 
@@ -282,9 +413,7 @@ func sseTokenMiddleware(username, password string, sseSecret []byte, afl *AuthFa
 			// Neither auth method succeeded
 			if afl != nil {
 				if afl.RecordFailure(ip) < 0 {
-					seconds := afl.LockoutSecondsRemaining(ip)
-					w.Header().Set("Retry-After", formatRetryAfter(seconds))
-					writeError(w, http.StatusTooManyRequests, "Too Many Requests", nil)
+					writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
 					return
 				}
 			}
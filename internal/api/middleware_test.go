@@ -1,8 +1,13 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -12,8 +17,48 @@ var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 })
 
+// errorBody decodes a JSON error response.
+func errorBody(t *testing.T, rec *httptest.ResponseRecorder) errorResponse {
+	t.Helper()
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error response %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
 // --- CSRF Middleware Tests ---
 
+func TestCORSMiddleware_Preflight(t *testing.T) {
+	handler := corsMiddleware(CORSConfig{AllowedOrigins: []string{"http://localhost:5173"}})(okHandler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/pins/1", nil)
+	req.Header.Set("Origin", "http://localhost:5173")
+	req.Header.Set("Access-Control-Request-Method", http.MethodDelete)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:5173" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	methods := strings.Split(rec.Header().Get("Access-Control-Allow-Methods"), ", ")
+	for _, m := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		if !slices.Contains(methods, m) {
+			t.Errorf("Access-Control-Allow-Methods = %v, want %s", methods, m)
+		}
+	}
+
+	req.Header.Set("Origin", "http://evil.example")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin: status %d, Allow-Origin %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
 func TestCSRFMiddleware_AllowsValidOrigin(t *testing.T) {
 	mw := csrfMiddleware([]string{"example.com"})
 
@@ -79,6 +124,30 @@ func TestCSRFMiddleware_RejectsMissingOriginAndReferer(t *testing.T) {
 	}
 }
 
+func TestCSRFMiddleware_AllowsAgentWithoutOrigin(t *testing.T) {
+	mw := csrfMiddleware([]string{"example.com"})
+
+	req := httptest.NewRequest(http.MethodPost, "/test", nil)
+	req.Header.Set(AgentHeader, "1")
+	rec := httptest.NewRecorder()
+
+	mw(okHandler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for agent request, got %d", rec.Code)
+	}
+
+	// An agent header does not excuse a foreign origin
+	req = httptest.NewRequest(http.MethodPost, "/test", nil)
+	req.Header.Set(AgentHeader, "1")
+	req.Header.Set("Origin", "https://evil.com")
+	rec = httptest.NewRecorder()
+
+	mw(okHandler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+}
+
 func TestCSRFMiddleware_AllowsGETWithoutOrigin(t *testing.T) {
 	mw := csrfMiddleware([]string{"example.com"})
 
@@ -168,6 +237,9 @@ func TestBasicAuthMiddleware_LockoutAfterFailures(t *testing.T) {
 	if rec2.Header().Get("Retry-After") == "" {
 		t.Error("expected Retry-After header on lockout")
 	}
+	if body := errorBody(t, rec2); body.Code != ErrCodeLockedOut || body.Details == nil {
+		t.Errorf("lockout body = %+v, want code %s with details", body, ErrCodeLockedOut)
+	}
 
 	// Valid credentials during lockout - should still be blocked
 	req3 := httptest.NewRequest(http.MethodGet, "/test", nil)
@@ -245,13 +317,51 @@ func TestBasicAuthMiddleware_SuccessClearsFailures(t *testing.T) {
 	}
 }
 
+// --- IP Filter Middleware Tests ---
+
+func TestIPFilterMiddleware(t *testing.T) {
+	f := IPFilter{
+		Allowed: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24"), netip.MustParsePrefix("fd00::/8")},
+		Denied:  []netip.Prefix{netip.MustParsePrefix("192.168.1.66/32")},
+	}
+	mw := ipFilterMiddleware(f)(okHandler)
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"192.168.1.20:50000", http.StatusOK},
+		{"[fd00::20]:50000", http.StatusOK},
+		{"127.0.0.1:50000", http.StatusOK}, // this PC is never locked out
+		{"[::1]:50000", http.StatusOK},
+		{"[::ffff:192.168.1.20]:50000", http.StatusOK},
+		{"192.168.1.66:50000", http.StatusForbidden}, // denied wins
+		{"192.168.2.20:50000", http.StatusForbidden}, // not allowed
+		{"not-an-ip", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		mw.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.remoteAddr, rec.Code, tt.want)
+		}
+	}
+
+	// Without an allowlist, only denied IPs are refused
+	if !(IPFilter{Denied: f.Denied}).Permits(netip.MustParseAddr("10.0.0.1")) {
+		t.Error("denylist alone should permit other IPs")
+	}
+}
+
 // --- Security Headers Middleware Tests ---
 
 func TestSecurityHeadersMiddleware(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/test", nil)
 	rec := httptest.NewRecorder()
 
-	securityHeadersMiddleware(okHandler).ServeHTTP(rec, req)
+	securityHeadersMiddleware(nil)(okHandler).ServeHTTP(rec, req)
 
 	expectedHeaders := []string{
 		"X-Content-Type-Options",
@@ -270,6 +380,47 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 	}
 }
 
+func TestSecurityHeadersMiddleware_CSP(t *testing.T) {
+	var nonces []string
+	handler := securityHeadersMiddleware(CSPSources{
+		"script-src": {"https://widgets.example.com"},
+		"worker-src": {"'self'"},
+		"frame-src":  nil,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, setCSPNonce(w, r))
+	}))
+
+	var policies []string
+	for range 2 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		policies = append(policies, rec.Header().Get("Content-Security-Policy"))
+	}
+
+	if nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Fatalf("nonces = %q, want a fresh one per response", nonces)
+	}
+	for i, csp := range policies {
+		want := "script-src 'self' 'nonce-" + nonces[i] + "' https://widgets.example.com;"
+		if !strings.Contains(csp, want) {
+			t.Errorf("CSP %q does not contain %q", csp, want)
+		}
+		if !strings.HasSuffix(csp, "; worker-src 'self'") || strings.Contains(csp, "frame-src") {
+			t.Errorf("CSP %q: want worker-src added and empty frame-src skipped", csp)
+		}
+		if !strings.Contains(csp, "default-src 'self';") {
+			t.Errorf("CSP %q lost the default directives", csp)
+		}
+	}
+
+	// Responses of handlers that ask for no nonce have none
+	rec := httptest.NewRecorder()
+	securityHeadersMiddleware(nil)(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if csp := rec.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self';") {
+		t.Errorf("CSP without a nonce = %q", csp)
+	}
+}
+
 // --- Constant Time Comparison Tests ---
 
 func TestConstantTimeEqualString(t *testing.T) {
@@ -292,3 +443,36 @@ func TestConstantTimeEqualString(t *testing.T) {
 		}
 	}
 }
+
+// --- Request Timeout Tests ---
+
+func TestTimeoutMiddleware_SlowStoreReturns503(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stands in for a store query that honors ctx
+		<-r.Context().Done()
+		writeError(w, http.StatusInternalServerError, "internal error", fmt.Errorf("query: %w", r.Context().Err()))
+	})
+	handler := timeoutMiddleware(10 * time.Millisecond)(slow)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/events", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if body := errorBody(t, rec); body.Code != ErrCodeBusy {
+		t.Errorf("code = %q, want %q", body.Code, ErrCodeBusy)
+	}
+}
+
+func TestTimeoutMiddleware_Disabled(t *testing.T) {
+	handler := timeoutMiddleware(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("unexpected deadline with timeout disabled")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
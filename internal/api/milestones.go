@@ -0,0 +1,41 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// handleMilestones handles GET /api/v1/milestones.
+// Query: kind (world_visits, player_anniversary or play_streak), account,
+// limit.
+func (s *Server) handleMilestones(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.MilestoneFilter{
+		Kind:    q.Get("kind"),
+		Account: q.Get("account"),
+	}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	result, err := s.milestones.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidMilestoneKind) {
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
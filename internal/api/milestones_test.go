@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubMilestonesUsecase struct {
+	got store.MilestoneFilter
+}
+
+func (s *stubMilestonesUsecase) List(ctx context.Context, filter store.MilestoneFilter) (app.MilestonesResponse, error) {
+	s.got = filter
+	if filter.Kind == "bogus" {
+		return app.MilestonesResponse{}, app.ErrInvalidMilestoneKind
+	}
+	return app.MilestonesResponse{Items: []store.Milestone{}}, nil
+}
+
+func TestMilestonesEndpoint(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantStatus int
+		want       store.MilestoneFilter
+	}{
+		{"", http.StatusOK, store.MilestoneFilter{}},
+		{"?kind=play_streak&account=alt&limit=5", http.StatusOK, store.MilestoneFilter{Kind: "play_streak", Account: "alt", Limit: 5}},
+		{"?limit=0", http.StatusBadRequest, store.MilestoneFilter{}},
+		{"?limit=x", http.StatusBadRequest, store.MilestoneFilter{}},
+		{"?kind=bogus", http.StatusBadRequest, store.MilestoneFilter{Kind: "bogus"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stub := &stubMilestonesUsecase{}
+			server := NewServer(":8080", app.HealthService{Version: "test"}, WithMilestonesUsecase(stub))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/milestones"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			server.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if stub.got != tt.want {
+				t.Errorf("filter = %+v, want %+v", stub.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// noteRequest is the request body for POST /api/v1/events/{id}/notes.
+type noteRequest struct {
+	Text string `json:"text"`
+}
+
+// notesResponse is the response body for note listings.
+type notesResponse struct {
+	Items []store.Note `json:"items"`
+}
+
+// handleAddNote handles POST /api/v1/events/{id}/notes.
+func (s *Server) handleAddNote(w http.ResponseWriter, r *http.Request) {
+	eventID, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req noteRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	note, err := s.notes.Add(r.Context(), eventID, req.Text)
+	if err != nil {
+		switch {
+		case errors.Is(err, app.ErrInvalidNote):
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+		case errors.Is(err, store.ErrNotFound):
+			writeError(w, http.StatusNotFound, "event not found", nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal error", err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, note)
+}
+
+// handleEventNotes handles GET /api/v1/events/{id}/notes.
+func (s *Server) handleEventNotes(w http.ResponseWriter, r *http.Request) {
+	eventID, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	notes, err := s.notes.List(r.Context(), store.NoteFilter{EventID: &eventID, Limit: 500})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notesResponse{Items: notes})
+}
+
+// handleSearchNotes handles GET /api/v1/notes?q=&limit=.
+func (s *Server) handleSearchNotes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.NoteFilter{Query: q.Get("q")}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	notes, err := s.notes.List(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notesResponse{Items: notes})
+}
+
+// handleDeleteNote handles DELETE /api/v1/notes/{id}.
+func (s *Server) handleDeleteNote(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.notes.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "note not found", nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseIDPath parses the {id} path value as a positive integer.
+// Writes a 400 response and returns false if it is invalid.
+func parseIDPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "invalid id", nil)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubNoteStore implements app.NoteStore for testing.
+type stubNoteStore struct {
+	notes map[int64]store.Note
+}
+
+func (s *stubNoteStore) AddNote(ctx context.Context, eventID int64, text string) (*store.Note, error) {
+	if eventID != 1 {
+		return nil, store.ErrNotFound
+	}
+	n := store.Note{ID: int64(len(s.notes) + 1), EventID: eventID, Text: text}
+	s.notes[n.ID] = n
+	return &n, nil
+}
+
+func (s *stubNoteStore) ListNotes(ctx context.Context, filter store.NoteFilter) ([]store.Note, error) {
+	notes := []store.Note{}
+	for _, n := range s.notes {
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+func (s *stubNoteStore) DeleteNote(ctx context.Context, id int64) error {
+	if _, ok := s.notes[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.notes, id)
+	return nil
+}
+
+func TestNotesEndpoints(t *testing.T) {
+	notes := &app.NotesService{Store: &stubNoteStore{notes: map[int64]store.Note{}}}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithNotesUsecase(notes))
+
+	do := func(method, target, body string) int {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	tests := []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodPost, "/api/v1/events/1/notes", `{"text":"fireworks"}`, http.StatusCreated},
+		{http.MethodPost, "/api/v1/events/2/notes", `{"text":"fireworks"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/v1/events/1/notes", `{"text":"   "}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/events/1/notes", `{"txt":"x"}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/events/abc/notes", `{"text":"x"}`, http.StatusBadRequest},
+		{http.MethodGet, "/api/v1/events/1/notes", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/notes?q=fire", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/notes?limit=0", "", http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/notes/1", "", http.StatusNoContent},
+		{http.MethodDelete, "/api/v1/notes/1", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if got := do(tt.method, tt.target, tt.body); got != tt.want {
+			t.Errorf("%s %s %s: status %d, want %d", tt.method, tt.target, tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+// notifyRulesResponse is the response body of the notify rule endpoints.
+type notifyRulesResponse struct {
+	Items []config.NotifyRule `json:"items"`
+}
+
+// handleListNotifyRules handles GET /api/v1/notify/rules.
+func (s *Server) handleListNotifyRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := s.notifyRules.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notifyRulesResponse{Items: rules})
+}
+
+// handleAddNotifyRule handles POST /api/v1/notify/rules. The rule is
+// appended, or inserted before the rule at ?index=.
+func (s *Server) handleAddNotifyRule(w http.ResponseWriter, r *http.Request) {
+	var index *int
+	if v := r.URL.Query().Get("index"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid index", nil)
+			return
+		}
+		index = &i
+	}
+	rule, ok := decodeNotifyRule(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := s.notifyRules.Add(r.Context(), rule, index)
+	if err != nil {
+		writeNotifyRuleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, notifyRulesResponse{Items: rules})
+}
+
+// handleReplaceNotifyRule handles PUT /api/v1/notify/rules/{index}.
+func (s *Server) handleReplaceNotifyRule(w http.ResponseWriter, r *http.Request) {
+	index, ok := parseRuleIndex(w, r)
+	if !ok {
+		return
+	}
+	rule, ok := decodeNotifyRule(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := s.notifyRules.Replace(r.Context(), index, rule)
+	if err != nil {
+		writeNotifyRuleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notifyRulesResponse{Items: rules})
+}
+
+// handleDeleteNotifyRule handles DELETE /api/v1/notify/rules/{index}.
+func (s *Server) handleDeleteNotifyRule(w http.ResponseWriter, r *http.Request) {
+	index, ok := parseRuleIndex(w, r)
+	if !ok {
+		return
+	}
+
+	rules, err := s.notifyRules.Delete(r.Context(), index)
+	if err != nil {
+		writeNotifyRuleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, notifyRulesResponse{Items: rules})
+}
+
+// decodeNotifyRule strictly decodes a rule body, writing a 400 response and
+// returning false if it is malformed.
+func decodeNotifyRule(w http.ResponseWriter, r *http.Request) (config.NotifyRule, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var rule config.NotifyRule
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rule); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return rule, false
+	}
+	return rule, true
+}
+
+// parseRuleIndex parses the {index} path value, writing a 400 response and
+// returning false if it is not a non-negative integer.
+func parseRuleIndex(w http.ResponseWriter, r *http.Request) (int, bool) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		writeError(w, http.StatusBadRequest, "invalid index", nil)
+		return 0, false
+	}
+	return index, true
+}
+
+// writeNotifyRuleError maps notify rule use case errors to responses.
+func writeNotifyRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, app.ErrInvalidNotifyRule), errors.Is(err, config.ErrHandEditedConfig):
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, app.ErrNotifyRuleNotFound):
+		writeError(w, http.StatusNotFound, err.Error(), nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	}
+}
@@ -1,16 +1,70 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
 // handleNow handles GET /api/v1/now requests.
+// Accepts an optional account; the default account is used if omitted.
 func (s *Server) handleNow(w http.ResponseWriter, r *http.Request) {
 	if s.state == nil {
 		writeError(w, http.StatusServiceUnavailable, "state not available", nil)
 		return
 	}
 
-	result := s.state.GetCurrentState(r.Context())
-	writeJSON(w, http.StatusOK, result)
+	result := s.state.GetCurrentState(r.Context(), r.URL.Query().Get("account"))
+	writeResponse(w, r, http.StatusOK, result)
+}
+
+// nowHistoryDefaultRange is the range covered by the state history when
+// since is omitted.
+const nowHistoryDefaultRange = 24 * time.Hour
+
+// nowHistoryMaxLimit is the largest limit accepted by the state history,
+// matching the store's cap for events.
+const nowHistoryMaxLimit = 500
+
+// nowHistoryResponse is the response body for GET /api/v1/now/history.
+type nowHistoryResponse struct {
+	Items []store.StateSnapshot `json:"items"`
+}
+
+// handleNowHistory handles GET /api/v1/now/history requests.
+// Accepts optional since/until (RFC3339, default last 24 hours), account and
+// limit (default 100, at most 500). Without account, snapshots of all
+// accounts are returned.
+// Snapshots are saved only when the state changes, so each one holds until
+// the next.
+func (s *Server) handleNowHistory(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	since, until, err := parseRange(r, now.Add(-nowHistoryDefaultRange), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > nowHistoryMaxLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+	}
+
+	items, err := s.snapshots.History(r.Context(), since, until, r.URL.Query().Get("account"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	if items == nil {
+		items = []store.StateSnapshot{}
+	}
+
+	writeJSON(w, http.StatusOK, nowHistoryResponse{Items: items})
 }
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubSnapshotsUsecase struct {
+	called bool
+	limit  int
+}
+
+func (s *stubSnapshotsUsecase) History(ctx context.Context, since, until time.Time, account string, limit int) ([]store.StateSnapshot, error) {
+	s.called = true
+	s.limit = limit
+	return nil, nil
+}
+
+func TestNowHistoryEndpoint_Limit(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantLimit  int
+	}{
+		{"", http.StatusOK, 0},
+		{"?limit=10", http.StatusOK, 10},
+		{"?limit=500", http.StatusOK, 500},
+		{"?limit=501", http.StatusBadRequest, 0},
+		{"?limit=0", http.StatusBadRequest, 0},
+		{"?limit=x", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stub := &stubSnapshotsUsecase{}
+			server := NewServer(":8080", app.HealthService{Version: "test"}, WithSnapshotsUsecase(stub))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/now/history"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			server.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if stub.called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("History called = %v", stub.called)
+			}
+			if stub.limit != tt.wantLimit {
+				t.Errorf("limit = %d, want %d", stub.limit, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// pinsResponse is the response body for GET /api/v1/pins.
+type pinsResponse struct {
+	Items []store.Pin `json:"items"`
+}
+
+// handleAddPin handles POST /api/v1/pins.
+func (s *Server) handleAddPin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req app.PinRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.EventID < 0 {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	pin, err := s.pins.Add(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeError(w, http.StatusNotFound, "event not found", nil)
+		case errors.Is(err, app.ErrInvalidPin):
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+		default:
+			writeError(w, http.StatusInternalServerError, "internal error", err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, pin)
+}
+
+// handleListPins handles GET /api/v1/pins.
+func (s *Server) handleListPins(w http.ResponseWriter, r *http.Request) {
+	items, err := s.pins.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pinsResponse{Items: items})
+}
+
+// handleDeletePin handles DELETE /api/v1/pins/{id}.
+func (s *Server) handleDeletePin(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.pins.Delete(r.Context(), id); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "pin not found", nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// handlePlayers handles GET /api/v1/players, the players directory.
+// Query: search, sort (last_seen or total_time), limit, cursor, account.
+func (s *Server) handlePlayers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.PlayerFilter{
+		Search:  q.Get("search"),
+		Sort:    q.Get("sort"),
+		Account: q.Get("account"),
+	}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+		filter.Limit = limit
+	}
+	if c := q.Get("cursor"); c != "" {
+		filter.Cursor = &c
+	}
+
+	page, err := s.players.List(r.Context(), filter)
+	if err != nil {
+		writePlayerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handlePlayerLastSeen handles GET /api/v1/players/{name}/lastseen.
+// {name} is a display name or usr_ ID.
+func (s *Server) handlePlayerLastSeen(w http.ResponseWriter, r *http.Request) {
+	seen, err := s.players.LastSeen(r.Context(), r.PathValue("name"), r.URL.Query().Get("account"))
+	if err != nil {
+		writePlayerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, seen)
+}
+
+// handlePlayerProfile handles GET /api/v1/players/{id}.
+// {id} is a usr_ ID or display name.
+func (s *Server) handlePlayerProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := s.players.Profile(r.Context(), r.PathValue("id"), r.URL.Query().Get("account"))
+	if err != nil {
+		writePlayerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// playerLinksResponse is the response of GET /api/v1/players/links.
+type playerLinksResponse struct {
+	Items []store.PlayerLink `json:"items"`
+}
+
+// mergeRequest is the body of POST /api/v1/players/merge.
+type mergeRequest struct {
+	PlayerName string `json:"player_name"`
+	PlayerID   string `json:"player_id"`
+}
+
+// handlePlayerLinks handles GET /api/v1/players/links.
+func (s *Server) handlePlayerLinks(w http.ResponseWriter, r *http.Request) {
+	links, err := s.players.Links(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, playerLinksResponse{Items: links})
+}
+
+// handleMergePlayer handles POST /api/v1/players/merge, linking a display
+// name to a player ID by hand.
+func (s *Server) handleMergePlayer(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req mergeRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	link, err := s.players.Merge(r.Context(), req.PlayerName, req.PlayerID)
+	if err != nil {
+		writePlayerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, link)
+}
+
+// handleUnlinkPlayer handles DELETE /api/v1/players/links/{name}.
+func (s *Server) handleUnlinkPlayer(w http.ResponseWriter, r *http.Request) {
+	if err := s.players.Unlink(r.Context(), r.PathValue("name")); err != nil {
+		writePlayerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writePlayerError maps player use case errors to responses.
+func writePlayerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, app.ErrInvalidPlayer):
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, store.ErrInvalidCursor):
+		writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusNotFound, "player not found", nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	}
+}
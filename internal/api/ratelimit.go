@@ -1,21 +1,79 @@
 package api
 
 import (
+	"maps"
 	"net"
 	"net/http"
-	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// Rate limit buckets. Each client IP has a token bucket per bucket name,
+// and rateLimitRoutes decides which bucket a request is counted in.
+const (
+	RateLimitBucketAuth     = "auth"     // credential endpoints, e.g. SSE tokens
+	RateLimitBucketRead     = "read"     // GET requests not in another bucket
+	RateLimitBucketWrite    = "write"    // other requests not in another bucket
+	RateLimitBucketAdmin    = "admin"    // config, backups and maintenance
+	RateLimitBucketStream   = "stream"   // SSE connections
+	RateLimitBucketLoopback = "loopback" // all requests from this PC, if it has a policy
+)
+
+// rateLimitRoutes is the policy table mapping requests to buckets. The
+// first entry whose method (empty for any) and path prefix match wins; a
+// prefix matches whole path segments only.
+var rateLimitRoutes = []struct {
+	method string
+	prefix string
+	bucket string
+}{
+	{http.MethodDelete, "/api/v1/stream/clients", RateLimitBucketAdmin},
+	{"", "/api/v1/stream", RateLimitBucketStream},
+	{"", "/api/v1/auth", RateLimitBucketAuth},
+	{"", "/api/v1/admin", RateLimitBucketAdmin},
+	{"", "/api/v1/config", RateLimitBucketAdmin},
+	{"", "/api/v1/backups", RateLimitBucketAdmin},
+	{http.MethodPost, "/api/v1/events/delete", RateLimitBucketAdmin},
+	{http.MethodGet, "/api/v1/events/stream-export", RateLimitBucketAdmin},
+	{"", "/api/v1/trash", RateLimitBucketAdmin},
+	{"", "/api/v1/export", RateLimitBucketAdmin},
+	{"", "/api/v1/logs", RateLimitBucketAdmin},
+	{"", "/api/v1/support", RateLimitBucketAdmin},
+	{"", "/api/v1/jobs", RateLimitBucketAdmin},
+	{http.MethodPost, "/api/v1/ingest/pause", RateLimitBucketAdmin},
+	{http.MethodPost, "/api/v1/ingest/resume", RateLimitBucketAdmin},
+	{http.MethodGet, "", RateLimitBucketRead},
+	{http.MethodHead, "", RateLimitBucketRead},
+	{"", "", RateLimitBucketWrite},
+}
+
+// rateLimitBucket returns the bucket of r from rateLimitRoutes.
+func rateLimitBucket(r *http.Request) string {
+	for _, route := range rateLimitRoutes {
+		if route.method != "" && route.method != r.Method {
+			continue
+		}
+		rest, ok := strings.CutPrefix(r.URL.Path, route.prefix)
+		if ok && (rest == "" || rest[0] == '/' || route.prefix == "") {
+			return route.bucket
+		}
+	}
+	return RateLimitBucketWrite
+}
+
 // RateLimiter provides IP-based rate limiting using token bucket algorithm.
+// Requests are limited per bucket, so e.g. a browser reconnecting its SSE
+// streams does not use up the budget of its other requests, and the
+// decisions are counted for Stats.
 type RateLimiter struct {
 	mu       sync.RWMutex
-	limiters map[string]*visitorLimiter
-	rate     rate.Limit // requests per second
-	burst    int        // max burst size
+	limiters map[string]*visitorLimiter // by bucket and IP
+	limits   map[string]RateLimit       // by bucket
+	fallback RateLimit                  // for buckets not in limits
+	counts   map[string]*RateLimitCounts
 	cleanup  time.Duration
 	stopOnce sync.Once
 	done     chan struct{}
@@ -26,33 +84,66 @@ type visitorLimiter struct {
 	lastSeen time.Time
 }
 
+// RateLimit is a token bucket: Rate requests per second on average, with
+// bursts of up to Burst. A zero Rate exempts requests from limiting.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
 // RateLimiterConfig configures the rate limiter.
 type RateLimiterConfig struct {
 	// Rate is requests per second allowed
 	Rate float64
 	// Burst is the maximum burst size
 	Burst int
+	// Policies replaces Rate and Burst for the buckets it lists. With a
+	// RateLimitBucketLoopback policy, clients on this PC are limited by it
+	// alone instead.
+	Policies map[string]RateLimit
 	// CleanupInterval is how often to clean up old entries
 	CleanupInterval time.Duration
 }
 
 // DefaultRateLimiterConfig returns sensible defaults for LAN mode.
 // 10 requests/second with burst of 20 is generous for normal use
-// but protects against abuse.
+// but protects against abuse. Reads get twice that; credential and admin
+// endpoints much less. Clients on this PC are not limited.
 func DefaultRateLimiterConfig() RateLimiterConfig {
 	return RateLimiterConfig{
-		Rate:            10,
-		Burst:           20,
+		Rate:  10,
+		Burst: 20,
+		Policies: map[string]RateLimit{
+			RateLimitBucketAuth:     {Rate: 0.5, Burst: 10},
+			RateLimitBucketRead:     {Rate: 20, Burst: 40},
+			RateLimitBucketAdmin:    {Rate: 1, Burst: 10},
+			RateLimitBucketStream:   {Rate: 1, Burst: 10},
+			RateLimitBucketLoopback: {},
+		},
 		CleanupInterval: 5 * time.Minute,
 	}
 }
 
+// RateLimitCounts counts the decisions of a RateLimiter for one bucket.
+type RateLimitCounts struct {
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+	Exempt  uint64 `json:"exempt"` // allowed without a limit
+}
+
+// RateLimitStats reports the decisions of a RateLimiter since it was
+// created.
+type RateLimitStats struct {
+	Buckets map[string]RateLimitCounts `json:"buckets"`
+}
+
 // NewRateLimiter creates a new IP-based rate limiter.
 func NewRateLimiter(cfg RateLimiterConfig) *RateLimiter {
 	rl := &RateLimiter{
 		limiters: make(map[string]*visitorLimiter),
-		rate:     rate.Limit(cfg.Rate),
-		burst:    cfg.Burst,
+		limits:   maps.Clone(cfg.Policies),
+		fallback: RateLimit{Rate: cfg.Rate, Burst: cfg.Burst},
+		counts:   make(map[string]*RateLimitCounts),
 		cleanup:  cfg.CleanupInterval,
 		done:     make(chan struct{}),
 	}
@@ -60,22 +151,66 @@ func NewRateLimiter(cfg RateLimiterConfig) *RateLimiter {
 	return rl
 }
 
-// Allow checks if a request from the given IP should be allowed.
+// Allow checks if a read request from the given IP should be allowed.
 func (rl *RateLimiter) Allow(ip string) bool {
+	return rl.allow(RateLimitBucketRead, ip)
+}
+
+// allow checks a request from ip against its token bucket in bucket, or in
+// the loopback bucket if ip is a loopback address and that has a policy.
+func (rl *RateLimiter) allow(bucket, ip string) bool {
+	if addr := net.ParseIP(ip); addr != nil && addr.IsLoopback() {
+		if _, ok := rl.limits[RateLimitBucketLoopback]; ok {
+			bucket = RateLimitBucketLoopback
+		}
+	}
+	limit, ok := rl.limits[bucket]
+	if !ok {
+		limit = rl.fallback
+	}
+
 	rl.mu.Lock()
-	v, exists := rl.limiters[ip]
+	defer rl.mu.Unlock()
+
+	c := rl.counts[bucket]
+	if c == nil {
+		c = &RateLimitCounts{}
+		rl.counts[bucket] = c
+	}
+	if limit.Rate == 0 {
+		c.Exempt++
+		return true
+	}
+
+	key := bucket + " " + ip
+	v, exists := rl.limiters[key]
 	if !exists {
 		v = &visitorLimiter{
-			limiter:  rate.NewLimiter(rl.rate, rl.burst),
+			limiter:  rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst),
 			lastSeen: time.Now(),
 		}
-		rl.limiters[ip] = v
+		rl.limiters[key] = v
 	} else {
 		v.lastSeen = time.Now()
 	}
-	rl.mu.Unlock()
 
-	return v.limiter.Allow()
+	if !v.limiter.Allow() {
+		c.Limited++
+		return false
+	}
+	c.Allowed++
+	return true
+}
+
+// Stats returns the decisions counted so far.
+func (rl *RateLimiter) Stats() RateLimitStats {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	stats := RateLimitStats{Buckets: make(map[string]RateLimitCounts, len(rl.counts))}
+	for bucket, c := range rl.counts {
+		stats.Buckets[bucket] = *c
+	}
+	return stats
 }
 
 // cleanupLoop periodically removes old entries.
@@ -99,9 +234,9 @@ func (rl *RateLimiter) cleanup_old() {
 	defer rl.mu.Unlock()
 
 	threshold := time.Now().Add(-rl.cleanup * 2)
-	for ip, v := range rl.limiters {
+	for key, v := range rl.limiters {
 		if v.lastSeen.Before(threshold) {
-			delete(rl.limiters, ip)
+			delete(rl.limiters, key)
 		}
 	}
 }
@@ -113,14 +248,15 @@ func (rl *RateLimiter) Stop() {
 	})
 }
 
-// Middleware returns an HTTP middleware that applies rate limiting.
+// Middleware returns an HTTP middleware that applies rate limiting in the
+// bucket rateLimitRoutes gives each request.
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ip := extractIP(r)
 
-		if !rl.Allow(ip) {
+		if !rl.allow(rateLimitBucket(r), ip) {
 			w.Header().Set("Retry-After", "1")
-			writeError(w, http.StatusTooManyRequests, "Too Many Requests", nil)
+			writeErrorCode(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Too Many Requests", retryDetails{RetryAfter: 1}, nil)
 			return
 		}
 
@@ -143,11 +279,12 @@ func extractIP(r *http.Request) string {
 // AuthFailureLimiter tracks authentication failures per IP.
 // This provides additional protection against brute force attacks.
 type AuthFailureLimiter struct {
-	mu       sync.RWMutex
-	failures map[string]*authFailure
-	maxFails int
-	window   time.Duration
-	lockout  time.Duration
+	mu        sync.RWMutex
+	failures  map[string]*authFailure
+	maxFails  int
+	window    time.Duration
+	lockout   time.Duration
+	onLockout func(ip string, failures int)
 }
 
 type authFailure struct {
@@ -161,6 +298,9 @@ type AuthFailureLimiterConfig struct {
 	MaxFailures   int           // Max failures before lockout
 	Window        time.Duration // Time window for counting failures
 	LockoutPeriod time.Duration // How long to lock out after max failures
+	// OnLockout is called in a goroutine of its own when an IP is locked
+	// out, e.g. to tell the user about a brute force attempt. May be nil.
+	OnLockout func(ip string, failures int)
 }
 
 // DefaultAuthFailureLimiterConfig returns sensible defaults.
@@ -175,10 +315,11 @@ func DefaultAuthFailureLimiterConfig() AuthFailureLimiterConfig {
 // NewAuthFailureLimiter creates a new auth failure limiter.
 func NewAuthFailureLimiter(cfg AuthFailureLimiterConfig) *AuthFailureLimiter {
 	return &AuthFailureLimiter{
-		failures: make(map[string]*authFailure),
-		maxFails: cfg.MaxFailures,
-		window:   cfg.Window,
-		lockout:  cfg.LockoutPeriod,
+		failures:  make(map[string]*authFailure),
+		maxFails:  cfg.MaxFailures,
+		window:    cfg.Window,
+		lockout:   cfg.LockoutPeriod,
+		onLockout: cfg.OnLockout,
 	}
 }
 
@@ -228,6 +369,9 @@ func (afl *AuthFailureLimiter) RecordFailure(ip string) int {
 
 	if f.count >= afl.maxFails {
 		f.lockedAt = now
+		if afl.onLockout != nil {
+			go afl.onLockout(ip, f.count)
+		}
 		return -1
 	}
 
@@ -265,9 +409,7 @@ func (afl *AuthFailureLimiter) Middleware(next http.Handler) http.Handler {
 		ip := extractIP(r)
 
 		if afl.IsLocked(ip) {
-			seconds := afl.LockoutSecondsRemaining(ip)
-			w.Header().Set("Retry-After", strconv.Itoa(seconds))
-			writeError(w, http.StatusTooManyRequests, "Too Many Requests", nil)
+			writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
 			return
 		}
 
@@ -98,6 +98,124 @@ func TestRateLimiter_Middleware(t *testing.T) {
 	}
 }
 
+func TestRateLimiter_Policies(t *testing.T) {
+	rl := NewRateLimiter(RateLimiterConfig{
+		Rate:  10,
+		Burst: 1,
+		Policies: map[string]RateLimit{
+			RateLimitBucketStream:   {Rate: 10, Burst: 2},
+			RateLimitBucketAdmin:    {Rate: 10, Burst: 1},
+			RateLimitBucketLoopback: {},
+		},
+		CleanupInterval: time.Hour,
+	})
+	defer rl.Stop()
+
+	handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, target, remoteAddr string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Reconnecting streams do not use up the budget of other requests, nor
+	// do reads that of admin requests
+	lan := "192.168.1.100:12345"
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := serve(http.MethodGet, "/api/v1/stream/derived", lan); code != want {
+			t.Errorf("stream %d: got %d, want %d", i+1, code, want)
+		}
+	}
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if code := serve(http.MethodGet, "/api/v1/events", lan); code != want {
+			t.Errorf("read %d: got %d, want %d", i+1, code, want)
+		}
+	}
+	if code := serve(http.MethodPut, "/api/v1/config", lan); code != http.StatusOK {
+		t.Errorf("admin after reads: got %d, want 200", code)
+	}
+	if code := serve(http.MethodGet, "/api/v1/config/export", lan); code != http.StatusTooManyRequests {
+		t.Errorf("admin over burst: got %d, want 429", code)
+	}
+	if code := serve(http.MethodPost, "/api/v1/notes/1", lan); code != http.StatusOK {
+		t.Errorf("write: got %d, want 200", code)
+	}
+
+	// Clients on this PC are exempt with a zero loopback policy
+	for _, addr := range []string{"127.0.0.1:5000", "[::1]:5000"} {
+		for range 5 {
+			if code := serve(http.MethodGet, "/api/v1/stream", addr); code != http.StatusOK {
+				t.Fatalf("%s: got %d, want 200", addr, code)
+			}
+		}
+	}
+
+	want := map[string]RateLimitCounts{
+		RateLimitBucketStream:   {Allowed: 2, Limited: 1},
+		RateLimitBucketRead:     {Allowed: 1, Limited: 1},
+		RateLimitBucketAdmin:    {Allowed: 1, Limited: 1},
+		RateLimitBucketWrite:    {Allowed: 1},
+		RateLimitBucketLoopback: {Exempt: 10},
+	}
+	stats := rl.Stats()
+	for bucket, counts := range want {
+		if stats.Buckets[bucket] != counts {
+			t.Errorf("%s counts = %+v, want %+v", bucket, stats.Buckets[bucket], counts)
+		}
+	}
+}
+
+func TestRateLimitBucket(t *testing.T) {
+	tests := []struct {
+		method, target, want string
+	}{
+		{http.MethodGet, "/api/v1/stream", RateLimitBucketStream},
+		{http.MethodPost, "/api/v1/auth/token", RateLimitBucketAuth},
+		{http.MethodPost, "/api/v1/admin/vacuum", RateLimitBucketAdmin},
+		{http.MethodGet, "/api/v1/backups/vrclog-backup-1.sqlite.gz", RateLimitBucketAdmin},
+		{http.MethodGet, "/api/v1/export/events", RateLimitBucketAdmin},
+		{http.MethodGet, "/api/v1/events/stream-export", RateLimitBucketAdmin},
+		{http.MethodGet, "/api/v1/logs/tail", RateLimitBucketAdmin},
+		{http.MethodPost, "/api/v1/support/bundle", RateLimitBucketAdmin},
+		{http.MethodPost, "/api/v1/jobs/backup/run", RateLimitBucketAdmin},
+		{http.MethodPost, "/api/v1/ingest/pause", RateLimitBucketAdmin},
+		{http.MethodPost, "/api/v1/ingest/resume", RateLimitBucketAdmin},
+		{http.MethodPost, "/api/v1/ingest/events", RateLimitBucketWrite},
+		{http.MethodDelete, "/api/v1/stream/clients/3", RateLimitBucketAdmin},
+		{http.MethodGet, "/api/v1/stream/derived", RateLimitBucketStream},
+		{http.MethodGet, "/api/v1/configs", RateLimitBucketRead},
+		{http.MethodHead, "/api/v1/now", RateLimitBucketRead},
+		{http.MethodDelete, "/api/v1/pins/1", RateLimitBucketWrite},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := rateLimitBucket(req); got != tt.want {
+			t.Errorf("%s %s: bucket %q, want %q", tt.method, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiter_LoopbackLimit(t *testing.T) {
+	rl := NewRateLimiter(RateLimiterConfig{
+		Rate:            10,
+		Burst:           5,
+		Policies:        map[string]RateLimit{RateLimitBucketLoopback: {Rate: 10, Burst: 1}},
+		CleanupInterval: time.Hour,
+	})
+	defer rl.Stop()
+
+	if !rl.Allow("127.0.0.1") {
+		t.Error("first loopback request should be allowed")
+	}
+	if rl.Allow("127.0.0.1") {
+		t.Error("loopback request over its own burst should be denied")
+	}
+}
+
 func TestAuthFailureLimiter_Lockout(t *testing.T) {
 	cfg := AuthFailureLimiterConfig{
 		MaxFailures:   3,
@@ -141,6 +259,37 @@ func TestAuthFailureLimiter_Lockout(t *testing.T) {
 	}
 }
 
+func TestAuthFailureLimiter_OnLockout(t *testing.T) {
+	type lockout struct {
+		ip       string
+		failures int
+	}
+	locked := make(chan lockout, 1)
+	afl := NewAuthFailureLimiter(AuthFailureLimiterConfig{
+		MaxFailures:   2,
+		Window:        time.Minute,
+		LockoutPeriod: time.Minute,
+		OnLockout:     func(ip string, failures int) { locked <- lockout{ip, failures} },
+	})
+
+	afl.RecordFailure("192.168.1.50")
+	select {
+	case got := <-locked:
+		t.Fatalf("OnLockout called before the limit: %+v", got)
+	default:
+	}
+
+	afl.RecordFailure("192.168.1.50")
+	select {
+	case got := <-locked:
+		if got != (lockout{"192.168.1.50", 2}) {
+			t.Errorf("OnLockout(%+v), want 192.168.1.50 after 2 failures", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnLockout not called")
+	}
+}
+
 func TestAuthFailureLimiter_SuccessClears(t *testing.T) {
 	cfg := AuthFailureLimiterConfig{
 		MaxFailures:   3,
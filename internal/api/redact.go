@@ -0,0 +1,80 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// EventMask lists the event fields hidden from a class of clients. The
+// zero mask hides nothing.
+type EventMask struct {
+	PlayerID   bool
+	Meta       bool
+	InstanceID bool
+}
+
+// ParseEventMask returns the mask hiding fields, named as in
+// config.Config.LANRedact.
+func ParseEventMask(fields []string) (EventMask, error) {
+	var m EventMask
+	for _, f := range fields {
+		switch f {
+		case config.RedactPlayerID:
+			m.PlayerID = true
+		case config.RedactMeta:
+			m.Meta = true
+		case config.RedactInstanceID:
+			m.InstanceID = true
+		default:
+			return EventMask{}, fmt.Errorf("unknown event field %q", f)
+		}
+	}
+	return m, nil
+}
+
+// Apply returns e with the masked fields removed. e itself is not
+// modified, as events from a hub are shared by all subscribers; it is
+// returned as is if the mask hides nothing.
+func (m EventMask) Apply(e *event.Event) *event.Event {
+	if e == nil || m == (EventMask{}) {
+		return e
+	}
+	c := *e
+	if m.PlayerID {
+		c.PlayerID = nil
+	}
+	if m.Meta {
+		c.MetaJSON = nil
+	}
+	if m.InstanceID {
+		c.InstanceID = nil
+	}
+	return &c
+}
+
+// applyDerived returns e with the masked fields removed from its event.
+func (m EventMask) applyDerived(e *derive.DerivedEvent) *derive.DerivedEvent {
+	if e == nil || m == (EventMask{}) {
+		return e
+	}
+	c := *e
+	c.Event = m.Apply(e.Event)
+	return &c
+}
+
+// eventMask returns the mask for the client of r. Loopback clients are
+// the local admin and see everything; others, such as LAN viewers, get
+// the mask set by WithLANRedaction. Handlers writing events must apply
+// it, to the events API, saved views, exports and the SSE streams alike.
+func (s *Server) eventMask(r *http.Request) EventMask {
+	addr, err := netip.ParseAddr(extractIP(r))
+	if err == nil && addr.Unmap().IsLoopback() {
+		return EventMask{}
+	}
+	return s.lanMask
+}
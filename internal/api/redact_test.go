@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+func TestParseEventMask(t *testing.T) {
+	m, err := ParseEventMask([]string{"player_id", "meta"})
+	if err != nil || m != (EventMask{PlayerID: true, Meta: true}) {
+		t.Errorf("ParseEventMask = %+v, %v", m, err)
+	}
+	if _, err := ParseEventMask([]string{"player_name"}); err == nil {
+		t.Error("ParseEventMask accepted an unknown field")
+	}
+}
+
+func TestEventMask_Apply(t *testing.T) {
+	e := &event.Event{
+		ID: 1, Type: event.TypePlayerJoin,
+		PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr("usr_a"),
+		InstanceID: event.StringPtr("1234~private(usr_b)"), MetaJSON: json.RawMessage(`{"k":"v"}`),
+	}
+	if got := (EventMask{}).Apply(e); got != e {
+		t.Error("zero mask copied the event")
+	}
+	got := EventMask{PlayerID: true, Meta: true}.Apply(e)
+	if got.PlayerID != nil || got.MetaJSON != nil || got.InstanceID == nil || *got.PlayerName != "Alice" {
+		t.Errorf("masked event = %+v", got)
+	}
+	if e.PlayerID == nil || e.MetaJSON == nil {
+		t.Error("Apply modified the original event")
+	}
+
+	d := EventMask{InstanceID: true}.applyDerived(&derive.DerivedEvent{Type: derive.DerivedPlayerJoined, Event: e, PlayerCount: 2})
+	if d.Event.InstanceID != nil || d.PlayerCount != 2 || e.InstanceID == nil {
+		t.Errorf("masked derived event = %+v", d)
+	}
+}
+
+func TestEventsEndpoint_LANRedaction(t *testing.T) {
+	events := &MockEventsService{
+		QueryFunc: func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+			return store.QueryResult{Items: []event.Event{
+				{ID: 1, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr("usr_a")},
+			}}, nil
+		},
+	}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithEventsUsecase(events),
+		WithLANRedaction(EventMask{PlayerID: true}))
+
+	for remote, wantID := range map[string]bool{
+		"127.0.0.1:50000":    true,
+		"[::1]:50000":        true,
+		"192.168.1.20:50000": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+
+		var resp eventsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Items) != 1 {
+			t.Fatalf("%s: response %v, %v", remote, resp, err)
+		}
+		if got := resp.Items[0].PlayerID != nil; got != wantID {
+			t.Errorf("%s: player_id sent = %v, want %v", remote, got, wantID)
+		}
+	}
+}
+
+// redactViews implements app.ViewsUsecase with a view of one event.
+type redactViews struct {
+	app.ViewsUsecase
+}
+
+func (redactViews) Events(ctx context.Context, name string, cursor *string, limit int) (store.QueryResult, error) {
+	return store.QueryResult{Items: []event.Event{
+		{ID: 1, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr("usr_a"),
+			MetaJSON: json.RawMessage(`{"k":"v"}`)},
+	}}, nil
+}
+
+// redactExportStore implements app.ExportStore with one event.
+type redactExportStore struct{}
+
+func (redactExportStore) ExportEvents(ctx context.Context, ew store.ExportWriter, since string) (store.ExportHeader, error) {
+	h := store.ExportHeader{Format: store.ExportFormat, Version: 1, Events: 1}
+	if err := ew.WriteHeader(h); err != nil {
+		return h, err
+	}
+	e := &event.Event{ID: 1, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr("usr_a"),
+		MetaJSON: json.RawMessage(`{"k":"v"}`)}
+	if err := ew.WriteEvent(e, nil); err != nil {
+		return h, err
+	}
+	return h, ew.Close()
+}
+
+func (redactExportStore) ExportCursor(context.Context, string) (string, error)  { return "", nil }
+func (redactExportStore) SetExportCursor(context.Context, string, string) error { return nil }
+
+func TestViewsAndExport_LANRedaction(t *testing.T) {
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithViewsUsecase(redactViews{}),
+		WithExportUsecase(&app.ExportService{Store: redactExportStore{}}),
+		WithLANRedaction(EventMask{PlayerID: true, Meta: true}))
+
+	get := func(target, remote string) string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s from %s: status %d", target, remote, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	for _, target := range []string{"/api/v1/views/alice/events", "/api/v1/export/events"} {
+		if body := get(target, "127.0.0.1:50000"); !strings.Contains(body, "usr_a") {
+			t.Errorf("%s from loopback: player_id missing in %s", target, body)
+		}
+		body := get(target, "192.168.1.20:50000")
+		if strings.Contains(body, "usr_a") || strings.Contains(body, `"k"`) {
+			t.Errorf("%s from LAN: masked fields sent in %s", target, body)
+		}
+		if !strings.Contains(body, "Alice") {
+			t.Errorf("%s from LAN: event missing in %s", target, body)
+		}
+	}
+}
@@ -2,14 +2,55 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"mime"
 	"net/http"
+	"strings"
+
+	"github.com/graaaaa/vrclog-companion/internal/msgpack"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// Error codes of API error responses. Clients branch on the code; the
+// message is for people and may change.
+const (
+	ErrCodeValidationFailed = "validation_failed" // a parameter or the body is invalid
+	ErrCodeInvalidCursor    = "invalid_cursor"    // the pagination cursor is malformed or stale
+	ErrCodeUnauthorized     = "unauthorized"      // credentials are missing or wrong
+	ErrCodeLockedOut        = "locked_out"        // too many failed logins; see Retry-After
+	ErrCodeForbidden        = "forbidden"         // the client IP is not allowed
+	ErrCodeCSRFFailed       = "csrf_failed"       // a mutating request failed the CSRF check
+	ErrCodeConfirmFailed    = "confirm_failed"    // the confirm token is missing, expired or for another request
+	ErrCodeNotFound         = "not_found"
+	ErrCodeConflict         = "conflict"     // the operation is already running
+	ErrCodeRateLimited      = "rate_limited" // see Retry-After
+	ErrCodeUnavailable      = "unavailable"  // the feature is not configured
+	ErrCodeBusy             = "busy"         // the database is busy; see Retry-After
+	ErrCodeInternal         = "internal_error"
 )
 
+// statusErrCodes gives the error code of a status for errors written
+// without one.
+var statusErrCodes = map[int]string{
+	http.StatusBadRequest:          ErrCodeValidationFailed,
+	http.StatusUnauthorized:        ErrCodeUnauthorized,
+	http.StatusForbidden:           ErrCodeForbidden,
+	http.StatusNotFound:            ErrCodeNotFound,
+	http.StatusConflict:            ErrCodeConflict,
+	http.StatusTooManyRequests:     ErrCodeRateLimited,
+	http.StatusInternalServerError: ErrCodeInternal,
+	http.StatusServiceUnavailable:  ErrCodeUnavailable,
+}
+
 // errorResponse is the standard error response format.
 type errorResponse struct {
-	Error string `json:"error"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+	Error   string `json:"error"` // same as Message, for clients that predate Code
 }
 
 // writeJSON encodes v as JSON and writes it to the response.
@@ -17,28 +58,93 @@ type errorResponse struct {
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	var buf bytes.Buffer
 	if err := json.NewEncoder(&buf).Encode(v); err != nil {
-		log.Printf("json encode failed: %v", err)
+		slog.Error("json encode failed", "error", err)
 		writeErrorFallback(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if _, err := w.Write(buf.Bytes()); err != nil {
-		log.Printf("write response failed: %v", err)
+		slog.Error("write response failed", "error", err)
 	}
 }
 
-// writeError writes a JSON error response with consistent format.
+// acceptsMsgpack reports whether the client asks for MessagePack with the
+// Accept header.
+func acceptsMsgpack(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(part)
+		if err == nil && (mediaType == msgpack.ContentType || mediaType == "application/x-msgpack") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeResponse writes v as MessagePack if the client accepts it, for
+// embedded clients, and as JSON otherwise. Errors are always JSON.
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Add("Vary", "Accept")
+	if !acceptsMsgpack(r) {
+		writeJSON(w, status, v)
+		return
+	}
+	data, err := msgpack.Marshal(v)
+	if err != nil {
+		slog.Error("msgpack encode failed", "error", err)
+		writeErrorFallback(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	w.Header().Set("Content-Type", msgpack.ContentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		slog.Error("write response failed", "error", err)
+	}
+}
+
+// writeError writes a JSON error response with consistent format, with
+// the error code of status.
 // For 5xx errors, the underlying error is logged for debugging.
 // The public message is what clients see; use generic messages for 5xx.
+// A 5xx caused by the request timeout or a locked database becomes 503 with
+// Retry-After, since retrying later is likely to succeed.
 func writeError(w http.ResponseWriter, status int, public string, err error) {
+	writeErrorCode(w, status, statusErrCodes[status], public, nil, err)
+}
+
+// writeErrorCode is writeError with an explicit error code and details,
+// for errors clients handle specifically. An empty code falls back to
+// ErrCodeInternal.
+func writeErrorCode(w http.ResponseWriter, status int, code, public string, details any, err error) {
+	if status >= 500 && (errors.Is(err, context.DeadlineExceeded) || store.IsBusy(err)) {
+		status = http.StatusServiceUnavailable
+		code = ErrCodeBusy
+		public = "database busy, try again later"
+		w.Header().Set("Retry-After", "1")
+	}
+	if code == "" {
+		code = ErrCodeInternal
+	}
 	if public == "" {
 		public = http.StatusText(status)
 	}
 	if status >= 500 && err != nil {
-		log.Printf("internal error: %v", err)
+		slog.Error("internal error", "error", err)
 	}
-	writeJSON(w, status, errorResponse{Error: public})
+	writeJSON(w, status, errorResponse{Code: code, Message: public, Details: details, Error: public})
+}
+
+// retryDetails are the details of errors the client may retry after a
+// wait.
+type retryDetails struct {
+	RetryAfter int `json:"retry_after"` // seconds
+}
+
+// writeLockedOut writes the error for an IP locked out after failed logins.
+func writeLockedOut(w http.ResponseWriter, seconds int) {
+	w.Header().Set("Retry-After", formatRetryAfter(seconds))
+	writeErrorCode(w, http.StatusTooManyRequests, ErrCodeLockedOut, "Too Many Requests",
+		retryDetails{RetryAfter: max(seconds, 0)}, nil)
 }
 
 // writeErrorFallback writes a plain text error when JSON encoding fails.
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // register PNG decoder for thumbnails
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// maxThumbnailSize is the largest accepted thumbnail edge length in pixels.
+const maxThumbnailSize = 1024
+
+// handleScreenshots handles GET /api/v1/screenshots.
+// Accepts the same filters as /api/v1/events (type is ignored).
+func (s *Server) handleScreenshots(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseEventsFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	page, err := s.screenshots.List(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []app.Screenshot{}
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// handleScreenshotImage handles GET /api/v1/screenshots/{id}/image.
+// Serves the screenshot file from disk. With ?size=N, serves a JPEG
+// thumbnail whose longer edge is at most N pixels.
+func (s *Server) handleScreenshotImage(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDPath(w, r)
+	if !ok {
+		return
+	}
+
+	size := 0
+	if v := r.URL.Query().Get("size"); v != "" {
+		var err error
+		size, err = strconv.Atoi(v)
+		if err != nil || size < 1 || size > maxThumbnailSize {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid size: %s", v), nil)
+			return
+		}
+	}
+
+	shot, err := s.screenshots.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "screenshot not found", nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	// Only serve image files; the path comes from the log, not the client.
+	switch strings.ToLower(filepath.Ext(shot.Path)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		writeError(w, http.StatusNotFound, "screenshot not found", nil)
+		return
+	}
+
+	f, err := os.Open(shot.Path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "screenshot file not found", nil)
+		return
+	}
+	defer f.Close()
+
+	if size == 0 {
+		info, err := f.Stat()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "internal error", err)
+			return
+		}
+		http.ServeContent(w, r, filepath.Base(shot.Path), info.ModTime(), f)
+		return
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", fmt.Errorf("decode %s: %w", shot.Path, err))
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	if err := jpeg.Encode(w, thumbnail(img, size), &jpeg.Options{Quality: 80}); err != nil {
+		// Headers are already sent; nothing more to report to the client
+		return
+	}
+}
+
+// thumbnail scales img down (nearest neighbor) so its longer edge is at most
+// size pixels. Images that already fit are returned unchanged.
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		sy := b.Min.Y + y*h/th
+		for x := 0; x < tw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*w/tw, sy))
+		}
+	}
+	return dst
+}
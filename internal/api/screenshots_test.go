@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubScreenshots implements app.ScreenshotsUsecase for testing.
+type stubScreenshots struct {
+	shots     map[int64]app.Screenshot
+	gotFilter store.QueryFilter
+}
+
+func (s *stubScreenshots) List(ctx context.Context, filter store.QueryFilter) (app.ScreenshotPage, error) {
+	s.gotFilter = filter
+	var page app.ScreenshotPage
+	for _, shot := range s.shots {
+		page.Items = append(page.Items, shot)
+	}
+	return page, nil
+}
+
+func (s *stubScreenshots) Get(ctx context.Context, id int64) (*app.Screenshot, error) {
+	shot, ok := s.shots[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return &shot, nil
+}
+
+func TestScreenshotsEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shot.png")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 400, 200))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	stub := &stubScreenshots{shots: map[int64]app.Screenshot{
+		1: {ID: 1, Path: path},
+		2: {ID: 2, Path: filepath.Join(dir, "notes.txt")},
+	}}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithScreenshotsUsecase(stub))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/api/v1/screenshots?world=wrld_a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: status %d, want 200", rec.Code)
+	}
+	var page app.ScreenshotPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Errorf("list: got %d items, want 2", len(page.Items))
+	}
+	if stub.gotFilter.World == nil || *stub.gotFilter.World != "wrld_a" {
+		t.Error("expected world filter to be wrld_a")
+	}
+
+	rec = get("/api/v1/screenshots/1/image")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("image: status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = get("/api/v1/screenshots/1/image?size=100")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("thumbnail: status %d, want 200", rec.Code)
+	}
+	img, _, err := image.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+		t.Errorf("thumbnail size = %dx%d, want 100x50", b.Dx(), b.Dy())
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/screenshots/3/image":          http.StatusNotFound,
+		"/api/v1/screenshots/2/image":          http.StatusNotFound, // not an image
+		"/api/v1/screenshots/x/image":          http.StatusBadRequest,
+		"/api/v1/screenshots/1/image?size=0":   http.StatusBadRequest,
+		"/api/v1/screenshots/1/image?size=big": http.StatusBadRequest,
+	} {
+		if rec := get(target); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+}
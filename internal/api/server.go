@@ -21,9 +21,44 @@ type Server struct {
 	state  app.StateUsecase
 	cfg    app.ConfigUsecase
 	stats  app.StatsUsecase
-
-	// SSE hub
-	hub *Hub
+	ingest app.IngestUsecase
+
+	screenshots app.ScreenshotsUsecase
+	media       app.MediaUsecase
+	notes       app.NotesUsecase
+	bookmarks   app.BookmarksUsecase
+	pins        app.PinsUsecase
+	views       app.ViewsUsecase
+	notifyRules app.NotifyRulesUsecase
+	players     app.PlayersUsecase
+	worlds      app.WorldsUsecase
+	memories    app.MemoriesUsecase
+	milestones  app.MilestonesUsecase
+	vrTime      app.VRTimeUsecase
+	snapshots   app.SnapshotsUsecase
+	receive     app.ReceiveUsecase
+	diagnostics app.DiagnosticsUsecase
+	maintenance app.MaintenanceUsecase
+	jobs        app.JobsUsecase
+	logs        app.LogsUsecase
+	support     app.SupportUsecase
+	eventDelete app.EventDeleteUsecase
+	trash       app.TrashUsecase
+	export      app.ExportUsecase
+	backups     app.BackupsUsecase
+	sessionsUC  app.SessionsUsecase
+
+	// SSE hubs
+	hub        *Hub
+	derivedHub *DerivedHub
+	actionHub  *ActionHub
+
+	// Clients connected to the SSE streams
+	streamClients streamClients
+
+	// SSE heartbeat interval and the bounds of the intervals clients may
+	// ask for
+	heartbeat, minHeartbeat, maxHeartbeat time.Duration
 
 	// Auth configuration
 	authEnabled  bool
@@ -33,6 +68,9 @@ type Server struct {
 	// SSE token configuration
 	sseSecret []byte
 
+	// Web UI session cookies (auth and SSE secret required)
+	sessions *sessionManager
+
 	// Web UI filesystem
 	webFS fs.FS
 
@@ -47,8 +85,26 @@ type Server struct {
 
 	// CSRF allowed hosts (derived from server address)
 	csrfAllowedHosts []string
+
+	// Client IP allowlist and denylist
+	ipFilter *IPFilter
+
+	// Event fields hidden from clients other than this PC
+	lanMask EventMask
+
+	// Extra Content Security Policy sources
+	cspSources CSPSources
+
+	// Time budget of non-streaming API requests (0 = none)
+	requestTimeout time.Duration
 }
 
+// DefaultRequestTimeout is the time budget of an API request, except SSE
+// streams and maintenance. Store queries still running when it expires are
+// interrupted and the request fails with 503, so slow queries do not pile
+// up behind SQLite's single writer.
+const DefaultRequestTimeout = 15 * time.Second
+
 // ServerOption configures a Server.
 type ServerOption func(*Server)
 
@@ -72,11 +128,147 @@ func WithStatsUsecase(stats app.StatsUsecase) ServerOption {
 	return func(s *Server) { s.stats = stats }
 }
 
+// WithIngestUsecase sets the ingest control use case.
+func WithIngestUsecase(ingest app.IngestUsecase) ServerOption {
+	return func(s *Server) { s.ingest = ingest }
+}
+
+// WithReceiveUsecase sets the use case for events forwarded by remote agents.
+func WithReceiveUsecase(receive app.ReceiveUsecase) ServerOption {
+	return func(s *Server) { s.receive = receive }
+}
+
+// WithScreenshotsUsecase sets the screenshots use case.
+func WithScreenshotsUsecase(uc app.ScreenshotsUsecase) ServerOption {
+	return func(s *Server) { s.screenshots = uc }
+}
+
+// WithMediaUsecase sets the video URL history use case.
+func WithMediaUsecase(uc app.MediaUsecase) ServerOption {
+	return func(s *Server) { s.media = uc }
+}
+
+// WithNotesUsecase sets the event notes use case.
+func WithNotesUsecase(uc app.NotesUsecase) ServerOption {
+	return func(s *Server) { s.notes = uc }
+}
+
+// WithSnapshotsUsecase sets the derived state history use case.
+func WithSnapshotsUsecase(uc app.SnapshotsUsecase) ServerOption {
+	return func(s *Server) { s.snapshots = uc }
+}
+
+// WithBookmarksUsecase sets the bookmarks and launch links use case.
+func WithBookmarksUsecase(uc app.BookmarksUsecase) ServerOption {
+	return func(s *Server) { s.bookmarks = uc }
+}
+
+// WithPinsUsecase sets the pinned history use case.
+func WithPinsUsecase(uc app.PinsUsecase) ServerOption {
+	return func(s *Server) { s.pins = uc }
+}
+
+// WithSessionsUsecase sets the use case keeping web UI sessions across
+// restarts. Without it, logouts are forgotten on restart.
+func WithSessionsUsecase(uc app.SessionsUsecase) ServerOption {
+	return func(s *Server) { s.sessionsUC = uc }
+}
+
+// WithViewsUsecase sets the saved event views use case.
+func WithViewsUsecase(uc app.ViewsUsecase) ServerOption {
+	return func(s *Server) { s.views = uc }
+}
+
+// WithNotifyRulesUsecase sets the notify rules use case.
+func WithNotifyRulesUsecase(uc app.NotifyRulesUsecase) ServerOption {
+	return func(s *Server) { s.notifyRules = uc }
+}
+
+// WithPlayersUsecase sets the player lookups use case.
+func WithPlayersUsecase(uc app.PlayersUsecase) ServerOption {
+	return func(s *Server) { s.players = uc }
+}
+
+// WithWorldsUsecase sets the worlds directory use case.
+func WithWorldsUsecase(uc app.WorldsUsecase) ServerOption {
+	return func(s *Server) { s.worlds = uc }
+}
+
+// WithMemoriesUsecase sets the "on this day" use case.
+func WithMemoriesUsecase(uc app.MemoriesUsecase) ServerOption {
+	return func(s *Server) { s.memories = uc }
+}
+
+// WithMilestonesUsecase sets the milestones use case.
+func WithMilestonesUsecase(uc app.MilestonesUsecase) ServerOption {
+	return func(s *Server) { s.milestones = uc }
+}
+
+// WithVRTimeUsecase sets the time-in-VR budgets use case.
+func WithVRTimeUsecase(uc app.VRTimeUsecase) ServerOption {
+	return func(s *Server) { s.vrTime = uc }
+}
+
+// WithDiagnosticsUsecase sets the troubleshooting diagnostics use case.
+func WithDiagnosticsUsecase(uc app.DiagnosticsUsecase) ServerOption {
+	return func(s *Server) { s.diagnostics = uc }
+}
+
+// WithEventDeleteUsecase sets the bulk event deletion use case.
+func WithEventDeleteUsecase(uc app.EventDeleteUsecase) ServerOption {
+	return func(s *Server) { s.eventDelete = uc }
+}
+
+// WithTrashUsecase sets the undo of destructive operations.
+func WithTrashUsecase(uc app.TrashUsecase) ServerOption {
+	return func(s *Server) { s.trash = uc }
+}
+
+// WithExportUsecase sets the event export use case.
+func WithExportUsecase(uc app.ExportUsecase) ServerOption {
+	return func(s *Server) { s.export = uc }
+}
+
+// WithLogsUsecase sets the use case of reading the app's recent logs.
+func WithLogsUsecase(uc app.LogsUsecase) ServerOption {
+	return func(s *Server) { s.logs = uc }
+}
+
+// WithSupportUsecase sets the use case of producing support bundles.
+func WithSupportUsecase(uc app.SupportUsecase) ServerOption {
+	return func(s *Server) { s.support = uc }
+}
+
+// WithMaintenanceUsecase sets the database maintenance use case.
+func WithMaintenanceUsecase(uc app.MaintenanceUsecase) ServerOption {
+	return func(s *Server) { s.maintenance = uc }
+}
+
+// WithJobsUsecase sets the scheduled jobs use case.
+func WithJobsUsecase(uc app.JobsUsecase) ServerOption {
+	return func(s *Server) { s.jobs = uc }
+}
+
+// WithBackupsUsecase sets the backup download use case.
+func WithBackupsUsecase(uc app.BackupsUsecase) ServerOption {
+	return func(s *Server) { s.backups = uc }
+}
+
 // WithHub sets the SSE hub.
 func WithHub(hub *Hub) ServerOption {
 	return func(s *Server) { s.hub = hub }
 }
 
+// WithDerivedHub sets the SSE hub for derived events.
+func WithDerivedHub(hub *DerivedHub) ServerOption {
+	return func(s *Server) { s.derivedHub = hub }
+}
+
+// WithActionHub sets the hub routing UI actions to the web UI.
+func WithActionHub(hub *ActionHub) ServerOption {
+	return func(s *Server) { s.actionHub = hub }
+}
+
 // WithBasicAuth enables HTTP Basic Auth.
 func WithBasicAuth(username, password string) ServerOption {
 	return func(s *Server) {
@@ -118,6 +310,42 @@ func WithCSRFAllowedHosts(hosts []string) ServerOption {
 	return func(s *Server) { s.csrfAllowedHosts = hosts }
 }
 
+// WithIPFilter limits the clients allowed to connect (recommended for LAN
+// mode).
+func WithIPFilter(f IPFilter) ServerOption {
+	return func(s *Server) { s.ipFilter = &f }
+}
+
+// WithLANRedaction hides the fields in m from events sent to clients
+// other than this PC, by the events API and the SSE streams.
+func WithLANRedaction(m EventMask) ServerOption {
+	return func(s *Server) { s.lanMask = m }
+}
+
+// WithCSPSources adds sources to the Content Security Policy, e.g. for
+// scripts a custom web UI embeds.
+func WithCSPSources(src CSPSources) ServerOption {
+	return func(s *Server) { s.cspSources = src }
+}
+
+// WithRequestTimeout sets the time budget of API requests other than SSE
+// streams. 0 disables it. Defaults to DefaultRequestTimeout.
+func WithRequestTimeout(d time.Duration) ServerOption {
+	return func(s *Server) { s.requestTimeout = d }
+}
+
+// WithSSEHeartbeat sets the interval of the SSE streams' heartbeat
+// comments and the bounds of the intervals clients may ask for with
+// ?heartbeat=. Defaults to 20s, between 5s and 2m. Ignored unless
+// 0 < minInterval <= interval <= maxInterval.
+func WithSSEHeartbeat(interval, minInterval, maxInterval time.Duration) ServerOption {
+	return func(s *Server) {
+		if minInterval > 0 && minInterval <= interval && interval <= maxInterval {
+			s.heartbeat, s.minHeartbeat, s.maxHeartbeat = interval, minInterval, maxInterval
+		}
+	}
+}
+
 // NewServer creates a new API server with the given dependencies.
 func NewServer(addr string, health app.HealthUsecase, opts ...ServerOption) *Server {
 	mux := http.NewServeMux()
@@ -131,20 +359,28 @@ func NewServer(addr string, health app.HealthUsecase, opts ...ServerOption) *Ser
 			IdleTimeout:       60 * time.Second,
 			MaxHeaderBytes:    1 << 14, // 16KB - limit header size to prevent DoS
 		},
-		mux:    mux,
-		health: health,
+		mux:            mux,
+		health:         health,
+		requestTimeout: DefaultRequestTimeout,
+		heartbeat:      defaultHeartbeatInterval,
+		minHeartbeat:   minHeartbeatInterval,
+		maxHeartbeat:   maxHeartbeatInterval,
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
+	if s.authEnabled && len(s.sseSecret) > 0 {
+		s.sessions = newSessionManager(s.sseSecret, s.sessionsUC)
+	}
 	s.registerRoutes()
 
-	// Build middleware chain: security headers -> CORS -> CSRF -> mux
+	// Build middleware chain: security headers -> IP filter -> CORS -> CSRF -> mux
 	var handler http.Handler = mux
 
-	// Apply CSRF protection for state-changing requests
+	// Apply CSRF protection for state-changing requests: the session's
+	// CSRF token if sent, Origin/Referer otherwise
 	if len(s.csrfAllowedHosts) > 0 {
-		handler = csrfMiddleware(s.csrfAllowedHosts)(handler)
+		handler = s.sessions.csrfMiddleware(handler, csrfMiddleware(s.csrfAllowedHosts)(handler))
 	}
 
 	// Apply CORS if configured
@@ -152,16 +388,27 @@ func NewServer(addr string, health app.HealthUsecase, opts ...ServerOption) *Ser
 		handler = corsMiddleware(*s.corsConfig)(handler)
 	}
 
+	// Refuse clients outside the IP allowlist before anything else
+	if s.ipFilter != nil {
+		handler = ipFilterMiddleware(*s.ipFilter)(handler)
+	}
+
 	// Apply security headers (always)
-	handler = securityHeadersMiddleware(handler)
+	handler = securityHeadersMiddleware(s.cspSources)(handler)
 
 	s.httpServer.Handler = handler
 	return s
 }
 
 // wrapAuth wraps a handler with auth middleware if auth is enabled.
-// Also applies rate limiting if configured.
+// Accepts a session cookie as well as Basic Auth. Also applies the request timeout and rate limiting if configured.
 func (s *Server) wrapAuth(h http.Handler) http.Handler {
+	return s.wrapAuthUntimed(timeoutMiddleware(s.requestTimeout)(h))
+}
+
+// wrapAuthUntimed is wrapAuth without the request timeout, for requests
+// that are expected to run long, such as VACUUM.
+func (s *Server) wrapAuthUntimed(h http.Handler) http.Handler {
 	// Apply rate limiting first (if configured)
 	if s.rateLimiter != nil {
 		h = s.rateLimiter.Middleware(h)
@@ -169,12 +416,21 @@ func (s *Server) wrapAuth(h http.Handler) http.Handler {
 	if !s.authEnabled {
 		return h
 	}
-	return basicAuthMiddleware(s.authUsername, s.authPassword, s.authFailureLimiter)(h)
+	return s.sessions.middleware(h, basicAuthMiddleware(s.authUsername, s.authPassword, s.authFailureLimiter)(h))
+}
+
+// wrapRateLimit applies rate limiting, if configured, to a handler that
+// checks credentials itself.
+func (s *Server) wrapRateLimit(h http.Handler) http.Handler {
+	if s.rateLimiter != nil {
+		return s.rateLimiter.Middleware(h)
+	}
+	return h
 }
 
 // wrapSSEAuth wraps a handler with SSE-aware auth middleware.
-// Accepts both Basic Auth and SSE tokens via query parameter.
-// Also applies rate limiting if configured.
+// Accepts Basic Auth, a session cookie, and SSE tokens via query parameter.
+// Also applies rate limiting if configured, apart from other requests.
 func (s *Server) wrapSSEAuth(h http.Handler) http.Handler {
 	// Apply rate limiting first (if configured)
 	if s.rateLimiter != nil {
@@ -183,7 +439,7 @@ func (s *Server) wrapSSEAuth(h http.Handler) http.Handler {
 	if !s.authEnabled {
 		return h
 	}
-	return sseTokenMiddleware(s.authUsername, s.authPassword, s.sseSecret, s.authFailureLimiter)(h)
+	return s.sessions.middleware(h, sseTokenMiddleware(s.authUsername, s.authPassword, s.sseSecret, s.authFailureLimiter)(h))
 }
 
 // registerRoutes sets up the API routes.
@@ -194,6 +450,28 @@ func (s *Server) registerRoutes() {
 	// Events endpoint (auth required if configured)
 	if s.events != nil {
 		s.mux.Handle("GET /api/v1/events", s.wrapAuth(http.HandlerFunc(s.handleEvents)))
+		s.mux.Handle("GET /api/v1/events/changes", s.wrapAuth(http.HandlerFunc(s.handleEventChanges)))
+		s.mux.Handle("GET /api/v1/events/aggregate", s.wrapAuth(http.HandlerFunc(s.handleEventsAggregate)))
+		// Untimed, as streaming every event of a large database takes a while
+		s.mux.Handle("GET /api/v1/events/stream-export", s.wrapAuthUntimed(http.HandlerFunc(s.handleEventsStreamExport)))
+	}
+
+	// Bulk delete (auth required if configured). Untimed, as a confirmed
+	// deletion of many events takes a while and must not stop halfway.
+	if s.eventDelete != nil {
+		s.mux.Handle("POST /api/v1/events/delete", s.wrapAuthUntimed(http.HandlerFunc(s.handleEventsDelete)))
+	}
+
+	// Event export (auth required if configured). Untimed, as a full
+	// export of a large database takes a while.
+	if s.export != nil {
+		s.mux.Handle("GET /api/v1/export/events", s.wrapAuthUntimed(http.HandlerFunc(s.handleExportEvents)))
+	}
+
+	// Trash endpoints (auth required if configured)
+	if s.trash != nil {
+		s.mux.Handle("GET /api/v1/trash", s.wrapAuth(http.HandlerFunc(s.handleTrash)))
+		s.mux.Handle("POST /api/v1/trash/restore", s.wrapAuth(http.HandlerFunc(s.handleTrashRestore)))
 	}
 
 	// Now endpoint (auth required if configured)
@@ -204,22 +482,182 @@ func (s *Server) registerRoutes() {
 	// Stats endpoint (auth required if configured)
 	if s.stats != nil {
 		s.mux.Handle("GET /api/v1/stats/basic", s.wrapAuth(http.HandlerFunc(s.handleStats)))
+		s.mux.Handle("GET /api/v1/stats/instances", s.wrapAuth(http.HandlerFunc(s.handleInstanceStats)))
+		s.mux.Handle("GET /api/v1/stats/copresence", s.wrapAuth(http.HandlerFunc(s.handleCopresenceStats)))
+		s.mux.Handle("GET /api/v1/stats/heatmap", s.wrapAuth(http.HandlerFunc(s.handleHeatmapStats)))
+		s.mux.Handle("GET /api/v1/stats/hosting", s.wrapAuth(http.HandlerFunc(s.handleHostingStats)))
 	}
 
 	// SSE stream endpoint (auth required if configured, accepts token auth)
 	if s.hub != nil && s.events != nil {
 		s.mux.Handle("GET /api/v1/stream", s.wrapSSEAuth(http.HandlerFunc(s.handleStream)))
 	}
+	if s.derivedHub != nil {
+		s.mux.Handle("GET /api/v1/stream/derived", s.wrapSSEAuth(http.HandlerFunc(s.handleDerivedStream)))
+	}
+	if s.actionHub != nil {
+		s.mux.Handle("GET /api/v1/actions/stream", s.wrapSSEAuth(http.HandlerFunc(s.handleActionStream)))
+		s.mux.Handle("POST /api/v1/actions/show-player/{id}", s.wrapAuth(http.HandlerFunc(s.handleShowPlayer)))
+	}
+	if (s.hub != nil && s.events != nil) || s.derivedHub != nil || s.actionHub != nil {
+		s.mux.Handle("GET /api/v1/stream/clients", s.wrapAuth(http.HandlerFunc(s.handleStreamClients)))
+		s.mux.Handle("DELETE /api/v1/stream/clients/{id}", s.wrapAuth(http.HandlerFunc(s.handleKickStreamClient)))
+	}
 
 	// Auth token endpoint (auth required if configured, issues SSE tokens)
 	if len(s.sseSecret) > 0 {
 		s.mux.Handle("POST /api/v1/auth/token", s.wrapAuth(http.HandlerFunc(s.handleAuthToken)))
 	}
 
+	// Session login and logout for the web UI (no auth required; login
+	// checks the credentials itself)
+	if s.sessions != nil {
+		s.mux.Handle("POST /api/v1/auth/login", s.wrapRateLimit(http.HandlerFunc(s.handleLogin)))
+		s.mux.Handle("POST /api/v1/auth/logout", s.wrapRateLimit(http.HandlerFunc(s.handleLogout)))
+		s.mux.Handle("POST /api/v1/auth/logout-all", s.wrapAuth(http.HandlerFunc(s.handleLogoutAll)))
+	}
+
 	// Config endpoints (auth required if configured)
 	if s.cfg != nil {
 		s.mux.Handle("GET /api/v1/config", s.wrapAuth(http.HandlerFunc(s.handleGetConfig)))
 		s.mux.Handle("PUT /api/v1/config", s.wrapAuth(http.HandlerFunc(s.handlePutConfig)))
+		s.mux.Handle("POST /api/v1/config/validate", s.wrapAuth(http.HandlerFunc(s.handleValidateConfig)))
+		s.mux.Handle("GET /api/v1/config/export", s.wrapAuth(http.HandlerFunc(s.handleExportSettings)))
+		s.mux.Handle("POST /api/v1/config/import", s.wrapAuth(http.HandlerFunc(s.handleImportSettings)))
+
+		// UI branding (no auth required)
+		s.mux.HandleFunc("GET /api/v1/ui-config", s.handleUIConfig)
+	}
+
+	// Ingest control endpoints (auth required if configured)
+	if s.ingest != nil {
+		s.mux.Handle("POST /api/v1/ingest/pause", s.wrapAuth(http.HandlerFunc(s.handleIngestPause)))
+		s.mux.Handle("POST /api/v1/ingest/resume", s.wrapAuth(http.HandlerFunc(s.handleIngestResume)))
+	}
+
+	// Events forwarded by remote agents (auth required if configured)
+	if s.receive != nil {
+		s.mux.Handle("POST /api/v1/ingest/events", s.wrapAuth(http.HandlerFunc(s.handleIngestEvents)))
+	}
+
+	// Screenshot endpoints (auth required if configured)
+	if s.screenshots != nil {
+		s.mux.Handle("GET /api/v1/screenshots", s.wrapAuth(http.HandlerFunc(s.handleScreenshots)))
+		s.mux.Handle("GET /api/v1/screenshots/{id}/image", s.wrapAuth(http.HandlerFunc(s.handleScreenshotImage)))
+	}
+
+	// Media history endpoint (auth required if configured)
+	if s.media != nil {
+		s.mux.Handle("GET /api/v1/media", s.wrapAuth(http.HandlerFunc(s.handleMedia)))
+	}
+
+	// Note endpoints (auth required if configured)
+	if s.notes != nil {
+		s.mux.Handle("POST /api/v1/events/{id}/notes", s.wrapAuth(http.HandlerFunc(s.handleAddNote)))
+		s.mux.Handle("GET /api/v1/events/{id}/notes", s.wrapAuth(http.HandlerFunc(s.handleEventNotes)))
+		s.mux.Handle("GET /api/v1/notes", s.wrapAuth(http.HandlerFunc(s.handleSearchNotes)))
+		s.mux.Handle("DELETE /api/v1/notes/{id}", s.wrapAuth(http.HandlerFunc(s.handleDeleteNote)))
+	}
+
+	// Bookmark and rejoin endpoints (auth required if configured)
+	if s.bookmarks != nil {
+		s.mux.Handle("GET /api/v1/events/{id}/launch", s.wrapAuth(http.HandlerFunc(s.handleEventLaunch)))
+		s.mux.Handle("GET /api/v1/bookmarks", s.wrapAuth(http.HandlerFunc(s.handleListBookmarks)))
+		s.mux.Handle("POST /api/v1/bookmarks", s.wrapAuth(http.HandlerFunc(s.handleAddBookmark)))
+		s.mux.Handle("DELETE /api/v1/bookmarks/{id}", s.wrapAuth(http.HandlerFunc(s.handleDeleteBookmark)))
+	}
+
+	// Pin endpoints (auth required if configured)
+	if s.pins != nil {
+		s.mux.Handle("GET /api/v1/pins", s.wrapAuth(http.HandlerFunc(s.handleListPins)))
+		s.mux.Handle("POST /api/v1/pins", s.wrapAuth(http.HandlerFunc(s.handleAddPin)))
+		s.mux.Handle("DELETE /api/v1/pins/{id}", s.wrapAuth(http.HandlerFunc(s.handleDeletePin)))
+	}
+
+	// Saved view endpoints (auth required if configured)
+	if s.views != nil {
+		s.mux.Handle("GET /api/v1/views", s.wrapAuth(http.HandlerFunc(s.handleListViews)))
+		s.mux.Handle("POST /api/v1/views", s.wrapAuth(http.HandlerFunc(s.handleSaveView)))
+		s.mux.Handle("GET /api/v1/views/{name}", s.wrapAuth(http.HandlerFunc(s.handleGetView)))
+		s.mux.Handle("DELETE /api/v1/views/{name}", s.wrapAuth(http.HandlerFunc(s.handleDeleteView)))
+		s.mux.Handle("GET /api/v1/views/{name}/events", s.wrapAuth(http.HandlerFunc(s.handleViewEvents)))
+	}
+	if s.notifyRules != nil {
+		s.mux.Handle("GET /api/v1/notify/rules", s.wrapAuth(http.HandlerFunc(s.handleListNotifyRules)))
+		s.mux.Handle("POST /api/v1/notify/rules", s.wrapAuth(http.HandlerFunc(s.handleAddNotifyRule)))
+		s.mux.Handle("PUT /api/v1/notify/rules/{index}", s.wrapAuth(http.HandlerFunc(s.handleReplaceNotifyRule)))
+		s.mux.Handle("DELETE /api/v1/notify/rules/{index}", s.wrapAuth(http.HandlerFunc(s.handleDeleteNotifyRule)))
+	}
+	if s.players != nil {
+		s.mux.Handle("GET /api/v1/players", s.wrapAuth(http.HandlerFunc(s.handlePlayers)))
+		s.mux.Handle("GET /api/v1/players/{id}", s.wrapAuth(http.HandlerFunc(s.handlePlayerProfile)))
+		s.mux.Handle("GET /api/v1/players/links", s.wrapAuth(http.HandlerFunc(s.handlePlayerLinks)))
+		s.mux.Handle("POST /api/v1/players/merge", s.wrapAuth(http.HandlerFunc(s.handleMergePlayer)))
+		s.mux.Handle("DELETE /api/v1/players/links/{name}", s.wrapAuth(http.HandlerFunc(s.handleUnlinkPlayer)))
+		s.mux.Handle("GET /api/v1/players/{name}/lastseen", s.wrapAuth(http.HandlerFunc(s.handlePlayerLastSeen)))
+	}
+	if s.worlds != nil {
+		s.mux.Handle("GET /api/v1/worlds", s.wrapAuth(http.HandlerFunc(s.handleWorlds)))
+	}
+
+	// On this day in earlier years (auth required if configured)
+	if s.memories != nil {
+		s.mux.Handle("GET /api/v1/memories", s.wrapAuth(http.HandlerFunc(s.handleMemories)))
+	}
+
+	// Milestones and play streak (auth required if configured)
+	if s.milestones != nil {
+		s.mux.Handle("GET /api/v1/milestones", s.wrapAuth(http.HandlerFunc(s.handleMilestones)))
+	}
+
+	// Time in VR against the budgets (auth required if configured)
+	if s.vrTime != nil {
+		s.mux.Handle("GET /api/v1/vrtime", s.wrapAuth(http.HandlerFunc(s.handleVRTime)))
+	}
+
+	// State history endpoint (auth required if configured)
+	if s.snapshots != nil {
+		s.mux.Handle("GET /api/v1/now/history", s.wrapAuth(http.HandlerFunc(s.handleNowHistory)))
+	}
+
+	// Diagnostics endpoints (auth required if configured)
+	if s.diagnostics != nil {
+		s.mux.Handle("GET /api/v1/diagnostics/logpath", s.wrapAuth(http.HandlerFunc(s.handleLogPathDiagnostics)))
+		s.mux.Handle("GET /api/v1/diagnostics/db", s.wrapAuth(http.HandlerFunc(s.handleDatabaseDiagnostics)))
+		s.mux.Handle("GET /api/v1/diagnostics/parse-failures", s.wrapAuth(http.HandlerFunc(s.handleParseFailureDiagnostics)))
+	}
+	if s.rateLimiter != nil {
+		s.mux.Handle("GET /api/v1/diagnostics/ratelimit", s.wrapAuth(http.HandlerFunc(s.handleRateLimitDiagnostics)))
+	}
+
+	// Maintenance endpoints (auth required if configured)
+	if s.maintenance != nil {
+		s.mux.Handle("GET /api/v1/admin/vacuum", s.wrapAuth(http.HandlerFunc(s.handleVacuumStatus)))
+		s.mux.Handle("POST /api/v1/admin/vacuum", s.wrapAuthUntimed(http.HandlerFunc(s.handleVacuum)))
+	}
+
+	// Scheduled jobs (auth required if configured)
+	if s.jobs != nil {
+		s.mux.Handle("GET /api/v1/jobs", s.wrapAuth(http.HandlerFunc(s.handleJobs)))
+		s.mux.Handle("POST /api/v1/jobs/{name}/run", s.wrapAuth(http.HandlerFunc(s.handleRunJob)))
+	}
+
+	// App logs (auth required if configured)
+	if s.logs != nil {
+		s.mux.Handle("GET /api/v1/logs/tail", s.wrapAuth(http.HandlerFunc(s.handleLogsTail)))
+	}
+
+	// Support bundle (auth required if configured). Untimed as counting
+	// the rows of a large database takes a while.
+	if s.support != nil {
+		s.mux.Handle("POST /api/v1/support/bundle", s.wrapAuthUntimed(http.HandlerFunc(s.handleSupportBundle)))
+	}
+
+	// Backup download endpoints (auth required if configured). Downloads
+	// are untimed as large backups take a while over slow links.
+	if s.backups != nil {
+		s.mux.Handle("GET /api/v1/backups", s.wrapAuth(http.HandlerFunc(s.handleBackups)))
+		s.mux.Handle("GET /api/v1/backups/{name}", s.wrapAuthUntimed(http.HandlerFunc(s.handleBackupDownload)))
 	}
 
 	// Static file serving (catch-all, must be last)
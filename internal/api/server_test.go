@@ -293,3 +293,66 @@ func TestWithBasicAuth_EmptyCredentials(t *testing.T) {
 		t.Errorf("expected status %d (auth disabled with empty password), got %d", http.StatusOK, rec2.Code)
 	}
 }
+
+// stubIngestController is a test double for app.IngestController.
+type stubIngestController struct {
+	paused bool
+}
+
+func (c *stubIngestController) Pause() bool {
+	changed := !c.paused
+	c.paused = true
+	return changed
+}
+
+func (c *stubIngestController) Resume() bool {
+	changed := c.paused
+	c.paused = false
+	return changed
+}
+
+func (c *stubIngestController) Paused() bool { return c.paused }
+
+func TestIngestPauseResumeEndpoints(t *testing.T) {
+	ctrl := &stubIngestController{}
+	health := app.HealthService{Version: "test", Ingest: ctrl}
+	server := NewServer(":8080", health, WithIngestUsecase(app.IngestService{Ingester: ctrl}))
+
+	post := func(path string) app.IngestStatus {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", path, http.StatusOK, rec.Code)
+		}
+		var resp app.IngestStatus
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := post("/api/v1/ingest/pause"); !resp.Paused || !resp.Changed {
+		t.Errorf("pause: got %+v, want paused and changed", resp)
+	}
+	if resp := post("/api/v1/ingest/pause"); !resp.Paused || resp.Changed {
+		t.Errorf("second pause: got %+v, want paused and unchanged", resp)
+	}
+
+	// Health reflects the paused state
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/health", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+	var healthResp app.HealthResult
+	if err := json.NewDecoder(rec.Body).Decode(&healthResp); err != nil {
+		t.Fatalf("failed to decode health response: %v", err)
+	}
+	if got := healthResp.Components["ingest"].Status; got != app.StatusPaused {
+		t.Errorf("ingest component status = %q, want %q", got, app.StatusPaused)
+	}
+
+	if resp := post("/api/v1/ingest/resume"); resp.Paused || !resp.Changed {
+		t.Errorf("resume: got %+v, want running and changed", resp)
+	}
+}
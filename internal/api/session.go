@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/api/sseauth"
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+// SessionCookieName is the name of the web UI session cookie.
+const SessionCookieName = "vrclog_session"
+
+// SessionTTL is how long a web UI login lasts.
+const SessionTTL = 7 * 24 * time.Hour
+
+// CSRF protection for session cookies (double submit): the web UI reads the
+// token from CSRFCookieName, or the login response, and sends it back in
+// CSRFHeader on mutating requests. The token is derived from the session,
+// so a cookie planted by another site cannot supply one.
+const (
+	CSRFCookieName = "vrclog_csrf"
+	CSRFHeader     = "X-CSRF-Token"
+)
+
+// sessionManager issues and checks web UI session cookies, so browsers need
+// not send Basic Auth with every request. Sessions are tokens in a scope of
+// their own, signed with a key derived from the SSE HMAC secret and the
+// session generation; logging out revokes the token until it expires, and
+// logging out everywhere moves to a new generation. With a sessions use
+// case both are kept across restarts, and a new generation set through it
+// elsewhere, e.g. by a password change, takes effect on the next request.
+type sessionManager struct {
+	secret   []byte
+	sessions app.SessionsUsecase // nil keeps the state in memory only
+
+	mu      sync.Mutex
+	key     []byte
+	gen     int64
+	follow  bool                 // derive key from the generation of sessions
+	revoked map[string]time.Time // token hash -> expiry
+}
+
+// newSessionManager creates a sessionManager signing with secret, loading
+// the generation and revocations from sessions if not nil. If they cannot
+// be loaded, it signs with a random key, so no earlier session is accepted.
+func newSessionManager(secret []byte, sessions app.SessionsUsecase) *sessionManager {
+	m := &sessionManager{secret: secret, sessions: sessions, revoked: make(map[string]time.Time)}
+	m.key = sessionKey(secret, 0)
+	if sessions == nil {
+		return m
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	gen, err := sessions.Generation(ctx)
+	if err == nil {
+		m.key, m.gen, m.follow = sessionKey(secret, gen), gen, true
+		m.revoked, err = sessions.Revoked(ctx, time.Now())
+	}
+	if err != nil {
+		m.follow = false
+		slog.Error("failed to load web UI sessions, ending all sessions", "error", err)
+		m.key = make([]byte, sha256.Size)
+		if _, err := rand.Read(m.key); err != nil {
+			panic(err) // crypto/rand never fails on supported platforms
+		}
+		m.revoked = make(map[string]time.Time)
+	}
+	return m
+}
+
+// sessionKey derives the session signing key of a generation.
+func sessionKey(secret []byte, gen int64) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("session." + strconv.FormatInt(gen, 10)))
+	return mac.Sum(nil)
+}
+
+// tokenHash identifies a session token in the revocation list without
+// storing the token itself.
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signingKey returns the current session signing key, moving to the
+// current generation of the sessions use case if it changed.
+func (m *sessionManager) signingKey(ctx context.Context) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.follow {
+		return m.key
+	}
+	// Cheap: SessionsService keeps the generation in memory
+	if gen, err := m.sessions.Generation(ctx); err == nil && gen != m.gen {
+		// Revocations are of tokens the old key signed
+		m.key, m.gen = sessionKey(m.secret, gen), gen
+		clear(m.revoked)
+	}
+	return m.key
+}
+
+// issue sets a new session cookie and its CSRF cookie on w, and returns
+// the CSRF token and when the session expires.
+func (m *sessionManager) issue(w http.ResponseWriter, r *http.Request) (string, time.Time, error) {
+	now := time.Now()
+	key := m.signingKey(r.Context())
+	token, err := sseauth.GenerateTokenTTL(key, sseauth.ScopeSession, now, SessionTTL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	csrf := csrfToken(key, token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(SessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	// Readable by the web UI's scripts, which must send it back
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    csrf,
+		Path:     "/",
+		MaxAge:   int(SessionTTL.Seconds()),
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return csrf, now.Add(SessionTTL), nil
+}
+
+// csrfToken returns the CSRF token of the session token signed with key.
+func csrfToken(key []byte, session string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("csrf." + session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// token returns the session token of r if it is valid and not revoked.
+func (m *sessionManager) token(r *http.Request) (string, sseauth.Claims, bool) {
+	c, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return "", sseauth.Claims{}, false
+	}
+	claims, err := sseauth.ValidateToken(c.Value, m.signingKey(r.Context()), sseauth.ScopeSession, time.Now())
+	if err != nil {
+		return "", sseauth.Claims{}, false
+	}
+	m.mu.Lock()
+	_, revoked := m.revoked[tokenHash(c.Value)]
+	m.mu.Unlock()
+	return c.Value, claims, !revoked
+}
+
+// revoke ends the session of r, if any, and clears its cookie.
+func (m *sessionManager) revoke(w http.ResponseWriter, r *http.Request) error {
+	if token, claims, ok := m.token(r); ok {
+		now := time.Now()
+		hash, exp := tokenHash(token), time.Unix(claims.Exp, 0)
+		m.mu.Lock()
+		for t, e := range m.revoked {
+			if e.Before(now) {
+				delete(m.revoked, t)
+			}
+		}
+		m.revoked[hash] = exp
+		m.mu.Unlock()
+		if m.sessions != nil {
+			if err := m.sessions.Revoke(r.Context(), hash, exp); err != nil {
+				return err
+			}
+		}
+	}
+	clearSessionCookies(w, r)
+	return nil
+}
+
+// revokeAll ends every session by moving to a new generation, and clears
+// the cookies of r.
+func (m *sessionManager) revokeAll(w http.ResponseWriter, r *http.Request) error {
+	if m.sessions == nil {
+		key := make([]byte, sha256.Size)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.key = key
+		clear(m.revoked)
+		m.mu.Unlock()
+		clearSessionCookies(w, r)
+		return nil
+	}
+	gen, err := m.sessions.LogoutAll(r.Context())
+	if err != nil {
+		return err
+	}
+	// Every earlier token is now invalid, so the generation can be
+	// followed even if loading the sessions failed at startup
+	m.mu.Lock()
+	m.key, m.gen, m.follow = sessionKey(m.secret, gen), gen, true
+	clear(m.revoked)
+	m.mu.Unlock()
+	clearSessionCookies(w, r)
+	return nil
+}
+
+// clearSessionCookies deletes the session and CSRF cookies.
+func clearSessionCookies(w http.ResponseWriter, r *http.Request) {
+	for _, name := range []string{SessionCookieName, CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == SessionCookieName,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+}
+
+// middleware serves requests with a valid session cookie with next, and
+// hands the others to fallback, e.g. Basic Auth. A nil manager always
+// falls back.
+func (m *sessionManager) middleware(next, fallback http.Handler) http.Handler {
+	if m == nil {
+		return fallback
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := m.token(r); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+// csrfMiddleware checks CSRFHeader on mutating requests that send it. A
+// token matching the session cookie lets the request through to next
+// without the Origin/Referer checks of fallback, which fail behind proxies
+// that strip those headers; a wrong token is refused. Requests without the
+// header go to fallback. A nil manager always falls back.
+func (m *sessionManager) csrfMiddleware(next, fallback http.Handler) http.Handler {
+	if m == nil {
+		return fallback
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		csrf := r.Header.Get(CSRFHeader)
+		if csrf == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete) {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		c, err := r.Cookie(SessionCookieName)
+		if err != nil || !hmac.Equal([]byte(csrf), []byte(csrfToken(m.signingKey(r.Context()), c.Value))) {
+			writeErrorCode(w, http.StatusForbidden, ErrCodeCSRFFailed, "Forbidden: invalid CSRF token", nil, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loginRequest is the body of POST /api/v1/auth/login.
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// loginResponse is the response of POST /api/v1/auth/login.
+type loginResponse struct {
+	CSRFToken string    `json:"csrf_token"` // send in CSRFHeader on mutating requests
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleLogin handles POST /api/v1/auth/login requests. Checks the Basic
+// Auth credentials and sets a session cookie. Failures count towards the
+// auth failure lockout like failed Basic Auth.
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	ip := extractIP(r)
+	afl := s.authFailureLimiter
+	if afl != nil && afl.IsLocked(ip) {
+		writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	usernameMatch := constantTimeEqualString(req.Username, s.authUsername)
+	passwordMatch := constantTimeEqualString(req.Password, s.authPassword)
+	if !usernameMatch || !passwordMatch {
+		if afl != nil && afl.RecordFailure(ip) < 0 {
+			writeLockedOut(w, afl.LockoutSecondsRemaining(ip))
+			return
+		}
+		// No WWW-Authenticate, so browsers do not show their own prompt
+		writeError(w, http.StatusUnauthorized, "invalid username or password", nil)
+		return
+	}
+	if afl != nil {
+		afl.RecordSuccess(ip)
+	}
+
+	csrf, expiresAt, err := s.sessions.issue(w, r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, loginResponse{CSRFToken: csrf, ExpiresAt: expiresAt})
+}
+
+// handleLogout handles POST /api/v1/auth/logout requests. Ends the session
+// of the cookie sent, if any; it needs no other auth.
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if err := s.sessions.revoke(w, r); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLogoutAll handles POST /api/v1/auth/logout-all requests. Ends every
+// session, on every browser.
+func (s *Server) handleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	if err := s.sessions.revokeAll(w, r); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/api/sseauth"
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+func TestSessionCookieAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithBasicAuth("user", "pass"),
+		WithSSESecret(secret),
+		WithAuthFailureLimiter(NewAuthFailureLimiter(DefaultAuthFailureLimiterConfig())),
+		WithBackupsUsecase(&app.BackupService{Dir: t.TempDir()}))
+
+	do := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/backups", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("no auth: status %d, want 401", rec.Code)
+	}
+	rec := do(http.MethodPost, "/api/v1/auth/login", `{"username":"user","password":"wrong"}`, nil)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != "" {
+		t.Fatalf("wrong password: status %d, WWW-Authenticate %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+
+	rec = do(http.MethodPost, "/api/v1/auth/login", `{"username":"user","password":"pass"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, want 200", rec.Code)
+	}
+	var session *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			session = c
+		}
+	}
+	if session == nil || !session.HttpOnly || session.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("session cookie = %+v", session)
+	}
+
+	if rec := do(http.MethodGet, "/api/v1/backups", "", session); rec.Code != http.StatusOK {
+		t.Errorf("with session: status %d, want 200", rec.Code)
+	}
+
+	// An SSE token is not a session
+	token, _ := sseauth.GenerateToken(secret, sseauth.ScopeSSE, time.Now())
+	forged := &http.Cookie{Name: SessionCookieName, Value: token}
+	if rec := do(http.MethodGet, "/api/v1/backups", "", forged); rec.Code != http.StatusUnauthorized {
+		t.Errorf("SSE token as session: status %d, want 401", rec.Code)
+	}
+
+	rec = do(http.MethodPost, "/api/v1/auth/logout", "", session)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("logout: status %d, want 204", rec.Code)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.MaxAge >= 0 {
+			t.Errorf("logout cookie %+v, want it cleared", c)
+		}
+	}
+	if rec := do(http.MethodGet, "/api/v1/backups", "", session); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status %d, want 401", rec.Code)
+	}
+}
+
+func TestSessionCSRFToken(t *testing.T) {
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithBasicAuth("user", "pass"),
+		WithSSESecret([]byte("0123456789abcdef0123456789abcdef")),
+		WithCSRFAllowedHosts([]string{"192.168.1.10:8080"}))
+	handler := server.httpServer.Handler
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(`{"username":"user","password":"pass"}`))
+	req.Header.Set("Origin", "http://192.168.1.10:8080")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login: status %d, want 200", rec.Code)
+	}
+	var login loginResponse
+	if err := json.NewDecoder(rec.Body).Decode(&login); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	cookies := make(map[string]*http.Cookie)
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	if cookies[CSRFCookieName] == nil || cookies[CSRFCookieName].HttpOnly || cookies[CSRFCookieName].Value != login.CSRFToken {
+		t.Fatalf("CSRF cookie = %+v, want a readable cookie holding %q", cookies[CSRFCookieName], login.CSRFToken)
+	}
+
+	// Logout stands in for any mutating request; none send Origin or Referer,
+	// as behind a proxy that strips them
+	logout := func(csrf string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+		req.AddCookie(cookies[SessionCookieName])
+		if csrf != "" {
+			req.Header.Set(CSRFHeader, csrf)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := logout(""); code != http.StatusForbidden {
+		t.Errorf("no token: status %d, want 403 from the Origin check", code)
+	}
+	if code := logout("forged"); code != http.StatusForbidden {
+		t.Errorf("wrong token: status %d, want 403", code)
+	}
+	if code := logout(login.CSRFToken); code != http.StatusNoContent {
+		t.Errorf("valid token: status %d, want 204", code)
+	}
+}
+
+// memSessionStore is an in-memory app.SessionStore standing in for the
+// database across restarts.
+type memSessionStore struct {
+	gen     int64
+	revoked map[string]time.Time
+}
+
+func (m *memSessionStore) SessionGeneration(context.Context) (int64, error) { return m.gen, nil }
+
+func (m *memSessionStore) BumpSessionGeneration(context.Context) (int64, error) {
+	m.gen++
+	return m.gen, nil
+}
+
+func (m *memSessionStore) RevokeSession(_ context.Context, hash string, exp time.Time) error {
+	m.revoked[hash] = exp
+	return nil
+}
+
+func (m *memSessionStore) RevokedSessions(context.Context, time.Time) (map[string]time.Time, error) {
+	return maps.Clone(m.revoked), nil
+}
+
+func TestSessionRevocationSurvivesRestart(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	sessions := &app.SessionsService{Store: &memSessionStore{revoked: make(map[string]time.Time)}}
+
+	login := func(m *sessionManager) *http.Cookie {
+		rec := httptest.NewRecorder()
+		if _, _, err := m.issue(rec, httptest.NewRequest(http.MethodPost, "/", nil)); err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+		return rec.Result().Cookies()[0]
+	}
+	valid := func(m *sessionManager, c *http.Cookie) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(c)
+		_, _, ok := m.token(req)
+		return ok
+	}
+	logout := func(c *http.Cookie, all bool) {
+		m := newSessionManager(secret, sessions)
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.AddCookie(c)
+		revoke := m.revoke
+		if all {
+			revoke = m.revokeAll
+		}
+		if err := revoke(httptest.NewRecorder(), req); err != nil {
+			t.Fatalf("logout: %v", err)
+		}
+		if valid(m, c) {
+			t.Error("session valid right after logout")
+		}
+	}
+
+	m := newSessionManager(secret, sessions)
+	revoked, kept := login(m), login(m)
+	logout(revoked, false)
+
+	// A new manager with the same secret is a restart
+	m = newSessionManager(secret, sessions)
+	if valid(m, revoked) {
+		t.Error("revoked session valid after a restart")
+	}
+	if !valid(m, kept) {
+		t.Error("other session invalid after a restart")
+	}
+
+	logout(kept, true)
+	m = newSessionManager(secret, sessions)
+	if valid(m, kept) {
+		t.Error("session valid after logging out everywhere and a restart")
+	}
+	if !valid(m, login(m)) {
+		t.Error("new session invalid after logging out everywhere")
+	}
+}
+
+func TestSessionEndsOnPasswordChange(t *testing.T) {
+	dir := t.TempDir()
+	sessions := &app.SessionsService{Store: &memSessionStore{revoked: make(map[string]time.Time)}}
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithBasicAuth("user", "pass"),
+		WithSSESecret([]byte("0123456789abcdef0123456789abcdef")),
+		WithSessionsUsecase(sessions),
+		WithConfigUsecase(app.ConfigService{
+			ConfigPath:  filepath.Join(dir, "config.json"),
+			SecretsPath: filepath.Join(dir, "secrets.json"),
+			Sessions:    sessions,
+		}))
+
+	do := func(method, target, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		return rec
+	}
+	login := func() *http.Cookie {
+		rec := do(http.MethodPost, "/api/v1/auth/login", `{"username":"user","password":"pass"}`, nil)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == SessionCookieName {
+				return c
+			}
+		}
+		t.Fatalf("login: status %d, no session cookie", rec.Code)
+		return nil
+	}
+
+	old, other := login(), login()
+	if rec := do(http.MethodPut, "/api/v1/config", `{"basic_auth_password":"new-pass"}`, old); rec.Code != http.StatusOK {
+		t.Fatalf("change password: status %d: %s", rec.Code, rec.Body)
+	}
+	// No restart: the running server drops the sessions at once
+	for name, c := range map[string]*http.Cookie{"changing": old, "other": other} {
+		if rec := do(http.MethodGet, "/api/v1/config", "", c); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s session after the password change: status %d, want 401", name, rec.Code)
+		}
+	}
+	if rec := do(http.MethodGet, "/api/v1/config", "", login()); rec.Code != http.StatusOK {
+		t.Errorf("new session: status %d, want 200", rec.Code)
+	}
+}
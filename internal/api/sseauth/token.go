@@ -3,6 +3,7 @@ package sseauth
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -21,6 +22,9 @@ const (
 
 	// ScopeSSE is the scope claim for SSE tokens.
 	ScopeSSE = "sse"
+
+	// ScopeSession is the scope claim for web UI session cookies.
+	ScopeSession = "session"
 )
 
 // Errors returned by token validation.
@@ -36,21 +40,33 @@ type Claims struct {
 	Exp   int64  `json:"exp"`   // Expiration time (Unix timestamp)
 	Iat   int64  `json:"iat"`   // Issued at time (Unix timestamp)
 	Scope string `json:"scope"` // Token scope (e.g., "sse")
+	ID    string `json:"jti"`   // Random, so tokens issued together differ
 }
 
 // GenerateToken creates a new SSE token.
 // Format: sse1.<payload_b64>.<sig_b64>
-// Payload: {"exp":<unix>, "iat":<unix>, "scope":"sse"}
+// Payload: {"exp":<unix>, "iat":<unix>, "scope":"sse", "jti":<random>}
 // Signature: HMAC-SHA256(secret, "sse1."+payload_b64)
 func GenerateToken(secret []byte, scope string, now time.Time) (string, error) {
+	return GenerateTokenTTL(secret, scope, now, DefaultTTL)
+}
+
+// GenerateTokenTTL is GenerateToken for a token valid for ttl instead of
+// DefaultTTL.
+func GenerateTokenTTL(secret []byte, scope string, now time.Time, ttl time.Duration) (string, error) {
 	if len(secret) == 0 {
 		return "", errors.New("secret cannot be empty")
 	}
 
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generate token id: %w", err)
+	}
 	claims := Claims{
-		Exp:   now.Add(DefaultTTL).Unix(),
+		Exp:   now.Add(ttl).Unix(),
 		Iat:   now.Unix(),
 		Scope: scope,
+		ID:    base64.RawURLEncoding.EncodeToString(id),
 	}
 
 	payloadJSON, err := json.Marshal(claims)
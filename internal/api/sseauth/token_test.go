@@ -182,3 +182,43 @@ func TestTokenRoundTrip(t *testing.T) {
 		t.Errorf("expected ErrTokenExpired at T+6min, got %v", err)
 	}
 }
+
+// FuzzValidateToken checks that tokens from the query string of LAN
+// clients never panic the validator, fail only with the known errors, and
+// validate only when signed with the secret: a forged token would have to
+// carry the payload of the one genuine seed.
+func FuzzValidateToken(f *testing.F) {
+	secret := []byte("test-secret-32-bytes-long-key!!")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	token, err := GenerateToken(secret, ScopeSSE, now)
+	if err != nil {
+		f.Fatalf("GenerateToken failed: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	signed := parts[0] + "." + parts[1] + "."
+
+	f.Add(token)
+	f.Add(token[:len(token)-1])
+	f.Add(parts[0] + "." + parts[2] + "." + parts[1])
+	f.Add("sse1.e30.")
+	f.Add("sse1...")
+	f.Add("sse1")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, token string) {
+		claims, err := ValidateToken(token, secret, ScopeSSE, now)
+		switch err {
+		case nil:
+		case ErrInvalidFormat, ErrInvalidSignature, ErrTokenExpired, ErrInvalidScope:
+			return
+		default:
+			t.Fatalf("ValidateToken(%q) unexpected error: %v", token, err)
+		}
+		if !strings.HasPrefix(token, signed) {
+			t.Fatalf("ValidateToken accepted a token not signed with the secret: %q", token)
+		}
+		if claims.Scope != ScopeSSE || claims.Exp < now.Unix() {
+			t.Errorf("ValidateToken(%q) claims = %+v", token, claims)
+		}
+	})
+}
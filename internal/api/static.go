@@ -1,47 +1,200 @@
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
 	"io/fs"
 	"net/http"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 )
 
+// Cache-Control values for the web UI.
+const (
+	// cacheImmutable is for fingerprinted assets: their name changes with
+	// their content, so browsers never need to revalidate them.
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate is for everything else, index.html in particular, so
+	// that a new build is picked up on the next load. The ETag keeps
+	// revalidation cheap.
+	cacheRevalidate = "no-cache"
+)
+
+// fingerprinted matches the content-hashed file names Vite gives build
+// output under assets/, e.g. assets/index-C78g5we9.js.
+var fingerprinted = regexp.MustCompile(`^assets/.+-[A-Za-z0-9_-]{8,}\.[a-z0-9]+$`)
+
+// compressible lists the extensions worth compressing; images and fonts
+// already are.
+var compressible = map[string]bool{
+	".html": true, ".js": true, ".css": true, ".svg": true, ".json": true,
+	".map": true, ".txt": true, ".xml": true, ".webmanifest": true,
+}
+
+// scriptTag matches the start of a script element in a page.
+var scriptTag = regexp.MustCompile(`(?i)<script\b[^>]*>`)
+
+// scriptSrc matches the src attribute in a script start tag.
+var scriptSrc = regexp.MustCompile(`(?i)\ssrc\s*=`)
+
+// hasInlineScript reports whether a page has a script element without a
+// src, which the Content Security Policy allows only with a nonce.
+func hasInlineScript(page []byte) bool {
+	for _, tag := range scriptTag.FindAll(page, -1) {
+		if !scriptSrc.Match(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// staticAsset is a file of the web UI held in memory with its precomputed
+// encodings.
+type staticAsset struct {
+	data []byte
+	etag string // strong ETag of the identity encoding
+	br   []byte // from a .br file next to the asset, if the build made one
+	gz   []byte // from a .gz file next to the asset, or compressed at startup
+
+	inlineScript bool // an HTML page with inline scripts, see serveWithNonce
+}
+
 // spaHandler serves static files from an embedded filesystem.
 // For paths not found, it returns index.html to support SPA routing.
+// Files are read once at startup; fingerprinted assets are cached by
+// browsers for good, and compressed variants are served to clients that
+// accept them.
 type spaHandler struct {
-	staticFS http.Handler
-	indexFS  fs.FS
+	assets map[string]*staticAsset
 }
 
 func newSPAHandler(webFS fs.FS) (*spaHandler, error) {
-	return &spaHandler{
-		staticFS: http.FileServer(http.FS(webFS)),
-		indexFS:  webFS,
-	}, nil
+	h := &spaHandler{assets: make(map[string]*staticAsset)}
+	err := fs.WalkDir(webFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// Compressed variants are attached to their asset below
+		if strings.HasSuffix(name, ".br") || strings.HasSuffix(name, ".gz") {
+			return nil
+		}
+		data, err := fs.ReadFile(webFS, name)
+		if err != nil {
+			return err
+		}
+		a := &staticAsset{data: data, etag: strongETag(data)}
+		a.inlineScript = path.Ext(name) == ".html" && hasInlineScript(data)
+		if compressible[path.Ext(name)] {
+			a.br, _ = fs.ReadFile(webFS, name+".br")
+			if a.gz, err = fs.ReadFile(webFS, name+".gz"); err != nil {
+				// Tiny files can grow when compressed
+				if gz := gzipBytes(data); len(gz) < len(data) {
+					a.gz = gz
+				}
+			}
+		}
+		h.assets[name] = a
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
 }
 
 func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Try to serve the requested file
-	path := strings.TrimPrefix(r.URL.Path, "/")
-	if path == "" {
-		path = "index.html"
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" {
+		name = "index.html"
 	}
 
-	// Check if file exists
-	f, err := h.indexFS.Open(path)
-	if err == nil {
-		f.Close()
-		h.staticFS.ServeHTTP(w, r)
-		return
+	a, ok := h.assets[name]
+	if !ok {
+		// A missing build asset is a stale page asking for an old build;
+		// index.html would only fail to parse as a script
+		if strings.HasPrefix(name, "assets/") {
+			writeError(w, http.StatusNotFound, "Not Found", nil)
+			return
+		}
+
+		// File not found - serve index.html for SPA routing
+		if a, ok = h.assets["index.html"]; !ok {
+			writeError(w, http.StatusNotFound, "Not Found", nil)
+			return
+		}
+		name = "index.html"
 	}
 
-	// File not found - serve index.html for SPA routing
-	indexContent, err := fs.ReadFile(h.indexFS, "index.html")
-	if err != nil {
-		writeError(w, http.StatusNotFound, "Not Found", nil)
-		return
+	if fingerprinted.MatchString(name) {
+		w.Header().Set("Cache-Control", cacheImmutable)
+	} else {
+		w.Header().Set("Cache-Control", cacheRevalidate)
+	}
+	if a.inlineScript {
+		if nonce := setCSPNonce(w, r); nonce != "" {
+			h.serveWithNonce(w, r, name, a, nonce)
+			return
+		}
 	}
+	h.serve(w, r, name, a)
+}
+
+// serveWithNonce writes a page with nonce added to its script elements, so
+// a custom web UI can have inline scripts. The page differs per response,
+// so it has no ETag: a page revalidated from the cache would carry an old
+// nonce. Pages are small, so it is not compressed either.
+func (h *spaHandler) serveWithNonce(w http.ResponseWriter, r *http.Request, name string, a *staticAsset, nonce string) {
+	page := scriptTag.ReplaceAllFunc(a.data, func(tag []byte) []byte {
+		return append([]byte(`<script nonce="`+nonce+`"`), tag[len("<script"):]...)
+	})
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(page))
+}
+
+// serve writes an asset in the best encoding the client accepts. Each
+// encoding has its own ETag, as the bytes differ.
+func (h *spaHandler) serve(w http.ResponseWriter, r *http.Request, name string, a *staticAsset) {
+	data, etag := a.data, a.etag
+	if a.br != nil || a.gz != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		accept := r.Header.Get("Accept-Encoding")
+		switch {
+		case a.br != nil && acceptsEncoding(accept, "br"):
+			data, etag = a.br, strings.TrimSuffix(etag, `"`)+`-br"`
+			w.Header().Set("Content-Encoding", "br")
+		case a.gz != nil && acceptsEncoding(accept, "gzip"):
+			data, etag = a.gz, strings.TrimSuffix(etag, `"`)+`-gz"`
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+	}
+	w.Header().Set("ETag", etag)
+	// ServeContent derives the Content-Type from name and answers
+	// If-None-Match with 304
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header lists coding
+// without a zero q-value.
+func acceptsEncoding(header, coding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		c, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(c), coding) {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.Write(indexContent)
+// gzipBytes compresses data at the best compression level; it runs once
+// per asset at startup.
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
 }
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSPAHandler(t *testing.T) {
+	index := "<!doctype html><title>VRClog</title>" + strings.Repeat("<!-- padding -->", 20)
+	script := "console.log('hello');" + strings.Repeat("\n// padding", 20)
+	h, err := newSPAHandler(fstest.MapFS{
+		"index.html":                  {Data: []byte(index)},
+		"assets/index-C78g5we9.js":    {Data: []byte(script)},
+		"assets/index-C78g5we9.js.br": {Data: []byte("brotli bytes")},
+		"favicon.ico":                 {Data: []byte{0, 0, 1, 0}},
+	})
+	if err != nil {
+		t.Fatalf("newSPAHandler: %v", err)
+	}
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Fingerprinted assets are cached for good, in the best encoding accepted
+	rec := get("/assets/index-C78g5we9.js", http.Header{"Accept-Encoding": {"gzip, br"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != cacheImmutable {
+		t.Errorf("asset: status %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli bytes" {
+		t.Errorf("asset with br: encoding %q, body %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("asset Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	// index.html revalidates, and is gzipped on the fly at startup
+	rec = get("/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Cache-Control") != cacheRevalidate || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("index: Cache-Control %q, encoding %q", rec.Header().Get("Cache-Control"), rec.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if string(body) != index {
+		t.Errorf("gunzipped index = %q", body)
+	}
+
+	// A matching ETag gets 304
+	etag := get("/", nil).Header().Get("ETag")
+	if rec := get("/", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status %d, want 304", rec.Code)
+	}
+	if gzEtag := get("/", http.Header{"Accept-Encoding": {"gzip"}}).Header().Get("ETag"); gzEtag == etag {
+		t.Errorf("gzip and identity share ETag %s", etag)
+	}
+
+	// SPA routes fall back to index.html; missing build assets do not
+	if rec := get("/players/usr_a", nil); rec.Code != http.StatusOK || rec.Body.String() != index {
+		t.Errorf("SPA route: status %d", rec.Code)
+	}
+	if rec := get("/assets/index-OLDHASH1.js", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("stale asset: status %d, want 404", rec.Code)
+	}
+
+	// Not compressible: served as is without Vary
+	if rec := get("/favicon.ico", http.Header{"Accept-Encoding": {"gzip"}}); rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("favicon: encoding %q, Vary %q", rec.Header().Get("Content-Encoding"), rec.Header().Get("Vary"))
+	}
+}
+
+func TestSPAHandler_InlineScriptNonce(t *testing.T) {
+	index := `<script type="module" src="/assets/index-C78g5we9.js"></script><SCRIPT>window.widget = 1</SCRIPT>`
+	spa, err := newSPAHandler(fstest.MapFS{
+		"index.html": {Data: []byte(index)},
+		"plain.html": {Data: []byte(`<script src="/app.js"></script>`)},
+	})
+	if err != nil {
+		t.Fatalf("newSPAHandler: %v", err)
+	}
+	handler := securityHeadersMiddleware(nil)(spa)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/")
+	csp := rec.Header().Get("Content-Security-Policy")
+	_, rest, ok := strings.Cut(csp, "'nonce-")
+	nonce, _, _ := strings.Cut(rest, "'")
+	if !ok || nonce == "" {
+		t.Fatalf("CSP %q has no nonce", csp)
+	}
+	want := `<script nonce="` + nonce + `" type="module" src="/assets/index-C78g5we9.js"></script><script nonce="` + nonce + `">window.widget = 1</SCRIPT>`
+	if rec.Body.String() != want {
+		t.Errorf("page = %q, want %q", rec.Body.String(), want)
+	}
+	if rec.Header().Get("ETag") != "" || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("page with a nonce: ETag %q, encoding %q", rec.Header().Get("ETag"), rec.Header().Get("Content-Encoding"))
+	}
+	if again := get("/"); strings.Contains(again.Body.String(), nonce) {
+		t.Error("nonce reused in the next response")
+	}
+
+	// Pages without inline scripts are served as built, with no nonce
+	rec = get("/plain.html")
+	if strings.Contains(rec.Header().Get("Content-Security-Policy"), "nonce") || rec.Header().Get("ETag") == "" {
+		t.Errorf("plain page: CSP %q, ETag %q", rec.Header().Get("Content-Security-Policy"), rec.Header().Get("ETag"))
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"gzip, deflate, br", true},
+		{"GZIP", true},
+		{"br;q=1.0, gzip;q=0.5", true},
+		{"gzip;q=0", false},
+		{"deflate", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.header, "gzip"); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, gzip) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
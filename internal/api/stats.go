@@ -1,17 +1,29 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
 // handleStats handles GET /api/v1/stats/basic requests.
+// All stats endpoints accept an optional account to restrict the counts.
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	if s.stats == nil {
 		writeError(w, http.StatusServiceUnavailable, "stats not available", nil)
 		return
 	}
 
-	result, err := s.stats.GetBasicStats(r.Context())
+	if s.statsNotModified(w, r) {
+		return
+	}
+
+	result, err := s.stats.GetBasicStats(r.Context(), r.URL.Query().Get("account"))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "internal error", err)
 		return
@@ -19,3 +31,205 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, result)
 }
+
+// handleInstanceStats handles GET /api/v1/stats/instances requests.
+// Accepts optional since/until (RFC3339); defaults to today (local time).
+func (s *Server) handleInstanceStats(w http.ResponseWriter, r *http.Request) {
+	if s.stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "stats not available", nil)
+		return
+	}
+
+	since, until, err := parseStatsRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if s.statsNotModified(w, r) {
+		return
+	}
+
+	result, err := s.stats.GetInstanceStats(r.Context(), since, until, r.URL.Query().Get("account"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// copresenceResponse is the response body for GET /api/v1/stats/copresence.
+type copresenceResponse struct {
+	Players []store.CopresenceEntry `json:"players"`
+}
+
+// handleCopresenceStats handles GET /api/v1/stats/copresence requests.
+// Accepts optional since/until (RFC3339, default today) and limit (top N).
+func (s *Server) handleCopresenceStats(w http.ResponseWriter, r *http.Request) {
+	if s.stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "stats not available", nil)
+		return
+	}
+
+	since, until, err := parseStatsRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	limit := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+	}
+
+	players, err := s.stats.GetCopresence(r.Context(), since, until, r.URL.Query().Get("account"), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	if players == nil {
+		players = []store.CopresenceEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, copresenceResponse{Players: players})
+}
+
+// heatmapDefaultDays is the range covered by the heatmap when since is omitted.
+const heatmapDefaultDays = 28
+
+// handleHeatmapStats handles GET /api/v1/stats/heatmap requests.
+// Accepts optional since/until (RFC3339, default the last four weeks ending
+// today) and type to count a single event type.
+func (s *Server) handleHeatmapStats(w http.ResponseWriter, r *http.Request) {
+	if s.stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "stats not available", nil)
+		return
+	}
+
+	_, today := store.GetTodayBoundary()
+	since, until, err := parseRange(r, today.AddDate(0, 0, -heatmapDefaultDays), today)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	typ := r.URL.Query().Get("type")
+	if typ != "" && !event.IsValidType(typ) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid type: %s", typ), nil)
+		return
+	}
+
+	if s.statsNotModified(w, r) {
+		return
+	}
+
+	result, err := s.stats.GetHeatmap(r.Context(), since, until, r.URL.Query().Get("account"), typ)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleHostingStats handles GET /api/v1/stats/hosting requests.
+// Accepts optional since/until (RFC3339, default all time up to the end of
+// today), user_id (usr_ ID of the user, default guessed) and limit (top N
+// worlds and hosts).
+func (s *Server) handleHostingStats(w http.ResponseWriter, r *http.Request) {
+	if s.stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "stats not available", nil)
+		return
+	}
+
+	_, today := store.GetTodayBoundary()
+	since, until, err := parseRange(r, time.Unix(0, 0).UTC(), today)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	q := r.URL.Query()
+	userID := q.Get("user_id")
+	if userID != "" && !strings.HasPrefix(userID, "usr_") {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid user_id: %s", userID), nil)
+		return
+	}
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+	}
+
+	if s.statsNotModified(w, r) {
+		return
+	}
+
+	result, err := s.stats.GetHosting(r.Context(), since, until, q.Get("account"), userID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// statsNotModified sets Last-Modified on a stats response and reports
+// whether the client's copy, by If-Modified-Since, is still current, in
+// which case it has answered 304 Not Modified. This lets low-power clients
+// poll cheaply. The time is rounded up to the second, the resolution of
+// HTTP dates, so a change later in the same second is not missed.
+// Copresence stats are not conditional, as open sessions grow with time.
+func (s *Server) statsNotModified(w http.ResponseWriter, r *http.Request) bool {
+	modified, err := s.stats.LastModified(r.Context())
+	if err != nil {
+		return false // serve the stats, which report the error if it persists
+	}
+	if t := modified.Truncate(time.Second); !t.Equal(modified) {
+		modified = t.Add(time.Second)
+	}
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache")
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// parseStatsRange parses optional since/until query parameters (RFC3339).
+// Missing values default to the boundaries of today in local time.
+func parseStatsRange(r *http.Request) (since, until time.Time, err error) {
+	since, until = store.GetTodayBoundary()
+	return parseRange(r, since, until)
+}
+
+// parseRange parses optional since/until query parameters (RFC3339),
+// falling back to the given defaults.
+func parseRange(r *http.Request, defaultSince, defaultUntil time.Time) (since, until time.Time, err error) {
+	since, until = defaultSince, defaultUntil
+	q := r.URL.Query()
+
+	if v := q.Get("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			return since, until, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			return since, until, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if !until.After(since) {
+		return since, until, fmt.Errorf("invalid range: until must be after since")
+	}
+	return since, until, nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+// stubStatsService is a StatsUsecase serving the basic stats; other
+// methods panic.
+type stubStatsService struct {
+	app.StatsUsecase
+	modified time.Time
+	calls    int
+}
+
+func (s *stubStatsService) GetBasicStats(ctx context.Context, account string) (*app.StatsResult, error) {
+	s.calls++
+	return &app.StatsResult{}, nil
+}
+
+func (s *stubStatsService) LastModified(ctx context.Context) (time.Time, error) {
+	return s.modified, nil
+}
+
+func TestStatsEndpoint_IfModifiedSince(t *testing.T) {
+	modified := time.Date(2024, 6, 15, 20, 0, 0, 500_000_000, time.UTC)
+	stats := &stubStatsService{modified: modified}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithStatsUsecase(stats))
+
+	// Rounded up to the second, as HTTP dates have no fractions
+	wantLM := modified.Add(time.Second / 2).Format(http.TimeFormat)
+	for _, tt := range []struct {
+		ims  string
+		want int
+	}{
+		{"", http.StatusOK},
+		{modified.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{wantLM, http.StatusNotModified},
+		{"not a date", http.StatusOK},
+	} {
+		stats.calls = 0
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats/basic", nil)
+		if tt.ims != "" {
+			req.Header.Set("If-Modified-Since", tt.ims)
+		}
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("If-Modified-Since %q: status %d, want %d", tt.ims, rec.Code, tt.want)
+		}
+		if got := rec.Header().Get("Last-Modified"); got != wantLM {
+			t.Errorf("If-Modified-Since %q: Last-Modified = %q, want %q", tt.ims, got, wantLM)
+		}
+		if wantCalls := map[bool]int{true: 1, false: 0}[tt.want == http.StatusOK]; stats.calls != wantCalls {
+			t.Errorf("If-Modified-Since %q: stats computed %d times, want %d", tt.ims, stats.calls, wantCalls)
+		}
+	}
+}
@@ -1,20 +1,38 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/graaaaa/vrclog-companion/internal/derive"
 	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/msgpack"
 	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
 const (
-	// heartbeatInterval is the interval for sending SSE heartbeat comments.
-	heartbeatInterval = 20 * time.Second
+	// defaultHeartbeatInterval is the interval for sending SSE heartbeat
+	// comments, and min/maxHeartbeatInterval bound the intervals clients
+	// may ask for, unless set by WithSSEHeartbeat.
+	defaultHeartbeatInterval = 20 * time.Second
+	minHeartbeatInterval     = 5 * time.Second
+	maxHeartbeatInterval     = 2 * time.Minute
+
+	// ssePaddingSize is the size of the padding comments sent in padding
+	// mode, more than buffering proxies hold back before passing data on.
+	ssePaddingSize = 2048
+
+	// sseBatchInterval is how long live events are collected for one
+	// frame in batch mode, and sseMaxBatchSize the most events per frame.
+	sseBatchInterval = 100 * time.Millisecond
+	sseMaxBatchSize  = 100
 
 	// missedEventsPageSize is the number of events to fetch per page during replay.
 	missedEventsPageSize = 100
@@ -23,7 +41,97 @@ const (
 	missedEventsMaxPages = 5
 )
 
+// ssePadding is the padding comment of padding mode, and msgpackPadding
+// its MessagePack equivalent, a run of nils.
+var (
+	ssePadding     = ":" + strings.Repeat(" ", ssePaddingSize) + "\n\n"
+	msgpackPadding = bytes.Repeat([]byte{msgpack.Nil}, ssePaddingSize)
+)
+
+// sseOptions are the settings a client chooses for its SSE stream with
+// query parameters and, for MessagePack, the Accept header.
+//
+// In MessagePack mode the stream is a sequence of MessagePack values
+// instead of SSE text: a map of "id" (if any), "event" and "data" per
+// message, and nil for the connection comment, heartbeats and padding.
+type sseOptions struct {
+	heartbeat time.Duration // ?heartbeat=<seconds>, clamped to the server's bounds
+	padding   bool          // ?padding=true, for clients behind buffering proxies
+	batch     bool          // ?batch=true, events stream only: events coalesced into frames
+	msgpack   bool          // Accept: application/msgpack
+}
+
+// parseSSEOptions parses the SSE options of r.
+// Writes a 400 response and returns false if they are invalid.
+func (s *Server) parseSSEOptions(w http.ResponseWriter, r *http.Request) (sseOptions, bool) {
+	q := r.URL.Query()
+	o := sseOptions{heartbeat: s.heartbeat, msgpack: acceptsMsgpack(r)}
+	if v := q.Get("heartbeat"); v != "" {
+		sec, err := strconv.Atoi(v)
+		if err != nil || sec < 1 {
+			writeError(w, http.StatusBadRequest, "invalid heartbeat: "+v, nil)
+			return sseOptions{}, false
+		}
+		o.heartbeat = min(max(time.Duration(sec)*time.Second, s.minHeartbeat), s.maxHeartbeat)
+	}
+	if v := q.Get("padding"); v != "" {
+		padding, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid padding: "+v, nil)
+			return sseOptions{}, false
+		}
+		o.padding = padding
+	}
+	if v := q.Get("batch"); v != "" {
+		batch, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid batch: "+v, nil)
+			return sseOptions{}, false
+		}
+		o.batch = batch
+	}
+	return o, true
+}
+
+// writeConnected writes the comment establishing the connection, padded
+// in padding mode so that buffering proxies pass it on at once.
+func (o sseOptions) writeConnected(w http.ResponseWriter, flusher http.Flusher) {
+	switch {
+	case o.msgpack && o.padding:
+		w.Write(msgpackPadding)
+	case o.msgpack:
+		w.Write([]byte{msgpack.Nil})
+	case o.padding:
+		fmt.Fprint(w, ": connected\n\n"+ssePadding)
+	default:
+		fmt.Fprintf(w, ": connected\n\n")
+	}
+	flusher.Flush()
+}
+
+// writeHeartbeat writes a heartbeat comment to keep the connection alive.
+// In padding mode it is padded, so that buffering proxies also pass on
+// the events written since the last one.
+func (o sseOptions) writeHeartbeat(w http.ResponseWriter, flusher http.Flusher) {
+	switch {
+	case o.msgpack && o.padding:
+		w.Write(msgpackPadding)
+	case o.msgpack:
+		w.Write([]byte{msgpack.Nil})
+	case o.padding:
+		fmt.Fprint(w, ssePadding)
+	default:
+		fmt.Fprintf(w, ":\n\n")
+	}
+	flusher.Flush()
+}
+
 // handleStream handles GET /api/v1/stream (SSE)
+// With ?view=<name>, only events matching the saved view are sent.
+// ?heartbeat, ?padding and ?batch are described at sseOptions. In batch
+// mode, live events are sent every sseBatchInterval as one frame, see
+// sseOptions.writeBatch, which suits bursty replays and overlays rendering at a
+// fixed frame rate.
 func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	// Check for streaming support
 	flusher, ok := w.(http.Flusher)
@@ -31,12 +139,26 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "streaming not supported", nil)
 		return
 	}
+	opts, ok := s.parseSSEOptions(w, r)
+	if !ok {
+		return
+	}
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	var filter store.QueryFilter
+	view := r.URL.Query().Get("view")
+	if view != "" {
+		if s.views == nil {
+			writeError(w, http.StatusNotFound, "view not found", nil)
+			return
+		}
+		var err error
+		if filter, err = s.views.Filter(r.Context(), view); err != nil {
+			writeViewError(w, err)
+			return
+		}
+	}
+
+	setSSEHeaders(w, opts)
 
 	// Parse Last-Event-ID header or query parameter for reconnection support
 	// Query parameter allows manual reconnection with Last-Event-ID
@@ -45,27 +167,39 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 		lastEventID = r.URL.Query().Get("last_event_id")
 	}
 
+	// Subscribe before replaying, so that events stored while the replay
+	// runs are not missed; those that are also replayed are skipped below
+	sub := s.hub.Subscribe()
+	defer s.hub.Unsubscribe(sub)
+	client := s.streamClients.add(r, StreamEvents, view)
+	defer s.streamClients.remove(client)
+
 	// If Last-Event-ID is provided, send missed events (best-effort)
+	mask := s.eventMask(r)
+	var replayed map[int64]struct{}
 	if lastEventID != "" {
 		// Errors are ignored - invalid cursor or DB errors just skip replay
-		_ = s.sendMissedEvents(r.Context(), w, flusher, lastEventID)
+		replayed, _ = s.sendMissedEvents(r.Context(), w, flusher, lastEventID, filter, mask, opts)
 	}
 
-	// Subscribe to hub
-	sub := s.hub.Subscribe()
-	defer s.hub.Unsubscribe(sub)
-
 	// Send initial comment to establish connection
-	fmt.Fprintf(w, ": connected\n\n")
-	flusher.Flush()
+	opts.writeConnected(w, flusher)
 
 	// Create heartbeat ticker
-	ticker := time.NewTicker(heartbeatInterval)
+	ticker := time.NewTicker(opts.heartbeat)
 	defer ticker.Stop()
 
 	// Handle client disconnect
 	ctx := r.Context()
 
+	// Events waiting for the next frame in batch mode, sent when batchDue
+	// fires. Pending events are dropped on disconnect; the client resumes
+	// from the last frame's ID.
+	var (
+		pending  []*event.Event
+		batchDue <-chan time.Time
+	)
+
 	for {
 		select {
 		case e, ok := <-sub.Events():
@@ -73,19 +207,45 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 				// Channel closed, subscriber removed
 				return
 			}
+			if !filter.Matches(e) {
+				continue
+			}
+			if _, ok := replayed[e.ID]; ok {
+				delete(replayed, e.ID)
+				continue
+			}
+
+			if !opts.batch {
+				opts.writeEvent(w, mask.Apply(e))
+				flusher.Flush()
+				continue
+			}
+			pending = append(pending, mask.Apply(e))
+			if len(pending) >= sseMaxBatchSize {
+				opts.writeBatch(w, pending)
+				flusher.Flush()
+				pending, batchDue = pending[:0], nil
+			} else if batchDue == nil {
+				batchDue = time.After(sseBatchInterval)
+			}
 
-			writeSSEEvent(w, e)
+		case <-batchDue:
+			opts.writeBatch(w, pending)
 			flusher.Flush()
+			pending, batchDue = pending[:0], nil
 
 		case <-ticker.C:
 			// Send heartbeat comment to keep connection alive
-			fmt.Fprintf(w, ":\n\n")
-			flusher.Flush()
+			opts.writeHeartbeat(w, flusher)
 
 		case <-ctx.Done():
 			// Client disconnected
 			return
 
+		case <-client.kicked:
+			// Disconnected by an admin
+			return
+
 		case <-sub.Done():
 			// Subscriber removed (hub stopped)
 			return
@@ -93,32 +253,131 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleDerivedStream handles GET /api/v1/stream/derived (SSE).
+// Streams derived events (world changes with the previous world, joins and
+// leaves with the resulting player count). Derived events are not stored,
+// so there is no Last-Event-ID replay; clients should fetch /api/v1/now
+// after (re)connecting.
+func (s *Server) handleDerivedStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported", nil)
+		return
+	}
+	opts, ok := s.parseSSEOptions(w, r)
+	if !ok {
+		return
+	}
+
+	setSSEHeaders(w, opts)
+
+	sub := s.derivedHub.Subscribe()
+	defer s.derivedHub.Unsubscribe(sub)
+	client := s.streamClients.add(r, StreamDerived, "")
+	defer s.streamClients.remove(client)
+	mask := s.eventMask(r)
+
+	opts.writeConnected(w, flusher)
+
+	ticker := time.NewTicker(opts.heartbeat)
+	defer ticker.Stop()
+
+	ctx := r.Context()
+
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+
+			opts.writeDerivedEvent(w, mask.applyDerived(e))
+			flusher.Flush()
+
+		case <-ticker.C:
+			opts.writeHeartbeat(w, flusher)
+
+		case <-ctx.Done():
+			return
+
+		case <-client.kicked:
+			return
+
+		case <-sub.Done():
+			return
+		}
+	}
+}
+
+// setSSEHeaders sets the response headers for an SSE stream. In padding
+// mode, proxies are also asked not to transform the stream, as compressing
+// it would buffer it again.
+func setSSEHeaders(w http.ResponseWriter, opts sseOptions) {
+	if opts.msgpack {
+		w.Header().Set("Content-Type", msgpack.ContentType)
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	if opts.padding {
+		w.Header().Set("Cache-Control", "no-cache, no-transform")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+}
+
 // sendMissedEvents sends events that were missed during a reconnection.
-// Uses Last-Event-ID as a cursor for QueryEvents.
+// If the event of Last-Event-ID is still in the hub's history, the events
+// after it are replayed from there without touching SQLite; otherwise
+// Last-Event-ID is used as a cursor for QueryEvents.
 // Best-effort: invalid cursors or errors are silently ignored.
 // Limited to missedEventsMaxPages pages to prevent unbounded replay.
-func (s *Server) sendMissedEvents(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, lastEventID string) error {
-	cursor := lastEventID
-	filter := store.QueryFilter{
-		Cursor: &cursor,
-		Limit:  missedEventsPageSize,
-		Order:  store.QueryOrderAsc, // Fetch events after Last-Event-ID (forward in time)
+// Only events matching filter's conditions are replayed, with the fields
+// in mask removed, and written as opts says.
+// Returns the IDs of the events sent, so that the live stream can skip
+// them.
+func (s *Server) sendMissedEvents(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, lastEventID string, filter store.QueryFilter, mask EventMask, opts sseOptions) (map[int64]struct{}, error) {
+	if _, lastID, err := store.DecodeCursor(lastEventID); err == nil {
+		recent, ok := s.hub.Since(func(e *event.Event) bool { return e.ID == lastID })
+		if ok {
+			sent := make(map[int64]struct{}, len(recent))
+			var events []*event.Event
+			for _, e := range recent {
+				if filter.Matches(e) {
+					events = append(events, mask.Apply(e))
+					sent[e.ID] = struct{}{}
+				}
+			}
+			opts.writeEvents(w, events)
+			flusher.Flush()
+			return sent, nil
+		}
 	}
 
+	cursor := lastEventID
+	filter.Cursor = &cursor
+	filter.Limit = missedEventsPageSize
+	filter.Order = store.QueryOrderAsc // Fetch events after Last-Event-ID (forward in time)
+
+	sent := make(map[int64]struct{})
 	for page := 0; page < missedEventsMaxPages; page++ {
 		result, err := s.events.Query(ctx, filter)
 		if err != nil {
 			if errors.Is(err, store.ErrInvalidCursor) {
 				// Invalid cursor - skip replay and start fresh
-				return nil
+				return sent, nil
 			}
 			// Other errors (DB, context cancelled) - stop replay
-			return err
+			return sent, err
 		}
 
+		events := make([]*event.Event, len(result.Items))
 		for i := range result.Items {
-			writeSSEEvent(w, &result.Items[i])
+			events[i] = mask.Apply(&result.Items[i])
+			sent[result.Items[i].ID] = struct{}{}
 		}
+		opts.writeEvents(w, events)
 		flusher.Flush()
 
 		if result.NextCursor == nil {
@@ -127,19 +386,72 @@ func (s *Server) sendMissedEvents(ctx context.Context, w http.ResponseWriter, fl
 		filter.Cursor = result.NextCursor
 	}
 
-	return nil
+	return sent, nil
 }
 
-// writeSSEEvent writes a single event in SSE format.
-// Uses cursor-style ID (base64(ts|id)) for Last-Event-ID support.
-func writeSSEEvent(w http.ResponseWriter, e *event.Event) {
-	data, err := json.Marshal(e)
-	if err != nil {
+// writeFrame writes one message: an SSE event named name with v as JSON
+// data, and an id if not empty, or in MessagePack mode a map of the same
+// "id", "event" and "data".
+func (o sseOptions) writeFrame(w http.ResponseWriter, id, name string, v any) {
+	if o.msgpack {
+		frame := map[string]any{"event": name, "data": v}
+		if id != "" {
+			frame["id"] = id
+		}
+		data, err := msgpack.Marshal(frame)
+		if err != nil {
+			return
+		}
+		w.Write(data)
 		return
 	}
 
-	eventID := store.EncodeCursor(e.Ts, e.ID)
-	fmt.Fprintf(w, "id: %s\n", eventID)
-	fmt.Fprintf(w, "event: %s\n", e.Type)
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\n", name)
 	fmt.Fprintf(w, "data: %s\n\n", data)
 }
+
+// writeEvent writes a single event, named after its type.
+// Uses cursor-style ID (base64(ts|id)) for Last-Event-ID support.
+func (o sseOptions) writeEvent(w http.ResponseWriter, e *event.Event) {
+	o.writeFrame(w, store.EncodeCursor(e.Ts, e.ID), e.Type, e)
+}
+
+// writeEvents writes events one by one, or in batch mode in frames of
+// up to sseMaxBatchSize events.
+func (o sseOptions) writeEvents(w http.ResponseWriter, events []*event.Event) {
+	if !o.batch {
+		for _, e := range events {
+			o.writeEvent(w, e)
+		}
+		return
+	}
+	for len(events) > 0 {
+		n := min(len(events), sseMaxBatchSize)
+		o.writeBatch(w, events[:n])
+		events = events[n:]
+	}
+}
+
+// writeBatch writes events as one frame named "batch", with an array of
+// the events as data. Its ID is the cursor of the last event, so that
+// Last-Event-ID resumes after the whole frame.
+func (o sseOptions) writeBatch(w http.ResponseWriter, events []*event.Event) {
+	if len(events) == 0 {
+		return
+	}
+	last := events[len(events)-1]
+	o.writeFrame(w, store.EncodeCursor(last.Ts, last.ID), "batch", events)
+}
+
+// writeDerivedEvent writes a single derived event, named after its type
+// (e.g. "event: world_changed").
+func (o sseOptions) writeDerivedEvent(w http.ResponseWriter, e *derive.DerivedEvent) {
+	o.writeFrame(w, "", e.Type.String(), e)
+}
@@ -0,0 +1,430 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/msgpack"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+func TestDerivedStream_PublishesDerivedEvents(t *testing.T) {
+	derivedHub := NewDerivedHub()
+	go derivedHub.Run()
+	defer derivedHub.Stop()
+
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithDerivedHub(derivedHub))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/stream/derived")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+
+	// Wait for the connection comment so the subscriber is registered
+	if line := <-lines; line != ": connected" {
+		t.Fatalf("first line = %q, want connection comment", line)
+	}
+
+	derivedHub.Publish(&derive.DerivedEvent{
+		Type:        derive.DerivedPlayerJoined,
+		Event:       &event.Event{ID: 7, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice")},
+		PlayerCount: 3,
+	})
+
+	var got []string
+	timeout := time.After(2 * time.Second)
+	for len(got) < 2 {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("stream closed early, got %q", got)
+			}
+			if line != "" {
+				got = append(got, line)
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for event, got %q", got)
+		}
+	}
+
+	if got[0] != "event: player_joined" {
+		t.Errorf("event line = %q, want event: player_joined", got[0])
+	}
+	var payload struct {
+		Type        string      `json:"type"`
+		Event       event.Event `json:"event"`
+		PlayerCount int         `json:"player_count"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(got[1], "data: ")), &payload); err != nil {
+		t.Fatalf("decode data %q: %v", got[1], err)
+	}
+	if payload.Type != "player_joined" || payload.PlayerCount != 3 || payload.Event.ID != 7 {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+// stubViews implements app.ViewsUsecase with a single view.
+type stubViews struct {
+	app.ViewsUsecase
+	name   string
+	filter store.QueryFilter
+}
+
+func (s *stubViews) Filter(ctx context.Context, name string) (store.QueryFilter, error) {
+	if name != s.name {
+		return store.QueryFilter{}, store.ErrNotFound
+	}
+	return s.filter, nil
+}
+
+func TestStream_ViewFilter(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	views := &stubViews{name: "alice", filter: store.QueryFilter{Player: event.StringPtr("Alice")}}
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithHub(hub), WithEventsUsecase(&MockEventsService{}), WithViewsUsecase(views))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	// Unknown views are rejected before streaming starts
+	resp, err := http.Get(ts.URL + "/api/v1/stream?view=nobody")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown view: status = %d, want 404", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/v1/stream?view=alice")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+	if line := <-lines; line != ": connected" {
+		t.Fatalf("first line = %q, want connection comment", line)
+	}
+
+	hub.Publish(&event.Event{ID: 1, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Bob")})
+	hub.Publish(&event.Event{ID: 2, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice")})
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed early")
+			}
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			var e event.Event
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				t.Fatalf("decode data %q: %v", line, err)
+			}
+			if e.ID != 2 {
+				t.Errorf("first streamed event = %d, want 2 (Bob filtered out)", e.ID)
+			}
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestStream_ReplayFromHistory(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	sub := hub.Subscribe()
+	base := time.Date(2024, 6, 15, 20, 0, 0, 0, time.UTC)
+	for id := int64(1); id <= 3; id++ {
+		hub.Publish(&event.Event{ID: id, Ts: base.Add(time.Duration(id) * time.Second), Type: event.TypePlayerJoin})
+		<-sub.Events()
+	}
+	hub.Unsubscribe(sub)
+
+	var queries atomic.Int32
+	events := &MockEventsService{
+		QueryFunc: func(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+			queries.Add(1)
+			return store.QueryResult{}, nil
+		},
+	}
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithHub(hub), WithEventsUsecase(events))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	// replay returns the IDs sent before the connection comment
+	replay := func(lastID int64) []int64 {
+		cursor := store.EncodeCursor(base.Add(time.Duration(lastID)*time.Second), lastID)
+		resp, err := http.Get(ts.URL + "/api/v1/stream?last_event_id=" + cursor)
+		if err != nil {
+			t.Fatalf("GET: %v", err)
+		}
+		defer resp.Body.Close()
+		var ids []int64
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() && sc.Text() != ": connected" {
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				var e event.Event
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatalf("decode data %q: %v", data, err)
+				}
+				ids = append(ids, e.ID)
+			}
+		}
+		return ids
+	}
+
+	if ids := replay(1); len(ids) != 2 || ids[0] != 2 || ids[1] != 3 {
+		t.Errorf("replay after 1 = %v, want [2 3]", ids)
+	}
+	if n := queries.Load(); n != 0 {
+		t.Errorf("replay from history queried the store %d times", n)
+	}
+
+	// Events no longer in the history are replayed from the store
+	replay(99)
+	if n := queries.Load(); n != 1 {
+		t.Errorf("replay of an unknown event queried the store %d times, want 1", n)
+	}
+}
+
+func TestParseSSEOptions(t *testing.T) {
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithSSEHeartbeat(20*time.Second, 10*time.Second, time.Minute))
+
+	tests := []struct {
+		query     string
+		heartbeat time.Duration
+		padding   bool
+		ok        bool
+	}{
+		{"", 20 * time.Second, false, true},
+		{"?heartbeat=15&padding=true", 15 * time.Second, true, true},
+		{"?heartbeat=1", 10 * time.Second, false, true},
+		{"?heartbeat=3600", time.Minute, false, true},
+		{"?heartbeat=0", 0, false, false},
+		{"?heartbeat=fast", 0, false, false},
+		{"?padding=maybe", 0, false, false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		o, ok := server.parseSSEOptions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream"+tt.query, nil))
+		if ok != tt.ok || (ok && (o.heartbeat != tt.heartbeat || o.padding != tt.padding)) {
+			t.Errorf("parseSSEOptions(%q) = %+v, %v, want heartbeat %v padding %v, %v", tt.query, o, ok, tt.heartbeat, tt.padding, tt.ok)
+		}
+		if !ok && rec.Code != http.StatusBadRequest {
+			t.Errorf("parseSSEOptions(%q): status %d, want 400", tt.query, rec.Code)
+		}
+	}
+}
+
+func TestDerivedStream_Padding(t *testing.T) {
+	derivedHub := NewDerivedHub()
+	go derivedHub.Run()
+	defer derivedHub.Stop()
+
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithDerivedHub(derivedHub))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/stream/derived?padding=true")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-cache, no-transform" {
+		t.Errorf("Cache-Control = %q, want no-transform in padding mode", cc)
+	}
+
+	r := bufio.NewReader(resp.Body)
+	for _, want := range []string{": connected\n", "\n", ":" + strings.Repeat(" ", ssePaddingSize) + "\n", "\n"} {
+		if line, err := r.ReadString('\n'); err != nil || line != want {
+			t.Fatalf("line = %.20q (%d bytes), %v, want %.20q (%d bytes)", line, len(line), err, want, len(want))
+		}
+	}
+}
+
+func TestStream_Batch(t *testing.T) {
+	hub := NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	base := time.Date(2024, 6, 15, 20, 0, 0, 0, time.UTC)
+	newEvent := func(id int64) *event.Event {
+		return &event.Event{ID: id, Ts: base.Add(time.Duration(id) * time.Second), Type: event.TypePlayerJoin}
+	}
+	sub := hub.Subscribe()
+	for id := int64(1); id <= 3; id++ {
+		hub.Publish(newEvent(id))
+		<-sub.Events()
+	}
+	hub.Unsubscribe(sub)
+
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithHub(hub), WithEventsUsecase(&MockEventsService{}))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/stream?batch=true&last_event_id=" + store.EncodeCursor(base.Add(time.Second), 1))
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+
+	type frame struct {
+		id, name string
+		ids      []int64
+	}
+	frames := make(chan frame)
+	go func() {
+		var f frame
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == ": connected":
+				frames <- frame{name: "connected"}
+			case strings.HasPrefix(line, "id: "):
+				f.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				f.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				var events []event.Event
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &events); err != nil {
+					t.Errorf("data %q is not an array of events: %v", line, err)
+				}
+				for _, e := range events {
+					f.ids = append(f.ids, e.ID)
+				}
+				frames <- f
+				f = frame{}
+			}
+		}
+		close(frames)
+	}()
+	next := func() frame {
+		select {
+		case f := <-frames:
+			return f
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for a frame")
+			return frame{}
+		}
+	}
+
+	// The replay from the hub's history is one frame, resumable after its last event
+	if f := next(); f.name != "batch" || len(f.ids) != 2 || f.ids[0] != 2 || f.ids[1] != 3 ||
+		f.id != store.EncodeCursor(base.Add(3*time.Second), 3) {
+		t.Errorf("replay frame = %+v, want batch of [2 3] with the cursor of 3", f)
+	}
+	if f := next(); f.name != "connected" {
+		t.Fatalf("frame after replay = %+v, want connection comment", f)
+	}
+
+	// Live events published together arrive together
+	for id := int64(4); id <= 6; id++ {
+		hub.Publish(newEvent(id))
+	}
+	var got []int64
+	for len(got) < 3 {
+		f := next()
+		if f.name != "batch" {
+			t.Fatalf("live frame = %+v, want batch", f)
+		}
+		got = append(got, f.ids...)
+	}
+	if got[0] != 4 || got[1] != 5 || got[2] != 6 {
+		t.Errorf("live events = %v, want [4 5 6]", got)
+	}
+}
+
+func TestDerivedStream_Msgpack(t *testing.T) {
+	derivedHub := NewDerivedHub()
+	go derivedHub.Run()
+	defer derivedHub.Stop()
+
+	server := NewServer(":8080", app.HealthService{Version: "test"}, WithDerivedHub(derivedHub))
+	ts := httptest.NewServer(server.mux)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/stream/derived", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != msgpack.ContentType {
+		t.Fatalf("Content-Type = %q, want %q", ct, msgpack.ContentType)
+	}
+
+	// The connection is established with a nil
+	r := bufio.NewReader(resp.Body)
+	if b, err := r.ReadByte(); err != nil || b != msgpack.Nil {
+		t.Fatalf("first byte = %#x, %v, want nil", b, err)
+	}
+
+	e := &derive.DerivedEvent{
+		Type:        derive.DerivedPlayerJoined,
+		Event:       &event.Event{ID: 7, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice")},
+		PlayerCount: 3,
+	}
+	derivedHub.Publish(e)
+
+	want, _ := msgpack.Marshal(map[string]any{"event": e.Type.String(), "data": e})
+	got := make([]byte, len(want))
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(r, got)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("frame = % x, %v, want % x", got, err, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for frame")
+	}
+}
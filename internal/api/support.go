@@ -0,0 +1,22 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// handleSupportBundle handles POST /api/v1/support/bundle, downloading a
+// zip of logs, config with secrets redacted, database and health
+// information to attach to a bug report.
+func (s *Server) handleSupportBundle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition",
+		`attachment; filename="vrclog-support-`+time.Now().Format("20060102-150405")+`.zip"`)
+
+	// Parts that fail are noted in the bundle, so an error means the
+	// client went away mid-download; too late for an error response
+	if err := s.support.WriteBundle(r.Context(), w); err != nil {
+		slog.Error("support bundle failed", "error", err)
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+)
+
+type stubSupportUsecase struct{}
+
+func (stubSupportUsecase) WriteBundle(ctx context.Context, w io.Writer) error {
+	_, err := io.WriteString(w, "PK zip")
+	return err
+}
+
+func TestSupportBundleEndpoint(t *testing.T) {
+	server := NewServer(":8080", app.HealthService{Version: "test"},
+		WithSupportUsecase(stubSupportUsecase{}),
+		WithBasicAuth("admin", "secret"),
+	)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/support/bundle", nil)
+	rec := httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without credentials, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/support/bundle", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="vrclog-support-`) {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if rec.Body.String() != "PK zip" {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/support/bundle", nil)
+	req.SetBasicAuth("admin", "secret")
+	rec = httptest.NewRecorder()
+	server.mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for GET, got %d", rec.Code)
+	}
+}
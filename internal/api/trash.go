@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// trashResponse is the response of GET /api/v1/trash.
+type trashResponse struct {
+	Items []app.TrashBatch `json:"items"`
+}
+
+// restoreRequest is the body of POST /api/v1/trash/restore.
+type restoreRequest struct {
+	BatchID string `json:"batch_id"`
+}
+
+// handleTrash handles GET /api/v1/trash, the deletions that can still be
+// undone.
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	batches, err := s.trash.ListTrash(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, trashResponse{Items: batches})
+}
+
+// handleTrashRestore handles POST /api/v1/trash/restore, undoing a
+// deletion.
+func (s *Server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+	var req restoreRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil || req.BatchID == "" {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	result, err := s.trash.RestoreTrash(r.Context(), req.BatchID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "trash batch not found", nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// viewRequest is the request body for POST /api/v1/views.
+type viewRequest struct {
+	Name   string           `json:"name"`
+	Filter store.ViewFilter `json:"filter"`
+}
+
+// viewsResponse is the response body for GET /api/v1/views.
+type viewsResponse struct {
+	Items []store.View `json:"items"`
+}
+
+// handleSaveView handles POST /api/v1/views.
+func (s *Server) handleSaveView(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var req viewRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body", nil)
+		return
+	}
+
+	v, err := s.views.Save(r.Context(), req.Name, req.Filter)
+	if err != nil {
+		if errors.Is(err, app.ErrInvalidView) {
+			writeError(w, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, v)
+}
+
+// handleListViews handles GET /api/v1/views.
+func (s *Server) handleListViews(w http.ResponseWriter, r *http.Request) {
+	items, err := s.views.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, viewsResponse{Items: items})
+}
+
+// handleGetView handles GET /api/v1/views/{name}.
+func (s *Server) handleGetView(w http.ResponseWriter, r *http.Request) {
+	v, err := s.views.Get(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeViewError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, v)
+}
+
+// handleDeleteView handles DELETE /api/v1/views/{name}.
+func (s *Server) handleDeleteView(w http.ResponseWriter, r *http.Request) {
+	if err := s.views.Delete(r.Context(), r.PathValue("name")); err != nil {
+		writeViewError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleViewEvents handles GET /api/v1/views/{name}/events.
+func (s *Server) handleViewEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 0
+	if l := q.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+	}
+	var cursor *string
+	if c := q.Get("cursor"); c != "" {
+		cursor = &c
+	}
+
+	result, err := s.views.Events(r.Context(), r.PathValue("name"), cursor, limit)
+	if err != nil {
+		writeViewError(w, err)
+		return
+	}
+
+	resp := eventsResponse{Items: result.Items, NextCursor: result.NextCursor}
+	if resp.Items == nil {
+		resp.Items = []event.Event{}
+	}
+	mask := s.eventMask(r)
+	for i := range resp.Items {
+		resp.Items[i] = *mask.Apply(&resp.Items[i])
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeViewError maps view use case errors to responses.
+func writeViewError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		writeError(w, http.StatusNotFound, "view not found", nil)
+	case errors.Is(err, store.ErrInvalidCursor):
+		writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	}
+}
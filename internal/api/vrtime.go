@@ -0,0 +1,15 @@
+package api
+
+import "net/http"
+
+// handleVRTime handles GET /api/v1/vrtime, the time spent in VRChat today
+// and this week against the budgets in the config.
+// Query: account.
+func (s *Server) handleVRTime(w http.ResponseWriter, r *http.Request) {
+	result, err := s.vrTime.Progress(r.Context(), r.URL.Query().Get("account"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// handleWorlds handles GET /api/v1/worlds, the worlds directory.
+// Query: search, sort (last_visited, visits or total_time), limit, cursor,
+// account.
+func (s *Server) handleWorlds(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.WorldFilter{
+		Search:  q.Get("search"),
+		Sort:    q.Get("sort"),
+		Account: q.Get("account"),
+	}
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", l), nil)
+			return
+		}
+		filter.Limit = limit
+	}
+	if c := q.Get("cursor"); c != "" {
+		filter.Cursor = &c
+	}
+
+	page, err := s.worlds.List(r.Context(), filter)
+	switch {
+	case errors.Is(err, app.ErrInvalidWorldFilter):
+		writeError(w, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, store.ErrInvalidCursor):
+		writeErrorCode(w, http.StatusBadRequest, ErrCodeInvalidCursor, "invalid cursor", nil, nil)
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "internal error", err)
+	default:
+		writeJSON(w, http.StatusOK, page)
+	}
+}
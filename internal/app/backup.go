@@ -0,0 +1,384 @@
+package app
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// Defaults for BackupService.
+const (
+	DefaultBackupTime = "03:00"
+	DefaultBackupKeep = 7
+)
+
+// Backup file names are backupFilePrefix + local timestamp + extension, so
+// they sort chronologically.
+const (
+	backupFilePrefix      = "vrclog-backup-"
+	backupTimestampFormat = "20060102-150405"
+)
+
+// BackupStore defines the store operations needed by BackupService.
+type BackupStore interface {
+	BackupTo(ctx context.Context, path string) error
+	ExportJSONL(ctx context.Context, w io.Writer) (int, error)
+}
+
+// BackupUploader copies a finished backup to remote storage and verifies
+// the copy.
+type BackupUploader interface {
+	Upload(ctx context.Context, name, path string) error
+}
+
+// BackupReporter reports the outcome of scheduled backups.
+type BackupReporter interface {
+	Status() BackupStatus
+}
+
+// BackupsUsecase lists the backups in the backup directory and opens them
+// for download.
+type BackupsUsecase interface {
+	ListBackups(ctx context.Context) ([]BackupFile, error)
+	// OpenBackup opens the named backup for reading, or returns
+	// store.ErrNotFound. The caller closes the file.
+	OpenBackup(ctx context.Context, name string) (*os.File, BackupFile, error)
+}
+
+// BackupFile is a backup in the backup directory. Backups are never
+// modified once written, so Name, Size and ModTime identify the content.
+type BackupFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modified_at"`
+}
+
+// BackupStatus describes the last backup attempt and the next scheduled one.
+type BackupStatus struct {
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastFile    string     `json:"last_file,omitempty"`
+	LastUpload  *time.Time `json:"last_upload,omitempty"` // last verified remote upload
+	LastError   string     `json:"last_error,omitempty"`  // empty if the last attempt succeeded
+	NextRun     *time.Time `json:"next_run,omitempty"`
+}
+
+// BackupService writes a compressed backup to Dir once a day at Time and
+// keeps the newest Keep backups. A backup is also made on startup if the
+// newest one in Dir is more than a day old, so days the app was not running
+// at Time are caught up. If Uploader is set, each backup is also uploaded;
+// retention of remote copies is left to the remote (e.g. bucket lifecycle
+// rules).
+type BackupService struct {
+	Store    BackupStore
+	Dir      string
+	Time     string         // local time of day, "HH:MM"; "" means DefaultBackupTime
+	Format   string         // config.BackupFormatSQLite (default) or config.BackupFormatJSONL
+	Keep     int            // 0 means DefaultBackupKeep
+	Uploader BackupUploader // nil means local backups only
+	Logger   *slog.Logger   // nil means slog.Default()
+
+	now func() time.Time // nil means time.Now
+
+	mu        sync.Mutex
+	status    BackupStatus
+	scheduled bool // Jobs was called
+}
+
+// Jobs returns the scheduled backup job, run daily at Time and on
+// startup if the newest backup is more than a day old. Returns no jobs,
+// disabling backups, if Time is invalid.
+func (s *BackupService) Jobs() []schedule.Job {
+	sched, err := s.dailySchedule()
+	if err != nil {
+		s.logger().Error("backups disabled", "error", err)
+		return nil
+	}
+	s.mu.Lock()
+	s.scheduled = true
+	s.mu.Unlock()
+	return []schedule.Job{{
+		Name:       "backup",
+		Schedule:   sched,
+		RunAtStart: s.catchUpNeeded(),
+		Run:        s.runOnce,
+	}}
+}
+
+// Status returns the outcome of the last backup and, once scheduled, when
+// the next one is due. Implements BackupReporter.
+func (s *BackupService) Status() BackupStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.status
+	if s.scheduled {
+		if next, err := s.nextRun(s.clock()); err == nil {
+			st.NextRun = &next
+		}
+	}
+	return st
+}
+
+// ListBackups returns the backups in Dir, newest first. Implements
+// BackupsUsecase.
+func (s *BackupService) ListBackups(ctx context.Context) ([]BackupFile, error) {
+	names, err := s.backups()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]BackupFile, 0, len(names))
+	for _, name := range slices.Backward(names) {
+		info, err := os.Stat(filepath.Join(s.Dir, name))
+		if err != nil {
+			// Pruned since the directory was read
+			continue
+		}
+		files = append(files, BackupFile{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// OpenBackup opens a backup in Dir by name. Only names of existing backups
+// are accepted, so the name cannot reach outside Dir. Implements
+// BackupsUsecase.
+func (s *BackupService) OpenBackup(ctx context.Context, name string) (*os.File, BackupFile, error) {
+	names, err := s.backups()
+	if err != nil {
+		return nil, BackupFile{}, err
+	}
+	if !slices.Contains(names, name) {
+		return nil, BackupFile{}, store.ErrNotFound
+	}
+	f, err := os.Open(filepath.Join(s.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, BackupFile{}, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, BackupFile{}, fmt.Errorf("open backup: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, BackupFile{}, fmt.Errorf("open backup: %w", err)
+	}
+	return f, BackupFile{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// runOnce makes a backup, uploads it if configured, and records and
+// returns the outcome. A failed upload is reported as an error even though
+// the local backup was written.
+func (s *BackupService) runOnce(ctx context.Context) error {
+	path, err := s.Backup(ctx)
+	if err == nil && s.Uploader != nil {
+		if err = s.Uploader.Upload(ctx, filepath.Base(path), path); err != nil {
+			err = fmt.Errorf("upload %s: %w", filepath.Base(path), err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path != "" {
+		now := s.clock()
+		s.status.LastSuccess = &now
+		s.status.LastFile = path
+		s.logger().Info("backup written", "path", path)
+		if err == nil && s.Uploader != nil {
+			s.status.LastUpload = &now
+			s.logger().Info("backup uploaded and verified", "path", path)
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		s.status.LastError = err.Error()
+		return err
+	}
+	s.status.LastError = ""
+	return nil
+}
+
+// Backup writes one backup now, removes old ones beyond Keep, and returns
+// the path of the new file.
+func (s *BackupService) Backup(ctx context.Context) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("create backup dir: %w", err)
+	}
+
+	ext := ".sqlite.gz"
+	if s.Format == config.BackupFormatJSONL {
+		ext = ".jsonl.gz"
+	}
+	name := backupFilePrefix + s.clock().Format(backupTimestampFormat) + ext
+	path := filepath.Join(s.Dir, name)
+	tmpPath := path + ".tmp"
+
+	if err := s.writeBackup(ctx, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("finish backup: %w", err)
+	}
+
+	if err := s.prune(); err != nil {
+		// The new backup is fine; old ones are removed next time
+		s.logger().Warn("failed to remove old backups", "error", err)
+	}
+	return path, nil
+}
+
+// writeBackup writes the gzip-compressed backup to path.
+func (s *BackupService) writeBackup(ctx context.Context, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create backup: %w", err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+
+	if s.Format == config.BackupFormatJSONL {
+		if _, err := s.Store.ExportJSONL(ctx, zw); err != nil {
+			return err
+		}
+	} else if err := s.copyDatabase(ctx, zw); err != nil {
+		return err
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compress backup: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync backup: %w", err)
+	}
+	return f.Close()
+}
+
+// copyDatabase snapshots the database into a temporary file and copies it
+// to w.
+func (s *BackupService) copyDatabase(ctx context.Context, w io.Writer) error {
+	tmpDir, err := os.MkdirTemp("", "vrclog-backup-")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "backup.sqlite")
+	if err := s.Store.BackupTo(ctx, dbPath); err != nil {
+		return err
+	}
+	db, err := os.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := io.Copy(w, db); err != nil {
+		return fmt.Errorf("compress backup: %w", err)
+	}
+	return nil
+}
+
+// prune removes the oldest backups beyond Keep.
+func (s *BackupService) prune() error {
+	names, err := s.backups()
+	if err != nil {
+		return err
+	}
+	keep := s.Keep
+	if keep <= 0 {
+		keep = DefaultBackupKeep
+	}
+	var errs []error
+	for len(names) > keep {
+		if err := os.Remove(filepath.Join(s.Dir, names[0])); err != nil {
+			errs = append(errs, err)
+		}
+		names = names[1:]
+	}
+	return errors.Join(errs...)
+}
+
+// backups returns the names of the backups in Dir, oldest first.
+func (s *BackupService) backups() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list backups: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, ".gz") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// catchUpNeeded reports whether the newest backup is missing or more than
+// a day old.
+func (s *BackupService) catchUpNeeded() bool {
+	names, err := s.backups()
+	if err != nil || len(names) == 0 {
+		return true
+	}
+	newest := names[len(names)-1]
+	stamp := strings.TrimPrefix(newest, backupFilePrefix)
+	if len(stamp) < len(backupTimestampFormat) {
+		return true
+	}
+	t, err := time.ParseInLocation(backupTimestampFormat, stamp[:len(backupTimestampFormat)], time.Local)
+	return err != nil || s.clock().Sub(t) > 24*time.Hour
+}
+
+// nextRun returns the next occurrence of Time after now, in local time.
+func (s *BackupService) nextRun(now time.Time) (time.Time, error) {
+	sched, err := s.dailySchedule()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(now), nil
+}
+
+// dailySchedule returns the schedule of backups, daily at Time.
+func (s *BackupService) dailySchedule() (schedule.Schedule, error) {
+	at := s.Time
+	if at == "" {
+		at = DefaultBackupTime
+	}
+	sched, err := schedule.Daily(at)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup time %q", at)
+	}
+	return sched, nil
+}
+
+func (s *BackupService) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func (s *BackupService) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
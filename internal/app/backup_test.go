@@ -0,0 +1,241 @@
+package app
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubBackupStore writes fixed contents instead of real backups.
+type stubBackupStore struct {
+	err error
+}
+
+func (s stubBackupStore) BackupTo(ctx context.Context, path string) error {
+	if s.err != nil {
+		return s.err
+	}
+	return os.WriteFile(path, []byte("sqlite"), 0o644)
+}
+
+func (s stubBackupStore) ExportJSONL(ctx context.Context, w io.Writer) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	_, err := io.WriteString(w, "{}\n")
+	return 1, err
+}
+
+// stubUploader records uploads and fails with err if set.
+type stubUploader struct {
+	names []string
+	err   error
+}
+
+func (u *stubUploader) Upload(ctx context.Context, name, path string) error {
+	u.names = append(u.names, name)
+	return u.err
+}
+
+// readGzip returns the decompressed contents of path.
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return string(b)
+}
+
+func TestBackupService_BackupAndRetention(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 15, 3, 0, 0, 0, time.Local)
+	svc := &BackupService{
+		Store: stubBackupStore{},
+		Dir:   dir,
+		Keep:  2,
+		now:   func() time.Time { return now },
+	}
+
+	var paths []string
+	for range 3 {
+		path, err := svc.Backup(ctx)
+		if err != nil {
+			t.Fatalf("Backup: %v", err)
+		}
+		paths = append(paths, path)
+		now = now.AddDate(0, 0, 1)
+	}
+
+	if want := filepath.Join(dir, "vrclog-backup-20240115-030000.sqlite.gz"); paths[0] != want {
+		t.Errorf("path = %q, want %q", paths[0], want)
+	}
+	if got := readGzip(t, paths[2]); got != "sqlite" {
+		t.Errorf("contents = %q", got)
+	}
+
+	names, err := svc.backups()
+	if err != nil {
+		t.Fatalf("backups: %v", err)
+	}
+	if len(names) != 2 || filepath.Join(dir, names[0]) != paths[1] {
+		t.Errorf("kept = %v, want the newest 2", names)
+	}
+}
+
+func TestBackupService_JSONL(t *testing.T) {
+	svc := &BackupService{
+		Store:  stubBackupStore{},
+		Dir:    t.TempDir(),
+		Format: config.BackupFormatJSONL,
+	}
+	path, err := svc.Backup(context.Background())
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if !strings.HasSuffix(path, ".jsonl.gz") {
+		t.Errorf("path = %q, want .jsonl.gz", path)
+	}
+	if got := readGzip(t, path); got != "{}\n" {
+		t.Errorf("contents = %q", got)
+	}
+}
+
+func TestBackupService_ListAndOpen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 15, 3, 0, 0, 0, time.Local)
+	svc := &BackupService{
+		Store: stubBackupStore{},
+		Dir:   dir,
+		now:   func() time.Time { return now },
+	}
+	for range 2 {
+		if _, err := svc.Backup(ctx); err != nil {
+			t.Fatalf("Backup: %v", err)
+		}
+		now = now.AddDate(0, 0, 1)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644)
+
+	files, err := svc.ListBackups(ctx)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "vrclog-backup-20240116-030000.sqlite.gz" || files[0].Size == 0 {
+		t.Fatalf("files = %+v, want the 2 backups newest first", files)
+	}
+
+	f, info, err := svc.OpenBackup(ctx, files[1].Name)
+	if err != nil {
+		t.Fatalf("OpenBackup: %v", err)
+	}
+	f.Close()
+	if info != files[1] {
+		t.Errorf("OpenBackup info = %+v, want %+v", info, files[1])
+	}
+
+	for _, name := range []string{"notes.txt", "../" + filepath.Base(dir) + "/" + files[0].Name, ""} {
+		if _, _, err := svc.OpenBackup(ctx, name); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("OpenBackup(%q): err = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestBackupService_FailureReportedInHealth(t *testing.T) {
+	dir := t.TempDir()
+	svc := &BackupService{Store: stubBackupStore{err: errors.New("disk full")}, Dir: dir}
+
+	svc.runOnce(context.Background())
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("failed backup left files: %v", entries)
+	}
+	result, _ := HealthService{Backup: svc}.Handle(context.Background())
+	if result.Status != StatusDegraded {
+		t.Errorf("status = %q, want degraded", result.Status)
+	}
+	if c := result.Components["backup"]; c.Status != StatusUnhealthy || !strings.Contains(c.Message, "disk full") {
+		t.Errorf("backup component = %+v", c)
+	}
+
+	svc.Store = stubBackupStore{}
+	svc.runOnce(context.Background())
+	result, _ = HealthService{Backup: svc}.Handle(context.Background())
+	if result.Status != StatusHealthy || result.Components["backup"].Status != StatusHealthy {
+		t.Errorf("after success: %+v", result)
+	}
+}
+
+func TestBackupService_Schedule(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local)
+	svc := &BackupService{Store: stubBackupStore{}, Dir: dir, Time: "03:30", now: func() time.Time { return now }}
+
+	next, err := svc.nextRun(now)
+	if err != nil {
+		t.Fatalf("nextRun: %v", err)
+	}
+	if want := time.Date(2024, 1, 16, 3, 30, 0, 0, time.Local); !next.Equal(want) {
+		t.Errorf("next = %v, want %v", next, want)
+	}
+
+	if !svc.catchUpNeeded() {
+		t.Error("catchUpNeeded with no backups = false")
+	}
+	if _, err := svc.Backup(context.Background()); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if svc.catchUpNeeded() {
+		t.Error("catchUpNeeded right after a backup = true")
+	}
+	now = now.Add(25 * time.Hour)
+	if !svc.catchUpNeeded() {
+		t.Error("catchUpNeeded a day later = false")
+	}
+}
+
+func TestBackupService_Upload(t *testing.T) {
+	uploader := &stubUploader{}
+	svc := &BackupService{Store: stubBackupStore{}, Dir: t.TempDir(), Uploader: uploader}
+
+	svc.runOnce(context.Background())
+	st := svc.Status()
+	if len(uploader.names) != 1 || uploader.names[0] != filepath.Base(st.LastFile) {
+		t.Errorf("uploaded %v, last file %q", uploader.names, st.LastFile)
+	}
+	if st.LastUpload == nil || st.LastError != "" {
+		t.Errorf("status = %+v, want uploaded", st)
+	}
+
+	// A failed upload keeps the local backup but is reported
+	uploader.err = errors.New("verify failed")
+	svc.runOnce(context.Background())
+	st = svc.Status()
+	if _, err := os.Stat(st.LastFile); err != nil {
+		t.Errorf("local backup missing: %v", err)
+	}
+	if !strings.Contains(st.LastError, "verify failed") {
+		t.Errorf("LastError = %q", st.LastError)
+	}
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// MaxBookmarkLabelLength is the maximum bookmark label length in characters.
+const MaxBookmarkLabelLength = 200
+
+var (
+	// ErrNotLaunchable is returned for events that do not identify an
+	// instance (anything but a world_join carrying world and instance IDs).
+	ErrNotLaunchable = errors.New("event has no instance to launch")
+
+	// ErrInvalidBookmark is returned when a bookmark fails validation.
+	ErrInvalidBookmark = errors.New("invalid bookmark")
+)
+
+// LaunchLink holds links that open an instance in VRChat.
+type LaunchLink struct {
+	WorldID    string `json:"world_id"`
+	InstanceID string `json:"instance_id"`
+	WorldName  string `json:"world_name,omitempty"`
+	LaunchURL  string `json:"launch_url"` // vrchat:// client link
+	WebURL     string `json:"web_url"`    // vrchat.com launch page
+}
+
+// Bookmark is a saved instance with its launch links.
+type Bookmark struct {
+	store.Bookmark
+	LaunchURL string `json:"launch_url"`
+	WebURL    string `json:"web_url"`
+}
+
+// BookmarksUsecase defines instance bookmarks and rejoin links.
+type BookmarksUsecase interface {
+	// LaunchLink returns links for the instance of a world_join event.
+	// Returns store.ErrNotFound or ErrNotLaunchable.
+	LaunchLink(ctx context.Context, eventID int64) (*LaunchLink, error)
+	// Add bookmarks the instance of a world_join event.
+	Add(ctx context.Context, eventID int64, label string) (*Bookmark, error)
+	List(ctx context.Context) ([]Bookmark, error)
+	// Delete removes a bookmark. Returns store.ErrNotFound if it does not exist.
+	Delete(ctx context.Context, id int64) error
+}
+
+// BookmarkStore defines store operations needed by BookmarksService.
+type BookmarkStore interface {
+	GetEvent(ctx context.Context, id int64) (*event.Event, error)
+	WorldNameFor(ctx context.Context, e *event.Event) (string, error)
+	SaveBookmark(ctx context.Context, b store.Bookmark) (*store.Bookmark, error)
+	ListBookmarks(ctx context.Context) ([]store.Bookmark, error)
+	DeleteBookmark(ctx context.Context, id int64) error
+}
+
+// BookmarksService implements BookmarksUsecase.
+type BookmarksService struct {
+	Store BookmarkStore
+}
+
+// LaunchLink builds launch links from a stored world_join event.
+func (s *BookmarksService) LaunchLink(ctx context.Context, eventID int64) (*LaunchLink, error) {
+	e, err := s.Store.GetEvent(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != event.TypeWorldJoin || e.WorldID == nil || e.InstanceID == nil {
+		return nil, ErrNotLaunchable
+	}
+
+	name, err := s.Store.WorldNameFor(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	return &LaunchLink{
+		WorldID:    *e.WorldID,
+		InstanceID: *e.InstanceID,
+		WorldName:  name,
+		LaunchURL:  instance.LaunchURL(*e.WorldID, *e.InstanceID),
+		WebURL:     instance.WebLaunchURL(*e.WorldID, *e.InstanceID),
+	}, nil
+}
+
+// Add bookmarks the instance of a world_join event.
+func (s *BookmarksService) Add(ctx context.Context, eventID int64, label string) (*Bookmark, error) {
+	label = strings.TrimSpace(label)
+	if utf8.RuneCountInString(label) > MaxBookmarkLabelLength {
+		return nil, fmt.Errorf("%w: label exceeds %d characters", ErrInvalidBookmark, MaxBookmarkLabelLength)
+	}
+
+	link, err := s.LaunchLink(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.Store.SaveBookmark(ctx, store.Bookmark{
+		WorldID:    link.WorldID,
+		InstanceID: link.InstanceID,
+		WorldName:  link.WorldName,
+		Label:      label,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withLinks(*b), nil
+}
+
+// List returns all bookmarks with launch links.
+func (s *BookmarksService) List(ctx context.Context) ([]Bookmark, error) {
+	stored, err := s.Store.ListBookmarks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Bookmark, 0, len(stored))
+	for _, b := range stored {
+		result = append(result, *withLinks(b))
+	}
+	return result, nil
+}
+
+// Delete removes a bookmark.
+func (s *BookmarksService) Delete(ctx context.Context, id int64) error {
+	return s.Store.DeleteBookmark(ctx, id)
+}
+
+func withLinks(b store.Bookmark) *Bookmark {
+	return &Bookmark{
+		Bookmark:  b,
+		LaunchURL: instance.LaunchURL(b.WorldID, b.InstanceID),
+		WebURL:    instance.WebLaunchURL(b.WorldID, b.InstanceID),
+	}
+}
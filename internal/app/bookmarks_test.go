@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubBookmarkStore is a test double for BookmarkStore.
+type stubBookmarkStore struct {
+	events map[int64]*event.Event
+	saved  []store.Bookmark
+}
+
+func (s *stubBookmarkStore) GetEvent(ctx context.Context, id int64) (*event.Event, error) {
+	e, ok := s.events[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *stubBookmarkStore) WorldNameFor(ctx context.Context, e *event.Event) (string, error) {
+	return "World A", nil
+}
+
+func (s *stubBookmarkStore) SaveBookmark(ctx context.Context, b store.Bookmark) (*store.Bookmark, error) {
+	b.ID = int64(len(s.saved) + 1)
+	s.saved = append(s.saved, b)
+	return &b, nil
+}
+
+func (s *stubBookmarkStore) ListBookmarks(ctx context.Context) ([]store.Bookmark, error) {
+	return s.saved, nil
+}
+
+func (s *stubBookmarkStore) DeleteBookmark(ctx context.Context, id int64) error {
+	return nil
+}
+
+func TestBookmarksService(t *testing.T) {
+	stub := &stubBookmarkStore{events: map[int64]*event.Event{
+		1: {ID: 1, Type: event.TypeWorldJoin, WorldID: event.StringPtr("wrld_a"), InstanceID: event.StringPtr("1~region(jp)")},
+		2: {ID: 2, Type: event.TypeWorldJoin, WorldName: event.StringPtr("World A")},
+		3: {ID: 3, Type: event.TypePlayerJoin},
+	}}
+	svc := &BookmarksService{Store: stub}
+	ctx := context.Background()
+
+	link, err := svc.LaunchLink(ctx, 1)
+	if err != nil {
+		t.Fatalf("LaunchLink: %v", err)
+	}
+	if link.LaunchURL != "vrchat://launch?ref=vrchat.com&id=wrld_a:1~region(jp)" || link.WorldName != "World A" {
+		t.Errorf("LaunchLink = %+v", link)
+	}
+
+	for _, id := range []int64{2, 3} {
+		if _, err := svc.LaunchLink(ctx, id); !errors.Is(err, ErrNotLaunchable) {
+			t.Errorf("LaunchLink(%d) error = %v, want ErrNotLaunchable", id, err)
+		}
+	}
+	if _, err := svc.LaunchLink(ctx, 9); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("LaunchLink(9) error = %v, want ErrNotFound", err)
+	}
+
+	b, err := svc.Add(ctx, 1, "  rooftop  ")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if b.Label != "rooftop" || b.WorldName != "World A" || b.LaunchURL == "" {
+		t.Errorf("Add = %+v", b)
+	}
+}
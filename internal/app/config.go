@@ -2,8 +2,11 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	"github.com/graaaaa/vrclog-companion/internal/config"
 )
@@ -16,33 +19,105 @@ type ConfigUsecase interface {
 	// UpdateConfig updates the configuration with the given changes.
 	// Returns the result indicating success and whether restart is required.
 	UpdateConfig(ctx context.Context, req ConfigUpdateRequest) (ConfigUpdateResponse, error)
+
+	// ValidateConfig checks the changes UpdateConfig would make without
+	// saving anything, reporting every problem rather than the first.
+	ValidateConfig(ctx context.Context, req ConfigUpdateRequest) ConfigValidationResult
+
+	// ExportSettings returns a bundle of the config for moving to another
+	// install. Secrets are included, encrypted, only if passphrase is set.
+	ExportSettings(ctx context.Context, passphrase string) (config.Bundle, error)
+
+	// ImportSettings replaces the config with the bundle's, and the secrets
+	// too if the bundle has them and passphrase is set. Returns an error
+	// wrapping config.ErrInvalidBundle or config.ErrWrongPassphrase if the
+	// bundle cannot be read.
+	ImportSettings(ctx context.Context, bundle config.Bundle, passphrase string) (SettingsImportResponse, error)
+
+	// GetUIConfig returns the branding of the web UI and overlays.
+	GetUIConfig(ctx context.Context) config.UIConfig
+}
+
+// SettingsImportResponse reports the result of ImportSettings.
+type SettingsImportResponse struct {
+	Success         bool `json:"success"`
+	RestartRequired bool `json:"restart_required"`
+	SecretsImported bool `json:"secrets_imported"`
 }
 
 // ConfigResponse represents the current configuration (excludes secret values).
 type ConfigResponse struct {
-	Port                     int    `json:"port"`
-	LanEnabled               bool   `json:"lan_enabled"`
-	DiscordBatchSec          int    `json:"discord_batch_sec"`
-	NotifyOnJoin             bool   `json:"notify_on_join"`
-	NotifyOnLeave            bool   `json:"notify_on_leave"`
-	NotifyOnWorldJoin        bool   `json:"notify_on_world_join"`
-	DiscordWebhookConfigured bool   `json:"discord_webhook_configured"`
-	LogPath                  string `json:"log_path"`
-	BasicAuthUsername        string `json:"basic_auth_username,omitempty"`
-	BasicAuthConfigured      bool   `json:"basic_auth_configured"`
+	Port                     int                 `json:"port"`
+	LanEnabled               bool                `json:"lan_enabled"`
+	DiscordBatchSec          int                 `json:"discord_batch_sec"`
+	NotifyOnJoin             bool                `json:"notify_on_join"`
+	NotifyOnLeave            bool                `json:"notify_on_leave"`
+	NotifyOnWorldJoin        bool                `json:"notify_on_world_join"`
+	NotifySessionRecap       bool                `json:"notify_session_recap"`
+	NotifyStartupGrace       int                 `json:"notify_startup_grace_sec"`
+	NotifyReturnAfter        int                 `json:"notify_return_after_days"`
+	NotifyMilestones         bool                `json:"notify_milestones"`
+	NotifyAuthLockout        bool                `json:"notify_on_auth_lockout"`
+	DiscordThreadID          string              `json:"discord_thread_id"`
+	DiscordUsername          string              `json:"discord_username"`
+	DiscordAvatarURL         string              `json:"discord_avatar_url"`
+	DiscordWebhookConfigured bool                `json:"discord_webhook_configured"`
+	DiscordBotConfigured     bool                `json:"discord_bot_configured"`
+	LogPath                  string              `json:"log_path"`
+	BasicAuthUsername        string              `json:"basic_auth_username,omitempty"`
+	BasicAuthConfigured      bool                `json:"basic_auth_configured"`
+	NotifyRules              []config.NotifyRule `json:"notify_rules"`
+	SoundHooks               []config.SoundHook  `json:"sound_hooks"`
+	PlayerTags               map[string][]string `json:"player_tags"`
+	FriendTags               []string            `json:"friend_tags"`
+	BackupDir                string              `json:"backup_dir"`
+	BackupTime               string              `json:"backup_time"`
+	BackupFormat             string              `json:"backup_format"`
+	BackupKeep               int                 `json:"backup_keep"`
+	VacuumIntervalDays       int                 `json:"vacuum_interval_days"`
+	SlowQueryMs              int                 `json:"slow_query_ms"`
+	UndoWindowHours          int                 `json:"undo_window_hours"`
+	RollupAfterDays          int                 `json:"rollup_after_days"`
+	MinFreeDiskMB            int                 `json:"min_free_disk_mb"`
+	UI                       config.UIConfig     `json:"ui"`
+	VRTime                   config.VRTimeConfig `json:"vr_time"`
 }
 
 // ConfigUpdateRequest contains optional fields for updating configuration.
 type ConfigUpdateRequest struct {
-	Port              *int    `json:"port,omitempty"`
-	LanEnabled        *bool   `json:"lan_enabled,omitempty"`
-	DiscordBatchSec   *int    `json:"discord_batch_sec,omitempty"`
-	NotifyOnJoin      *bool   `json:"notify_on_join,omitempty"`
-	NotifyOnLeave     *bool   `json:"notify_on_leave,omitempty"`
-	NotifyOnWorldJoin *bool   `json:"notify_on_world_join,omitempty"`
-	DiscordWebhookURL *string `json:"discord_webhook_url,omitempty"`
-	LogPath           *string `json:"log_path,omitempty"`
-	BasicAuthPassword *string `json:"basic_auth_password,omitempty"`
+	Port               *int                 `json:"port,omitempty"`
+	LanEnabled         *bool                `json:"lan_enabled,omitempty"`
+	DiscordBatchSec    *int                 `json:"discord_batch_sec,omitempty"`
+	NotifyOnJoin       *bool                `json:"notify_on_join,omitempty"`
+	NotifyOnLeave      *bool                `json:"notify_on_leave,omitempty"`
+	NotifyOnWorldJoin  *bool                `json:"notify_on_world_join,omitempty"`
+	NotifySessionRecap *bool                `json:"notify_session_recap,omitempty"`
+	NotifyStartupGrace *int                 `json:"notify_startup_grace_sec,omitempty"`
+	NotifyReturnAfter  *int                 `json:"notify_return_after_days,omitempty"`
+	NotifyMilestones   *bool                `json:"notify_milestones,omitempty"`
+	NotifyAuthLockout  *bool                `json:"notify_on_auth_lockout,omitempty"`
+	DiscordThreadID    *string              `json:"discord_thread_id,omitempty"`
+	DiscordUsername    *string              `json:"discord_username,omitempty"`
+	DiscordAvatarURL   *string              `json:"discord_avatar_url,omitempty"`
+	DiscordWebhookURL  *string              `json:"discord_webhook_url,omitempty"`
+	DiscordBotToken    *string              `json:"discord_bot_token,omitempty"`
+	LogPath            *string              `json:"log_path,omitempty"`
+	BasicAuthPassword  *string              `json:"basic_auth_password,omitempty"`
+	NotifyRules        *[]config.NotifyRule `json:"notify_rules,omitempty"`
+	SoundHooks         *[]config.SoundHook  `json:"sound_hooks,omitempty"`
+	PlayerTags         *map[string][]string `json:"player_tags,omitempty"`
+	FriendTags         *[]string            `json:"friend_tags,omitempty"`
+	BackupDir          *string              `json:"backup_dir,omitempty"`
+	BackupTime         *string              `json:"backup_time,omitempty"`
+	BackupFormat       *string              `json:"backup_format,omitempty"`
+	BackupKeep         *int                 `json:"backup_keep,omitempty"`
+	VacuumIntervalDays *int                 `json:"vacuum_interval_days,omitempty"`
+	SlowQueryMs        *int                 `json:"slow_query_ms,omitempty"`
+	UndoWindowHours    *int                 `json:"undo_window_hours,omitempty"`
+	RollupAfterDays    *int                 `json:"rollup_after_days,omitempty"`
+	MinFreeDiskMB      *int                 `json:"min_free_disk_mb,omitempty"`
+	UI                 *config.UIConfig     `json:"ui,omitempty"`
+	VRTime             *config.VRTimeConfig `json:"vr_time,omitempty"`
 }
 
 // ConfigUpdateResponse indicates the result of a configuration update.
@@ -52,10 +127,36 @@ type ConfigUpdateResponse struct {
 	NewPort         int  `json:"new_port,omitempty"`
 }
 
+// ConfigValidationResult reports the problems found by ValidateConfig.
+type ConfigValidationResult struct {
+	Valid  bool               `json:"valid"`
+	Errors []ConfigFieldError `json:"errors"`
+}
+
+// ConfigFieldError is a problem with one field of a ConfigUpdateRequest.
+type ConfigFieldError struct {
+	Field   string `json:"field"` // JSON name, e.g. "port" or "notify_rules[2]"
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e ConfigFieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
 // ConfigService implements ConfigUsecase.
 type ConfigService struct {
 	ConfigPath  string
 	SecretsPath string
+
+	// PortAvailable reports whether the server could listen on port.
+	// nil means checkPortAvailable.
+	PortAvailable func(port int) error
+
+	// Sessions, if set, ends every web UI session when the password
+	// changes. A server sharing the service rejects the old sessions from
+	// the next request.
+	Sessions SessionsUsecase
 }
 
 // GetConfig returns the current configuration.
@@ -70,10 +171,34 @@ func (s ConfigService) GetConfig(ctx context.Context) ConfigResponse {
 		NotifyOnJoin:             cfg.NotifyOnJoin,
 		NotifyOnLeave:            cfg.NotifyOnLeave,
 		NotifyOnWorldJoin:        cfg.NotifyOnWorldJoin,
+		NotifySessionRecap:       cfg.NotifySessionRecap,
+		NotifyStartupGrace:       cfg.NotifyStartupGrace,
+		NotifyReturnAfter:        cfg.NotifyReturnAfter,
+		NotifyMilestones:         cfg.NotifyMilestones,
+		NotifyAuthLockout:        cfg.NotifyAuthLockout,
+		DiscordThreadID:          cfg.DiscordThreadID,
+		DiscordUsername:          cfg.DiscordUsername,
+		DiscordAvatarURL:         cfg.DiscordAvatarURL,
 		DiscordWebhookConfigured: !sec.DiscordWebhookURL.IsEmpty(),
+		DiscordBotConfigured:     !sec.DiscordBotToken.IsEmpty(),
 		LogPath:                  cfg.LogPath,
 		BasicAuthUsername:        sec.BasicAuthUsername,
 		BasicAuthConfigured:      !sec.BasicAuthPassword.IsEmpty(),
+		NotifyRules:              notifyRulesOrEmpty(cfg.NotifyRules),
+		SoundHooks:               soundHooksOrEmpty(cfg.SoundHooks),
+		PlayerTags:               playerTagsOrEmpty(cfg.PlayerTags),
+		FriendTags:               friendTagsOrEmpty(cfg.FriendTags),
+		BackupDir:                cfg.BackupDir,
+		BackupTime:               cfg.BackupTime,
+		BackupFormat:             cfg.BackupFormat,
+		BackupKeep:               cfg.BackupKeep,
+		VacuumIntervalDays:       cfg.VacuumIntervalDays,
+		SlowQueryMs:              cfg.SlowQueryMs,
+		UndoWindowHours:          cfg.UndoWindowHours,
+		RollupAfterDays:          cfg.RollupAfterDays,
+		MinFreeDiskMB:            cfg.MinFreeDiskMB,
+		UI:                       cfg.UI,
+		VRTime:                   cfg.VRTime,
 	}
 }
 
@@ -91,15 +216,17 @@ func (s ConfigService) UpdateConfig(ctx context.Context, req ConfigUpdateRequest
 		return ConfigUpdateResponse{}, fmt.Errorf("load secrets: %w", err)
 	}
 
+	if errs := checkConfigUpdate(req); len(errs) > 0 {
+		return ConfigUpdateResponse{}, errs[0]
+	}
+
 	originalPort := cfg.Port
 	configChanged := false
 	secretsChanged := false
+	passwordChanged := false
 
 	// Apply updates to config
 	if req.Port != nil {
-		if *req.Port < 1 || *req.Port > 65535 {
-			return ConfigUpdateResponse{}, fmt.Errorf("port must be between 1 and 65535")
-		}
 		cfg.Port = *req.Port
 		configChanged = true
 	}
@@ -108,9 +235,6 @@ func (s ConfigService) UpdateConfig(ctx context.Context, req ConfigUpdateRequest
 		configChanged = true
 	}
 	if req.DiscordBatchSec != nil {
-		if *req.DiscordBatchSec < 0 {
-			return ConfigUpdateResponse{}, fmt.Errorf("discord_batch_sec must be non-negative")
-		}
 		cfg.DiscordBatchSec = *req.DiscordBatchSec
 		configChanged = true
 	}
@@ -126,23 +250,116 @@ func (s ConfigService) UpdateConfig(ctx context.Context, req ConfigUpdateRequest
 		cfg.NotifyOnWorldJoin = *req.NotifyOnWorldJoin
 		configChanged = true
 	}
+	if req.NotifySessionRecap != nil {
+		cfg.NotifySessionRecap = *req.NotifySessionRecap
+		configChanged = true
+	}
+	if req.NotifyStartupGrace != nil {
+		cfg.NotifyStartupGrace = *req.NotifyStartupGrace
+		configChanged = true
+	}
+	if req.NotifyReturnAfter != nil {
+		cfg.NotifyReturnAfter = *req.NotifyReturnAfter
+		configChanged = true
+	}
+	if req.NotifyMilestones != nil {
+		cfg.NotifyMilestones = *req.NotifyMilestones
+		configChanged = true
+	}
+	if req.NotifyAuthLockout != nil {
+		cfg.NotifyAuthLockout = *req.NotifyAuthLockout
+		configChanged = true
+	}
+	if req.DiscordThreadID != nil {
+		cfg.DiscordThreadID = *req.DiscordThreadID
+		configChanged = true
+	}
+	if req.DiscordUsername != nil {
+		cfg.DiscordUsername = *req.DiscordUsername
+		configChanged = true
+	}
+	if req.DiscordAvatarURL != nil {
+		cfg.DiscordAvatarURL = *req.DiscordAvatarURL
+		configChanged = true
+	}
 	if req.LogPath != nil {
 		cfg.LogPath = *req.LogPath
 		configChanged = true
 	}
+	if req.NotifyRules != nil {
+		cfg.NotifyRules = *req.NotifyRules
+		configChanged = true
+	}
+	if req.SoundHooks != nil {
+		cfg.SoundHooks = *req.SoundHooks
+		configChanged = true
+	}
+	if req.PlayerTags != nil {
+		cfg.PlayerTags = *req.PlayerTags
+		configChanged = true
+	}
+	if req.FriendTags != nil {
+		cfg.FriendTags = *req.FriendTags
+		configChanged = true
+	}
+	if req.BackupDir != nil {
+		cfg.BackupDir = *req.BackupDir
+		configChanged = true
+	}
+	if req.BackupTime != nil {
+		cfg.BackupTime = *req.BackupTime
+		configChanged = true
+	}
+	if req.BackupFormat != nil {
+		cfg.BackupFormat = *req.BackupFormat
+		configChanged = true
+	}
+	if req.BackupKeep != nil {
+		cfg.BackupKeep = *req.BackupKeep
+		configChanged = true
+	}
+	if req.VacuumIntervalDays != nil {
+		cfg.VacuumIntervalDays = *req.VacuumIntervalDays
+		configChanged = true
+	}
+	if req.SlowQueryMs != nil {
+		cfg.SlowQueryMs = *req.SlowQueryMs
+		configChanged = true
+	}
+	if req.UndoWindowHours != nil {
+		cfg.UndoWindowHours = *req.UndoWindowHours
+		configChanged = true
+	}
+	if req.RollupAfterDays != nil {
+		cfg.RollupAfterDays = *req.RollupAfterDays
+		configChanged = true
+	}
+	if req.MinFreeDiskMB != nil {
+		cfg.MinFreeDiskMB = *req.MinFreeDiskMB
+		configChanged = true
+	}
+	if req.UI != nil {
+		cfg.UI = *req.UI
+		configChanged = true
+	}
+	if req.VRTime != nil {
+		cfg.VRTime = *req.VRTime
+		configChanged = true
+	}
 
 	// Apply updates to secrets
 	if req.DiscordWebhookURL != nil {
-		url := *req.DiscordWebhookURL
-		if url != "" && !isValidDiscordWebhookURL(url) {
-			return ConfigUpdateResponse{}, fmt.Errorf("invalid Discord webhook URL")
-		}
-		sec.DiscordWebhookURL = config.Secret(url)
+		sec.DiscordWebhookURL = config.Secret(*req.DiscordWebhookURL)
+		secretsChanged = true
+	}
+	if req.DiscordBotToken != nil {
+		sec.DiscordBotToken = config.Secret(strings.TrimSpace(*req.DiscordBotToken))
 		secretsChanged = true
 	}
 	if req.BasicAuthPassword != nil {
 		pw := *req.BasicAuthPassword
 		if pw != "" {
+			passwordChanged = pw != sec.BasicAuthPassword.Value()
 			sec.BasicAuthPassword = config.Secret(pw)
 			// Ensure username exists
 			if sec.BasicAuthUsername == "" {
@@ -165,6 +382,11 @@ func (s ConfigService) UpdateConfig(ctx context.Context, req ConfigUpdateRequest
 			return ConfigUpdateResponse{}, fmt.Errorf("save secrets: %w", err)
 		}
 	}
+	if passwordChanged && s.Sessions != nil {
+		if _, err := s.Sessions.LogoutAll(ctx); err != nil {
+			return ConfigUpdateResponse{}, fmt.Errorf("end sessions: %w", err)
+		}
+	}
 
 	resp := ConfigUpdateResponse{
 		Success:         true,
@@ -178,6 +400,200 @@ func (s ConfigService) UpdateConfig(ctx context.Context, req ConfigUpdateRequest
 	return resp, nil
 }
 
+// ValidateConfig runs the checks of UpdateConfig, plus a check that a new
+// port is not already in use, without saving.
+func (s ConfigService) ValidateConfig(ctx context.Context, req ConfigUpdateRequest) ConfigValidationResult {
+	errs := checkConfigUpdate(req)
+
+	if req.Port != nil && *req.Port >= 1 && *req.Port <= 65535 {
+		cfg, _ := config.LoadConfigFrom(s.ConfigPath)
+		// The current port is held by this server, so only a change is checked
+		if *req.Port != cfg.Port {
+			available := s.PortAvailable
+			if available == nil {
+				available = checkPortAvailable
+			}
+			if err := available(*req.Port); err != nil {
+				errs = append(errs, ConfigFieldError{Field: "port", Message: "port is already in use"})
+			}
+		}
+	}
+
+	return ConfigValidationResult{Valid: len(errs) == 0, Errors: errs}
+}
+
+// GetUIConfig returns the branding of the web UI and overlays.
+func (s ConfigService) GetUIConfig(ctx context.Context) config.UIConfig {
+	cfg, _ := config.LoadConfigFrom(s.ConfigPath)
+	return cfg.UI
+}
+
+// ExportSettings bundles the current config, and the secrets if a
+// passphrase is given.
+func (s ConfigService) ExportSettings(ctx context.Context, passphrase string) (config.Bundle, error) {
+	cfg, err := config.LoadConfigFrom(s.ConfigPath)
+	if err != nil {
+		return config.Bundle{}, fmt.Errorf("load config: %w", err)
+	}
+
+	var sec *config.Secrets
+	if passphrase != "" {
+		loaded, status, err := config.LoadSecretsFrom(s.SecretsPath)
+		if err != nil && status == config.SecretsFallback {
+			return config.Bundle{}, fmt.Errorf("load secrets: %w", err)
+		}
+		sec = &loaded
+	}
+	return config.NewBundle(cfg, sec, passphrase, time.Now())
+}
+
+// ImportSettings writes the bundle's config and, if decrypted, secrets.
+// Secrets are only written after the config, so a bundle rejected for a
+// hand-edited config changes nothing.
+func (s ConfigService) ImportSettings(ctx context.Context, bundle config.Bundle, passphrase string) (SettingsImportResponse, error) {
+	cfg, sec, err := bundle.Open(passphrase)
+	if err != nil {
+		return SettingsImportResponse{}, err
+	}
+	if err := config.SaveConfigTo(cfg, s.ConfigPath); err != nil {
+		return SettingsImportResponse{}, fmt.Errorf("save config: %w", err)
+	}
+	if sec != nil {
+		if err := config.SaveSecretsTo(*sec, s.SecretsPath); err != nil {
+			return SettingsImportResponse{}, fmt.Errorf("save secrets: %w", err)
+		}
+	}
+	return SettingsImportResponse{Success: true, RestartRequired: true, SecretsImported: sec != nil}, nil
+}
+
+// checkConfigUpdate validates each field set in req.
+func checkConfigUpdate(req ConfigUpdateRequest) []ConfigFieldError {
+	errs := []ConfigFieldError{}
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, ConfigFieldError{Field: field, Message: err.Error()})
+		}
+	}
+
+	if req.Port != nil && (*req.Port < 1 || *req.Port > 65535) {
+		check("port", errors.New("must be between 1 and 65535"))
+	}
+	if req.DiscordBatchSec != nil && *req.DiscordBatchSec < 0 {
+		check("discord_batch_sec", errors.New("must be non-negative"))
+	}
+	if req.NotifyStartupGrace != nil && *req.NotifyStartupGrace < 0 {
+		check("notify_startup_grace_sec", errors.New("must be non-negative"))
+	}
+	if req.NotifyReturnAfter != nil && *req.NotifyReturnAfter < 0 {
+		check("notify_return_after_days", errors.New("must be non-negative"))
+	}
+	if req.DiscordThreadID != nil {
+		check("discord_thread_id", config.ValidateDiscordThreadID(*req.DiscordThreadID))
+	}
+	if req.DiscordUsername != nil {
+		check("discord_username", config.ValidateDiscordUsername(*req.DiscordUsername))
+	}
+	if req.DiscordAvatarURL != nil {
+		check("discord_avatar_url", config.ValidateDiscordAvatarURL(*req.DiscordAvatarURL))
+	}
+	if req.NotifyRules != nil {
+		for i, r := range *req.NotifyRules {
+			check(fmt.Sprintf("notify_rules[%d]", i), config.ValidateNotifyRule(r))
+		}
+	}
+	if req.SoundHooks != nil {
+		if len(*req.SoundHooks) > config.MaxSoundHooks {
+			check("sound_hooks", fmt.Errorf("at most %d hooks", config.MaxSoundHooks))
+		}
+		for i, h := range *req.SoundHooks {
+			check(fmt.Sprintf("sound_hooks[%d]", i), config.ValidateSoundHook(h))
+		}
+	}
+	if req.PlayerTags != nil {
+		check("player_tags", config.ValidatePlayerTags(*req.PlayerTags))
+	}
+	if req.FriendTags != nil {
+		check("friend_tags", config.ValidateFriendTags(*req.FriendTags))
+	}
+	if req.BackupTime != nil {
+		check("backup_time", config.ValidateBackupTime(*req.BackupTime))
+	}
+	if req.BackupFormat != nil {
+		check("backup_format", config.ValidateBackupFormat(*req.BackupFormat))
+	}
+	if req.BackupKeep != nil && *req.BackupKeep < 1 {
+		check("backup_keep", errors.New("must be at least 1"))
+	}
+	if req.VacuumIntervalDays != nil {
+		check("vacuum_interval_days", config.ValidateVacuumIntervalDays(*req.VacuumIntervalDays))
+	}
+	if req.SlowQueryMs != nil {
+		check("slow_query_ms", config.ValidateSlowQueryMs(*req.SlowQueryMs))
+	}
+	if req.UndoWindowHours != nil {
+		check("undo_window_hours", config.ValidateUndoWindowHours(*req.UndoWindowHours))
+	}
+	if req.RollupAfterDays != nil {
+		check("rollup_after_days", config.ValidateRollupAfterDays(*req.RollupAfterDays))
+	}
+	if req.MinFreeDiskMB != nil {
+		check("min_free_disk_mb", config.ValidateMinFreeDiskMB(*req.MinFreeDiskMB))
+	}
+	if req.UI != nil {
+		check("ui.title", config.ValidateUITitle(req.UI.Title))
+		check("ui.accent_color", config.ValidateUIAccentColor(req.UI.AccentColor))
+		check("ui.language", config.ValidateUILanguage(req.UI.Language))
+	}
+	if req.VRTime != nil {
+		check("vr_time", config.ValidateVRTime(*req.VRTime))
+	}
+	if req.DiscordWebhookURL != nil && *req.DiscordWebhookURL != "" && !isValidDiscordWebhookURL(*req.DiscordWebhookURL) {
+		check("discord_webhook_url", errors.New("invalid Discord webhook URL"))
+	}
+	return errs
+}
+
+// checkPortAvailable tries to listen on port on all interfaces.
+func checkPortAvailable(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
+
+// notifyRulesOrEmpty returns rules, or an empty slice so JSON encodes [] instead of null.
+func notifyRulesOrEmpty(rules []config.NotifyRule) []config.NotifyRule {
+	if rules == nil {
+		return []config.NotifyRule{}
+	}
+	return rules
+}
+
+// soundHooksOrEmpty returns hooks, or an empty slice so JSON encodes [] instead of null.
+func soundHooksOrEmpty(hooks []config.SoundHook) []config.SoundHook {
+	if hooks == nil {
+		return []config.SoundHook{}
+	}
+	return hooks
+}
+
+// friendTagsOrEmpty returns tags, or an empty slice so JSON encodes [] instead of null.
+func friendTagsOrEmpty(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// playerTagsOrEmpty returns tags, or an empty map so JSON encodes {} instead of null.
+func playerTagsOrEmpty(tags map[string][]string) map[string][]string {
+	if tags == nil {
+		return map[string][]string{}
+	}
+	return tags
+}
+
 // isValidDiscordWebhookURL validates Discord webhook URL format.
 func isValidDiscordWebhookURL(url string) bool {
 	return strings.HasPrefix(url, "https://discord.com/api/webhooks/") ||
@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+func newTestConfigService(t *testing.T) ConfigService {
+	t.Helper()
+	dir := t.TempDir()
+	return ConfigService{
+		ConfigPath:  filepath.Join(dir, "config.json"),
+		SecretsPath: filepath.Join(dir, "secrets.json"),
+	}
+}
+
+func TestConfigService_ValidateConfig(t *testing.T) {
+	svc := newTestConfigService(t)
+	var checked []int
+	svc.PortAvailable = func(port int) error {
+		checked = append(checked, port)
+		return errors.New("in use")
+	}
+
+	port, grace := 9000, -1
+	webhook, backupTime := "https://example.com/hook", "25:00"
+	result := svc.ValidateConfig(context.Background(), ConfigUpdateRequest{
+		Port:               &port,
+		NotifyStartupGrace: &grace,
+		DiscordWebhookURL:  &webhook,
+		BackupTime:         &backupTime,
+		UI:                 &config.UIConfig{Title: "VRClog", AccentColor: "blue"},
+	})
+
+	if result.Valid {
+		t.Fatal("expected invalid result")
+	}
+	fields := map[string]bool{}
+	for _, e := range result.Errors {
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"port", "notify_startup_grace_sec", "discord_webhook_url", "backup_time", "ui.accent_color"} {
+		if !fields[f] {
+			t.Errorf("no error for %s in %+v", f, result.Errors)
+		}
+	}
+	if _, err := os.Stat(svc.ConfigPath); !errors.Is(err, os.ErrNotExist) {
+		t.Error("ValidateConfig wrote the config file")
+	}
+
+	// The port the config already uses is not checked: this server holds it
+	checked = nil
+	current := config.DefaultConfig().Port
+	result = svc.ValidateConfig(context.Background(), ConfigUpdateRequest{Port: &current})
+	if !result.Valid || len(checked) != 0 {
+		t.Errorf("current port: result %+v, checked %v", result, checked)
+	}
+}
+
+func TestConfigService_UpdateConfigRejectsInvalid(t *testing.T) {
+	svc := newTestConfigService(t)
+
+	keep := 0
+	_, err := svc.UpdateConfig(context.Background(), ConfigUpdateRequest{BackupKeep: &keep})
+	var fieldErr ConfigFieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "backup_keep" {
+		t.Errorf("err = %v, want backup_keep field error", err)
+	}
+	if _, err := os.Stat(svc.ConfigPath); !errors.Is(err, os.ErrNotExist) {
+		t.Error("invalid update wrote the config file")
+	}
+}
+
+// logoutCounter counts LogoutAll calls.
+type logoutCounter struct {
+	SessionsUsecase
+	n int64
+}
+
+func (c *logoutCounter) LogoutAll(context.Context) (int64, error) {
+	c.n++
+	return c.n, nil
+}
+
+func TestConfigService_PasswordChangeEndsSessions(t *testing.T) {
+	svc := newTestConfigService(t)
+	sessions := &logoutCounter{}
+	svc.Sessions = sessions
+
+	update := func(req ConfigUpdateRequest) {
+		t.Helper()
+		if _, err := svc.UpdateConfig(context.Background(), req); err != nil {
+			t.Fatalf("UpdateConfig: %v", err)
+		}
+	}
+	pw, same, keep := "new-password", "new-password", 5
+	update(ConfigUpdateRequest{BasicAuthPassword: &pw})
+	if sessions.n != 1 {
+		t.Fatalf("%d logouts after a password change, want 1", sessions.n)
+	}
+	update(ConfigUpdateRequest{BasicAuthPassword: &same})
+	update(ConfigUpdateRequest{BackupKeep: &keep})
+	if sessions.n != 1 {
+		t.Errorf("%d logouts, want none without a password change", sessions.n)
+	}
+}
+
+func TestConfigService_ExportImportSettings(t *testing.T) {
+	src := newTestConfigService(t)
+	port := 9200
+	webhook := "https://discord.com/api/webhooks/1/abc"
+	if _, err := src.UpdateConfig(context.Background(), ConfigUpdateRequest{Port: &port, DiscordWebhookURL: &webhook}); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := src.ExportSettings(context.Background(), "passphrase")
+	if err != nil {
+		t.Fatalf("ExportSettings: %v", err)
+	}
+
+	dst := newTestConfigService(t)
+	result, err := dst.ImportSettings(context.Background(), bundle, "passphrase")
+	if err != nil {
+		t.Fatalf("ImportSettings: %v", err)
+	}
+	if !result.SecretsImported || !result.RestartRequired {
+		t.Errorf("result = %+v", result)
+	}
+	got := dst.GetConfig(context.Background())
+	if got.Port != 9200 || !got.DiscordWebhookConfigured {
+		t.Errorf("imported config = port %d, webhook %v", got.Port, got.DiscordWebhookConfigured)
+	}
+
+	// Without a passphrase only the config is exported
+	bundle, err = src.ExportSettings(context.Background(), "")
+	if err != nil || bundle.Secrets != nil {
+		t.Errorf("export without passphrase: secrets %v, err %v", bundle.Secrets, err)
+	}
+}
@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// DiagnosticsUsecase defines the troubleshooting diagnostics use case.
+type DiagnosticsUsecase interface {
+	// LogPath reports which log directory each account is read from and
+	// what the watcher sees there.
+	LogPath(ctx context.Context) LogPathDiagnostics
+	// Database reports database performance counters.
+	Database(ctx context.Context) DatabaseDiagnostics
+	// ParseFailures reports the size of the parse failure table and its
+	// most frequent failure patterns.
+	ParseFailures(ctx context.Context) (store.ParseFailureStats, error)
+}
+
+// DiagnosticsParseFailurePatterns is the number of failure patterns
+// returned by DiagnosticsUsecase.ParseFailures.
+const DiagnosticsParseFailurePatterns = 20
+
+// SlowQueryReporter reports slow database queries. Implemented by
+// store.Store.
+type SlowQueryReporter interface {
+	SlowQueries() store.SlowQueryStats
+}
+
+// ParseFailureReporter reports stored parse failures by pattern.
+// Implemented by store.Store.
+type ParseFailureReporter interface {
+	ParseFailureStats(ctx context.Context, limit int) (store.ParseFailureStats, error)
+}
+
+// DatabaseDiagnostics is the response of DiagnosticsUsecase.Database.
+type DatabaseDiagnostics struct {
+	SlowQueries store.SlowQueryStats `json:"slow_queries"`
+}
+
+// LogPathDiagnostics is the response of DiagnosticsUsecase.LogPath.
+type LogPathDiagnostics struct {
+	CheckedAt time.Time                `json:"checked_at"`
+	Accounts  []ingest.LogDirDiagnosis `json:"accounts"` // default account first
+}
+
+// DiagnosticsService implements DiagnosticsUsecase.
+type DiagnosticsService struct {
+	LogDir   string               // config.Config.LogPath; "" means auto-detect
+	Accounts []config.Account     // additional accounts
+	DB       SlowQueryReporter    // nil if there is no database (agent mode)
+	Failures ParseFailureReporter // nil if there is no database (agent mode)
+
+	now func() time.Time // nil means time.Now
+}
+
+// LogPath checks the log directory of the default account and of each
+// additional account.
+func (s DiagnosticsService) LogPath(ctx context.Context) LogPathDiagnostics {
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	result := LogPathDiagnostics{
+		CheckedAt: now,
+		Accounts:  []ingest.LogDirDiagnosis{ingest.DiagnoseLogDir("", s.LogDir, now)},
+	}
+	for _, a := range s.Accounts {
+		result.Accounts = append(result.Accounts, ingest.DiagnoseLogDir(a.Name, a.LogPath, now))
+	}
+	return result
+}
+
+// Database returns the slow query counters.
+func (s DiagnosticsService) Database(ctx context.Context) DatabaseDiagnostics {
+	var d DatabaseDiagnostics
+	if s.DB != nil {
+		d.SlowQueries = s.DB.SlowQueries()
+	}
+	return d
+}
+
+// ParseFailures returns the parse failure table's size and its most
+// frequent patterns.
+func (s DiagnosticsService) ParseFailures(ctx context.Context) (store.ParseFailureStats, error) {
+	if s.Failures == nil {
+		return store.ParseFailureStats{Top: []store.ParseFailurePatternStats{}}, nil
+	}
+	return s.Failures.ParseFailureStats(ctx, DiagnosticsParseFailurePatterns)
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// DeleteConfirmTTL is how long the confirm token of a dry run stays valid.
+const DeleteConfirmTTL = 5 * time.Minute
+
+// ErrConfirmRequired is returned when events are deleted without the
+// confirm token of a dry run.
+var ErrConfirmRequired = errors.New("confirm_token required; do a dry run first")
+
+// ErrInvalidConfirmToken is returned when a confirm token is expired or was
+// issued for a different filter.
+var ErrInvalidConfirmToken = errors.New("invalid or expired confirm_token; do a dry run again")
+
+// EventDeleteUsecase defines the bulk event deletion use case.
+type EventDeleteUsecase interface {
+	// DeleteEvents counts the events matching req.Filter and, unless
+	// req.DryRun is set, deletes them. A deletion needs the confirm token
+	// of a dry run with the same filter: ErrConfirmRequired without one,
+	// ErrInvalidConfirmToken if it does not match.
+	DeleteEvents(ctx context.Context, req DeleteEventsRequest) (DeleteEventsResult, error)
+}
+
+// EventDeleteStore defines store operations needed by EventDeleteService.
+type EventDeleteStore interface {
+	CountMatchingEvents(ctx context.Context, f store.QueryFilter) (int64, error)
+	DeleteEvents(ctx context.Context, f store.QueryFilter, batchSize int, trashBatch string) (int64, error)
+}
+
+// DeleteEventsRequest selects the events to delete. Limit, Cursor and
+// Order of Filter are ignored.
+type DeleteEventsRequest struct {
+	Filter       store.QueryFilter
+	DryRun       bool
+	ConfirmToken string
+}
+
+// DeleteEventsResult reports a dry run or a deletion.
+type DeleteEventsResult struct {
+	DryRun       bool       `json:"dry_run"`
+	Count        int64      `json:"count"`                   // events matching (dry run) or deleted
+	ConfirmToken string     `json:"confirm_token,omitempty"` // dry run only; pass back to delete
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // when ConfirmToken expires
+	BatchID      string     `json:"batch_id,omitempty"`      // trash batch to restore from; empty if deleted for good
+	UndoUntil    *time.Time `json:"undo_until,omitempty"`    // when the trash batch is purged
+}
+
+// EventDeleteService implements EventDeleteUsecase. Confirm tokens are
+// signed with a key made at first use, so they do not survive a restart.
+// A token covers the filter, not the matching events: events ingested
+// between the dry run and the deletion are deleted too if they match.
+type EventDeleteService struct {
+	Store      EventDeleteStore
+	BatchSize  int           // events per delete statement; 0 means store.DefaultDeleteBatchSize
+	UndoWindow time.Duration // how long deleted events stay in the trash; 0 deletes them for good
+
+	now func() time.Time // nil means time.Now
+
+	keyOnce sync.Once
+	key     []byte
+}
+
+// DeleteEvents counts or deletes the events matching req.Filter.
+// A confirmed deletion runs to the end even if the client goes away, so
+// it never stops halfway.
+func (s *EventDeleteService) DeleteEvents(ctx context.Context, req DeleteEventsRequest) (DeleteEventsResult, error) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	f := deleteFilter(req.Filter)
+
+	if req.DryRun {
+		n, err := s.Store.CountMatchingEvents(ctx, f)
+		if err != nil {
+			return DeleteEventsResult{}, err
+		}
+		expires := now().Add(DeleteConfirmTTL).Truncate(time.Second)
+		return DeleteEventsResult{
+			DryRun:       true,
+			Count:        n,
+			ConfirmToken: s.confirmToken(f, expires),
+			ExpiresAt:    &expires,
+		}, nil
+	}
+
+	if req.ConfirmToken == "" {
+		return DeleteEventsResult{}, ErrConfirmRequired
+	}
+	if !s.validToken(f, req.ConfirmToken, now()) {
+		return DeleteEventsResult{}, ErrInvalidConfirmToken
+	}
+	var result DeleteEventsResult
+	if s.UndoWindow > 0 {
+		until := now().Add(s.UndoWindow)
+		result.BatchID, result.UndoUntil = rand.Text(), &until
+	}
+	n, err := s.Store.DeleteEvents(context.WithoutCancel(ctx), f, s.BatchSize, result.BatchID)
+	result.Count = n
+	return result, err
+}
+
+// deleteFilter returns the conditions of f only, with times in UTC, so
+// equal filters sign the same.
+func deleteFilter(f store.QueryFilter) store.QueryFilter {
+	f.Limit, f.Cursor, f.Order = 0, nil, store.QueryOrderDesc
+	if f.Since != nil {
+		t := f.Since.UTC()
+		f.Since = &t
+	}
+	if f.Until != nil {
+		t := f.Until.UTC()
+		f.Until = &t
+	}
+	return f
+}
+
+// confirmToken returns "<expiry unix>.<signature>" for f.
+func (s *EventDeleteService) confirmToken(f store.QueryFilter, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + s.sign(f, exp)
+}
+
+func (s *EventDeleteService) validToken(f store.QueryFilter, token string, now time.Time) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.sign(f, exp)))
+}
+
+func (s *EventDeleteService) sign(f store.QueryFilter, exp string) string {
+	s.keyOnce.Do(func() {
+		s.key = make([]byte, 32)
+		rand.Read(s.key)
+	})
+	// QueryFilter has only plain fields, so its JSON is stable
+	data, _ := json.Marshal(f)
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(exp))
+	mac.Write([]byte{0})
+	mac.Write(data)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
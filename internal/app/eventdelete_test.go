@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubEventDeleteStore counts matching events as n and records deletions.
+type stubEventDeleteStore struct {
+	n       int64
+	deleted []store.QueryFilter
+}
+
+func (s *stubEventDeleteStore) CountMatchingEvents(ctx context.Context, f store.QueryFilter) (int64, error) {
+	return s.n, nil
+}
+
+func (s *stubEventDeleteStore) DeleteEvents(ctx context.Context, f store.QueryFilter, batchSize int, trashBatch string) (int64, error) {
+	s.deleted = append(s.deleted, f)
+	return s.n, nil
+}
+
+func TestEventDeleteService(t *testing.T) {
+	st := &stubEventDeleteStore{n: 42}
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	svc := &EventDeleteService{Store: st, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	joinType := event.TypePlayerJoin
+	since := time.Date(2024, 1, 1, 9, 0, 0, 0, time.FixedZone("JST", 9*3600))
+	filter := store.QueryFilter{Type: &joinType, Since: &since}
+
+	if _, err := svc.DeleteEvents(ctx, DeleteEventsRequest{Filter: filter}); !errors.Is(err, ErrConfirmRequired) {
+		t.Fatalf("delete without token: err = %v, want ErrConfirmRequired", err)
+	}
+
+	dry, err := svc.DeleteEvents(ctx, DeleteEventsRequest{Filter: filter, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !dry.DryRun || dry.Count != 42 || dry.ConfirmToken == "" || dry.ExpiresAt == nil {
+		t.Fatalf("dry run = %+v, want count 42 with a token", dry)
+	}
+	if len(st.deleted) != 0 {
+		t.Fatal("dry run deleted events")
+	}
+
+	// The token is bound to the filter
+	otherType := event.TypePlayerLeft
+	other := store.QueryFilter{Type: &otherType, Since: &since}
+	if _, err := svc.DeleteEvents(ctx, DeleteEventsRequest{Filter: other, ConfirmToken: dry.ConfirmToken}); !errors.Is(err, ErrInvalidConfirmToken) {
+		t.Errorf("token for another filter: err = %v, want ErrInvalidConfirmToken", err)
+	}
+
+	// The same instant in another zone, and a limit, are the same filter
+	sinceUTC := since.UTC()
+	same := store.QueryFilter{Type: &joinType, Since: &sinceUTC, Limit: 10}
+	res, err := svc.DeleteEvents(ctx, DeleteEventsRequest{Filter: same, ConfirmToken: dry.ConfirmToken})
+	if err != nil {
+		t.Fatalf("confirmed delete: %v", err)
+	}
+	if res.DryRun || res.Count != 42 || len(st.deleted) != 1 {
+		t.Errorf("delete = %+v with %d deletions, want 42 deleted once", res, len(st.deleted))
+	}
+	if res.BatchID != "" {
+		t.Errorf("BatchID = %q without an undo window, want none", res.BatchID)
+	}
+
+	// With an undo window the deletion goes to a trash batch
+	svc.UndoWindow = time.Hour
+	res, err = svc.DeleteEvents(ctx, DeleteEventsRequest{Filter: filter, ConfirmToken: dry.ConfirmToken})
+	if err != nil {
+		t.Fatalf("delete to trash: %v", err)
+	}
+	if res.BatchID == "" || res.UndoUntil == nil || !res.UndoUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("delete to trash = %+v, want a batch restorable for an hour", res)
+	}
+
+	now = now.Add(DeleteConfirmTTL)
+	if _, err := svc.DeleteEvents(ctx, DeleteEventsRequest{Filter: filter, ConfirmToken: dry.ConfirmToken}); !errors.Is(err, ErrInvalidConfirmToken) {
+		t.Errorf("expired token: err = %v, want ErrInvalidConfirmToken", err)
+	}
+}
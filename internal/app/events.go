@@ -9,11 +9,19 @@ import (
 // EventsUsecase defines the events query use case.
 type EventsUsecase interface {
 	Query(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error)
+	// Changes returns inserts, updates and deletes after sinceCursor for
+	// incremental sync. Returns store.ErrInvalidCursor for a bad cursor.
+	Changes(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error)
+	// Aggregate counts the events matching the filter by groupBy (one of
+	// store.GroupByType, GroupByPlayer or GroupByWorld).
+	Aggregate(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error)
 }
 
 // EventStore defines store operations needed by EventsService.
 type EventStore interface {
 	QueryEvents(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error)
+	EventChanges(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error)
+	AggregateEvents(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error)
 }
 
 // EventsService implements EventsUsecase.
@@ -25,3 +33,13 @@ type EventsService struct {
 func (s *EventsService) Query(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
 	return s.Store.QueryEvents(ctx, filter)
 }
+
+// Changes returns a page of the event change log.
+func (s *EventsService) Changes(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error) {
+	return s.Store.EventChanges(ctx, sinceCursor, limit)
+}
+
+// Aggregate counts the events matching the filter by groupBy.
+func (s *EventsService) Aggregate(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error) {
+	return s.Store.AggregateEvents(ctx, filter, groupBy)
+}
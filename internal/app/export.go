@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// DefaultExportDestination is the destination of exports that name none.
+const DefaultExportDestination = "default"
+
+// Export file formats.
+const (
+	ExportFormatJSONL   = "jsonl"   // a store.ExportHeader line, then one event per line
+	ExportFormatParquet = "parquet" // one row per event; the header is in the file metadata
+)
+
+// ErrInvalidExportFormat is returned for an unknown export format.
+var ErrInvalidExportFormat = errors.New("invalid format: use jsonl or parquet")
+
+// ErrInvalidDestination is returned for an export destination that is not
+// 1-64 letters, digits, dots, dashes or underscores.
+var ErrInvalidDestination = errors.New("invalid destination: use 1-64 letters, digits, '.', '-' or '_'")
+
+var exportDestinationPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// ExportUsecase defines the event export use case.
+type ExportUsecase interface {
+	// ExportEvents writes events to w in the requested format, along with
+	// a store.ExportHeader. An incremental export only has the events
+	// inserted or updated since the last successful export to the same
+	// destination (all of them the first time). Nothing is written if it
+	// returns ErrInvalidDestination or ErrInvalidExportFormat.
+	ExportEvents(ctx context.Context, w io.Writer, req ExportRequest) (store.ExportHeader, error)
+}
+
+// ExportStore defines store operations needed by ExportService.
+type ExportStore interface {
+	ExportEvents(ctx context.Context, ew store.ExportWriter, since string) (store.ExportHeader, error)
+	ExportCursor(ctx context.Context, destination string) (string, error)
+	SetExportCursor(ctx context.Context, destination, cursor string) error
+}
+
+// ExportRequest selects what ExportEvents writes.
+type ExportRequest struct {
+	Destination string // e.g. the analytics tool being fed; "" means DefaultExportDestination
+	Incremental bool
+	Format      string // ExportFormatJSONL (default) or ExportFormatParquet
+
+	// Mask, if set, redacts each event before it is written, e.g. for
+	// LAN clients. It must not modify the event passed.
+	Mask func(*event.Event) *event.Event
+}
+
+// ExportService implements ExportUsecase. It remembers the cursor of the
+// last export to each destination once the export was written in full,
+// so a failed or interrupted export is sent again next time.
+type ExportService struct {
+	Store ExportStore
+}
+
+// ExportEvents writes a full or incremental export and records its cursor
+// for the destination.
+func (s *ExportService) ExportEvents(ctx context.Context, w io.Writer, req ExportRequest) (store.ExportHeader, error) {
+	dest := req.Destination
+	if dest == "" {
+		dest = DefaultExportDestination
+	}
+	if !exportDestinationPattern.MatchString(dest) {
+		return store.ExportHeader{}, ErrInvalidDestination
+	}
+	newWriter := store.NewJSONLExportWriter
+	switch req.Format {
+	case "", ExportFormatJSONL:
+	case ExportFormatParquet:
+		newWriter = store.NewParquetExportWriter
+	default:
+		return store.ExportHeader{}, ErrInvalidExportFormat
+	}
+
+	var opts []store.ExportOption
+	if req.Mask != nil {
+		opts = append(opts, store.WithEventFilter(req.Mask))
+	}
+
+	since := ""
+	if req.Incremental {
+		var err error
+		if since, err = s.Store.ExportCursor(ctx, dest); err != nil {
+			return store.ExportHeader{}, err
+		}
+	}
+	header, err := s.Store.ExportEvents(ctx, newWriter(w, opts...), since)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		// A stored cursor that no longer parses; export everything again
+		header, err = s.Store.ExportEvents(ctx, newWriter(w, opts...), "")
+	}
+	if err != nil {
+		return header, err
+	}
+	return header, s.Store.SetExportCursor(ctx, dest, header.Cursor)
+}
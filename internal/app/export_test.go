@@ -0,0 +1,100 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubExportStore exports up to cursor "c<n>" on the n-th export and keeps
+// cursors in memory.
+type stubExportStore struct {
+	exports int
+	since   []string
+	err     error
+	cursors map[string]string
+}
+
+func (s *stubExportStore) ExportEvents(ctx context.Context, ew store.ExportWriter, since string) (store.ExportHeader, error) {
+	s.since = append(s.since, since)
+	if s.err != nil {
+		return store.ExportHeader{}, s.err
+	}
+	s.exports++
+	header := store.ExportHeader{Since: since, Cursor: "c" + string(rune('0'+s.exports))}
+	if err := ew.WriteHeader(header); err != nil {
+		return header, err
+	}
+	return header, ew.Close()
+}
+
+func (s *stubExportStore) ExportCursor(ctx context.Context, destination string) (string, error) {
+	return s.cursors[destination], nil
+}
+
+func (s *stubExportStore) SetExportCursor(ctx context.Context, destination, cursor string) error {
+	s.cursors[destination] = cursor
+	return nil
+}
+
+func TestExportService(t *testing.T) {
+	st := &stubExportStore{cursors: map[string]string{}}
+	svc := &ExportService{Store: st}
+	ctx := context.Background()
+	export := func(req ExportRequest) error {
+		_, err := svc.ExportEvents(ctx, io.Discard, req)
+		return err
+	}
+
+	// The first incremental export has everything, later ones continue
+	// where the last one to the same destination ended
+	for _, req := range []ExportRequest{
+		{Destination: "warehouse", Incremental: true},
+		{Destination: "warehouse", Incremental: true},
+		{Incremental: true},
+		{Destination: "warehouse"},
+	} {
+		if err := export(req); err != nil {
+			t.Fatalf("export %+v: %v", req, err)
+		}
+	}
+	want := []string{"", "c1", "", ""}
+	for i, since := range st.since {
+		if since != want[i] {
+			t.Errorf("export %d since %q, want %q", i, since, want[i])
+		}
+	}
+	if st.cursors["warehouse"] != "c4" || st.cursors[DefaultExportDestination] != "c3" {
+		t.Errorf("cursors = %v", st.cursors)
+	}
+
+	// A failed export does not move the cursor
+	st.err = errors.New("disk I/O error")
+	if err := export(ExportRequest{Destination: "warehouse", Incremental: true}); err == nil {
+		t.Fatal("expected the store error")
+	}
+	if st.cursors["warehouse"] != "c4" {
+		t.Errorf("cursor after failure = %q, want c4", st.cursors["warehouse"])
+	}
+
+	var buf bytes.Buffer
+	if _, err := svc.ExportEvents(ctx, &buf, ExportRequest{Destination: "../etc"}); !errors.Is(err, ErrInvalidDestination) || buf.Len() > 0 {
+		t.Errorf("bad destination: err = %v, %d bytes written", err, buf.Len())
+	}
+	if _, err := svc.ExportEvents(ctx, &buf, ExportRequest{Format: "csv"}); !errors.Is(err, ErrInvalidExportFormat) || buf.Len() > 0 {
+		t.Errorf("bad format: err = %v, %d bytes written", err, buf.Len())
+	}
+
+	// The format picks the file type
+	st.err = nil
+	if _, err := svc.ExportEvents(ctx, &buf, ExportRequest{Format: ExportFormatParquet}); err != nil {
+		t.Fatalf("parquet export: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("PAR1")) {
+		t.Errorf("parquet export starts with %q", buf.Bytes()[:min(4, buf.Len())])
+	}
+}
@@ -1,7 +1,14 @@
 // Package app provides application use cases.
 package app
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/diskspace"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
 
 // HealthUsecase defines the health check use case.
 type HealthUsecase interface {
@@ -15,11 +22,27 @@ type HealthChecker interface {
 	Ping(ctx context.Context) error
 }
 
+// PauseReporter reports whether a component is intentionally paused.
+type PauseReporter interface {
+	Paused() bool
+}
+
+// WriteReporter reports whether events are reaching the database.
+type WriteReporter interface {
+	WriteStatus() ingest.WriteStatus
+}
+
+// DiskSpaceReporter reports the free space on the data directory's volume.
+type DiskSpaceReporter interface {
+	Status() diskspace.Status
+}
+
 // HealthResult represents the health check response.
 type HealthResult struct {
 	Status     string                     `json:"status"`
 	Version    string                     `json:"version"`
 	Components map[string]ComponentHealth `json:"components,omitempty"`
+	Subsystems map[string]ComponentHealth `json:"subsystems,omitempty"` // supervised background components
 }
 
 // ComponentHealth represents the health status of a single component.
@@ -33,13 +56,19 @@ const (
 	StatusHealthy   = "healthy"
 	StatusDegraded  = "degraded"
 	StatusUnhealthy = "unhealthy"
+	StatusPaused    = "paused"
 )
 
 // HealthService implements HealthUsecase.
 type HealthService struct {
 	Version           string
 	DB                HealthChecker
+	Ingest            PauseReporter
+	Storage           WriteReporter // nil if write failures are not buffered
 	DiscordConfigured bool
+	Backup            BackupReporter    // nil if scheduled backups are disabled
+	DiskSpace         DiskSpaceReporter // nil if disk space is not monitored
+	Subsystems        ComponentReporter // nil if background components are not supervised
 }
 
 // Handle returns the current health status.
@@ -66,6 +95,56 @@ func (s HealthService) Handle(ctx context.Context) (HealthResult, error) {
 		}
 	}
 
+	// Report ingestion state (paused is intentional, so overall status is unaffected)
+	if s.Ingest != nil {
+		if s.Ingest.Paused() {
+			result.Components["ingest"] = ComponentHealth{
+				Status:  StatusPaused,
+				Message: "log ingestion paused",
+			}
+		} else {
+			result.Components["ingest"] = ComponentHealth{
+				Status: StatusHealthy,
+			}
+		}
+	}
+
+	// Report event writes; buffering in memory degrades overall status
+	if s.Storage != nil {
+		result.Components["storage"] = storageHealth(s.Storage.WriteStatus())
+		if result.Components["storage"].Status != StatusHealthy {
+			result.Status = StatusDegraded
+		}
+	}
+
+	// Report scheduled backups; a failed backup degrades overall status
+	if s.Backup != nil {
+		result.Components["backup"] = backupHealth(s.Backup.Status())
+		if result.Components["backup"].Status != StatusHealthy {
+			result.Status = StatusDegraded
+		}
+	}
+
+	// Report free disk space; low space degrades overall status
+	if s.DiskSpace != nil {
+		result.Components["disk_space"] = diskSpaceHealth(s.DiskSpace.Status())
+		if result.Components["disk_space"].Status != StatusHealthy {
+			result.Status = StatusDegraded
+		}
+	}
+
+	// Report background components; a crashed or failed one degrades
+	// overall status
+	if s.Subsystems != nil {
+		result.Subsystems = make(map[string]ComponentHealth)
+		for _, st := range s.Subsystems.Components() {
+			result.Subsystems[st.Name] = subsystemHealth(st)
+			if st.State == ComponentRestarting || st.State == ComponentFailed {
+				result.Status = StatusDegraded
+			}
+		}
+	}
+
 	// Report Discord webhook configuration status
 	if s.DiscordConfigured {
 		result.Components["discord_webhook"] = ComponentHealth{
@@ -80,3 +159,78 @@ func (s HealthService) Handle(ctx context.Context) (HealthResult, error) {
 
 	return result, nil
 }
+
+// storageHealth summarizes the write status for the health check.
+func storageHealth(st ingest.WriteStatus) ComponentHealth {
+	if !st.Degraded {
+		return ComponentHealth{Status: StatusHealthy}
+	}
+	msg := fmt.Sprintf("database not writable, %d events buffered in memory", st.Buffered)
+	if st.Dropped > 0 {
+		msg += fmt.Sprintf(", %d dropped", st.Dropped)
+	}
+	if st.LastError != "" {
+		msg += ": " + st.LastError
+	}
+	return ComponentHealth{Status: StatusUnhealthy, Message: msg}
+}
+
+// diskSpaceHealth summarizes the free disk space for the health check.
+func diskSpaceHealth(st diskspace.Status) ComponentHealth {
+	switch {
+	case st.Low:
+		return ComponentHealth{
+			Status: StatusDegraded,
+			Message: fmt.Sprintf("%d MB free, below %d MB; parse failures are not stored",
+				st.FreeBytes>>20, st.MinFreeBytes>>20),
+		}
+	case st.Error != "":
+		return ComponentHealth{Status: StatusDegraded, Message: "free space unknown: " + st.Error}
+	case st.CheckedAt == nil:
+		return ComponentHealth{Status: StatusHealthy}
+	}
+	return ComponentHealth{Status: StatusHealthy, Message: fmt.Sprintf("%d MB free", st.FreeBytes>>20)}
+}
+
+// subsystemHealth summarizes the state of a background component for the
+// health check.
+func subsystemHealth(st ComponentStatus) ComponentHealth {
+	switch st.State {
+	case ComponentRunning:
+		if st.Restarts > 0 {
+			return ComponentHealth{
+				Status:  StatusHealthy,
+				Message: fmt.Sprintf("restarted %d times, last %s", st.Restarts, st.LastError),
+			}
+		}
+		return ComponentHealth{Status: StatusHealthy}
+	case ComponentRestarting:
+		return ComponentHealth{Status: StatusDegraded, Message: "restarting after " + st.LastError}
+	case ComponentFailed:
+		return ComponentHealth{Status: StatusUnhealthy, Message: "stopped: " + st.LastError}
+	}
+	return ComponentHealth{Status: st.State}
+}
+
+// backupHealth summarizes the backup status for the health check.
+func backupHealth(st BackupStatus) ComponentHealth {
+	if st.LastError != "" {
+		return ComponentHealth{
+			Status:  StatusUnhealthy,
+			Message: "last backup failed: " + st.LastError,
+		}
+	}
+	if st.LastSuccess != nil {
+		return ComponentHealth{
+			Status:  StatusHealthy,
+			Message: "last backup " + st.LastSuccess.Format(time.RFC3339),
+		}
+	}
+	if st.NextRun != nil {
+		return ComponentHealth{
+			Status:  StatusHealthy,
+			Message: "next backup " + st.NextRun.Format(time.RFC3339),
+		}
+	}
+	return ComponentHealth{Status: StatusHealthy}
+}
@@ -0,0 +1,41 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/diskspace"
+)
+
+type stubDiskSpace diskspace.Status
+
+func (s stubDiskSpace) Status() diskspace.Status { return diskspace.Status(s) }
+
+func TestHealthService_DiskSpace(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		status diskspace.Status
+		want   string
+		msg    string
+	}{
+		{"not checked yet", diskspace.Status{}, StatusHealthy, ""},
+		{"enough", diskspace.Status{CheckedAt: &now, FreeBytes: 2048 << 20, MinFreeBytes: 500 << 20}, StatusHealthy, "2048 MB free"},
+		{"low", diskspace.Status{CheckedAt: &now, Low: true, FreeBytes: 100 << 20, MinFreeBytes: 500 << 20}, StatusDegraded, "below 500 MB"},
+		{"check failed", diskspace.Status{CheckedAt: &now, Error: "device not ready"}, StatusDegraded, "device not ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, _ := HealthService{DiskSpace: stubDiskSpace(tt.status)}.Handle(context.Background())
+			c := result.Components["disk_space"]
+			if c.Status != tt.want || !strings.Contains(c.Message, tt.msg) {
+				t.Errorf("disk_space = %+v, want %s with %q", c, tt.want, tt.msg)
+			}
+			if result.Status != tt.want {
+				t.Errorf("status = %q, want %q", result.Status, tt.want)
+			}
+		})
+	}
+}
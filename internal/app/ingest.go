@@ -0,0 +1,41 @@
+package app
+
+import "context"
+
+// IngestUsecase defines the ingestion control use case.
+type IngestUsecase interface {
+	// Pause stops reading VRChat logs until Resume is called.
+	Pause(ctx context.Context) IngestStatus
+	// Resume restarts reading VRChat logs after Pause.
+	Resume(ctx context.Context) IngestStatus
+}
+
+// IngestController defines the ingester operations needed by IngestService.
+type IngestController interface {
+	Pause() bool
+	Resume() bool
+	Paused() bool
+}
+
+// IngestStatus represents the result of a pause/resume request.
+type IngestStatus struct {
+	Paused  bool `json:"paused"`
+	Changed bool `json:"changed"` // false if already in the requested state
+}
+
+// IngestService implements IngestUsecase.
+type IngestService struct {
+	Ingester IngestController
+}
+
+// Pause pauses ingestion.
+func (s IngestService) Pause(ctx context.Context) IngestStatus {
+	changed := s.Ingester.Pause()
+	return IngestStatus{Paused: s.Ingester.Paused(), Changed: changed}
+}
+
+// Resume resumes ingestion.
+func (s IngestService) Resume(ctx context.Context) IngestStatus {
+	changed := s.Ingester.Resume()
+	return IngestStatus{Paused: s.Ingester.Paused(), Changed: changed}
+}
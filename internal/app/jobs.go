@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+)
+
+// JobsUsecase lists the scheduled jobs and runs them on request.
+type JobsUsecase interface {
+	// Jobs returns the status of each job.
+	Jobs(ctx context.Context) []schedule.Status
+	// TriggerJob starts a job now and returns its status. Returns
+	// schedule.ErrUnknownJob, schedule.ErrJobRunning or
+	// schedule.ErrNotRunning if it cannot be started.
+	TriggerJob(ctx context.Context, name string) (schedule.Status, error)
+}
+
+// JobsService implements JobsUsecase.
+type JobsService struct {
+	Scheduler *schedule.Scheduler
+}
+
+// Jobs returns the status of each job.
+func (s JobsService) Jobs(ctx context.Context) []schedule.Status {
+	return s.Scheduler.Jobs()
+}
+
+// TriggerJob starts a job now and returns its status.
+func (s JobsService) TriggerJob(ctx context.Context, name string) (schedule.Status, error) {
+	if err := s.Scheduler.Trigger(name); err != nil {
+		return schedule.Status{}, err
+	}
+	for _, st := range s.Scheduler.Jobs() {
+		if st.Name == name {
+			return st, nil
+		}
+	}
+	return schedule.Status{}, schedule.ErrUnknownJob
+}
@@ -0,0 +1,44 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/graaaaa/vrclog-companion/internal/logging"
+)
+
+// Limits of LogsUsecase.Tail.
+const (
+	DefaultLogTailLines = 100
+	MaxLogTailLines     = logging.DefaultBufferSize
+)
+
+// LogsUsecase defines the use case of reading the app's own recent logs,
+// for troubleshooting without access to the PC.
+type LogsUsecase interface {
+	// Tail returns up to lines of the most recent log records at minLevel
+	// or above, oldest first.
+	Tail(ctx context.Context, lines int, minLevel slog.Level) LogTail
+}
+
+// LogBuffer keeps recent log records. Implemented by logging.Buffer.
+type LogBuffer interface {
+	Tail(n int, minLevel slog.Level) []logging.Entry
+}
+
+// LogTail is the response of LogsUsecase.Tail.
+type LogTail struct {
+	Items []logging.Entry `json:"items"`
+	File  string          `json:"file,omitempty"` // the log file with the full history, if any
+}
+
+// LogsService implements LogsUsecase.
+type LogsService struct {
+	Buffer LogBuffer
+	File   string // path of the log file; "" if logs are not written to a file
+}
+
+// Tail returns the most recent records in the buffer.
+func (s LogsService) Tail(ctx context.Context, lines int, minLevel slog.Level) LogTail {
+	return LogTail{Items: s.Buffer.Tail(lines, minLevel), File: s.File}
+}
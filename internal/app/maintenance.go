@@ -0,0 +1,176 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+)
+
+// DefaultVacuumStartDelay is how long MaintenanceService waits after startup
+// before an overdue VACUUM, so it does not slow down the startup replay.
+const DefaultVacuumStartDelay = time.Minute
+
+// AnalyzeCheckInterval is how often MaintenanceService checks whether
+// enough events were inserted to refresh the query planner statistics.
+const AnalyzeCheckInterval = 5 * time.Minute
+
+// ErrVacuumRunning is returned when a VACUUM is requested while one is
+// already running.
+var ErrVacuumRunning = errors.New("vacuum already running")
+
+// MaintenanceUsecase defines the database maintenance use case.
+type MaintenanceUsecase interface {
+	// VacuumStatus returns when VACUUM last ran and is next due.
+	VacuumStatus(ctx context.Context) (VacuumStatus, error)
+	// Vacuum runs VACUUM now. Returns ErrVacuumRunning if one is running.
+	Vacuum(ctx context.Context) (VacuumStatus, error)
+}
+
+// MaintenanceStore defines the store operations needed by MaintenanceService.
+type MaintenanceStore interface {
+	Vacuum(ctx context.Context) error
+	VacuumIfNeeded(ctx context.Context, interval time.Duration) (bool, error)
+	LastVacuum(ctx context.Context) (time.Time, error)
+	AnalyzeIfNeeded(ctx context.Context) (bool, error)
+}
+
+// VacuumStatus describes the VACUUM schedule.
+type VacuumStatus struct {
+	LastVacuum   *time.Time `json:"last_vacuum,omitempty"` // nil if never run
+	NextVacuum   *time.Time `json:"next_vacuum,omitempty"` // nil if only run manually
+	IntervalDays int        `json:"interval_days"`         // 0 means manual only
+	Running      bool       `json:"running"`
+}
+
+// MaintenanceService runs VACUUM every Interval and on request, and
+// ANALYZE after bulk inserts (startup replay, merges, events received from
+// remote agents). See Jobs for the schedule.
+type MaintenanceService struct {
+	Store    MaintenanceStore
+	Interval time.Duration // 0 means manual only
+	OnVacuum func()        // called after each successful VACUUM; may be nil
+	Logger   *slog.Logger  // nil means slog.Default()
+
+	startDelay    time.Duration // 0 means DefaultVacuumStartDelay
+	analyzePeriod time.Duration // 0 means AnalyzeCheckInterval
+
+	mu      sync.Mutex
+	running bool
+}
+
+// Jobs returns the scheduled jobs: ANALYZE when needed, checked every
+// AnalyzeCheckInterval, and, if Interval is set, VACUUM when due, checked
+// daily and DefaultVacuumStartDelay after startup.
+func (s *MaintenanceService) Jobs() []schedule.Job {
+	period := s.analyzePeriod
+	if period <= 0 {
+		period = AnalyzeCheckInterval
+	}
+	jobs := []schedule.Job{{Name: "analyze", Schedule: schedule.Every(period), Run: s.analyze}}
+	if s.Interval > 0 {
+		delay := s.startDelay
+		if delay <= 0 {
+			delay = DefaultVacuumStartDelay
+		}
+		jobs = append(jobs, schedule.Job{
+			Name:       "vacuum",
+			Schedule:   schedule.Every(24 * time.Hour),
+			RunAtStart: true,
+			StartDelay: delay,
+			Run:        s.vacuumIfDue,
+		})
+	}
+	return jobs
+}
+
+// analyze runs ANALYZE if enough events were inserted since the last one.
+func (s *MaintenanceService) analyze(ctx context.Context) error {
+	_, err := s.Store.AnalyzeIfNeeded(ctx)
+	return err
+}
+
+// vacuumIfDue runs VACUUM if Interval has passed since the last one.
+func (s *MaintenanceService) vacuumIfDue(ctx context.Context) error {
+	if !s.begin() {
+		return nil // a manual VACUUM is running
+	}
+	vacuumed, err := s.Store.VacuumIfNeeded(ctx, s.Interval)
+	s.end()
+	if err != nil {
+		return err
+	}
+	if vacuumed && s.OnVacuum != nil {
+		s.OnVacuum()
+	}
+	return nil
+}
+
+// VacuumStatus returns when VACUUM last ran and is next due.
+func (s *MaintenanceService) VacuumStatus(ctx context.Context) (VacuumStatus, error) {
+	last, err := s.Store.LastVacuum(ctx)
+	if err != nil {
+		return VacuumStatus{}, err
+	}
+
+	s.mu.Lock()
+	status := VacuumStatus{
+		IntervalDays: int(s.Interval / (24 * time.Hour)),
+		Running:      s.running,
+	}
+	s.mu.Unlock()
+	if !last.IsZero() {
+		status.LastVacuum = &last
+	}
+	if s.Interval > 0 {
+		next := last.Add(s.Interval)
+		if last.IsZero() || next.Before(time.Now()) {
+			next = time.Now() // overdue; runs shortly
+		}
+		status.NextVacuum = &next
+	}
+	return status, nil
+}
+
+// Vacuum runs VACUUM now and returns the updated status.
+func (s *MaintenanceService) Vacuum(ctx context.Context) (VacuumStatus, error) {
+	if !s.begin() {
+		return VacuumStatus{}, ErrVacuumRunning
+	}
+	err := s.Store.Vacuum(ctx)
+	s.end()
+	if err != nil {
+		return VacuumStatus{}, err
+	}
+	if s.OnVacuum != nil {
+		s.OnVacuum()
+	}
+	return s.VacuumStatus(ctx)
+}
+
+// begin marks a VACUUM as running. Returns false if one already is.
+func (s *MaintenanceService) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return false
+	}
+	s.running = true
+	return true
+}
+
+func (s *MaintenanceService) end() {
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+}
+
+func (s *MaintenanceService) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+)
+
+// runJobs runs jobs on a scheduler until ctx is cancelled. The returned
+// channel is closed once the scheduler has stopped.
+func runJobs(t *testing.T, ctx context.Context, jobs []schedule.Job) <-chan struct{} {
+	t.Helper()
+	sched := schedule.New()
+	for _, j := range jobs {
+		if err := sched.Add(j); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		sched.Run(ctx)
+		close(done)
+	}()
+	return done
+}
+
+// stubMaintenanceStore records vacuums. Vacuum blocks on block if set.
+type stubMaintenanceStore struct {
+	mu       sync.Mutex
+	last     time.Time
+	count    int
+	analyzed int
+	block    chan struct{}
+}
+
+func (s *stubMaintenanceStore) Vacuum(ctx context.Context) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = time.Now()
+	s.count++
+	return nil
+}
+
+func (s *stubMaintenanceStore) VacuumIfNeeded(ctx context.Context, interval time.Duration) (bool, error) {
+	last, _ := s.LastVacuum(ctx)
+	if time.Since(last) < interval {
+		return false, nil
+	}
+	return true, s.Vacuum(ctx)
+}
+
+func (s *stubMaintenanceStore) AnalyzeIfNeeded(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.analyzed++
+	return true, nil
+}
+
+func (s *stubMaintenanceStore) LastVacuum(ctx context.Context) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last, nil
+}
+
+func TestMaintenanceService_RunVacuumsWhenDue(t *testing.T) {
+	store := &stubMaintenanceStore{last: time.Now().Add(-40 * 24 * time.Hour)}
+	vacuumed := make(chan struct{}, 1)
+	svc := &MaintenanceService{
+		Store:      store,
+		Interval:   30 * 24 * time.Hour,
+		OnVacuum:   func() { vacuumed <- struct{}{} },
+		startDelay: time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runJobs(t, ctx, svc.Jobs())
+
+	select {
+	case <-vacuumed:
+	case <-time.After(time.Second):
+		t.Fatal("overdue vacuum did not run")
+	}
+
+	status, err := svc.VacuumStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.IntervalDays != 30 || status.LastVacuum == nil || status.NextVacuum == nil {
+		t.Fatalf("status = %+v", status)
+	}
+	if want := status.LastVacuum.Add(30 * 24 * time.Hour); !status.NextVacuum.Equal(want) {
+		t.Errorf("next vacuum = %v, want %v", status.NextVacuum, want)
+	}
+}
+
+func TestMaintenanceService_VacuumWhileRunning(t *testing.T) {
+	store := &stubMaintenanceStore{block: make(chan struct{})}
+	svc := &MaintenanceService{Store: store}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := svc.Vacuum(context.Background())
+		done <- err
+	}()
+	// Wait for the first vacuum to start
+	for {
+		status, _ := svc.VacuumStatus(context.Background())
+		if status.Running {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := svc.Vacuum(context.Background()); !errors.Is(err, ErrVacuumRunning) {
+		t.Errorf("second Vacuum err = %v, want ErrVacuumRunning", err)
+	}
+	close(store.block)
+	if err := <-done; err != nil {
+		t.Fatalf("first Vacuum: %v", err)
+	}
+
+	status, err := svc.VacuumStatus(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Running || status.LastVacuum == nil || status.NextVacuum != nil {
+		t.Errorf("status = %+v, want finished manual-only vacuum", status)
+	}
+}
+
+func TestMaintenanceService_RunAnalyzes(t *testing.T) {
+	store := &stubMaintenanceStore{}
+	svc := &MaintenanceService{Store: store, analyzePeriod: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runJobs(t, ctx, svc.Jobs())
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.Lock()
+		n := store.analyzed
+		store.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("AnalyzeIfNeeded was not called")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if store.count != 0 {
+		t.Errorf("vacuumed %d times with Interval 0", store.count)
+	}
+}
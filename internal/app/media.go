@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// MediaPlay is a URL a video player in a world tried to play.
+type MediaPlay struct {
+	ID         int64     `json:"id"`
+	Ts         time.Time `json:"ts"`
+	URL        string    `json:"url"`
+	WorldID    *string   `json:"world_id,omitempty"`
+	WorldName  *string   `json:"world_name,omitempty"`
+	InstanceID *string   `json:"instance_id,omitempty"`
+}
+
+// MediaPage is one page of media plays.
+type MediaPage struct {
+	Items      []MediaPlay `json:"items"`
+	NextCursor *string     `json:"next_cursor,omitempty"`
+}
+
+// MediaUsecase defines the video URL history use case.
+type MediaUsecase interface {
+	// List returns media plays matching filter. filter.Type is ignored.
+	List(ctx context.Context, filter store.QueryFilter) (MediaPage, error)
+}
+
+// MediaService implements MediaUsecase on top of video_play events.
+type MediaService struct {
+	Store EventStore
+}
+
+// List returns media plays matching filter, newest first by default.
+func (s *MediaService) List(ctx context.Context, filter store.QueryFilter) (MediaPage, error) {
+	typ := event.TypeVideoPlay
+	filter.Type = &typ
+
+	result, err := s.Store.QueryEvents(ctx, filter)
+	if err != nil {
+		return MediaPage{}, err
+	}
+
+	page := MediaPage{Items: make([]MediaPlay, 0, len(result.Items)), NextCursor: result.NextCursor}
+	for _, e := range result.Items {
+		var meta struct {
+			URL string `json:"url"`
+		}
+		if len(e.MetaJSON) > 0 {
+			if err := json.Unmarshal(e.MetaJSON, &meta); err != nil {
+				return MediaPage{}, fmt.Errorf("decode media %d meta: %w", e.ID, err)
+			}
+		}
+		page.Items = append(page.Items, MediaPlay{
+			ID:         e.ID,
+			Ts:         e.Ts,
+			URL:        meta.URL,
+			WorldID:    e.WorldID,
+			WorldName:  e.WorldName,
+			InstanceID: e.InstanceID,
+		})
+	}
+	return page, nil
+}
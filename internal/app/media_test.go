@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubEventStore is a test double for EventStore.
+type stubEventStore struct {
+	gotFilter store.QueryFilter
+	result    store.QueryResult
+}
+
+func (s *stubEventStore) QueryEvents(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+	s.gotFilter = filter
+	return s.result, nil
+}
+
+func (s *stubEventStore) EventChanges(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error) {
+	return store.ChangesResult{}, nil
+}
+
+func (s *stubEventStore) AggregateEvents(ctx context.Context, filter store.QueryFilter, groupBy string) (store.AggregateResult, error) {
+	return store.AggregateResult{}, nil
+}
+
+func TestMediaService_List(t *testing.T) {
+	stub := &stubEventStore{result: store.QueryResult{Items: []event.Event{{
+		ID:        7,
+		Type:      event.TypeVideoPlay,
+		WorldName: event.StringPtr("Club"),
+		MetaJSON:  json.RawMessage(`{"url":"https://example.com/song"}`),
+	}}}}
+	svc := &MediaService{Store: stub}
+
+	// A caller-provided type is replaced
+	other := event.TypePlayerJoin
+	page, err := svc.List(context.Background(), store.QueryFilter{Type: &other})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if stub.gotFilter.Type == nil || *stub.gotFilter.Type != event.TypeVideoPlay {
+		t.Errorf("Type filter = %v, want video_play", stub.gotFilter.Type)
+	}
+	if len(page.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(page.Items))
+	}
+	item := page.Items[0]
+	if item.ID != 7 || item.URL != "https://example.com/song" || *item.WorldName != "Club" {
+		t.Errorf("item = %+v", item)
+	}
+}
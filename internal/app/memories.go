@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// MemoryPlayers is how many of the players met on a day a memory lists.
+const MemoryPlayers = 5
+
+// MemoriesUsecase defines the "on this day" use case.
+type MemoriesUsecase interface {
+	// OnThisDay returns what happened on the month and day of date, in its
+	// location, in each earlier year. An empty account covers all accounts.
+	OnThisDay(ctx context.Context, date time.Time, account string) (Memories, error)
+}
+
+// MemoryStore defines store operations needed by MemoriesService.
+type MemoryStore interface {
+	GetMemories(ctx context.Context, date time.Time, account string, players int) ([]store.DayMemory, error)
+}
+
+// Memories is the response of MemoriesUsecase.OnThisDay.
+type Memories struct {
+	Date  string            `json:"date"`  // YYYY-MM-DD
+	Items []store.DayMemory `json:"items"` // most recent year first; years without events are left out
+}
+
+// MemoriesService implements MemoriesUsecase.
+type MemoriesService struct {
+	Store MemoryStore
+}
+
+// OnThisDay returns the memories of date.
+func (s *MemoriesService) OnThisDay(ctx context.Context, date time.Time, account string) (Memories, error) {
+	items, err := s.Store.GetMemories(ctx, date, account, MemoryPlayers)
+	if err != nil {
+		return Memories{}, err
+	}
+	return Memories{Date: date.Format("2006-01-02"), Items: items}, nil
+}
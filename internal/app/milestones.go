@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// ErrInvalidMilestoneKind is returned when a milestone filter names an
+// unknown kind.
+var ErrInvalidMilestoneKind = errors.New("invalid milestone kind")
+
+// MilestonesUsecase defines the milestones use case.
+type MilestonesUsecase interface {
+	// List returns milestones matching filter, most recent first, and the
+	// current play streak of filter.Account.
+	List(ctx context.Context, filter store.MilestoneFilter) (MilestonesResponse, error)
+}
+
+// MilestoneStore defines store operations needed by MilestonesService.
+type MilestoneStore interface {
+	ListMilestones(ctx context.Context, f store.MilestoneFilter) ([]store.Milestone, error)
+	CurrentStreak(ctx context.Context, account string, now time.Time) (int, error)
+}
+
+// MilestonesResponse is the response of MilestonesUsecase.List.
+type MilestonesResponse struct {
+	Items         []store.Milestone `json:"items"`
+	CurrentStreak int               `json:"current_streak"` // days in a row with a world join, up to today
+}
+
+// MilestonesService implements MilestonesUsecase.
+type MilestonesService struct {
+	Store MilestoneStore
+	now   func() time.Time // nil means time.Now
+}
+
+// List returns the milestones matching filter.
+func (s *MilestonesService) List(ctx context.Context, filter store.MilestoneFilter) (MilestonesResponse, error) {
+	switch filter.Kind {
+	case "", store.MilestoneWorldVisits, store.MilestonePlayerAnniversary, store.MilestonePlayStreak:
+	default:
+		return MilestonesResponse{}, fmt.Errorf("%w: %s", ErrInvalidMilestoneKind, filter.Kind)
+	}
+
+	items, err := s.Store.ListMilestones(ctx, filter)
+	if err != nil {
+		return MilestonesResponse{}, err
+	}
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	streak, err := s.Store.CurrentStreak(ctx, filter.Account, now)
+	if err != nil {
+		return MilestonesResponse{}, err
+	}
+	return MilestonesResponse{Items: items, CurrentStreak: streak}, nil
+}
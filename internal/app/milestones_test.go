@@ -0,0 +1,45 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubMilestoneStore struct {
+	gotAccount string
+	gotNow     time.Time
+}
+
+func (s *stubMilestoneStore) ListMilestones(ctx context.Context, f store.MilestoneFilter) ([]store.Milestone, error) {
+	return []store.Milestone{{Kind: f.Kind, Value: 7}}, nil
+}
+
+func (s *stubMilestoneStore) CurrentStreak(ctx context.Context, account string, now time.Time) (int, error) {
+	s.gotAccount, s.gotNow = account, now
+	return 3, nil
+}
+
+func TestMilestonesService_List(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+	st := &stubMilestoneStore{}
+	svc := &MilestonesService{Store: st, now: func() time.Time { return now }}
+
+	got, err := svc.List(context.Background(), store.MilestoneFilter{Kind: store.MilestonePlayStreak, Account: "alt"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(got.Items) != 1 || got.CurrentStreak != 3 {
+		t.Errorf("response = %+v", got)
+	}
+	if st.gotAccount != "alt" || !st.gotNow.Equal(now) {
+		t.Errorf("CurrentStreak(%q, %v), want alt at %v", st.gotAccount, st.gotNow, now)
+	}
+
+	if _, err := svc.List(context.Background(), store.MilestoneFilter{Kind: "visits"}); !errors.Is(err, ErrInvalidMilestoneKind) {
+		t.Errorf("unknown kind: err = %v, want ErrInvalidMilestoneKind", err)
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// MaxNoteLength is the maximum note length in characters.
+const MaxNoteLength = 2000
+
+// ErrInvalidNote is returned when note text fails validation.
+var ErrInvalidNote = errors.New("invalid note")
+
+// NotesUsecase defines event annotation operations.
+type NotesUsecase interface {
+	// Add attaches a note to an event. Returns store.ErrNotFound if the
+	// event does not exist, or ErrInvalidNote for empty or oversized text.
+	Add(ctx context.Context, eventID int64, text string) (*store.Note, error)
+	List(ctx context.Context, filter store.NoteFilter) ([]store.Note, error)
+	// Delete removes a note. Returns store.ErrNotFound if it does not exist.
+	Delete(ctx context.Context, id int64) error
+}
+
+// NoteStore defines store operations needed by NotesService.
+type NoteStore interface {
+	AddNote(ctx context.Context, eventID int64, text string) (*store.Note, error)
+	ListNotes(ctx context.Context, filter store.NoteFilter) ([]store.Note, error)
+	DeleteNote(ctx context.Context, id int64) error
+}
+
+// NotesService implements NotesUsecase.
+type NotesService struct {
+	Store NoteStore
+}
+
+// Add validates and stores a note.
+func (s *NotesService) Add(ctx context.Context, eventID int64, text string) (*store.Note, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("%w: text is required", ErrInvalidNote)
+	}
+	if utf8.RuneCountInString(text) > MaxNoteLength {
+		return nil, fmt.Errorf("%w: text exceeds %d characters", ErrInvalidNote, MaxNoteLength)
+	}
+	return s.Store.AddNote(ctx, eventID, text)
+}
+
+// List returns notes matching filter.
+func (s *NotesService) List(ctx context.Context, filter store.NoteFilter) ([]store.Note, error) {
+	return s.Store.ListNotes(ctx, filter)
+}
+
+// Delete removes a note.
+func (s *NotesService) Delete(ctx context.Context, id int64) error {
+	return s.Store.DeleteNote(ctx, id)
+}
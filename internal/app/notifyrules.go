@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+// Errors returned by NotifyRulesUsecase.
+var (
+	ErrInvalidNotifyRule  = errors.New("invalid notify rule")
+	ErrNotifyRuleNotFound = errors.New("notify rule not found")
+)
+
+// NotifyRulesUsecase manages the ordered list of notify rules in the config
+// file. Every method returns the full list after the change, since rule
+// positions shift.
+type NotifyRulesUsecase interface {
+	List(ctx context.Context) ([]config.NotifyRule, error)
+	// Add inserts a rule at index, or appends it if index is nil.
+	// Returns ErrInvalidNotifyRule.
+	Add(ctx context.Context, rule config.NotifyRule, index *int) ([]config.NotifyRule, error)
+	// Replace replaces the rule at index. Returns ErrInvalidNotifyRule or
+	// ErrNotifyRuleNotFound.
+	Replace(ctx context.Context, index int, rule config.NotifyRule) ([]config.NotifyRule, error)
+	// Delete removes the rule at index. Returns ErrNotifyRuleNotFound.
+	Delete(ctx context.Context, index int) ([]config.NotifyRule, error)
+}
+
+// NotifyRulesService implements NotifyRulesUsecase.
+type NotifyRulesService struct {
+	ConfigPath string
+
+	// Apply is called with the new rules after they are saved, so the
+	// running notifier uses them without a restart. May be nil.
+	Apply func(rules []config.NotifyRule)
+
+	mu sync.Mutex // serializes read-modify-write of the config file
+}
+
+// List returns the rules in evaluation order.
+func (s *NotifyRulesService) List(ctx context.Context) ([]config.NotifyRule, error) {
+	cfg, err := config.LoadConfigFrom(s.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return notifyRulesOrEmpty(cfg.NotifyRules), nil
+}
+
+// Add validates and inserts a rule.
+func (s *NotifyRulesService) Add(ctx context.Context, rule config.NotifyRule, index *int) ([]config.NotifyRule, error) {
+	return s.update(&rule, func(cfg *config.Config) error {
+		if len(cfg.NotifyRules) >= config.MaxNotifyRules {
+			return fmt.Errorf("%w: at most %d rules", ErrInvalidNotifyRule, config.MaxNotifyRules)
+		}
+		i := len(cfg.NotifyRules)
+		if index != nil {
+			if *index < 0 || *index > len(cfg.NotifyRules) {
+				return fmt.Errorf("%w: index must be between 0 and %d", ErrInvalidNotifyRule, len(cfg.NotifyRules))
+			}
+			i = *index
+		}
+		cfg.NotifyRules = slices.Insert(cfg.NotifyRules, i, rule)
+		return nil
+	})
+}
+
+// Replace validates a rule and stores it at index.
+func (s *NotifyRulesService) Replace(ctx context.Context, index int, rule config.NotifyRule) ([]config.NotifyRule, error) {
+	return s.update(&rule, func(cfg *config.Config) error {
+		if index < 0 || index >= len(cfg.NotifyRules) {
+			return ErrNotifyRuleNotFound
+		}
+		cfg.NotifyRules[index] = rule
+		return nil
+	})
+}
+
+// Delete removes the rule at index.
+func (s *NotifyRulesService) Delete(ctx context.Context, index int) ([]config.NotifyRule, error) {
+	return s.update(nil, func(cfg *config.Config) error {
+		if index < 0 || index >= len(cfg.NotifyRules) {
+			return ErrNotifyRuleNotFound
+		}
+		cfg.NotifyRules = slices.Delete(cfg.NotifyRules, index, index+1)
+		return nil
+	})
+}
+
+// update validates rule (if any), applies change to the loaded config,
+// saves it, and hands the new rules to Apply.
+func (s *NotifyRulesService) update(rule *config.NotifyRule, change func(cfg *config.Config) error) ([]config.NotifyRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := config.LoadConfigFrom(s.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	if rule != nil {
+		if err := validateNotifyRule(*rule, cfg.PlayerTags); err != nil {
+			return nil, err
+		}
+	}
+	if err := change(&cfg); err != nil {
+		return nil, err
+	}
+	if err := config.SaveConfigTo(cfg, s.ConfigPath); err != nil {
+		return nil, err
+	}
+
+	rules := notifyRulesOrEmpty(cfg.NotifyRules)
+	if s.Apply != nil {
+		s.Apply(slices.Clone(rules))
+	}
+	return rules, nil
+}
+
+// validateNotifyRule checks a rule, including that its player tags are
+// defined.
+func validateNotifyRule(r config.NotifyRule, tags map[string][]string) error {
+	if err := config.ValidateNotifyRule(r); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidNotifyRule, err)
+	}
+	for _, tag := range r.PlayerTags {
+		if _, ok := tags[tag]; !ok {
+			return fmt.Errorf("%w: unknown player tag %q", ErrInvalidNotifyRule, tag)
+		}
+	}
+	return nil
+}
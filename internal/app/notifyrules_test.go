@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+func TestNotifyRulesService_CRUD(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := config.DefaultConfig()
+	cfg.PlayerTags = map[string][]string{"friends": {"Alice"}}
+	if err := config.SaveConfigTo(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+
+	var applied []config.NotifyRule
+	svc := &NotifyRulesService{ConfigPath: path, Apply: func(r []config.NotifyRule) { applied = r }}
+
+	deny := config.NotifyRule{InstanceTypes: []string{"public"}, Action: config.RuleActionDeny}
+	friends := config.NotifyRule{PlayerTags: []string{"friends"}, Action: config.RuleActionAllow, MentionRoleID: "42"}
+
+	if _, err := svc.Add(ctx, deny, nil); err != nil {
+		t.Fatal(err)
+	}
+	first := 0
+	rules, err := svc.Add(ctx, friends, &first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].MentionRoleID != "42" || rules[1].Action != config.RuleActionDeny {
+		t.Fatalf("rules = %+v, want friends rule inserted first", rules)
+	}
+	if len(applied) != 2 {
+		t.Errorf("applied %d rules, want 2", len(applied))
+	}
+
+	deny.InstanceTypes = []string{"group_public"}
+	if _, err := svc.Replace(ctx, 1, deny); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Delete(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err = svc.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].InstanceTypes[0] != "group_public" {
+		t.Errorf("rules = %+v, want only the replaced deny rule", rules)
+	}
+}
+
+func TestNotifyRulesService_Errors(t *testing.T) {
+	ctx := context.Background()
+	svc := &NotifyRulesService{ConfigPath: filepath.Join(t.TempDir(), "config.json")}
+
+	if _, err := svc.Add(ctx, config.NotifyRule{Action: "maybe"}, nil); !errors.Is(err, ErrInvalidNotifyRule) {
+		t.Errorf("invalid action: err = %v, want ErrInvalidNotifyRule", err)
+	}
+	unknownTag := config.NotifyRule{PlayerTags: []string{"vip"}, Action: config.RuleActionAllow}
+	if _, err := svc.Add(ctx, unknownTag, nil); !errors.Is(err, ErrInvalidNotifyRule) {
+		t.Errorf("unknown tag: err = %v, want ErrInvalidNotifyRule", err)
+	}
+	outOfRange := 3
+	if _, err := svc.Add(ctx, config.NotifyRule{Action: config.RuleActionAllow}, &outOfRange); !errors.Is(err, ErrInvalidNotifyRule) {
+		t.Errorf("bad index: err = %v, want ErrInvalidNotifyRule", err)
+	}
+	if _, err := svc.Replace(ctx, 0, config.NotifyRule{Action: config.RuleActionAllow}); !errors.Is(err, ErrNotifyRuleNotFound) {
+		t.Errorf("replace missing: err = %v, want ErrNotifyRuleNotFound", err)
+	}
+	if _, err := svc.Delete(ctx, 0); !errors.Is(err, ErrNotifyRuleNotFound) {
+		t.Errorf("delete missing: err = %v, want ErrNotifyRuleNotFound", err)
+	}
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// MaxPinLabelLength is the maximum pin label length in characters.
+const MaxPinLabelLength = 200
+
+// ErrInvalidPin is returned when a pin request fails validation.
+var ErrInvalidPin = errors.New("invalid pin")
+
+// PinRequest pins either the instance session started by a world_join
+// event (EventID) or an explicit [Since, Until) range.
+type PinRequest struct {
+	EventID int64      `json:"event_id,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+	Until   *time.Time `json:"until,omitempty"`
+	Label   string     `json:"label,omitempty"`
+}
+
+// PinsUsecase defines pinning history so retention keeps it.
+type PinsUsecase interface {
+	// Add pins a session or date range. Returns ErrInvalidPin, or
+	// store.ErrNotFound if the event does not exist.
+	Add(ctx context.Context, req PinRequest) (*store.Pin, error)
+	List(ctx context.Context) ([]store.Pin, error)
+	// Delete unpins. Returns store.ErrNotFound if the pin does not exist.
+	Delete(ctx context.Context, id int64) error
+}
+
+// PinStore defines store operations needed by PinsService.
+type PinStore interface {
+	GetEvent(ctx context.Context, id int64) (*event.Event, error)
+	SessionEnd(ctx context.Context, e *event.Event) (*time.Time, error)
+	SavePin(ctx context.Context, p store.Pin) (*store.Pin, error)
+	ListPins(ctx context.Context) ([]store.Pin, error)
+	DeletePin(ctx context.Context, id int64) error
+}
+
+// PinsService implements PinsUsecase.
+type PinsService struct {
+	Store PinStore
+}
+
+// Add validates req and stores the pin.
+func (s *PinsService) Add(ctx context.Context, req PinRequest) (*store.Pin, error) {
+	label := strings.TrimSpace(req.Label)
+	if utf8.RuneCountInString(label) > MaxPinLabelLength {
+		return nil, fmt.Errorf("%w: label exceeds %d characters", ErrInvalidPin, MaxPinLabelLength)
+	}
+
+	pin := store.Pin{Label: label}
+	switch {
+	case req.EventID != 0:
+		if req.Since != nil || req.Until != nil {
+			return nil, fmt.Errorf("%w: give either event_id or since/until", ErrInvalidPin)
+		}
+		e, err := s.Store.GetEvent(ctx, req.EventID)
+		if err != nil {
+			return nil, err
+		}
+		if e.Type != event.TypeWorldJoin || e.WorldID == nil {
+			return nil, fmt.Errorf("%w: event %d does not start an instance session", ErrInvalidPin, req.EventID)
+		}
+		end, err := s.Store.SessionEnd(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		pin.EventID = e.ID
+		pin.Since = e.Ts
+		pin.Until = end
+	case req.Since != nil && req.Until != nil:
+		if !req.Since.Before(*req.Until) {
+			return nil, fmt.Errorf("%w: since must be before until", ErrInvalidPin)
+		}
+		pin.Since = *req.Since
+		pin.Until = req.Until
+	default:
+		return nil, fmt.Errorf("%w: event_id or both since and until are required", ErrInvalidPin)
+	}
+
+	return s.Store.SavePin(ctx, pin)
+}
+
+// List returns all pins.
+func (s *PinsService) List(ctx context.Context) ([]store.Pin, error) {
+	return s.Store.ListPins(ctx)
+}
+
+// Delete removes a pin.
+func (s *PinsService) Delete(ctx context.Context, id int64) error {
+	return s.Store.DeletePin(ctx, id)
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubPinStore is a test double for PinStore.
+type stubPinStore struct {
+	events map[int64]*event.Event
+	end    *time.Time
+	saved  []store.Pin
+}
+
+func (s *stubPinStore) GetEvent(ctx context.Context, id int64) (*event.Event, error) {
+	e, ok := s.events[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *stubPinStore) SessionEnd(ctx context.Context, e *event.Event) (*time.Time, error) {
+	return s.end, nil
+}
+
+func (s *stubPinStore) SavePin(ctx context.Context, p store.Pin) (*store.Pin, error) {
+	p.ID = int64(len(s.saved) + 1)
+	s.saved = append(s.saved, p)
+	return &p, nil
+}
+
+func (s *stubPinStore) ListPins(ctx context.Context) ([]store.Pin, error) {
+	return s.saved, nil
+}
+
+func (s *stubPinStore) DeletePin(ctx context.Context, id int64) error {
+	return nil
+}
+
+func TestPinsService_Add(t *testing.T) {
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	end := base.Add(time.Hour)
+	stub := &stubPinStore{
+		events: map[int64]*event.Event{
+			1: {ID: 1, Ts: base, Type: event.TypeWorldJoin, WorldID: event.StringPtr("wrld_a")},
+			2: {ID: 2, Ts: base, Type: event.TypeWorldJoin, WorldName: event.StringPtr("World A")},
+			3: {ID: 3, Ts: base, Type: event.TypePlayerJoin},
+		},
+		end: &end,
+	}
+	svc := &PinsService{Store: stub}
+	ctx := context.Background()
+
+	// A session runs from its world_join to the next one
+	p, err := svc.Add(ctx, PinRequest{EventID: 1, Label: "  party  "})
+	if err != nil {
+		t.Fatalf("Add session: %v", err)
+	}
+	if !p.Since.Equal(base) || p.Until == nil || !p.Until.Equal(end) || p.EventID != 1 || p.Label != "party" {
+		t.Errorf("session pin = %+v", p)
+	}
+
+	// An explicit range
+	since, until := base, base.Add(24*time.Hour)
+	if _, err := svc.Add(ctx, PinRequest{Since: &since, Until: &until}); err != nil {
+		t.Fatalf("Add range: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  PinRequest
+		want error
+	}{
+		{"missing event", PinRequest{EventID: 9}, store.ErrNotFound},
+		{"name line", PinRequest{EventID: 2}, ErrInvalidPin},
+		{"not world_join", PinRequest{EventID: 3}, ErrInvalidPin},
+		{"event and range", PinRequest{EventID: 1, Since: &since}, ErrInvalidPin},
+		{"missing until", PinRequest{Since: &since}, ErrInvalidPin},
+		{"empty range", PinRequest{Since: &until, Until: &since}, ErrInvalidPin},
+		{"empty request", PinRequest{}, ErrInvalidPin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Add(ctx, tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+	if len(stub.saved) != 2 {
+		t.Errorf("saved %d pins, want 2", len(stub.saved))
+	}
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// ErrInvalidPlayer is returned when a player lookup is malformed.
+var ErrInvalidPlayer = errors.New("invalid player")
+
+// PlayersUsecase defines the players directory and lookups of individual
+// players.
+type PlayersUsecase interface {
+	// List returns one page of the players directory.
+	// Returns ErrInvalidPlayer for an unknown sort order, or
+	// store.ErrInvalidCursor.
+	List(ctx context.Context, filter store.PlayerFilter) (store.PlayerPage, error)
+
+	// LastSeen returns the most recent sighting of a player, given by ID or
+	// display name (see event.NormalizeName). An empty account covers all
+	// accounts.
+	// Returns ErrInvalidPlayer, or store.ErrNotFound if never seen.
+	LastSeen(ctx context.Context, player, account string) (*store.LastSeen, error)
+
+	// Profile aggregates what is known about a player, given by ID or
+	// display name (see event.NormalizeName). An empty account covers all
+	// accounts.
+	// Returns ErrInvalidPlayer, or store.ErrNotFound if never seen.
+	Profile(ctx context.Context, player, account string) (*PlayerProfile, error)
+
+	// Links returns the links from display names to player IDs, both
+	// inferred and set by hand.
+	Links(ctx context.Context) ([]store.PlayerLink, error)
+
+	// Merge links a display name to a player ID, so that the name's
+	// name-only events count towards that player.
+	// Returns ErrInvalidPlayer for a blank name or a malformed ID.
+	Merge(ctx context.Context, name, playerID string) (*store.PlayerLink, error)
+
+	// Unlink removes the link of a display name and keeps it from being
+	// inferred again.
+	// Returns ErrInvalidPlayer, or store.ErrNotFound if the name is not linked.
+	Unlink(ctx context.Context, name string) error
+}
+
+// PlayerStore defines store operations needed by PlayersService.
+type PlayerStore interface {
+	ListPlayers(ctx context.Context, filter store.PlayerFilter) (store.PlayerPage, error)
+	LastSeen(ctx context.Context, player, account string) (*store.LastSeen, error)
+	PlayerProfile(ctx context.Context, player, account string) (*store.PlayerProfile, error)
+	ListPlayerLinks(ctx context.Context) ([]store.PlayerLink, error)
+	LinkPlayer(ctx context.Context, name, playerID string) (*store.PlayerLink, error)
+	UnlinkPlayer(ctx context.Context, name string) error
+}
+
+// PlayersService implements PlayersUsecase.
+type PlayersService struct {
+	Store PlayerStore
+	// PlayerTags maps tags to player IDs or display names, as in
+	// config.Config.PlayerTags.
+	PlayerTags map[string][]string
+}
+
+// PlayerProfile is a player's profile with the tags they are listed under.
+type PlayerProfile struct {
+	store.PlayerProfile
+	Tags []string `json:"tags"`
+}
+
+// List returns one page of the players directory.
+func (s *PlayersService) List(ctx context.Context, filter store.PlayerFilter) (store.PlayerPage, error) {
+	switch filter.Sort {
+	case "", store.PlayerSortLastSeen, store.PlayerSortTotalTime:
+	default:
+		return store.PlayerPage{}, fmt.Errorf("%w: unknown sort %q", ErrInvalidPlayer, filter.Sort)
+	}
+	filter.Search = strings.TrimSpace(filter.Search)
+	return s.Store.ListPlayers(ctx, filter)
+}
+
+// LastSeen looks up the most recent sighting of a player.
+func (s *PlayersService) LastSeen(ctx context.Context, player, account string) (*store.LastSeen, error) {
+	player = strings.TrimSpace(player)
+	if player == "" {
+		return nil, fmt.Errorf("%w: player is required", ErrInvalidPlayer)
+	}
+	return s.Store.LastSeen(ctx, player, account)
+}
+
+// Profile builds the profile of a player.
+func (s *PlayersService) Profile(ctx context.Context, player, account string) (*PlayerProfile, error) {
+	player = strings.TrimSpace(player)
+	if player == "" {
+		return nil, fmt.Errorf("%w: player is required", ErrInvalidPlayer)
+	}
+	p, err := s.Store.PlayerProfile(ctx, player, account)
+	if err != nil {
+		return nil, err
+	}
+	return &PlayerProfile{PlayerProfile: *p, Tags: s.tagsOf(p)}, nil
+}
+
+// Links returns all player links.
+func (s *PlayersService) Links(ctx context.Context) ([]store.PlayerLink, error) {
+	return s.Store.ListPlayerLinks(ctx)
+}
+
+// Merge links a display name to a player ID by hand.
+func (s *PlayersService) Merge(ctx context.Context, name, playerID string) (*store.PlayerLink, error) {
+	name, playerID = strings.TrimSpace(name), strings.TrimSpace(playerID)
+	if name == "" {
+		return nil, fmt.Errorf("%w: player_name is required", ErrInvalidPlayer)
+	}
+	if !strings.HasPrefix(playerID, "usr_") {
+		return nil, fmt.Errorf("%w: player_id must be a usr_ ID", ErrInvalidPlayer)
+	}
+	return s.Store.LinkPlayer(ctx, name, playerID)
+}
+
+// Unlink removes the link of a display name.
+func (s *PlayersService) Unlink(ctx context.Context, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%w: player_name is required", ErrInvalidPlayer)
+	}
+	return s.Store.UnlinkPlayer(ctx, name)
+}
+
+// tagsOf returns the sorted tags listing the player by ID or by any of
+// their display names.
+func (s *PlayersService) tagsOf(p *store.PlayerProfile) []string {
+	tags := []string{}
+	for tag, players := range s.PlayerTags {
+		for _, key := range players {
+			if (p.PlayerID != "" && key == p.PlayerID) || slices.Contains(p.Names, key) {
+				tags = append(tags, tag)
+				break
+			}
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
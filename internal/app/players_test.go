@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubPlayerStore struct {
+	player, account string
+	filter          store.PlayerFilter
+	name, playerID  string
+}
+
+func (s *stubPlayerStore) ListPlayers(ctx context.Context, filter store.PlayerFilter) (store.PlayerPage, error) {
+	s.filter = filter
+	return store.PlayerPage{}, nil
+}
+
+func (s *stubPlayerStore) LastSeen(ctx context.Context, player, account string) (*store.LastSeen, error) {
+	s.player, s.account = player, account
+	return &store.LastSeen{}, nil
+}
+
+func (s *stubPlayerStore) PlayerProfile(ctx context.Context, player, account string) (*store.PlayerProfile, error) {
+	s.player, s.account = player, account
+	return &store.PlayerProfile{PlayerName: "Alicia", PlayerID: "usr_alice", Names: []string{"Alice", "Alicia"}}, nil
+}
+
+func (s *stubPlayerStore) ListPlayerLinks(ctx context.Context) ([]store.PlayerLink, error) {
+	return []store.PlayerLink{}, nil
+}
+
+func (s *stubPlayerStore) LinkPlayer(ctx context.Context, name, playerID string) (*store.PlayerLink, error) {
+	s.name, s.playerID = name, playerID
+	return &store.PlayerLink{PlayerName: name, PlayerID: playerID, Manual: true}, nil
+}
+
+func (s *stubPlayerStore) UnlinkPlayer(ctx context.Context, name string) error {
+	s.name = name
+	return nil
+}
+
+func TestPlayersService_Merge(t *testing.T) {
+	st := &stubPlayerStore{}
+	svc := &PlayersService{Store: st}
+
+	for _, tt := range []struct{ name, id string }{{" ", "usr_a"}, {"Alice", ""}, {"Alice", "Bob"}} {
+		if _, err := svc.Merge(context.Background(), tt.name, tt.id); !errors.Is(err, ErrInvalidPlayer) {
+			t.Errorf("Merge(%q, %q): err = %v, want ErrInvalidPlayer", tt.name, tt.id, err)
+		}
+	}
+	if _, err := svc.Merge(context.Background(), " Alice ", " usr_a "); err != nil {
+		t.Fatal(err)
+	}
+	if st.name != "Alice" || st.playerID != "usr_a" {
+		t.Errorf("store got %q, %q", st.name, st.playerID)
+	}
+
+	if err := svc.Unlink(context.Background(), ""); !errors.Is(err, ErrInvalidPlayer) {
+		t.Errorf("Unlink blank: err = %v, want ErrInvalidPlayer", err)
+	}
+}
+
+func TestPlayersService_List(t *testing.T) {
+	st := &stubPlayerStore{}
+	svc := &PlayersService{Store: st}
+
+	if _, err := svc.List(context.Background(), store.PlayerFilter{Sort: "name"}); !errors.Is(err, ErrInvalidPlayer) {
+		t.Errorf("unknown sort: err = %v, want ErrInvalidPlayer", err)
+	}
+	if _, err := svc.List(context.Background(), store.PlayerFilter{Search: " ali ", Sort: store.PlayerSortTotalTime}); err != nil {
+		t.Fatal(err)
+	}
+	if st.filter.Search != "ali" || st.filter.Sort != store.PlayerSortTotalTime {
+		t.Errorf("store got %+v", st.filter)
+	}
+}
+
+func TestPlayersService_LastSeen(t *testing.T) {
+	st := &stubPlayerStore{}
+	svc := &PlayersService{Store: st}
+
+	if _, err := svc.LastSeen(context.Background(), "  ", ""); !errors.Is(err, ErrInvalidPlayer) {
+		t.Errorf("blank player: err = %v, want ErrInvalidPlayer", err)
+	}
+	if _, err := svc.LastSeen(context.Background(), " Alice ", "alt"); err != nil {
+		t.Fatal(err)
+	}
+	if st.player != "Alice" || st.account != "alt" {
+		t.Errorf("store got (%q, %q), want (Alice, alt)", st.player, st.account)
+	}
+}
+
+func TestPlayersService_Profile(t *testing.T) {
+	st := &stubPlayerStore{}
+	svc := &PlayersService{Store: st, PlayerTags: map[string][]string{
+		"friends": {"usr_alice"},
+		"club":    {"Bob", "Alice"},
+		"work":    {"Carol"},
+	}}
+
+	if _, err := svc.Profile(context.Background(), "", ""); !errors.Is(err, ErrInvalidPlayer) {
+		t.Errorf("blank player: err = %v, want ErrInvalidPlayer", err)
+	}
+	p, err := svc.Profile(context.Background(), " usr_alice ", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.player != "usr_alice" {
+		t.Errorf("store got %q, want usr_alice", st.player)
+	}
+	if strings.Join(p.Tags, ",") != "club,friends" {
+		t.Errorf("tags = %v, want [club friends]", p.Tags)
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
+
+// MaxReceiveBatch is the maximum number of events accepted per Receive call.
+const MaxReceiveBatch = 500
+
+// ErrInvalidForwardedEvent is returned when a forwarded batch is empty, too
+// large, or contains an event that fails validation.
+var ErrInvalidForwardedEvent = errors.New("invalid forwarded event")
+
+// ReceiveUsecase defines the use case for events forwarded by remote agents
+// (vrclog --forward-to).
+type ReceiveUsecase interface {
+	// Receive stores the events and runs them through derive, notify and
+	// SSE like locally read ones. Already stored events are skipped, so a
+	// batch can safely be retried. Returns ErrInvalidForwardedEvent if the
+	// batch is rejected; nothing is stored in that case.
+	Receive(ctx context.Context, events []ingest.Event) (ReceiveResult, error)
+}
+
+// EventReceiver defines the ingester operation needed by ReceiveService.
+type EventReceiver interface {
+	Ingest(ctx context.Context, ev ingest.Event) (bool, error)
+}
+
+// ReceiveResult reports how many forwarded events were new.
+type ReceiveResult struct {
+	Received int `json:"received"`
+	Inserted int `json:"inserted"` // excludes duplicates
+}
+
+// ReceiveService implements ReceiveUsecase.
+type ReceiveService struct {
+	Ingester EventReceiver
+}
+
+// Receive validates the whole batch, then ingests it in order.
+func (s ReceiveService) Receive(ctx context.Context, events []ingest.Event) (ReceiveResult, error) {
+	if len(events) == 0 || len(events) > MaxReceiveBatch {
+		return ReceiveResult{}, fmt.Errorf("%w: batch must have 1 to %d events", ErrInvalidForwardedEvent, MaxReceiveBatch)
+	}
+	for i, ev := range events {
+		if err := validateForwardedEvent(ev); err != nil {
+			return ReceiveResult{}, fmt.Errorf("%w: event %d: %v", ErrInvalidForwardedEvent, i, err)
+		}
+	}
+
+	result := ReceiveResult{Received: len(events)}
+	for _, ev := range events {
+		inserted, err := s.Ingester.Ingest(ctx, ev)
+		if err != nil {
+			return result, err
+		}
+		if inserted {
+			result.Inserted++
+		}
+	}
+	return result, nil
+}
+
+// validateForwardedEvent checks the fields the store and dedupe key rely on.
+func validateForwardedEvent(ev ingest.Event) error {
+	if !event.IsValidType(ev.Type) {
+		return fmt.Errorf("unknown type %q", ev.Type)
+	}
+	if ev.Timestamp.IsZero() {
+		return errors.New("ts is required")
+	}
+	if ev.RawLine == "" {
+		return errors.New("raw_line is required")
+	}
+	return nil
+}
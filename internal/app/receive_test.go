@@ -0,0 +1,58 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
+
+// stubEventReceiver treats every event as new and records it.
+type stubEventReceiver struct {
+	events []ingest.Event
+}
+
+func (r *stubEventReceiver) Ingest(ctx context.Context, ev ingest.Event) (bool, error) {
+	r.events = append(r.events, ev)
+	return true, nil
+}
+
+func TestReceiveService_Receive(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	valid := ingest.Event{Type: "player_join", Timestamp: ts, PlayerName: "Alice", RawLine: "line"}
+
+	recv := &stubEventReceiver{}
+	svc := ReceiveService{Ingester: recv}
+	result, err := svc.Receive(context.Background(), []ingest.Event{valid})
+	if err != nil {
+		t.Fatalf("Receive: %v", err)
+	}
+	if result != (ReceiveResult{Received: 1, Inserted: 1}) || len(recv.events) != 1 {
+		t.Errorf("result = %+v, ingested %d", result, len(recv.events))
+	}
+
+	tests := []struct {
+		name   string
+		events []ingest.Event
+	}{
+		{"empty batch", nil},
+		{"too many", make([]ingest.Event, MaxReceiveBatch+1)},
+		{"unknown type", []ingest.Event{valid, {Type: "bogus", Timestamp: ts, RawLine: "x"}}},
+		{"missing ts", []ingest.Event{{Type: "player_join", RawLine: "x"}}},
+		{"missing raw line", []ingest.Event{{Type: "player_join", Timestamp: ts}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := &stubEventReceiver{}
+			_, err := ReceiveService{Ingester: recv}.Receive(context.Background(), tt.events)
+			if !errors.Is(err, ErrInvalidForwardedEvent) {
+				t.Errorf("err = %v, want ErrInvalidForwardedEvent", err)
+			}
+			if len(recv.events) != 0 {
+				t.Errorf("ingested %d events from a rejected batch", len(recv.events))
+			}
+		})
+	}
+}
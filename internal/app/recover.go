@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Default delays before restarting a background loop that panicked.
+const (
+	DefaultRecoverMinBackoff = time.Second
+	DefaultRecoverMaxBackoff = time.Minute
+)
+
+// PanicError describes a panic recovered from a background loop.
+type PanicError struct {
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Recoverer runs background loops such as the ingester, notifier and SSE
+// hubs, restarting a loop after a panic instead of letting one bad event
+// take down the app or silently stop ingestion.
+type Recoverer struct {
+	MinBackoff time.Duration // 0 means DefaultRecoverMinBackoff
+	MaxBackoff time.Duration // 0 means DefaultRecoverMaxBackoff
+	Logger     *slog.Logger  // nil means slog.Default()
+
+	// OnPanic is called after each panic, before waiting to restart;
+	// may be nil
+	OnPanic func(name string, p *PanicError)
+}
+
+// Run calls run until it returns without panicking, and returns its error.
+// After a panic the stack trace is logged, OnPanic is called and run is
+// restarted after a delay that doubles with each panic, up to MaxBackoff.
+// The delay is reset once run has kept going for MaxBackoff. Returns
+// ctx.Err() if ctx is done while waiting to restart.
+func (r *Recoverer) Run(ctx context.Context, name string, run func(context.Context) error) error {
+	minBackoff, maxBackoff := r.MinBackoff, r.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultRecoverMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultRecoverMaxBackoff
+	}
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		err := callRecovered(ctx, run)
+		p, ok := err.(*PanicError)
+		if !ok {
+			return err
+		}
+		if time.Since(started) >= maxBackoff {
+			backoff, attempt = minBackoff, 1
+		}
+
+		logger.Error("subsystem panicked, restarting",
+			"subsystem", name,
+			"panic", fmt.Sprint(p.Value),
+			"attempt", attempt,
+			"backoff", backoff,
+			"stack", string(p.Stack),
+		)
+		if r.OnPanic != nil {
+			err := callRecovered(ctx, func(context.Context) error {
+				r.OnPanic(name, p)
+				return nil
+			})
+			if err != nil {
+				logger.Error("panic handler failed", "subsystem", name, "error", err)
+			}
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// callRecovered calls run, returning a *PanicError if it panicked.
+func callRecovered(ctx context.Context, run func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return run(ctx)
+}
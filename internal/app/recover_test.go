@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecoverer_RestartsAfterPanic(t *testing.T) {
+	var panics []string
+	r := &Recoverer{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: 4 * time.Millisecond,
+		OnPanic: func(name string, p *PanicError) {
+			if !strings.Contains(string(p.Stack), "TestRecoverer_RestartsAfterPanic") {
+				t.Errorf("stack does not include the panicking function:\n%s", p.Stack)
+			}
+			panics = append(panics, name+": "+p.Error())
+		},
+	}
+
+	calls := 0
+	wantErr := errors.New("source closed")
+	err := r.Run(context.Background(), "ingester", func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			panic("bad event")
+		}
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Run = %v, want %v", err, wantErr)
+	}
+	if calls != 3 {
+		t.Errorf("run called %d times, want 3", calls)
+	}
+	if len(panics) != 2 || panics[0] != "ingester: panic: bad event" {
+		t.Errorf("OnPanic calls = %q", panics)
+	}
+}
+
+func TestRecoverer_StopsWhileWaiting(t *testing.T) {
+	r := &Recoverer{
+		MinBackoff: time.Hour,
+		OnPanic:    func(string, *PanicError) { panic("handler") }, // logged, not fatal
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, "hub", func(context.Context) error { panic("boom") })
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// Screenshot is a screenshot taken in VRChat, linked to the world and
+// instance the user was in at the time.
+type Screenshot struct {
+	ID         int64     `json:"id"`
+	Ts         time.Time `json:"ts"`
+	Path       string    `json:"path"`
+	WorldID    *string   `json:"world_id,omitempty"`
+	WorldName  *string   `json:"world_name,omitempty"`
+	InstanceID *string   `json:"instance_id,omitempty"`
+}
+
+// ScreenshotPage is one page of screenshots.
+type ScreenshotPage struct {
+	Items      []Screenshot `json:"items"`
+	NextCursor *string      `json:"next_cursor,omitempty"`
+}
+
+// ScreenshotsUsecase defines screenshot listing and lookup.
+type ScreenshotsUsecase interface {
+	// List returns screenshots matching filter. filter.Type is ignored.
+	List(ctx context.Context, filter store.QueryFilter) (ScreenshotPage, error)
+	// Get returns a single screenshot, or store.ErrNotFound.
+	Get(ctx context.Context, id int64) (*Screenshot, error)
+}
+
+// ScreenshotStore defines store operations needed by ScreenshotsService.
+type ScreenshotStore interface {
+	QueryEvents(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error)
+	GetEvent(ctx context.Context, id int64) (*event.Event, error)
+}
+
+// ScreenshotsService implements ScreenshotsUsecase on top of screenshot events.
+type ScreenshotsService struct {
+	Store ScreenshotStore
+}
+
+// List returns screenshots matching filter, newest first by default.
+func (s *ScreenshotsService) List(ctx context.Context, filter store.QueryFilter) (ScreenshotPage, error) {
+	typ := event.TypeScreenshot
+	filter.Type = &typ
+
+	result, err := s.Store.QueryEvents(ctx, filter)
+	if err != nil {
+		return ScreenshotPage{}, err
+	}
+
+	page := ScreenshotPage{Items: make([]Screenshot, 0, len(result.Items)), NextCursor: result.NextCursor}
+	for i := range result.Items {
+		shot, err := toScreenshot(&result.Items[i])
+		if err != nil {
+			return ScreenshotPage{}, err
+		}
+		page.Items = append(page.Items, *shot)
+	}
+	return page, nil
+}
+
+// Get returns the screenshot with the given event ID.
+func (s *ScreenshotsService) Get(ctx context.Context, id int64) (*Screenshot, error) {
+	e, err := s.Store.GetEvent(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != event.TypeScreenshot {
+		return nil, store.ErrNotFound
+	}
+	return toScreenshot(e)
+}
+
+// toScreenshot converts a screenshot event, reading the path from its meta.
+func toScreenshot(e *event.Event) (*Screenshot, error) {
+	var meta struct {
+		Path string `json:"path"`
+	}
+	if len(e.MetaJSON) > 0 {
+		if err := json.Unmarshal(e.MetaJSON, &meta); err != nil {
+			return nil, fmt.Errorf("decode screenshot %d meta: %w", e.ID, err)
+		}
+	}
+	return &Screenshot{
+		ID:         e.ID,
+		Ts:         e.Ts,
+		Path:       meta.Path,
+		WorldID:    e.WorldID,
+		WorldName:  e.WorldName,
+		InstanceID: e.InstanceID,
+	}, nil
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SessionsUsecase defines the state of web UI sessions kept across
+// restarts: the session generation and the sessions logged out.
+type SessionsUsecase interface {
+	// Generation returns the session generation. Sessions are signed with
+	// a key derived from it, so a new generation ends every session. It is
+	// cheap enough to call on every request.
+	Generation(ctx context.Context) (int64, error)
+	// LogoutAll ends every session and returns the new generation.
+	LogoutAll(ctx context.Context) (int64, error)
+	// Revoke records that the session with the given token hash was
+	// logged out, until it expires.
+	Revoke(ctx context.Context, tokenHash string, expiresAt time.Time) error
+	// Revoked returns the token hashes of the sessions logged out and not
+	// yet expired at now, with their expiry.
+	Revoked(ctx context.Context, now time.Time) (map[string]time.Time, error)
+}
+
+// SessionStore defines store operations needed by SessionsService.
+type SessionStore interface {
+	SessionGeneration(ctx context.Context) (int64, error)
+	BumpSessionGeneration(ctx context.Context) (int64, error)
+	RevokeSession(ctx context.Context, tokenHash string, expiresAt time.Time) error
+	RevokedSessions(ctx context.Context, now time.Time) (map[string]time.Time, error)
+}
+
+// SessionsService implements SessionsUsecase. It keeps the generation in
+// memory once read, so the server can check it on every request, and
+// LogoutAll through any holder of the service, such as ConfigService on a
+// password change, is seen by the server at once.
+type SessionsService struct {
+	Store SessionStore
+
+	mu     sync.Mutex
+	gen    int64
+	loaded bool
+}
+
+// Generation returns the session generation.
+func (s *SessionsService) Generation(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded {
+		gen, err := s.Store.SessionGeneration(ctx)
+		if err != nil {
+			return 0, err
+		}
+		s.gen, s.loaded = gen, true
+	}
+	return s.gen, nil
+}
+
+// LogoutAll bumps the session generation.
+func (s *SessionsService) LogoutAll(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gen, err := s.Store.BumpSessionGeneration(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.gen, s.loaded = gen, true
+	return gen, nil
+}
+
+// Revoke stores the revocation.
+func (s *SessionsService) Revoke(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	return s.Store.RevokeSession(ctx, tokenHash, expiresAt)
+}
+
+// Revoked returns the stored revocations.
+func (s *SessionsService) Revoked(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	return s.Store.RevokedSessions(ctx, now)
+}
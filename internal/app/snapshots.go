@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// Defaults for SnapshotService.
+const (
+	DefaultSnapshotInterval  = time.Minute
+	DefaultSnapshotRetention = 90 * 24 * time.Hour
+)
+
+// SnapshotsUsecase defines the derived state history use case.
+type SnapshotsUsecase interface {
+	// History returns state snapshots taken in [since, until), oldest first.
+	// An empty account covers all accounts.
+	History(ctx context.Context, since, until time.Time, account string, limit int) ([]store.StateSnapshot, error)
+}
+
+// SnapshotStore defines the store operations for state snapshots.
+type SnapshotStore interface {
+	SaveStateSnapshot(ctx context.Context, snap store.StateSnapshot) (int64, error)
+	LatestStateSnapshot(ctx context.Context, account string) (*store.StateSnapshot, error)
+	ListStateSnapshots(ctx context.Context, since, until time.Time, account string, limit int) ([]store.StateSnapshot, error)
+	PruneStateSnapshots(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+// SnapshotService periodically saves the state of each account to the store,
+// restores it on startup, and serves the saved history.
+type SnapshotService struct {
+	State     *derive.MultiState
+	Store     SnapshotStore
+	Interval  time.Duration // 0 means DefaultSnapshotInterval
+	Retention time.Duration // 0 means DefaultSnapshotRetention
+	Logger    *slog.Logger  // nil means slog.Default()
+
+	last map[string]*store.StateSnapshot // last saved per account, to skip unchanged states
+}
+
+// Restore loads the latest snapshot of each account known to State.
+// Accounts without a saved snapshot are left as they are.
+func (s *SnapshotService) Restore(ctx context.Context) error {
+	for _, account := range s.State.Accounts() {
+		if err := s.restore(ctx, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SnapshotService) restore(ctx context.Context, account string) error {
+	snap, err := s.Store.LatestStateSnapshot(ctx, account)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	restored := derive.Snapshot{PeakPlayers: snap.PeakPlayers}
+	if snap.WorldID != "" || snap.WorldName != "" {
+		restored.World = &derive.WorldInfo{
+			WorldID:    snap.WorldID,
+			WorldName:  snap.WorldName,
+			InstanceID: snap.InstanceID,
+		}
+		if snap.JoinedAt != nil {
+			restored.World.JoinedAt = *snap.JoinedAt
+		}
+	}
+	for _, p := range snap.Players {
+		restored.Players = append(restored.Players, derive.PlayerInfo{
+			PlayerName: p.PlayerName,
+			PlayerID:   p.PlayerID,
+			JoinedAt:   p.JoinedAt,
+		})
+	}
+	s.State.For(account).Restore(restored)
+	s.remember(snap)
+	return nil
+}
+
+// Run saves a snapshot every Interval until ctx is cancelled.
+// Snapshots identical to the previous one are skipped.
+func (s *SnapshotService) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSnapshotInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(ctx); err != nil && ctx.Err() == nil {
+				s.logger().Warn("failed to save state snapshot", "error", err)
+			}
+		}
+	}
+}
+
+// Snapshot saves the state of each account that changed since its last
+// snapshot.
+func (s *SnapshotService) Snapshot(ctx context.Context) error {
+	now := time.Now().UTC()
+	for _, account := range s.State.Accounts() {
+		snap := snapshotFromState(s.State.For(account).Snapshot(), now)
+		snap.Account = account
+		if last := s.last[account]; last != nil && sameSnapshot(last, &snap) {
+			continue
+		}
+		id, err := s.Store.SaveStateSnapshot(ctx, snap)
+		if err != nil {
+			return err
+		}
+		snap.ID = id
+		s.remember(&snap)
+	}
+	return nil
+}
+
+// Jobs returns the scheduled job pruning snapshots older than Retention on
+// startup and daily.
+func (s *SnapshotService) Jobs() []schedule.Job {
+	return []schedule.Job{{
+		Name:       "snapshot_prune",
+		Schedule:   schedule.Every(24 * time.Hour),
+		RunAtStart: true,
+		Run:        s.Prune,
+	}}
+}
+
+// Prune deletes snapshots older than Retention, except pinned ones.
+func (s *SnapshotService) Prune(ctx context.Context) error {
+	retention := s.Retention
+	if retention <= 0 {
+		retention = DefaultSnapshotRetention
+	}
+	_, err := s.Store.PruneStateSnapshots(ctx, time.Now().UTC().Add(-retention))
+	return err
+}
+
+// History returns state snapshots taken in [since, until), oldest first.
+func (s *SnapshotService) History(ctx context.Context, since, until time.Time, account string, limit int) ([]store.StateSnapshot, error) {
+	return s.Store.ListStateSnapshots(ctx, since, until, account, limit)
+}
+
+func (s *SnapshotService) remember(snap *store.StateSnapshot) {
+	if s.last == nil {
+		s.last = make(map[string]*store.StateSnapshot)
+	}
+	s.last[snap.Account] = snap
+}
+
+func (s *SnapshotService) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+func snapshotFromState(d derive.Snapshot, ts time.Time) store.StateSnapshot {
+	snap := store.StateSnapshot{
+		Ts:          ts,
+		PlayerCount: len(d.Players),
+		PeakPlayers: d.PeakPlayers,
+		Players:     make([]store.SnapshotPlayer, 0, len(d.Players)),
+	}
+	if d.World != nil {
+		snap.WorldID = d.World.WorldID
+		snap.WorldName = d.World.WorldName
+		snap.InstanceID = d.World.InstanceID
+		if !d.World.JoinedAt.IsZero() {
+			joinedAt := d.World.JoinedAt
+			snap.JoinedAt = &joinedAt
+		}
+	}
+	for _, p := range d.Players {
+		snap.Players = append(snap.Players, store.SnapshotPlayer{
+			PlayerName: p.PlayerName,
+			PlayerID:   p.PlayerID,
+			JoinedAt:   p.JoinedAt,
+		})
+	}
+	return snap
+}
+
+// sameSnapshot reports whether a and b describe the same world and players,
+// ignoring when they were taken.
+func sameSnapshot(a, b *store.StateSnapshot) bool {
+	if a.Account != b.Account || a.WorldID != b.WorldID || a.WorldName != b.WorldName || a.InstanceID != b.InstanceID ||
+		a.PeakPlayers != b.PeakPlayers || len(a.Players) != len(b.Players) {
+		return false
+	}
+	if (a.JoinedAt == nil) != (b.JoinedAt == nil) || (a.JoinedAt != nil && !a.JoinedAt.Equal(*b.JoinedAt)) {
+		return false
+	}
+	players := make(map[store.SnapshotPlayer]bool, len(a.Players))
+	for _, p := range a.Players {
+		p.JoinedAt = p.JoinedAt.UTC()
+		players[p] = true
+	}
+	for _, p := range b.Players {
+		p.JoinedAt = p.JoinedAt.UTC()
+		if !players[p] {
+			return false
+		}
+	}
+	return true
+}
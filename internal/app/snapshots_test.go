@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// stubSnapshotStore is an in-memory SnapshotStore.
+type stubSnapshotStore struct {
+	saved  []store.StateSnapshot
+	pruned int
+}
+
+func (s *stubSnapshotStore) SaveStateSnapshot(ctx context.Context, snap store.StateSnapshot) (int64, error) {
+	snap.ID = int64(len(s.saved) + 1)
+	s.saved = append(s.saved, snap)
+	return snap.ID, nil
+}
+
+func (s *stubSnapshotStore) LatestStateSnapshot(ctx context.Context, account string) (*store.StateSnapshot, error) {
+	for i := len(s.saved) - 1; i >= 0; i-- {
+		if s.saved[i].Account == account {
+			snap := s.saved[i]
+			return &snap, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *stubSnapshotStore) ListStateSnapshots(ctx context.Context, since, until time.Time, account string, limit int) ([]store.StateSnapshot, error) {
+	return s.saved, nil
+}
+
+func (s *stubSnapshotStore) PruneStateSnapshots(ctx context.Context, cutoff time.Time) (int64, error) {
+	s.pruned++
+	return 0, nil
+}
+
+func TestSnapshotService_SaveAndRestore(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubSnapshotStore{}
+	ts := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	state := derive.NewMulti()
+	state.Update(&event.Event{Type: event.TypeWorldJoin, Ts: ts,
+		WorldID: event.StringPtr("wrld_a"), WorldName: event.StringPtr("World A")})
+	state.Update(&event.Event{Type: event.TypePlayerJoin, Ts: ts, PlayerName: event.StringPtr("Alice")})
+
+	svc := &SnapshotService{State: state, Store: stub}
+	if err := svc.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	// Unchanged state is not saved again
+	if err := svc.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(stub.saved) != 1 {
+		t.Fatalf("expected 1 saved snapshot, got %d", len(stub.saved))
+	}
+	if stub.pruned != 0 {
+		t.Errorf("Snapshot pruned %d times; pruning is a scheduled job", stub.pruned)
+	}
+	if err := svc.Prune(ctx); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if stub.pruned != 1 {
+		t.Errorf("expected 1 prune, got %d", stub.pruned)
+	}
+
+	// A fresh state restored from the store matches the original
+	restored := derive.NewMulti()
+	if err := (&SnapshotService{State: restored, Store: stub}).Restore(ctx); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	w := restored.For("").CurrentWorld()
+	if w == nil || w.WorldName != "World A" || !w.JoinedAt.Equal(ts) {
+		t.Errorf("restored world = %+v", w)
+	}
+	if players := restored.For("").CurrentPlayers(); len(players) != 1 || players[0].PlayerName != "Alice" {
+		t.Errorf("restored players = %+v", players)
+	}
+}
+
+func TestSnapshotService_PerAccount(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubSnapshotStore{}
+	ts := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	state := derive.NewMulti()
+	state.Update(&event.Event{Type: event.TypeWorldJoin, Ts: ts,
+		WorldID: event.StringPtr("wrld_a"), WorldName: event.StringPtr("World A")})
+	state.Update(&event.Event{Type: event.TypeWorldJoin, Ts: ts, Account: event.StringPtr("alt"),
+		WorldID: event.StringPtr("wrld_b"), WorldName: event.StringPtr("World B")})
+
+	svc := &SnapshotService{State: state, Store: stub}
+	if err := svc.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(stub.saved) != 2 {
+		t.Fatalf("expected 2 saved snapshots, got %d", len(stub.saved))
+	}
+
+	// Only the account that changed gets a new snapshot
+	state.Update(&event.Event{Type: event.TypePlayerJoin, Ts: ts, Account: event.StringPtr("alt"),
+		PlayerName: event.StringPtr("Bob")})
+	if err := svc.Snapshot(ctx); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if len(stub.saved) != 3 || stub.saved[2].Account != "alt" {
+		t.Fatalf("saved = %+v", stub.saved)
+	}
+
+	// Restore fills the accounts the state knows about
+	restored := derive.NewMulti()
+	restored.For("alt")
+	if err := (&SnapshotService{State: restored, Store: stub}).Restore(ctx); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if w := restored.For("").CurrentWorld(); w == nil || w.WorldName != "World A" {
+		t.Errorf("default world = %+v", w)
+	}
+	if w := restored.For("alt").CurrentWorld(); w == nil || w.WorldName != "World B" {
+		t.Errorf("alt world = %+v", w)
+	}
+	if n := restored.For("alt").PlayerCount(); n != 1 {
+		t.Errorf("alt players = %d, want 1", n)
+	}
+}
+
+func TestSnapshotService_RestoreEmpty(t *testing.T) {
+	state := derive.NewMulti()
+	svc := &SnapshotService{State: state, Store: &stubSnapshotStore{}}
+	if err := svc.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if state.CurrentWorld() != nil {
+		t.Error("expected no world after restoring from an empty store")
+	}
+}
@@ -2,14 +2,18 @@ package app
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 
 	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
 // StateUsecase defines the current state use case.
 type StateUsecase interface {
-	// GetCurrentState returns the current world and players.
-	GetCurrentState(ctx context.Context) StateResult
+	// GetCurrentState returns the current world and players of account.
+	// The default account is "".
+	GetCurrentState(ctx context.Context, account string) StateResult
 }
 
 // StateResult represents the current state response.
@@ -18,15 +22,71 @@ type StateResult struct {
 	Players []derive.PlayerInfo `json:"players"`
 }
 
-// StateService implements StateUsecase by wrapping derive.State.
+// CurrentInstanceStore defines the store operation StateService reads the
+// current instance with.
+type CurrentInstanceStore interface {
+	CurrentInstance(ctx context.Context, account string) (*store.CurrentInstance, error)
+}
+
+// StateService implements StateUsecase. With a Store it reads the current
+// instance the store keeps in step with inserted events, which survives
+// restarts and never scans events; otherwise, or if the store fails, it
+// reads derive.MultiState.
 type StateService struct {
-	State *derive.State
+	State  *derive.MultiState
+	Store  CurrentInstanceStore // optional
+	Logger *slog.Logger         // nil means slog.Default()
 }
 
-// GetCurrentState returns the current world and player list.
-func (s StateService) GetCurrentState(ctx context.Context) StateResult {
+// GetCurrentState returns the current world and player list of account.
+// An account with no events yet has no world and no players.
+func (s StateService) GetCurrentState(ctx context.Context, account string) StateResult {
+	if s.Store != nil {
+		ci, err := s.Store.CurrentInstance(ctx, account)
+		switch {
+		case err == nil:
+			return stateFromInstance(ci)
+		case errors.Is(err, store.ErrNotFound):
+			return StateResult{Players: []derive.PlayerInfo{}}
+		default:
+			s.logger().Warn("failed to read current instance, using in-memory state", "error", err)
+		}
+	}
+
+	state := s.State.Lookup(account)
+	if state == nil {
+		return StateResult{Players: []derive.PlayerInfo{}}
+	}
 	return StateResult{
-		World:   s.State.CurrentWorld(),
-		Players: s.State.CurrentPlayers(),
+		World:   state.CurrentWorld(),
+		Players: state.CurrentPlayers(),
+	}
+}
+
+func (s StateService) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// stateFromInstance converts a stored current instance.
+func stateFromInstance(ci *store.CurrentInstance) StateResult {
+	result := StateResult{
+		World: &derive.WorldInfo{
+			WorldID:    ci.WorldID,
+			WorldName:  ci.WorldName,
+			InstanceID: ci.InstanceID,
+			JoinedAt:   ci.JoinedAt,
+		},
+		Players: make([]derive.PlayerInfo, 0, len(ci.Players)),
+	}
+	for _, p := range ci.Players {
+		result.Players = append(result.Players, derive.PlayerInfo{
+			PlayerName: p.PlayerName,
+			PlayerID:   p.PlayerID,
+			JoinedAt:   p.JoinedAt,
+		})
 	}
+	return result
 }
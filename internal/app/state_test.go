@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubCurrentInstanceStore struct {
+	ci  *store.CurrentInstance
+	err error
+}
+
+func (s stubCurrentInstanceStore) CurrentInstance(ctx context.Context, account string) (*store.CurrentInstance, error) {
+	return s.ci, s.err
+}
+
+func TestStateService_GetCurrentState(t *testing.T) {
+	ctx := context.Background()
+	ts := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	state := derive.NewMulti()
+	state.Update(&event.Event{Ts: ts, Type: event.TypeWorldJoin, WorldID: event.StringPtr("wrld_memory")})
+
+	// The stored instance wins over the in-memory state
+	svc := StateService{State: state, Store: stubCurrentInstanceStore{ci: &store.CurrentInstance{
+		WorldID: "wrld_db", JoinedAt: ts,
+		Players: []store.OnlinePlayer{{PlayerName: "Alice", JoinedAt: ts}},
+	}}}
+	got := svc.GetCurrentState(ctx, "")
+	if got.World == nil || got.World.WorldID != "wrld_db" || len(got.Players) != 1 || got.Players[0].PlayerName != "Alice" {
+		t.Errorf("stored state = %+v", got)
+	}
+
+	svc.Store = stubCurrentInstanceStore{err: store.ErrNotFound}
+	if got := svc.GetCurrentState(ctx, ""); got.World != nil || got.Players == nil || len(got.Players) != 0 {
+		t.Errorf("no stored instance = %+v, want no world and no players", got)
+	}
+
+	// A failing store falls back to the in-memory state
+	svc.Store = stubCurrentInstanceStore{err: errors.New("database is locked")}
+	svc.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	if got := svc.GetCurrentState(ctx, ""); got.World == nil || got.World.WorldID != "wrld_memory" {
+		t.Errorf("fallback state = %+v, want wrld_memory", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
 	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
@@ -14,21 +15,67 @@ type StatsResult struct {
 	TodayWorldChanges int      `json:"today_world_changes"`
 	RecentPlayers     []string `json:"recent_players"`
 	LastEventAt       *string  `json:"last_event_at,omitempty"`
+
+	// Runtime covers this run of the app across all accounts; nil if not
+	// tracked
+	Runtime *RuntimeStats `json:"runtime,omitempty"`
+}
+
+// RuntimeStats is how long the app has been running and what it has
+// ingested since it started.
+type RuntimeStats struct {
+	StartedAt time.Time `json:"started_at"`
+	UptimeSec int64     `json:"uptime_sec"`
+	ingest.Counts
+}
+
+// IngestCounter reports what was ingested since startup. Implemented by
+// ingest.Ingester.
+type IngestCounter interface {
+	Counts() ingest.Counts
 }
 
 // StatsUsecase defines the interface for stats operations.
+// An empty account covers all accounts.
 type StatsUsecase interface {
-	GetBasicStats(ctx context.Context) (*StatsResult, error)
+	GetBasicStats(ctx context.Context, account string) (*StatsResult, error)
+	// GetInstanceStats returns world joins grouped by instance type and region.
+	GetInstanceStats(ctx context.Context, since, until time.Time, account string) (*store.InstanceStats, error)
+	// GetCopresence returns time shared with each player, longest first.
+	// limit <= 0 returns all players.
+	GetCopresence(ctx context.Context, since, until time.Time, account string, limit int) ([]store.CopresenceEntry, error)
+	// GetHeatmap returns event counts per local weekday and hour.
+	// An empty typ counts all event types.
+	GetHeatmap(ctx context.Context, since, until time.Time, account, typ string) (*store.Heatmap, error)
+	// GetHosting returns the instances joined by who hosted them. An empty
+	// userID is guessed from the events; limit <= 0 returns all worlds and
+	// hosts.
+	GetHosting(ctx context.Context, since, until time.Time, account, userID string, limit int) (*store.HostingStats, error)
+	// LastModified returns when the stats last changed: the latest change
+	// to the events, or the start of today, as the default ranges and
+	// the basic stats cover today.
+	LastModified(ctx context.Context) (time.Time, error)
 }
 
 // StatsStore defines the interface for stats data access.
 type StatsStore interface {
-	GetBasicStats(ctx context.Context, since, until time.Time) (*store.BasicStats, error)
+	GetBasicStats(ctx context.Context, since, until time.Time, account string) (*store.BasicStats, error)
+	GetInstanceStats(ctx context.Context, since, until time.Time, account string) (*store.InstanceStats, error)
+	GetCopresence(ctx context.Context, since, until time.Time, account string, limit int) ([]store.CopresenceEntry, error)
+	GetHeatmap(ctx context.Context, since, until time.Time, account, typ string) (*store.Heatmap, error)
+	GetHostingStats(ctx context.Context, since, until time.Time, account, userID string, limit int) (*store.HostingStats, error)
+	EventsChangedAt(ctx context.Context) (time.Time, error)
 }
 
 // StatsService implements StatsUsecase.
 type StatsService struct {
 	store StatsStore
+
+	// StartedAt and Ingest add RuntimeStats to GetBasicStats, if both set
+	StartedAt time.Time
+	Ingest    IngestCounter
+
+	now func() time.Time // nil means time.Now
 }
 
 // NewStatsService creates a new StatsService.
@@ -37,19 +84,65 @@ func NewStatsService(store StatsStore) *StatsService {
 }
 
 // GetBasicStats retrieves basic statistics for today (local time).
-func (s *StatsService) GetBasicStats(ctx context.Context) (*StatsResult, error) {
+func (s *StatsService) GetBasicStats(ctx context.Context, account string) (*StatsResult, error) {
 	since, until := store.GetTodayBoundary()
 
-	stats, err := s.store.GetBasicStats(ctx, since, until)
+	stats, err := s.store.GetBasicStats(ctx, since, until, account)
 	if err != nil {
 		return nil, err
 	}
 
-	return &StatsResult{
+	result := &StatsResult{
 		TodayJoins:        stats.JoinCount,
 		TodayLeaves:       stats.LeaveCount,
 		TodayWorldChanges: stats.WorldChangeCount,
 		RecentPlayers:     stats.RecentPlayers,
 		LastEventAt:       stats.LastEventAt,
-	}, nil
+	}
+	if !s.StartedAt.IsZero() && s.Ingest != nil {
+		now := time.Now()
+		if s.now != nil {
+			now = s.now()
+		}
+		result.Runtime = &RuntimeStats{
+			StartedAt: s.StartedAt,
+			UptimeSec: int64(now.Sub(s.StartedAt).Seconds()),
+			Counts:    s.Ingest.Counts(),
+		}
+	}
+	return result, nil
+}
+
+// GetInstanceStats retrieves world join counts grouped by instance attributes.
+func (s *StatsService) GetInstanceStats(ctx context.Context, since, until time.Time, account string) (*store.InstanceStats, error) {
+	return s.store.GetInstanceStats(ctx, since, until, account)
+}
+
+// GetCopresence retrieves time shared with each player in the range.
+func (s *StatsService) GetCopresence(ctx context.Context, since, until time.Time, account string, limit int) ([]store.CopresenceEntry, error) {
+	return s.store.GetCopresence(ctx, since, until, account, limit)
+}
+
+// GetHeatmap retrieves event counts per weekday and hour in the range.
+func (s *StatsService) GetHeatmap(ctx context.Context, since, until time.Time, account, typ string) (*store.Heatmap, error) {
+	return s.store.GetHeatmap(ctx, since, until, account, typ)
+}
+
+// GetHosting retrieves the instances joined in the range by who hosted them.
+func (s *StatsService) GetHosting(ctx context.Context, since, until time.Time, account, userID string, limit int) (*store.HostingStats, error) {
+	return s.store.GetHostingStats(ctx, since, until, account, userID, limit)
+}
+
+// LastModified returns the later of the latest change to the events and
+// the start of today.
+func (s *StatsService) LastModified(ctx context.Context) (time.Time, error) {
+	changed, err := s.store.EventsChangedAt(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	today, _ := store.GetTodayBoundary()
+	if today.After(changed) {
+		return today, nil
+	}
+	return changed, nil
 }
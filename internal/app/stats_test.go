@@ -6,18 +6,48 @@ import (
 	"testing"
 	"time"
 
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
 	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
 // stubStatsStore is a test double for StatsStore.
 type stubStatsStore struct {
-	gotSince time.Time
-	gotUntil time.Time
-	result   *store.BasicStats
-	err      error
+	gotSince  time.Time
+	gotUntil  time.Time
+	result    *store.BasicStats
+	changedAt time.Time
+	err       error
 }
 
-func (s *stubStatsStore) GetBasicStats(ctx context.Context, since, until time.Time) (*store.BasicStats, error) {
+func (s *stubStatsStore) GetInstanceStats(ctx context.Context, since, until time.Time, account string) (*store.InstanceStats, error) {
+	s.gotSince = since
+	s.gotUntil = until
+	return &store.InstanceStats{}, s.err
+}
+
+func (s *stubStatsStore) GetCopresence(ctx context.Context, since, until time.Time, account string, limit int) ([]store.CopresenceEntry, error) {
+	s.gotSince = since
+	s.gotUntil = until
+	return nil, s.err
+}
+
+func (s *stubStatsStore) GetHeatmap(ctx context.Context, since, until time.Time, account, typ string) (*store.Heatmap, error) {
+	s.gotSince = since
+	s.gotUntil = until
+	return &store.Heatmap{}, s.err
+}
+
+func (s *stubStatsStore) GetHostingStats(ctx context.Context, since, until time.Time, account, userID string, limit int) (*store.HostingStats, error) {
+	s.gotSince = since
+	s.gotUntil = until
+	return &store.HostingStats{UserID: userID}, s.err
+}
+
+func (s *stubStatsStore) EventsChangedAt(ctx context.Context) (time.Time, error) {
+	return s.changedAt, s.err
+}
+
+func (s *stubStatsStore) GetBasicStats(ctx context.Context, since, until time.Time, account string) (*store.BasicStats, error) {
 	s.gotSince = since
 	s.gotUntil = until
 	return s.result, s.err
@@ -36,7 +66,7 @@ func TestStatsService_GetBasicStats_Success(t *testing.T) {
 	}
 	svc := NewStatsService(stub)
 
-	result, err := svc.GetBasicStats(context.Background())
+	result, err := svc.GetBasicStats(context.Background(), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats error: %v", err)
 	}
@@ -59,6 +89,38 @@ func TestStatsService_GetBasicStats_Success(t *testing.T) {
 	}
 }
 
+type stubIngestCounter ingest.Counts
+
+func (c stubIngestCounter) Counts() ingest.Counts { return ingest.Counts(c) }
+
+func TestStatsService_GetBasicStats_Runtime(t *testing.T) {
+	stub := &stubStatsStore{result: &store.BasicStats{RecentPlayers: []string{}}}
+	svc := NewStatsService(stub)
+
+	result, err := svc.GetBasicStats(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetBasicStats error: %v", err)
+	}
+	if result.Runtime != nil {
+		t.Errorf("Runtime = %+v without a start time", result.Runtime)
+	}
+
+	started := time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC)
+	counts := ingest.Counts{EventsIngested: 18000, DuplicatesSkipped: 40, ParseFailures: 2}
+	svc.StartedAt = started
+	svc.Ingest = stubIngestCounter(counts)
+	svc.now = func() time.Time { return started.Add(3*24*time.Hour + 90*time.Second) }
+
+	result, err = svc.GetBasicStats(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetBasicStats error: %v", err)
+	}
+	want := RuntimeStats{StartedAt: started, UptimeSec: 3*24*3600 + 90, Counts: counts}
+	if result.Runtime == nil || *result.Runtime != want {
+		t.Errorf("Runtime = %+v, want %+v", result.Runtime, want)
+	}
+}
+
 func TestStatsService_GetBasicStats_DateRange(t *testing.T) {
 	stub := &stubStatsStore{
 		result: &store.BasicStats{
@@ -67,7 +129,7 @@ func TestStatsService_GetBasicStats_DateRange(t *testing.T) {
 	}
 	svc := NewStatsService(stub)
 
-	_, err := svc.GetBasicStats(context.Background())
+	_, err := svc.GetBasicStats(context.Background(), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats error: %v", err)
 	}
@@ -90,7 +152,7 @@ func TestStatsService_GetBasicStats_Error(t *testing.T) {
 	}
 	svc := NewStatsService(stub)
 
-	_, err := svc.GetBasicStats(context.Background())
+	_, err := svc.GetBasicStats(context.Background(), "")
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -111,7 +173,7 @@ func TestStatsService_GetBasicStats_NilLastEventAt(t *testing.T) {
 	}
 	svc := NewStatsService(stub)
 
-	result, err := svc.GetBasicStats(context.Background())
+	result, err := svc.GetBasicStats(context.Background(), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats error: %v", err)
 	}
@@ -129,7 +191,7 @@ func TestStatsService_GetBasicStats_EmptyRecentPlayers(t *testing.T) {
 	}
 	svc := NewStatsService(stub)
 
-	result, err := svc.GetBasicStats(context.Background())
+	result, err := svc.GetBasicStats(context.Background(), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats error: %v", err)
 	}
@@ -141,3 +203,19 @@ func TestStatsService_GetBasicStats_EmptyRecentPlayers(t *testing.T) {
 		t.Errorf("len(RecentPlayers) = %d, want 0", len(result.RecentPlayers))
 	}
 }
+
+func TestStatsService_LastModified(t *testing.T) {
+	today, _ := store.GetTodayBoundary()
+	for _, tt := range []struct {
+		changed, want time.Time
+	}{
+		{today.Add(-time.Hour), today},
+		{today.Add(time.Minute), today.Add(time.Minute)},
+	} {
+		svc := NewStatsService(&stubStatsStore{changedAt: tt.changed})
+		got, err := svc.LastModified(context.Background())
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("LastModified with change at %v = %v, %v, want %v", tt.changed, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// States of a supervised component, see ComponentStatus.
+const (
+	ComponentPending    = "pending"    // Start not called yet
+	ComponentRunning    = "running"    // Run is active
+	ComponentRestarting = "restarting" // Run panicked and is restarted after a backoff
+	ComponentStopped    = "stopped"    // Run returned without error, or Stop was called
+	ComponentFailed     = "failed"     // Run returned an error
+)
+
+// Component is a background subsystem run by a Supervisor, such as the
+// ingester, the notifier or an SSE hub.
+type Component struct {
+	Name string
+
+	// DependsOn names the components started before this one and stopped
+	// after it, e.g. the hubs and notifier the ingester publishes to.
+	DependsOn []string
+
+	// Run runs the component until ctx is cancelled. It is restarted if it
+	// panics.
+	Run func(ctx context.Context) error
+
+	// Stop, if set, is called after Run's context is cancelled, for
+	// components whose Run does not return on cancellation.
+	Stop func()
+}
+
+// ComponentStatus describes the state of a supervised component.
+type ComponentStatus struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"` // see Component* states
+	Since     time.Time `json:"since"` // when State last changed
+	Restarts  int       `json:"restarts,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// ComponentReporter reports the state of supervised background components.
+type ComponentReporter interface {
+	Components() []ComponentStatus
+}
+
+// Supervisor starts background components in dependency order, restarts
+// them after a panic, reports their state, and stops them in reverse
+// order on shutdown, so a new subsystem is wired in with a single Add.
+type Supervisor struct {
+	Recoverer *Recoverer   // restarts panicked components; nil uses the defaults
+	Logger    *slog.Logger // nil means slog.Default()
+
+	mu         sync.Mutex
+	components []*supervised // in start order once started
+	started    bool
+}
+
+// supervised is a component and its run state.
+type supervised struct {
+	Component
+	cancel context.CancelFunc
+	done   chan struct{} // closed when Run has returned for good
+	status ComponentStatus
+}
+
+// Add registers a component. Components must be added before Start.
+func (s *Supervisor) Add(c Component) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.components = append(s.components, &supervised{
+		Component: c,
+		status:    ComponentStatus{Name: c.Name, State: ComponentPending, Since: time.Now().UTC()},
+	})
+}
+
+// Start starts the components, each after those it depends on, in the
+// order they were added otherwise. Returns an error without starting any
+// if names are duplicated or dependencies are unknown or circular, or if
+// called twice.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.New("supervisor already started")
+	}
+	ordered, err := startOrder(s.components)
+	if err != nil {
+		return err
+	}
+	s.components = ordered
+	s.started = true
+
+	recoverer := s.Recoverer
+	if recoverer == nil {
+		recoverer = &Recoverer{Logger: s.Logger}
+	}
+	for _, c := range ordered {
+		runCtx, cancel := context.WithCancel(ctx)
+		c.cancel = cancel
+		c.done = make(chan struct{})
+		c.status.State = ComponentRunning
+		c.status.Since = time.Now().UTC()
+		go s.run(runCtx, recoverer, c)
+	}
+	return nil
+}
+
+// run runs c until it returns without panicking, tracking its state.
+func (s *Supervisor) run(ctx context.Context, recoverer *Recoverer, c *supervised) {
+	defer close(c.done)
+	first := true
+	err := recoverer.Run(ctx, c.Name, func(ctx context.Context) error {
+		if !first {
+			s.setState(c, ComponentRunning, "")
+		}
+		first = false
+		defer func() {
+			// Record the panic and let the recoverer handle it
+			if v := recover(); v != nil {
+				s.mu.Lock()
+				c.status.Restarts++
+				s.mu.Unlock()
+				s.setState(c, ComponentRestarting, fmt.Sprintf("panic: %v", v))
+				panic(v)
+			}
+		}()
+		return c.Run(ctx)
+	})
+
+	switch {
+	case ctx.Err() != nil:
+		s.setState(c, ComponentStopped, "")
+	case err != nil:
+		s.logger().Error("component failed", "component", c.Name, "error", err)
+		s.setState(c, ComponentFailed, err.Error())
+	default:
+		s.logger().Info("component stopped", "component", c.Name)
+		s.setState(c, ComponentStopped, "")
+	}
+}
+
+// Stop stops the components in reverse start order, waiting for each to
+// return before stopping those it depends on. If ctx is done first, the
+// remaining components are cancelled without waiting and an error is
+// returned. Does nothing if Start was not called.
+func (s *Supervisor) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	components := s.components
+	started := s.started
+	s.mu.Unlock()
+	if !started {
+		return nil
+	}
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		c.cancel()
+		if c.Stop != nil {
+			c.Stop()
+		}
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("%s did not stop: %w", c.Name, ctx.Err()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Components returns the state of each component, in start order once
+// started.
+func (s *Supervisor) Components() []ComponentStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]ComponentStatus, len(s.components))
+	for i, c := range s.components {
+		items[i] = c.status
+	}
+	return items
+}
+
+// setState moves c to state, recording lastError if not empty.
+func (s *Supervisor) setState(c *supervised, state, lastError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.status.State = state
+	c.status.Since = time.Now().UTC()
+	if lastError != "" {
+		c.status.LastError = lastError
+	}
+}
+
+func (s *Supervisor) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// startOrder sorts components so each comes after those it depends on,
+// keeping the order they were added otherwise.
+func startOrder(components []*supervised) ([]*supervised, error) {
+	byName := make(map[string]*supervised, len(components))
+	for _, c := range components {
+		if c.Name == "" {
+			return nil, errors.New("component without a name")
+		}
+		if _, dup := byName[c.Name]; dup {
+			return nil, fmt.Errorf("duplicate component %q", c.Name)
+		}
+		byName[c.Name] = c
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(components))
+	ordered := make([]*supervised, 0, len(components))
+	var visit func(c *supervised) error
+	visit = func(c *supervised) error {
+		switch marks[c.Name] {
+		case visiting:
+			return fmt.Errorf("component %q depends on itself", c.Name)
+		case visited:
+			return nil
+		}
+		marks[c.Name] = visiting
+		for _, name := range c.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("component %q depends on unknown component %q", c.Name, name)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		marks[c.Name] = visited
+		ordered = append(ordered, c)
+		return nil
+	}
+	for _, c := range components {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingComponent returns a component that appends "start name" and
+// "stop name" to log as it runs until cancelled.
+func recordingComponent(name string, log *[]string, mu *sync.Mutex, deps ...string) Component {
+	return Component{
+		Name:      name,
+		DependsOn: deps,
+		Run: func(ctx context.Context) error {
+			mu.Lock()
+			*log = append(*log, "start "+name)
+			mu.Unlock()
+			<-ctx.Done()
+			// Give components stopped too early a chance to show up out of order
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			*log = append(*log, "stop "+name)
+			mu.Unlock()
+			return nil
+		},
+	}
+}
+
+func TestSupervisor_Order(t *testing.T) {
+	var mu sync.Mutex
+	var log []string
+	s := &Supervisor{}
+	s.Add(recordingComponent("ingester", &log, &mu, "hub", "notifier"))
+	s.Add(recordingComponent("hub", &log, &mu))
+	s.Add(recordingComponent("notifier", &log, &mu, "hub"))
+
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var names []string
+	for _, c := range s.Components() {
+		names = append(names, c.Name)
+		if c.State != ComponentRunning {
+			t.Errorf("%s state = %q, want running", c.Name, c.State)
+		}
+	}
+	if got := strings.Join(names, ","); got != "hub,notifier,ingester" {
+		t.Errorf("start order = %s, want hub,notifier,ingester", got)
+	}
+	if err := s.Start(context.Background()); err == nil {
+		t.Error("second Start succeeded")
+	}
+
+	// Wait for all to start, as Run is called in goroutines
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(log)
+		mu.Unlock()
+		if n == 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	mu.Lock()
+	stops := strings.Join(log[3:], ",")
+	mu.Unlock()
+	if want := "stop ingester,stop notifier,stop hub"; stops != want {
+		t.Errorf("stop order = %s, want %s", stops, want)
+	}
+	for _, c := range s.Components() {
+		if c.State != ComponentStopped {
+			t.Errorf("%s state = %q after Stop, want stopped", c.Name, c.State)
+		}
+	}
+}
+
+func TestSupervisor_InvalidDependencies(t *testing.T) {
+	run := func(ctx context.Context) error { return nil }
+	tests := []struct {
+		name       string
+		components []Component
+	}{
+		{"duplicate", []Component{{Name: "a", Run: run}, {Name: "a", Run: run}}},
+		{"unknown", []Component{{Name: "a", DependsOn: []string{"b"}, Run: run}}},
+		{"cycle", []Component{
+			{Name: "a", DependsOn: []string{"b"}, Run: run},
+			{Name: "b", DependsOn: []string{"a"}, Run: run},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Supervisor{}
+			for _, c := range tt.components {
+				s.Add(c)
+			}
+			if err := s.Start(context.Background()); err == nil {
+				t.Error("Start succeeded")
+			}
+			if c := s.Components()[0]; c.State != ComponentPending {
+				t.Errorf("state = %q, want pending", c.State)
+			}
+		})
+	}
+}
+
+func TestSupervisor_PanicAndFailure(t *testing.T) {
+	s := &Supervisor{Recoverer: &Recoverer{MinBackoff: time.Hour}}
+	s.Add(Component{Name: "ingester", Run: func(ctx context.Context) error { panic("bad event") }})
+	s.Add(Component{Name: "bot", Run: func(ctx context.Context) error { return errors.New("invalid token") }})
+	s.Add(Component{Name: "hub", Run: func(ctx context.Context) error { <-ctx.Done(); return nil }})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var result HealthResult
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		result, _ = HealthService{Subsystems: s}.Handle(context.Background())
+		if result.Subsystems["ingester"].Status == StatusDegraded && result.Subsystems["bot"].Status == StatusUnhealthy {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if result.Status != StatusDegraded {
+		t.Errorf("status = %q, want degraded", result.Status)
+	}
+	if c := result.Subsystems["ingester"]; c.Status != StatusDegraded || !strings.Contains(c.Message, "bad event") {
+		t.Errorf("ingester = %+v, want restarting after the panic", c)
+	}
+	if c := result.Subsystems["bot"]; c.Status != StatusUnhealthy || !strings.Contains(c.Message, "invalid token") {
+		t.Errorf("bot = %+v, want failed", c)
+	}
+	if c := result.Subsystems["hub"]; c.Status != StatusHealthy {
+		t.Errorf("hub = %+v, want healthy", c)
+	}
+	for _, c := range s.Components() {
+		if c.Name == "ingester" && c.Restarts != 1 {
+			t.Errorf("ingester restarts = %d, want 1", c.Restarts)
+		}
+	}
+
+	// Stop does not wait out the restart backoff
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// Limits of the support bundle.
+const (
+	SupportParseFailureSamples = 50
+	SupportLogFileBytes        = 1 << 20 // tail of the current log file
+)
+
+// SupportUsecase defines the use case of collecting troubleshooting
+// information into one file to attach to a bug report.
+type SupportUsecase interface {
+	// WriteBundle writes the support bundle to w as a zip. Parts that
+	// cannot be collected are listed in the bundle's manifest instead of
+	// failing it; an error means the zip could not be written.
+	WriteBundle(ctx context.Context, w io.Writer) error
+}
+
+// SupportStore is the database access of SupportService. Implemented by
+// store.Store.
+type SupportStore interface {
+	DatabaseInfo(ctx context.Context) (store.DatabaseInfo, error)
+	RecentParseFailures(ctx context.Context, limit int) ([]store.ParseFailure, error)
+}
+
+// SupportManifest is manifest.json in a support bundle.
+type SupportManifest struct {
+	CreatedAt time.Time         `json:"created_at"`
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	OS        string            `json:"os"`
+	Arch      string            `json:"arch"`
+	Files     []string          `json:"files"`
+	Errors    map[string]string `json:"errors,omitempty"` // file name to why it is missing
+}
+
+// SupportService implements SupportUsecase.
+type SupportService struct {
+	Version     string
+	Health      HealthUsecase
+	Logs        LogBuffer    // nil if there are no recent records
+	LogFile     string       // current log file; "" if logs are not written to a file
+	ConfigPath  string       // "" leaves out the config
+	SecretsPath string       // "" leaves out the secrets; values are always redacted
+	Store       SupportStore // nil if there is no database
+
+	now func() time.Time // nil means time.Now
+}
+
+// WriteBundle writes manifest.json, health.json, config.json,
+// secrets.json, database.json, parse_failures.json, logs.jsonl and the
+// end of the log file, as far as each is available.
+func (s SupportService) WriteBundle(ctx context.Context, w io.Writer) error {
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	manifest := SupportManifest{
+		CreatedAt: now.UTC(),
+		Version:   s.Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Errors:    map[string]string{},
+	}
+	zw := zip.NewWriter(w)
+
+	// add writes one file, or records why it is missing
+	add := func(name string, collect func(w io.Writer) error) error {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := collect(f); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			manifest.Errors[name] = err.Error()
+			fmt.Fprintf(f, "not collected: %v\n", err)
+			return nil
+		}
+		manifest.Files = append(manifest.Files, name)
+		return nil
+	}
+	parts := []struct {
+		name    string
+		enabled bool
+		collect func(w io.Writer) error
+	}{
+		{"health.json", s.Health != nil, func(w io.Writer) error {
+			result, err := s.Health.Handle(ctx)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, result)
+		}},
+		{"config.json", s.ConfigPath != "", func(w io.Writer) error {
+			cfg, err := config.LoadConfigFrom(s.ConfigPath)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, cfg)
+		}},
+		{"secrets.json", s.SecretsPath != "", func(w io.Writer) error {
+			sec, _, err := config.LoadSecretsFrom(s.SecretsPath)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, sec.Redacted())
+		}},
+		{"database.json", s.Store != nil, func(w io.Writer) error {
+			info, err := s.Store.DatabaseInfo(ctx)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, info)
+		}},
+		{"parse_failures.json", s.Store != nil, func(w io.Writer) error {
+			failures, err := s.Store.RecentParseFailures(ctx, SupportParseFailureSamples)
+			if err != nil {
+				return err
+			}
+			return writeIndentedJSON(w, failures)
+		}},
+		{"logs.jsonl", s.Logs != nil, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			for _, e := range s.Logs.Tail(MaxLogTailLines, slog.LevelDebug) {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{filepath.Base(s.LogFile), s.LogFile != "", func(w io.Writer) error {
+			return copyFileTail(w, s.LogFile, SupportLogFileBytes)
+		}},
+	}
+	for _, p := range parts {
+		if !p.enabled {
+			continue
+		}
+		if err := add(p.name, p.collect); err != nil {
+			return err
+		}
+	}
+
+	if len(manifest.Errors) == 0 {
+		manifest.Errors = nil
+	}
+	if err := add("manifest.json", func(w io.Writer) error { return writeIndentedJSON(w, manifest) }); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeIndentedJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// copyFileTail copies the last n bytes of the file at path to w, starting
+// at a line boundary if it has to skip anything.
+func copyFileTail(w io.Writer, path string, n int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= n {
+		_, err = io.Copy(w, f)
+		return err
+	}
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, info.Size()-n); err != nil && err != io.EOF {
+		return err
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	_, err = w.Write(buf)
+	return err
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/logging"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubHealth struct{}
+
+func (stubHealth) Handle(ctx context.Context) (HealthResult, error) {
+	return HealthResult{Status: StatusHealthy, Version: "1.2.3"}, nil
+}
+
+type stubSupportStore struct {
+	infoErr error
+}
+
+func (s stubSupportStore) DatabaseInfo(ctx context.Context) (store.DatabaseInfo, error) {
+	return store.DatabaseInfo{SchemaVersion: 1}, s.infoErr
+}
+
+func (s stubSupportStore) RecentParseFailures(ctx context.Context, limit int) ([]store.ParseFailure, error) {
+	return []store.ParseFailure{{RawLine: "garbled line", Error: "no match"}}, nil
+}
+
+// readZip returns the files in a zip by name.
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestSupportService_WriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	secretsPath := filepath.Join(dir, "secrets.json")
+	if err := config.SaveConfigTo(config.DefaultConfig(), configPath); err != nil {
+		t.Fatalf("SaveConfigTo: %v", err)
+	}
+	sec := config.DefaultSecrets()
+	sec.DiscordWebhookURL = "https://discord.com/api/webhooks/1/hook-token"
+	sec.BasicAuthPassword = "hunter2"
+	if err := config.SaveSecretsTo(sec, secretsPath); err != nil {
+		t.Fatalf("SaveSecretsTo: %v", err)
+	}
+
+	logFile := filepath.Join(dir, logging.FileName)
+	os.WriteFile(logFile, []byte("level=INFO msg=started\n"), 0o600)
+	buf := logging.NewBuffer(10)
+	slog.New(slog.NewJSONHandler(buf, nil)).Warn("watcher restarted", "attempt", 2)
+
+	svc := SupportService{
+		Version:     "1.2.3",
+		Health:      stubHealth{},
+		Logs:        buf,
+		LogFile:     logFile,
+		ConfigPath:  configPath,
+		SecretsPath: secretsPath,
+		Store:       stubSupportStore{infoErr: errors.New("database is locked")},
+		now:         func() time.Time { return time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC) },
+	}
+	var out bytes.Buffer
+	if err := svc.WriteBundle(context.Background(), &out); err != nil {
+		t.Fatalf("WriteBundle: %v", err)
+	}
+	files := readZip(t, out.Bytes())
+
+	var manifest SupportManifest
+	if err := json.Unmarshal([]byte(files["manifest.json"]), &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	wantFiles := "health.json config.json secrets.json parse_failures.json logs.jsonl vrclog.log"
+	if got := strings.Join(manifest.Files, " "); got != wantFiles {
+		t.Errorf("manifest files = %q, want %q", got, wantFiles)
+	}
+	if manifest.Version != "1.2.3" || manifest.Errors["database.json"] != "database is locked" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	for name, want := range map[string]string{
+		"health.json":         `"status": "healthy"`,
+		"config.json":         `"port"`,
+		"secrets.json":        `"basic_auth_password": "[REDACTED]"`,
+		"database.json":       "not collected: database is locked",
+		"parse_failures.json": "garbled line",
+		"logs.jsonl":          `"msg":"watcher restarted"`,
+		"vrclog.log":          "msg=started",
+	} {
+		if !strings.Contains(files[name], want) {
+			t.Errorf("%s = %q, want it to contain %q", name, files[name], want)
+		}
+	}
+	for name, content := range files {
+		if strings.Contains(content, "hunter2") || strings.Contains(content, "hook-token") {
+			t.Errorf("%s leaks a secret: %s", name, content)
+		}
+	}
+}
+
+func TestCopyFileTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	os.WriteFile(path, []byte("first line\nsecond line\nthird\n"), 0o600)
+
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{100, "first line\nsecond line\nthird\n"},
+		{15, "third\n"}, // the cut-off part of the second line is skipped
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := copyFileTail(&buf, path, tt.n); err != nil {
+			t.Fatalf("copyFileTail(%d): %v", tt.n, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("copyFileTail(%d) = %q, want %q", tt.n, buf.String(), tt.want)
+		}
+	}
+}
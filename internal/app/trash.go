@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/schedule"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// TrashPurgeInterval is how often TrashService purges batches whose undo
+// window has passed.
+const TrashPurgeInterval = time.Hour
+
+// TrashUsecase defines the undo of destructive operations.
+type TrashUsecase interface {
+	// ListTrash returns the batches that can still be restored, newest
+	// first.
+	ListTrash(ctx context.Context) ([]TrashBatch, error)
+	// RestoreTrash puts a batch back. Returns store.ErrNotFound if the
+	// batch is not in the trash or its undo window has passed.
+	RestoreTrash(ctx context.Context, batchID string) (store.RestoreResult, error)
+}
+
+// TrashStore defines store operations needed by TrashService.
+type TrashStore interface {
+	ListTrash(ctx context.Context) ([]store.TrashBatch, error)
+	RestoreTrash(ctx context.Context, batchID string) (store.RestoreResult, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+}
+
+// TrashBatch is a batch in the trash with the end of its undo window.
+type TrashBatch struct {
+	store.TrashBatch
+	UndoUntil time.Time `json:"undo_until"`
+}
+
+// TrashService implements TrashUsecase and purges batches once their
+// undo window has passed.
+type TrashService struct {
+	Store      TrashStore
+	UndoWindow time.Duration // 0 purges everything; deletions then skip the trash
+	Logger     *slog.Logger  // nil means slog.Default()
+
+	now func() time.Time // nil means time.Now
+}
+
+// Jobs returns the scheduled job purging expired batches on startup and
+// every TrashPurgeInterval.
+func (s *TrashService) Jobs() []schedule.Job {
+	return []schedule.Job{{
+		Name:       "trash_purge",
+		Schedule:   schedule.Every(TrashPurgeInterval),
+		RunAtStart: true,
+		Run: func(ctx context.Context) error {
+			_, err := s.purge(ctx)
+			return err
+		},
+	}}
+}
+
+// ListTrash returns the batches that can still be restored, newest first.
+func (s *TrashService) ListTrash(ctx context.Context) ([]TrashBatch, error) {
+	batches, err := s.Store.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := s.cutoff()
+	items := make([]TrashBatch, 0, len(batches))
+	for _, b := range batches {
+		if b.DeletedAt.Before(cutoff) {
+			continue // purged on the next run
+		}
+		items = append(items, TrashBatch{TrashBatch: b, UndoUntil: b.DeletedAt.Add(s.UndoWindow)})
+	}
+	return items, nil
+}
+
+// RestoreTrash puts a batch back, unless its undo window has passed.
+func (s *TrashService) RestoreTrash(ctx context.Context, batchID string) (store.RestoreResult, error) {
+	if _, err := s.purge(ctx); err != nil {
+		return store.RestoreResult{}, err
+	}
+	return s.Store.RestoreTrash(ctx, batchID)
+}
+
+func (s *TrashService) purge(ctx context.Context) (int64, error) {
+	n, err := s.Store.PurgeTrash(ctx, s.cutoff())
+	if err == nil && n > 0 {
+		s.logger().Info("purged trash", "rows", n)
+	}
+	return n, err
+}
+
+// cutoff returns the deletion time before which batches are expired.
+func (s *TrashService) cutoff() time.Time {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	return now().Add(-s.UndoWindow)
+}
+
+func (s *TrashService) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// ErrInvalidView is returned when a view fails validation.
+var ErrInvalidView = errors.New("invalid view")
+
+// viewNamePattern limits view names to characters that are safe in a URL path.
+var viewNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ViewsUsecase defines named event filters saved on the server.
+type ViewsUsecase interface {
+	// Save creates or replaces a view. Returns ErrInvalidView.
+	Save(ctx context.Context, name string, f store.ViewFilter) (*store.View, error)
+	List(ctx context.Context) ([]store.View, error)
+	// Get returns a view. Returns store.ErrNotFound if it does not exist.
+	Get(ctx context.Context, name string) (*store.View, error)
+	// Delete removes a view. Returns store.ErrNotFound if it does not exist.
+	Delete(ctx context.Context, name string) error
+	// Filter resolves a view into a query filter, with relative time
+	// windows ending now. Returns store.ErrNotFound.
+	Filter(ctx context.Context, name string) (store.QueryFilter, error)
+	// Events queries the events matching a view, newest first.
+	// Returns store.ErrNotFound or store.ErrInvalidCursor.
+	Events(ctx context.Context, name string, cursor *string, limit int) (store.QueryResult, error)
+}
+
+// ViewStore defines store operations needed by ViewsService.
+type ViewStore interface {
+	SaveView(ctx context.Context, name string, f store.ViewFilter) (*store.View, error)
+	GetView(ctx context.Context, name string) (*store.View, error)
+	ListViews(ctx context.Context) ([]store.View, error)
+	DeleteView(ctx context.Context, name string) error
+	QueryEvents(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error)
+}
+
+// ViewsService implements ViewsUsecase.
+type ViewsService struct {
+	Store ViewStore
+	now   func() time.Time // for testing; nil means time.Now
+}
+
+// Save validates and stores a view.
+func (s *ViewsService) Save(ctx context.Context, name string, f store.ViewFilter) (*store.View, error) {
+	if err := validateView(name, f); err != nil {
+		return nil, err
+	}
+	return s.Store.SaveView(ctx, name, f)
+}
+
+// List returns all views.
+func (s *ViewsService) List(ctx context.Context) ([]store.View, error) {
+	return s.Store.ListViews(ctx)
+}
+
+// Get returns a view by name.
+func (s *ViewsService) Get(ctx context.Context, name string) (*store.View, error) {
+	return s.Store.GetView(ctx, name)
+}
+
+// Delete removes a view.
+func (s *ViewsService) Delete(ctx context.Context, name string) error {
+	return s.Store.DeleteView(ctx, name)
+}
+
+// Filter resolves a saved view into a query filter.
+func (s *ViewsService) Filter(ctx context.Context, name string) (store.QueryFilter, error) {
+	v, err := s.Store.GetView(ctx, name)
+	if err != nil {
+		return store.QueryFilter{}, err
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	return ViewQueryFilter(v.Filter, now()), nil
+}
+
+// Events returns a page of events matching a view.
+func (s *ViewsService) Events(ctx context.Context, name string, cursor *string, limit int) (store.QueryResult, error) {
+	filter, err := s.Filter(ctx, name)
+	if err != nil {
+		return store.QueryResult{}, err
+	}
+	filter.Cursor = cursor
+	filter.Limit = limit
+	return s.Store.QueryEvents(ctx, filter)
+}
+
+// ViewQueryFilter converts a saved filter into a query filter. A Within
+// window becomes a Since relative to now.
+func ViewQueryFilter(f store.ViewFilter, now time.Time) store.QueryFilter {
+	opt := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	q := store.QueryFilter{
+		Type:         opt(f.Type),
+		Player:       opt(f.Player),
+		World:        opt(f.World),
+		InstanceType: opt(f.InstanceType),
+		Region:       opt(f.Region),
+		GroupID:      opt(f.GroupID),
+		Account:      opt(f.Account),
+		Since:        f.Since,
+		Until:        f.Until,
+	}
+	if d, err := time.ParseDuration(f.Within); err == nil && d > 0 {
+		since := now.Add(-d)
+		q.Since = &since
+	}
+	return q
+}
+
+func validateView(name string, f store.ViewFilter) error {
+	if !viewNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name must be 1-64 letters, digits, '-' or '_'", ErrInvalidView)
+	}
+	if f.Type != "" && !event.IsValidType(f.Type) {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidView, f.Type)
+	}
+	if f.InstanceType != "" && !instance.IsValidType(f.InstanceType) {
+		return fmt.Errorf("%w: unknown instance_type %q", ErrInvalidView, f.InstanceType)
+	}
+	if f.Since != nil && f.Until != nil && !f.Since.Before(*f.Until) {
+		return fmt.Errorf("%w: since must be before until", ErrInvalidView)
+	}
+	if f.Within != "" {
+		if f.Since != nil {
+			return fmt.Errorf("%w: give either since or within", ErrInvalidView)
+		}
+		if d, err := time.ParseDuration(f.Within); err != nil || d <= 0 {
+			return fmt.Errorf("%w: within must be a positive duration such as 24h", ErrInvalidView)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+func TestValidateView(t *testing.T) {
+	since := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	tests := []struct {
+		name    string
+		view    string
+		filter  store.ViewFilter
+		wantErr bool
+	}{
+		{"valid", "friends-joins", store.ViewFilter{Type: event.TypePlayerJoin, Player: "Alice", Within: "24h"}, false},
+		{"range", "jan", store.ViewFilter{Since: &since, Until: &until}, false},
+		{"empty name", "", store.ViewFilter{}, true},
+		{"name with slash", "a/b", store.ViewFilter{}, true},
+		{"unknown type", "x", store.ViewFilter{Type: "bogus"}, true},
+		{"unknown instance type", "x", store.ViewFilter{InstanceType: "bogus"}, true},
+		{"reversed range", "x", store.ViewFilter{Since: &until, Until: &since}, true},
+		{"bad within", "x", store.ViewFilter{Within: "yesterday"}, true},
+		{"since and within", "x", store.ViewFilter{Since: &since, Within: "1h"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateView(tt.view, tt.filter)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("validateView = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidView) {
+				t.Errorf("err = %v, want ErrInvalidView", err)
+			}
+		})
+	}
+}
+
+func TestViewQueryFilter(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	q := ViewQueryFilter(store.ViewFilter{Type: event.TypeWorldJoin, World: "wrld_a", Within: "2h"}, now)
+
+	if q.Type == nil || *q.Type != event.TypeWorldJoin || q.World == nil || *q.World != "wrld_a" {
+		t.Errorf("filter = %+v", q)
+	}
+	if q.Player != nil || q.Account != nil {
+		t.Errorf("empty fields should stay nil: %+v", q)
+	}
+	if q.Since == nil || !q.Since.Equal(now.Add(-2*time.Hour)) {
+		t.Errorf("since = %v, want %v", q.Since, now.Add(-2*time.Hour))
+	}
+}
@@ -0,0 +1,190 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+// VRTimeCheckInterval is how often VRTimeService checks the budgets.
+const VRTimeCheckInterval = time.Minute
+
+// VRTimeUsecase defines the time-in-VR budgets use case.
+type VRTimeUsecase interface {
+	// Progress returns the time spent in VRChat today and this week
+	// against the budgets in the config. An empty account covers all
+	// accounts.
+	Progress(ctx context.Context, account string) (VRTimeProgress, error)
+}
+
+// VRTimeStore defines store operations needed by VRTimeService.
+type VRTimeStore interface {
+	TimeInVR(ctx context.Context, since, until time.Time, account string) (time.Duration, error)
+}
+
+// VRTimeProgress is the response of VRTimeUsecase.Progress.
+type VRTimeProgress struct {
+	Daily  BudgetProgress `json:"daily"`
+	Weekly BudgetProgress `json:"weekly"`
+}
+
+// BudgetProgress is the time spent in one budget period.
+type BudgetProgress struct {
+	Since         time.Time `json:"since"` // start of the local day or week
+	Until         time.Time `json:"until"`
+	Minutes       int64     `json:"minutes"`
+	BudgetMinutes int       `json:"budget_minutes"`    // 0 = no budget
+	Percent       int       `json:"percent,omitempty"` // of the budget; may exceed 100
+	Exceeded      bool      `json:"exceeded"`
+}
+
+// VRTimeService implements VRTimeUsecase and alerts when a budget is used
+// up. Budgets are read from the config file on each use, so changes apply
+// without a restart.
+type VRTimeService struct {
+	Store      VRTimeStore
+	ConfigPath string
+
+	// Alert sends a notification, e.g. Notifier.Alert. nil disables the
+	// alerts, as does VRTimeConfig.Notify.
+	Alert  func(ctx context.Context, title, message string)
+	Logger *slog.Logger // nil means slog.Default()
+
+	now func() time.Time // nil means time.Now
+
+	mu      sync.Mutex
+	alerted map[string]time.Time // "daily" or "weekly" -> start of the period last alerted
+}
+
+// Progress returns the time spent today and this week.
+func (s *VRTimeService) Progress(ctx context.Context, account string) (VRTimeProgress, error) {
+	cfg, _ := config.LoadConfigFrom(s.ConfigPath)
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+
+	day, week := budgetPeriods(now)
+	var p VRTimeProgress
+	var err error
+	if p.Daily, err = s.progress(ctx, day, account, cfg.VRTime.DailyMinutes); err != nil {
+		return VRTimeProgress{}, err
+	}
+	if p.Weekly, err = s.progress(ctx, week, account, cfg.VRTime.WeeklyMinutes); err != nil {
+		return VRTimeProgress{}, err
+	}
+	return p, nil
+}
+
+func (s *VRTimeService) progress(ctx context.Context, period [2]time.Time, account string, budget int) (BudgetProgress, error) {
+	spent, err := s.Store.TimeInVR(ctx, period[0], period[1], account)
+	if err != nil {
+		return BudgetProgress{}, err
+	}
+	p := BudgetProgress{
+		Since:         period[0],
+		Until:         period[1],
+		Minutes:       int64(spent / time.Minute),
+		BudgetMinutes: budget,
+	}
+	if budget > 0 {
+		p.Percent = int(p.Minutes * 100 / int64(budget))
+		p.Exceeded = p.Minutes >= int64(budget)
+	}
+	return p, nil
+}
+
+// Run checks the budgets every VRTimeCheckInterval until ctx is cancelled,
+// alerting once per period when one is used up.
+func (s *VRTimeService) Run(ctx context.Context) {
+	ticker := time.NewTicker(VRTimeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.check(ctx); err != nil && ctx.Err() == nil {
+			s.logger().Warn("VR time check failed", "error", err)
+		}
+	}
+}
+
+// check alerts for each budget used up in its current period, unless
+// already alerted.
+func (s *VRTimeService) check(ctx context.Context) error {
+	cfg, _ := config.LoadConfigFrom(s.ConfigPath)
+	if s.Alert == nil || !cfg.VRTime.Notify || (cfg.VRTime.DailyMinutes == 0 && cfg.VRTime.WeeklyMinutes == 0) {
+		return nil
+	}
+	p, err := s.Progress(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	for _, b := range []struct {
+		period, when string
+		progress     BudgetProgress
+	}{
+		{"daily", "today", p.Daily},
+		{"weekly", "this week", p.Weekly},
+	} {
+		if !b.progress.Exceeded || !s.markAlerted(b.period, b.progress.Since) {
+			continue
+		}
+		s.Alert(ctx, "Time in VR",
+			fmt.Sprintf("You've been in VR %s %s (%s budget: %s).",
+				formatMinutes(b.progress.Minutes), b.when, b.period, formatMinutes(int64(b.progress.BudgetMinutes))))
+	}
+	return nil
+}
+
+// markAlerted records an alert for the period starting at since. Returns
+// false if one was already sent.
+func (s *VRTimeService) markAlerted(period string, since time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.alerted == nil {
+		s.alerted = make(map[string]time.Time)
+	}
+	if s.alerted[period].Equal(since) {
+		return false
+	}
+	s.alerted[period] = since
+	return true
+}
+
+func (s *VRTimeService) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// budgetPeriods returns the local day and the week starting on Monday that
+// contain now.
+func budgetPeriods(now time.Time) (day, week [2]time.Time) {
+	y, m, d := now.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	day = [2]time.Time{start, start.AddDate(0, 0, 1)}
+	monday := start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	week = [2]time.Time{monday, monday.AddDate(0, 0, 7)}
+	return day, week
+}
+
+// formatMinutes renders minutes as "5h", "45m" or "5h 30m".
+func formatMinutes(minutes int64) string {
+	h, m := minutes/60, minutes%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh %dm", h, m)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+// stubVRTimeStore spends minutes per day in the range.
+type stubVRTimeStore struct {
+	minutesPerDay int
+}
+
+func (s *stubVRTimeStore) TimeInVR(ctx context.Context, since, until time.Time, account string) (time.Duration, error) {
+	days := int(until.Sub(since).Hours()+12) / 24
+	return time.Duration(days*s.minutesPerDay) * time.Minute, nil
+}
+
+func TestVRTimeService(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	cfg := config.DefaultConfig()
+	cfg.VRTime = config.VRTimeConfig{DailyMinutes: 240, WeeklyMinutes: 1200, Notify: true}
+	if err := config.SaveConfigTo(cfg, path); err != nil {
+		t.Fatalf("SaveConfigTo: %v", err)
+	}
+
+	var alerts []string
+	st := &stubVRTimeStore{minutesPerDay: 300}
+	now := time.Date(2024, 6, 15, 20, 0, 0, 0, time.Local) // a Saturday
+	svc := &VRTimeService{
+		Store:      st,
+		ConfigPath: path,
+		Alert:      func(ctx context.Context, title, message string) { alerts = append(alerts, message) },
+		now:        func() time.Time { return now },
+	}
+
+	p, err := svc.Progress(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Progress: %v", err)
+	}
+	if p.Daily.Minutes != 300 || p.Daily.Percent != 125 || !p.Daily.Exceeded ||
+		!p.Daily.Since.Equal(time.Date(2024, 6, 15, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("daily = %+v", p.Daily)
+	}
+	if p.Weekly.Minutes != 2100 || !p.Weekly.Exceeded ||
+		!p.Weekly.Since.Equal(time.Date(2024, 6, 10, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("weekly = %+v", p.Weekly)
+	}
+
+	// One alert per budget and period
+	for range 2 {
+		if err := svc.check(context.Background()); err != nil {
+			t.Fatalf("check: %v", err)
+		}
+	}
+	want := []string{
+		"You've been in VR 5h today (daily budget: 4h).",
+		"You've been in VR 35h this week (weekly budget: 20h).",
+	}
+	if len(alerts) != 2 || alerts[0] != want[0] || alerts[1] != want[1] {
+		t.Errorf("alerts = %q, want %q", alerts, want)
+	}
+	now = now.Add(24 * time.Hour) // Sunday: a new day in the same week
+	svc.check(context.Background())
+	if len(alerts) != 3 {
+		t.Errorf("alerts after a day = %q, want one more", alerts)
+	}
+
+	// Within budget, and without a budget
+	st.minutesPerDay = 30
+	p, _ = svc.Progress(context.Background(), "")
+	if p.Daily.Exceeded || p.Daily.Percent != 12 {
+		t.Errorf("daily within budget = %+v", p.Daily)
+	}
+	cfg.VRTime = config.VRTimeConfig{}
+	config.SaveConfigTo(cfg, path)
+	p, _ = svc.Progress(context.Background(), "")
+	if p.Daily.Exceeded || p.Daily.Percent != 0 || p.Daily.BudgetMinutes != 0 {
+		t.Errorf("daily without budget = %+v", p.Daily)
+	}
+}
+
+func TestBudgetPeriods(t *testing.T) {
+	for _, now := range []time.Time{
+		time.Date(2024, 6, 10, 0, 0, 0, 0, time.Local),   // Monday
+		time.Date(2024, 6, 16, 23, 59, 0, 0, time.Local), // Sunday
+	} {
+		_, week := budgetPeriods(now)
+		if !week[0].Equal(time.Date(2024, 6, 10, 0, 0, 0, 0, time.Local)) ||
+			!week[1].Equal(time.Date(2024, 6, 17, 0, 0, 0, 0, time.Local)) {
+			t.Errorf("week of %v = %v", now, week)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// ErrInvalidWorldFilter is returned for a malformed worlds directory query.
+var ErrInvalidWorldFilter = errors.New("invalid world filter")
+
+// WorldsUsecase defines the worlds directory.
+type WorldsUsecase interface {
+	// List returns one page of the worlds directory.
+	// Returns ErrInvalidWorldFilter for an unknown sort order, or
+	// store.ErrInvalidCursor.
+	List(ctx context.Context, filter store.WorldFilter) (store.WorldPage, error)
+}
+
+// WorldStore defines store operations needed by WorldsService.
+type WorldStore interface {
+	ListWorlds(ctx context.Context, filter store.WorldFilter) (store.WorldPage, error)
+}
+
+// WorldsService implements WorldsUsecase.
+type WorldsService struct {
+	Store WorldStore
+}
+
+// List returns one page of the worlds directory.
+func (s *WorldsService) List(ctx context.Context, filter store.WorldFilter) (store.WorldPage, error) {
+	switch filter.Sort {
+	case "", store.WorldSortLastVisited, store.WorldSortVisits, store.WorldSortTotalTime:
+	default:
+		return store.WorldPage{}, fmt.Errorf("%w: unknown sort %q", ErrInvalidWorldFilter, filter.Sort)
+	}
+	filter.Search = strings.TrimSpace(filter.Search)
+	return s.Store.ListWorlds(ctx, filter)
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubWorldStore struct {
+	filter store.WorldFilter
+}
+
+func (s *stubWorldStore) ListWorlds(ctx context.Context, filter store.WorldFilter) (store.WorldPage, error) {
+	s.filter = filter
+	return store.WorldPage{}, nil
+}
+
+func TestWorldsService_List(t *testing.T) {
+	st := &stubWorldStore{}
+	svc := &WorldsService{Store: st}
+
+	if _, err := svc.List(context.Background(), store.WorldFilter{Sort: "name"}); !errors.Is(err, ErrInvalidWorldFilter) {
+		t.Errorf("unknown sort: err = %v, want ErrInvalidWorldFilter", err)
+	}
+	if _, err := svc.List(context.Background(), store.WorldFilter{Search: " club ", Sort: store.WorldSortVisits}); err != nil {
+		t.Fatal(err)
+	}
+	if st.filter.Search != "club" || st.filter.Sort != store.WorldSortVisits {
+		t.Errorf("store got %+v", st.filter)
+	}
+}
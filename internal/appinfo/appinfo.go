@@ -26,4 +26,12 @@ const (
 
 	// DatabaseFileName is the SQLite database file name.
 	DatabaseFileName = "vrclog.sqlite"
+
+	// DemoDatabaseFileName is the SQLite database file name in demo mode,
+	// so simulated events never mix with real ones.
+	DemoDatabaseFileName = "vrclog-demo.sqlite"
+
+	// LogDirName is the directory in the data directory for the app's own
+	// log files.
+	LogDirName = "logs"
 )
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Settings bundle identification.
+const (
+	BundleFormat  = "vrclog-settings"
+	BundleVersion = 1
+)
+
+// MinBundlePassphraseLength is the shortest passphrase accepted for
+// encrypting secrets in a bundle.
+const MinBundlePassphraseLength = 8
+
+// Key derivation parameters for bundle secrets.
+const (
+	bundleKDF        = "pbkdf2-sha256"
+	bundleIterations = 600000 // OWASP recommendation for PBKDF2-HMAC-SHA256
+	bundleSaltSize   = 16
+)
+
+var (
+	// ErrInvalidBundle is returned when a bundle cannot be read.
+	ErrInvalidBundle = errors.New("invalid settings bundle")
+	// ErrWrongPassphrase is returned when a bundle's secrets cannot be
+	// decrypted with the given passphrase.
+	ErrWrongPassphrase = errors.New("wrong passphrase for settings bundle")
+	// ErrWeakPassphrase is returned when exporting secrets with a passphrase
+	// shorter than MinBundlePassphraseLength.
+	ErrWeakPassphrase = fmt.Errorf("passphrase must be at least %d characters", MinBundlePassphraseLength)
+)
+
+// Bundle carries the settings of one install to another. Config is the
+// config.json object; Secrets, if present, is secrets.json encrypted with
+// a passphrase.
+type Bundle struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	ExportedAt time.Time         `json:"exported_at"`
+	Config     json.RawMessage   `json:"config"`
+	Secrets    *EncryptedSecrets `json:"secrets,omitempty"`
+}
+
+// EncryptedSecrets is a Secrets JSON object encrypted with AES-256-GCM
+// under a key derived from a passphrase.
+type EncryptedSecrets struct {
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewBundle creates a bundle of cfg. If sec is non-nil, it is included,
+// encrypted with passphrase.
+func NewBundle(cfg Config, sec *Secrets, passphrase string, now time.Time) (Bundle, error) {
+	cfg.SchemaVersion = CurrentSchemaVersion
+	cfgJSON, err := json.Marshal(cfg)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("marshal config: %w", err)
+	}
+	b := Bundle{
+		Format:     BundleFormat,
+		Version:    BundleVersion,
+		ExportedAt: now.UTC(),
+		Config:     cfgJSON,
+	}
+	if sec == nil {
+		return b, nil
+	}
+
+	if len(passphrase) < MinBundlePassphraseLength {
+		return Bundle{}, ErrWeakPassphrase
+	}
+	s := *sec
+	s.SchemaVersion = CurrentSchemaVersion
+	plain, err := json.Marshal(s)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("marshal secrets: %w", err)
+	}
+	enc := &EncryptedSecrets{
+		KDF:        bundleKDF,
+		Iterations: bundleIterations,
+		Salt:       make([]byte, bundleSaltSize),
+	}
+	if _, err := rand.Read(enc.Salt); err != nil {
+		return Bundle{}, fmt.Errorf("generate salt: %w", err)
+	}
+	aead, err := bundleCipher(passphrase, enc.Salt, enc.Iterations)
+	if err != nil {
+		return Bundle{}, err
+	}
+	enc.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(enc.Nonce); err != nil {
+		return Bundle{}, fmt.Errorf("generate nonce: %w", err)
+	}
+	enc.Ciphertext = aead.Seal(nil, enc.Nonce, plain, []byte(BundleFormat))
+	b.Secrets = enc
+	return b, nil
+}
+
+// Open returns the config of the bundle and, if the bundle has secrets and
+// passphrase is not empty, the decrypted secrets. Older config schema
+// versions are migrated.
+func (b Bundle) Open(passphrase string) (Config, *Secrets, error) {
+	if b.Format != BundleFormat || b.Version != BundleVersion || len(b.Config) == 0 {
+		return Config{}, nil, fmt.Errorf("%w: not a %s v%d bundle", ErrInvalidBundle, BundleFormat, BundleVersion)
+	}
+	cfg, err := ParseConfigJSON(b.Config)
+	if err != nil {
+		return Config{}, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if b.Secrets == nil || passphrase == "" {
+		return cfg, nil, nil
+	}
+
+	enc := b.Secrets
+	// The iteration cap keeps a crafted bundle from stalling the server
+	if enc.KDF != bundleKDF || enc.Iterations < 1 || enc.Iterations > 10*bundleIterations || len(enc.Salt) == 0 {
+		return Config{}, nil, fmt.Errorf("%w: unsupported secrets encryption", ErrInvalidBundle)
+	}
+	aead, err := bundleCipher(passphrase, enc.Salt, enc.Iterations)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	if len(enc.Nonce) != aead.NonceSize() {
+		return Config{}, nil, fmt.Errorf("%w: bad nonce", ErrInvalidBundle)
+	}
+	plain, err := aead.Open(nil, enc.Nonce, enc.Ciphertext, []byte(BundleFormat))
+	if err != nil {
+		return Config{}, nil, ErrWrongPassphrase
+	}
+	var sec Secrets
+	if err := json.Unmarshal(plain, &sec); err != nil {
+		return Config{}, nil, fmt.Errorf("%w: decode secrets: %v", ErrInvalidBundle, err)
+	}
+	sec.SchemaVersion = CurrentSchemaVersion
+	return cfg, &sec, nil
+}
+
+// bundleCipher derives the AES-256-GCM cipher for a passphrase and salt.
+func bundleCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBundle_RoundTrip(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Port = 9100
+	cfg.NotifyOnLeave = false
+	sec := DefaultSecrets()
+	sec.DiscordWebhookURL = "https://discord.com/api/webhooks/1/abc"
+
+	b, err := NewBundle(cfg, &sec, "correct horse", time.Now())
+	if err != nil {
+		t.Fatalf("NewBundle: %v", err)
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "discord.com") {
+		t.Error("bundle contains secrets in plain text")
+	}
+
+	var decoded Bundle
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	gotCfg, gotSec, err := decoded.Open("correct horse")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if gotCfg.Port != 9100 || gotCfg.NotifyOnLeave {
+		t.Errorf("config = %+v", gotCfg)
+	}
+	if gotSec == nil || gotSec.DiscordWebhookURL != sec.DiscordWebhookURL {
+		t.Errorf("secrets = %v", gotSec)
+	}
+
+	if _, _, err := decoded.Open("wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v", err)
+	}
+	if _, gotSec, err := decoded.Open(""); err != nil || gotSec != nil {
+		t.Errorf("no passphrase: secrets %v, err %v; want config only", gotSec, err)
+	}
+}
+
+func TestBundle_Invalid(t *testing.T) {
+	if _, err := NewBundle(DefaultConfig(), &Secrets{}, "short", time.Now()); !errors.Is(err, ErrWeakPassphrase) {
+		t.Errorf("short passphrase: err = %v", err)
+	}
+
+	for _, b := range []Bundle{
+		{Format: "other", Version: BundleVersion, Config: json.RawMessage(`{}`)},
+		{Format: BundleFormat, Version: BundleVersion + 1, Config: json.RawMessage(`{}`)},
+		{Format: BundleFormat, Version: BundleVersion, Config: json.RawMessage(`{"schema_version": 999}`)},
+	} {
+		if _, _, err := b.Open(""); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("Open(%+v): err = %v, want ErrInvalidBundle", b, err)
+		}
+	}
+}
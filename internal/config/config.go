@@ -4,40 +4,282 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
-)
+	"time"
 
-// CurrentSchemaVersion is the current config schema version.
-const CurrentSchemaVersion = 1
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
+	"github.com/graaaaa/vrclog-companion/internal/logging"
+)
 
 // Environment variable names for config overrides.
 // Priority: Environment > Config File > Default
 const (
-	EnvPort              = "VRCLOG_PORT"
-	EnvLanEnabled        = "VRCLOG_LAN_ENABLED"
-	EnvLogPath           = "VRCLOG_LOG_PATH"
-	EnvDiscordBatchSec   = "VRCLOG_DISCORD_BATCH_SEC"
-	EnvAutoStart         = "VRCLOG_AUTO_START"
-	EnvNotifyOnJoin      = "VRCLOG_NOTIFY_ON_JOIN"
-	EnvNotifyOnLeave     = "VRCLOG_NOTIFY_ON_LEAVE"
-	EnvNotifyOnWorldJoin = "VRCLOG_NOTIFY_ON_WORLD_JOIN"
+	EnvPort               = "VRCLOG_PORT"
+	EnvLanEnabled         = "VRCLOG_LAN_ENABLED"
+	EnvLogPath            = "VRCLOG_LOG_PATH"
+	EnvDiscordBatchSec    = "VRCLOG_DISCORD_BATCH_SEC"
+	EnvAutoStart          = "VRCLOG_AUTO_START"
+	EnvNotifyOnJoin       = "VRCLOG_NOTIFY_ON_JOIN"
+	EnvNotifyOnLeave      = "VRCLOG_NOTIFY_ON_LEAVE"
+	EnvNotifyOnWorldJoin  = "VRCLOG_NOTIFY_ON_WORLD_JOIN"
+	EnvNotifySessionRecap = "VRCLOG_NOTIFY_SESSION_RECAP"
+	EnvNotifyStartupGrace = "VRCLOG_NOTIFY_STARTUP_GRACE_SEC"
+	EnvNotifyReturnAfter  = "VRCLOG_NOTIFY_RETURN_AFTER_DAYS"
+	EnvNotifyMilestones   = "VRCLOG_NOTIFY_MILESTONES"
+	EnvNotifyAuthLockout  = "VRCLOG_NOTIFY_ON_AUTH_LOCKOUT"
+	EnvAppLogLevel        = "VRCLOG_APP_LOG_LEVEL"
+	EnvAppLogFormat       = "VRCLOG_APP_LOG_FORMAT"
 )
 
 // Config holds non-sensitive application configuration.
 type Config struct {
-	SchemaVersion      int      `json:"schema_version"`
-	Port               int      `json:"port"`
-	LanEnabled         bool     `json:"lan_enabled"`
-	LogPath            string   `json:"log_path"`
-	DiscordBatchSec    int      `json:"discord_batch_sec"`
-	AutoStartEnabled   bool     `json:"auto_start_enabled"`
-	NotifyOnJoin       bool     `json:"notify_on_join"`
-	NotifyOnLeave      bool     `json:"notify_on_leave"`
-	NotifyOnWorldJoin  bool     `json:"notify_on_world_join"`
-	CORSAllowedOrigins []string `json:"cors_allowed_origins,omitempty"`
+	SchemaVersion      int                 `json:"schema_version"`
+	Port               int                 `json:"port"`
+	LanEnabled         bool                `json:"lan_enabled"`
+	LogPath            string              `json:"log_path"`
+	DiscordBatchSec    int                 `json:"discord_batch_sec"`
+	AutoStartEnabled   bool                `json:"auto_start"`
+	NotifyOnJoin       bool                `json:"notify_on_join"`
+	NotifyOnLeave      bool                `json:"notify_on_leave"`
+	NotifyOnWorldJoin  bool                `json:"notify_on_world_join"`
+	NotifySessionRecap bool                `json:"notify_session_recap"`         // recap embed when leaving an instance
+	NotifyStartupGrace int                 `json:"notify_startup_grace_sec"`     // no notifications this long after startup
+	NotifyReturnAfter  int                 `json:"notify_return_after_days"`     // notify when a player joins after this many days unseen, 0 = off
+	NotifyMilestones   bool                `json:"notify_milestones"`            // announce milestones such as the 100th visit to a world
+	NotifyAuthLockout  bool                `json:"notify_on_auth_lockout"`       // alert when repeated failed logins lock out an IP in LAN mode
+	DiscordMaxEmbeds   int                 `json:"discord_max_embeds,omitempty"` // embeds per message, 0 = Discord limit
+	DiscordMaxNames    int                 `json:"discord_max_names,omitempty"`  // names listed per embed, 0 = default
+	DiscordMaxChars    int                 `json:"discord_max_chars,omitempty"`  // embed characters per message, 0 = Discord limit
+	DiscordThreadID    string              `json:"discord_thread_id,omitempty"`  // post into this thread of the webhook's channel
+	DiscordUsername    string              `json:"discord_username,omitempty"`   // overrides the webhook's default name
+	DiscordAvatarURL   string              `json:"discord_avatar_url,omitempty"` // overrides the webhook's default avatar
+	CORSAllowedOrigins []string            `json:"cors_allowed_origins,omitempty"`
+	AllowedIPs         []string            `json:"allowed_ips,omitempty"` // IPs or CIDRs allowed to connect in LAN mode; empty allows all
+	DeniedIPs          []string            `json:"denied_ips,omitempty"`  // IPs or CIDRs refused even if allowed
+	LANRedact          []string            `json:"lan_redact,omitempty"`  // event fields hidden from clients other than this PC: player_id, meta, instance_id
+	CSP                map[string][]string `json:"csp,omitempty"`         // extra Content-Security-Policy sources by directive, e.g. script-src
+	NotifyRules        []NotifyRule        `json:"notify_rules,omitempty"`
+	SoundHooks         []SoundHook         `json:"sound_hooks,omitempty"`   // audio cues on joins, leaves and world changes
+	PlayerTags         map[string][]string `json:"player_tags,omitempty"`   // tag -> player IDs or display names, for notify rules
+	FriendTags         []string            `json:"friend_tags"`             // player_tags whose players are grouped as friends in Discord embeds
+	Accounts           []Account           `json:"accounts,omitempty"`      // additional VRChat accounts to ingest
+	BackupDir          string              `json:"backup_dir,omitempty"`    // nightly backups are written here; empty disables them
+	BackupTime         string              `json:"backup_time,omitempty"`   // local time of day to back up, "HH:MM"
+	BackupFormat       string              `json:"backup_format,omitempty"` // BackupFormatSQLite or BackupFormatJSONL
+	BackupKeep         int                 `json:"backup_keep,omitempty"`   // number of backups kept in BackupDir
+	VacuumIntervalDays int                 `json:"vacuum_interval_days"`    // days between automatic VACUUMs, 0 = manual only
+	SlowQueryMs        int                 `json:"slow_query_ms"`           // log database queries slower than this, 0 = off
+	UndoWindowHours    int                 `json:"undo_window_hours"`       // hours deleted events stay restorable in the trash, 0 = delete permanently
+	RollupAfterDays    int                 `json:"rollup_after_days"`       // stats read daily rollups for days older than this, 0 = always count events
+	MinFreeDiskMB      int                 `json:"min_free_disk_mb"`        // warn and stop storing parse failures below this much free space, 0 = off
+	UI                 UIConfig            `json:"ui"`                      // branding of the web UI and overlays
+	RateLimit          RateLimitConfig     `json:"rate_limit"`              // request limits in LAN mode
+	Logging            LoggingConfig       `json:"logging"`                 // the app's own logs
+	VRTime             VRTimeConfig        `json:"vr_time"`                 // budgets for the time spent in VRChat
+	SSE                SSEConfig           `json:"sse"`                     // keep-alive of the SSE streams
+}
+
+// MaxSSEHeartbeatSec is the longest heartbeat interval of the SSE streams.
+const MaxSSEHeartbeatSec = 3600
+
+// SSEConfig sets the heartbeats of the SSE streams: comments keeping idle
+// connections open through proxies that close them after a while.
+// Clients may ask for an interval between the bounds with ?heartbeat=.
+type SSEConfig struct {
+	HeartbeatSec    int `json:"heartbeat_sec"`     // interval unless the client asks for another
+	MinHeartbeatSec int `json:"min_heartbeat_sec"` // shortest interval a client may ask for
+	MaxHeartbeatSec int `json:"max_heartbeat_sec"` // longest interval a client may ask for
+}
+
+// VRTimeConfig sets budgets for the time spent in VRChat. A zero budget
+// means none.
+type VRTimeConfig struct {
+	DailyMinutes  int  `json:"daily_minutes"`  // per local calendar day
+	WeeklyMinutes int  `json:"weekly_minutes"` // per week starting on Monday
+	Notify        bool `json:"notify"`         // alert on Discord when a budget is used up
+}
+
+// Limits of the logging settings.
+const (
+	MaxLogFileSizeMB = 1024
+	MaxLogFiles      = 100
+)
+
+// LoggingConfig configures the app's own logs, as opposed to the VRChat
+// logs it reads.
+type LoggingConfig struct {
+	Level       string `json:"level"`        // debug, info, warn or error
+	Format      string `json:"format"`       // text or json
+	File        bool   `json:"file"`         // also write to logs/vrclog.log in the data directory
+	MaxSizeMB   int    `json:"max_size_mb"`  // rotate the file when it would grow past this
+	RotateDaily bool   `json:"rotate_daily"` // also rotate on the first write of each day
+	MaxFiles    int    `json:"max_files"`    // rotated files kept
+}
+
+// MaxRateLimitBurst is the maximum burst of a rate limit.
+const MaxRateLimitBurst = 1000
+
+// RateLimitConfig is the rate limiting policy of each class of routes in
+// LAN mode. Each class is limited apart from the others.
+type RateLimitConfig struct {
+	Auth     RateLimitPolicy `json:"auth"`     // credential endpoints, e.g. SSE tokens
+	Read     RateLimitPolicy `json:"read"`     // GET requests not in another class
+	Write    RateLimitPolicy `json:"write"`    // other requests not in another class
+	Admin    RateLimitPolicy `json:"admin"`    // config, backups and maintenance
+	Stream   RateLimitPolicy `json:"stream"`   // SSE connections
+	Loopback RateLimitPolicy `json:"loopback"` // all requests from this PC, instead of the other classes
+}
+
+// Policies returns the policies of c by class name.
+func (c *RateLimitConfig) Policies() map[string]*RateLimitPolicy {
+	return map[string]*RateLimitPolicy{
+		"auth":     &c.Auth,
+		"read":     &c.Read,
+		"write":    &c.Write,
+		"admin":    &c.Admin,
+		"stream":   &c.Stream,
+		"loopback": &c.Loopback,
+	}
+}
+
+// RateLimitPolicy allows each client IP Rate requests per second on
+// average, with bursts of up to Burst. A zero Rate turns the limit off.
+type RateLimitPolicy struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// UI languages. An empty language follows the browser.
+const (
+	UILanguageEnglish  = "en"
+	UILanguageJapanese = "ja"
+)
+
+// MaxUITitleLength is the maximum length of the web UI title.
+const MaxUITitleLength = 64
+
+// UIConfig brands the web UI and OBS overlays. They read it at runtime, so
+// changes need no rebuild of the embedded web assets.
+type UIConfig struct {
+	Title       string `json:"title"`        // page and header title
+	AccentColor string `json:"accent_color"` // "#RRGGBB"; empty keeps the built-in color
+	Language    string `json:"language"`     // UILanguageEnglish, UILanguageJapanese or empty
+	HideStats   bool   `json:"hide_stats"`   // hide the stats pages, e.g. when streaming
+}
+
+// Limits of the maintenance settings.
+const (
+	MaxVacuumIntervalDays = 365
+	MaxSlowQueryMs        = 60000
+	MaxUndoWindowHours    = 30 * 24
+	MaxRollupAfterDays    = 3650
+	MaxMinFreeDiskMB      = 1024 * 1024
+)
+
+// Backup formats. Both are gzip-compressed.
+const (
+	BackupFormatSQLite = "sqlite" // copy of the database
+	BackupFormatJSONL  = "jsonl"  // events, one JSON object per line
+)
+
+// MaxAccountNameLength is the maximum length of an account name.
+const MaxAccountNameLength = 64
+
+// Account is an additional VRChat account whose logs are ingested alongside
+// the default one (LogPath). Its events are tagged with Name.
+type Account struct {
+	Name    string `json:"name"`
+	LogPath string `json:"log_path"`
+}
+
+// Notify rule actions.
+const (
+	RuleActionAllow = "allow" // send the notification
+	RuleActionDeny  = "deny"  // suppress the notification
+)
+
+// Notify rule sinks.
+const (
+	RuleSinkDiscord = "discord"
+)
+
+// MaxNotifyRules is the maximum number of notify rules.
+const MaxNotifyRules = 100
+
+// NotifyRule decides whether and how a notification is sent.
+// Rules are evaluated in order and the first rule whose conditions all
+// match decides; if no rule matches, the notification is sent.
+// Empty conditions match anything.
+type NotifyRule struct {
+	// EventTypes limits the rule to these event types (player_join, player_left, world_join).
+	EventTypes []string `json:"event_types,omitempty"`
+	// WorldIDs limits the rule to these worlds (wrld_xxx).
+	WorldIDs []string `json:"world_ids,omitempty"`
+	// InstanceTypes limits the rule to these instance types (public, friends, invite, ...).
+	InstanceTypes []string `json:"instance_types,omitempty"`
+	// PlayerTags limits the rule to join and leave events of players listed
+	// under one of these tags in Config.PlayerTags.
+	PlayerTags []string `json:"player_tags,omitempty"`
+	// TimeWindow limits the rule to a local time of day, "HH:MM-HH:MM".
+	// A window ending before it starts wraps past midnight.
+	TimeWindow string `json:"time_window,omitempty"`
+	// MinPlayers and MaxPlayers limit the rule to instances with this many
+	// players after the event. 0 means no limit.
+	MinPlayers int `json:"min_players,omitempty"`
+	MaxPlayers int `json:"max_players,omitempty"`
+	// Action is RuleActionAllow or RuleActionDeny.
+	Action string `json:"action"`
+	// Sink is where allowed notifications go. Empty means RuleSinkDiscord,
+	// currently the only sink.
+	Sink string `json:"sink,omitempty"`
+	// MentionRoleID mentions this Discord role in allowed notifications.
+	MentionRoleID string `json:"mention_role_id,omitempty"`
+}
+
+// Event fields that LANRedact can hide.
+const (
+	RedactPlayerID   = "player_id"
+	RedactMeta       = "meta"
+	RedactInstanceID = "instance_id"
+)
+
+// MaxSoundHooks is the maximum number of sound hooks.
+const MaxSoundHooks = 20
+
+// Sound hook cooldowns, in seconds.
+const (
+	DefaultSoundHookCooldownSec = 5
+	MaxSoundHookCooldownSec     = 3600
+)
+
+// SoundHook gives an audio cue when a player joins or leaves or the world
+// changes, e.g. for desktop users tabbed out of VRChat: it calls a local
+// URL, such as a soundboard app's trigger, plays a sound file, or both.
+type SoundHook struct {
+	// EventTypes are the events that trigger the hook (player_join,
+	// player_left, world_join). Empty means player_join.
+	EventTypes []string `json:"event_types,omitempty"`
+	// PlayerTags limits join and leave triggers to players listed under
+	// one of these tags in Config.PlayerTags.
+	PlayerTags []string `json:"player_tags,omitempty"`
+	// URL is requested on a trigger. Only loopback and private network
+	// addresses are allowed.
+	URL string `json:"url,omitempty"`
+	// Method is "POST" (default), sending the derived event as JSON, or
+	// "GET" with no body.
+	Method string `json:"method,omitempty"`
+	// Sound is the path of a WAV file played through the OS.
+	Sound string `json:"sound,omitempty"`
+	// CooldownSec is the minimum time between triggers of the hook; more
+	// are dropped. 0 means DefaultSoundHookCooldownSec.
+	CooldownSec int `json:"cooldown_sec,omitempty"`
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -52,6 +294,34 @@ func DefaultConfig() Config {
 		NotifyOnJoin:       true,
 		NotifyOnLeave:      true,
 		NotifyOnWorldJoin:  true,
+		NotifyStartupGrace: 10,
+		NotifyAuthLockout:  true,
+		FriendTags:         []string{"friend", "vip"},
+		UI:                 UIConfig{Title: "VRClog Companion"},
+		RateLimit: RateLimitConfig{
+			Auth:   RateLimitPolicy{Rate: 0.5, Burst: 10},
+			Read:   RateLimitPolicy{Rate: 20, Burst: 40},
+			Write:  RateLimitPolicy{Rate: 10, Burst: 20},
+			Admin:  RateLimitPolicy{Rate: 1, Burst: 10},
+			Stream: RateLimitPolicy{Rate: 1, Burst: 10},
+		},
+		BackupTime:         "03:00",
+		BackupFormat:       BackupFormatSQLite,
+		BackupKeep:         7,
+		VacuumIntervalDays: 30,
+		SlowQueryMs:        500,
+		UndoWindowHours:    72,
+		RollupAfterDays:    30,
+		MinFreeDiskMB:      500,
+		Logging: LoggingConfig{
+			Level:       "info",
+			Format:      "text",
+			File:        true,
+			MaxSizeMB:   10,
+			RotateDaily: true,
+			MaxFiles:    5,
+		},
+		SSE: SSEConfig{HeartbeatSec: 20, MinHeartbeatSec: 5, MaxHeartbeatSec: 120},
 	}
 }
 
@@ -66,7 +336,9 @@ func LoadConfig() (Config, error) {
 	return LoadConfigFrom(path)
 }
 
-// LoadConfigFrom reads config from the specified path.
+// LoadConfigFrom reads config from the specified path. Files ending in
+// .yaml, .yml or .toml are parsed as YAML or TOML with ${ENV_VAR}
+// interpolation; anything else as JSON.
 func LoadConfigFrom(path string) (Config, error) {
 	cfg := DefaultConfig()
 
@@ -76,28 +348,43 @@ func LoadConfigFrom(path string) (Config, error) {
 			// File doesn't exist, use defaults (not an error)
 			return cfg, nil
 		}
-		log.Printf("Warning: failed to read config file: %v, using defaults", err)
+		slog.Warn("failed to read config file, using defaults", "path", path, "error", err)
 		return cfg, nil
 	}
 
-	// Try to parse JSON
-	dec := json.NewDecoder(bytes.NewReader(data))
-	if err := dec.Decode(&cfg); err != nil {
-		log.Printf("Warning: config file is corrupt: %v, using defaults", err)
+	// YAML and TOML are converted to JSON, then everything is parsed as JSON
+	data, err = configJSON(path, data)
+	if err != nil {
+		slog.Warn("config file is corrupt, using defaults", "path", path, "error", err)
 		return DefaultConfig(), nil
 	}
 
-	// Check schema version
-	if cfg.SchemaVersion != CurrentSchemaVersion {
-		log.Printf("Warning: config schema version mismatch (got %d, expected %d), using defaults",
-			cfg.SchemaVersion, CurrentSchemaVersion)
+	cfg, err = ParseConfigJSON(data)
+	if err != nil {
+		slog.Warn("invalid config file, using defaults", "path", path, "error", err)
 		return DefaultConfig(), nil
 	}
+	return cfg, nil
+}
 
-	// Normalize/validate values
-	cfg = normalizeConfig(cfg)
+// ParseConfigJSON decodes a JSON config over the defaults, upgrading older
+// schema versions and normalizing values like LoadConfigFrom, but returns
+// an error instead of falling back to defaults.
+func ParseConfigJSON(data []byte) (Config, error) {
+	// Upgrade older schema versions so settings survive upgrades
+	data, err := migrateConfig(data)
+	if err != nil {
+		return DefaultConfig(), err
+	}
 
-	return cfg, nil
+	cfg := DefaultConfig()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("config file is corrupt: %w", err)
+	}
+
+	// Normalize/validate values
+	return normalizeConfig(cfg), nil
 }
 
 // normalizeConfig validates and normalizes config values.
@@ -117,6 +404,198 @@ func normalizeConfig(cfg Config) Config {
 		cfg.DiscordBatchSec = defaults.DiscordBatchSec
 	}
 
+	// Validate startup grace seconds
+	if cfg.NotifyStartupGrace < 0 {
+		cfg.NotifyStartupGrace = defaults.NotifyStartupGrace
+	}
+
+	// Validate long-absence threshold
+	if cfg.NotifyReturnAfter < 0 {
+		cfg.NotifyReturnAfter = 0
+	}
+
+	// Negative payload limits mean "use the default"
+	cfg.DiscordMaxEmbeds = max(cfg.DiscordMaxEmbeds, 0)
+	cfg.DiscordMaxNames = max(cfg.DiscordMaxNames, 0)
+	cfg.DiscordMaxChars = max(cfg.DiscordMaxChars, 0)
+
+	// Drop invalid webhook overrides; Discord rejects the whole request otherwise
+	if err := ValidateDiscordThreadID(cfg.DiscordThreadID); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "discord_thread_id", "error", err)
+		cfg.DiscordThreadID = ""
+	}
+	if err := ValidateDiscordUsername(cfg.DiscordUsername); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "discord_username", "error", err)
+		cfg.DiscordUsername = ""
+	}
+	if err := ValidateDiscordAvatarURL(cfg.DiscordAvatarURL); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "discord_avatar_url", "error", err)
+		cfg.DiscordAvatarURL = ""
+	}
+
+	// Fall back to the default branding field by field
+	if err := ValidateUITitle(cfg.UI.Title); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "ui.title", "error", err)
+		cfg.UI.Title = defaults.UI.Title
+	}
+	if err := ValidateUIAccentColor(cfg.UI.AccentColor); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "ui.accent_color", "error", err)
+		cfg.UI.AccentColor = defaults.UI.AccentColor
+	}
+	if err := ValidateUILanguage(cfg.UI.Language); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "ui.language", "error", err)
+		cfg.UI.Language = defaults.UI.Language
+	}
+
+	// Fall back to the default rate limit policy class by class
+	for name, p := range cfg.RateLimit.Policies() {
+		if err := ValidateRateLimitPolicy(*p); err != nil {
+			slog.Warn("ignoring invalid config value", "field", "rate_limit."+name, "error", err)
+			*p = *defaults.RateLimit.Policies()[name]
+		}
+	}
+
+	// Drop invalid notify rules rather than discarding the whole config
+	if len(cfg.NotifyRules) > 0 {
+		rules := make([]NotifyRule, 0, len(cfg.NotifyRules))
+		for i, r := range cfg.NotifyRules {
+			if err := ValidateNotifyRule(r); err != nil {
+				slog.Warn("ignoring invalid notify rule", "index", i, "error", err)
+				continue
+			}
+			rules = append(rules, r)
+		}
+		cfg.NotifyRules = rules
+	}
+
+	// Likewise for sound hooks
+	if len(cfg.SoundHooks) > 0 {
+		hooks := make([]SoundHook, 0, len(cfg.SoundHooks))
+		for i, h := range cfg.SoundHooks {
+			if err := ValidateSoundHook(h); err != nil {
+				slog.Warn("ignoring invalid sound hook", "index", i, "error", err)
+				continue
+			}
+			if len(hooks) == MaxSoundHooks {
+				slog.Warn("ignoring extra sound hooks", "max", MaxSoundHooks)
+				break
+			}
+			hooks = append(hooks, h)
+		}
+		cfg.SoundHooks = hooks
+	}
+
+	// Fall back to defaults for invalid backup settings
+	if err := ValidateBackupTime(cfg.BackupTime); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "backup_time", "error", err)
+		cfg.BackupTime = defaults.BackupTime
+	}
+	if err := ValidateBackupFormat(cfg.BackupFormat); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "backup_format", "error", err)
+		cfg.BackupFormat = defaults.BackupFormat
+	}
+	if cfg.BackupKeep < 1 {
+		cfg.BackupKeep = defaults.BackupKeep
+	}
+	if err := ValidateVacuumIntervalDays(cfg.VacuumIntervalDays); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "vacuum_interval_days", "error", err)
+		cfg.VacuumIntervalDays = defaults.VacuumIntervalDays
+	}
+	if err := ValidateSlowQueryMs(cfg.SlowQueryMs); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "slow_query_ms", "error", err)
+		cfg.SlowQueryMs = defaults.SlowQueryMs
+	}
+	if err := ValidateUndoWindowHours(cfg.UndoWindowHours); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "undo_window_hours", "error", err)
+		cfg.UndoWindowHours = defaults.UndoWindowHours
+	}
+	if err := ValidateRollupAfterDays(cfg.RollupAfterDays); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "rollup_after_days", "error", err)
+		cfg.RollupAfterDays = defaults.RollupAfterDays
+	}
+	if err := ValidateMinFreeDiskMB(cfg.MinFreeDiskMB); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "min_free_disk_mb", "error", err)
+		cfg.MinFreeDiskMB = defaults.MinFreeDiskMB
+	}
+
+	// Fall back to the default logging settings field by field
+	if err := ValidateLogLevel(cfg.Logging.Level); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "logging.level", "error", err)
+		cfg.Logging.Level = defaults.Logging.Level
+	}
+	if err := logging.ValidateFormat(cfg.Logging.Format); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "logging.format", "error", err)
+		cfg.Logging.Format = defaults.Logging.Format
+	}
+	if cfg.Logging.MaxSizeMB < 1 || cfg.Logging.MaxSizeMB > MaxLogFileSizeMB {
+		slog.Warn("ignoring invalid config value", "field", "logging.max_size_mb", "min", 1, "max", MaxLogFileSizeMB)
+		cfg.Logging.MaxSizeMB = defaults.Logging.MaxSizeMB
+	}
+	if cfg.Logging.MaxFiles < 1 || cfg.Logging.MaxFiles > MaxLogFiles {
+		slog.Warn("ignoring invalid config value", "field", "logging.max_files", "min", 1, "max", MaxLogFiles)
+		cfg.Logging.MaxFiles = defaults.Logging.MaxFiles
+	}
+
+	if err := ValidateVRTime(cfg.VRTime); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "vr_time", "error", err)
+		cfg.VRTime = defaults.VRTime
+	}
+
+	if err := ValidateSSE(cfg.SSE); err != nil {
+		slog.Warn("ignoring invalid config value", "field", "sse", "error", err)
+		cfg.SSE = defaults.SSE
+	}
+
+	// Drop invalid IP entries. An allowlist with none left would allow
+	// everyone, so it falls back to this PC only.
+	allowed := len(cfg.AllowedIPs) > 0
+	cfg.AllowedIPs = validIPEntries("allowed_ips", cfg.AllowedIPs)
+	if allowed && len(cfg.AllowedIPs) == 0 {
+		slog.Warn("allowed_ips has no valid entries, only this PC can connect")
+		cfg.AllowedIPs = []string{"127.0.0.1"}
+	}
+	cfg.DeniedIPs = validIPEntries("denied_ips", cfg.DeniedIPs)
+
+	// Drop unknown fields to redact
+	if len(cfg.LANRedact) > 0 {
+		fields := make([]string, 0, len(cfg.LANRedact))
+		for _, f := range cfg.LANRedact {
+			if err := ValidateRedactField(f); err != nil {
+				slog.Warn("ignoring invalid config value", "field", "lan_redact", "error", err)
+				continue
+			}
+			fields = append(fields, f)
+		}
+		cfg.LANRedact = fields
+	}
+
+	// Drop CSP directives that would break the policy
+	for directive, sources := range cfg.CSP {
+		if err := ValidateCSPDirective(directive, sources); err != nil {
+			slog.Warn("ignoring invalid config value", "field", "csp."+directive, "error", err)
+			delete(cfg.CSP, directive)
+		}
+	}
+
+	// Drop invalid or duplicate accounts
+	if len(cfg.Accounts) > 0 {
+		accounts := make([]Account, 0, len(cfg.Accounts))
+		seen := make(map[string]bool, len(cfg.Accounts))
+		for i, a := range cfg.Accounts {
+			if err := ValidateAccount(a); err != nil {
+				slog.Warn("ignoring invalid account", "index", i, "error", err)
+				continue
+			}
+			if seen[a.Name] {
+				slog.Warn("ignoring account with a duplicate name", "index", i, "name", a.Name)
+				continue
+			}
+			seen[a.Name] = true
+			accounts = append(accounts, a)
+		}
+		cfg.Accounts = accounts
+	}
+
 	return cfg
 }
 
@@ -131,7 +610,12 @@ func SaveConfig(cfg Config) error {
 }
 
 // SaveConfigTo writes config to the specified path atomically.
+// Returns ErrHandEditedConfig if path is a YAML or TOML file.
 func SaveConfigTo(cfg Config, path string) error {
+	if isHandEditedConfig(path) {
+		return ErrHandEditedConfig
+	}
+
 	// Ensure schema version is set
 	cfg.SchemaVersion = CurrentSchemaVersion
 
@@ -185,6 +669,43 @@ func ApplyEnvOverrides(cfg Config) Config {
 		cfg.NotifyOnWorldJoin = parseBool(v)
 	}
 
+	// Notify session recap
+	if v := os.Getenv(EnvNotifySessionRecap); v != "" {
+		cfg.NotifySessionRecap = parseBool(v)
+	}
+
+	// Notify startup grace seconds
+	if v := os.Getenv(EnvNotifyStartupGrace); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec >= 0 {
+			cfg.NotifyStartupGrace = sec
+		}
+	}
+
+	// Notify long-absence threshold days
+	if v := os.Getenv(EnvNotifyReturnAfter); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days >= 0 {
+			cfg.NotifyReturnAfter = days
+		}
+	}
+
+	// Notify milestones
+	if v := os.Getenv(EnvNotifyMilestones); v != "" {
+		cfg.NotifyMilestones = parseBool(v)
+	}
+
+	// Notify on auth lockout
+	if v := os.Getenv(EnvNotifyAuthLockout); v != "" {
+		cfg.NotifyAuthLockout = parseBool(v)
+	}
+
+	// App log level and format
+	if v := os.Getenv(EnvAppLogLevel); v != "" && ValidateLogLevel(v) == nil {
+		cfg.Logging.Level = v
+	}
+	if v := os.Getenv(EnvAppLogFormat); v != "" && logging.ValidateFormat(v) == nil {
+		cfg.Logging.Format = v
+	}
+
 	return cfg
 }
 
@@ -195,3 +716,420 @@ func parseBool(s string) bool {
 	s = strings.ToLower(strings.TrimSpace(s))
 	return s == "true" || s == "1" || s == "yes" || s == "on"
 }
+
+// MaxDiscordUsernameLength is the Discord limit for webhook username overrides.
+const MaxDiscordUsernameLength = 80
+
+// ValidateDiscordThreadID checks that id is empty or a Discord snowflake.
+func ValidateDiscordThreadID(id string) error {
+	if id == "" {
+		return nil
+	}
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		return fmt.Errorf("invalid thread ID %q", id)
+	}
+	return nil
+}
+
+// ValidateDiscordUsername checks a webhook username override against the
+// restrictions Discord enforces. An empty name keeps the webhook's default.
+func ValidateDiscordUsername(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len([]rune(name)) > MaxDiscordUsernameLength {
+		return fmt.Errorf("username longer than %d characters", MaxDiscordUsernameLength)
+	}
+	if strings.Contains(strings.ToLower(name), "discord") {
+		return errors.New(`username must not contain "discord"`)
+	}
+	return nil
+}
+
+// ValidateDiscordAvatarURL checks that u is empty or an http(s) URL.
+func ValidateDiscordAvatarURL(u string) error {
+	if u == "" {
+		return nil
+	}
+	if !strings.HasPrefix(u, "https://") && !strings.HasPrefix(u, "http://") {
+		return fmt.Errorf("invalid avatar URL %q", u)
+	}
+	return nil
+}
+
+// ValidateUITitle checks that title is not blank and at most
+// MaxUITitleLength characters.
+func ValidateUITitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return errors.New("title must not be empty")
+	}
+	if len([]rune(title)) > MaxUITitleLength {
+		return fmt.Errorf("title longer than %d characters", MaxUITitleLength)
+	}
+	return nil
+}
+
+// ValidateUIAccentColor checks that c is empty or a "#RRGGBB" color.
+func ValidateUIAccentColor(c string) error {
+	if c == "" {
+		return nil
+	}
+	if len(c) != 7 || c[0] != '#' {
+		return fmt.Errorf("accent color must be #RRGGBB, got %q", c)
+	}
+	if _, err := strconv.ParseUint(c[1:], 16, 32); err != nil {
+		return fmt.Errorf("accent color must be #RRGGBB, got %q", c)
+	}
+	return nil
+}
+
+// ValidateUILanguage checks that lang is empty or a supported UI language.
+func ValidateUILanguage(lang string) error {
+	switch lang {
+	case "", UILanguageEnglish, UILanguageJapanese:
+		return nil
+	}
+	return fmt.Errorf("unsupported language %q", lang)
+}
+
+// ValidateVRTime checks that the budgets of c are between 0 (no budget)
+// and the length of a day or week.
+func ValidateVRTime(c VRTimeConfig) error {
+	if c.DailyMinutes < 0 || c.DailyMinutes > 24*60 {
+		return fmt.Errorf("daily_minutes must be between 0 and %d", 24*60)
+	}
+	if c.WeeklyMinutes < 0 || c.WeeklyMinutes > 7*24*60 {
+		return fmt.Errorf("weekly_minutes must be between 0 and %d", 7*24*60)
+	}
+	return nil
+}
+
+// ValidateSSE checks that the heartbeat intervals of c are between 1 and
+// MaxSSEHeartbeatSec seconds and the default is within the bounds.
+func ValidateSSE(c SSEConfig) error {
+	if c.MinHeartbeatSec < 1 || c.MaxHeartbeatSec > MaxSSEHeartbeatSec || c.MinHeartbeatSec > c.MaxHeartbeatSec {
+		return fmt.Errorf("min_heartbeat_sec and max_heartbeat_sec must be between 1 and %d, min first", MaxSSEHeartbeatSec)
+	}
+	if c.HeartbeatSec < c.MinHeartbeatSec || c.HeartbeatSec > c.MaxHeartbeatSec {
+		return fmt.Errorf("heartbeat_sec must be between min_heartbeat_sec and max_heartbeat_sec")
+	}
+	return nil
+}
+
+// ValidateCSPDirective checks that directive is a CSP directive name such
+// as "script-src" and that none of its sources could end the directive or
+// the header early.
+func ValidateCSPDirective(directive string, sources []string) error {
+	if directive == "" || strings.Trim(directive, "abcdefghijklmnopqrstuvwxyz-") != "" {
+		return fmt.Errorf("invalid directive name")
+	}
+	for _, src := range sources {
+		if src == "" || strings.ContainsAny(src, ";, \t\r\n") {
+			return fmt.Errorf("invalid source %q", src)
+		}
+	}
+	return nil
+}
+
+// ParseIPList parses IPs and CIDRs such as "192.168.1.20" or
+// "192.168.1.0/24" into prefixes; a single IP becomes a prefix of its
+// full length.
+func ParseIPList(list []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(list))
+	for _, entry := range list {
+		p, err := parseIPEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+// ValidateIPList checks that every entry of list is an IP or a CIDR.
+func ValidateIPList(list []string) error {
+	_, err := ParseIPList(list)
+	return err
+}
+
+func parseIPEntry(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		p, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil || addr.Zone() != "" {
+		return netip.Prefix{}, fmt.Errorf("invalid IP %q", entry)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validIPEntries returns the entries of list that parse, logging the others.
+func validIPEntries(field string, list []string) []string {
+	if len(list) == 0 {
+		return list
+	}
+	valid := make([]string, 0, len(list))
+	for _, entry := range list {
+		if _, err := parseIPEntry(entry); err != nil {
+			slog.Warn("ignoring invalid config value", "field", field, "error", err)
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	return valid
+}
+
+// ValidateRateLimitPolicy checks that p is off (zero rate) or has a
+// positive rate and a burst between 1 and MaxRateLimitBurst.
+func ValidateRateLimitPolicy(p RateLimitPolicy) error {
+	if p.Rate < 0 {
+		return fmt.Errorf("rate must not be negative, got %g", p.Rate)
+	}
+	if p.Rate > 0 && (p.Burst < 1 || p.Burst > MaxRateLimitBurst) {
+		return fmt.Errorf("burst must be between 1 and %d", MaxRateLimitBurst)
+	}
+	return nil
+}
+
+// ValidateVacuumIntervalDays checks that days is between 0 (manual VACUUM
+// only) and MaxVacuumIntervalDays.
+func ValidateVacuumIntervalDays(days int) error {
+	if days < 0 || days > MaxVacuumIntervalDays {
+		return fmt.Errorf("must be between 0 and %d", MaxVacuumIntervalDays)
+	}
+	return nil
+}
+
+// ValidateUndoWindowHours checks that hours is between 0 (deletions are
+// permanent) and MaxUndoWindowHours.
+func ValidateUndoWindowHours(hours int) error {
+	if hours < 0 || hours > MaxUndoWindowHours {
+		return fmt.Errorf("must be between 0 and %d", MaxUndoWindowHours)
+	}
+	return nil
+}
+
+// ValidateRollupAfterDays checks that days is between 0 (stats always count
+// events) and MaxRollupAfterDays.
+func ValidateRollupAfterDays(days int) error {
+	if days < 0 || days > MaxRollupAfterDays {
+		return fmt.Errorf("must be between 0 and %d", MaxRollupAfterDays)
+	}
+	return nil
+}
+
+// ValidateMinFreeDiskMB checks that mb is between 0 (disk space is not
+// monitored) and MaxMinFreeDiskMB.
+func ValidateMinFreeDiskMB(mb int) error {
+	if mb < 0 || mb > MaxMinFreeDiskMB {
+		return fmt.Errorf("must be between 0 and %d", MaxMinFreeDiskMB)
+	}
+	return nil
+}
+
+// ValidateSlowQueryMs checks that ms is between 0 (slow query logging off)
+// and MaxSlowQueryMs.
+func ValidateSlowQueryMs(ms int) error {
+	if ms < 0 || ms > MaxSlowQueryMs {
+		return fmt.Errorf("must be between 0 and %d", MaxSlowQueryMs)
+	}
+	return nil
+}
+
+// ValidateLogLevel checks that level is debug, info, warn or error.
+func ValidateLogLevel(level string) error {
+	_, err := logging.ParseLevel(level)
+	return err
+}
+
+// ValidateBackupTime checks that t is a time of day in "HH:MM" form.
+func ValidateBackupTime(t string) error {
+	if _, err := time.Parse("15:04", t); err != nil {
+		return fmt.Errorf("must be HH:MM, got %q", t)
+	}
+	return nil
+}
+
+// ValidateBackupFormat checks that f is a known backup format.
+func ValidateBackupFormat(f string) error {
+	if f != BackupFormatSQLite && f != BackupFormatJSONL {
+		return fmt.Errorf("must be %q or %q, got %q", BackupFormatSQLite, BackupFormatJSONL, f)
+	}
+	return nil
+}
+
+// ValidateAccount checks that an account has a name of at most
+// MaxAccountNameLength characters and a log path. The path is required
+// because auto-detection would find the default account's logs.
+func ValidateAccount(a Account) error {
+	if strings.TrimSpace(a.Name) == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(a.Name)) > MaxAccountNameLength {
+		return fmt.Errorf("name must be at most %d characters", MaxAccountNameLength)
+	}
+	if strings.TrimSpace(a.LogPath) == "" {
+		return errors.New("log_path is required")
+	}
+	return nil
+}
+
+// ValidateNotifyRule checks that a notify rule has a valid action, sink,
+// and conditions.
+func ValidateNotifyRule(r NotifyRule) error {
+	if r.Action != RuleActionAllow && r.Action != RuleActionDeny {
+		return fmt.Errorf("invalid action %q", r.Action)
+	}
+	if r.Sink != "" && r.Sink != RuleSinkDiscord {
+		return fmt.Errorf("invalid sink %q", r.Sink)
+	}
+	if r.MentionRoleID != "" {
+		if r.Action != RuleActionAllow {
+			return errors.New("mention_role_id requires action allow")
+		}
+		if _, err := strconv.ParseUint(r.MentionRoleID, 10, 64); err != nil {
+			return fmt.Errorf("invalid role ID %q", r.MentionRoleID)
+		}
+	}
+	if r.TimeWindow != "" {
+		if _, _, err := ParseTimeWindow(r.TimeWindow); err != nil {
+			return err
+		}
+	}
+	if r.MinPlayers < 0 || r.MaxPlayers < 0 {
+		return errors.New("player counts must be non-negative")
+	}
+	if r.MaxPlayers > 0 && r.MinPlayers > r.MaxPlayers {
+		return errors.New("min_players must not exceed max_players")
+	}
+	for _, tag := range r.PlayerTags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("empty player tag")
+		}
+	}
+	for _, t := range r.EventTypes {
+		switch t {
+		case event.TypePlayerJoin, event.TypePlayerLeft, event.TypeWorldJoin:
+		default:
+			return fmt.Errorf("invalid event type %q", t)
+		}
+	}
+	for _, t := range r.InstanceTypes {
+		if !instance.IsValidType(t) {
+			return fmt.Errorf("invalid instance type %q", t)
+		}
+	}
+	return nil
+}
+
+// ValidateSoundHook checks that h has a URL or a sound, valid event types,
+// and a cooldown between 0 and MaxSoundHookCooldownSec. The URL must be
+// http or https on localhost or a loopback or private network address, as
+// the hook is meant for apps on this PC or LAN.
+func ValidateSoundHook(h SoundHook) error {
+	if h.URL == "" && h.Sound == "" {
+		return errors.New("url or sound is required")
+	}
+	if h.URL != "" {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid URL %q", h.URL)
+		}
+		if host := u.Hostname(); host != "localhost" {
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !(addr.IsLoopback() || addr.IsPrivate()) {
+				return fmt.Errorf("URL %q is not on localhost or a private network", h.URL)
+			}
+		}
+	}
+	switch h.Method {
+	case "", "GET", "POST":
+	default:
+		return fmt.Errorf("invalid method %q", h.Method)
+	}
+	if h.CooldownSec < 0 || h.CooldownSec > MaxSoundHookCooldownSec {
+		return fmt.Errorf("cooldown_sec must be between 0 and %d", MaxSoundHookCooldownSec)
+	}
+	for _, tag := range h.PlayerTags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("empty player tag")
+		}
+	}
+	for _, t := range h.EventTypes {
+		switch t {
+		case event.TypePlayerJoin, event.TypePlayerLeft, event.TypeWorldJoin:
+		default:
+			return fmt.Errorf("invalid event type %q", t)
+		}
+	}
+	return nil
+}
+
+// ValidatePlayerTags checks that tag names and the players listed under
+// them are not blank.
+func ValidatePlayerTags(tags map[string][]string) error {
+	for tag, players := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("empty tag name")
+		}
+		for _, p := range players {
+			if strings.TrimSpace(p) == "" {
+				return fmt.Errorf("tag %q lists an empty player", tag)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateRedactField checks that f is an event field LANRedact can hide.
+func ValidateRedactField(f string) error {
+	switch f {
+	case RedactPlayerID, RedactMeta, RedactInstanceID:
+		return nil
+	}
+	return fmt.Errorf("unknown field %q (want %s, %s or %s)", f, RedactPlayerID, RedactMeta, RedactInstanceID)
+}
+
+// ValidateFriendTags checks that no friend tag is blank.
+func ValidateFriendTags(tags []string) error {
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) == "" {
+			return errors.New("empty tag name")
+		}
+	}
+	return nil
+}
+
+// ParseTimeWindow parses a local time window "HH:MM-HH:MM" into minutes
+// since midnight. The end is exclusive; an end before the start wraps past
+// midnight.
+func ParseTimeWindow(w string) (start, end int, err error) {
+	from, to, ok := strings.Cut(w, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("time window must be HH:MM-HH:MM, got %q", w)
+	}
+	start, err1 := parseClock(from)
+	end, err2 := parseClock(to)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("time window must be HH:MM-HH:MM, got %q", w)
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("time window %q is empty", w)
+	}
+	return start, end, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
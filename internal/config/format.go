@@ -0,0 +1,177 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// handEditedConfigNames are looked for in the data directory before
+// config.json, in this order. They use the same keys as config.json.
+var handEditedConfigNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// ErrHandEditedConfig is returned when saving to a YAML or TOML config file.
+// Those are never rewritten, so comments and ${ENV_VAR} references survive.
+var ErrHandEditedConfig = errors.New("config file is YAML or TOML; edit it by hand")
+
+// envRefPattern matches ${NAME} references in YAML and TOML config files.
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// isHandEditedConfig reports whether path is a YAML or TOML config file.
+func isHandEditedConfig(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// configJSON converts the contents of a config file to JSON, so every
+// format is decoded with the json tags and rules of Config. In YAML and
+// TOML files, ${NAME} references in string values are replaced with
+// environment variables after parsing, so a value cannot change the
+// structure of the file; JSON files are returned unchanged for backward
+// compatibility.
+func configJSON(path string, data []byte) ([]byte, error) {
+	var v any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("parse YAML: %w", err)
+		}
+	case ".toml":
+		m := map[string]any{}
+		if err := toml.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("parse TOML: %w", err)
+		}
+		v = m
+	default:
+		return data, nil
+	}
+	out, err := json.Marshal(interpolateEnv(v, reflect.TypeFor[Config]()))
+	if err != nil {
+		return nil, fmt.Errorf("convert to JSON: %w", err)
+	}
+	return out, nil
+}
+
+// interpolateEnv replaces ${NAME} in the string values of v, a parsed YAML
+// or TOML document, with the value of environment variable NAME. Unset
+// variables are replaced with "" and logged. t is the type v decodes to,
+// if known: a string that is a single reference, e.g. port: "${PORT}",
+// becomes a number or boolean where t has one.
+func interpolateEnv(v any, t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case string:
+		return interpolateString(v, t)
+	case map[string]any:
+		for k, elem := range v {
+			v[k] = interpolateEnv(elem, fieldType(t, k))
+		}
+	case []map[string]any: // TOML arrays of tables
+		for _, elem := range v {
+			interpolateEnv(elem, elemType(t))
+		}
+	case []any:
+		for i, elem := range v {
+			v[i] = interpolateEnv(elem, elemType(t))
+		}
+	}
+	return v
+}
+
+// interpolateString replaces the references in s, converting the result
+// to the kind of t if s is a single reference.
+func interpolateString(s string, t reflect.Type) any {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	out := envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			slog.Warn("config references an unset environment variable", "name", name)
+		}
+		return v
+	})
+	if t == nil || envRefPattern.FindString(s) != s || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return out
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		// Left as a string if not a number, for the decoder to report
+		if n := strings.TrimSpace(out); jsonNumberPattern.MatchString(n) {
+			return json.Number(n)
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(strings.TrimSpace(out)); err == nil {
+			return b
+		}
+	}
+	return out
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// jsonNumberPattern matches a JSON number.
+var jsonNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// fieldType returns the type of the JSON field key of t, a struct or map
+// type, or nil if unknown.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		var folded reflect.Type
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if name == key {
+				return f.Type
+			}
+			// encoding/json also matches keys case-insensitively
+			if folded == nil && strings.EqualFold(name, key) {
+				folded = f.Type
+			}
+		}
+		return folded
+	}
+	return nil
+}
+
+// elemType returns the element type of t, a slice or array type, or nil
+// if unknown.
+func elemType(t reflect.Type) reflect.Type {
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// configMigrations[i] upgrades a decoded config object from schema version
+// i+1 to i+2. Append a function here, rather than editing an old one, when
+// a release renames or reinterprets a config key.
+var configMigrations = []func(m map[string]any){
+	migrateConfigV1ToV2,
+}
+
+// CurrentSchemaVersion is the current config schema version.
+const CurrentSchemaVersion = 2
+
+// migrateConfigV1ToV2 renames auto_start_enabled to auto_start, matching
+// the VRCLOG_AUTO_START environment variable.
+func migrateConfigV1ToV2(m map[string]any) {
+	renameConfigKey(m, "auto_start_enabled", "auto_start")
+}
+
+// migrateConfig upgrades a JSON config object to CurrentSchemaVersion.
+// A missing schema_version means the current one (hand-written YAML/TOML).
+// Configs from a newer version are rejected, since their keys may mean
+// something else.
+func migrateConfig(data []byte) ([]byte, error) {
+	var m map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep integers exact
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("config file is corrupt: %w", err)
+	}
+	if m == nil {
+		return data, nil
+	}
+
+	version := CurrentSchemaVersion
+	if raw, ok := m["schema_version"]; ok {
+		n, ok := raw.(json.Number)
+		v, err := n.Int64()
+		if !ok || err != nil {
+			return nil, fmt.Errorf("schema_version must be an integer, got %v", raw)
+		}
+		version = int(v)
+	}
+	if version < 1 || version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("unsupported config schema version %d (this build supports up to %d)",
+			version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return data, nil
+	}
+
+	for ; version < CurrentSchemaVersion; version++ {
+		configMigrations[version-1](m)
+		slog.Info("migrated config", "from_version", version, "to_version", version+1)
+	}
+	m["schema_version"] = CurrentSchemaVersion
+	return json.Marshal(m)
+}
+
+// renameConfigKey moves m[from] to m[to] unless to is already set.
+func renameConfigKey(m map[string]any, from, to string) {
+	v, ok := m[from]
+	if !ok {
+		return
+	}
+	delete(m, from)
+	if _, exists := m[to]; !exists {
+		m[to] = v
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFrom_MigratesV1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"schema_version": 1, "port": 9001, "auto_start_enabled": true, "discord_thread_id": "123456789012345678"}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfigFrom(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Port != 9001 || !cfg.AutoStartEnabled || cfg.DiscordThreadID != "123456789012345678" {
+		t.Errorf("settings lost in migration: %+v", cfg)
+	}
+	if cfg.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", cfg.SchemaVersion, CurrentSchemaVersion)
+	}
+
+	// Saving writes the current schema
+	if err := SaveConfigTo(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"auto_start": true`) || strings.Contains(string(data), "auto_start_enabled") {
+		t.Errorf("saved config = %s", data)
+	}
+}
+
+func TestMigrateConfig_KeepsNewKey(t *testing.T) {
+	out, err := migrateConfig([]byte(`{"schema_version": 1, "auto_start_enabled": true, "auto_start": false}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"auto_start":false`) || strings.Contains(string(out), "auto_start_enabled") {
+		t.Errorf("migrated = %s", out)
+	}
+}
+
+func TestMigrateConfig_RejectsUnsupported(t *testing.T) {
+	for _, content := range []string{
+		`{"schema_version": 0}`,
+		`{"schema_version": 999}`,
+		`{"schema_version": "two"}`,
+	} {
+		if _, err := migrateConfig([]byte(content)); err == nil {
+			t.Errorf("migrateConfig(%s) succeeded", content)
+		}
+	}
+}
+
+func TestLoadSecretsFrom_AcceptsOlderSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	content := `{"schema_version": 1, "basic_auth_username": "admin", "basic_auth_password": "pw"}`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sec, status, err := LoadSecretsFrom(path)
+	if err != nil || status != SecretsLoaded {
+		t.Fatalf("status = %v, err = %v", status, err)
+	}
+	if sec.BasicAuthPassword.Value() != "pw" {
+		t.Error("v1 secrets were discarded")
+	}
+}
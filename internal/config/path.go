@@ -2,18 +2,119 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/graaaaa/vrclog-companion/internal/appinfo"
 )
 
+// Environment variables that move the data directory, see DataDir.
+const (
+	EnvDataDir  = "VRCLOG_DATA_DIR"
+	EnvPortable = "VRCLOG_PORTABLE"
+)
+
+// Overrides set by the --data-dir, --portable and --config flags. Empty
+// means the per-user default.
+var (
+	dataDirOverride    string
+	portable           bool
+	configPathOverride string
+)
+
+// SetDataDir makes the data directory (config, secrets, database, lock)
+// dir instead of the per-user default, so independent profiles can run side
+// by side. Call before anything else in this package.
+func SetDataDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("data dir %q: %w", dir, err)
+	}
+	dataDirOverride = abs
+	return nil
+}
+
+// SetPortable keeps the data directory next to the executable, e.g. for an
+// install on a USB stick. SetDataDir and VRCLOG_DATA_DIR take precedence.
+// Call before anything else in this package.
+func SetPortable() {
+	portable = true
+}
+
+// Portable reports whether the data directory is kept next to the
+// executable, set with SetPortable or VRCLOG_PORTABLE.
+func Portable() bool {
+	return portable || parseBool(os.Getenv(EnvPortable))
+}
+
+// overrideDataDir returns the data directory chosen by SetDataDir,
+// VRCLOG_DATA_DIR or portable mode, in that order, or "" for the per-user
+// default.
+func overrideDataDir() (string, error) {
+	if dataDirOverride != "" {
+		return dataDirOverride, nil
+	}
+	if dir := os.Getenv(EnvDataDir); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("%s %q: %w", EnvDataDir, dir, err)
+		}
+		return abs, nil
+	}
+	if Portable() {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("portable data dir: %w", err)
+		}
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		return filepath.Dir(exe), nil
+	}
+	return "", nil
+}
+
+// SetConfigPath makes ConfigPath return path instead of config.json in the
+// data directory. Secrets and the database stay in the data directory.
+func SetConfigPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("config path %q: %w", path, err)
+	}
+	configPathOverride = abs
+	return nil
+}
+
+// ProfileID identifies the data directory for single-instance locking: ""
+// for the default directory, otherwise a short hash of its path. Instances
+// with different data directories do not block each other.
+func ProfileID() string {
+	dir, err := overrideDataDir()
+	if err != nil || dir == "" {
+		return ""
+	}
+	key := filepath.Clean(dir)
+	if runtime.GOOS == "windows" {
+		key = strings.ToLower(key) // paths are case-insensitive
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
 // DataDir returns the application data directory path.
 // On Windows: %LOCALAPPDATA%/vrclog/
 // On other platforms: ~/.config/vrclog/ or equivalent
+// SetDataDir, VRCLOG_DATA_DIR and portable mode override it.
 func DataDir() (string, error) {
+	if dir, err := overrideDataDir(); err != nil || dir != "" {
+		return dir, err
+	}
+
 	var base string
 
 	// On Windows, use LOCALAPPDATA; on other platforms, use UserConfigDir
@@ -39,7 +140,9 @@ func DataDir() (string, error) {
 	return filepath.Join(base, appinfo.DirName), nil
 }
 
-// EnsureDataDir creates the data directory if it doesn't exist.
+// EnsureDataDir creates the data directory if it doesn't exist, and
+// checks that it is writable, so a read-only USB stick or a mistyped
+// VRCLOG_DATA_DIR fails at startup rather than on the first save.
 func EnsureDataDir() (string, error) {
 	dir, err := DataDir()
 	if err != nil {
@@ -49,6 +152,12 @@ func EnsureDataDir() (string, error) {
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return "", fmt.Errorf("create data dir %q: %w", dir, err)
 	}
+	probe, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return "", fmt.Errorf("data dir %q is not writable: %w", dir, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
 
 	return dir, nil
 }
@@ -62,8 +171,22 @@ func dataPath(filename string) (string, error) {
 	return filepath.Join(dir, filename), nil
 }
 
-// ConfigPath returns the path to config.json.
+// ConfigPath returns the path set with SetConfigPath, else the first of
+// config.yaml, config.yml and config.toml that exists in the data
+// directory, else config.json.
 func ConfigPath() (string, error) {
+	if configPathOverride != "" {
+		return configPathOverride, nil
+	}
+	for _, name := range handEditedConfigNames {
+		path, err := dataPath(name)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
 	return dataPath(appinfo.ConfigFileName)
 }
 
@@ -7,10 +7,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/big"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 const (
@@ -60,11 +62,77 @@ func (s Secret) IsEmpty() bool {
 // Secrets holds sensitive application configuration.
 // WARNING: Do not log this struct directly as json.Marshal will expose values.
 type Secrets struct {
-	SchemaVersion     int    `json:"schema_version"`
-	DiscordWebhookURL Secret `json:"discord_webhook_url"`
-	BasicAuthUsername string `json:"basic_auth_username"`
-	BasicAuthPassword Secret `json:"basic_auth_password"`
-	SSEHMACSecret     Secret `json:"sse_hmac_secret"` // HMAC key for SSE token signing
+	SchemaVersion     int           `json:"schema_version"`
+	DiscordWebhookURL Secret        `json:"discord_webhook_url"`
+	DiscordBotToken   Secret        `json:"discord_bot_token,omitempty"` // enables the slash command bot
+	BasicAuthUsername string        `json:"basic_auth_username"`
+	BasicAuthPassword Secret        `json:"basic_auth_password"`
+	SSEHMACSecret     Secret        `json:"sse_hmac_secret"`         // HMAC key for SSE token signing
+	BackupRemote      *BackupRemote `json:"backup_remote,omitempty"` // where nightly backups are uploaded, if anywhere
+	MetricsPush       *MetricsPush  `json:"metrics_push,omitempty"`  // where event and player counts are pushed, if anywhere
+	Healthcheck       *Healthcheck  `json:"healthcheck,omitempty"`   // uptime monitor pinged while ingestion is healthy, if any
+}
+
+// Backup remote types.
+const (
+	BackupRemoteS3     = "s3"
+	BackupRemoteWebDAV = "webdav"
+)
+
+// DefaultBackupRemoteRegion is the S3 region used when none is configured.
+const DefaultBackupRemoteRegion = "us-east-1"
+
+// BackupRemote is an S3-compatible bucket or a WebDAV share that nightly
+// backups are uploaded to.
+type BackupRemote struct {
+	Type string `json:"type"` // BackupRemoteS3 or BackupRemoteWebDAV
+	// URL is the S3 endpoint with bucket and optional prefix in path style
+	// (e.g. "https://s3.eu-west-1.amazonaws.com/my-bucket/vrclog"), or the
+	// WebDAV collection to upload into.
+	URL      string `json:"url"`
+	Region   string `json:"region,omitempty"`   // S3 only
+	Username string `json:"username,omitempty"` // S3 access key ID or WebDAV user
+	Password Secret `json:"password,omitempty"` // S3 secret access key or WebDAV password
+}
+
+// Metrics push interval bounds, in seconds.
+const (
+	DefaultMetricsPushIntervalSec = 60
+	MinMetricsPushIntervalSec     = 10
+	MaxMetricsPushIntervalSec     = 3600
+)
+
+// MetricsPush is an InfluxDB or VictoriaMetrics endpoint that accepts the
+// line protocol. Event counts and player counts are pushed to it on an
+// interval.
+type MetricsPush struct {
+	// URL is the write endpoint with any query parameters it needs, e.g.
+	// "http://localhost:8086/api/v2/write?org=home&bucket=vrclog" for
+	// InfluxDB 2, "http://localhost:8086/write?db=vrclog" for InfluxDB 1
+	// or "http://localhost:8428/write" for VictoriaMetrics.
+	URL         string            `json:"url"`
+	Token       Secret            `json:"token,omitempty"`        // InfluxDB 2 API token
+	Username    string            `json:"username,omitempty"`     // Basic Auth, for InfluxDB 1 or a proxy
+	Password    Secret            `json:"password,omitempty"`     // Basic Auth password
+	IntervalSec int               `json:"interval_sec,omitempty"` // 0 means DefaultMetricsPushIntervalSec
+	Tags        map[string]string `json:"tags,omitempty"`         // added to every point, e.g. {"host": "desktop"}
+}
+
+// Healthcheck ping interval bounds, in seconds.
+const (
+	DefaultHealthcheckIntervalSec = 60
+	MinHealthcheckIntervalSec     = 10
+	MaxHealthcheckIntervalSec     = 3600
+)
+
+// Healthcheck is a dead man's switch: an uptime monitor such as
+// healthchecks.io or an Uptime Kuma push monitor, pinged on an interval
+// while ingestion is healthy, that alerts when the pings stop.
+type Healthcheck struct {
+	// URL is the ping URL, e.g. "https://hc-ping.com/<uuid>". Such URLs
+	// are the check's credentials, so it is a secret.
+	URL         Secret `json:"url"`
+	IntervalSec int    `json:"interval_sec,omitempty"` // 0 means DefaultHealthcheckIntervalSec
 }
 
 // DefaultSecrets returns a Secrets with empty values.
@@ -78,6 +146,60 @@ func DefaultSecrets() Secrets {
 	}
 }
 
+// Redacted returns a copy of s that is safe to share, e.g. in a bug
+// report: secrets that are set are replaced by "[REDACTED]", and URLs lose
+// their user info and query values, which may hold credentials.
+func (s Secrets) Redacted() Secrets {
+	redact := func(v Secret) Secret {
+		if v.IsEmpty() {
+			return v
+		}
+		return Secret(v.String())
+	}
+	out := s
+	out.DiscordWebhookURL = redact(s.DiscordWebhookURL)
+	out.DiscordBotToken = redact(s.DiscordBotToken)
+	out.BasicAuthPassword = redact(s.BasicAuthPassword)
+	out.SSEHMACSecret = redact(s.SSEHMACSecret)
+	if s.BackupRemote != nil {
+		r := *s.BackupRemote
+		r.URL = redactURL(r.URL)
+		r.Password = redact(r.Password)
+		out.BackupRemote = &r
+	}
+	if s.MetricsPush != nil {
+		m := *s.MetricsPush
+		m.URL = redactURL(m.URL)
+		m.Token = redact(m.Token)
+		m.Password = redact(m.Password)
+		out.MetricsPush = &m
+	}
+	if s.Healthcheck != nil {
+		h := *s.Healthcheck
+		h.URL = redact(h.URL)
+		out.Healthcheck = &h
+	}
+	return out
+}
+
+// redactURL removes the user info of rawURL and masks its query values.
+// URLs that do not parse are masked entirely.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Secret(rawURL).String()
+	}
+	u.User = nil
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k := range q {
+			q[k] = []string{"REDACTED"} // brackets would be escaped
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
 // LoadSecrets reads secrets from disk. Returns the secrets, load status, and any error.
 // Status indicates whether it's safe to overwrite the secrets file.
 func LoadSecrets() (Secrets, SecretsLoadStatus, error) {
@@ -100,27 +222,87 @@ func LoadSecretsFrom(path string) (Secrets, SecretsLoadStatus, error) {
 			// File doesn't exist, safe to create
 			return sec, SecretsMissing, nil
 		}
-		log.Printf("Warning: failed to read secrets file: %v, using defaults", err)
+		slog.Warn("failed to read secrets file, using defaults", "path", path, "error", err)
 		return sec, SecretsFallback, fmt.Errorf("read secrets: %w", err)
 	}
 
 	// Try to parse JSON
 	dec := json.NewDecoder(bytes.NewReader(data))
 	if err := dec.Decode(&sec); err != nil {
-		log.Printf("Warning: secrets file is corrupt: %v, using defaults", err)
+		slog.Warn("secrets file is corrupt, using defaults", "path", path, "error", err)
 		return DefaultSecrets(), SecretsFallback, fmt.Errorf("decode secrets: %w", err)
 	}
 
-	// Check schema version
-	if sec.SchemaVersion != CurrentSchemaVersion {
-		log.Printf("Warning: secrets schema version mismatch (got %d, expected %d), using defaults",
-			sec.SchemaVersion, CurrentSchemaVersion)
+	// Check schema version. Secrets have not changed across config schema
+	// versions, so older ones are read as is.
+	if sec.SchemaVersion < 1 || sec.SchemaVersion > CurrentSchemaVersion {
+		slog.Warn("secrets schema version mismatch, using defaults",
+			"version", sec.SchemaVersion, "max_version", CurrentSchemaVersion)
 		return DefaultSecrets(), SecretsFallback, fmt.Errorf("schema mismatch: got %d", sec.SchemaVersion)
 	}
 
 	return sec, SecretsLoaded, nil
 }
 
+// ValidateBackupRemote checks that r has a known type and an http(s) URL.
+// S3 remotes also need a bucket in the URL path and access keys.
+func ValidateBackupRemote(r BackupRemote) error {
+	if r.Type != BackupRemoteS3 && r.Type != BackupRemoteWebDAV {
+		return fmt.Errorf("type must be %q or %q, got %q", BackupRemoteS3, BackupRemoteWebDAV, r.Type)
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if r.Type == BackupRemoteS3 {
+		if strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("url must include the bucket, e.g. https://s3.amazonaws.com/bucket")
+		}
+		if r.Username == "" || r.Password.IsEmpty() {
+			return fmt.Errorf("username (access key ID) and password (secret access key) are required")
+		}
+	}
+	return nil
+}
+
+// ValidateMetricsPush checks that m has an http(s) URL, an interval within
+// bounds, at most one kind of credentials and tags that are non-empty and
+// do not clash with the account and type tags.
+func ValidateMetricsPush(m MetricsPush) error {
+	u, err := url.Parse(m.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if m.IntervalSec != 0 && (m.IntervalSec < MinMetricsPushIntervalSec || m.IntervalSec > MaxMetricsPushIntervalSec) {
+		return fmt.Errorf("interval_sec must be between %d and %d", MinMetricsPushIntervalSec, MaxMetricsPushIntervalSec)
+	}
+	if !m.Token.IsEmpty() && (m.Username != "" || !m.Password.IsEmpty()) {
+		return fmt.Errorf("set either token or username and password, not both")
+	}
+	for k, v := range m.Tags {
+		if k == "" || v == "" {
+			return fmt.Errorf("tags must have non-empty keys and values")
+		}
+		if k == "account" || k == "type" {
+			return fmt.Errorf("tag %q is set by the app", k)
+		}
+	}
+	return nil
+}
+
+// ValidateHealthcheck checks that h has an http(s) URL and an interval
+// within bounds.
+func ValidateHealthcheck(h Healthcheck) error {
+	u, err := url.Parse(h.URL.Value())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if h.IntervalSec != 0 && (h.IntervalSec < MinHealthcheckIntervalSec || h.IntervalSec > MaxHealthcheckIntervalSec) {
+		return fmt.Errorf("interval_sec must be between %d and %d", MinHealthcheckIntervalSec, MaxHealthcheckIntervalSec)
+	}
+	return nil
+}
+
 // SaveSecrets writes secrets to disk atomically.
 func SaveSecrets(sec Secrets) error {
 	path, err := SecretsPath()
@@ -0,0 +1,436 @@
+package conformance
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// check is one conformance check.
+type check struct {
+	name     string
+	spec     string // the section of SPEC.md it covers
+	optional bool   // the endpoint is a feature servers may leave out; skipped on 404
+	fn       func(ctx context.Context, s *run) error
+}
+
+// checks are run in this order; later ones use what earlier ones found.
+var checks = []check{
+	{"health", "12.1", false, checkHealth},
+	{"auth-required", "7.2", false, checkAuthRequired},
+	{"events", "12.3", false, checkEvents},
+	{"events-pagination", "12.3", false, checkEventsPagination},
+	{"events-type-filter", "12.3", false, checkEventsTypeFilter},
+	{"events-invalid-params", "12", false, checkEventsInvalidParams},
+	{"events-invalid-cursor", "12", false, checkEventsInvalidCursor},
+	{"events-changes", "12.3.1", true, checkEventChanges},
+	{"events-stream-export", "12.3.1.4", true, checkStreamExport},
+	{"now", "12.2", true, checkNow},
+	{"stats-basic", "12.4", true, checkStatsBasic},
+	{"sse-token", "12.6", true, checkSSEToken},
+	{"sse-stream", "12.5", false, checkSSEStream},
+	{"sse-token-auth", "12.5", false, checkSSETokenAuth},
+	{"sse-invalid-token", "12.5", false, checkSSEInvalidToken},
+	{"sse-replay", "12.5", false, checkSSEReplay},
+}
+
+// checkHealth checks that the health endpoint answers without credentials.
+func checkHealth(ctx context.Context, s *run) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.request(ctx, http.MethodGet, "/api/v1/health", false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var health struct {
+		Status  string  `json:"status"`
+		Version *string `json:"version"`
+	}
+	if err := decodeJSON(resp, http.StatusOK, "/api/v1/health", &health); err != nil {
+		return err
+	}
+	if health.Status == "" {
+		return errors.New("health has no status")
+	}
+	if health.Version == nil {
+		return errors.New("health has no version")
+	}
+	return nil
+}
+
+// checkAuthRequired checks that the API refuses requests without
+// credentials, with a Basic challenge. It is skipped without credentials,
+// as the server then presumably does not require them.
+func checkAuthRequired(ctx context.Context, s *run) error {
+	if s.username == "" {
+		return skip("no credentials given")
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	const path = "/api/v1/events?limit=1"
+	resp, err := s.request(ctx, http.MethodGet, path, false, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if c := resp.Header.Get("WWW-Authenticate"); resp.StatusCode == http.StatusUnauthorized && !strings.HasPrefix(c, "Basic") {
+		return fmt.Errorf("WWW-Authenticate %q, want a Basic challenge", c)
+	}
+	return checkError(resp, path, http.StatusUnauthorized, "unauthorized")
+}
+
+// checkEvents checks the newest events: their fields, the limit and that
+// they come newest first.
+func checkEvents(ctx context.Context, s *run) error {
+	var page eventsPage
+	if err := s.getJSON(ctx, "/api/v1/events?limit=5", &page); err != nil {
+		return err
+	}
+	if page.Items == nil {
+		return errors.New("items is missing or null, want an array")
+	}
+	if len(page.Items) > 5 {
+		return fmt.Errorf("%d items for limit=5", len(page.Items))
+	}
+	if err := validateEvents(page.Items, true); err != nil {
+		return err
+	}
+	s.events = page.Items
+	return nil
+}
+
+// validateEvents checks the fields and the order of events, newest first
+// if desc is set and oldest first otherwise.
+func validateEvents(events []wireEvent, desc bool) error {
+	for i, e := range events {
+		if err := e.validate(); err != nil {
+			return err
+		}
+		if i == 0 {
+			continue
+		}
+		prev := events[i-1]
+		if (desc && e.Ts.After(prev.Ts)) || (!desc && e.Ts.Before(prev.Ts)) {
+			return fmt.Errorf("event %d (%s) is out of order after event %d (%s)",
+				e.ID, e.Ts.Format(time.RFC3339Nano), prev.ID, prev.Ts.Format(time.RFC3339Nano))
+		}
+	}
+	return nil
+}
+
+// checkEventsPagination pages through the newest events one at a time.
+func checkEventsPagination(ctx context.Context, s *run) error {
+	if len(s.events) < 2 {
+		return skip("fewer than 2 events")
+	}
+	var first, second eventsPage
+	if err := s.getJSON(ctx, "/api/v1/events?limit=1", &first); err != nil {
+		return err
+	}
+	if len(first.Items) != 1 {
+		return fmt.Errorf("%d items for limit=1", len(first.Items))
+	}
+	if first.NextCursor == nil || *first.NextCursor == "" {
+		return errors.New("no next_cursor although there are more events")
+	}
+	if err := s.getJSON(ctx, "/api/v1/events?limit=1&cursor="+url.QueryEscape(*first.NextCursor), &second); err != nil {
+		return err
+	}
+	if len(second.Items) != 1 {
+		return fmt.Errorf("%d items on the second page, want 1", len(second.Items))
+	}
+	if second.Items[0].ID == first.Items[0].ID {
+		return fmt.Errorf("event %d is on both pages", first.Items[0].ID)
+	}
+	return validateEvents([]wireEvent{first.Items[0], second.Items[0]}, true)
+}
+
+// checkEventsTypeFilter checks that type filters the events.
+func checkEventsTypeFilter(ctx context.Context, s *run) error {
+	if len(s.events) == 0 {
+		return skip("no events")
+	}
+	typ := s.events[0].Type
+	var page eventsPage
+	if err := s.getJSON(ctx, "/api/v1/events?limit=5&type="+url.QueryEscape(typ), &page); err != nil {
+		return err
+	}
+	if len(page.Items) == 0 {
+		return fmt.Errorf("no events for type=%s, although event %d has it", typ, s.events[0].ID)
+	}
+	for _, e := range page.Items {
+		if e.Type != typ {
+			return fmt.Errorf("event %d of type %q for type=%s", e.ID, e.Type, typ)
+		}
+	}
+	return nil
+}
+
+// checkEventsInvalidParams checks that invalid parameters are refused
+// with validation_failed.
+func checkEventsInvalidParams(ctx context.Context, s *run) error {
+	for _, q := range []string{"limit=0", "limit=abc", "type=no_such_type", "since=yesterday"} {
+		if err := s.wantError(ctx, "/api/v1/events?"+q, true, http.StatusBadRequest, "validation_failed"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEventsInvalidCursor checks that a malformed cursor is refused with
+// invalid_cursor.
+func checkEventsInvalidCursor(ctx context.Context, s *run) error {
+	return s.wantError(ctx, "/api/v1/events?cursor=%21%21not-a-cursor", true, http.StatusBadRequest, "invalid_cursor")
+}
+
+// checkEventChanges checks a page of the change log and that its cursor
+// can be passed back.
+func checkEventChanges(ctx context.Context, s *run) error {
+	type changes struct {
+		Items []struct {
+			Op    string          `json:"op"`
+			ID    int64           `json:"id"`
+			Event json.RawMessage `json:"event"`
+		} `json:"items"`
+		NextCursor string `json:"next_cursor"`
+		HasMore    *bool  `json:"has_more"`
+	}
+	var page changes
+	if err := s.getJSON(ctx, "/api/v1/events/changes?limit=5", &page); err != nil {
+		return err
+	}
+	if page.Items == nil {
+		return errors.New("items is missing or null, want an array")
+	}
+	if page.NextCursor == "" {
+		return errors.New("next_cursor is empty")
+	}
+	if page.HasMore == nil {
+		return errors.New("has_more is missing")
+	}
+	for _, c := range page.Items {
+		switch {
+		case c.ID <= 0:
+			return fmt.Errorf("change has id %d, want a positive id", c.ID)
+		case c.Op == "upsert":
+			var e wireEvent
+			if err := json.Unmarshal(c.Event, &e); err != nil {
+				return fmt.Errorf("upsert of %d: invalid event: %v", c.ID, err)
+			}
+			if err := e.validate(); err != nil {
+				return err
+			}
+		case c.Op != "delete":
+			return fmt.Errorf("change of %d has op %q, want upsert or delete", c.ID, c.Op)
+		}
+	}
+	var next changes
+	if err := s.getJSON(ctx, "/api/v1/events/changes?limit=5&since_cursor="+url.QueryEscape(page.NextCursor), &next); err != nil {
+		return err
+	}
+	return s.wantError(ctx, "/api/v1/events/changes?since_cursor=%21%21", true, http.StatusBadRequest, "invalid_cursor")
+}
+
+// checkStreamExport exports the events since the newest one, which must
+// include it, and checks the NDJSON lines.
+func checkStreamExport(ctx context.Context, s *run) error {
+	since := time.Now().UTC()
+	if len(s.events) > 0 {
+		since = s.events[0].Ts
+	}
+	path := "/api/v1/events/stream-export?since=" + url.QueryEscape(since.Format(time.RFC3339Nano))
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.request(ctx, http.MethodGet, path, true, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotImplemented
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: status %d, want 200%s", path, resp.StatusCode, errorDetail(resp))
+	}
+	if err := wantContentType(resp, "application/x-ndjson"); err != nil {
+		return err
+	}
+
+	var events []wireEvent
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for sc.Scan() {
+		var e wireEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return fmt.Errorf("line %d: invalid JSON: %v", len(events)+1, err)
+		}
+		if e.Ts.Before(since) {
+			return fmt.Errorf("event %d is before since", e.ID)
+		}
+		events = append(events, e)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+	if err := validateEvents(events, false); err != nil {
+		return err
+	}
+	if len(s.events) > 0 && !containsEvent(events, s.events[0].ID) {
+		return fmt.Errorf("export since event %d does not include it", s.events[0].ID)
+	}
+	return nil
+}
+
+// containsEvent reports whether events includes the event with id.
+func containsEvent(events []wireEvent, id int64) bool {
+	for _, e := range events {
+		if e.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNow checks the shape of the current world and players.
+func checkNow(ctx context.Context, s *run) error {
+	var now map[string]json.RawMessage
+	if err := s.getJSON(ctx, "/api/v1/now", &now); err != nil {
+		return err
+	}
+	if _, ok := now["world"]; !ok {
+		return errors.New("world is missing, want an object or null")
+	}
+	var players []json.RawMessage
+	if err := json.Unmarshal(now["players"], &players); err != nil || players == nil {
+		return errors.New("players is missing or not an array")
+	}
+	return nil
+}
+
+// checkStatsBasic checks that the basic stats are a JSON object.
+func checkStatsBasic(ctx context.Context, s *run) error {
+	var stats map[string]json.RawMessage
+	return s.getJSON(ctx, "/api/v1/stats/basic", &stats)
+}
+
+// checkSSEToken issues an SSE token, for the checks after it.
+func checkSSEToken(ctx context.Context, s *run) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	// A state-changing request from a script: the Origin of the server
+	// itself passes CSRF protection
+	resp, err := s.request(ctx, http.MethodPost, "/api/v1/auth/token", true, http.Header{"Origin": {s.base}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return skip("SSE tokens not configured")
+	}
+	var tok struct {
+		Token     string `json:"token"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := decodeJSON(resp, http.StatusOK, "/api/v1/auth/token", &tok); err != nil {
+		return err
+	}
+	if tok.Token == "" {
+		return errors.New("token is empty")
+	}
+	if tok.ExpiresIn <= 0 {
+		return fmt.Errorf("expires_in %d, want a positive number of seconds", tok.ExpiresIn)
+	}
+	s.token = tok.Token
+	return nil
+}
+
+// checkSSEStream connects to the event stream and waits for its first
+// message.
+func checkSSEStream(ctx context.Context, s *run) error {
+	return s.firstMessage(ctx, "/api/v1/stream", true, nil)
+}
+
+// checkSSETokenAuth connects to the event stream with the token alone, as
+// a browser EventSource does.
+func checkSSETokenAuth(ctx context.Context, s *run) error {
+	if s.token == "" {
+		return skip("no SSE token")
+	}
+	return s.firstMessage(ctx, "/api/v1/stream?token="+url.QueryEscape(s.token), false, nil)
+}
+
+// firstMessage connects to the SSE stream at path and reads its first
+// message.
+func (s *run) firstMessage(ctx context.Context, path string, auth bool, header http.Header) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.openStream(ctx, path, auth, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = readMessage(bufio.NewReader(resp.Body))
+	return err
+}
+
+// checkSSEInvalidToken checks that the stream refuses a forged token. It
+// is skipped without credentials, as the server then presumably does not
+// require any.
+func checkSSEInvalidToken(ctx context.Context, s *run) error {
+	if s.username == "" {
+		return skip("no credentials given")
+	}
+	return s.wantError(ctx, "/api/v1/stream?token=sse1.e30.AAAA", false, http.StatusUnauthorized, "unauthorized")
+}
+
+// checkSSEReplay reconnects to the stream with the cursor of the second
+// newest event as Last-Event-ID and expects the newest to be replayed
+// first, with the same ID.
+func checkSSEReplay(ctx context.Context, s *run) error {
+	if len(s.events) < 2 {
+		return skip("fewer than 2 events")
+	}
+	var page eventsPage
+	if err := s.getJSON(ctx, "/api/v1/events?limit=2", &page); err != nil {
+		return err
+	}
+	if len(page.Items) != 2 || page.NextCursor == nil {
+		return errors.New("no cursor for the second newest event")
+	}
+	want := page.Items[0]
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.openStream(ctx, "/api/v1/stream", true, http.Header{"Last-Event-ID": {*page.NextCursor}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	m, err := readMessage(bufio.NewReader(resp.Body))
+	if err != nil {
+		return fmt.Errorf("event %d was not replayed: %w", want.ID, err)
+	}
+	if m.data == "" {
+		return fmt.Errorf("event %d was not replayed before the stream went live", want.ID)
+	}
+	var got wireEvent
+	if err := json.Unmarshal([]byte(m.data), &got); err != nil {
+		return fmt.Errorf("invalid event data: %v", err)
+	}
+	switch {
+	case got.ID != want.ID:
+		return fmt.Errorf("replayed event %d first, want %d", got.ID, want.ID)
+	case m.id == "":
+		return fmt.Errorf("replayed event %d has no id", got.ID)
+	case m.event != got.Type:
+		return fmt.Errorf("replayed event %d is named %q, want its type %q", got.ID, m.event, got.Type)
+	}
+	return got.validate()
+}
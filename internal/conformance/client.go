@@ -0,0 +1,224 @@
+package conformance
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// run is the state of one Runner.Run, shared by the checks.
+type run struct {
+	*Runner
+	events []wireEvent // newest events, from the events check
+	token  string      // SSE token, from the sse-token check
+}
+
+// wireEvent is the part of an event every check relies on.
+type wireEvent struct {
+	ID   int64     `json:"id"`
+	Ts   time.Time `json:"ts"`
+	Type string    `json:"type"`
+}
+
+// validate checks the fields every event must have.
+func (e wireEvent) validate() error {
+	if e.ID <= 0 {
+		return fmt.Errorf("event has id %d, want a positive id", e.ID)
+	}
+	if e.Ts.IsZero() {
+		return fmt.Errorf("event %d has no ts", e.ID)
+	}
+	if !event.IsValidType(e.Type) {
+		return fmt.Errorf("event %d has unknown type %q", e.ID, e.Type)
+	}
+	return nil
+}
+
+// eventsPage is the response of GET /api/v1/events.
+type eventsPage struct {
+	Items      []wireEvent `json:"items"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// apiError is the error response format of every endpoint.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// request makes a request to path, with the credentials if auth is set.
+// The caller closes the body.
+func (s *run) request(ctx context.Context, method, path string, auth bool, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if auth && s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	return s.client.Do(req)
+}
+
+// getJSON makes an authenticated GET request to path and decodes the JSON
+// response into v. Any status other than 200 is an error; 404 is
+// errNotImplemented, for optional endpoints to skip on.
+func (s *run) getJSON(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.request(ctx, http.MethodGet, path, true, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeJSON(resp, http.StatusOK, path, v)
+}
+
+// errNotImplemented is returned for 404 responses to GET requests.
+var errNotImplemented = skipError{reason: "endpoint not implemented (404)"}
+
+// decodeJSON checks the status and content type of resp and decodes its
+// body into v.
+func decodeJSON(resp *http.Response, want int, path string, v any) error {
+	if resp.StatusCode != want {
+		if resp.StatusCode == http.StatusNotFound && want == http.StatusOK {
+			return errNotImplemented
+		}
+		return fmt.Errorf("%s: status %d, want %d%s", path, resp.StatusCode, want, errorDetail(resp))
+	}
+	if err := wantContentType(resp, "application/json"); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s: invalid JSON: %w", path, err)
+	}
+	return nil
+}
+
+// errorDetail returns the code of an error response, for messages.
+func errorDetail(resp *http.Response) string {
+	var e apiError
+	if json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e) != nil || e.Code == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s: %s)", e.Code, e.Message)
+}
+
+// wantContentType checks the media type of resp, ignoring parameters.
+func wantContentType(resp *http.Response, want string) error {
+	ct := resp.Header.Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != want {
+		return fmt.Errorf("Content-Type %q, want %s", ct, want)
+	}
+	return nil
+}
+
+// wantError makes a GET request to path and checks that it fails with
+// status and the error code.
+func (s *run) wantError(ctx context.Context, path string, auth bool, status int, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.request(ctx, http.MethodGet, path, auth, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkError(resp, path, status, code)
+}
+
+// checkError checks that resp has status and is an error response in the
+// standard format with the error code.
+func checkError(resp *http.Response, path string, status int, code string) error {
+	if resp.StatusCode != status {
+		return fmt.Errorf("%s: status %d, want %d", path, resp.StatusCode, status)
+	}
+	if err := wantContentType(resp, "application/json"); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var e apiError
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return fmt.Errorf("%s: invalid error JSON: %w", path, err)
+	}
+	switch {
+	case e.Code != code:
+		return fmt.Errorf("%s: error code %q, want %q", path, e.Code, code)
+	case e.Message == "":
+		return fmt.Errorf("%s: error has no message", path)
+	case e.Error != e.Message:
+		return fmt.Errorf("%s: error %q differs from message %q", path, e.Error, e.Message)
+	}
+	return nil
+}
+
+// sseMessage is one message of an SSE stream: an event, or a comment if
+// data is empty.
+type sseMessage struct {
+	id    string
+	event string
+	data  string
+}
+
+// openStream connects to the SSE stream at path. The caller closes the
+// body; ctx bounds the whole stream.
+func (s *run) openStream(ctx context.Context, path string, auth bool, header http.Header) (*http.Response, error) {
+	resp, err := s.request(ctx, http.MethodGet, path, auth, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: status %d, want 200", path, resp.StatusCode)
+	}
+	if err := wantContentType(resp, "text/event-stream"); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return resp, nil
+}
+
+// readMessage reads the next message of an SSE stream.
+func readMessage(r *bufio.Reader) (sseMessage, error) {
+	var m sseMessage
+	var seen bool
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return m, fmt.Errorf("stream ended before a message: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if seen {
+				return m, nil
+			}
+			continue
+		}
+		seen = true
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			m.id = value
+		case "event":
+			m.event = value
+		case "data":
+			if m.data != "" {
+				m.data += "\n"
+			}
+			m.data += value
+		}
+	}
+}
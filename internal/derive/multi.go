@@ -0,0 +1,108 @@
+package derive
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// MultiState tracks a separate State for each VRChat account, keyed by the
+// event's Account. The default account is "".
+// It is safe for concurrent use.
+type MultiState struct {
+	mu     sync.RWMutex
+	states map[string]*State
+	last   string // account of the most recent Update
+}
+
+// NewMulti creates a MultiState holding only the default account.
+func NewMulti() *MultiState {
+	return &MultiState{states: map[string]*State{"": New()}}
+}
+
+// For returns the state of account, creating it if needed.
+func (m *MultiState) For(account string) *State {
+	m.mu.RLock()
+	s, ok := m.states[account]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.states[account]; ok {
+		return s
+	}
+	s = New()
+	m.states[account] = s
+	return s
+}
+
+// Lookup returns the state of account, or nil if it has not been seen.
+func (m *MultiState) Lookup(account string) *State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.states[account]
+}
+
+// Accounts returns the known accounts, sorted, starting with the default "".
+func (m *MultiState) Accounts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	accounts := make([]string, 0, len(m.states))
+	for a := range m.states {
+		accounts = append(accounts, a)
+	}
+	slices.Sort(accounts)
+	return accounts
+}
+
+// Update routes e to the state of its account.
+// See State.Update.
+func (m *MultiState) Update(e *event.Event) *DerivedEvent {
+	if e == nil || e.Type == event.TypeSystem {
+		// System events say nothing about the world and must not change
+		// which account is current
+		return nil
+	}
+	account := deref(e.Account)
+	s := m.For(account)
+
+	m.mu.Lock()
+	m.last = account
+	m.mu.Unlock()
+
+	return s.Update(e)
+}
+
+// CurrentWorld returns the current world of the account that was updated
+// most recently. Since notifications are filtered right after the update
+// that produced them, this is the world of the notified event's account.
+func (m *MultiState) CurrentWorld() *WorldInfo {
+	m.mu.RLock()
+	s := m.states[m.last]
+	m.mu.RUnlock()
+	return s.CurrentWorld()
+}
+
+// CurrentPlayers returns the players with the account that was updated
+// most recently, the one whose world CurrentWorld returns.
+func (m *MultiState) CurrentPlayers() []PlayerInfo {
+	m.mu.RLock()
+	s := m.states[m.last]
+	m.mu.RUnlock()
+	return s.CurrentPlayers()
+}
+
+// PlayerCounts returns the current player count of every known account.
+func (m *MultiState) PlayerCounts() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := make(map[string]int, len(m.states))
+	for a, s := range m.states {
+		counts[a] = s.PlayerCount()
+	}
+	return counts
+}
@@ -19,13 +19,75 @@ const (
 	DerivedPlayerJoined
 	// DerivedPlayerLeft indicates a player left the instance.
 	DerivedPlayerLeft
+	// DerivedSessionEnded carries the recap of an instance session. State
+	// never returns it; notify splits it off a DerivedWorldChanged event.
+	DerivedSessionEnded
+	// DerivedPlayerReturned indicates a player joined after a long absence.
+	// State never returns it; notify derives it from a DerivedPlayerJoined
+	// event and the player's previous sighting.
+	DerivedPlayerReturned
+	// DerivedMilestone announces a milestone, such as the 100th visit to a
+	// world. State never returns it; milestones are found by the store at
+	// ingest.
+	DerivedMilestone
 )
 
+// String returns the snake_case name of t, e.g. "world_changed".
+func (t DerivedEventType) String() string {
+	switch t {
+	case DerivedWorldChanged:
+		return "world_changed"
+	case DerivedPlayerJoined:
+		return "player_joined"
+	case DerivedPlayerLeft:
+		return "player_left"
+	case DerivedSessionEnded:
+		return "session_ended"
+	case DerivedPlayerReturned:
+		return "player_returned"
+	case DerivedMilestone:
+		return "milestone"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes t as its String form.
+func (t DerivedEventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
 // DerivedEvent represents a state change for notification purposes.
 type DerivedEvent struct {
-	Type      DerivedEventType
-	Event     *event.Event // Original event that triggered this
-	PrevWorld *WorldInfo   // Previous world (only for WorldChanged)
+	Type        DerivedEventType `json:"type"`
+	Event       *event.Event     `json:"event"`                // Original event that triggered this
+	PrevWorld   *WorldInfo       `json:"prev_world,omitempty"` // Previous world (only for WorldChanged)
+	Recap       *SessionRecap    `json:"recap,omitempty"`      // Session that just ended (only for WorldChanged, nil if none)
+	PlayerCount int              `json:"player_count"`         // Players in the instance after the change
+	LastSeen    *time.Time       `json:"last_seen,omitempty"`  // Previous sighting of the player (only for PlayerReturned)
+	Milestone   *MilestoneInfo   `json:"milestone,omitempty"`  // Milestone reached (only for Milestone)
+}
+
+// MilestoneInfo describes a milestone reached by the event of a
+// DerivedMilestone.
+type MilestoneInfo struct {
+	Kind  string `json:"kind"`           // world_visits, player_anniversary or play_streak
+	Name  string `json:"name,omitempty"` // world or player name
+	Value int    `json:"value"`          // visits, years or days
+}
+
+// SessionRecap summarizes a finished instance session.
+type SessionRecap struct {
+	World       WorldInfo `json:"world"`
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+	PeakPlayers int       `json:"peak_players"`
+	PlayersSeen []string  `json:"players_seen"` // display names in first-join order
+}
+
+// Duration returns how long the session lasted.
+func (r *SessionRecap) Duration() time.Duration {
+	return r.EndedAt.Sub(r.StartedAt)
 }
 
 // WorldInfo represents current world state.
@@ -49,12 +111,18 @@ type State struct {
 	mu           sync.RWMutex
 	currentWorld *WorldInfo
 	players      map[string]*PlayerInfo // keyed by PlayerID (or PlayerName if ID is empty)
+
+	// Per-session aggregates, reset on world change
+	peakPlayers int
+	seen        map[string]bool // player keys seen this session
+	seenNames   []string
 }
 
 // New creates a new State.
 func New() *State {
 	return &State{
 		players: make(map[string]*PlayerInfo),
+		seen:    make(map[string]bool),
 	}
 }
 
@@ -83,6 +151,7 @@ func (s *State) Update(e *event.Event) *DerivedEvent {
 
 func (s *State) handleWorldJoin(e *event.Event) *DerivedEvent {
 	prev := s.currentWorld
+	recap := s.recapLocked(e.Ts)
 
 	// Update current world
 	s.currentWorld = &WorldInfo{
@@ -92,13 +161,34 @@ func (s *State) handleWorldJoin(e *event.Event) *DerivedEvent {
 		JoinedAt:   e.Ts,
 	}
 
-	// Clear player list on world change
+	// Clear player list and session aggregates on world change
 	s.players = make(map[string]*PlayerInfo)
+	s.peakPlayers = 0
+	s.seen = make(map[string]bool)
+	s.seenNames = nil
 
 	return &DerivedEvent{
 		Type:      DerivedWorldChanged,
 		Event:     e,
 		PrevWorld: prev,
+		Recap:     recap,
+	}
+}
+
+// recapLocked summarizes the current session as ending at end.
+// Returns nil if there is no session or no other player was seen in it,
+// which also skips the empty session between VRChat's "Joining" and
+// "Entering Room" lines. Must be called with mu held.
+func (s *State) recapLocked(end time.Time) *SessionRecap {
+	if s.currentWorld == nil || len(s.seenNames) == 0 {
+		return nil
+	}
+	return &SessionRecap{
+		World:       *s.currentWorld,
+		StartedAt:   s.currentWorld.JoinedAt,
+		EndedAt:     end,
+		PeakPlayers: s.peakPlayers,
+		PlayersSeen: append([]string(nil), s.seenNames...),
 	}
 }
 
@@ -127,10 +217,22 @@ func (s *State) handlePlayerJoin(e *event.Event) *DerivedEvent {
 		PlayerID:   deref(e.PlayerID),
 		JoinedAt:   e.Ts,
 	}
+	if len(s.players) > s.peakPlayers {
+		s.peakPlayers = len(s.players)
+	}
+	if !s.seen[key] {
+		s.seen[key] = true
+		name := deref(e.PlayerName)
+		if name == "" {
+			name = key
+		}
+		s.seenNames = append(s.seenNames, name)
+	}
 
 	return &DerivedEvent{
-		Type:  DerivedPlayerJoined,
-		Event: e,
+		Type:        DerivedPlayerJoined,
+		Event:       e,
+		PlayerCount: len(s.players),
 	}
 }
 
@@ -148,8 +250,9 @@ func (s *State) handlePlayerLeft(e *event.Event) *DerivedEvent {
 	delete(s.players, key)
 
 	return &DerivedEvent{
-		Type:  DerivedPlayerLeft,
-		Event: e,
+		Type:        DerivedPlayerLeft,
+		Event:       e,
+		PlayerCount: len(s.players),
 	}
 }
 
@@ -177,6 +280,69 @@ func (s *State) CurrentPlayers() []PlayerInfo {
 	return result
 }
 
+// Snapshot is a point-in-time copy of State.
+type Snapshot struct {
+	World       *WorldInfo // nil if not in a world
+	Players     []PlayerInfo
+	PeakPlayers int // peak player count of the current session
+}
+
+// Snapshot returns a copy of the current state.
+// Safe for concurrent use.
+func (s *State) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap := Snapshot{
+		Players:     make([]PlayerInfo, 0, len(s.players)),
+		PeakPlayers: s.peakPlayers,
+	}
+	if s.currentWorld != nil {
+		w := *s.currentWorld
+		snap.World = &w
+	}
+	for _, p := range s.players {
+		snap.Players = append(snap.Players, *p)
+	}
+	return snap
+}
+
+// Restore replaces the current state with snap, e.g. from a snapshot saved
+// before a restart. Players in snap count as seen for the session recap.
+// Safe for concurrent use.
+func (s *State) Restore(snap Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.currentWorld = nil
+	if snap.World != nil {
+		w := *snap.World
+		s.currentWorld = &w
+	}
+	s.players = make(map[string]*PlayerInfo, len(snap.Players))
+	s.seen = make(map[string]bool, len(snap.Players))
+	s.seenNames = nil
+	for _, p := range snap.Players {
+		key := p.PlayerID
+		if key == "" {
+			key = p.PlayerName
+		}
+		if key == "" {
+			continue
+		}
+		p := p
+		s.players[key] = &p
+		if !s.seen[key] {
+			s.seen[key] = true
+			name := p.PlayerName
+			if name == "" {
+				name = key
+			}
+			s.seenNames = append(s.seenNames, name)
+		}
+	}
+	s.peakPlayers = max(snap.PeakPlayers, len(s.players))
+}
+
 // PlayerCount returns the current player count.
 // Safe for concurrent use.
 func (s *State) PlayerCount() int {
@@ -310,3 +310,107 @@ func TestState_ThreadSafety(t *testing.T) {
 	wg.Wait()
 	// If we get here without panic, thread safety is working
 }
+
+func TestState_WorldChange_Recap(t *testing.T) {
+	s := New()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	// "Joining" then "Entering Room": the empty session in between has no recap
+	if d := s.Update(&event.Event{Type: event.TypeWorldJoin, WorldID: ptr("wrld_a"), Ts: base}); d.Recap != nil {
+		t.Errorf("first world join: unexpected recap %+v", d.Recap)
+	}
+	if d := s.Update(&event.Event{Type: event.TypeWorldJoin, WorldName: ptr("World A"), Ts: base}); d.Recap != nil {
+		t.Errorf("Entering Room: unexpected recap %+v", d.Recap)
+	}
+
+	s.Update(&event.Event{Type: event.TypePlayerJoin, PlayerName: ptr("Alice"), Ts: base.Add(time.Minute)})
+	s.Update(&event.Event{Type: event.TypePlayerJoin, PlayerName: ptr("Bob"), Ts: base.Add(2 * time.Minute)})
+	s.Update(&event.Event{Type: event.TypePlayerLeft, PlayerName: ptr("Bob"), Ts: base.Add(3 * time.Minute)})
+	// Rejoining does not count twice
+	s.Update(&event.Event{Type: event.TypePlayerJoin, PlayerName: ptr("Bob"), Ts: base.Add(4 * time.Minute)})
+
+	d := s.Update(&event.Event{Type: event.TypeWorldJoin, WorldID: ptr("wrld_b"), Ts: base.Add(time.Hour)})
+	if d.Recap == nil {
+		t.Fatal("expected recap on world change")
+	}
+	r := d.Recap
+	if r.World.WorldName != "World A" {
+		t.Errorf("World = %q, want World A", r.World.WorldName)
+	}
+	if r.Duration() != time.Hour {
+		t.Errorf("Duration = %v, want 1h", r.Duration())
+	}
+	if r.PeakPlayers != 2 {
+		t.Errorf("PeakPlayers = %d, want 2", r.PeakPlayers)
+	}
+	if len(r.PlayersSeen) != 2 || r.PlayersSeen[0] != "Alice" || r.PlayersSeen[1] != "Bob" {
+		t.Errorf("PlayersSeen = %v, want [Alice Bob]", r.PlayersSeen)
+	}
+}
+
+func TestState_SnapshotRestore(t *testing.T) {
+	s := New()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	s.Update(&event.Event{Type: event.TypeWorldJoin, WorldName: ptr("World A"), Ts: base})
+	s.Update(&event.Event{Type: event.TypePlayerJoin, PlayerName: ptr("Alice"), Ts: base})
+	s.Update(&event.Event{Type: event.TypePlayerJoin, PlayerName: ptr("Bob"), PlayerID: ptr("usr_b"), Ts: base})
+	s.Update(&event.Event{Type: event.TypePlayerLeft, PlayerName: ptr("Alice"), Ts: base})
+
+	snap := s.Snapshot()
+	if snap.World == nil || snap.World.WorldName != "World A" || len(snap.Players) != 1 || snap.PeakPlayers != 2 {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+
+	restored := New()
+	restored.Restore(snap)
+	if restored.PlayerCount() != 1 || restored.CurrentWorld().WorldName != "World A" {
+		t.Errorf("restored state = %+v, %d players", restored.CurrentWorld(), restored.PlayerCount())
+	}
+
+	// A duplicate join of a restored player is ignored
+	if d := restored.Update(&event.Event{Type: event.TypePlayerJoin, PlayerName: ptr("Bob"), PlayerID: ptr("usr_b"), Ts: base}); d != nil {
+		t.Errorf("expected duplicate join to be ignored, got %+v", d)
+	}
+
+	// The restored session still produces a recap
+	d := restored.Update(&event.Event{Type: event.TypeWorldJoin, WorldName: ptr("World B"), Ts: base.Add(time.Hour)})
+	if d.Recap == nil || d.Recap.PeakPlayers != 2 || len(d.Recap.PlayersSeen) != 1 {
+		t.Errorf("recap = %+v", d.Recap)
+	}
+}
+
+func TestMultiState_SeparatesAccounts(t *testing.T) {
+	m := NewMulti()
+	ts := time.Now()
+
+	m.Update(&event.Event{Type: event.TypeWorldJoin, Ts: ts, WorldID: ptr("wrld_main")})
+	m.Update(&event.Event{Type: event.TypePlayerJoin, Ts: ts, PlayerName: ptr("Alice")})
+	m.Update(&event.Event{Type: event.TypeWorldJoin, Ts: ts, Account: ptr("alt"), WorldID: ptr("wrld_alt")})
+
+	// The alt's world change does not clear the default account's players
+	if n := m.For("").PlayerCount(); n != 1 {
+		t.Errorf("default players = %d, want 1", n)
+	}
+	if w := m.For("alt").CurrentWorld(); w == nil || w.WorldID != "wrld_alt" {
+		t.Errorf("alt world = %+v", w)
+	}
+	if accounts := m.Accounts(); len(accounts) != 2 || accounts[0] != "" || accounts[1] != "alt" {
+		t.Errorf("accounts = %q", accounts)
+	}
+
+	// CurrentWorld follows the most recently updated account
+	if w := m.CurrentWorld(); w == nil || w.WorldID != "wrld_alt" {
+		t.Errorf("current world = %+v, want wrld_alt", w)
+	}
+	derived := m.Update(&event.Event{Type: event.TypePlayerLeft, Ts: ts, PlayerName: ptr("Alice")})
+	if derived == nil || derived.PlayerCount != 0 {
+		t.Errorf("derived = %+v, want Alice leaving the default account", derived)
+	}
+	if w := m.CurrentWorld(); w == nil || w.WorldID != "wrld_main" {
+		t.Errorf("current world = %+v, want wrld_main", w)
+	}
+
+	if m.Lookup("unknown") != nil {
+		t.Error("expected no state for an unseen account")
+	}
+}
@@ -0,0 +1,414 @@
+// Package discordbot runs an optional Discord bot that answers slash
+// commands such as /whoishere and /lastseen. It connects out to the Discord
+// gateway, so nothing needs to be reachable from the internet.
+package discordbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/version"
+)
+
+// Defaults for Bot.
+const (
+	DefaultGatewayURL = "wss://gateway.discord.gg/?v=10&encoding=json"
+	DefaultAPIBase    = "https://discord.com/api/v10"
+
+	MinReconnectDelay = time.Second
+	MaxReconnectDelay = 2 * time.Minute
+
+	// responseTimeout bounds answering one command. Discord drops
+	// interactions not answered within 3 seconds.
+	responseTimeout = 3 * time.Second
+	dialTimeout     = 30 * time.Second
+)
+
+// Gateway opcodes.
+const (
+	gatewayDispatch       = 0
+	gatewayHeartbeat      = 1
+	gatewayIdentify       = 2
+	gatewayReconnect      = 7
+	gatewayInvalidSession = 9
+	gatewayHello          = 10
+	gatewayHeartbeatAck   = 11
+)
+
+// interactionApplicationCommand is the interaction type of a slash command.
+const interactionApplicationCommand = 2
+
+// maxContentLength is Discord's message content limit.
+const maxContentLength = 2000
+
+// ErrTokenRejected is returned by Run when Discord closes the gateway
+// connection for a reason reconnecting cannot fix, e.g. an invalid token.
+var ErrTokenRejected = errors.New("discord bot token rejected")
+
+// Handler answers slash commands. Implemented by Commands.
+type Handler interface {
+	// Commands returns the commands to register with Discord.
+	Commands() []Command
+	// Handle returns the reply to a command. options maps option names to
+	// their values.
+	Handle(ctx context.Context, name string, options map[string]string) string
+}
+
+// Command is a slash command definition, as registered with Discord.
+type Command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []CommandOption `json:"options,omitempty"`
+}
+
+// CommandOption is an argument of a slash command.
+type CommandOption struct {
+	Type        int    `json:"type"` // OptionString
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// OptionString is the Discord option type of a string argument.
+const OptionString = 3
+
+// Bot keeps a gateway connection open and answers slash commands.
+type Bot struct {
+	token      config.Secret
+	handler    Handler
+	gatewayURL string
+	apiBase    string
+	client     *http.Client
+	logger     *slog.Logger
+
+	registered bool // commands registered during this Run
+}
+
+// Option configures a Bot.
+type Option func(*Bot)
+
+// WithLogger sets the logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(b *Bot) {
+		if logger != nil {
+			b.logger = logger
+		}
+	}
+}
+
+// WithEndpoints overrides the gateway and REST API URLs (for testing).
+func WithEndpoints(gatewayURL, apiBase string) Option {
+	return func(b *Bot) {
+		b.gatewayURL = gatewayURL
+		b.apiBase = apiBase
+	}
+}
+
+// New creates a Bot. Call Run to connect.
+func New(token config.Secret, handler Handler, opts ...Option) *Bot {
+	b := &Bot{
+		token:      token,
+		handler:    handler,
+		gatewayURL: DefaultGatewayURL,
+		apiBase:    DefaultAPIBase,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		logger:     slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// gatewayPayload is a gateway message.
+type gatewayPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// Run connects to the gateway and answers commands until ctx is cancelled,
+// reconnecting with exponential backoff when the connection drops.
+// Returns an error wrapping ErrTokenRejected if Discord refuses the bot.
+func (b *Bot) Run(ctx context.Context) error {
+	b.logger.Info("Discord bot starting")
+	defer b.logger.Info("Discord bot stopped")
+
+	delay := MinReconnectDelay
+	for {
+		start := time.Now()
+		err := b.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var ce *CloseError
+		if errors.As(err, &ce) && isFatalClose(ce.Code) {
+			return fmt.Errorf("%w: %v", ErrTokenRejected, err)
+		}
+
+		// A connection that lasted a while resets the backoff
+		if time.Since(start) > MaxReconnectDelay {
+			delay = MinReconnectDelay
+		}
+		b.logger.Warn("Discord gateway disconnected, reconnecting", "error", err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, MaxReconnectDelay)
+	}
+}
+
+// isFatalClose reports whether a gateway close code means reconnecting
+// would fail again: authentication failed, invalid intents, and the like.
+func isFatalClose(code int) bool {
+	switch code {
+	case 4004, 4010, 4011, 4012, 4013, 4014:
+		return true
+	}
+	return false
+}
+
+// session runs one gateway connection until it fails or ctx is cancelled.
+func (b *Bot) session(ctx context.Context) error {
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	conn, err := dialWebSocket(dialCtx, b.gatewayURL)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("dial gateway: %w", err)
+	}
+	defer conn.Close(1000)
+	stop := context.AfterFunc(ctx, func() { conn.Close(1000) })
+	defer stop()
+
+	// The gateway opens with Hello, carrying the heartbeat interval
+	var hello struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	p, err := readPayload(conn)
+	if err != nil {
+		return err
+	}
+	if p.Op != gatewayHello || json.Unmarshal(p.D, &hello) != nil || hello.HeartbeatInterval <= 0 {
+		return fmt.Errorf("expected hello, got op %d", p.Op)
+	}
+	if err := b.identify(conn); err != nil {
+		return err
+	}
+
+	msgs := make(chan gatewayPayload)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			p, err := readPayload(conn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- p:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(time.Duration(hello.HeartbeatInterval) * time.Millisecond)
+	defer ticker.Stop()
+	var seq *int64
+	acked := true
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+			// No ack since the last heartbeat: the connection is dead
+			if !acked {
+				return errors.New("heartbeat not acknowledged")
+			}
+			acked = false
+			if err := sendPayload(conn, gatewayHeartbeat, seq); err != nil {
+				return err
+			}
+		case p := <-msgs:
+			if p.S != nil {
+				seq = p.S
+			}
+			switch p.Op {
+			case gatewayDispatch:
+				b.dispatch(ctx, p)
+			case gatewayHeartbeat:
+				if err := sendPayload(conn, gatewayHeartbeat, seq); err != nil {
+					return err
+				}
+			case gatewayHeartbeatAck:
+				acked = true
+			case gatewayReconnect:
+				return errors.New("gateway requested reconnect")
+			case gatewayInvalidSession:
+				return errors.New("gateway invalidated the session")
+			}
+		}
+	}
+}
+
+// identify authenticates the connection. The bot needs no intents:
+// interactions are delivered regardless.
+func (b *Bot) identify(conn *wsConn) error {
+	return sendPayload(conn, gatewayIdentify, map[string]any{
+		"token":   b.token.Value(),
+		"intents": 0,
+		"properties": map[string]string{
+			"os":      runtime.GOOS,
+			"browser": "vrclog",
+			"device":  "vrclog",
+		},
+	})
+}
+
+// dispatch handles a gateway event.
+func (b *Bot) dispatch(ctx context.Context, p gatewayPayload) {
+	switch p.T {
+	case "READY":
+		var ready struct {
+			Application struct {
+				ID string `json:"id"`
+			} `json:"application"`
+		}
+		if err := json.Unmarshal(p.D, &ready); err != nil {
+			b.logger.Warn("invalid READY event", "error", err)
+			return
+		}
+		b.logger.Info("Discord bot connected")
+		if !b.registered {
+			go b.registerCommands(ctx, ready.Application.ID)
+			b.registered = true
+		}
+
+	case "INTERACTION_CREATE":
+		var in interaction
+		if err := json.Unmarshal(p.D, &in); err != nil {
+			b.logger.Warn("invalid interaction", "error", err)
+			return
+		}
+		if in.Type != interactionApplicationCommand {
+			return
+		}
+		go b.respond(ctx, in)
+	}
+}
+
+// interaction is the part of an INTERACTION_CREATE event the bot uses.
+type interaction struct {
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	Type  int    `json:"type"`
+	Data  struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string `json:"name"`
+			Value any    `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// respond answers a slash command.
+func (b *Bot) respond(ctx context.Context, in interaction) {
+	ctx, cancel := context.WithTimeout(ctx, responseTimeout)
+	defer cancel()
+
+	options := make(map[string]string, len(in.Data.Options))
+	for _, o := range in.Data.Options {
+		options[o.Name] = fmt.Sprint(o.Value)
+	}
+	content := b.handler.Handle(ctx, in.Data.Name, options)
+	if r := []rune(content); len(r) > maxContentLength {
+		content = string(r[:maxContentLength-1]) + "…"
+	}
+
+	body := map[string]any{
+		"type": 4, // CHANNEL_MESSAGE_WITH_SOURCE
+		"data": map[string]any{
+			"content":          content,
+			"allowed_mentions": map[string]any{"parse": []string{}},
+		},
+	}
+	path := "/interactions/" + in.ID + "/" + in.Token + "/callback"
+	if err := b.call(ctx, http.MethodPost, path, body); err != nil {
+		b.logger.Warn("failed to answer Discord command", "command", in.Data.Name, "error", err)
+	}
+}
+
+// registerCommands replaces the bot's global slash commands with the
+// handler's.
+func (b *Bot) registerCommands(ctx context.Context, appID string) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := b.call(ctx, http.MethodPut, "/applications/"+appID+"/commands", b.handler.Commands()); err != nil {
+		b.logger.Warn("failed to register Discord commands", "error", err)
+	}
+}
+
+// call sends an authenticated REST API request.
+func (b *Bot) call(ctx context.Context, method, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.apiBase+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bot "+b.token.Value())
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DiscordBot (https://github.com/graaaa/vrclog-companion, "+version.String()+")")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// The path of interaction callbacks holds a token; leave it out
+		return fmt.Errorf("%s request: status %d", method, resp.StatusCode)
+	}
+	return nil
+}
+
+// readPayload reads and decodes one gateway message.
+func readPayload(conn *wsConn) (gatewayPayload, error) {
+	var p gatewayPayload
+	msg, err := conn.ReadMessage()
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(msg, &p); err != nil {
+		return p, fmt.Errorf("decode gateway message: %w", err)
+	}
+	return p, nil
+}
+
+// sendPayload encodes and sends one gateway message.
+func sendPayload(conn *wsConn, op int, d any) error {
+	data, err := json.Marshal(map[string]any{"op": op, "d": d})
+	if err != nil {
+		return err
+	}
+	return conn.WriteText(data)
+}
@@ -0,0 +1,198 @@
+package discordbot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+type stubState struct {
+	world   *derive.WorldInfo
+	players []derive.PlayerInfo
+}
+
+func (s *stubState) CurrentWorld() *derive.WorldInfo     { return s.world }
+func (s *stubState) CurrentPlayers() []derive.PlayerInfo { return s.players }
+
+// stubPlayers returns seen for every lookup, or ErrNotFound if nil.
+type stubPlayers struct {
+	seen *store.LastSeen
+}
+
+func (s *stubPlayers) LastSeen(ctx context.Context, player, account string) (*store.LastSeen, error) {
+	if s.seen == nil {
+		return nil, store.ErrNotFound
+	}
+	return s.seen, nil
+}
+
+func TestCommands_WhoIsHere(t *testing.T) {
+	joined := time.Unix(1700000000, 0)
+	c := &Commands{State: &stubState{
+		world: &derive.WorldInfo{WorldName: "The Great Pug", InstanceID: "123~friends(usr_a)", JoinedAt: joined},
+		players: []derive.PlayerInfo{
+			{PlayerName: "Bob", JoinedAt: joined.Add(2 * time.Minute)},
+			{PlayerName: "Alice", JoinedAt: joined.Add(time.Minute)},
+		},
+	}}
+
+	got := c.Handle(context.Background(), CommandWhoIsHere, nil)
+	want := "In **The Great Pug** (friends) since <t:1700000000:f> (<t:1700000000:R>) with 2 players:\n- Alice\n- Bob"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	c.State = &stubState{}
+	if got := c.Handle(context.Background(), CommandWhoIsHere, nil); got != "Not in an instance right now." {
+		t.Errorf("outside an instance: got %q", got)
+	}
+}
+
+func TestCommands_LastSeen(t *testing.T) {
+	seen := time.Unix(1700000000, 0)
+	c := &Commands{
+		State: &stubState{players: []derive.PlayerInfo{{PlayerName: "Carol", PlayerID: "usr_c"}}},
+		Players: &stubPlayers{seen: &store.LastSeen{
+			Event: event.Event{Type: event.TypePlayerLeft, Ts: seen, PlayerName: event.StringPtr("Alice")},
+			World: &store.SeenWorld{WorldName: event.StringPtr("Club")},
+			With:  []store.PlayerRef{{PlayerName: "Bob"}, {PlayerName: "Eve"}},
+		}},
+	}
+
+	tests := []struct {
+		player string
+		want   string
+	}{
+		{"Alice", "**Alice** was last seen <t:1700000000:f> (<t:1700000000:R>) in **Club** with Bob, Eve."},
+		{"usr_c", "**Carol** is here right now."},
+		{"", "Give a display name or usr_ ID."},
+	}
+	for _, tt := range tests {
+		got := c.Handle(context.Background(), CommandLastSeen, map[string]string{"player": tt.player})
+		if got != tt.want {
+			t.Errorf("lastseen %q = %q, want %q", tt.player, got, tt.want)
+		}
+	}
+
+	c.Players = &stubPlayers{}
+	if got := c.Handle(context.Background(), CommandLastSeen, map[string]string{"player": "Dave"}); got != "Never seen **Dave**." {
+		t.Errorf("unknown player: got %q", got)
+	}
+}
+
+// fakeGateway accepts one WebSocket connection and runs script on it.
+func fakeGateway(t *testing.T, script func(c *wsConn)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+		// Frames written by the server are masked too; clients accept both
+		script(&wsConn{conn: conn, br: bufio.NewReader(rw)})
+	}))
+}
+
+func TestBot_AnswersInteraction(t *testing.T) {
+	callbacks := make(chan map[string]any, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot secret-token" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/interactions/42/tok/callback" {
+			var v map[string]any
+			json.Unmarshal(body, &v)
+			callbacks <- v
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer api.Close()
+
+	identified := make(chan map[string]any, 1)
+	gw := fakeGateway(t, func(c *wsConn) {
+		c.WriteText([]byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		msg, err := c.ReadMessage()
+		if err != nil {
+			t.Errorf("read identify: %v", err)
+			return
+		}
+		var p map[string]any
+		json.Unmarshal(msg, &p)
+		identified <- p
+		c.WriteText([]byte(`{"op":0,"s":1,"t":"READY","d":{"application":{"id":"app1"}}}`))
+		c.WriteText([]byte(`{"op":0,"s":2,"t":"INTERACTION_CREATE","d":{"id":"42","token":"tok","type":2,` +
+			`"data":{"name":"lastseen","options":[{"name":"player","type":3,"value":"Alice"}]}}}`))
+		c.ReadMessage() // block until the client goes away
+	})
+	defer gw.Close()
+
+	handler := &Commands{State: &stubState{}, Players: &stubPlayers{}}
+	bot := New("secret-token", handler, WithEndpoints("ws"+strings.TrimPrefix(gw.URL, "http"), api.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bot.Run(ctx) }()
+
+	select {
+	case p := <-identified:
+		d, _ := p["d"].(map[string]any)
+		if p["op"] != float64(gatewayIdentify) || d["token"] != "secret-token" {
+			t.Errorf("identify = %v", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for identify")
+	}
+
+	select {
+	case v := <-callbacks:
+		data, _ := v["data"].(map[string]any)
+		if v["type"] != float64(4) || data["content"] != "Never seen **Alice**." {
+			t.Errorf("callback = %v", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the interaction response")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestBot_StopsWhenTokenRejected(t *testing.T) {
+	gw := fakeGateway(t, func(c *wsConn) {
+		c.WriteText([]byte(`{"op":10,"d":{"heartbeat_interval":45000}}`))
+		c.ReadMessage()
+		c.Close(4004)
+	})
+	defer gw.Close()
+
+	bot := New("bad", &Commands{}, WithEndpoints("ws"+strings.TrimPrefix(gw.URL, "http"), "http://127.0.0.1:0"))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bot.Run(ctx); !errors.Is(err, ErrTokenRejected) {
+		t.Errorf("Run = %v, want ErrTokenRejected", err)
+	}
+}
@@ -0,0 +1,162 @@
+package discordbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
+	"github.com/graaaaa/vrclog-companion/internal/store"
+)
+
+// Command names.
+const (
+	CommandWhoIsHere = "whoishere"
+	CommandLastSeen  = "lastseen"
+)
+
+// StateProvider provides the live instance state. Implemented by
+// derive.State.
+type StateProvider interface {
+	CurrentWorld() *derive.WorldInfo
+	CurrentPlayers() []derive.PlayerInfo
+}
+
+// PlayerLookup finds the last sighting of a player. Implemented by
+// store.Store.
+type PlayerLookup interface {
+	LastSeen(ctx context.Context, player, account string) (*store.LastSeen, error)
+}
+
+// Commands answers /whoishere from derived state and /lastseen from the
+// store. It implements Handler.
+type Commands struct {
+	State   StateProvider
+	Players PlayerLookup
+}
+
+// Commands returns the slash command definitions.
+func (c *Commands) Commands() []Command {
+	return []Command{
+		{Name: CommandWhoIsHere, Description: "Show the current instance and who is in it"},
+		{
+			Name:        CommandLastSeen,
+			Description: "Show when a player was last seen",
+			Options: []CommandOption{
+				{Type: OptionString, Name: "player", Description: "Display name or usr_ ID", Required: true},
+			},
+		},
+	}
+}
+
+// Handle answers a command.
+func (c *Commands) Handle(ctx context.Context, name string, options map[string]string) string {
+	switch name {
+	case CommandWhoIsHere:
+		return c.whoIsHere()
+	case CommandLastSeen:
+		return c.lastSeen(ctx, strings.TrimSpace(options["player"]))
+	default:
+		return "Unknown command."
+	}
+}
+
+// whoIsHere describes the current instance and lists its players in join
+// order.
+func (c *Commands) whoIsHere() string {
+	world := c.State.CurrentWorld()
+	if world == nil {
+		return "Not in an instance right now."
+	}
+
+	players := c.State.CurrentPlayers()
+	slices.SortFunc(players, func(a, b derive.PlayerInfo) int {
+		return a.JoinedAt.Compare(b.JoinedAt)
+	})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "In **%s**", worldLabel(world.WorldName, world.WorldID))
+	if t := instance.Parse(world.InstanceID).Type; t != "" {
+		fmt.Fprintf(&sb, " (%s)", t)
+	}
+	fmt.Fprintf(&sb, " since %s with %d %s", discordTime(world.JoinedAt), len(players), plural(len(players), "player"))
+	for i, p := range players {
+		if i == 0 {
+			sb.WriteString(":")
+		}
+		sb.WriteString("\n- " + p.PlayerName)
+	}
+	return sb.String()
+}
+
+// lastSeen reports when a player was last seen and in which world.
+func (c *Commands) lastSeen(ctx context.Context, player string) string {
+	if player == "" {
+		return "Give a display name or usr_ ID."
+	}
+	for _, p := range c.State.CurrentPlayers() {
+		if p.PlayerName == player || (p.PlayerID != "" && p.PlayerID == player) {
+			return fmt.Sprintf("**%s** is here right now.", p.PlayerName)
+		}
+	}
+
+	seen, err := c.Players.LastSeen(ctx, player, "")
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Sprintf("Never seen **%s**.", player)
+	}
+	if err != nil {
+		return "Could not look that up right now."
+	}
+
+	name := player
+	if seen.Event.PlayerName != nil {
+		name = *seen.Event.PlayerName
+	}
+	msg := fmt.Sprintf("**%s** was last seen %s", name, discordTime(seen.Event.Ts))
+	if w := seen.World; w != nil {
+		msg += " in **" + worldLabel(deref(w.WorldName), deref(w.WorldID)) + "**"
+	}
+	if len(seen.With) > 0 {
+		names := make([]string, len(seen.With))
+		for i, p := range seen.With {
+			names[i] = p.PlayerName
+		}
+		msg += " with " + strings.Join(names, ", ")
+	}
+	return msg + "."
+}
+
+// discordTime formats t as a Discord timestamp, which each reader sees in
+// their own time zone.
+func discordTime(t time.Time) string {
+	return fmt.Sprintf("<t:%d:f> (<t:%d:R>)", t.Unix(), t.Unix())
+}
+
+// worldLabel returns the world name, falling back to its ID.
+func worldLabel(name, id string) string {
+	if name != "" {
+		return name
+	}
+	if id != "" {
+		return id
+	}
+	return "an unknown world"
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,175 @@
+// Package diskspace watches the free space on the volume holding the data
+// directory, so the app can warn before the disk fills up and stop
+// storing what it can do without while space is low.
+package diskspace
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultInterval is how often the free space is checked.
+const DefaultInterval = time.Minute
+
+// Usage is the space on a volume, in bytes.
+type Usage struct {
+	Free  uint64 // available to this process
+	Total uint64
+}
+
+// Check returns the space on the volume holding dir.
+func Check(dir string) (Usage, error) {
+	return usage(dir)
+}
+
+// Status is the result of the last check of a Monitor.
+type Status struct {
+	Dir          string     `json:"dir"`
+	FreeBytes    uint64     `json:"free_bytes"`
+	TotalBytes   uint64     `json:"total_bytes"`
+	MinFreeBytes uint64     `json:"min_free_bytes"`
+	Low          bool       `json:"low"`                  // free space is below MinFreeBytes
+	CheckedAt    *time.Time `json:"checked_at,omitempty"` // nil before the first check
+	Error        string     `json:"error,omitempty"`      // why the last check failed
+}
+
+// Monitor checks the free space on the volume holding a directory on an
+// interval. Space becomes low below the threshold and recovers once it is
+// 10% above it, so usage hovering around the threshold does not flap.
+type Monitor struct {
+	dir         string
+	minFree     uint64
+	interval    time.Duration
+	logger      *slog.Logger
+	onLow       func(Status)
+	onRecovered func(Status)
+	usage       func(dir string) (Usage, error)
+
+	low    atomic.Bool
+	mu     sync.Mutex
+	status Status
+}
+
+// Option configures a Monitor.
+type Option func(*Monitor)
+
+// WithInterval sets how often the free space is checked.
+func WithInterval(d time.Duration) Option {
+	return func(m *Monitor) {
+		if d > 0 {
+			m.interval = d
+		}
+	}
+}
+
+// WithLogger sets the logger for failed checks and state changes.
+func WithLogger(logger *slog.Logger) Option {
+	return func(m *Monitor) {
+		if logger != nil {
+			m.logger = logger
+		}
+	}
+}
+
+// WithOnLow sets a function called when free space falls below the
+// threshold. It runs on the monitor's goroutine.
+func WithOnLow(fn func(Status)) Option {
+	return func(m *Monitor) { m.onLow = fn }
+}
+
+// WithOnRecovered sets a function called when free space is back above
+// the threshold. It runs on the monitor's goroutine.
+func WithOnRecovered(fn func(Status)) Option {
+	return func(m *Monitor) { m.onRecovered = fn }
+}
+
+// New creates a Monitor for the volume holding dir that reports space as
+// low below minFree bytes.
+func New(dir string, minFree uint64, opts ...Option) *Monitor {
+	m := &Monitor{
+		dir:      dir,
+		minFree:  minFree,
+		interval: DefaultInterval,
+		logger:   slog.Default(),
+		usage:    usage,
+		status:   Status{Dir: dir, MinFreeBytes: minFree},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Run checks the free space now and then every interval until ctx is
+// done.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.check()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// check updates the status and calls onLow or onRecovered when the state
+// changes. A failed check keeps the previous state.
+func (m *Monitor) check() {
+	u, err := m.usage(m.dir)
+	now := time.Now().UTC()
+
+	m.mu.Lock()
+	m.status.CheckedAt = &now
+	if err != nil {
+		if m.status.Error == "" {
+			m.logger.Warn("failed to check free disk space", "dir", m.dir, "error", err)
+		}
+		m.status.Error = err.Error()
+		m.mu.Unlock()
+		return
+	}
+	wasLow := m.status.Low
+	low := u.Free < m.minFree
+	if wasLow && !low {
+		low = u.Free < m.minFree+m.minFree/10
+	}
+	m.status.FreeBytes, m.status.TotalBytes = u.Free, u.Total
+	m.status.Low = low
+	m.status.Error = ""
+	st := m.status
+	m.mu.Unlock()
+	m.low.Store(low)
+
+	switch {
+	case low && !wasLow:
+		m.logger.Warn("free disk space low", "dir", m.dir,
+			"free_mb", u.Free>>20, "min_free_mb", m.minFree>>20)
+		if m.onLow != nil {
+			m.onLow(st)
+		}
+	case !low && wasLow:
+		m.logger.Info("free disk space recovered", "dir", m.dir, "free_mb", u.Free>>20)
+		if m.onRecovered != nil {
+			m.onRecovered(st)
+		}
+	}
+}
+
+// Low reports whether free space was below the threshold at the last
+// check. Cheap enough to call before every write.
+func (m *Monitor) Low() bool {
+	return m.low.Load()
+}
+
+// Status returns the result of the last check.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
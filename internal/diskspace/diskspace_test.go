@@ -0,0 +1,69 @@
+package diskspace
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	u, err := Check(t.TempDir())
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if u.Total == 0 || u.Free > u.Total {
+		t.Errorf("Check = %+v", u)
+	}
+}
+
+func TestMonitor_LowAndRecovered(t *testing.T) {
+	const mb = 1 << 20
+	var free uint64 = 600 * mb
+	var checkErr error
+	var lows, recoveries int
+	m := New("/data", 500*mb,
+		WithOnLow(func(Status) { lows++ }),
+		WithOnRecovered(func(Status) { recoveries++ }))
+	m.usage = func(string) (Usage, error) { return Usage{Free: free, Total: 1000 * mb}, checkErr }
+
+	if st := m.Status(); st.CheckedAt != nil || st.Low {
+		t.Errorf("status before the first check = %+v", st)
+	}
+	m.check()
+	if m.Low() || lows != 0 {
+		t.Errorf("low with 600 MB free of a 500 MB threshold")
+	}
+
+	free = 400 * mb
+	m.check()
+	m.check()
+	if !m.Low() || lows != 1 {
+		t.Errorf("Low = %v after %d calls, want low once", m.Low(), lows)
+	}
+	if st := m.Status(); !st.Low || st.FreeBytes != 400*mb || st.MinFreeBytes != 500*mb {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Just above the threshold is not enough to recover
+	free = 520 * mb
+	m.check()
+	if !m.Low() || recoveries != 0 {
+		t.Error("recovered within 10% of the threshold")
+	}
+
+	// A failed check keeps the state
+	checkErr = errors.New("device not ready")
+	free = 900 * mb
+	m.check()
+	if st := m.Status(); !st.Low || st.Error != "device not ready" {
+		t.Errorf("status after a failed check = %+v", st)
+	}
+
+	checkErr = nil
+	m.check()
+	if m.Low() || recoveries != 1 {
+		t.Errorf("Low = %v with %d recoveries, want recovered once", m.Low(), recoveries)
+	}
+	if st := m.Status(); st.Error != "" || st.FreeBytes != 900*mb {
+		t.Errorf("status after recovery = %+v", st)
+	}
+}
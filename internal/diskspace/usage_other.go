@@ -0,0 +1,16 @@
+//go:build !windows
+
+package diskspace
+
+import "syscall"
+
+// usage returns the space on the volume holding dir. Free counts the
+// blocks available to unprivileged users, not those reserved for root.
+func usage(dir string) (Usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return Usage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return Usage{Free: st.Bavail * bsize, Total: st.Blocks * bsize}, nil
+}
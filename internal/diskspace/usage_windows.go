@@ -0,0 +1,19 @@
+//go:build windows
+
+package diskspace
+
+import "golang.org/x/sys/windows"
+
+// usage returns the space on the volume holding dir. Free is what is
+// available to the current user, which disk quotas may lower.
+func usage(dir string) (Usage, error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return Usage{}, err
+	}
+	var free, total uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &free, &total, nil); err != nil {
+		return Usage{}, err
+	}
+	return Usage{Free: free, Total: total}, nil
+}
@@ -12,8 +12,42 @@ const (
 	TypePlayerJoin = "player_join"
 	TypePlayerLeft = "player_left"
 	TypeWorldJoin  = "world_join"
+	TypeScreenshot = "screenshot" // meta: {"path": "..."}
+	TypeVideoPlay  = "video_play" // meta: {"url": "..."}
+	TypeSystem     = "system"     // meta: {"kind": "...", ...}; see System* kinds
 )
 
+// Kinds of system events, which record what the app itself did so the
+// timeline can explain gaps.
+const (
+	SystemAppStarted         = "app_started" // meta: {"version": "..."}
+	SystemAppStopped         = "app_stopped"
+	SystemWatcherAttached    = "watcher_attached"  // meta: {"path": "...", "previous": "..."}; previous is set on log rotation
+	SystemWatcherRestarted   = "watcher_restarted" // meta: {"reason": "...", "attempt": "N"}
+	SystemNotifierDisabled   = "notifier_disabled" // meta: {"reason": "..."}
+	SystemDBVacuumed         = "db_vacuumed"
+	SystemAuthLockout        = "auth_lockout"         // meta: {"ip": "...", "failures": "N"}
+	SystemSubsystemPanic     = "subsystem_panic"      // meta: {"subsystem": "...", "panic": "..."}; restarted after a backoff
+	SystemDiskSpaceLow       = "disk_space_low"       // meta: {"free_mb": "N", "min_free_mb": "N"}
+	SystemDiskSpaceRecovered = "disk_space_recovered" // meta: {"free_mb": "N"}
+)
+
+// IsValidType reports whether t is a known event type.
+func IsValidType(t string) bool {
+	switch t {
+	case TypePlayerJoin, TypePlayerLeft, TypeWorldJoin, TypeScreenshot, TypeVideoPlay, TypeSystem:
+		return true
+	}
+	return false
+}
+
+// IsWorldScoped reports whether events of type t happen inside a world
+// without naming it in the log line. The store stamps such events with the
+// world and instance the user was in at the time.
+func IsWorldScoped(t string) bool {
+	return t == TypeScreenshot || t == TypeVideoPlay
+}
+
 // Event represents a VRChat log event.
 // This is the domain model shared across packages, independent of storage implementation.
 type Event struct {
@@ -25,6 +59,11 @@ type Event struct {
 	WorldID       *string         `json:"world_id,omitempty"`
 	WorldName     *string         `json:"world_name,omitempty"`
 	InstanceID    *string         `json:"instance_id,omitempty"`
+	InstanceType  *string         `json:"instance_type,omitempty"`
+	Region        *string         `json:"region,omitempty"`
+	GroupID       *string         `json:"group_id,omitempty"`
+	DurationSec   *int64          `json:"duration_sec,omitempty"` // player_left only: time since the matching join
+	Account       *string         `json:"account,omitempty"`      // VRChat account the log belongs to; nil for the default account
 	MetaJSON      json.RawMessage `json:"meta,omitempty"`
 	DedupeKey     string          `json:"-"`
 	IngestedAt    time.Time       `json:"ingested_at"`
@@ -0,0 +1,16 @@
+package event
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeName returns the form of a display name used to compare names:
+// NFKC-normalized, so full-width and half-width forms match, case-folded and
+// trimmed. Two names are the same player name if their normalized forms are
+// equal.
+func NormalizeName(name string) string {
+	return cases.Fold().String(norm.NFKC.String(strings.TrimSpace(name)))
+}
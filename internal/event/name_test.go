@@ -0,0 +1,25 @@
+package event
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Alice", "alice"},
+		{"ＡＬＩＣＥ", "alice"},    // full-width
+		{"ｱﾘｽ", "アリス"},        // half-width katakana
+		{"Straße", "STRASSE"}, // full case folding
+		{" Bob ", "bob"},
+		{"が", "か\u3099"}, // combining dakuten composes
+		{"🐱Cat", "🐱cat"},
+	}
+	for _, tt := range tests {
+		if a, b := NormalizeName(tt.a), NormalizeName(tt.b); a != b {
+			t.Errorf("NormalizeName(%q) = %q, NormalizeName(%q) = %q; want equal", tt.a, a, tt.b, b)
+		}
+	}
+	if NormalizeName("アリス") == NormalizeName("ありす") {
+		t.Error("katakana and hiragana should stay distinct")
+	}
+}
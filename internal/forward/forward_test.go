@@ -0,0 +1,186 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/api"
+	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
+
+// stubReceiver records events ingested by the receiving server.
+type stubReceiver struct {
+	mu     sync.Mutex
+	events []ingest.Event
+	seen   map[string]bool
+}
+
+func (r *stubReceiver) Ingest(ctx context.Context, ev ingest.Event) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := ingest.DedupeKey(ev.Account, ev.RawLine)
+	if r.seen[key] {
+		return false, nil
+	}
+	r.seen[key] = true
+	r.events = append(r.events, ev)
+	return true, nil
+}
+
+func TestClient_SendToServer(t *testing.T) {
+	recv := &stubReceiver{seen: map[string]bool{}}
+	server := api.NewServer(":0", app.HealthService{Version: "test"},
+		api.WithReceiveUsecase(app.ReceiveService{Ingester: recv}),
+		api.WithBasicAuth("agent", "secret"),
+		api.WithCSRFAllowedHosts([]string{"example.com"}))
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	ts0 := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	batch := []ingest.Event{
+		{Type: "world_join", Timestamp: ts0, WorldID: "wrld_a", InstanceID: "1~region(jp)", RawLine: "a"},
+		{Type: "player_join", Timestamp: ts0, PlayerName: "Alice", RawLine: "b", Account: "pc"},
+	}
+
+	// Wrong credentials are rejected, but not as a permanent error
+	bad, err := NewClient("http://agent:wrong@" + ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	var statusErr *StatusError
+	if err := bad.Send(context.Background(), batch); !errors.As(err, &statusErr) ||
+		statusErr.Code != http.StatusUnauthorized || statusErr.Permanent() {
+		t.Fatalf("Send with wrong password: err = %v", err)
+	}
+
+	client, err := NewClient("http://agent:secret@" + ts.Listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if want := ts.URL + EventsPath; client.Endpoint() != want {
+		t.Errorf("Endpoint = %q, want %q", client.Endpoint(), want)
+	}
+	for range 2 { // resending is harmless
+		if err := client.Send(context.Background(), batch); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if len(recv.events) != 2 || recv.events[1].Account != "pc" || !recv.events[0].Timestamp.Equal(ts0) {
+		t.Errorf("received %+v", recv.events)
+	}
+
+	// Invalid events are a permanent failure
+	err = client.Send(context.Background(), []ingest.Event{{Type: "bogus", Timestamp: ts0, RawLine: "c"}})
+	if !errors.As(err, &statusErr) || !statusErr.Permanent() {
+		t.Errorf("Send invalid event: err = %v, want a permanent StatusError", err)
+	}
+}
+
+func TestNewClient_InvalidURL(t *testing.T) {
+	for _, target := range []string{"", "ftp://host", "http://", "::"} {
+		if _, err := NewClient(target); err == nil {
+			t.Errorf("NewClient(%q): expected error", target)
+		}
+	}
+}
+
+// chanSource is an EventSource fed by the test.
+type chanSource struct {
+	events chan ingest.Event
+	errs   chan error
+}
+
+func (s *chanSource) Start(ctx context.Context) (<-chan ingest.Event, <-chan error, error) {
+	return s.events, s.errs, nil
+}
+
+// fakeSender fails while down is set and records sent batches otherwise.
+type fakeSender struct {
+	mu      sync.Mutex
+	down    bool
+	batches [][]ingest.Event
+	sent    chan struct{}
+}
+
+func (s *fakeSender) Send(ctx context.Context, events []ingest.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.batches = append(s.batches, append([]ingest.Event(nil), events...))
+	s.sent <- struct{}{}
+	return nil
+}
+
+func TestForwarder_BatchesAndRetries(t *testing.T) {
+	src := &chanSource{events: make(chan ingest.Event), errs: make(chan error)}
+	sender := &fakeSender{down: true, sent: make(chan struct{}, 10)}
+	f := New(src, sender, WithBatchSize(2), WithMaxPending(3), WithFlushInterval(time.Hour))
+
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	// The first full batch fails and is kept for retry
+	f.add(ingest.Event{RawLine: "1"})
+	f.add(ingest.Event{RawLine: "2"})
+	f.flush(context.Background())
+	if f.Pending() != 2 || f.backoff != MinRetryBackoff {
+		t.Fatalf("pending = %d, backoff = %v", f.Pending(), f.backoff)
+	}
+
+	// While down, the oldest events are dropped beyond maxPending
+	f.add(ingest.Event{RawLine: "3"})
+	f.add(ingest.Event{RawLine: "4"})
+	if f.Pending() != 3 || f.pending[0].RawLine != "2" {
+		t.Fatalf("pending = %+v", f.pending)
+	}
+
+	// Nothing is sent before the backoff elapses
+	sender.down = false
+	f.flush(context.Background())
+	if len(sender.batches) != 0 {
+		t.Fatalf("sent %d batches during backoff", len(sender.batches))
+	}
+
+	now = now.Add(MinRetryBackoff)
+	f.flush(context.Background())
+	if f.Pending() != 0 || len(sender.batches) != 2 || len(sender.batches[0]) != 2 || len(sender.batches[1]) != 1 {
+		t.Fatalf("pending = %d, batches = %+v", f.Pending(), sender.batches)
+	}
+	if f.backoff != 0 {
+		t.Errorf("backoff = %v after success, want 0", f.backoff)
+	}
+}
+
+func TestForwarder_RunFlushesOnShutdown(t *testing.T) {
+	src := &chanSource{events: make(chan ingest.Event), errs: make(chan error)}
+	sender := &fakeSender{sent: make(chan struct{}, 10)}
+	f := New(src, sender, WithBatchSize(10), WithFlushInterval(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- f.Run(ctx) }()
+
+	src.events <- ingest.Event{RawLine: "1"}
+	src.errs <- &ingest.ParseError{Line: "junk"}
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for Run to return")
+	}
+	if len(sender.batches) != 1 || sender.batches[0][0].RawLine != "1" {
+		t.Errorf("batches = %+v, want the pending event sent on shutdown", sender.batches)
+	}
+}
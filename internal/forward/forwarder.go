@@ -0,0 +1,213 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/ingest"
+)
+
+// Defaults for Forwarder.
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+	DefaultMaxPending    = 10000
+	MinRetryBackoff      = time.Second
+	MaxRetryBackoff      = time.Minute
+
+	// finalFlushTimeout bounds the last send attempt on shutdown.
+	finalFlushTimeout = 3 * time.Second
+)
+
+// Sender posts a batch of events. Implemented by Client.
+type Sender interface {
+	Send(ctx context.Context, events []ingest.Event) error
+}
+
+// Forwarder reads events from a source and sends them in batches. While the
+// receiver is unreachable, events are kept (up to a limit, oldest dropped
+// first) and retried with exponential backoff. The receiver discards
+// duplicates, so resending a batch is harmless.
+type Forwarder struct {
+	source        ingest.EventSource
+	sender        Sender
+	logger        *slog.Logger
+	batchSize     int
+	flushInterval time.Duration
+	maxPending    int
+	now           func() time.Time
+
+	pending []ingest.Event
+	backoff time.Duration
+	retryAt time.Time
+	dropped int
+}
+
+// Option configures a Forwarder.
+type Option func(*Forwarder)
+
+// WithLogger sets the logger for the Forwarder.
+func WithLogger(logger *slog.Logger) Option {
+	return func(f *Forwarder) {
+		if logger != nil {
+			f.logger = logger
+		}
+	}
+}
+
+// WithBatchSize sets the maximum number of events per request.
+func WithBatchSize(n int) Option {
+	return func(f *Forwarder) { f.batchSize = n }
+}
+
+// WithFlushInterval sets how often pending events are sent.
+func WithFlushInterval(d time.Duration) Option {
+	return func(f *Forwarder) { f.flushInterval = d }
+}
+
+// WithMaxPending sets how many unsent events are kept while the receiver
+// is unreachable.
+func WithMaxPending(n int) Option {
+	return func(f *Forwarder) { f.maxPending = n }
+}
+
+// New creates a Forwarder.
+func New(source ingest.EventSource, sender Sender, opts ...Option) *Forwarder {
+	f := &Forwarder{
+		source:        source,
+		sender:        sender,
+		logger:        slog.Default(),
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		maxPending:    DefaultMaxPending,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.batchSize < 1 {
+		f.batchSize = 1
+	}
+	if f.flushInterval <= 0 {
+		f.flushInterval = DefaultFlushInterval
+	}
+	f.maxPending = max(f.maxPending, f.batchSize)
+	return f
+}
+
+// Run forwards events until ctx is cancelled or the source closes, then
+// makes a last attempt to send what is pending. Returns ctx.Err() on
+// cancellation, nil on clean source shutdown.
+func (f *Forwarder) Run(ctx context.Context) error {
+	events, errs, err := f.source.Start(ctx)
+	if err != nil {
+		return err
+	}
+
+	f.logger.Info("forwarding started")
+	defer f.logger.Info("forwarding stopped")
+
+	ticker := time.NewTicker(f.flushInterval)
+	defer ticker.Stop()
+
+	for events != nil || errs != nil {
+		select {
+		case <-ctx.Done():
+			f.finalFlush()
+			return ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			f.add(ev)
+			if len(f.pending) >= f.batchSize {
+				f.flush(ctx)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			var parseErr *ingest.ParseError
+			if errors.As(err, &parseErr) {
+				f.logger.Debug("unparsed log line not forwarded", "error", err)
+				continue
+			}
+			f.logger.Warn("source error", "error", err)
+		case <-ticker.C:
+			f.flush(ctx)
+		}
+	}
+
+	f.finalFlush()
+	return ctx.Err()
+}
+
+// Pending returns the number of events not yet sent.
+func (f *Forwarder) Pending() int {
+	return len(f.pending)
+}
+
+// add queues ev, dropping the oldest event if the queue is full.
+func (f *Forwarder) add(ev ingest.Event) {
+	if len(f.pending) >= f.maxPending {
+		f.pending = f.pending[1:]
+		f.dropped++
+	}
+	f.pending = append(f.pending, ev)
+}
+
+// flush sends pending events in batches until the queue is empty or a send
+// fails. Does nothing while waiting to retry.
+func (f *Forwarder) flush(ctx context.Context) {
+	if f.dropped > 0 {
+		f.logger.Warn("receiver unreachable, dropped oldest events", "count", f.dropped)
+		f.dropped = 0
+	}
+	if f.now().Before(f.retryAt) {
+		return
+	}
+
+	for len(f.pending) > 0 {
+		n := min(len(f.pending), f.batchSize)
+		err := f.sender.Send(ctx, f.pending[:n])
+		if err != nil {
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) && statusErr.Permanent() {
+				// Retrying would fail forever and block newer events
+				f.logger.Error("receiver rejected events, dropping batch", "count", n, "error", err)
+				f.pending = f.pending[n:]
+				continue
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			f.backoff = min(max(2*f.backoff, MinRetryBackoff), MaxRetryBackoff)
+			f.retryAt = f.now().Add(f.backoff)
+			f.logger.Warn("failed to forward events, will retry",
+				"pending", len(f.pending), "retry_in", f.backoff, "error", err)
+			return
+		}
+		f.pending = f.pending[n:]
+		f.backoff = 0
+		f.retryAt = time.Time{}
+	}
+	f.pending = nil
+}
+
+// finalFlush makes one last attempt to send pending events on shutdown.
+func (f *Forwarder) finalFlush() {
+	if len(f.pending) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+	defer cancel()
+	f.retryAt = time.Time{}
+	f.flush(ctx)
+	if n := len(f.pending); n > 0 {
+		f.logger.Warn("events not forwarded before shutdown", "count", n)
+	}
+}
@@ -0,0 +1,146 @@
+// Package healthping pings an uptime monitor such as healthchecks.io or an
+// Uptime Kuma push monitor on an interval while ingestion is healthy. The
+// monitor alerts when the pings stop, so the user learns that the
+// companion died or stopped recording while they were in VR.
+package healthping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+// DefaultTimeout is the HTTP timeout for a single ping.
+const DefaultTimeout = 10 * time.Second
+
+// CheckFunc reports whether the app is healthy enough to ping: nil if
+// so, otherwise the reason it is not, which is logged.
+type CheckFunc func(ctx context.Context) error
+
+// StatusError is returned when the monitor answers with a non-2xx status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("uptime monitor returned HTTP %d", e.Code)
+	}
+	return fmt.Sprintf("uptime monitor returned HTTP %d: %s", e.Code, e.Body)
+}
+
+// Pinger pings the monitor's URL on an interval while check passes.
+type Pinger struct {
+	url        string
+	interval   time.Duration
+	check      CheckFunc
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// Option configures a Pinger.
+type Option func(*Pinger)
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Pinger) { p.httpClient = c }
+}
+
+// WithLogger sets the logger for failed and resumed pings.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Pinger) {
+		if logger != nil {
+			p.logger = logger
+		}
+	}
+}
+
+// New creates a Pinger for the monitor in cfg.
+func New(cfg config.Healthcheck, check CheckFunc, opts ...Option) (*Pinger, error) {
+	if err := config.ValidateHealthcheck(cfg); err != nil {
+		return nil, fmt.Errorf("invalid healthcheck: %w", err)
+	}
+	interval := cfg.IntervalSec
+	if interval == 0 {
+		interval = config.DefaultHealthcheckIntervalSec
+	}
+	p := &Pinger{
+		url:        cfg.URL.Value(),
+		interval:   time.Duration(interval) * time.Second,
+		check:      check,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		logger:     slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// Run pings every interval until ctx is done, the first time after one
+// interval, when the app has settled. While the check fails no pings are
+// sent, so the monitor alerts once its grace period runs out. Check and
+// ping failures are logged once until a ping succeeds again.
+func (p *Pinger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := p.check(ctx)
+		if err != nil {
+			err = fmt.Errorf("not pinging, app unhealthy: %w", err)
+		} else {
+			err = p.Ping(ctx)
+		}
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil && !failing:
+			p.logger.Warn("healthcheck ping failed", "error", err)
+			failing = true
+		case err == nil && failing:
+			p.logger.Info("healthcheck pings resumed")
+			failing = false
+		}
+	}
+}
+
+// Ping sends one ping, without checking health.
+func (p *Pinger) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		// The URL is a secret; keep it out of the logs
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("send ping: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+}
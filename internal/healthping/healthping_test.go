@@ -0,0 +1,110 @@
+package healthping
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+func TestNew_Invalid(t *testing.T) {
+	for _, cfg := range []config.Healthcheck{
+		{URL: "ftp://hc-ping.com/x"},
+		{URL: "https://hc-ping.com/x", IntervalSec: 5},
+	} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("New(%+v) accepted an invalid config", cfg)
+		}
+	}
+}
+
+func TestPinger_Ping(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte("not found"))
+		}
+	}))
+	defer ts.Close()
+
+	p, err := New(config.Healthcheck{URL: config.Secret(ts.URL + "/ping/uuid")}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := p.Ping(context.Background()); err != nil {
+		t.Errorf("Ping: %v", err)
+	}
+
+	status = http.StatusNotFound
+	var se *StatusError
+	if err := p.Ping(context.Background()); !errors.As(err, &se) || se.Code != http.StatusNotFound || se.Body != "not found" {
+		t.Errorf("Ping = %v, want StatusError 404", err)
+	}
+
+	ts.Close()
+	if err := p.Ping(context.Background()); err == nil || strings.Contains(err.Error(), "uuid") {
+		t.Errorf("Ping to a closed server = %v, want an error without the URL", err)
+	}
+}
+
+func TestPinger_Run_SkipsWhileUnhealthy(t *testing.T) {
+	var pings atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings.Add(1)
+	}))
+	defer ts.Close()
+
+	var healthy atomic.Bool
+	var logs bytes.Buffer
+	p, err := New(config.Healthcheck{URL: config.Secret(ts.URL)}, func(ctx context.Context) error {
+		if !healthy.Load() {
+			return errors.New("ingestion stopped")
+		}
+		return nil
+	}, WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p.interval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if n := pings.Load(); n != 0 {
+		t.Errorf("%d pings while unhealthy, want 0", n)
+	}
+	healthy.Store(true)
+	deadline := time.Now().Add(2 * time.Second)
+	for pings.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if pings.Load() == 0 {
+		t.Error("no pings once healthy")
+	}
+
+	cancel()
+	<-done
+
+	// The failure is logged once, until pings resume
+	out := logs.String()
+	if n := strings.Count(out, "healthcheck ping failed"); n != 1 || !strings.Contains(out, "ingestion stopped") {
+		t.Errorf("failure logged %d times: %s", n, out)
+	}
+	if !strings.Contains(out, "healthcheck pings resumed") {
+		t.Errorf("resume not logged: %s", out)
+	}
+}
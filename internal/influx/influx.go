@@ -0,0 +1,228 @@
+// Package influx pushes event counts and player counts to an InfluxDB or
+// VictoriaMetrics endpoint in the line protocol, for people who already
+// run a metrics stack.
+//
+// Every push writes, per account:
+//
+//	vrclog_events,type=player_join total=123i <ns>
+//	vrclog_players online=4i <ns>
+//
+// total counts the events ingested since the app started, so graph it
+// with non_negative_difference() or increase(). The default account has
+// no account tag; other accounts are tagged account=<name>.
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// DefaultTimeout is the HTTP timeout for a single push.
+const DefaultTimeout = 15 * time.Second
+
+// PlayerCounter reports the current player count of each account.
+type PlayerCounter interface {
+	PlayerCounts() map[string]int
+}
+
+// StatusError is returned when the endpoint answers with a non-2xx status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("metrics endpoint returned HTTP %d", e.Code)
+	}
+	return fmt.Sprintf("metrics endpoint returned HTTP %d: %s", e.Code, e.Body)
+}
+
+// counterKey identifies an event counter.
+type counterKey struct {
+	account string
+	typ     string
+}
+
+// Pusher counts ingested events and periodically pushes the counts along
+// with player counts.
+type Pusher struct {
+	endpoint   string
+	token      string
+	username   string
+	password   string
+	interval   time.Duration
+	tags       string // pre-escaped ",k=v" pairs added to every point
+	players    PlayerCounter
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	counts map[counterKey]int64
+}
+
+// Option configures a Pusher.
+type Option func(*Pusher)
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(p *Pusher) { p.httpClient = c }
+}
+
+// New creates a Pusher for the endpoint in cfg. players may be nil, in
+// which case no player counts are pushed.
+func New(cfg config.MetricsPush, players PlayerCounter, opts ...Option) (*Pusher, error) {
+	if err := config.ValidateMetricsPush(cfg); err != nil {
+		return nil, fmt.Errorf("invalid metrics push: %w", err)
+	}
+	interval := cfg.IntervalSec
+	if interval == 0 {
+		interval = config.DefaultMetricsPushIntervalSec
+	}
+	var tags strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(cfg.Tags)) {
+		tags.WriteString("," + escapeTag(k) + "=" + escapeTag(cfg.Tags[k]))
+	}
+	p := &Pusher{
+		endpoint:   cfg.URL,
+		token:      cfg.Token.Value(),
+		username:   cfg.Username,
+		password:   cfg.Password.Value(),
+		interval:   time.Duration(interval) * time.Second,
+		tags:       tags.String(),
+		players:    players,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		now:        time.Now,
+		counts:     make(map[counterKey]int64),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// Observe counts an ingested event. Safe for concurrent use.
+func (p *Pusher) Observe(e *event.Event) {
+	key := counterKey{typ: e.Type}
+	if e.Account != nil {
+		key.account = *e.Account
+	}
+	p.mu.Lock()
+	p.counts[key]++
+	p.mu.Unlock()
+}
+
+// Run pushes every interval until ctx is done. Failures are logged once
+// until a push succeeds again; counts are cumulative, so nothing is lost
+// in between.
+func (p *Pusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := p.Push(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil && !failing:
+			slog.Warn("metrics push failed", "error", err)
+			failing = true
+		case err == nil && failing:
+			slog.Info("metrics push recovered")
+			failing = false
+		}
+	}
+}
+
+// Push writes the current counts to the endpoint once.
+func (p *Pusher) Push(ctx context.Context) error {
+	body := p.lines(p.now())
+	if len(body) == 0 {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Token "+p.token)
+	} else if p.username != "" || p.password != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+}
+
+// lines returns the points to push at now, sorted so pushes are stable.
+func (p *Pusher) lines(now time.Time) []byte {
+	ts := " " + strconv.FormatInt(now.UnixNano(), 10) + "\n"
+	var b bytes.Buffer
+
+	p.mu.Lock()
+	keys := slices.SortedFunc(maps.Keys(p.counts), func(a, b counterKey) int {
+		if c := strings.Compare(a.account, b.account); c != 0 {
+			return c
+		}
+		return strings.Compare(a.typ, b.typ)
+	})
+	for _, k := range keys {
+		b.WriteString("vrclog_events" + p.tags + accountTag(k.account) + ",type=" + escapeTag(k.typ))
+		b.WriteString(" total=" + strconv.FormatInt(p.counts[k], 10) + "i" + ts)
+	}
+	p.mu.Unlock()
+
+	if p.players != nil {
+		counts := p.players.PlayerCounts()
+		for _, account := range slices.Sorted(maps.Keys(counts)) {
+			b.WriteString("vrclog_players" + p.tags + accountTag(account))
+			b.WriteString(" online=" + strconv.Itoa(counts[account]) + "i" + ts)
+		}
+	}
+	return b.Bytes()
+}
+
+// accountTag returns the account tag, or nothing for the default account.
+func accountTag(account string) string {
+	if account == "" {
+		return ""
+	}
+	return ",account=" + escapeTag(account)
+}
+
+// tagEscaper escapes tag keys and values for the line protocol, which has
+// no escape for newlines; they become spaces.
+var tagEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `)
+
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
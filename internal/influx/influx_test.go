@@ -0,0 +1,91 @@
+package influx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+type stubPlayers map[string]int
+
+func (s stubPlayers) PlayerCounts() map[string]int { return s }
+
+func TestPusher_Push(t *testing.T) {
+	var body, auth string
+	status := http.StatusNoContent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, auth = string(b), r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		if status != http.StatusNoContent {
+			io.WriteString(w, `{"error":"bucket not found"}`)
+		}
+	}))
+	defer ts.Close()
+
+	p, err := New(config.MetricsPush{
+		URL:   ts.URL + "/api/v2/write?org=home&bucket=vrclog",
+		Token: "tok",
+		Tags:  map[string]string{"host": "my pc"},
+	}, stubPlayers{"": 3, "alt,1": 1})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	p.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	// Nothing counted yet: only the gauges are pushed
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if strings.Contains(body, "vrclog_events") || !strings.Contains(body, "vrclog_players,host=my\\ pc online=3i") {
+		t.Errorf("first push = %q", body)
+	}
+
+	p.Observe(&event.Event{Type: event.TypePlayerJoin})
+	p.Observe(&event.Event{Type: event.TypePlayerJoin})
+	p.Observe(&event.Event{Type: event.TypeWorldJoin, Account: event.StringPtr("alt,1")})
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	want := strings.Join([]string{
+		`vrclog_events,host=my\ pc,type=player_join total=2i 1700000000000000000`,
+		`vrclog_events,host=my\ pc,account=alt\,1,type=world_join total=1i 1700000000000000000`,
+		`vrclog_players,host=my\ pc online=3i 1700000000000000000`,
+		`vrclog_players,host=my\ pc,account=alt\,1 online=1i 1700000000000000000`,
+	}, "\n") + "\n"
+	if body != want {
+		t.Errorf("body =\n%s\nwant\n%s", body, want)
+	}
+	if auth != "Token tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	status = http.StatusNotFound
+	var statusErr *StatusError
+	if err := p.Push(context.Background()); !errors.As(err, &statusErr) || statusErr.Code != http.StatusNotFound ||
+		!strings.Contains(statusErr.Body, "bucket not found") {
+		t.Errorf("Push to a bad bucket: err = %v", err)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for _, cfg := range []config.MetricsPush{
+		{URL: "localhost:8086"},
+		{URL: "http://localhost:8428/write", IntervalSec: 1},
+		{URL: "http://localhost:8086/write", Token: "tok", Username: "u"},
+		{URL: "http://localhost:8428/write", Tags: map[string]string{"type": "x"}},
+		{URL: "http://localhost:8428/write", Tags: map[string]string{"host": ""}},
+	} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
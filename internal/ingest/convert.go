@@ -3,9 +3,11 @@ package ingest
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
 )
 
 // Clock provides time for deterministic testing.
@@ -28,18 +30,39 @@ func ToStoreEvent(e Event) *event.Event {
 
 // ToStoreEventWithClock allows deterministic tests by injecting a clock.
 func ToStoreEventWithClock(e Event, clk Clock) *event.Event {
-	dedupeKey := SHA256Hex(e.RawLine)
+	dedupeKey := DedupeKey(e.Account, e.RawLine)
+	inst := instance.Parse(e.InstanceID)
+	var meta json.RawMessage
+	if len(e.Data) > 0 {
+		// A map[string]string always marshals
+		meta, _ = json.Marshal(e.Data)
+	}
 	return &event.Event{
-		Ts:         e.Timestamp,
-		Type:       e.Type,
-		PlayerName: stringPtrIfNotEmpty(e.PlayerName),
-		PlayerID:   stringPtrIfNotEmpty(e.PlayerID),
-		WorldID:    stringPtrIfNotEmpty(e.WorldID),
-		WorldName:  stringPtrIfNotEmpty(e.WorldName),
-		InstanceID: stringPtrIfNotEmpty(e.InstanceID),
-		DedupeKey:  dedupeKey,
-		IngestedAt: clk.Now(),
+		Ts:           e.Timestamp,
+		Type:         e.Type,
+		PlayerName:   stringPtrIfNotEmpty(e.PlayerName),
+		PlayerID:     stringPtrIfNotEmpty(e.PlayerID),
+		WorldID:      stringPtrIfNotEmpty(e.WorldID),
+		WorldName:    stringPtrIfNotEmpty(e.WorldName),
+		InstanceID:   stringPtrIfNotEmpty(e.InstanceID),
+		InstanceType: stringPtrIfNotEmpty(inst.Type),
+		Region:       stringPtrIfNotEmpty(inst.Region),
+		GroupID:      stringPtrIfNotEmpty(inst.GroupID),
+		MetaJSON:     meta,
+		Account:      stringPtrIfNotEmpty(e.Account),
+		DedupeKey:    dedupeKey,
+		IngestedAt:   clk.Now(),
+	}
+}
+
+// DedupeKey returns the dedupe key for a raw log line. Lines from a named
+// account are keyed separately, so two accounts logging the same line are
+// both kept; the default account keeps the plain line hash.
+func DedupeKey(account, rawLine string) string {
+	if account == "" {
+		return SHA256Hex(rawLine)
 	}
+	return SHA256Hex(account + "\x00" + rawLine)
 }
 
 // SHA256Hex returns the SHA256 hash of the input string as a hex string.
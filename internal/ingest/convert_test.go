@@ -147,6 +147,54 @@ func TestToStoreEvent_WorldJoin(t *testing.T) {
 	if storeEvent.InstanceID == nil || *storeEvent.InstanceID != "12345~region(us)" {
 		t.Errorf("InstanceID = %v, want 12345~region(us)", storeEvent.InstanceID)
 	}
+
+	// Instance attributes are parsed from InstanceID
+	if storeEvent.InstanceType == nil || *storeEvent.InstanceType != "public" {
+		t.Errorf("InstanceType = %v, want public", storeEvent.InstanceType)
+	}
+	if storeEvent.Region == nil || *storeEvent.Region != "us" {
+		t.Errorf("Region = %v, want us", storeEvent.Region)
+	}
+}
+
+func TestToStoreEvent_GroupInstance(t *testing.T) {
+	ev := Event{
+		Type:       "world_join",
+		Timestamp:  time.Now(),
+		WorldID:    "wrld_xxx",
+		InstanceID: "12345~group(grp_abc)~groupAccessType(public)~region(jp)",
+		RawLine:    "raw",
+	}
+
+	storeEvent := ToStoreEvent(ev)
+
+	if storeEvent.GroupID == nil || *storeEvent.GroupID != "grp_abc" {
+		t.Errorf("GroupID = %v, want grp_abc", storeEvent.GroupID)
+	}
+	if storeEvent.InstanceType == nil || *storeEvent.InstanceType != "group_public" {
+		t.Errorf("InstanceType = %v, want group_public", storeEvent.InstanceType)
+	}
+}
+
+func TestToStoreEvent_Account(t *testing.T) {
+	line := "2024.01.15 10:30:45 Log        -  [Behaviour] OnPlayerJoined TestUser"
+
+	def := ToStoreEvent(Event{Type: "player_join", RawLine: line})
+	if def.Account != nil {
+		t.Errorf("Account = %v, want nil for the default account", *def.Account)
+	}
+	// The default account keeps the plain line hash so existing rows still dedupe
+	if def.DedupeKey != SHA256Hex(line) {
+		t.Errorf("default DedupeKey = %s, want SHA256Hex(line)", def.DedupeKey)
+	}
+
+	alt := ToStoreEvent(Event{Type: "player_join", RawLine: line, Account: "alt"})
+	if alt.Account == nil || *alt.Account != "alt" {
+		t.Errorf("Account = %v, want alt", alt.Account)
+	}
+	if alt.DedupeKey == def.DedupeKey {
+		t.Error("expected the same line from another account to get a different DedupeKey")
+	}
 }
 
 func TestStringPtrIfNotEmpty(t *testing.T) {
@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of testdata/corpus")
+
+// corpusEnd is appended to every corpus snippet; its event marks the end.
+const corpusEnd = "2030.01.01 00:00:00 Log        -  [VRC Camera] Took screenshot to: corpus-end"
+
+// goldenEvent is the stored form of an event, as kept in the golden files.
+// Timestamps are wall-clock times, as logged, so the files do not depend on
+// the time zone the tests run in.
+type goldenEvent struct {
+	Ts           string          `json:"ts"`
+	Type         string          `json:"type"`
+	PlayerName   *string         `json:"player_name,omitempty"`
+	PlayerID     *string         `json:"player_id,omitempty"`
+	WorldID      *string         `json:"world_id,omitempty"`
+	WorldName    *string         `json:"world_name,omitempty"`
+	InstanceID   *string         `json:"instance_id,omitempty"`
+	InstanceType *string         `json:"instance_type,omitempty"`
+	Region       *string         `json:"region,omitempty"`
+	GroupID      *string         `json:"group_id,omitempty"`
+	Meta         json.RawMessage `json:"meta,omitempty"`
+	DedupeKey    string          `json:"dedupe_key"`
+}
+
+// TestLogCorpus runs each snippet in testdata/corpus through the real
+// watcher and conversion, and compares the events as they would be stored
+// with the snippet's .golden.json file. A difference after upgrading
+// vrclog-go means stored fields or dedupe keys changed; if intended, rewrite
+// the golden files with go test ./internal/ingest -run TestLogCorpus -update.
+func TestLogCorpus(t *testing.T) {
+	snippets, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.txt"))
+	if err != nil || len(snippets) == 0 {
+		t.Fatalf("no corpus snippets: %v", err)
+	}
+	for _, path := range snippets {
+		name := strings.TrimSuffix(filepath.Base(path), ".txt")
+		t.Run(name, func(t *testing.T) {
+			got := []goldenEvent{}
+			for _, e := range ingestCorpus(t, path) {
+				got = append(got, goldenEvent{
+					Ts:           e.Ts.Format("2006-01-02T15:04:05"),
+					Type:         e.Type,
+					PlayerName:   e.PlayerName,
+					PlayerID:     e.PlayerID,
+					WorldID:      e.WorldID,
+					WorldName:    e.WorldName,
+					InstanceID:   e.InstanceID,
+					InstanceType: e.InstanceType,
+					Region:       e.Region,
+					GroupID:      e.GroupID,
+					Meta:         e.MetaJSON,
+					DedupeKey:    e.DedupeKey,
+				})
+			}
+			data, err := json.MarshalIndent(got, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data = append(data, '\n')
+
+			goldenPath := strings.TrimSuffix(path, ".txt") + ".golden.json"
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(data, want) {
+				t.Errorf("events differ from %s:\n%s", goldenPath, data)
+			}
+		})
+	}
+}
+
+// ingestCorpus tails a copy of the snippet at path with VRClogSource, as
+// the app would, and returns its events converted for the store.
+func ingestCorpus(t *testing.T, path string) []*event.Event {
+	t.Helper()
+	snippet, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "output_log_2030-01-01_00-00-00.txt")
+	if err := os.WriteFile(logPath, append(snippet, corpusEnd+"\n"...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	src := NewVRClogSource(time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local), WithLogDir(dir),
+		WithSourceLogger(slog.New(slog.DiscardHandler)))
+	events, _, err := src.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	clock := &testClock{t: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	var out []*event.Event
+	for ev := range events {
+		if ev.Type == event.TypeSystem {
+			continue
+		}
+		if strings.HasSuffix(ev.RawLine, "corpus-end") {
+			return out
+		}
+		out = append(out, ToStoreEventWithClock(ev, clock))
+	}
+	t.Fatalf("source stopped before the end of %s: %v", path, ctx.Err())
+	return nil
+}
@@ -0,0 +1,187 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// EnvLogDir is the environment variable vrclog-go reads the log directory
+// from when none is configured.
+const EnvLogDir = "VRCLOG_LOGDIR"
+
+// MaxDiagnosedLogFiles caps the files listed in a LogDirDiagnosis.
+const MaxDiagnosedLogFiles = 20
+
+// Where the log directory of a LogDirDiagnosis came from.
+const (
+	LogDirSourceConfig = "config" // log_path or an account's log_path
+	LogDirSourceEnv    = "env"    // EnvLogDir
+	LogDirSourceAuto   = "auto"   // VRChat's default location
+)
+
+// staleLogAge is how old the newest log file can be before the diagnosis
+// suggests VRChat is not writing to this directory.
+const staleLogAge = 24 * time.Hour
+
+// LogDirDiagnosis explains which log directory a source watches and what it
+// finds there.
+type LogDirDiagnosis struct {
+	Account    string            `json:"account"`
+	Source     string            `json:"source"`        // LogDirSource*
+	Dir        string            `json:"dir,omitempty"` // directory watched; empty if none was found
+	Candidates []LogDirCandidate `json:"candidates"`    // directories checked, in order
+	Files      []LogFileInfo     `json:"files"`         // output_log_*.txt, newest first
+	FileCount  int               `json:"file_count"`    // may exceed len(Files)
+	NewestLog  *time.Time        `json:"newest_log,omitempty"`
+	Problems   []string          `json:"problems"` // human-readable hints; empty if all looks fine
+}
+
+// LogDirCandidate is one directory checked while locating the log directory.
+type LogDirCandidate struct {
+	Path  string `json:"path"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// LogFileInfo describes one VRChat log file.
+type LogFileInfo struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Error   string    `json:"error,omitempty"` // set if the file cannot be opened
+}
+
+// DiagnoseLogDir locates the log directory the way vrclog-go does (logDir,
+// then EnvLogDir, then VRChat's default locations) and reports what it
+// finds, including permission errors.
+func DiagnoseLogDir(account, logDir string, now time.Time) LogDirDiagnosis {
+	d := LogDirDiagnosis{Account: account, Candidates: []LogDirCandidate{}, Files: []LogFileInfo{}, Problems: []string{}}
+
+	var candidates []string
+	switch {
+	case logDir != "":
+		d.Source = LogDirSourceConfig
+		candidates = []string{logDir}
+	case os.Getenv(EnvLogDir) != "":
+		d.Source = LogDirSourceEnv
+		candidates = []string{os.Getenv(EnvLogDir)}
+	default:
+		d.Source = LogDirSourceAuto
+		candidates = defaultLogDirs()
+	}
+
+	for _, dir := range candidates {
+		c := LogDirCandidate{Path: dir}
+		if err := checkLogDir(dir); err != nil {
+			c.Error = err.Error()
+		} else {
+			c.OK = true
+			if d.Dir == "" {
+				d.Dir = dir
+			}
+		}
+		d.Candidates = append(d.Candidates, c)
+	}
+
+	if d.Dir == "" {
+		switch d.Source {
+		case LogDirSourceAuto:
+			d.Problems = append(d.Problems, "VRChat log directory not found; set log_path to the folder containing output_log_*.txt")
+		case LogDirSourceEnv:
+			d.Problems = append(d.Problems, EnvLogDir+" does not point to an accessible directory")
+		default:
+			d.Problems = append(d.Problems, "log_path does not point to an accessible directory")
+		}
+		return d
+	}
+
+	d.listFiles()
+	switch {
+	case d.FileCount == 0:
+		d.Problems = append(d.Problems, "no output_log_*.txt files; start VRChat once, and check that logging is not disabled")
+	case d.NewestLog != nil && now.Sub(*d.NewestLog) > staleLogAge:
+		d.Problems = append(d.Problems, fmt.Sprintf("newest log file is %s old; VRChat may be writing logs elsewhere",
+			now.Sub(*d.NewestLog).Round(time.Hour)))
+	}
+	for _, f := range d.Files {
+		if f.Error != "" {
+			d.Problems = append(d.Problems, fmt.Sprintf("cannot read %s: %s", f.Name, f.Error))
+		}
+	}
+	return d
+}
+
+// listFiles fills in the log files of d.Dir.
+func (d *LogDirDiagnosis) listFiles() {
+	matches, err := filepath.Glob(filepath.Join(d.Dir, "output_log_*.txt"))
+	if err != nil {
+		d.Problems = append(d.Problems, fmt.Sprintf("list log files: %v", err))
+		return
+	}
+
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		f := LogFileInfo{Name: filepath.Base(path), Size: info.Size(), ModTime: info.ModTime()}
+		if file, err := os.Open(path); err != nil {
+			f.Error = err.Error()
+		} else {
+			file.Close()
+		}
+		d.Files = append(d.Files, f)
+	}
+	slices.SortFunc(d.Files, func(a, b LogFileInfo) int { return b.ModTime.Compare(a.ModTime) })
+
+	d.FileCount = len(d.Files)
+	if d.FileCount > 0 {
+		newest := d.Files[0].ModTime
+		d.NewestLog = &newest
+	}
+	if len(d.Files) > MaxDiagnosedLogFiles {
+		d.Files = d.Files[:MaxDiagnosedLogFiles]
+	}
+}
+
+// checkLogDir reports why dir cannot be watched, or nil.
+func checkLogDir(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("does not exist")
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory")
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// defaultLogDirs returns VRChat's default log directories, matching
+// vrclog-go's auto-detection. Empty outside Windows.
+func defaultLogDirs() []string {
+	localAppData := os.Getenv("LOCALAPPDATA")
+	if localAppData == "" {
+		if userProfile := os.Getenv("USERPROFILE"); userProfile != "" {
+			localAppData = filepath.Join(userProfile, "AppData", "Local")
+		}
+	}
+	if localAppData == "" {
+		return nil
+	}
+	localLow := filepath.Join(filepath.Dir(localAppData), "LocalLow")
+	return []string{
+		filepath.Join(localLow, "VRChat", "VRChat"),
+		filepath.Join(localLow, "VRChat", "vrchat"),
+	}
+}
@@ -0,0 +1,71 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiagnoseLogDir(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, name := range []string{"output_log_old.txt", "output_log_new.txt", "other.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("log"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := now.Add(-time.Duration(3-i) * time.Hour)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d := DiagnoseLogDir("", dir, now)
+	if d.Source != LogDirSourceConfig || d.Dir != dir {
+		t.Fatalf("source/dir = %q/%q, want config/%q", d.Source, d.Dir, dir)
+	}
+	if d.FileCount != 2 || d.Files[0].Name != "output_log_new.txt" {
+		t.Fatalf("files = %+v, want output_log_new.txt first of 2", d.Files)
+	}
+	if d.NewestLog == nil || !d.NewestLog.Equal(now.Add(-2*time.Hour)) {
+		t.Errorf("newest log = %v, want %v", d.NewestLog, now.Add(-2*time.Hour))
+	}
+	if len(d.Problems) != 0 {
+		t.Errorf("problems = %v, want none", d.Problems)
+	}
+
+	// A week later the newest log is stale
+	d = DiagnoseLogDir("", dir, now.Add(7*24*time.Hour))
+	if len(d.Problems) != 1 {
+		t.Errorf("problems = %v, want a stale log warning", d.Problems)
+	}
+}
+
+func TestDiagnoseLogDir_Missing(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "nope")
+
+	d := DiagnoseLogDir("alt", missing, time.Now())
+	if d.Account != "alt" || d.Dir != "" {
+		t.Fatalf("account/dir = %q/%q, want alt/empty", d.Account, d.Dir)
+	}
+	if len(d.Candidates) != 1 || d.Candidates[0].OK || d.Candidates[0].Error == "" {
+		t.Errorf("candidates = %+v, want one failed candidate", d.Candidates)
+	}
+	if len(d.Problems) != 1 {
+		t.Errorf("problems = %v, want one", d.Problems)
+	}
+}
+
+func TestDiagnoseLogDir_Env(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvLogDir, dir)
+
+	d := DiagnoseLogDir("", "", time.Now())
+	if d.Source != LogDirSourceEnv || d.Dir != dir {
+		t.Fatalf("source/dir = %q/%q, want env/%q", d.Source, d.Dir, dir)
+	}
+	if d.FileCount != 0 || len(d.Problems) != 1 {
+		t.Errorf("files = %d, problems = %v, want 0 files and a hint", d.FileCount, d.Problems)
+	}
+}
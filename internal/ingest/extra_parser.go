@@ -0,0 +1,56 @@
+package ingest
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/vrclog/vrclog-go/pkg/vrclog"
+)
+
+// logTimestampLayout is the timestamp prefix of VRChat log lines
+// ("2024.01.15 23:59:59"), in local time.
+const logTimestampLayout = "2006.01.02 15:04:05"
+
+var (
+	screenshotPattern = regexp.MustCompile(`\[VRC Camera\] Took screenshot to: (.+)$`)
+	videoPlayPattern  = regexp.MustCompile(`\[Video Playback\] Attempting to resolve URL '(.+)'`)
+)
+
+// parseExtraLine parses log lines that vrclog-go's default parser does not
+// recognize. It runs alongside vrclog.DefaultParser; unrecognized lines are
+// reported as not matched, never as errors.
+func parseExtraLine(_ context.Context, line string) (vrclog.ParseResult, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < len(logTimestampLayout) {
+		return vrclog.ParseResult{}, nil
+	}
+	ts, err := time.ParseInLocation(logTimestampLayout, line[:len(logTimestampLayout)], time.Local)
+	if err != nil {
+		return vrclog.ParseResult{}, nil
+	}
+
+	if m := screenshotPattern.FindStringSubmatch(line); m != nil {
+		return matched(vrclog.Event{
+			Type:      event.TypeScreenshot,
+			Timestamp: ts,
+			Data:      map[string]string{"path": strings.TrimSpace(m[1])},
+		}), nil
+	}
+
+	if m := videoPlayPattern.FindStringSubmatch(line); m != nil {
+		return matched(vrclog.Event{
+			Type:      event.TypeVideoPlay,
+			Timestamp: ts,
+			Data:      map[string]string{"url": m[1]},
+		}), nil
+	}
+
+	return vrclog.ParseResult{}, nil
+}
+
+func matched(ev vrclog.Event) vrclog.ParseResult {
+	return vrclog.ParseResult{Events: []vrclog.Event{ev}, Matched: true}
+}
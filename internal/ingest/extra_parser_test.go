@@ -0,0 +1,84 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestParseExtraLine_Screenshot(t *testing.T) {
+	line := `2024.01.15 23:59:59 Log        -  [VRC Camera] Took screenshot to: C:\Users\me\Pictures\VRChat\2024-01\VRChat_2024-01-15_23-59-59.123_1920x1080.png`
+
+	result, err := parseExtraLine(context.Background(), line)
+	if err != nil {
+		t.Fatalf("parseExtraLine: %v", err)
+	}
+	if !result.Matched || len(result.Events) != 1 {
+		t.Fatalf("expected 1 matched event, got %+v", result)
+	}
+
+	ev := result.Events[0]
+	if string(ev.Type) != event.TypeScreenshot {
+		t.Errorf("Type = %q, want %q", ev.Type, event.TypeScreenshot)
+	}
+	wantTs := time.Date(2024, 1, 15, 23, 59, 59, 0, time.Local)
+	if !ev.Timestamp.Equal(wantTs) {
+		t.Errorf("Timestamp = %v, want %v", ev.Timestamp, wantTs)
+	}
+	wantPath := `C:\Users\me\Pictures\VRChat\2024-01\VRChat_2024-01-15_23-59-59.123_1920x1080.png`
+	if ev.Data["path"] != wantPath {
+		t.Errorf("path = %q, want %q", ev.Data["path"], wantPath)
+	}
+}
+
+func TestParseExtraLine_VideoPlay(t *testing.T) {
+	line := `2024.01.15 23:00:00 Log        -  [Video Playback] Attempting to resolve URL 'https://www.youtube.com/watch?v=abc'`
+
+	result, err := parseExtraLine(context.Background(), line)
+	if err != nil {
+		t.Fatalf("parseExtraLine: %v", err)
+	}
+	if !result.Matched || len(result.Events) != 1 {
+		t.Fatalf("expected 1 matched event, got %+v", result)
+	}
+	ev := result.Events[0]
+	if string(ev.Type) != event.TypeVideoPlay {
+		t.Errorf("Type = %q, want %q", ev.Type, event.TypeVideoPlay)
+	}
+	if got, want := ev.Data["url"], "https://www.youtube.com/watch?v=abc"; got != want {
+		t.Errorf("url = %q, want %q", got, want)
+	}
+}
+
+func TestParseExtraLine_NoMatch(t *testing.T) {
+	lines := []string{
+		"",
+		"garbage",
+		"2024.01.15 23:59:59 Log        -  [Behaviour] OnPlayerJoined Alice (usr_a)",
+		"not a timestamp [VRC Camera] Took screenshot to: x.png",
+	}
+	for _, line := range lines {
+		result, err := parseExtraLine(context.Background(), line)
+		if err != nil {
+			t.Errorf("parseExtraLine(%q) error: %v", line, err)
+		}
+		if result.Matched {
+			t.Errorf("parseExtraLine(%q) matched, want no match", line)
+		}
+	}
+}
+
+func TestToStoreEvent_DataBecomesMeta(t *testing.T) {
+	storeEvent := ToStoreEvent(Event{
+		Type:      event.TypeScreenshot,
+		Timestamp: time.Now(),
+		RawLine:   "raw",
+		Data:      map[string]string{"path": "a.png"},
+	})
+
+	if got, want := string(storeEvent.MetaJSON), `{"path":"a.png"}`; got != want {
+		t.Errorf("MetaJSON = %s, want %s", got, want)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/graaaaa/vrclog-companion/internal/event"
 )
@@ -25,6 +28,47 @@ type Ingester struct {
 	logger   *slog.Logger
 	clock    Clock
 	onInsert OnInsertFunc
+
+	// storeParseFailures reports whether parse failures are stored; nil
+	// means always
+	storeParseFailures func() bool
+
+	// pause state (protected by mu)
+	mu           sync.Mutex
+	paused       bool
+	resumeCh     chan struct{}
+	cancelSource context.CancelFunc
+	lastEventTs  time.Time
+
+	// write failure handling (see writebuffer.go)
+	isWriteFailure func(error) bool
+	onWriteState   OnWriteStateFunc
+	bufferSize     int
+	retryMin       time.Duration
+	retryMax       time.Duration
+	flushCh        chan struct{}
+
+	// buffer state (protected by wmu)
+	wmu           sync.Mutex
+	degraded      bool
+	degradedSince time.Time
+	buffer        []Event
+	dropped       int64
+	lastWriteErr  error
+
+	running atomic.Bool // see Running
+
+	// counters since New (see Counts)
+	inserted      atomic.Int64
+	duplicates    atomic.Int64
+	parseFailures atomic.Int64
+}
+
+// Counts are what an Ingester has done since it was created.
+type Counts struct {
+	EventsIngested    int64 `json:"events_ingested"`    // new events stored
+	DuplicatesSkipped int64 `json:"duplicates_skipped"` // events already in the store
+	ParseFailures     int64 `json:"parse_failures"`     // lines the parser could not read, repeats included
 }
 
 // Option configures an Ingester.
@@ -46,13 +90,24 @@ func WithOnInsert(fn OnInsertFunc) Option {
 	return func(i *Ingester) { i.onInsert = fn }
 }
 
+// WithStoreParseFailures sets a function deciding whether each parse
+// failure is stored, e.g. to stop storing them while disk space is low.
+// Failures not stored are still counted.
+func WithStoreParseFailures(fn func() bool) Option {
+	return func(i *Ingester) { i.storeParseFailures = fn }
+}
+
 // New creates a new Ingester.
 func New(source EventSource, store EventStore, opts ...Option) *Ingester {
 	i := &Ingester{
-		source: source,
-		store:  store,
-		logger: slog.Default(),
-		clock:  DefaultClock,
+		source:     source,
+		store:      store,
+		logger:     slog.Default(),
+		clock:      DefaultClock,
+		bufferSize: DefaultWriteBufferSize,
+		retryMin:   DefaultWriteRetryMin,
+		retryMax:   DefaultWriteRetryMax,
+		flushCh:    make(chan struct{}, 1),
 	}
 	for _, opt := range opts {
 		opt(i)
@@ -62,7 +117,71 @@ func New(source EventSource, store EventStore, opts ...Option) *Ingester {
 
 // Run starts the ingestion loop. Blocks until ctx is cancelled or source closes.
 // Returns ctx.Err() on context cancellation, nil on clean source shutdown.
+// While paused (see Pause), the source is stopped and restarted on Resume.
+// Events buffered after a write failure are only flushed while Run is active.
+// If Run panics it may be called again, and resumes near the last event read.
 func (i *Ingester) Run(ctx context.Context) error {
+	i.running.Store(true)
+	defer i.running.Store(false)
+
+	// When restarted after a panic, resume near the last event read, and
+	// stop the source on the way out should Run panic again
+	i.prepareReplay()
+	defer func() {
+		i.mu.Lock()
+		if i.cancelSource != nil {
+			i.cancelSource()
+			i.cancelSource = nil
+		}
+		i.mu.Unlock()
+	}()
+
+	if i.isWriteFailure != nil {
+		flushCtx, stopFlush := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		wg.Go(func() { i.flushLoop(flushCtx) })
+		defer wg.Wait()
+		defer stopFlush()
+	}
+
+	for {
+		if err := i.waitResumed(ctx); err != nil {
+			return err
+		}
+
+		srcCtx, cancel := context.WithCancel(ctx)
+		i.mu.Lock()
+		i.cancelSource = cancel
+		paused := i.paused
+		i.mu.Unlock()
+		if paused {
+			// Paused between waitResumed and here; wait again
+			cancel()
+			continue
+		}
+
+		err := i.runSource(srcCtx)
+		cancel()
+
+		i.mu.Lock()
+		i.cancelSource = nil
+		paused = i.paused
+		i.mu.Unlock()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !paused {
+			return err
+		}
+		i.logger.Info("ingestion paused")
+		i.prepareReplay()
+	}
+}
+
+// runSource starts the source and processes its output until the source
+// closes or ctx is cancelled.
+func (i *Ingester) runSource(ctx context.Context) error {
 	events, errs, err := i.source.Start(ctx)
 	if err != nil {
 		return err
@@ -113,20 +232,143 @@ func (i *Ingester) Run(ctx context.Context) error {
 	return ctx.Err()
 }
 
+// Pause stops reading from the event source until Resume is called.
+// Returns false if ingestion was already paused.
+// Safe to call from any goroutine.
+func (i *Ingester) Pause() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.paused {
+		return false
+	}
+	i.paused = true
+	i.resumeCh = make(chan struct{})
+	if i.cancelSource != nil {
+		i.cancelSource()
+	}
+	return true
+}
+
+// Resume restarts reading from the event source after Pause.
+// Returns false if ingestion was not paused.
+// Safe to call from any goroutine.
+func (i *Ingester) Resume() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !i.paused {
+		return false
+	}
+	i.paused = false
+	close(i.resumeCh)
+	i.resumeCh = nil
+	return true
+}
+
+// Paused reports whether ingestion is currently paused.
+// Safe to call from any goroutine.
+func (i *Ingester) Paused() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.paused
+}
+
+// Running reports whether Run is active, including while paused; false
+// once it has returned, e.g. because the source failed.
+// Safe to call from any goroutine.
+func (i *Ingester) Running() bool {
+	return i.running.Load()
+}
+
+// waitResumed blocks while ingestion is paused.
+// Returns ctx.Err() if ctx is cancelled while waiting.
+func (i *Ingester) waitResumed(ctx context.Context) error {
+	i.mu.Lock()
+	ch := i.resumeCh
+	i.mu.Unlock()
+	if ch == nil {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ch:
+		i.logger.Info("ingestion resumed")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// prepareReplay moves the source's replay start to just before the last
+// processed event, so a restart after pause does not re-read the whole log.
+// Sources that don't implement ReplaySinceSetter replay from their original
+// start time; duplicates are discarded by the store.
+func (i *Ingester) prepareReplay() {
+	setter, ok := i.source.(ReplaySinceSetter)
+	if !ok {
+		return
+	}
+	i.mu.Lock()
+	last := i.lastEventTs
+	i.mu.Unlock()
+	if last.IsZero() {
+		return
+	}
+	setter.SetReplaySince(CalculateReplaySince(last, DefaultReplayRollback))
+}
+
 // handleEvent processes a single event.
 func (i *Ingester) handleEvent(ctx context.Context, ev Event) {
-	storeEvent := ToStoreEventWithClock(ev, i.clock)
+	i.mu.Lock()
+	// System events are stamped with the wall clock, not log time, so they
+	// must not move the replay start past unread log lines
+	if ev.Type != event.TypeSystem && ev.Timestamp.After(i.lastEventTs) {
+		i.lastEventTs = ev.Timestamp
+	}
+	i.mu.Unlock()
 
-	_, inserted, err := i.store.InsertEvent(ctx, storeEvent)
-	if err != nil {
+	if _, err := i.insert(ctx, ev); err != nil {
 		i.logger.Error("failed to insert event",
 			"type", ev.Type,
 			"error", err,
 		)
-		return
 	}
+}
+
+// Ingest stores an event that did not come from the source, such as one
+// forwarded by a remote agent, and runs the OnInsert callback if it is new.
+// Returns false for duplicates, and for events buffered while the store is
+// not writable. Safe to call from any goroutine.
+func (i *Ingester) Ingest(ctx context.Context, ev Event) (bool, error) {
+	return i.insert(ctx, ev)
+}
+
+// insert stores ev, or buffers it while the store is not writable.
+func (i *Ingester) insert(ctx context.Context, ev Event) (bool, error) {
+	if i.bufferIfDegraded(ev) {
+		return false, nil
+	}
+	inserted, err := i.write(ctx, ev)
+	if err != nil && i.enterDegraded(ev, err) {
+		return false, nil
+	}
+	return inserted, err
+}
 
-	if inserted {
+// write converts and stores ev, calling onInsert if it was not a duplicate.
+func (i *Ingester) write(ctx context.Context, ev Event) (bool, error) {
+	storeEvent := ToStoreEventWithClock(ev, i.clock)
+
+	_, inserted, err := i.store.InsertEvent(ctx, storeEvent)
+	if err != nil {
+		return false, err
+	}
+
+	if !inserted {
+		i.duplicates.Add(1)
+	} else {
+		i.inserted.Add(1)
 		i.logger.Debug("event inserted",
 			"type", ev.Type,
 			"ts", ev.Timestamp,
@@ -138,6 +380,17 @@ func (i *Ingester) handleEvent(ctx context.Context, ev Event) {
 			i.onInsert(ctx, storeEvent)
 		}
 	}
+	return inserted, nil
+}
+
+// Counts returns the events stored, duplicates skipped and parse failures
+// since the Ingester was created. Safe to call from any goroutine.
+func (i *Ingester) Counts() Counts {
+	return Counts{
+		EventsIngested:    i.inserted.Load(),
+		DuplicatesSkipped: i.duplicates.Load(),
+		ParseFailures:     i.parseFailures.Load(),
+	}
 }
 
 // handleError processes an error from the source.
@@ -154,6 +407,10 @@ func (i *Ingester) handleError(ctx context.Context, err error) {
 
 // handleParseError saves a parse failure to the database.
 func (i *Ingester) handleParseError(ctx context.Context, parseErr *ParseError) {
+	i.parseFailures.Add(1)
+	if i.storeParseFailures != nil && !i.storeParseFailures() {
+		return
+	}
 	errMsg := ""
 	if parseErr.Err != nil {
 		errMsg = parseErr.Err.Error()
@@ -161,6 +418,11 @@ func (i *Ingester) handleParseError(ctx context.Context, parseErr *ParseError) {
 
 	inserted, err := i.store.InsertParseFailure(ctx, parseErr.Line, errMsg)
 	if err != nil {
+		if i.isWriteFailure != nil && i.isWriteFailure(err) {
+			// Already reported once by enterDegraded; parse failures are not buffered
+			i.logger.Debug("parse failure not recorded", "error", err)
+			return
+		}
 		i.logger.Error("failed to insert parse failure",
 			"error", err,
 		)
@@ -33,6 +33,9 @@ func waitCh[T any](t *testing.T, ch <-chan T, name string) T {
 type MockEventSource struct {
 	events chan Event
 	errs   chan error
+
+	// exited, if set, receives a value when a started source stops
+	exited chan struct{}
 }
 
 func NewMockEventSource() *MockEventSource {
@@ -48,6 +51,11 @@ func (m *MockEventSource) Start(ctx context.Context) (<-chan Event, <-chan error
 	errCh := make(chan error)
 
 	go func() {
+		defer func() {
+			if m.exited != nil {
+				m.exited <- struct{}{}
+			}
+		}()
 		defer close(eventCh)
 		defer close(errCh)
 
@@ -212,6 +220,9 @@ func TestIngester_HandleEvent(t *testing.T) {
 	if events[0].DedupeKey != SHA256Hex(rawLine) {
 		t.Errorf("expected dedupe key %s, got %s", SHA256Hex(rawLine), events[0].DedupeKey)
 	}
+	if !ingester.Running() {
+		t.Error("Running() = false while Run is active")
+	}
 
 	// Cancel and wait for shutdown
 	cancel()
@@ -223,6 +234,9 @@ func TestIngester_HandleEvent(t *testing.T) {
 	case <-time.After(time.Second):
 		t.Error("timeout waiting for ingester to stop")
 	}
+	if ingester.Running() {
+		t.Error("Running() = true after Run returned")
+	}
 }
 
 func TestIngester_HandleParseError(t *testing.T) {
@@ -331,6 +345,161 @@ func TestIngester_SourceClose(t *testing.T) {
 	}
 }
 
+// replaySinceSource wraps MockEventSource and records SetReplaySince calls.
+type replaySinceSource struct {
+	*MockEventSource
+	replaySince chan time.Time
+}
+
+func (s *replaySinceSource) SetReplaySince(t time.Time) {
+	s.replaySince <- t
+}
+
+func TestIngester_PauseResume(t *testing.T) {
+	source := &replaySinceSource{
+		MockEventSource: NewMockEventSource(),
+		replaySince:     make(chan time.Time, 1),
+	}
+	source.exited = make(chan struct{}, 2)
+	store := NewMockEventStore()
+
+	inserted := make(chan struct{}, 2)
+	ingester := New(source, store, WithOnInsert(func(ctx context.Context, e *event.Event) {
+		inserted <- struct{}{}
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ingester.Run(ctx)
+	}()
+
+	ts := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+	source.SendEvent(Event{Type: "player_join", Timestamp: ts, PlayerName: "A", RawLine: "line-a"})
+	waitCh(t, inserted, "first insert")
+
+	if !ingester.Pause() {
+		t.Fatal("Pause() = false, want true")
+	}
+	if ingester.Pause() {
+		t.Error("second Pause() = true, want false")
+	}
+	if !ingester.Paused() {
+		t.Error("Paused() = false after Pause")
+	}
+
+	// Replay start moves to just before the last processed event
+	got := waitCh(t, source.replaySince, "SetReplaySince")
+	if want := ts.Add(-DefaultReplayRollback); !got.Equal(want) {
+		t.Errorf("replay since = %v, want %v", got, want)
+	}
+
+	// Wait for the stopped source to exit so it can't take the next event
+	waitCh(t, source.exited, "source exit")
+	source.SendEvent(Event{Type: "player_join", Timestamp: ts.Add(time.Second), PlayerName: "B", RawLine: "line-b"})
+
+	select {
+	case <-inserted:
+		t.Fatal("event ingested while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if !ingester.Resume() {
+		t.Fatal("Resume() = false, want true")
+	}
+	if ingester.Resume() {
+		t.Error("second Resume() = true, want false")
+	}
+	waitCh(t, inserted, "insert after resume")
+
+	if n := len(store.GetInsertedEvents()); n != 2 {
+		t.Errorf("inserted %d events, want 2", n)
+	}
+
+	cancel()
+	if err := waitCh(t, done, "ingester stop"); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestIngester_RestartAfterPanic(t *testing.T) {
+	source := &replaySinceSource{
+		MockEventSource: NewMockEventSource(),
+		replaySince:     make(chan time.Time, 1),
+	}
+	source.exited = make(chan struct{}, 2)
+	store := NewMockEventStore()
+
+	inserted := make(chan string, 2)
+	ingester := New(source, store, WithOnInsert(func(ctx context.Context, e *event.Event) {
+		if e.PlayerName != nil && *e.PlayerName == "A" {
+			panic("bad event")
+		}
+		inserted <- *e.PlayerName
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	crashed := make(chan any, 1)
+	go func() {
+		defer func() { crashed <- recover() }()
+		ingester.Run(ctx)
+	}()
+
+	ts := time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC)
+	source.SendEvent(Event{Type: "player_join", Timestamp: ts, PlayerName: "A", RawLine: "line-a"})
+	if v := waitCh(t, crashed, "panic"); v != "bad event" {
+		t.Fatalf("Run panicked with %v, want bad event", v)
+	}
+	if ingester.Running() {
+		t.Error("Running() = true after Run panicked")
+	}
+
+	// Wait for the source stopped by the panic to exit
+	waitCh(t, source.exited, "source exit")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ingester.Run(ctx)
+	}()
+
+	// The restarted Run resumes near the last event read
+	got := waitCh(t, source.replaySince, "SetReplaySince")
+	if want := ts.Add(-DefaultReplayRollback); !got.Equal(want) {
+		t.Errorf("replay since = %v, want %v", got, want)
+	}
+	source.SendEvent(Event{Type: "player_join", Timestamp: ts.Add(time.Second), PlayerName: "B", RawLine: "line-b"})
+	if name := waitCh(t, inserted, "insert after restart"); name != "B" {
+		t.Errorf("inserted %q, want B", name)
+	}
+
+	cancel()
+	if err := waitCh(t, done, "ingester stop"); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestIngester_CancelWhilePaused(t *testing.T) {
+	source := NewMockEventSource()
+	store := NewMockEventStore()
+	ingester := New(source, store)
+	ingester.Pause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ingester.Run(ctx)
+	}()
+
+	cancel()
+	if err := waitCh(t, done, "ingester stop"); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got: %v", err)
+	}
+}
+
 func TestIngester_Dedupe(t *testing.T) {
 	rawLine := "2024.01.15 10:30:45 Log - [NetworkManager] OnPlayerJoined TestUser"
 
@@ -364,6 +533,61 @@ func TestIngester_Dedupe(t *testing.T) {
 	}
 }
 
+// dedupeStore is a MockEventStore that reports repeated dedupe keys as
+// duplicates.
+type dedupeStore struct {
+	*MockEventStore
+	seen map[string]bool
+}
+
+func (d *dedupeStore) InsertEvent(ctx context.Context, e *event.Event) (int64, bool, error) {
+	if d.seen[e.DedupeKey] {
+		return 0, false, nil
+	}
+	d.seen[e.DedupeKey] = true
+	return d.MockEventStore.InsertEvent(ctx, e)
+}
+
+func TestIngester_Counts(t *testing.T) {
+	store := &dedupeStore{MockEventStore: NewMockEventStore(), seen: map[string]bool{}}
+	ingester := New(NewMockEventSource(), store)
+	ctx := context.Background()
+
+	for _, line := range []string{"line 1", "line 2", "line 1"} {
+		if _, err := ingester.Ingest(ctx, Event{Type: "player_join", RawLine: line}); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+	}
+	for range 2 {
+		ingester.handleError(ctx, &ParseError{Line: "garbled", Err: errors.New("no match")})
+	}
+	ingester.handleError(ctx, errors.New("watcher stopped"))
+
+	want := Counts{EventsIngested: 2, DuplicatesSkipped: 1, ParseFailures: 2}
+	if got := ingester.Counts(); got != want {
+		t.Errorf("Counts() = %+v, want %+v", got, want)
+	}
+}
+
+func TestIngester_StoreParseFailures(t *testing.T) {
+	store := NewMockEventStore()
+	store.parseFailureCh = nil
+	lowSpace := true
+	ingester := New(NewMockEventSource(), store, WithStoreParseFailures(func() bool { return !lowSpace }))
+	ctx := context.Background()
+
+	ingester.handleError(ctx, &ParseError{Line: "while low", Err: errors.New("no match")})
+	lowSpace = false
+	ingester.handleError(ctx, &ParseError{Line: "after", Err: errors.New("no match")})
+
+	if got := store.GetInsertedErrors(); len(got) != 1 || got[0] != "after" {
+		t.Errorf("stored %v, want only the failure after space recovered", got)
+	}
+	if n := ingester.Counts().ParseFailures; n != 2 {
+		t.Errorf("ParseFailures = %d, want 2 counting the one not stored", n)
+	}
+}
+
 func TestParseError_Error(t *testing.T) {
 	// With underlying error
 	parseErr := &ParseError{
@@ -589,3 +813,38 @@ type fakeClock struct {
 func (f *fakeClock) Now() time.Time {
 	return f.t
 }
+
+func TestMultiSource(t *testing.T) {
+	a, b := NewMockEventSource(), NewMockEventSource()
+	src := NewMultiSource(a, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, errs, err := src.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	a.events <- Event{Type: "player_join", RawLine: "a"}
+	b.events <- Event{Type: "player_join", RawLine: "b", Account: "alt"}
+	b.errs <- errors.New("boom")
+
+	got := map[string]string{}
+	for range 2 {
+		ev := waitCh(t, events, "event")
+		got[ev.RawLine] = ev.Account
+	}
+	if len(got) != 2 || got["a"] != "" || got["b"] != "alt" {
+		t.Errorf("events = %v", got)
+	}
+	if err := waitCh(t, errs, "error"); err == nil || err.Error() != "boom" {
+		t.Errorf("err = %v, want boom", err)
+	}
+
+	// Both channels close once every source has stopped
+	cancel()
+	for range events {
+	}
+	for range errs {
+	}
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MultiSource merges several sources into one, for ingesting the logs of
+// more than one VRChat account. Events keep the Account set by the source
+// that produced them.
+type MultiSource struct {
+	sources []EventSource
+}
+
+// NewMultiSource creates a MultiSource over the given sources.
+func NewMultiSource(sources ...EventSource) *MultiSource {
+	return &MultiSource{sources: sources}
+}
+
+// SetReplaySince forwards t to every source that implements
+// ReplaySinceSetter. Implements ReplaySinceSetter.
+func (m *MultiSource) SetReplaySince(t time.Time) {
+	for _, src := range m.sources {
+		if rs, ok := src.(ReplaySinceSetter); ok {
+			rs.SetReplaySince(t)
+		}
+	}
+}
+
+// Start starts all sources and merges their output. If any source fails to
+// start, the ones already started are stopped and the error is returned.
+// Both channels close once every source has closed its channels.
+func (m *MultiSource) Start(ctx context.Context) (<-chan Event, <-chan error, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	type started struct {
+		events <-chan Event
+		errs   <-chan error
+	}
+	var all []started
+	for _, src := range m.sources {
+		events, errs, err := src.Start(ctx)
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		all = append(all, started{events, errs})
+	}
+
+	eventCh := make(chan Event, DefaultEventBufferSize)
+	errCh := make(chan error, DefaultErrorBufferSize)
+
+	var wg sync.WaitGroup
+	for _, s := range all {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for ev := range s.events {
+				select {
+				case eventCh <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for err := range s.errs {
+				select {
+				case errCh <- err:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(eventCh)
+		close(errCh)
+	}()
+
+	return eventCh, errCh, nil
+}
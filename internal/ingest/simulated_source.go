@@ -0,0 +1,275 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Defaults for SimulatedSource.
+const (
+	DefaultSimulatedInterval = 5 * time.Second  // mean time between players joining or leaving
+	DefaultSimulatedStay     = 10 * time.Minute // mean time spent in an instance
+)
+
+// SimulatedLocalPlayer is the display name of the simulated user.
+const SimulatedLocalPlayer = "DemoUser"
+
+// simulatedWorlds are the worlds SimulatedSource visits.
+var simulatedWorlds = []string{
+	"The Black Cat", "Midnight Rooftop", "Japan Shrine", "Movie & Chill",
+	"Udon Tag", "Ghost Club", "Just B Club", "Sakura Lounge",
+}
+
+// simulatedPlayers are the other players SimulatedSource meets.
+var simulatedPlayers = []string{
+	"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi",
+	"Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil",
+	"Trent", "Uma", "Victor", "Walter", "Xena", "Yuki", "Zoe", "Kaito",
+	"Sakura", "Ren", "Hana", "Sora",
+}
+
+// simulatedInstanceTags are instance ID suffixes for each kind of instance,
+// without the region. The friends instances are hosted by the local player.
+var simulatedInstanceTags = []string{
+	"", "~hidden(usr_0000000a-0000-4000-8000-00000000d00d)",
+	"~friends(usr_0000001d-0000-4000-8000-00000000beef)",
+	"~private(usr_0000000b-0000-4000-8000-00000000d00d)~canRequestInvite",
+	"~group(grp_00000001-0000-4000-8000-00000000d00d)~groupAccessType(public)",
+}
+
+// simulatedRegions are the regions of simulated instances.
+var simulatedRegions = []string{"jp", "us", "use", "eu"}
+
+// SimulatedSource implements EventSource with randomized but plausible
+// VRChat activity, for demos and tests without VRChat running: joining an
+// instance of one of a few worlds, the players already there showing up,
+// others joining and leaving, and now and then moving on to another
+// instance. Events are stamped with the current time and have raw lines in
+// the VRChat log format.
+type SimulatedSource struct {
+	interval        time.Duration
+	stay            time.Duration
+	account         string
+	seed            uint64 // 0 means random
+	clock           Clock
+	eventBufferSize int
+}
+
+// SimulatedOption configures SimulatedSource.
+type SimulatedOption func(*SimulatedSource)
+
+// WithSimulatedInterval sets the mean time between players joining or
+// leaving. Non-positive values are ignored.
+func WithSimulatedInterval(d time.Duration) SimulatedOption {
+	return func(s *SimulatedSource) {
+		if d > 0 {
+			s.interval = d
+		}
+	}
+}
+
+// WithSimulatedStay sets the mean time spent in an instance before moving
+// on. Non-positive values are ignored.
+func WithSimulatedStay(d time.Duration) SimulatedOption {
+	return func(s *SimulatedSource) {
+		if d > 0 {
+			s.stay = d
+		}
+	}
+}
+
+// WithSimulatedAccount tags every event with the given account name.
+func WithSimulatedAccount(name string) SimulatedOption {
+	return func(s *SimulatedSource) { s.account = name }
+}
+
+// WithSimulatedSeed makes the activity repeatable: sources with the same
+// non-zero seed produce the same events, apart from their timestamps.
+func WithSimulatedSeed(seed uint64) SimulatedOption {
+	return func(s *SimulatedSource) { s.seed = seed }
+}
+
+// WithSimulatedClock sets the clock events are stamped with.
+func WithSimulatedClock(clock Clock) SimulatedOption {
+	return func(s *SimulatedSource) { s.clock = clock }
+}
+
+// NewSimulatedSource creates a SimulatedSource.
+func NewSimulatedSource(opts ...SimulatedOption) *SimulatedSource {
+	s := &SimulatedSource{
+		interval:        DefaultSimulatedInterval,
+		stay:            DefaultSimulatedStay,
+		clock:           DefaultClock,
+		eventBufferSize: DefaultEventBufferSize,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Start begins producing events. The error channel never receives; both
+// channels close when ctx is cancelled.
+func (s *SimulatedSource) Start(ctx context.Context) (<-chan Event, <-chan error, error) {
+	seed := s.seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	sim := &simulation{
+		src: s,
+		rng: rand.New(rand.NewPCG(seed, seed)),
+		out: make(chan Event, s.eventBufferSize),
+	}
+	errCh := make(chan error)
+	go func() {
+		defer close(errCh)
+		defer close(sim.out)
+		sim.run(ctx)
+	}()
+	return sim.out, errCh, nil
+}
+
+// simulation is the state of one run of a SimulatedSource.
+type simulation struct {
+	src   *SimulatedSource
+	rng   *rand.Rand
+	out   chan Event
+	here  []int     // indexes into simulatedPlayers of the others in the instance
+	leave time.Time // when to move on to another instance
+}
+
+// run produces events until ctx is cancelled.
+func (m *simulation) run(ctx context.Context) {
+	if !m.joinInstance(ctx) {
+		return
+	}
+	timer := time.NewTimer(m.wait(m.src.interval))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		var ok bool
+		switch {
+		case !m.src.clock.Now().Before(m.leave):
+			ok = m.leaveInstance(ctx) && m.joinInstance(ctx)
+		case len(m.here) < 2 || (len(m.here) < len(simulatedPlayers)/2 && m.rng.Float64() < 0.55):
+			ok = m.playerJoins(ctx, m.stranger())
+		default:
+			i := m.rng.IntN(len(m.here))
+			p := m.here[i]
+			m.here = append(m.here[:i], m.here[i+1:]...)
+			ok = m.emitLeft(ctx, simulatedPlayers[p])
+		}
+		if !ok {
+			return
+		}
+		timer.Reset(m.wait(m.src.interval))
+	}
+}
+
+// wait returns a random duration around mean, exponentially distributed
+// and clamped to a tenth and four times the mean.
+func (m *simulation) wait(mean time.Duration) time.Duration {
+	d := time.Duration(m.rng.ExpFloat64() * float64(mean))
+	return min(max(d, mean/10), 4*mean)
+}
+
+// joinInstance joins an instance of a random world, in the order VRChat
+// logs it, then lets the players already there show up.
+func (m *simulation) joinInstance(ctx context.Context) bool {
+	w := m.rng.IntN(len(simulatedWorlds))
+	worldID := simulatedWorldID(w)
+	instanceID := fmt.Sprintf("%05d%s~region(%s)", 10000+m.rng.IntN(90000),
+		simulatedInstanceTags[m.rng.IntN(len(simulatedInstanceTags))],
+		simulatedRegions[m.rng.IntN(len(simulatedRegions))])
+
+	if !m.emit(ctx, Event{Type: event.TypeWorldJoin, WorldID: worldID, InstanceID: instanceID},
+		"Joining "+worldID+":"+instanceID) {
+		return false
+	}
+	if !m.emit(ctx, Event{Type: event.TypeWorldJoin, WorldName: simulatedWorlds[w]},
+		"Entering Room: "+simulatedWorlds[w]) {
+		return false
+	}
+	if !m.emitJoined(ctx, SimulatedLocalPlayer, simulatedPlayerID(len(simulatedPlayers))) {
+		return false
+	}
+	m.here = m.here[:0]
+	for range 2 + m.rng.IntN(6) {
+		if !m.playerJoins(ctx, m.stranger()) {
+			return false
+		}
+	}
+	m.leave = m.src.clock.Now().Add(m.wait(m.src.stay))
+	return true
+}
+
+// leaveInstance lets the others in the instance leave, as VRChat logs it
+// when the local player leaves.
+func (m *simulation) leaveInstance(ctx context.Context) bool {
+	for _, p := range m.here {
+		if !m.emitLeft(ctx, simulatedPlayers[p]) {
+			return false
+		}
+	}
+	m.here = m.here[:0]
+	return true
+}
+
+// stranger returns a random player not in the instance.
+func (m *simulation) stranger() int {
+	for {
+		p := m.rng.IntN(len(simulatedPlayers))
+		if !slices.Contains(m.here, p) {
+			return p
+		}
+	}
+}
+
+// playerJoins lets player p join the instance.
+func (m *simulation) playerJoins(ctx context.Context, p int) bool {
+	m.here = append(m.here, p)
+	return m.emitJoined(ctx, simulatedPlayers[p], simulatedPlayerID(p))
+}
+
+func (m *simulation) emitJoined(ctx context.Context, name, id string) bool {
+	return m.emit(ctx, Event{Type: event.TypePlayerJoin, PlayerName: name, PlayerID: id},
+		fmt.Sprintf("OnPlayerJoined %s (%s)", name, id))
+}
+
+func (m *simulation) emitLeft(ctx context.Context, name string) bool {
+	return m.emit(ctx, Event{Type: event.TypePlayerLeft, PlayerName: name}, "OnPlayerLeft "+name)
+}
+
+// emit stamps ev with the current time, the account and a raw line for
+// msg, and sends it. Returns false if ctx was cancelled first.
+func (m *simulation) emit(ctx context.Context, ev Event, msg string) bool {
+	ev.Timestamp = m.src.clock.Now()
+	ev.Account = m.src.account
+	ev.RawLine = ev.Timestamp.Local().Format("2006.01.02 15:04:05") + " Log        -  [Behaviour] " + msg
+	select {
+	case m.out <- ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// simulatedWorldID returns the world ID of simulatedWorlds[w].
+func simulatedWorldID(w int) string {
+	return fmt.Sprintf("wrld_%08x-0000-4000-8000-00000000d00d", w+1)
+}
+
+// simulatedPlayerID returns the user ID of simulatedPlayers[p], or of the
+// local player for p == len(simulatedPlayers).
+func simulatedPlayerID(p int) string {
+	return fmt.Sprintf("usr_%08x-0000-4000-8000-00000000beef", p+1)
+}
@@ -0,0 +1,104 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
+)
+
+// collectSimulated starts src and returns its first n events.
+func collectSimulated(t *testing.T, src *SimulatedSource, n int) []Event {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, errs, err := src.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	var got []Event
+	timeout := time.After(10 * time.Second)
+	for len(got) < n {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-timeout:
+			t.Fatalf("got %d events, want %d", len(got), n)
+		}
+	}
+
+	// Both channels close once cancelled
+	cancel()
+	for range events {
+	}
+	if _, ok := <-errs; ok {
+		t.Error("error channel received")
+	}
+	return got
+}
+
+func TestSimulatedSource(t *testing.T) {
+	src := NewSimulatedSource(WithSimulatedInterval(time.Millisecond),
+		WithSimulatedStay(30*time.Millisecond), WithSimulatedAccount("alt"), WithSimulatedSeed(1))
+	got := collectSimulated(t, src, 300)
+
+	// An instance is joined the way VRChat logs it
+	if got[0].Type != event.TypeWorldJoin || got[0].WorldID == "" || got[0].InstanceID == "" ||
+		got[1].Type != event.TypeWorldJoin || got[1].WorldName == "" ||
+		got[2].Type != event.TypePlayerJoin || got[2].PlayerName != SimulatedLocalPlayer {
+		t.Fatalf("first events = %+v", got[:3])
+	}
+
+	here := map[string]bool{}
+	instances := 0
+	for i, ev := range got {
+		if ev.Account != "alt" || ev.RawLine == "" || ev.Timestamp.IsZero() {
+			t.Fatalf("event %d = %+v, want account, raw line and time", i, ev)
+		}
+		switch ev.Type {
+		case event.TypeWorldJoin:
+			if ev.WorldID == "" {
+				continue
+			}
+			instances++
+			if len(here) > 1 {
+				t.Fatalf("event %d: joined another instance with %v still there", i, here)
+			}
+			clear(here)
+			if info := instance.Parse(ev.InstanceID); !instance.IsValidType(info.Type) || info.Region == "" {
+				t.Errorf("instance %q parses to %+v", ev.InstanceID, info)
+			}
+		case event.TypePlayerJoin:
+			if here[ev.PlayerName] {
+				t.Fatalf("event %d: %s joined twice", i, ev.PlayerName)
+			}
+			here[ev.PlayerName] = true
+		case event.TypePlayerLeft:
+			if !here[ev.PlayerName] || ev.PlayerName == SimulatedLocalPlayer {
+				t.Fatalf("event %d: %s left without being there", i, ev.PlayerName)
+			}
+			delete(here, ev.PlayerName)
+		default:
+			t.Fatalf("event %d has type %s", i, ev.Type)
+		}
+	}
+	if instances < 2 {
+		t.Errorf("joined %d instances, want to move on at least once", instances)
+	}
+}
+
+func TestSimulatedSource_Seed(t *testing.T) {
+	newSource := func() *SimulatedSource {
+		return NewSimulatedSource(WithSimulatedInterval(time.Millisecond), WithSimulatedSeed(42))
+	}
+	a := collectSimulated(t, newSource(), 30)
+	b := collectSimulated(t, newSource(), 30)
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].PlayerName != b[i].PlayerName ||
+			a[i].WorldID != b[i].WorldID || a[i].InstanceID != b[i].InstanceID {
+			t.Fatalf("event %d differs: %+v vs %+v", i, a[i], b[i])
+		}
+	}
+}
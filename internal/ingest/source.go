@@ -3,7 +3,11 @@ package ingest
 
 import (
 	"context"
+	"fmt"
+	"maps"
 	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
 )
 
 // EventSource abstracts event production for testing.
@@ -14,17 +18,43 @@ type EventSource interface {
 	Start(ctx context.Context) (<-chan Event, <-chan error, error)
 }
 
+// ReplaySinceSetter is implemented by sources that can change the replay start
+// time used by their next Start call. The Ingester uses it when resuming after
+// a pause so that only recent log lines are re-read.
+type ReplaySinceSetter interface {
+	SetReplaySince(t time.Time)
+}
+
 // Event represents a parsed VRChat log event.
 // This mirrors vrclog.Event fields needed for ingestion.
+// The JSON form is what remote agents forward (see package forward).
 type Event struct {
-	Type       string
-	Timestamp  time.Time
-	PlayerName string
-	PlayerID   string
-	WorldID    string
-	WorldName  string
-	InstanceID string
-	RawLine    string
+	Type       string            `json:"type"`
+	Timestamp  time.Time         `json:"ts"`
+	PlayerName string            `json:"player_name,omitempty"`
+	PlayerID   string            `json:"player_id,omitempty"`
+	WorldID    string            `json:"world_id,omitempty"`
+	WorldName  string            `json:"world_name,omitempty"`
+	InstanceID string            `json:"instance_id,omitempty"`
+	RawLine    string            `json:"raw_line"`
+	Data       map[string]string `json:"data,omitempty"`    // extra fields from custom parsers, stored as meta
+	Account    string            `json:"account,omitempty"` // VRChat account the log belongs to, empty for the default
+}
+
+// NewSystemEvent returns a system event of the given kind, stamped with the
+// current time. data is stored in meta alongside the kind.
+func NewSystemEvent(kind, account string, data map[string]string) Event {
+	now := time.Now()
+	meta := map[string]string{"kind": kind}
+	maps.Copy(meta, data)
+	return Event{
+		Type:      event.TypeSystem,
+		Timestamp: now,
+		// Unique per occurrence, so the store does not discard repeats as duplicates
+		RawLine: fmt.Sprintf("vrclog-companion %s %s", kind, now.Format(time.RFC3339Nano)),
+		Data:    meta,
+		Account: account,
+	}
 }
 
 // ParseError wraps a parse failure with the original line.
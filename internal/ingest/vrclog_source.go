@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"strconv"
 	"time"
 
+	"github.com/graaaaa/vrclog-companion/internal/event"
 	"github.com/vrclog/vrclog-go/pkg/vrclog"
 )
 
@@ -15,14 +17,31 @@ const (
 	DefaultErrorBufferSize = 16
 )
 
+// Defaults for restarting a watcher that stopped on its own.
+const (
+	DefaultRestartMinBackoff = time.Second
+	DefaultRestartMaxBackoff = time.Minute
+)
+
+// sourceParsers are the parsers the watcher runs on every log line, all of
+// them, combining their events.
+var sourceParsers = []vrclog.Parser{vrclog.DefaultParser{}, vrclog.ParserFunc(parseExtraLine)}
+
+// watchFunc starts a watcher replaying from replaySince. Replaced in tests.
+type watchFunc func(ctx context.Context, replaySince time.Time) (watchSession, error)
+
 // VRClogSource implements EventSource using vrclog-go.
 type VRClogSource struct {
 	replaySince     time.Time
 	logDir          string // optional override
+	account         string // tag for produced events, empty for the default account
 	waitForLogs     *bool  // optional override for wait behavior (nil = default)
 	logger          *slog.Logger
 	eventBufferSize int
 	errorBufferSize int
+	restartMin      time.Duration // 0 means DefaultRestartMinBackoff
+	restartMax      time.Duration // 0 means DefaultRestartMaxBackoff
+	watch           watchFunc     // nil means startWatcher
 }
 
 // SourceOption configures VRClogSource.
@@ -34,6 +53,12 @@ func WithLogDir(dir string) SourceOption {
 	return func(s *VRClogSource) { s.logDir = dir }
 }
 
+// WithAccount tags every event produced by the source with the given
+// account name.
+func WithAccount(name string) SourceOption {
+	return func(s *VRClogSource) { s.account = name }
+}
+
 // WithSourceLogger sets the logger for the source.
 // If logger is nil, it is ignored and the default logger is retained.
 func WithSourceLogger(logger *slog.Logger) SourceOption {
@@ -64,6 +89,15 @@ func WithErrorBufferSize(size int) SourceOption {
 	return func(s *VRClogSource) { s.errorBufferSize = size }
 }
 
+// WithRestartBackoff sets the delay before the first restart of a watcher
+// that stopped on its own, doubling up to maxDelay on repeated failures.
+func WithRestartBackoff(minDelay, maxDelay time.Duration) SourceOption {
+	return func(s *VRClogSource) {
+		s.restartMin = minDelay
+		s.restartMax = maxDelay
+	}
+}
+
 // NewVRClogSource creates a new VRClogSource.
 // replaySince specifies the time from which to replay events.
 func NewVRClogSource(replaySince time.Time, opts ...SourceOption) *VRClogSource {
@@ -86,6 +120,12 @@ func NewVRClogSource(replaySince time.Time, opts ...SourceOption) *VRClogSource
 	return s
 }
 
+// SetReplaySince changes the replay start time for the next Start call.
+// Implements ReplaySinceSetter. Must not be called concurrently with Start.
+func (s *VRClogSource) SetReplaySince(t time.Time) {
+	s.replaySince = t
+}
+
 // computeWaitForLogs determines whether to wait for log files to appear.
 // Default behavior: wait only when auto-detecting (logDir is empty).
 // When logDir is explicitly set, fail immediately to catch misconfigurations.
@@ -99,94 +139,298 @@ func (s *VRClogSource) computeWaitForLogs() bool {
 }
 
 // Start begins watching VRChat logs and returns event/error channels.
-// Both channels close when ctx is cancelled or on fatal error.
+// Both channels close when ctx is cancelled.
+//
+// If the watcher stops on its own, for example because the log file could
+// not be opened while VRChat held it locked, it is restarted with
+// exponential backoff, replaying from shortly before the last event seen.
+// Each restart is reported as a system event. Switching to a new
+// output_log file when VRChat rotates logs is handled by the watcher itself.
 func (s *VRClogSource) Start(ctx context.Context) (<-chan Event, <-chan error, error) {
 	// Defensive check: ensure logger is set
 	if s.logger == nil {
 		s.logger = slog.Default()
 	}
+	watch := s.watch
+	if watch == nil {
+		watch = s.startWatcher
+	}
+
+	w, err := watch(ctx, s.replaySince)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	// Create output channels with configurable buffer sizes.
+	// Buffered event channel reduces backpressure from DB latency.
+	eventCh := make(chan Event, s.eventBufferSize)
+	errCh := make(chan error, s.errorBufferSize)
+	go s.run(ctx, watch, w, eventCh, errCh)
+	return eventCh, errCh, nil
+}
+
+// watchSession is a running vrclog watcher.
+type watchSession struct {
+	events <-chan vrclog.Event
+	errs   <-chan error
+	files  <-chan watchedFile // log files the watcher started tailing; may be nil
+	close  func() error
+}
+
+// watchedFile is a log file a watcher started tailing.
+type watchedFile struct {
+	path     string
+	previous string // file tailed before a log rotation, if any
+}
+
+// watchState is what a source remembers across watcher restarts.
+type watchState struct {
+	lastTs   time.Time // timestamp of the newest event seen
+	lastFile string    // log file last reported as attached
+}
+
+// startWatcher starts a vrclog watcher replaying from replaySince.
+func (s *VRClogSource) startWatcher(ctx context.Context, replaySince time.Time) (watchSession, error) {
 	// Build vrclog options
 	waitForLogs := s.computeWaitForLogs()
 	var opts []vrclog.WatchOption
-	opts = append(opts, vrclog.WithReplaySinceTime(s.replaySince))
+	opts = append(opts, vrclog.WithReplaySinceTime(replaySince))
 	opts = append(opts, vrclog.WithIncludeRawLine(true))
 	opts = append(opts, vrclog.WithWaitForLogs(waitForLogs))
-	opts = append(opts, vrclog.WithLogger(s.logger))
+	files := make(chan watchedFile, 4)
+	opts = append(opts, vrclog.WithLogger(slog.New(&fileLogHandler{next: s.logger.Handler(), files: files})))
+	opts = append(opts, vrclog.WithParsers(sourceParsers...))
 	if s.logDir != "" {
 		opts = append(opts, vrclog.WithLogDir(s.logDir))
 	}
 
 	s.logger.Info("starting VRChat log watcher",
-		"replay_since", s.replaySince,
+		"account", s.account,
+		"replay_since", replaySince,
 		"wait_for_logs", waitForLogs,
 	)
 
 	watcher, err := vrclog.NewWatcherWithOptions(opts...)
 	if err != nil {
-		return nil, nil, err
+		return watchSession{}, err
 	}
 
 	vrcEvents, vrcErrs, err := watcher.Watch(ctx)
 	if err != nil {
 		_ = watcher.Close()
-		return nil, nil, err
+		return watchSession{}, err
 	}
+	return watchSession{events: vrcEvents, errs: vrcErrs, files: files, close: watcher.Close}, nil
+}
 
-	// Create output channels with configurable buffer sizes.
-	// Buffered event channel reduces backpressure from DB latency.
-	eventCh := make(chan Event, s.eventBufferSize)
-	errCh := make(chan error, s.errorBufferSize)
+// run converts and forwards the output of w, restarting the watcher
+// whenever it stops before ctx is cancelled.
+func (s *VRClogSource) run(ctx context.Context, watch watchFunc, w watchSession,
+	eventCh chan<- Event, errCh chan<- error) {
+	defer close(eventCh)
+	defer close(errCh)
 
-	// Start goroutine to convert and forward events.
-	// Uses nil-channel pattern: nil each channel when closed, exit when both are nil.
-	logger := s.logger
-	go func() {
-		defer close(eventCh)
-		defer close(errCh)
-		defer watcher.Close()
-
-		events := vrcEvents
-		errs := vrcErrs
-		var droppedErrors int64
-
-		defer func() {
-			if droppedErrors > 0 {
-				logger.Warn("errors dropped due to full buffer", "count", droppedErrors)
-			}
-		}()
+	var droppedErrors int64
+	defer func() {
+		if droppedErrors > 0 {
+			s.logger.Warn("errors dropped due to full buffer", "count", droppedErrors)
+		}
+	}()
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+			droppedErrors++
+		}
+	}
+
+	minBackoff, maxBackoff := s.restartBackoff()
+	backoff := minBackoff
+	attempt := 0
+	var state watchState
+	for {
+		started := time.Now()
+		reason := s.forward(ctx, w, eventCh, sendErr, &state)
+		_ = w.close()
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= maxBackoff {
+			// The watcher ran for a while, so this is a new failure
+			backoff = minBackoff
+			attempt = 0
+		}
 
-		for events != nil || errs != nil {
+		for {
+			attempt++
+			s.logger.Warn("VRChat log watcher stopped, restarting",
+				"account", s.account,
+				"reason", reason,
+				"attempt", attempt,
+				"backoff", backoff,
+			)
+			timer := time.NewTimer(backoff)
 			select {
 			case <-ctx.Done():
+				timer.Stop()
 				return
-			case ev, ok := <-events:
-				if !ok {
-					events = nil
-					continue
-				}
-				select {
-				case eventCh <- convertEvent(ev):
-				case <-ctx.Done():
-					return
-				}
-			case err, ok := <-errs:
-				if !ok {
-					errs = nil
-					continue
-				}
-				select {
-				case errCh <- convertError(err):
-				case <-ctx.Done():
-					return
-				default:
-					droppedErrors++
-				}
+			case <-timer.C:
 			}
+			backoff = min(backoff*2, maxBackoff)
+
+			since := s.replaySince
+			if !state.lastTs.IsZero() {
+				since = state.lastTs.Add(-DefaultReplayRollback)
+			}
+			var err error
+			if w, err = watch(ctx, since); err == nil {
+				break
+			}
+			reason = err.Error()
+			sendErr(err)
 		}
-	}()
 
-	return eventCh, errCh, nil
+		restarted := NewSystemEvent(event.SystemWatcherRestarted, s.account, map[string]string{
+			"reason":  reason,
+			"attempt": strconv.Itoa(attempt),
+		})
+		select {
+		case eventCh <- restarted:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// forward converts and forwards events and errors from w until its
+// channels close or ctx is cancelled, and returns why the watcher stopped.
+// Switching to a log file not seen before is reported as a system event.
+func (s *VRClogSource) forward(ctx context.Context, w watchSession, eventCh chan<- Event,
+	sendErr func(error), state *watchState) string {
+	// Uses nil-channel pattern: nil each channel when closed, exit when both are nil.
+	events := w.events
+	errs := w.errs
+	reason := "watcher closed"
+	for events != nil || errs != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err().Error()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			e := convertEvent(ev)
+			e.Account = s.account
+			if e.Timestamp.After(state.lastTs) {
+				state.lastTs = e.Timestamp
+			}
+			select {
+			case eventCh <- e:
+			case <-ctx.Done():
+				return ctx.Err().Error()
+			}
+		case f := <-w.files:
+			if f.path == state.lastFile {
+				continue
+			}
+			state.lastFile = f.path
+			s.logger.Info("watching VRChat log file", "account", s.account, "path", f.path)
+			data := map[string]string{"path": f.path}
+			if f.previous != "" {
+				data["previous"] = f.previous
+			}
+			select {
+			case eventCh <- NewSystemEvent(event.SystemWatcherAttached, s.account, data):
+			case <-ctx.Done():
+				return ctx.Err().Error()
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			var parseErr *vrclog.ParseError
+			if !errors.As(err, &parseErr) {
+				reason = err.Error()
+			}
+			sendErr(convertError(err))
+		}
+	}
+	return reason
+}
+
+// restartBackoff returns the initial and maximum delay between watcher
+// restarts.
+func (s *VRClogSource) restartBackoff() (time.Duration, time.Duration) {
+	minBackoff, maxBackoff := s.restartMin, s.restartMax
+	if minBackoff <= 0 {
+		minBackoff = DefaultRestartMinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = max(DefaultRestartMaxBackoff, minBackoff)
+	}
+	return minBackoff, maxBackoff
+}
+
+// fileLogHandler passes vrclog's log records on to next and reports the log
+// files the watcher starts tailing on files. vrclog-go does not expose this
+// otherwise, so it is recognized from the watcher's debug messages.
+type fileLogHandler struct {
+	next  slog.Handler
+	files chan<- watchedFile
+}
+
+// Enabled implements slog.Handler. Debug records are always wanted, since
+// they carry the file names.
+func (h *fileLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level == slog.LevelDebug || h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *fileLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var f watchedFile
+	switch r.Message {
+	case "started tailing":
+		f.path = recordAttr(r, "path")
+	case "log rotation detected":
+		f.path = recordAttr(r, "to")
+		f.previous = recordAttr(r, "from")
+	}
+	if f.path != "" {
+		select {
+		case h.files <- f:
+		default:
+		}
+	}
+
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *fileLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &fileLogHandler{next: h.next.WithAttrs(attrs), files: h.files}
+}
+
+// WithGroup implements slog.Handler.
+func (h *fileLogHandler) WithGroup(name string) slog.Handler {
+	return &fileLogHandler{next: h.next.WithGroup(name), files: h.files}
+}
+
+// recordAttr returns the string value of the attribute key of r.
+func recordAttr(r slog.Record, key string) string {
+	var v string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == key {
+			v = a.Value.String()
+			return false
+		}
+		return true
+	})
+	return v
 }
 
 // convertEvent converts a vrclog.Event to our internal Event type.
@@ -200,6 +444,7 @@ func convertEvent(ev vrclog.Event) Event {
 		WorldName:  ev.WorldName,
 		InstanceID: ev.InstanceID,
 		RawLine:    ev.RawLine,
+		Data:       ev.Data,
 	}
 }
 
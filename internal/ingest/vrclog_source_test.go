@@ -1,10 +1,18 @@
 package ingest
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/vrclog/vrclog-go/pkg/vrclog"
 )
 
 // TestNewVRClogSource_DefaultValues verifies default initialization.
@@ -178,3 +186,116 @@ func TestWaitForLogsLogic(t *testing.T) {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+// TestVRClogSource_RestartsStoppedWatcher verifies that a watcher that stops
+// on its own is restarted from just before the last event, and that the
+// restart is reported as a system event.
+func TestVRClogSource_RestartsStoppedWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	eventTs := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var sinces []time.Time
+	src := NewVRClogSource(eventTs.Add(-time.Hour),
+		WithAccount("alt"),
+		WithRestartBackoff(time.Millisecond, 10*time.Millisecond))
+	src.watch = func(ctx context.Context, since time.Time) (watchSession, error) {
+		sinces = append(sinces, since)
+		switch len(sinces) {
+		case 1:
+			// Emits one event, then fails like a locked log file
+			events := make(chan vrclog.Event, 1)
+			errs := make(chan error, 1)
+			events <- vrclog.Event{Type: vrclog.EventPlayerJoin, Timestamp: eventTs, RawLine: "join"}
+			errs <- errors.New("sharing violation")
+			close(events)
+			close(errs)
+			return watchSession{events: events, errs: errs, close: func() error { return nil }}, nil
+		case 2:
+			return watchSession{}, errors.New("still locked")
+		default:
+			// Runs until cancelled
+			events := make(chan vrclog.Event)
+			errs := make(chan error)
+			go func() {
+				<-ctx.Done()
+				close(events)
+				close(errs)
+			}()
+			return watchSession{events: events, errs: errs, close: func() error { return nil }}, nil
+		}
+	}
+
+	events, errs, err := src.Start(ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	go func() {
+		for range errs {
+		}
+	}()
+
+	first := <-events
+	if first.Type != string(vrclog.EventPlayerJoin) || first.Account != "alt" {
+		t.Fatalf("first event = %+v, want alt player_join", first)
+	}
+	restarted := <-events
+	if restarted.Type != event.TypeSystem || restarted.Account != "alt" {
+		t.Fatalf("second event = %+v, want alt system event", restarted)
+	}
+	if restarted.Data["kind"] != event.SystemWatcherRestarted ||
+		restarted.Data["reason"] != "still locked" || restarted.Data["attempt"] != "2" {
+		t.Errorf("restart meta = %v", restarted.Data)
+	}
+	if len(sinces) != 3 || !sinces[2].Equal(eventTs.Add(-DefaultReplayRollback)) {
+		t.Errorf("replay starts = %v, want third at %v", sinces, eventTs.Add(-DefaultReplayRollback))
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+// TestVRClogSource_ReportsAttachedFile verifies that the log file the watcher
+// tails is reported as a system event.
+func TestVRClogSource_ReportsAttachedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output_log_2024-01-01_00-00-00.txt")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, _, err := NewVRClogSource(time.Now(), WithLogDir(dir)).Start(ctx)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Type != event.TypeSystem || ev.Data["kind"] != event.SystemWatcherAttached || ev.Data["path"] != path {
+			t.Errorf("event = %+v, want watcher_attached for %s", ev, path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watcher_attached event")
+	}
+}
+
+// TestFileLogHandler_Rotation verifies that log rotation is recognized from
+// the watcher's log records and that records still reach the next handler.
+func TestFileLogHandler_Rotation(t *testing.T) {
+	var buf bytes.Buffer
+	files := make(chan watchedFile, 1)
+	logger := slog.New(&fileLogHandler{next: slog.NewTextHandler(&buf, nil), files: files})
+
+	logger.Debug("log rotation detected", "from", "old.txt", "to", "new.txt")
+	logger.Info("unrelated")
+
+	if f := <-files; f.path != "new.txt" || f.previous != "old.txt" {
+		t.Errorf("file = %+v, want new.txt rotated from old.txt", f)
+	}
+	if out := buf.String(); strings.Contains(out, "rotation") || !strings.Contains(out, "unrelated") {
+		t.Errorf("next handler got %q, want only the info record", out)
+	}
+}
@@ -0,0 +1,220 @@
+package ingest
+
+import (
+	"context"
+	"time"
+)
+
+// Write buffering defaults.
+const (
+	// DefaultWriteBufferSize is the maximum number of events held in memory
+	// while the store is not writable. The oldest events are dropped first.
+	DefaultWriteBufferSize = 10000
+
+	// DefaultWriteRetryMin and DefaultWriteRetryMax bound the backoff
+	// between attempts to flush the buffer.
+	DefaultWriteRetryMin = 5 * time.Second
+	DefaultWriteRetryMax = 2 * time.Minute
+)
+
+// WriteStatus describes whether events are reaching the store.
+type WriteStatus struct {
+	Degraded  bool       `json:"degraded"`
+	Since     *time.Time `json:"since,omitempty"`
+	Buffered  int        `json:"buffered"`
+	Dropped   int64      `json:"dropped"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// OnWriteStateFunc is called when the store stops accepting writes
+// (degraded true, err is the failure) and when buffered events have been
+// flushed after it recovers (degraded false, err nil).
+type OnWriteStateFunc func(degraded bool, err error)
+
+// WithWriteFailure enables buffering on store errors for which isFailure
+// returns true, such as a full disk or a read-only database. Without it,
+// every failed insert is logged and the event is lost.
+func WithWriteFailure(isFailure func(error) bool) Option {
+	return func(i *Ingester) { i.isWriteFailure = isFailure }
+}
+
+// WithWriteBuffer sets the maximum number of events buffered while the
+// store is not writable. Non-positive values keep the default.
+func WithWriteBuffer(size int) Option {
+	return func(i *Ingester) {
+		if size > 0 {
+			i.bufferSize = size
+		}
+	}
+}
+
+// WithWriteRetry sets the backoff between flush attempts.
+func WithWriteRetry(minDelay, maxDelay time.Duration) Option {
+	return func(i *Ingester) {
+		i.retryMin = minDelay
+		i.retryMax = maxDelay
+	}
+}
+
+// WithOnWriteState sets a callback for write failure and recovery.
+func WithOnWriteState(fn OnWriteStateFunc) Option {
+	return func(i *Ingester) { i.onWriteState = fn }
+}
+
+// WriteStatus returns the current write state.
+// Safe to call from any goroutine.
+func (i *Ingester) WriteStatus() WriteStatus {
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+
+	st := WriteStatus{
+		Degraded: i.degraded,
+		Buffered: len(i.buffer),
+		Dropped:  i.dropped,
+	}
+	if i.degraded {
+		since := i.degradedSince
+		st.Since = &since
+	}
+	if i.lastWriteErr != nil {
+		st.LastError = i.lastWriteErr.Error()
+	}
+	return st
+}
+
+// bufferIfDegraded queues ev if earlier writes failed, so that events reach
+// the store in order once it recovers. Returns false if ev should be
+// written directly.
+func (i *Ingester) bufferIfDegraded(ev Event) bool {
+	i.wmu.Lock()
+	defer i.wmu.Unlock()
+	if !i.degraded {
+		return false
+	}
+	i.push(ev)
+	return true
+}
+
+// enterDegraded buffers ev after a failed write and, on the first failure,
+// logs once, fires the callback and wakes the flush loop.
+// Returns false if err is not a write failure.
+func (i *Ingester) enterDegraded(ev Event, err error) bool {
+	if i.isWriteFailure == nil || !i.isWriteFailure(err) {
+		return false
+	}
+
+	i.wmu.Lock()
+	first := !i.degraded
+	i.degraded = true
+	i.lastWriteErr = err
+	if first {
+		i.degradedSince = i.clock.Now()
+	}
+	i.push(ev)
+	i.wmu.Unlock()
+
+	if first {
+		i.logger.Error("database not writable, buffering events in memory",
+			"error", err,
+			"buffer_size", i.bufferSize,
+		)
+		if i.onWriteState != nil {
+			i.onWriteState(true, err)
+		}
+		select {
+		case i.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// push appends ev to the buffer, dropping the oldest event if it is full.
+// Caller must hold wmu.
+func (i *Ingester) push(ev Event) {
+	if len(i.buffer) >= i.bufferSize {
+		i.buffer[0] = Event{}
+		i.buffer = i.buffer[1:]
+		i.dropped++
+	}
+	i.buffer = append(i.buffer, ev)
+}
+
+// flushLoop waits for the store to fail, then retries with backoff until
+// the buffer has been written. Returns when ctx is cancelled.
+func (i *Ingester) flushLoop(ctx context.Context) {
+	for {
+		select {
+		case <-i.flushCh:
+		case <-ctx.Done():
+			return
+		}
+
+		delay := i.retryMin
+		for {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+			if i.flush(ctx) {
+				break
+			}
+			delay = min(delay*2, i.retryMax)
+		}
+	}
+}
+
+// flush writes buffered events in order. Returns true once the buffer is
+// empty and the ingester is back to direct writes.
+func (i *Ingester) flush(ctx context.Context) bool {
+	flushed := 0
+	for {
+		i.wmu.Lock()
+		if len(i.buffer) == 0 {
+			dropped := i.dropped
+			i.degraded = false
+			i.lastWriteErr = nil
+			i.wmu.Unlock()
+
+			i.logger.Info("database writable again, buffered events flushed",
+				"flushed", flushed,
+				"dropped", dropped,
+			)
+			if i.onWriteState != nil {
+				i.onWriteState(false, nil)
+			}
+			return true
+		}
+		ev := i.buffer[0]
+		i.buffer[0] = Event{}
+		i.buffer = i.buffer[1:]
+		i.wmu.Unlock()
+
+		_, err := i.write(ctx, ev)
+		if err != nil && i.isWriteFailure(err) {
+			i.wmu.Lock()
+			i.lastWriteErr = err
+			// Put ev back in front unless newer events filled the buffer
+			if len(i.buffer) < i.bufferSize {
+				i.buffer = append([]Event{ev}, i.buffer...)
+			} else {
+				i.dropped++
+			}
+			i.wmu.Unlock()
+			i.logger.Debug("database still not writable", "error", err)
+			return false
+		}
+		if err != nil {
+			// Not a write failure (e.g. invalid event); retrying won't help
+			i.logger.Error("failed to insert buffered event",
+				"type", ev.Type,
+				"error", err,
+			)
+			continue
+		}
+		flushed++
+	}
+}
@@ -0,0 +1,139 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+var errDiskFull = errors.New("database or disk is full")
+
+func isDiskFull(err error) bool { return errors.Is(err, errDiskFull) }
+
+func (m *MockEventStore) setInsertErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.insertEventErr = err
+}
+
+func joinEvent(n int) Event {
+	name := fmt.Sprintf("User%d", n)
+	return Event{
+		Type:       "player_join",
+		Timestamp:  time.Date(2024, 1, 15, 10, 30, n, 0, time.UTC),
+		PlayerName: name,
+		RawLine:    "OnPlayerJoined " + name,
+	}
+}
+
+func TestIngester_BuffersWhileNotWritable(t *testing.T) {
+	source := NewMockEventSource()
+	store := NewMockEventStore()
+	store.setInsertErr(errDiskFull)
+
+	states := make(chan bool, 2)
+	inserted := make(chan string, 10)
+	ingester := New(source, store,
+		WithWriteFailure(isDiskFull),
+		WithWriteRetry(10*time.Millisecond, 20*time.Millisecond),
+		WithOnWriteState(func(degraded bool, err error) {
+			if degraded && !errors.Is(err, errDiskFull) {
+				t.Errorf("expected disk full error, got %v", err)
+			}
+			states <- degraded
+		}),
+		WithOnInsert(func(ctx context.Context, e *event.Event) {
+			inserted <- *e.PlayerName
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- ingester.Run(ctx) }()
+
+	source.SendEvent(joinEvent(1))
+	if !waitCh(t, states, "degraded") {
+		t.Fatal("expected degraded state first")
+	}
+	source.SendEvent(joinEvent(2))
+	source.SendEvent(joinEvent(3))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for ingester.WriteStatus().Buffered < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 buffered events, got %+v", ingester.WriteStatus())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	st := ingester.WriteStatus()
+	if !st.Degraded || st.Since == nil || st.LastError == "" {
+		t.Errorf("unexpected status while degraded: %+v", st)
+	}
+
+	store.setInsertErr(nil)
+	if waitCh(t, states, "recovered") {
+		t.Fatal("expected recovered state")
+	}
+
+	// Buffered events are written in order and run the OnInsert callback
+	for n := 1; n <= 3; n++ {
+		want := fmt.Sprintf("User%d", n)
+		if got := waitCh(t, inserted, "insert"); got != want {
+			t.Errorf("insert %d: got %s, want %s", n, got, want)
+		}
+	}
+	if st := ingester.WriteStatus(); st.Degraded || st.Buffered != 0 || st.LastError != "" {
+		t.Errorf("unexpected status after recovery: %+v", st)
+	}
+
+	// Writes go straight to the store again
+	source.SendEvent(joinEvent(4))
+	if got := waitCh(t, inserted, "insert"); got != "User4" {
+		t.Errorf("got %s, want User4", got)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestIngester_WriteBufferDropsOldest(t *testing.T) {
+	store := NewMockEventStore()
+	store.setInsertErr(errDiskFull)
+	ingester := New(NewMockEventSource(), store,
+		WithWriteFailure(isDiskFull),
+		WithWriteBuffer(2),
+	)
+
+	for n := 1; n <= 3; n++ {
+		inserted, err := ingester.Ingest(context.Background(), joinEvent(n))
+		if err != nil || inserted {
+			t.Fatalf("Ingest(%d) = %v, %v; want buffered", n, inserted, err)
+		}
+	}
+
+	st := ingester.WriteStatus()
+	if st.Buffered != 2 || st.Dropped != 1 {
+		t.Errorf("expected 2 buffered and 1 dropped, got %+v", st)
+	}
+	if name := ingester.buffer[0].PlayerName; name != "User2" {
+		t.Errorf("expected oldest event dropped, first buffered is %s", name)
+	}
+}
+
+func TestIngester_OtherErrorsNotBuffered(t *testing.T) {
+	store := NewMockEventStore()
+	store.setInsertErr(errors.New("invalid event"))
+	ingester := New(NewMockEventSource(), store, WithWriteFailure(isDiskFull))
+
+	if _, err := ingester.Ingest(context.Background(), joinEvent(1)); err == nil {
+		t.Fatal("expected error to be returned")
+	}
+	if st := ingester.WriteStatus(); st.Degraded || st.Buffered != 0 {
+		t.Errorf("expected no buffering, got %+v", st)
+	}
+}
@@ -0,0 +1,126 @@
+// Package instance parses VRChat instance IDs into structured information.
+//
+// Instance IDs have the form "<name>~<tag>(<value>)~<tag>...", for example
+// "12345~private(usr_xxx)~canRequestInvite~region(jp)~nonce(...)".
+package instance
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Instance type constants.
+const (
+	TypeUnknown     = ""
+	TypePublic      = "public"
+	TypeFriendsPlus = "friends_plus"
+	TypeFriends     = "friends"
+	TypeInvitePlus  = "invite_plus"
+	TypeInvite      = "invite"
+	TypeGroup       = "group"
+	TypeGroupPlus   = "group_plus"
+	TypeGroupPublic = "group_public"
+)
+
+// Types returns all known instance types.
+func Types() []string {
+	return []string{
+		TypePublic, TypeFriendsPlus, TypeFriends, TypeInvitePlus,
+		TypeInvite, TypeGroup, TypeGroupPlus, TypeGroupPublic,
+	}
+}
+
+// IsValidType reports whether t is a known instance type.
+func IsValidType(t string) bool {
+	for _, known := range Types() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Info holds the parsed components of an instance ID.
+type Info struct {
+	Name    string // instance name (numeric part before the first '~')
+	Type    string // one of the Type* constants
+	Region  string // region code (us, use, eu, jp), empty if absent
+	OwnerID string // owning user (usr_xxx) for friends/invite instances
+	GroupID string // owning group (grp_xxx) for group instances
+}
+
+// Parse parses an instance ID. An empty ID yields TypeUnknown.
+func Parse(id string) Info {
+	if id == "" {
+		return Info{Type: TypeUnknown}
+	}
+
+	parts := strings.Split(id, "~")
+	info := Info{Name: parts[0], Type: TypePublic}
+
+	var canRequestInvite bool
+	var groupAccess string
+	for _, part := range parts[1:] {
+		tag, value := splitTag(part)
+		switch tag {
+		case "hidden":
+			info.Type = TypeFriendsPlus
+			info.OwnerID = value
+		case "friends":
+			info.Type = TypeFriends
+			info.OwnerID = value
+		case "private":
+			info.Type = TypeInvite
+			info.OwnerID = value
+		case "canRequestInvite":
+			canRequestInvite = true
+		case "group":
+			info.Type = TypeGroup
+			info.GroupID = value
+		case "groupAccessType":
+			groupAccess = value
+		case "region":
+			info.Region = value
+		}
+	}
+
+	switch info.Type {
+	case TypeInvite:
+		if canRequestInvite {
+			info.Type = TypeInvitePlus
+		}
+	case TypeGroup:
+		switch groupAccess {
+		case "plus":
+			info.Type = TypeGroupPlus
+		case "public":
+			info.Type = TypeGroupPublic
+		}
+	}
+
+	return info
+}
+
+// splitTag splits "tag(value)" into its tag and value.
+// A part without parentheses is returned as a tag with an empty value.
+func splitTag(part string) (tag, value string) {
+	tag, rest, ok := strings.Cut(part, "(")
+	if !ok {
+		return part, ""
+	}
+	return tag, strings.TrimSuffix(rest, ")")
+}
+
+// LaunchURL returns a vrchat:// link that opens the given instance in the
+// VRChat client. The id is left unescaped, matching the links vrchat.com
+// generates; world and instance IDs never contain '&' or '#'.
+func LaunchURL(worldID, instanceID string) string {
+	return "vrchat://launch?ref=vrchat.com&id=" + worldID + ":" + instanceID
+}
+
+// WebLaunchURL returns the vrchat.com page that launches the given instance,
+// for devices without the VRChat client.
+func WebLaunchURL(worldID, instanceID string) string {
+	q := url.Values{"worldId": {worldID}, "instanceId": {instanceID}}
+	return "https://vrchat.com/home/launch?" + q.Encode()
+}
@@ -0,0 +1,60 @@
+package instance
+
+import "testing"
+
+func TestParse_Types(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"", TypeUnknown},
+		{"12345", TypePublic},
+		{"12345~region(us)", TypePublic},
+		{"12345~hidden(usr_a)~region(jp)~nonce(x)", TypeFriendsPlus},
+		{"12345~friends(usr_a)~region(eu)", TypeFriends},
+		{"12345~private(usr_a)~canRequestInvite~region(jp)", TypeInvitePlus},
+		{"12345~private(usr_a)~region(jp)", TypeInvite},
+		{"12345~group(grp_a)~groupAccessType(members)~region(jp)", TypeGroup},
+		{"12345~group(grp_a)~groupAccessType(plus)", TypeGroupPlus},
+		{"12345~group(grp_a)~groupAccessType(public)", TypeGroupPublic},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.id).Type; got != tt.want {
+			t.Errorf("Parse(%q).Type = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestParse_Components(t *testing.T) {
+	info := Parse("98765~group(grp_abc)~groupAccessType(plus)~region(jp)~nonce(x)")
+	want := Info{Name: "98765", Type: TypeGroupPlus, Region: "jp", GroupID: "grp_abc"}
+	if info != want {
+		t.Errorf("Parse = %+v, want %+v", info, want)
+	}
+
+	info = Parse("123~hidden(usr_owner)~region(use)")
+	if info.OwnerID != "usr_owner" || info.Region != "use" {
+		t.Errorf("Parse = %+v, want owner usr_owner and region use", info)
+	}
+}
+
+func TestIsValidType(t *testing.T) {
+	if !IsValidType(TypeFriendsPlus) {
+		t.Error("IsValidType(friends_plus) = false")
+	}
+	if IsValidType("bogus") || IsValidType(TypeUnknown) {
+		t.Error("IsValidType accepted an unknown type")
+	}
+}
+
+func TestLaunchURLs(t *testing.T) {
+	id := "12345~friends(usr_a)~region(jp)"
+
+	if got, want := LaunchURL("wrld_x", id), "vrchat://launch?ref=vrchat.com&id=wrld_x:12345~friends(usr_a)~region(jp)"; got != want {
+		t.Errorf("LaunchURL = %q, want %q", got, want)
+	}
+	if got, want := WebLaunchURL("wrld_x", id), "https://vrchat.com/home/launch?instanceId=12345~friends%28usr_a%29~region%28jp%29&worldId=wrld_x"; got != want {
+		t.Errorf("WebLaunchURL = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is the number of records a Buffer keeps by default.
+const DefaultBufferSize = 1000
+
+// Entry is a log record kept by a Buffer.
+type Entry struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"` // DEBUG, INFO, WARN or ERROR
+	Message string         `json:"msg"`
+	Attrs   map[string]any `json:"attrs,omitempty"` // groups are nested maps
+
+	level slog.Level
+}
+
+// Buffer keeps the most recent log records in memory. It is the writer of
+// a slog.JSONHandler, which writes each record as one line.
+type Buffer struct {
+	mu      sync.Mutex
+	entries []Entry // ring of up to cap(entries) records
+	next    int     // index of the oldest record once the ring is full
+}
+
+// NewBuffer creates a Buffer keeping size records, or DefaultBufferSize if
+// size is not positive.
+func NewBuffer(size int) *Buffer {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Buffer{entries: make([]Entry, 0, size)}
+}
+
+// Write adds the record encoded as a JSON object in p. Lines that are not
+// records are ignored.
+func (b *Buffer) Write(p []byte) (int, error) {
+	var m map[string]any
+	if err := json.Unmarshal(p, &m); err != nil {
+		return len(p), nil
+	}
+	var e Entry
+	if t, ok := m[slog.TimeKey].(string); ok {
+		e.Time, _ = time.Parse(time.RFC3339Nano, t)
+	}
+	if lv, ok := m[slog.LevelKey].(string); ok {
+		e.level.UnmarshalText([]byte(lv))
+		e.Level = lv
+	}
+	e.Message, _ = m[slog.MessageKey].(string)
+	delete(m, slog.TimeKey)
+	delete(m, slog.LevelKey)
+	delete(m, slog.MessageKey)
+	if len(m) > 0 {
+		e.Attrs = m
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) < cap(b.entries) {
+		b.entries = append(b.entries, e)
+	} else {
+		b.entries[b.next] = e
+		b.next = (b.next + 1) % len(b.entries)
+	}
+	return len(p), nil
+}
+
+// Tail returns up to n of the most recent records at minLevel or above,
+// oldest first.
+func (b *Buffer) Tail(n int, minLevel slog.Level) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make([]Entry, 0, min(n, len(b.entries)))
+	for i := len(b.entries) - 1; i >= 0 && len(out) < n; i-- {
+		e := b.entries[(b.next+i)%len(b.entries)]
+		if e.level >= minLevel {
+			out = append(out, e)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+// Package logging sets up the app's structured logging: a text or JSON
+// slog handler writing to stderr and, optionally, to rotating files in the
+// data directory, plus an in-memory buffer of recent records for the logs
+// API.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Log formats.
+const (
+	FormatText = "text" // slog's key=value format
+	FormatJSON = "json" // one JSON object per line
+)
+
+// Config configures a Logger.
+type Config struct {
+	Format string     // FormatText or FormatJSON; empty means FormatText
+	Level  slog.Level // records below this level are discarded
+
+	// Dir is the directory of the log files. Empty logs to stderr only.
+	Dir         string
+	MaxSize     int64 // bytes; see WithMaxSize
+	RotateDaily bool  // see WithDailyRotation
+	MaxFiles    int   // see WithMaxFiles
+
+	BufferSize int // records kept for Tail; 0 means DefaultBufferSize
+}
+
+// Logger is the app's logger. Records go to stderr, the log file if there
+// is one, and the buffer of recent records.
+type Logger struct {
+	*slog.Logger
+	Buffer *Buffer
+	file   *RotatingWriter
+}
+
+// New creates a Logger writing to stderr and, if cfg.Dir is set, to a
+// rotating log file there.
+func New(stderr io.Writer, cfg Config) (*Logger, error) {
+	if err := ValidateFormat(cfg.Format); err != nil {
+		return nil, err
+	}
+
+	l := &Logger{Buffer: NewBuffer(cfg.BufferSize)}
+	out := stderr
+	if cfg.Dir != "" {
+		opts := []RotateOption{WithMaxFiles(cfg.MaxFiles)}
+		if cfg.MaxSize > 0 {
+			opts = append(opts, WithMaxSize(cfg.MaxSize))
+		}
+		if cfg.RotateDaily {
+			opts = append(opts, WithDailyRotation())
+		}
+		f, err := NewRotatingWriter(cfg.Dir, opts...)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+		out = io.MultiWriter(stderr, f)
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: cfg.Level}
+	var h slog.Handler
+	if cfg.Format == FormatJSON {
+		h = slog.NewJSONHandler(out, handlerOpts)
+	} else {
+		h = slog.NewTextHandler(out, handlerOpts)
+	}
+	l.Logger = slog.New(teeHandler{h, slog.NewJSONHandler(l.Buffer, handlerOpts)})
+	return l, nil
+}
+
+// File returns the path of the current log file, or "" if logs are not
+// written to a file.
+func (l *Logger) File() string {
+	if l.file == nil {
+		return ""
+	}
+	return l.file.Path()
+}
+
+// Close closes the log file, if any.
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// ValidateFormat checks that format is FormatText, FormatJSON or empty.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatText, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("must be %q or %q", FormatText, FormatJSON)
+}
+
+// ParseLevel parses "debug", "info", "warn" or "error", in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	switch strings.ToLower(s) {
+	case "debug":
+		level = slog.LevelDebug
+	case "info":
+		level = slog.LevelInfo
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return level, errors.New(`must be "debug", "info", "warn" or "error"`)
+	}
+	return level, nil
+}
+
+// teeHandler passes each record to every handler that is enabled for it.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_Formats(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatText, `level=WARN msg="disk almost full" free_mb=12`},
+		{FormatJSON, `"level":"WARN","msg":"disk almost full","free_mb":12}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var stderr bytes.Buffer
+			l, err := New(&stderr, Config{Format: tt.format, Level: slog.LevelInfo})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer l.Close()
+
+			l.Debug("not logged")
+			l.Warn("disk almost full", "free_mb", 12)
+			if got := stderr.String(); !strings.Contains(got, tt.want) || strings.Contains(got, "not logged") {
+				t.Errorf("stderr = %q, want %q only", got, tt.want)
+			}
+			if l.File() != "" {
+				t.Errorf("File() = %q without a directory", l.File())
+			}
+		})
+	}
+
+	if _, err := New(&bytes.Buffer{}, Config{Format: "xml"}); err == nil {
+		t.Error("New accepted an unknown format")
+	}
+}
+
+func TestNew_FileAndBuffer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	var stderr bytes.Buffer
+	l, err := New(&stderr, Config{Level: slog.LevelDebug, Dir: dir})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	l.With("component", "ingest").WithGroup("event").Info("stored", "id", 42)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("log file: %v", err)
+	}
+	if string(data) != stderr.String() || !strings.Contains(string(data), "component=ingest event.id=42") {
+		t.Errorf("log file = %q, stderr = %q", data, stderr.String())
+	}
+	if l.File() != filepath.Join(dir, FileName) {
+		t.Errorf("File() = %q", l.File())
+	}
+
+	entries := l.Buffer.Tail(10, slog.LevelDebug)
+	if len(entries) != 1 {
+		t.Fatalf("buffer has %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Level != "INFO" || e.Message != "stored" || e.Time.IsZero() {
+		t.Errorf("entry = %+v", e)
+	}
+	attrs, _ := json.Marshal(e.Attrs)
+	if string(attrs) != `{"component":"ingest","event":{"id":42}}` {
+		t.Errorf("attrs = %s", attrs)
+	}
+}
+
+func TestBuffer_Tail(t *testing.T) {
+	b := NewBuffer(5)
+	l := slog.New(slog.NewJSONHandler(b, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	for i := range 8 {
+		level := slog.LevelInfo
+		if i%2 == 1 {
+			level = slog.LevelWarn
+		}
+		l.Log(t.Context(), level, fmt.Sprintf("message %d", i))
+	}
+
+	messages := func(entries []Entry) string {
+		var s []string
+		for _, e := range entries {
+			s = append(s, e.Message)
+		}
+		return strings.Join(s, ",")
+	}
+	tests := []struct {
+		n     int
+		level slog.Level
+		want  string
+	}{
+		{10, slog.LevelDebug, "message 3,message 4,message 5,message 6,message 7"},
+		{2, slog.LevelDebug, "message 6,message 7"},
+		{10, slog.LevelWarn, "message 3,message 5,message 7"},
+		{10, slog.LevelError, ""},
+	}
+	for _, tt := range tests {
+		if got := messages(b.Tail(tt.n, tt.level)); got != tt.want {
+			t.Errorf("Tail(%d, %v) = %q, want %q", tt.n, tt.level, got, tt.want)
+		}
+	}
+	if got := NewBuffer(0).Tail(10, slog.LevelDebug); len(got) != 0 {
+		t.Errorf("empty buffer returned %d entries", len(got))
+	}
+}
+
+func TestRotatingWriter_Size(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+	w, err := NewRotatingWriter(dir, WithMaxSize(20), WithMaxFiles(2))
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	defer w.Close()
+	w.now = func() time.Time { return now }
+
+	for i := range 5 {
+		fmt.Fprintf(w, "line %d 123456789\n", i) // 17 bytes: one line per file
+		now = now.Add(time.Second)
+	}
+	// Two writes in the same second
+	now = now.Add(-time.Second)
+	fmt.Fprintf(w, "line 5 123456789\n")
+
+	files, err := w.rotatedFiles()
+	if err != nil {
+		t.Fatalf("rotatedFiles: %v", err)
+	}
+	want := []string{"vrclog-20240615-120004.log", "vrclog-20240615-120004.1.log"}
+	if strings.Join(files, " ") != strings.Join(want, " ") {
+		t.Errorf("rotated files = %v, want %v", files, want)
+	}
+	for name, content := range map[string]string{
+		FileName: "line 5 123456789\n",
+		want[0]:  "line 3 123456789\n",
+		want[1]:  "line 4 123456789\n",
+	} {
+		data, _ := os.ReadFile(filepath.Join(dir, name))
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+}
+
+func TestRotatingWriter_Daily(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotatingWriter(dir, WithDailyRotation())
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	now := time.Date(2024, 6, 15, 23, 59, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+	fmt.Fprintln(w, "before midnight")
+	now = now.Add(30 * time.Second)
+	fmt.Fprintln(w, "still the same day")
+	now = now.Add(time.Minute)
+	fmt.Fprintln(w, "next day")
+	w.Close()
+
+	files, _ := w.rotatedFiles()
+	if len(files) != 1 || files[0] != "vrclog-20240616-000030.log" {
+		t.Fatalf("rotated files = %v", files)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, files[0]))
+	if string(data) != "before midnight\nstill the same day\n" {
+		t.Errorf("rotated file = %q", data)
+	}
+
+	// A file left by an earlier run on another day is rotated on the
+	// first write
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(dir, FileName), old, old)
+	w, err = NewRotatingWriter(dir, WithDailyRotation())
+	if err != nil {
+		t.Fatalf("NewRotatingWriter: %v", err)
+	}
+	defer w.Close()
+	fmt.Fprintln(w, "today")
+	if files, _ := w.rotatedFiles(); len(files) != 2 {
+		t.Errorf("rotated files after restart = %v, want 2", files)
+	}
+
+	if _, err := w.Write([]byte("x")); err != nil {
+		t.Errorf("Write: %v", err)
+	}
+	w.Close()
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"Warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		if got, err := ParseLevel(s); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "trace", "warning"} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Errorf("ParseLevel(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log file names. The current file is FileName; rotated files are named
+// after the time they were rotated, e.g. vrclog-20240615-120000.log.
+const (
+	FileName          = "vrclog.log"
+	rotatedPrefix     = "vrclog-"
+	rotatedSuffix     = ".log"
+	rotatedTimeFormat = "20060102-150405"
+)
+
+// Rotation defaults.
+const (
+	DefaultMaxSize  = 10 << 20 // 10 MiB
+	DefaultMaxFiles = 5
+)
+
+// RotatingWriter writes to FileName in a directory and moves the file
+// aside when it grows too large or, with daily rotation, on the first write
+// of a new day. Only the newest rotated files are kept. Safe for concurrent
+// use.
+type RotatingWriter struct {
+	dir      string
+	maxSize  int64
+	daily    bool
+	maxFiles int
+	now      func() time.Time
+
+	mu        sync.Mutex
+	f         *os.File
+	size      int64
+	lastWrite time.Time
+}
+
+// RotateOption configures a RotatingWriter.
+type RotateOption func(*RotatingWriter)
+
+// WithMaxSize rotates the file before a write would take it past n bytes.
+// Defaults to DefaultMaxSize.
+func WithMaxSize(n int64) RotateOption {
+	return func(w *RotatingWriter) { w.maxSize = n }
+}
+
+// WithDailyRotation also rotates the file on the first write of each local
+// calendar day, so each file covers at most one day.
+func WithDailyRotation() RotateOption {
+	return func(w *RotatingWriter) { w.daily = true }
+}
+
+// WithMaxFiles keeps n rotated files, deleting older ones. Zero or less
+// means DefaultMaxFiles.
+func WithMaxFiles(n int) RotateOption {
+	return func(w *RotatingWriter) {
+		if n > 0 {
+			w.maxFiles = n
+		}
+	}
+}
+
+// NewRotatingWriter opens FileName in dir for appending, creating dir if
+// needed.
+func NewRotatingWriter(dir string, opts ...RotateOption) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		dir:      dir,
+		maxSize:  DefaultMaxSize,
+		maxFiles: DefaultMaxFiles,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Path returns the path of the current log file.
+func (w *RotatingWriter) Path() string {
+	return filepath.Join(w.dir, FileName)
+}
+
+// Write appends p to the log file, rotating it first if due.
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	now := w.now()
+	if w.size > 0 && (w.size+int64(len(p)) > w.maxSize || (w.daily && !sameDay(w.lastWrite, now))) {
+		if err := w.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	w.lastWrite = now
+	return n, err
+}
+
+// Close closes the log file. Later writes fail.
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// open opens the current file, picking up the size and last write time of
+// a file left by an earlier run.
+func (w *RotatingWriter) open() error {
+	f, err := os.OpenFile(w.Path(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open log file: %w", err)
+	}
+	w.f = f
+	w.size = info.Size()
+	w.lastWrite = info.ModTime()
+	return nil
+}
+
+// rotate moves the current file aside, opens a new one and deletes the
+// oldest rotated files. Must be called with mu held.
+func (w *RotatingWriter) rotate(now time.Time) error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	// Files rotated within the same second get a counter
+	name := rotatedPrefix + now.Format(rotatedTimeFormat)
+	rotated := filepath.Join(w.dir, name+rotatedSuffix)
+	for i := 1; fileExists(rotated); i++ {
+		rotated = filepath.Join(w.dir, fmt.Sprintf("%s.%d%s", name, i, rotatedSuffix))
+	}
+	if err := os.Rename(w.Path(), rotated); err != nil {
+		// Keep writing to the same file rather than losing logs
+		if openErr := w.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.prune()
+	return nil
+}
+
+// prune deletes all but the newest maxFiles rotated files.
+func (w *RotatingWriter) prune() {
+	files, err := w.rotatedFiles()
+	if err != nil || len(files) <= w.maxFiles {
+		return
+	}
+	for _, name := range files[:len(files)-w.maxFiles] {
+		os.Remove(filepath.Join(w.dir, name))
+	}
+}
+
+// rotatedFiles returns the names of the rotated files, oldest first.
+func (w *RotatingWriter) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() && strings.HasPrefix(name, rotatedPrefix) && strings.HasSuffix(name, rotatedSuffix) {
+			names = append(names, name)
+		}
+	}
+	// Names sort by time, and a counter after the time sorts it after the
+	// file without one
+	slices.SortFunc(names, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, rotatedSuffix), strings.TrimSuffix(b, rotatedSuffix))
+	})
+	return names, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	a, b = a.Local(), b.Local()
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
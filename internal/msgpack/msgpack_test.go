@@ -0,0 +1,53 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		in   any
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{false, []byte{0xc2}},
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0xcc, 0x80}},
+		{65536, []byte{0xce, 0x00, 0x01, 0x00, 0x00}},
+		{int64(1) << 40, []byte{0xcf, 0, 0, 0x01, 0, 0, 0, 0, 0}},
+		{-1, []byte{0xff}},
+		{-33, []byte{0xd0, 0xdf}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{strings.Repeat("x", 32), append([]byte{0xd9, 32}, strings.Repeat("x", 32)...)},
+		{[]int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{make([]int, 16), append([]byte{0xdc, 0x00, 0x10}, make([]byte, 16)...)},
+		// Keys sorted, field names and omitempty as in JSON
+		{struct {
+			B int    `json:"b"`
+			A string `json:"a"`
+			C string `json:"c,omitempty"`
+		}{B: 1, A: "x"}, []byte{0x82, 0xa1, 'a', 0xa1, 'x', 0xa1, 'b', 0x01}},
+		{json.RawMessage(`{"k":[null]}`), []byte{0x81, 0xa1, 'k', 0x91, 0xc0}},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.in)
+		if err != nil {
+			t.Errorf("Marshal(%v): %v", tt.in, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("Marshal(%v) = % x, want % x", tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := Marshal(func() {}); err == nil {
+		t.Error("Marshal accepted a value JSON cannot encode")
+	}
+}
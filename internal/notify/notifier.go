@@ -1,12 +1,15 @@
 package notify
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/graaaaa/vrclog-companion/internal/config"
 	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
 )
 
 // FilterConfig determines which events trigger notifications.
@@ -14,8 +17,52 @@ type FilterConfig struct {
 	NotifyOnJoin      bool
 	NotifyOnLeave     bool
 	NotifyOnWorldJoin bool
+
+	// SessionRecap posts a recap of the previous instance session on world
+	// change, independently of NotifyOnWorldJoin and Rules.
+	SessionRecap bool
+
+	// ReturnAfter announces players who join at least this long after they
+	// were last seen, independently of NotifyOnJoin. Zero disables it; it
+	// also needs WithSightings.
+	ReturnAfter time.Duration
+
+	// Milestones announces milestones, such as the 100th visit to a world,
+	// independently of Rules.
+	Milestones bool
+
+	// Rules are evaluated against the current world after the per-type flags.
+	// The first matching rule decides; no match means notify.
+	Rules []config.NotifyRule
+
+	// PlayerTags maps the tags used by rule PlayerTags conditions to player
+	// IDs or display names.
+	PlayerTags map[string][]string
+
+	// FriendTags are the PlayerTags whose players count as friends. Joins
+	// and leaves of friends get embeds of their own, and batches with a
+	// summary line. Empty disables the grouping.
+	FriendTags []string
+}
+
+// WorldProvider provides the current world for rule evaluation.
+// Implemented by derive.State.
+type WorldProvider interface {
+	CurrentWorld() *derive.WorldInfo
 }
 
+// Sightings looks up when a player was seen before.
+// Implemented by store.Store.
+type Sightings interface {
+	// SeenBefore returns the newest join or leave of player, given by ID or
+	// display name, before the given time. An empty account covers all
+	// accounts. Returns an error if the player was not seen before then.
+	SeenBefore(ctx context.Context, player, account string, before time.Time) (time.Time, error)
+}
+
+// sightingTimeout bounds the lookup of a joining player's previous sighting.
+const sightingTimeout = 5 * time.Second
+
 // NotifierStatus represents the current status of the notifier.
 type NotifierStatus struct {
 	Disabled       bool
@@ -34,8 +81,17 @@ type Notifier struct {
 	afterFunc    AfterFunc
 	batchDelay   time.Duration
 	filter       FilterConfig
+	world        WorldProvider
+	sightings    Sightings
 	logger       *slog.Logger
 	maxQueueSize int
+	limits       PayloadLimits
+	onDisabled   func(reason string)
+
+	// Events enqueued before suppressUntil are dropped (startup replay)
+	startupGrace  time.Duration
+	suppressUntil time.Time
+	now           func() time.Time
 
 	eventCh chan *derive.DerivedEvent
 	flushCh chan struct{}
@@ -45,6 +101,7 @@ type Notifier struct {
 	// internal state (protected by mu)
 	mu          sync.Mutex
 	queue       []*derive.DerivedEvent
+	mentions    []string // role IDs to mention in the next flush
 	timerHandle TimerHandle
 	status      NotifierStatus
 
@@ -69,6 +126,18 @@ func WithNotifierLogger(logger *slog.Logger) NotifierOption {
 	return func(n *Notifier) { n.logger = logger }
 }
 
+// WithWorldProvider sets the source of the current world for notify rules.
+// Without it, rules with world or instance conditions never match.
+func WithWorldProvider(p WorldProvider) NotifierOption {
+	return func(n *Notifier) { n.world = p }
+}
+
+// WithSightings sets the lookup of previous sightings used for
+// FilterConfig.ReturnAfter.
+func WithSightings(s Sightings) NotifierOption {
+	return func(n *Notifier) { n.sightings = s }
+}
+
 // WithMaxQueueSize sets the maximum queue size.
 func WithMaxQueueSize(size int) NotifierOption {
 	return func(n *Notifier) {
@@ -78,6 +147,24 @@ func WithMaxQueueSize(size int) NotifierOption {
 	}
 }
 
+// WithPayloadLimits sets the size limits for generated Discord payloads.
+func WithPayloadLimits(l PayloadLimits) NotifierOption {
+	return func(n *Notifier) { n.limits = l }
+}
+
+// WithOnDisabled sets a callback run once when a fatal send error disables
+// notifications. It is called from the notifier's goroutine.
+func WithOnDisabled(fn func(reason string)) NotifierOption {
+	return func(n *Notifier) { n.onDisabled = fn }
+}
+
+// WithStartupGrace drops events enqueued within d of creating the notifier,
+// so that events replayed from the log at startup don't trigger
+// notifications. Derived state is still updated by the caller.
+func WithStartupGrace(d time.Duration) NotifierOption {
+	return func(n *Notifier) { n.startupGrace = d }
+}
+
 // NewNotifier creates a new Notifier.
 // Call Run() to start processing events.
 func NewNotifier(sender Sender, batchDelaySec int, filter FilterConfig, opts ...NotifierOption) *Notifier {
@@ -92,23 +179,28 @@ func NewNotifier(sender Sender, batchDelaySec int, filter FilterConfig, opts ...
 		filter:       filter,
 		logger:       slog.Default(),
 		maxQueueSize: DefaultMaxQueueSize,
+		limits:       DefaultPayloadLimits(),
 		eventCh:      make(chan *derive.DerivedEvent, 64),
 		flushCh:      make(chan struct{}, 1),
 		stopCh:       make(chan struct{}),
 		doneCh:       make(chan struct{}),
 		queue:        make([]*derive.DerivedEvent, 0, 16),
+		now:          time.Now,
 	}
 	for _, opt := range opts {
 		opt(n)
 	}
+	if n.startupGrace > 0 {
+		n.suppressUntil = n.now().Add(n.startupGrace)
+	}
 	return n
 }
 
 // Run starts the notification processing loop.
 // Blocks until Stop is called or ctx is cancelled.
+// If Run panics, it may be called again to restart the loop; queued
+// events and pending batches are kept.
 func (n *Notifier) Run(ctx context.Context) {
-	defer close(n.doneCh)
-
 	for {
 		select {
 		case ev := <-n.eventCh:
@@ -120,11 +212,13 @@ func (n *Notifier) Run(ctx context.Context) {
 		case <-n.stopCh:
 			// Best-effort flush on stop
 			n.flush(ctx)
+			close(n.doneCh)
 			return
 
 		case <-ctx.Done():
 			// Best-effort flush on context cancel
 			n.flush(context.Background()) // use fresh context for final flush
+			close(n.doneCh)
 			return
 		}
 	}
@@ -147,12 +241,88 @@ func (n *Notifier) Enqueue(event *derive.DerivedEvent) {
 		return
 	}
 
-	// Apply filter
-	if !n.shouldNotify(event) {
+	// Drop replayed events during the startup grace period
+	if !n.suppressUntil.IsZero() && n.now().Before(n.suppressUntil) {
+		n.logger.Debug("notification suppressed during startup", "type", event.Type)
 		return
 	}
 
-	// Non-blocking send
+	if event.Type == derive.DerivedWorldChanged && event.Recap != nil && n.filter.SessionRecap {
+		n.send(&derive.DerivedEvent{
+			Type:  derive.DerivedSessionEnded,
+			Event: event.Event,
+			Recap: event.Recap,
+		})
+	}
+
+	if event.Type == derive.DerivedPlayerJoined && n.filter.ReturnAfter > 0 && n.sightings != nil {
+		go n.checkReturn(event)
+	}
+
+	n.dispatch(event)
+}
+
+// dispatch applies the filter and rules to an event and queues it if it is
+// to be sent.
+func (n *Notifier) dispatch(event *derive.DerivedEvent) {
+	notify, rule := n.decide(event)
+	if !notify {
+		return
+	}
+	if rule != nil && rule.MentionRoleID != "" {
+		n.mu.Lock()
+		if !contains(n.mentions, rule.MentionRoleID) {
+			n.mentions = append(n.mentions, rule.MentionRoleID)
+		}
+		n.mu.Unlock()
+	}
+
+	n.send(event)
+}
+
+// checkReturn looks up when a joining player was last seen and dispatches a
+// DerivedPlayerReturned event if it was at least ReturnAfter ago. It runs
+// in its own goroutine, so that the store lookup doesn't hold up ingestion.
+func (n *Notifier) checkReturn(joined *derive.DerivedEvent) {
+	e := joined.Event
+	if e == nil {
+		return
+	}
+	player := cmp.Or(deref(e.PlayerID), deref(e.PlayerName))
+	if player == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sightingTimeout)
+	defer cancel()
+	last, err := n.sightings.SeenBefore(ctx, player, deref(e.Account), e.Ts)
+	if err != nil {
+		// Also the case for players never seen before
+		n.logger.Debug("no previous sighting", "player", player, "error", err)
+		return
+	}
+	if e.Ts.Sub(last) < n.filter.ReturnAfter {
+		return
+	}
+	n.dispatch(&derive.DerivedEvent{
+		Type:        derive.DerivedPlayerReturned,
+		Event:       e,
+		PlayerCount: joined.PlayerCount,
+		LastSeen:    &last,
+	})
+}
+
+// SetRules replaces the notify rules. Events enqueued afterwards are
+// evaluated against the new rules. Safe to call from any goroutine.
+func (n *Notifier) SetRules(rules []config.NotifyRule) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.filter.Rules = rules
+}
+
+// send queues an event without blocking; if the channel is full, the event
+// is dropped.
+func (n *Notifier) send(event *derive.DerivedEvent) {
 	select {
 	case n.eventCh <- event:
 	default:
@@ -163,16 +333,127 @@ func (n *Notifier) Enqueue(event *derive.DerivedEvent) {
 }
 
 func (n *Notifier) shouldNotify(event *derive.DerivedEvent) bool {
+	notify, _ := n.decide(event)
+	return notify
+}
+
+// decide applies the per-type flags and the rules to an event. It returns
+// whether to notify and the rule that decided, nil if none matched.
+func (n *Notifier) decide(event *derive.DerivedEvent) (bool, *config.NotifyRule) {
+	var enabled bool
 	switch event.Type {
 	case derive.DerivedPlayerJoined:
-		return n.filter.NotifyOnJoin
+		enabled = n.filter.NotifyOnJoin
 	case derive.DerivedPlayerLeft:
-		return n.filter.NotifyOnLeave
+		enabled = n.filter.NotifyOnLeave
 	case derive.DerivedWorldChanged:
-		return n.filter.NotifyOnWorldJoin
+		enabled = n.filter.NotifyOnWorldJoin
+	case derive.DerivedPlayerReturned:
+		enabled = n.filter.ReturnAfter > 0
+	case derive.DerivedMilestone:
+		return n.filter.Milestones, nil
 	default:
+		return false, nil
+	}
+	n.mu.Lock()
+	rules := n.filter.Rules
+	n.mu.Unlock()
+	if !enabled || len(rules) == 0 {
+		return enabled, nil
+	}
+
+	var world *derive.WorldInfo
+	if n.world != nil {
+		world = n.world.CurrentWorld()
+	}
+	r := evaluateRules(rules, n.filter.PlayerTags, event, world)
+	if r == nil {
+		return true, nil
+	}
+	return r.Action == config.RuleActionAllow, r
+}
+
+// evaluateRules returns the first rule matching the event and current
+// world, or nil if no rule matches.
+func evaluateRules(rules []config.NotifyRule, tags map[string][]string, ev *derive.DerivedEvent, world *derive.WorldInfo) *config.NotifyRule {
+	eventType := ""
+	now := time.Now()
+	if ev.Event != nil {
+		eventType = ev.Event.Type
+		if !ev.Event.Ts.IsZero() {
+			now = ev.Event.Ts
+		}
+	}
+	local := now.Local()
+	minute := local.Hour()*60 + local.Minute()
+
+	for i := range rules {
+		r := &rules[i]
+		if len(r.EventTypes) > 0 && !contains(r.EventTypes, eventType) {
+			continue
+		}
+		if len(r.WorldIDs) > 0 && (world == nil || !contains(r.WorldIDs, world.WorldID)) {
+			continue
+		}
+		if len(r.InstanceTypes) > 0 {
+			if world == nil || !contains(r.InstanceTypes, instance.Parse(world.InstanceID).Type) {
+				continue
+			}
+		}
+		if len(r.PlayerTags) > 0 && !hasPlayerTag(tags, r.PlayerTags, ev) {
+			continue
+		}
+		if r.TimeWindow != "" && !inTimeWindow(r.TimeWindow, minute) {
+			continue
+		}
+		if r.MinPlayers > 0 && ev.PlayerCount < r.MinPlayers {
+			continue
+		}
+		if r.MaxPlayers > 0 && ev.PlayerCount > r.MaxPlayers {
+			continue
+		}
+		return r
+	}
+	return nil
+}
+
+// hasPlayerTag reports whether the player of a join or leave event is listed
+// under one of the given tags.
+func hasPlayerTag(tags map[string][]string, want []string, ev *derive.DerivedEvent) bool {
+	if ev.Event == nil {
+		return false
+	}
+	for _, tag := range want {
+		for _, p := range tags[tag] {
+			if (ev.Event.PlayerID != nil && *ev.Event.PlayerID == p) ||
+				(ev.Event.PlayerName != nil && *ev.Event.PlayerName == p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inTimeWindow reports whether minute (since local midnight) falls in the
+// window. Invalid windows never match.
+func inTimeWindow(window string, minute int) bool {
+	start, end, err := config.ParseTimeWindow(window)
+	if err != nil {
 		return false
 	}
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 func (n *Notifier) handleEvent(ev *derive.DerivedEvent) {
@@ -235,14 +516,19 @@ func (n *Notifier) eventKey(ev *derive.DerivedEvent) string {
 	switch ev.Type {
 	case derive.DerivedWorldChanged:
 		return "world"
-	case derive.DerivedPlayerJoined, derive.DerivedPlayerLeft:
-		// Use PlayerID if available, otherwise PlayerName
+	case derive.DerivedPlayerJoined, derive.DerivedPlayerLeft, derive.DerivedPlayerReturned:
+		// Use PlayerID if available, otherwise PlayerName. A return is
+		// announced alongside the join, so it is keyed apart.
+		prefix := "player:"
+		if ev.Type == derive.DerivedPlayerReturned {
+			prefix = "returned:"
+		}
 		if ev.Event != nil {
 			if ev.Event.PlayerID != nil && *ev.Event.PlayerID != "" {
-				return "player:" + *ev.Event.PlayerID
+				return prefix + *ev.Event.PlayerID
 			}
 			if ev.Event.PlayerName != nil {
-				return "player:" + *ev.Event.PlayerName
+				return prefix + *ev.Event.PlayerName
 			}
 		}
 		return ""
@@ -275,22 +561,36 @@ func (n *Notifier) flush(ctx context.Context) {
 			"backoff_until", n.backoffUntil,
 			"remaining", remaining,
 		)
-		// Schedule flush for when backoff ends
-		if n.timerHandle == nil {
-			n.timerHandle = n.afterFunc(remaining, n.triggerFlush)
+		// Schedule flush for when backoff ends. The batch timer that got us
+		// here has already fired, so it is replaced rather than kept;
+		// otherwise the queue would wait for a timer that never comes.
+		if n.timerHandle != nil {
+			n.timerHandle.Stop()
 		}
+		n.timerHandle = n.afterFunc(remaining, n.triggerFlush)
 		n.mu.Unlock()
 		return
 	}
 
 	// Take ownership of queue
 	events := n.queue
+	mentions := n.mentions
 	n.queue = make([]*derive.DerivedEvent, 0, 16)
+	n.mentions = nil
 	n.timerHandle = nil
 	n.mu.Unlock()
 
 	// Build and send payloads
-	payloads := BuildPayloads(events)
+	var friend func(*derive.DerivedEvent) bool
+	if len(n.filter.FriendTags) > 0 {
+		friend = func(ev *derive.DerivedEvent) bool {
+			return hasPlayerTag(n.filter.PlayerTags, n.filter.FriendTags, ev)
+		}
+	}
+	payloads := buildPayloads(events, n.limits, friend)
+	if len(payloads) > 0 && len(mentions) > 0 {
+		addMentions(&payloads[0], mentions)
+	}
 	for _, payload := range payloads {
 		result, retryAfter := n.sender.Send(ctx, payload)
 		n.handleSendResult(result, retryAfter)
@@ -322,12 +622,41 @@ func (n *Notifier) handleSendResult(result SendResult, retryAfter time.Duration)
 
 	case SendFatal:
 		// Stop trying (e.g., invalid webhook URL)
+		const reason = "fatal error (invalid webhook or authentication failed)"
 		n.mu.Lock()
+		wasDisabled := n.status.Disabled
 		n.status.Disabled = true
-		n.status.DisabledReason = "fatal error (invalid webhook or authentication failed)"
+		n.status.DisabledReason = reason
 		n.status.DisabledAt = time.Now()
 		n.mu.Unlock()
 		n.logger.Error("Discord send fatal error, notifications disabled")
+		if !wasDisabled && n.onDisabled != nil {
+			n.onDisabled(reason)
+		}
+	}
+}
+
+// Alert sends a message about the app itself (e.g. the database is not
+// writable) right away, bypassing batching, filters and rules. It does
+// nothing if notifications were disabled by a fatal error. Safe to call from
+// any goroutine; it blocks while sending.
+func (n *Notifier) Alert(ctx context.Context, title, message string) {
+	n.mu.Lock()
+	disabled := n.status.Disabled
+	n.mu.Unlock()
+	if disabled {
+		return
+	}
+
+	payload := DiscordPayload{Embeds: []DiscordEmbed{{
+		Title:       title,
+		Description: truncate(message, n.limits.normalize().descriptionLimit(title)),
+		Color:       ColorAmber,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}}}
+	result, _ := n.sender.Send(ctx, payload)
+	if result != SendOK {
+		n.logger.Warn("Discord alert not sent", "title", title)
 	}
 }
 
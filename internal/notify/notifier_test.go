@@ -2,10 +2,14 @@ package notify
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/graaaaa/vrclog-companion/internal/config"
 	"github.com/graaaaa/vrclog-companion/internal/derive"
 	"github.com/graaaaa/vrclog-companion/internal/event"
 )
@@ -274,6 +278,142 @@ func TestNotifier_FilterConfig(t *testing.T) {
 	<-done
 }
 
+// stubWorld implements WorldProvider for testing.
+type stubWorld struct {
+	world *derive.WorldInfo
+}
+
+func (s *stubWorld) CurrentWorld() *derive.WorldInfo { return s.world }
+
+func TestNotifier_Rules(t *testing.T) {
+	home := &derive.WorldInfo{WorldID: "wrld_home", InstanceID: "1~private(usr_me)"}
+	public := &derive.WorldInfo{WorldID: "wrld_club", InstanceID: "2~region(jp)"}
+
+	// Only notify about joins at home; never notify in public instances
+	rules := []config.NotifyRule{
+		{EventTypes: []string{event.TypePlayerJoin}, WorldIDs: []string{"wrld_home"}, Action: config.RuleActionAllow},
+		{EventTypes: []string{event.TypePlayerJoin}, Action: config.RuleActionDeny},
+		{InstanceTypes: []string{"public"}, Action: config.RuleActionDeny},
+	}
+
+	tests := []struct {
+		name  string
+		world *derive.WorldInfo
+		ev    *derive.DerivedEvent
+		want  bool
+	}{
+		{"join at home", home, makeJoinEvent("Alice"), true},
+		{"join elsewhere", public, makeJoinEvent("Alice"), false},
+		{"join with unknown world", nil, makeJoinEvent("Alice"), false},
+		{"leave at home", home, makeLeaveEvent("Bob"), true},
+		{"leave in public", public, makeLeaveEvent("Bob"), false},
+		{"world change to public", public, makeWorldEvent("Club"), false},
+		{"leave with unknown world", nil, makeLeaveEvent("Bob"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNotifier(NewMockSender(), 3, FilterConfig{
+				NotifyOnJoin:      true,
+				NotifyOnLeave:     true,
+				NotifyOnWorldJoin: true,
+				Rules:             rules,
+			}, WithWorldProvider(&stubWorld{world: tt.world}))
+
+			if got := n.shouldNotify(tt.ev); got != tt.want {
+				t.Errorf("shouldNotify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifier_RuleConditions(t *testing.T) {
+	at := func(hour int, ev *derive.DerivedEvent) *derive.DerivedEvent {
+		ev.Event.Ts = time.Date(2024, 1, 15, hour, 0, 0, 0, time.Local)
+		return ev
+	}
+	withCount := func(n int, ev *derive.DerivedEvent) *derive.DerivedEvent {
+		ev.PlayerCount = n
+		return ev
+	}
+
+	rules := []config.NotifyRule{
+		{PlayerTags: []string{"friends"}, Action: config.RuleActionAllow},
+		{TimeWindow: "23:00-07:00", Action: config.RuleActionDeny},
+		{MinPlayers: 20, Action: config.RuleActionDeny},
+	}
+	tags := map[string][]string{"friends": {"Alice", "usr_bob"}}
+
+	bob := makeJoinEvent("Bob")
+	bob.Event.PlayerID = ptr("usr_bob")
+
+	tests := []struct {
+		name string
+		ev   *derive.DerivedEvent
+		want bool
+	}{
+		{"tagged by name at night", at(2, makeJoinEvent("Alice")), true},
+		{"tagged by ID in a crowd", withCount(30, at(12, bob)), true},
+		{"stranger at night", at(2, makeJoinEvent("Carol")), false},
+		{"stranger at window end", at(7, makeJoinEvent("Carol")), true},
+		{"stranger in a crowd", withCount(25, at(12, makeJoinEvent("Carol"))), false},
+		{"stranger in a small group", withCount(5, at(12, makeJoinEvent("Carol"))), true},
+		{"world change at night", at(23, makeWorldEvent("Club")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := NewNotifier(NewMockSender(), 3, FilterConfig{
+				NotifyOnJoin:      true,
+				NotifyOnWorldJoin: true,
+				Rules:             rules,
+				PlayerTags:        tags,
+			})
+			if got := n.shouldNotify(tt.ev); got != tt.want {
+				t.Errorf("shouldNotify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifier_SetRules(t *testing.T) {
+	n := NewNotifier(NewMockSender(), 3, FilterConfig{NotifyOnJoin: true})
+	if !n.shouldNotify(makeJoinEvent("Alice")) {
+		t.Fatal("expected notify without rules")
+	}
+	n.SetRules([]config.NotifyRule{{Action: config.RuleActionDeny}})
+	if n.shouldNotify(makeJoinEvent("Alice")) {
+		t.Error("expected the new deny rule to suppress the event")
+	}
+}
+
+func TestNotifier_RuleMentionsRole(t *testing.T) {
+	timerFactory := &FakeTimerFactory{}
+	sender := NewMockSender()
+	n := NewNotifier(sender, 3, FilterConfig{
+		NotifyOnJoin: true,
+		Rules: []config.NotifyRule{
+			{EventTypes: []string{event.TypePlayerJoin}, Action: config.RuleActionAllow, MentionRoleID: "1234"},
+		},
+	}, WithAfterFunc(timerFactory.AfterFunc()))
+
+	n.Enqueue(makeJoinEvent("Alice"))
+	n.handleEvent(<-n.eventCh)
+	n.flush(context.Background())
+
+	calls := sender.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 send, got %d", len(calls))
+	}
+	if calls[0].Content != "<@&1234>" {
+		t.Errorf("content = %q, want role mention", calls[0].Content)
+	}
+	am := calls[0].AllowedMentions
+	if am == nil || len(am.Parse) != 0 || len(am.Roles) != 1 || am.Roles[0] != "1234" {
+		t.Errorf("allowed_mentions = %+v, want only role 1234", am)
+	}
+}
+
 func TestNotifier_BackoffOn429(t *testing.T) {
 	timerFactory := &FakeTimerFactory{}
 	sender := NewMockSender()
@@ -319,14 +459,67 @@ func TestNotifier_BackoffOn429(t *testing.T) {
 	<-done
 }
 
+func TestNotifier_FlushesWhenBackoffEnds(t *testing.T) {
+	timerFactory := &FakeTimerFactory{}
+	sender := NewMockSender()
+	sender.SetResult(SendRetryable, 200*time.Millisecond)
+
+	n := NewNotifier(sender, 3, FilterConfig{
+		NotifyOnJoin: true,
+	}, WithAfterFunc(timerFactory.AfterFunc()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+
+	n.Enqueue(makeJoinEvent("Alice"))
+	time.Sleep(50 * time.Millisecond)
+	timerFactory.FireAll()
+	waitSend(t, sender)
+	sender.SetResult(SendOK, 0)
+
+	// The batch timer of an event queued during backoff fires before the
+	// backoff ends: the flush must be rescheduled
+	n.Enqueue(makeJoinEvent("Bob"))
+	time.Sleep(50 * time.Millisecond)
+	batch := timerFactory.LastHandle()
+	batch.Fire()
+	time.Sleep(50 * time.Millisecond)
+	retry := timerFactory.LastHandle()
+	if retry == batch {
+		t.Fatal("no flush scheduled for when the backoff ends")
+	}
+	if sender.CallCount() != 1 {
+		t.Fatalf("expected 1 call during backoff, got %d", sender.CallCount())
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	retry.Fire()
+	waitSend(t, sender)
+	calls := sender.Calls()
+	if len(calls) != 2 || !strings.Contains(calls[1].Embeds[0].Description, "Bob") {
+		t.Errorf("expected Bob to be sent after the backoff, got %+v", calls)
+	}
+
+	cancel()
+	<-done
+}
+
 func TestNotifier_StopsOnFatal(t *testing.T) {
 	timerFactory := &FakeTimerFactory{}
 	sender := NewMockSender()
 	sender.SetResult(SendFatal, 0)
 
+	disabledCh := make(chan string, 1)
 	n := NewNotifier(sender, 3, FilterConfig{
 		NotifyOnJoin: true,
-	}, WithAfterFunc(timerFactory.AfterFunc()))
+	}, WithAfterFunc(timerFactory.AfterFunc()),
+		WithOnDisabled(func(reason string) { disabledCh <- reason }))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -343,6 +536,15 @@ func TestNotifier_StopsOnFatal(t *testing.T) {
 	timerFactory.FireAll()
 	waitSend(t, sender)
 
+	select {
+	case reason := <-disabledCh:
+		if reason == "" {
+			t.Error("expected OnDisabled to receive a reason")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected OnDisabled to be called")
+	}
+
 	// Check status
 	status := n.Status()
 	if !status.Disabled {
@@ -421,6 +623,34 @@ func TestNotifier_NilEvent(t *testing.T) {
 	}
 }
 
+func TestNotifier_Alert(t *testing.T) {
+	sender := NewMockSender()
+	n := NewNotifier(sender, 3, FilterConfig{})
+
+	n.Alert(context.Background(), "Database not writable", "disk full")
+
+	calls := sender.Calls()
+	if len(calls) != 1 || len(calls[0].Embeds) != 1 {
+		t.Fatalf("expected 1 payload with 1 embed, got %+v", calls)
+	}
+	embed := calls[0].Embeds[0]
+	if embed.Title != "Database not writable" || embed.Description != "disk full" {
+		t.Errorf("unexpected embed %+v", embed)
+	}
+	if embed.Color != ColorAmber {
+		t.Errorf("expected amber color, got %#x", embed.Color)
+	}
+
+	// Alerts are dropped once the webhook has been disabled
+	n.mu.Lock()
+	n.status.Disabled = true
+	n.mu.Unlock()
+	n.Alert(context.Background(), "ignored", "ignored")
+	if sender.CallCount() != 1 {
+		t.Errorf("expected no send while disabled, got %d calls", sender.CallCount())
+	}
+}
+
 func TestBackoff_Calculation(t *testing.T) {
 	cfg := DefaultBackoffConfig
 
@@ -483,3 +713,363 @@ func TestPayload_EmptyEvents(t *testing.T) {
 		t.Error("expected nil for empty slice")
 	}
 }
+
+func TestPayload_LeaveDuration(t *testing.T) {
+	leave := makeLeaveEvent("Alice")
+	sec := int64(47 * 60)
+	leave.Event.DurationSec = &sec
+
+	payloads := BuildPayloads([]*derive.DerivedEvent{leave})
+	if len(payloads) != 1 || len(payloads[0].Embeds) != 1 {
+		t.Fatalf("expected 1 payload with 1 embed, got %+v", payloads)
+	}
+	if got, want := payloads[0].Embeds[0].Description, "**Alice** left after 47 minutes"; got != want {
+		t.Errorf("description = %q, want %q", got, want)
+	}
+}
+
+// checkDiscordLimits fails the test if any payload would be rejected by Discord.
+func checkDiscordLimits(t *testing.T, payloads []DiscordPayload) {
+	t.Helper()
+	for i, p := range payloads {
+		if len(p.Embeds) > MaxEmbedsPerRequest {
+			t.Errorf("payload %d: %d embeds, limit %d", i, len(p.Embeds), MaxEmbedsPerRequest)
+		}
+		total := 0
+		for _, e := range p.Embeds {
+			if n := utf8.RuneCountInString(e.Description); n > MaxDescriptionLength {
+				t.Errorf("payload %d: description has %d chars, limit %d", i, n, MaxDescriptionLength)
+			}
+			total += utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+		}
+		if total > MaxCharsPerRequest {
+			t.Errorf("payload %d: %d chars, limit %d", i, total, MaxCharsPerRequest)
+		}
+	}
+}
+
+func TestPayload_DiscordLimits(t *testing.T) {
+	longName := strings.Repeat("あ", 60)
+	var events []*derive.DerivedEvent
+	for i := 0; i < 12; i++ {
+		events = append(events, makeWorldEvent(fmt.Sprintf("%s %d", strings.Repeat("W", 2000), i)))
+	}
+	for i := 0; i < 500; i++ {
+		events = append(events, makeJoinEvent(fmt.Sprintf("%s%d", longName, i)))
+		events = append(events, makeLeaveEvent(fmt.Sprintf("%s%d", longName, i)))
+	}
+
+	payloads := BuildPayloadsWithLimits(events, PayloadLimits{MaxNames: 1000})
+	checkDiscordLimits(t, payloads)
+
+	embeds := 0
+	for _, p := range payloads {
+		embeds += len(p.Embeds)
+	}
+	if embeds != 14 {
+		t.Errorf("expected 14 embeds in total, got %d", embeds)
+	}
+	if len(payloads) < 2 {
+		t.Errorf("expected embeds to be split across payloads, got %d", len(payloads))
+	}
+}
+
+func TestPayload_MaxNames(t *testing.T) {
+	events := []*derive.DerivedEvent{
+		makeJoinEvent("Alice"), makeJoinEvent("Bob"), makeJoinEvent("Carol"),
+		makeJoinEvent("Dave"), makeJoinEvent("Eve"),
+	}
+
+	payloads := BuildPayloadsWithLimits(events, PayloadLimits{MaxNames: 2})
+	if len(payloads) != 1 || len(payloads[0].Embeds) != 1 {
+		t.Fatalf("expected 1 payload with 1 embed, got %+v", payloads)
+	}
+	want := "**5 players** joined: Alice, Bob and 3 more…"
+	if got := payloads[0].Embeds[0].Description; got != want {
+		t.Errorf("description = %q, want %q", got, want)
+	}
+}
+
+func TestPayload_MaxEmbeds(t *testing.T) {
+	events := []*derive.DerivedEvent{
+		makeWorldEvent("A"), makeWorldEvent("B"), makeWorldEvent("C"),
+	}
+
+	payloads := BuildPayloadsWithLimits(events, PayloadLimits{MaxEmbeds: 2})
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 payloads, got %d", len(payloads))
+	}
+	if len(payloads[0].Embeds) != 2 || len(payloads[1].Embeds) != 1 {
+		t.Errorf("expected 2+1 embeds, got %d+%d", len(payloads[0].Embeds), len(payloads[1].Embeds))
+	}
+
+	// Limits above Discord's are clamped
+	payloads = BuildPayloadsWithLimits(events, PayloadLimits{MaxEmbeds: 50, MaxChars: 100000})
+	checkDiscordLimits(t, payloads)
+}
+
+func TestJoinNames(t *testing.T) {
+	names := []string{"Alice", "Bob", "Carol"}
+	tests := []struct {
+		maxNames, budget int
+		want             string
+	}{
+		{10, 100, "Alice, Bob, Carol"},
+		{2, 100, "Alice, Bob and 1 more…"},
+		{10, 18, "Alice and 2 more…"},
+		{10, 5, "3 more…"},
+	}
+	for _, tt := range tests {
+		got := joinNames(names, tt.maxNames, tt.budget)
+		if got != tt.want {
+			t.Errorf("joinNames(max=%d, budget=%d) = %q, want %q", tt.maxNames, tt.budget, got, tt.want)
+		}
+		if tt.budget >= 10 && utf8.RuneCountInString(got) > tt.budget {
+			t.Errorf("joinNames(max=%d, budget=%d) exceeds budget: %q", tt.maxNames, tt.budget, got)
+		}
+	}
+}
+
+func TestFormatPresence(t *testing.T) {
+	tests := []struct {
+		sec  int64
+		want string
+	}{
+		{30, "less than a minute"},
+		{60, "1 minute"},
+		{47 * 60, "47 minutes"},
+		{2*3600 + 5*60, "2h 5m"},
+	}
+	for _, tt := range tests {
+		if got := formatPresence(tt.sec); got != tt.want {
+			t.Errorf("formatPresence(%d) = %q, want %q", tt.sec, got, tt.want)
+		}
+	}
+}
+
+func TestNotifier_SessionRecap(t *testing.T) {
+	timerFactory := &FakeTimerFactory{}
+	sender := NewMockSender()
+
+	// World change notifications are off; the recap is sent on its own
+	n := NewNotifier(sender, 3, FilterConfig{SessionRecap: true}, WithAfterFunc(timerFactory.AfterFunc()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+
+	end := time.Date(2024, 1, 15, 13, 5, 0, 0, time.UTC)
+	wc := makeWorldEvent("Next World")
+	wc.Recap = &derive.SessionRecap{
+		World:       derive.WorldInfo{WorldName: "World A"},
+		StartedAt:   end.Add(-65 * time.Minute),
+		EndedAt:     end,
+		PeakPlayers: 2,
+		PlayersSeen: []string{"Alice", "Bob"},
+	}
+	n.Enqueue(wc)
+
+	time.Sleep(50 * time.Millisecond)
+	timerFactory.FireAll()
+	waitSend(t, sender)
+
+	calls := sender.Calls()
+	if len(calls) != 1 || len(calls[0].Embeds) != 1 {
+		t.Fatalf("expected 1 call with 1 embed, got %+v", calls)
+	}
+	embed := calls[0].Embeds[0]
+	if embed.Title != "Session Recap" {
+		t.Errorf("title = %q, want Session Recap", embed.Title)
+	}
+	want := "**World A** for 1h 5m\nPeak players: 2\nPlayers seen (2): Alice, Bob"
+	if embed.Description != want {
+		t.Errorf("description = %q, want %q", embed.Description, want)
+	}
+
+	cancel()
+	<-done
+}
+
+// stubSightings reports every player as last seen at seen, or as never
+// seen if seen is zero.
+type stubSightings struct {
+	seen time.Time
+}
+
+func (s *stubSightings) SeenBefore(ctx context.Context, player, account string, before time.Time) (time.Time, error) {
+	if s.seen.IsZero() {
+		return time.Time{}, fmt.Errorf("%s not seen", player)
+	}
+	return s.seen, nil
+}
+
+func TestNotifier_PlayerReturned(t *testing.T) {
+	filter := FilterConfig{ReturnAfter: 30 * 24 * time.Hour}
+
+	// Joins themselves are off; only a long absence is announced
+	join := makeJoinEvent("Alice")
+	for _, seen := range []time.Time{{}, join.Event.Ts.Add(-24 * time.Hour)} {
+		n := NewNotifier(NewMockSender(), 3, filter, WithSightings(&stubSightings{seen: seen}))
+		n.Enqueue(join)
+		time.Sleep(50 * time.Millisecond)
+		if got := len(n.eventCh); got != 0 {
+			t.Errorf("last seen %v: %d events queued, want none", seen, got)
+		}
+	}
+
+	sightings := &stubSightings{seen: join.Event.Ts.Add(-92 * 24 * time.Hour)}
+	n := NewNotifier(NewMockSender(), 3, filter, WithSightings(sightings))
+	n.Enqueue(join)
+	var ev *derive.DerivedEvent
+	select {
+	case ev = <-n.eventCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for the returned event")
+	}
+	if ev.Type != derive.DerivedPlayerReturned || ev.LastSeen == nil || !ev.LastSeen.Equal(sightings.seen) {
+		t.Fatalf("event = %+v, want player_returned with the previous sighting", ev)
+	}
+
+	payloads := BuildPayloads([]*derive.DerivedEvent{ev})
+	if len(payloads) != 1 || len(payloads[0].Embeds) != 1 {
+		t.Fatalf("payloads = %+v, want 1 embed", payloads)
+	}
+	embed := payloads[0].Embeds[0]
+	want := "You haven't seen **Alice** in 3 months — they just joined."
+	if embed.Title != "Welcome Back" || embed.Description != want || embed.Color != ColorPurple {
+		t.Errorf("embed = %+v, want %q", embed, want)
+	}
+}
+
+func TestNotifier_Milestones(t *testing.T) {
+	milestone := func(kind, name string, value int) *derive.DerivedEvent {
+		ev := makeJoinEvent(name)
+		ev.Type = derive.DerivedMilestone
+		ev.Milestone = &derive.MilestoneInfo{Kind: kind, Name: name, Value: value}
+		return ev
+	}
+
+	// Off by default, even with every other notification on
+	n := NewNotifier(NewMockSender(), 3, FilterConfig{NotifyOnJoin: true, NotifyOnLeave: true, NotifyOnWorldJoin: true, SessionRecap: true})
+	if ok, _ := n.decide(milestone("play_streak", "", 7)); ok {
+		t.Error("milestone announced without FilterConfig.Milestones")
+	}
+	n = NewNotifier(NewMockSender(), 3, FilterConfig{Milestones: true})
+	if ok, _ := n.decide(milestone("play_streak", "", 7)); !ok {
+		t.Error("milestone not announced with FilterConfig.Milestones")
+	}
+
+	payloads := BuildPayloads([]*derive.DerivedEvent{
+		milestone("world_visits", "The Black Cat", 100),
+		milestone("player_anniversary", "Alice", 1),
+		milestone("play_streak", "", 30),
+	})
+	if len(payloads) != 1 || len(payloads[0].Embeds) != 3 {
+		t.Fatalf("payloads = %+v, want 3 embeds", payloads)
+	}
+	want := []string{
+		"Your 100th visit to **The Black Cat**!",
+		"1 year since you first met **Alice**.",
+		"30 days in a row in VRChat!",
+	}
+	for i, embed := range payloads[0].Embeds {
+		if embed.Title != "Milestone" || embed.Description != want[i] || embed.Color != ColorGold {
+			t.Errorf("embed %d = %+v, want %q", i, embed, want[i])
+		}
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{
+		1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th",
+		13: "13th", 21: "21st", 100: "100th", 101: "101st", 111: "111th",
+	} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestPayload_FriendGroups(t *testing.T) {
+	tags := map[string][]string{"friend": {"Alice"}, "vip": {"usr_bob"}}
+	friend := func(ev *derive.DerivedEvent) bool {
+		return hasPlayerTag(tags, []string{"friend", "vip"}, ev)
+	}
+	bob := makeJoinEvent("Bob")
+	bob.Event.PlayerID = ptr("usr_bob")
+	events := []*derive.DerivedEvent{
+		makeJoinEvent("Alice"), makeJoinEvent("Carol"), bob, makeJoinEvent("Dave"),
+		makeLeaveEvent("Eve"),
+	}
+
+	payloads := buildPayloads(events, DefaultPayloadLimits(), friend)
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(payloads))
+	}
+	if want := "2 friends, 2 strangers joined"; payloads[0].Content != want {
+		t.Errorf("content = %q, want %q", payloads[0].Content, want)
+	}
+	embeds := payloads[0].Embeds
+	if len(embeds) != 3 {
+		t.Fatalf("expected 3 embeds, got %+v", embeds)
+	}
+	if embeds[0].Title != "Friend Joined" || embeds[0].Color != ColorGold ||
+		embeds[0].Description != "**2 players** joined: Alice, Bob" {
+		t.Errorf("friends embed = %+v", embeds[0])
+	}
+	if embeds[1].Title != "Player Joined" || embeds[1].Color != ColorGreen ||
+		embeds[1].Description != "**2 players** joined: Carol, Dave" {
+		t.Errorf("strangers embed = %+v", embeds[1])
+	}
+	if embeds[2].Title != "Player Left" || embeds[2].Color != ColorRed {
+		t.Errorf("leaves embed = %+v", embeds[2])
+	}
+
+	// Without friend tags, everyone shares one embed and there is no summary
+	payloads = BuildPayloads(events)
+	if payloads[0].Content != "" || len(payloads[0].Embeds) != 2 {
+		t.Errorf("ungrouped payload = %+v", payloads[0])
+	}
+}
+
+func TestFormatAbsence(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{day, "1 day"},
+		{10 * day, "10 days"},
+		{21 * day, "3 weeks"},
+		{92 * day, "3 months"},
+		{400 * day, "1 year"},
+	}
+	for _, tt := range tests {
+		if got := formatAbsence(tt.d); got != tt.want {
+			t.Errorf("formatAbsence(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestNotifier_StartupGrace(t *testing.T) {
+	filter := FilterConfig{NotifyOnJoin: true}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func(n *Notifier) { n.now = func() time.Time { return now } }
+
+	n := NewNotifier(NewMockSender(), 3, filter, WithStartupGrace(time.Minute), clock)
+	now = now.Add(time.Minute - time.Second)
+	n.Enqueue(makeJoinEvent("Alice"))
+	if got := len(n.eventCh); got != 0 {
+		t.Errorf("expected event to be suppressed during grace period, %d queued", got)
+	}
+
+	now = now.Add(time.Second)
+	n.Enqueue(makeJoinEvent("Alice"))
+	if got := len(n.eventCh); got != 1 {
+		t.Errorf("expected event to be queued after grace period, %d queued", got)
+	}
+}
@@ -4,24 +4,105 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/graaaaa/vrclog-companion/internal/derive"
 )
 
 // Discord embed color constants.
 const (
-	ColorGreen = 0x00FF00 // Player joined
-	ColorRed   = 0xFF0000 // Player left
-	ColorBlue  = 0x5865F2 // World changed (Discord blurple)
+	ColorGreen  = 0x00FF00 // Player joined
+	ColorRed    = 0xFF0000 // Player left
+	ColorBlue   = 0x5865F2 // World changed (Discord blurple)
+	ColorGray   = 0x99AAB5 // Session recap
+	ColorAmber  = 0xFAA61A // App alert
+	ColorPurple = 0x9B59B6 // Player returned after a long absence
+	ColorGold   = 0xF1C40F // Friend joined, milestone
+	ColorOrange = 0xE67E22 // Friend left
 )
 
-// MaxEmbedsPerRequest is the Discord API limit for embeds per message.
-const MaxEmbedsPerRequest = 10
+// Discord API limits. Character counts cover embed titles and descriptions.
+const (
+	// MaxEmbedsPerRequest is the Discord API limit for embeds per message.
+	MaxEmbedsPerRequest = 10
+	// MaxDescriptionLength is the Discord API limit for an embed description.
+	MaxDescriptionLength = 4096
+	// MaxCharsPerRequest is the Discord API limit for the combined length of
+	// all embeds in one message.
+	MaxCharsPerRequest = 6000
+)
+
+// DefaultMaxNames is the default number of player names listed per embed.
+const DefaultMaxNames = 30
+
+// PayloadLimits bounds the size of generated Discord payloads.
+// Zero or out-of-range values fall back to the Discord limits (or
+// DefaultMaxNames); limits can only be tightened, never relaxed.
+type PayloadLimits struct {
+	MaxEmbeds int // embeds per message
+	MaxNames  int // player names listed per embed before "and N more…"
+	MaxChars  int // combined embed characters per message
+}
+
+// DefaultPayloadLimits returns the limits used by BuildPayloads.
+func DefaultPayloadLimits() PayloadLimits {
+	return PayloadLimits{
+		MaxEmbeds: MaxEmbedsPerRequest,
+		MaxNames:  DefaultMaxNames,
+		MaxChars:  MaxCharsPerRequest,
+	}
+}
+
+func (l PayloadLimits) normalize() PayloadLimits {
+	d := DefaultPayloadLimits()
+	if l.MaxEmbeds <= 0 || l.MaxEmbeds > d.MaxEmbeds {
+		l.MaxEmbeds = d.MaxEmbeds
+	}
+	if l.MaxNames <= 0 {
+		l.MaxNames = d.MaxNames
+	}
+	if l.MaxChars <= 0 || l.MaxChars > d.MaxChars {
+		l.MaxChars = d.MaxChars
+	}
+	return l
+}
+
+// descriptionLimit returns the room left for the description of an embed
+// with the given title.
+func (l PayloadLimits) descriptionLimit(title string) int {
+	return min(MaxDescriptionLength, l.MaxChars-utf8.RuneCountInString(title))
+}
 
 // DiscordPayload represents a Discord webhook request body.
 type DiscordPayload struct {
-	Content string         `json:"content,omitempty"`
-	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+	Content   string         `json:"content,omitempty"`
+	Username  string         `json:"username,omitempty"`
+	AvatarURL string         `json:"avatar_url,omitempty"`
+	Embeds    []DiscordEmbed `json:"embeds,omitempty"`
+
+	// AllowedMentions restricts who the content may ping. Without it,
+	// Discord would also resolve mentions in player names.
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+
+	// ThreadID posts the message into a thread of the webhook's channel.
+	// Discord takes it as a query parameter, so it is not part of the body.
+	ThreadID string `json:"-"`
+}
+
+// AllowedMentions is the allowed_mentions object of a webhook request.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`           // always empty: no @everyone or user pings
+	Roles []string `json:"roles,omitempty"` // role IDs that may be pinged
+}
+
+// addMentions pings the given roles in the content of p.
+func addMentions(p *DiscordPayload, roles []string) {
+	tags := make([]string, len(roles))
+	for i, id := range roles {
+		tags[i] = "<@&" + id + ">"
+	}
+	p.Content = strings.TrimSpace(strings.Join(tags, " ") + " " + p.Content)
+	p.AllowedMentions = &AllowedMentions{Parse: []string{}, Roles: roles}
 }
 
 // DiscordEmbed represents a Discord embed.
@@ -32,16 +113,32 @@ type DiscordEmbed struct {
 	Timestamp   string `json:"timestamp,omitempty"`
 }
 
-// BuildPayloads creates Discord payloads from batched derived events.
-// May return multiple payloads if events exceed MaxEmbedsPerRequest.
+// BuildPayloads creates Discord payloads from batched derived events using
+// DefaultPayloadLimits.
 func BuildPayloads(events []*derive.DerivedEvent) []DiscordPayload {
+	return BuildPayloadsWithLimits(events, DefaultPayloadLimits())
+}
+
+// BuildPayloadsWithLimits creates Discord payloads from batched derived
+// events. Long name lists are truncated to fit a single embed, and embeds
+// are split across multiple payloads when they exceed the embed count or
+// character limits of one message.
+func BuildPayloadsWithLimits(events []*derive.DerivedEvent, limits PayloadLimits) []DiscordPayload {
+	return buildPayloads(events, limits, nil)
+}
+
+// buildPayloads is BuildPayloadsWithLimits with joins and leaves of friends
+// grouped apart from strangers. A nil friend puts everyone in one group and
+// leaves out the summary line.
+func buildPayloads(events []*derive.DerivedEvent, limits PayloadLimits, friend func(*derive.DerivedEvent) bool) []DiscordPayload {
 	if len(events) == 0 {
 		return nil
 	}
+	limits = limits.normalize()
 
 	// Group by type for cleaner messages
 	var joins, leaves []*derive.DerivedEvent
-	var worldChanges []*derive.DerivedEvent
+	var worldChanges, recaps, returns, milestones []*derive.DerivedEvent
 
 	for _, e := range events {
 		switch e.Type {
@@ -51,31 +148,103 @@ func BuildPayloads(events []*derive.DerivedEvent) []DiscordPayload {
 			leaves = append(leaves, e)
 		case derive.DerivedWorldChanged:
 			worldChanges = append(worldChanges, e)
+		case derive.DerivedSessionEnded:
+			if e.Recap != nil {
+				recaps = append(recaps, e)
+			}
+		case derive.DerivedPlayerReturned:
+			if e.LastSeen != nil {
+				returns = append(returns, e)
+			}
+		case derive.DerivedMilestone:
+			if e.Milestone != nil {
+				milestones = append(milestones, e)
+			}
 		}
 	}
 
 	var embeds []DiscordEmbed
 
+	// Recaps of the sessions that ended come before the new world
+	for _, r := range recaps {
+		embeds = append(embeds, buildRecapEmbed(r.Recap, limits))
+	}
+
 	// World change embeds (usually one, but handle multiples)
 	for _, wc := range worldChanges {
-		embeds = append(embeds, buildWorldEmbed(wc))
+		embeds = append(embeds, buildWorldEmbed(wc, limits))
 	}
 
-	// Batch joins into single embed
-	if len(joins) > 0 {
-		embeds = append(embeds, buildJoinsEmbed(joins))
+	// Returning players get an embed each, ahead of the joins
+	for _, r := range returns {
+		embeds = append(embeds, buildReturnedEmbed(r, limits))
 	}
 
-	// Batch leaves into single embed
+	for _, m := range milestones {
+		embeds = append(embeds, buildMilestoneEmbed(m, limits))
+	}
+
+	// Batch joins and leaves into one embed each, friends first
+	friendJoins, joins := splitFriends(joins, friend)
+	friendLeaves, leaves := splitFriends(leaves, friend)
+	if len(friendJoins) > 0 {
+		embeds = append(embeds, buildJoinsEmbed(friendJoins, "Friend Joined", ColorGold, limits))
+	}
+	if len(joins) > 0 {
+		embeds = append(embeds, buildJoinsEmbed(joins, "Player Joined", ColorGreen, limits))
+	}
+	if len(friendLeaves) > 0 {
+		embeds = append(embeds, buildLeavesEmbed(friendLeaves, "Friend Left", ColorOrange, limits))
+	}
 	if len(leaves) > 0 {
-		embeds = append(embeds, buildLeavesEmbed(leaves))
+		embeds = append(embeds, buildLeavesEmbed(leaves, "Player Left", ColorRed, limits))
 	}
 
 	// Split into multiple payloads if needed
-	return splitIntoPayloads(embeds)
+	payloads := splitIntoPayloads(embeds, limits)
+	if friend != nil && len(payloads) > 0 {
+		var lines []string
+		if n := len(friendJoins) + len(joins); n > 1 {
+			lines = append(lines, summarizeFriends(len(friendJoins), len(joins), "joined"))
+		}
+		if n := len(friendLeaves) + len(leaves); n > 1 {
+			lines = append(lines, summarizeFriends(len(friendLeaves), len(leaves), "left"))
+		}
+		payloads[0].Content = strings.Join(lines, "\n")
+	}
+	return payloads
 }
 
-func buildWorldEmbed(e *derive.DerivedEvent) DiscordEmbed {
+// splitFriends splits events into those of friends and the rest, keeping
+// their order. With a nil friend, everyone is in the rest.
+func splitFriends(events []*derive.DerivedEvent, friend func(*derive.DerivedEvent) bool) (friends, rest []*derive.DerivedEvent) {
+	if friend == nil {
+		return nil, events
+	}
+	for _, e := range events {
+		if friend(e) {
+			friends = append(friends, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	return friends, rest
+}
+
+// summarizeFriends renders a summary line such as "3 friends, 5 strangers
+// joined".
+func summarizeFriends(friends, strangers int, verb string) string {
+	return fmt.Sprintf("%s, %s %s", plural(friends, "friend"), plural(strangers, "stranger"), verb)
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func buildWorldEmbed(e *derive.DerivedEvent, l PayloadLimits) DiscordEmbed {
 	worldName := deref(e.Event.WorldName)
 	if worldName == "" {
 		worldName = "Unknown World"
@@ -90,13 +259,87 @@ func buildWorldEmbed(e *derive.DerivedEvent) DiscordEmbed {
 
 	return DiscordEmbed{
 		Title:       "World Changed",
-		Description: desc,
+		Description: truncate(desc, l.descriptionLimit("World Changed")),
 		Color:       ColorBlue,
 		Timestamp:   e.Event.Ts.Format(time.RFC3339),
 	}
 }
 
-func buildJoinsEmbed(events []*derive.DerivedEvent) DiscordEmbed {
+func buildRecapEmbed(r *derive.SessionRecap, l PayloadLimits) DiscordEmbed {
+	const title = "Session Recap"
+	worldName := r.World.WorldName
+	if worldName == "" {
+		worldName = "Unknown World"
+	}
+
+	desc := fmt.Sprintf("**%s** for %s\nPeak players: %d\nPlayers seen (%d): ",
+		worldName, formatPresence(int64(r.Duration()/time.Second)), r.PeakPlayers, len(r.PlayersSeen))
+	desc += joinNames(r.PlayersSeen, l.MaxNames, l.descriptionLimit(title)-utf8.RuneCountInString(desc))
+
+	return DiscordEmbed{
+		Title:       title,
+		Description: truncate(desc, l.descriptionLimit(title)),
+		Color:       ColorGray,
+		Timestamp:   r.EndedAt.Format(time.RFC3339),
+	}
+}
+
+func buildReturnedEmbed(e *derive.DerivedEvent, l PayloadLimits) DiscordEmbed {
+	const title = "Welcome Back"
+	desc := fmt.Sprintf("You haven't seen **%s** in %s — they just joined.",
+		deref(e.Event.PlayerName), formatAbsence(e.Event.Ts.Sub(*e.LastSeen)))
+
+	return DiscordEmbed{
+		Title:       title,
+		Description: truncate(desc, l.descriptionLimit(title)),
+		Color:       ColorPurple,
+		Timestamp:   e.Event.Ts.Format(time.RFC3339),
+	}
+}
+
+func buildMilestoneEmbed(e *derive.DerivedEvent, l PayloadLimits) DiscordEmbed {
+	const title = "Milestone"
+	m := e.Milestone
+	var desc string
+	switch m.Kind {
+	case "world_visits":
+		desc = fmt.Sprintf("Your %s visit to **%s**!", ordinal(m.Value), m.Name)
+	case "player_anniversary":
+		desc = fmt.Sprintf("%s since you first met **%s**.", plural(m.Value, "year"), m.Name)
+	case "play_streak":
+		desc = fmt.Sprintf("%d days in a row in VRChat!", m.Value)
+	default:
+		desc = fmt.Sprintf("%s: %d", m.Kind, m.Value)
+	}
+
+	embed := DiscordEmbed{
+		Title:       title,
+		Description: truncate(desc, l.descriptionLimit(title)),
+		Color:       ColorGold,
+	}
+	if e.Event != nil {
+		embed.Timestamp = e.Event.Ts.Format(time.RFC3339)
+	}
+	return embed
+}
+
+// ordinal renders n as 1st, 2nd, 3rd, 4th, ..., 11th, ..., 21st.
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func buildJoinsEmbed(events []*derive.DerivedEvent, title string, color int, l PayloadLimits) DiscordEmbed {
 	names := make([]string, len(events))
 	for i, e := range events {
 		names[i] = deref(e.Event.PlayerName)
@@ -106,52 +349,154 @@ func buildJoinsEmbed(events []*derive.DerivedEvent) DiscordEmbed {
 	if len(events) == 1 {
 		desc = fmt.Sprintf("**%s** joined", names[0])
 	} else {
-		desc = fmt.Sprintf("**%d players** joined: %s", len(events), strings.Join(names, ", "))
+		desc = fmt.Sprintf("**%d players** joined: ", len(events))
+		desc += joinNames(names, l.MaxNames, l.descriptionLimit(title)-utf8.RuneCountInString(desc))
 	}
 
 	return DiscordEmbed{
-		Title:       "Player Joined",
-		Description: desc,
-		Color:       ColorGreen,
+		Title:       title,
+		Description: truncate(desc, l.descriptionLimit(title)),
+		Color:       color,
 		Timestamp:   events[len(events)-1].Event.Ts.Format(time.RFC3339),
 	}
 }
 
-func buildLeavesEmbed(events []*derive.DerivedEvent) DiscordEmbed {
+func buildLeavesEmbed(events []*derive.DerivedEvent, title string, color int, l PayloadLimits) DiscordEmbed {
 	names := make([]string, len(events))
 	for i, e := range events {
 		names[i] = deref(e.Event.PlayerName)
+		if d := e.Event.DurationSec; d != nil {
+			names[i] += fmt.Sprintf(" (%s)", formatPresence(*d))
+		}
 	}
 
 	var desc string
 	if len(events) == 1 {
-		desc = fmt.Sprintf("**%s** left", names[0])
+		desc = fmt.Sprintf("**%s** left", deref(events[0].Event.PlayerName))
+		if d := events[0].Event.DurationSec; d != nil {
+			desc += " after " + formatPresence(*d)
+		}
 	} else {
-		desc = fmt.Sprintf("**%d players** left: %s", len(events), strings.Join(names, ", "))
+		desc = fmt.Sprintf("**%d players** left: ", len(events))
+		desc += joinNames(names, l.MaxNames, l.descriptionLimit(title)-utf8.RuneCountInString(desc))
 	}
 
 	return DiscordEmbed{
-		Title:       "Player Left",
-		Description: desc,
-		Color:       ColorRed,
+		Title:       title,
+		Description: truncate(desc, l.descriptionLimit(title)),
+		Color:       color,
 		Timestamp:   events[len(events)-1].Event.Ts.Format(time.RFC3339),
 	}
 }
 
-func splitIntoPayloads(embeds []DiscordEmbed) []DiscordPayload {
+// formatPresence renders a presence duration in seconds, e.g. "47 minutes"
+// or "2h 5m".
+func formatPresence(sec int64) string {
+	switch {
+	case sec < 60:
+		return "less than a minute"
+	case sec < 120:
+		return "1 minute"
+	case sec < 3600:
+		return fmt.Sprintf("%d minutes", sec/60)
+	default:
+		return fmt.Sprintf("%dh %dm", sec/3600, (sec%3600)/60)
+	}
+}
+
+// formatAbsence renders a long absence coarsely, e.g. "3 weeks" or
+// "3 months".
+func formatAbsence(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	n, unit := days, "day"
+	switch {
+	case days >= 365:
+		n, unit = days/365, "year"
+	case days >= 60:
+		n, unit = days/30, "month"
+	case days >= 14:
+		n, unit = days/7, "week"
+	}
+	return plural(n, unit)
+}
+
+// joinNames joins names with ", ", listing at most maxNames of them and
+// no more than budget characters in total. Names that don't fit are
+// summarized as " and N more…".
+func joinNames(names []string, maxNames, budget int) string {
+	var b strings.Builder
+	length, listed := 0, 0
+	for i, name := range names {
+		if i >= maxNames {
+			break
+		}
+		sep := ""
+		if i > 0 {
+			sep = ", "
+		}
+		// Reserve room for the summary in case this is the last name that fits
+		reserve := 0
+		if rest := len(names) - i - 1; rest > 0 {
+			reserve = utf8.RuneCountInString(moreNames(rest, false))
+		}
+		n := utf8.RuneCountInString(sep + name)
+		if length+n+reserve > budget {
+			break
+		}
+		b.WriteString(sep + name)
+		length += n
+		listed++
+	}
+	if rest := len(names) - listed; rest > 0 {
+		b.WriteString(moreNames(rest, listed == 0))
+	}
+	return b.String()
+}
+
+func moreNames(n int, alone bool) string {
+	if alone {
+		return fmt.Sprintf("%d more…", n)
+	}
+	return fmt.Sprintf(" and %d more…", n)
+}
+
+// truncate shortens s to at most max characters, ending with "…" if cut.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	if max <= 0 {
+		return ""
+	}
+	r := []rune(s)
+	return string(r[:max-1]) + "…"
+}
+
+// embedLength returns the length of an embed as counted by Discord.
+func embedLength(e DiscordEmbed) int {
+	return utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+}
+
+// splitIntoPayloads packs embeds into payloads in order, starting a new
+// payload whenever the next embed would exceed the embed or character limit.
+func splitIntoPayloads(embeds []DiscordEmbed, l PayloadLimits) []DiscordPayload {
 	if len(embeds) == 0 {
 		return nil
 	}
 
 	var payloads []DiscordPayload
-	for i := 0; i < len(embeds); i += MaxEmbedsPerRequest {
-		end := i + MaxEmbedsPerRequest
-		if end > len(embeds) {
-			end = len(embeds)
+	var cur []DiscordEmbed
+	chars := 0
+	for _, e := range embeds {
+		n := embedLength(e)
+		if len(cur) > 0 && (len(cur) >= l.MaxEmbeds || chars+n > l.MaxChars) {
+			payloads = append(payloads, DiscordPayload{Embeds: cur})
+			cur, chars = nil, 0
 		}
-		payloads = append(payloads, DiscordPayload{Embeds: embeds[i:end]})
+		cur = append(cur, e)
+		chars += n
 	}
-	return payloads
+	return append(payloads, DiscordPayload{Embeds: cur})
 }
 
 func deref(s *string) string {
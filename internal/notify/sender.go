@@ -7,7 +7,9 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/graaaaa/vrclog-companion/internal/config"
@@ -37,8 +39,32 @@ type DiscordSender struct {
 	webhookURL config.Secret
 	client     *http.Client
 	logger     *slog.Logger
+
+	// Per-sink defaults for payloads that don't set their own
+	threadID  string
+	username  string
+	avatarURL string
+
+	// Rate limit bucket reported by Discord; shared by all sends so that
+	// split payloads of one flush are paced together (protected by mu)
+	mu     sync.Mutex
+	bucket rateBucket
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
 }
 
+// rateBucket is the last known state of the webhook's rate limit bucket.
+type rateBucket struct {
+	known     bool
+	remaining int
+	resetAt   time.Time
+}
+
+// MaxPaceWait is the longest Send blocks waiting for the rate limit bucket
+// to reset. Longer waits are returned to the caller as a retry-after.
+const MaxPaceWait = 5 * time.Second
+
 // SenderOption configures a DiscordSender.
 type SenderOption func(*DiscordSender)
 
@@ -52,6 +78,21 @@ func WithSenderLogger(logger *slog.Logger) SenderOption {
 	return func(s *DiscordSender) { s.logger = logger }
 }
 
+// WithThreadID posts all messages into the given thread of the webhook's
+// channel, e.g. a forum post.
+func WithThreadID(id string) SenderOption {
+	return func(s *DiscordSender) { s.threadID = id }
+}
+
+// WithIdentity overrides the webhook's default username and avatar.
+// Empty values keep the defaults configured in Discord.
+func WithIdentity(username, avatarURL string) SenderOption {
+	return func(s *DiscordSender) {
+		s.username = username
+		s.avatarURL = avatarURL
+	}
+}
+
 // NewDiscordSender creates a new Discord sender.
 // The webhookURL is stored as a Secret and will appear as [REDACTED] in logs.
 func NewDiscordSender(webhookURL config.Secret, opts ...SenderOption) *DiscordSender {
@@ -59,6 +100,8 @@ func NewDiscordSender(webhookURL config.Secret, opts ...SenderOption) *DiscordSe
 		webhookURL: webhookURL,
 		client:     &http.Client{Timeout: 10 * time.Second},
 		logger:     slog.Default(),
+		now:        time.Now,
+		sleep:      sleepContext,
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -73,15 +116,43 @@ func (s *DiscordSender) Send(ctx context.Context, payload DiscordPayload) (SendR
 		return SendFatal, 0
 	}
 
+	if payload.ThreadID == "" {
+		payload.ThreadID = s.threadID
+	}
+	if payload.Username == "" {
+		payload.Username = s.username
+	}
+	if payload.AvatarURL == "" {
+		payload.AvatarURL = s.avatarURL
+	}
+
 	body, err := json.Marshal(payload)
 	if err != nil {
 		s.logger.Error("failed to marshal Discord payload", "error", err)
 		return SendFatal, 0
 	}
 
-	// Note: webhookURL.Value() gets the actual URL for the request
+	// Pace proactively instead of running into a 429
+	if wait := s.reserve(); wait > 0 {
+		if wait > MaxPaceWait {
+			s.logger.Debug("Discord rate limit bucket exhausted", "reset_after", wait)
+			return SendRetryable, wait
+		}
+		if err := s.sleep(ctx, wait); err != nil {
+			return SendRetryable, 0
+		}
+		s.reserve()
+	}
+
+	target, err := s.requestURL(payload.ThreadID)
+	if err != nil {
+		s.logger.Error("invalid Discord webhook URL", "webhook_url", s.webhookURL)
+		return SendFatal, 0
+	}
+
+	// Note: target holds the actual URL for the request
 	// but webhookURL itself logs as [REDACTED]
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.webhookURL.Value(), bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
 	if err != nil {
 		s.logger.Error("failed to create request", "error", err)
 		return SendFatal, 0
@@ -98,6 +169,8 @@ func (s *DiscordSender) Send(ctx context.Context, payload DiscordPayload) (SendR
 	// Drain body to allow connection reuse
 	_, _ = io.Copy(io.Discard, resp.Body)
 
+	s.updateBucket(resp.Header)
+
 	switch {
 	case resp.StatusCode >= 200 && resp.StatusCode < 300:
 		s.logger.Debug("Discord notification sent", "status", resp.StatusCode)
@@ -106,6 +179,7 @@ func (s *DiscordSender) Send(ctx context.Context, payload DiscordPayload) (SendR
 	case resp.StatusCode == 429:
 		// Rate limited - check Retry-After header
 		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		s.exhaustBucket(retryAfter)
 		s.logger.Warn("Discord rate limited", "retry_after", retryAfter)
 		return SendRetryable, retryAfter
 
@@ -129,6 +203,86 @@ func (s *DiscordSender) Send(ctx context.Context, payload DiscordPayload) (SendR
 	}
 }
 
+// reserve takes one request from the rate limit bucket. It returns how long
+// to wait first if the bucket is exhausted, or 0 if the request may go ahead.
+func (s *DiscordSender) reserve() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.bucket.known {
+		return 0
+	}
+	if wait := s.bucket.resetAt.Sub(s.now()); wait <= 0 {
+		// Bucket has reset; the next response tells us the new state
+		s.bucket = rateBucket{}
+		return 0
+	} else if s.bucket.remaining <= 0 {
+		return wait
+	}
+	s.bucket.remaining--
+	return 0
+}
+
+// updateBucket records the rate limit headers of a Discord response.
+// Responses without them leave the bucket unchanged.
+func (s *DiscordSender) updateBucket(h http.Header) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetAfter := parseRetryAfter(h.Get("X-RateLimit-Reset-After"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bucket = rateBucket{
+		known:     true,
+		remaining: remaining,
+		resetAt:   s.now().Add(resetAfter),
+	}
+}
+
+// exhaustBucket marks the bucket empty until retryAfter has passed, so a
+// 429 also pauses sends that don't go through the notifier's backoff.
+func (s *DiscordSender) exhaustBucket(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resetAt := s.now().Add(retryAfter); resetAt.After(s.bucket.resetAt) {
+		s.bucket.resetAt = resetAt
+	}
+	s.bucket.known = true
+	s.bucket.remaining = 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestURL returns the webhook URL, with the thread_id query parameter
+// added when threadID is set.
+func (s *DiscordSender) requestURL(threadID string) (string, error) {
+	if threadID == "" {
+		return s.webhookURL.Value(), nil
+	}
+	u, err := url.Parse(s.webhookURL.Value())
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("thread_id", threadID)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 func parseRetryAfter(header string) time.Duration {
 	if header == "" {
 		return 0
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+func TestDiscordSender_ThreadAndIdentity(t *testing.T) {
+	var gotQuery string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewDiscordSender(config.Secret(srv.URL+"/api/webhooks/1/token?wait=true"),
+		WithThreadID("123456"),
+		WithIdentity("VRClog", "https://example.com/avatar.png"))
+
+	result, _ := s.Send(context.Background(), DiscordPayload{Embeds: []DiscordEmbed{{Title: "x"}}})
+	if result != SendOK {
+		t.Fatalf("expected SendOK, got %v", result)
+	}
+	if gotQuery != "thread_id=123456&wait=true" {
+		t.Errorf("query = %q", gotQuery)
+	}
+	if gotBody["username"] != "VRClog" || gotBody["avatar_url"] != "https://example.com/avatar.png" {
+		t.Errorf("identity not sent: %v", gotBody)
+	}
+	if _, ok := gotBody["thread_id"]; ok {
+		t.Error("thread_id must not be part of the body")
+	}
+}
+
+func TestDiscordSender_PayloadOverridesSink(t *testing.T) {
+	var gotQuery string
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewDiscordSender(config.Secret(srv.URL), WithThreadID("1"), WithIdentity("Sink", ""))
+	s.Send(context.Background(), DiscordPayload{Content: "hi", ThreadID: "2", Username: "Payload"})
+
+	if gotQuery != "thread_id=2" {
+		t.Errorf("query = %q, want thread_id=2", gotQuery)
+	}
+	if gotBody["username"] != "Payload" {
+		t.Errorf("username = %v, want Payload", gotBody["username"])
+	}
+	if _, ok := gotBody["avatar_url"]; ok {
+		t.Error("empty avatar_url should be omitted")
+	}
+}
+
+// fakePacer replaces the sender's clock and sleep, advancing the clock by
+// each slept duration.
+type fakePacer struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (p *fakePacer) install(s *DiscordSender) {
+	p.now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return p.now }
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		p.sleeps = append(p.sleeps, d)
+		p.now = p.now.Add(d)
+		return nil
+	}
+}
+
+func TestDiscordSender_PacesOnExhaustedBucket(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// Bucket of 2: first response leaves 1, second leaves 0
+		w.Header().Set("X-RateLimit-Remaining", []string{"1", "0", "1"}[min(requests-1, 2)])
+		w.Header().Set("X-RateLimit-Reset-After", "1.5")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewDiscordSender(config.Secret(srv.URL))
+	var p fakePacer
+	p.install(s)
+
+	for i := 0; i < 3; i++ {
+		if result, _ := s.Send(context.Background(), DiscordPayload{Content: "x"}); result != SendOK {
+			t.Fatalf("send %d: expected SendOK, got %v", i, result)
+		}
+	}
+
+	if len(p.sleeps) != 1 || p.sleeps[0] != 1500*time.Millisecond {
+		t.Errorf("expected one 1.5s wait before the third send, got %v", p.sleeps)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestDiscordSender_LongResetReturnsRetryAfter(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "30")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := NewDiscordSender(config.Secret(srv.URL))
+	var p fakePacer
+	p.install(s)
+
+	s.Send(context.Background(), DiscordPayload{Content: "x"})
+	result, retryAfter := s.Send(context.Background(), DiscordPayload{Content: "x"})
+	if result != SendRetryable || retryAfter != 30*time.Second {
+		t.Errorf("expected retryable with 30s, got %v %v", result, retryAfter)
+	}
+	if requests != 1 || len(p.sleeps) != 0 {
+		t.Errorf("expected no request or wait, got %d requests and sleeps %v", requests, p.sleeps)
+	}
+}
+
+func TestDiscordSender_429ExhaustsBucket(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	s := NewDiscordSender(config.Secret(srv.URL))
+	var p fakePacer
+	p.install(s)
+
+	if result, retryAfter := s.Send(context.Background(), DiscordPayload{Content: "x"}); result != SendRetryable || retryAfter != 2*time.Second {
+		t.Fatalf("expected retryable with 2s, got %v %v", result, retryAfter)
+	}
+	if wait := s.reserve(); wait != 2*time.Second {
+		t.Errorf("expected bucket to be exhausted for 2s, got %v", wait)
+	}
+}
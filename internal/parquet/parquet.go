@@ -0,0 +1,409 @@
+// Package parquet writes Apache Parquet files, so exports can be loaded
+// straight into DuckDB, pandas and the like. It covers only what flat
+// event rows need: required and optional INT64, timestamp and string
+// columns, PLAIN encoded and GZIP compressed, one page per column chunk.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Type is the type of a column.
+type Type int
+
+// Column types.
+const (
+	Int64     Type = iota // int64
+	String                // string, stored as UTF-8 BYTE_ARRAY
+	Timestamp             // time.Time, stored as INT64 microseconds since the Unix epoch, UTC
+)
+
+// Column describes one column of the file.
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool // nil values are allowed and stored as null
+}
+
+// DefaultRowGroupSize is how many rows a Writer buffers before writing
+// them out as a row group.
+const DefaultRowGroupSize = 64 * 1024
+
+// Parquet physical types, repetitions, encodings and annotations
+// (parquet.thrift).
+const (
+	physInt64     = 2
+	physByteArray = 6
+
+	repRequired = 0
+	repOptional = 1
+
+	encPlain = 0
+	encRLE   = 3
+
+	codecGzip = 2
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	pageData = 0
+)
+
+const magic = "PAR1"
+
+// column buffers the values of one column for the current row group.
+type column struct {
+	Column
+	defs   []bool // per row: value present; optional columns only
+	values bytes.Buffer
+	count  int // non-null values
+}
+
+// chunk is a written column chunk, kept for the footer.
+type chunk struct {
+	offset           int64
+	numValues        int64
+	compressedSize   int64
+	uncompressedSize int64
+}
+
+type rowGroup struct {
+	chunks []chunk
+	rows   int64
+	size   int64
+}
+
+// Writer writes rows to a Parquet file. Nothing reaches the underlying
+// writer before the first row group is full or Close is called. Close
+// must be called to write the footer.
+type Writer struct {
+	w            io.Writer
+	columns      []*column
+	rowGroupSize int
+	metadata     [][2]string
+
+	offset    int64
+	rows      int // rows in the current row group
+	rowGroups []rowGroup
+	numRows   int64
+	err       error
+	closed    bool
+}
+
+// Option configures a Writer.
+type Option func(*Writer)
+
+// WithRowGroupSize sets how many rows go in a row group.
+func WithRowGroupSize(n int) Option {
+	return func(w *Writer) {
+		if n > 0 {
+			w.rowGroupSize = n
+		}
+	}
+}
+
+// NewWriter returns a Writer for a file with the given columns.
+func NewWriter(w io.Writer, columns []Column, opts ...Option) *Writer {
+	pw := &Writer{w: w, rowGroupSize: DefaultRowGroupSize}
+	for _, c := range columns {
+		pw.columns = append(pw.columns, &column{Column: c})
+	}
+	for _, opt := range opts {
+		opt(pw)
+	}
+	return pw
+}
+
+// SetMetadata adds a key/value pair to the file's metadata. It may be
+// called any time before Close.
+func (w *Writer) SetMetadata(key, value string) {
+	w.metadata = append(w.metadata, [2]string{key, value})
+}
+
+// Write adds a row with one value per column, in column order: int64,
+// string or time.Time according to the column type, or nil for null.
+func (w *Writer) Write(row []any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return errors.New("parquet: write after close")
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("parquet: row has %d values, want %d", len(row), len(w.columns))
+	}
+	for i, c := range w.columns {
+		if err := c.check(row[i]); err != nil {
+			return err
+		}
+	}
+	for i, c := range w.columns {
+		c.append(row[i])
+	}
+	w.rows++
+	if w.rows >= w.rowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.rows > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	footer := w.footer()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	footer = append(footer, magic...)
+	return w.write(footer)
+}
+
+func (c *column) check(v any) error {
+	if v == nil {
+		if !c.Optional {
+			return fmt.Errorf("parquet: column %s: null in required column", c.Name)
+		}
+		return nil
+	}
+	ok := false
+	switch c.Type {
+	case Int64:
+		_, ok = v.(int64)
+	case String:
+		_, ok = v.(string)
+	case Timestamp:
+		_, ok = v.(time.Time)
+	}
+	if !ok {
+		return fmt.Errorf("parquet: column %s: unexpected value of type %T", c.Name, v)
+	}
+	return nil
+}
+
+func (c *column) append(v any) {
+	if c.Optional {
+		c.defs = append(c.defs, v != nil)
+	}
+	if v == nil {
+		return
+	}
+	var b [8]byte
+	switch v := v.(type) {
+	case int64:
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		c.values.Write(b[:])
+	case time.Time:
+		binary.LittleEndian.PutUint64(b[:], uint64(v.UnixMicro()))
+		c.values.Write(b[:])
+	case string:
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(v)))
+		c.values.Write(b[:4])
+		c.values.WriteString(v)
+	}
+	c.count++
+}
+
+func (c *column) physicalType() int32 {
+	if c.Type == String {
+		return physByteArray
+	}
+	return physInt64
+}
+
+func (w *Writer) write(p []byte) error {
+	if w.offset == 0 {
+		if _, err := io.WriteString(w.w, magic); err != nil {
+			w.err = err
+			return err
+		}
+		w.offset = int64(len(magic))
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	rg := rowGroup{rows: int64(w.rows)}
+	for _, c := range w.columns {
+		page := c.page()
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		zw.Write(page)
+		if err := zw.Close(); err != nil {
+			w.err = err
+			return err
+		}
+
+		var t thriftWriter
+		t.begin()
+		t.i32(1, pageData)
+		t.i32(2, int32(len(page)))
+		t.i32(3, int32(compressed.Len()))
+		t.structField(5)
+		t.i32(1, int32(w.rows))
+		t.i32(2, encPlain)
+		t.i32(3, encRLE)
+		t.i32(4, encRLE)
+		t.end()
+		t.end()
+
+		ch := chunk{
+			offset:           max(w.offset, int64(len(magic))),
+			numValues:        int64(w.rows),
+			compressedSize:   int64(len(t.buf) + compressed.Len()),
+			uncompressedSize: int64(len(t.buf) + len(page)),
+		}
+		if err := w.write(t.buf); err != nil {
+			return err
+		}
+		if err := w.write(compressed.Bytes()); err != nil {
+			return err
+		}
+		rg.chunks = append(rg.chunks, ch)
+		rg.size += ch.uncompressedSize
+
+		c.defs = c.defs[:0]
+		c.values.Reset()
+		c.count = 0
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+	w.numRows += rg.rows
+	w.rows = 0
+	return nil
+}
+
+// page returns the uncompressed data page: definition levels for an
+// optional column, then the non-null values.
+func (c *column) page() []byte {
+	var page []byte
+	if c.Optional {
+		levels := encodeLevels(c.defs)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	return append(page, c.values.Bytes()...)
+}
+
+// encodeLevels encodes definition levels 0 and 1 as RLE runs of the
+// RLE/bit-packing hybrid encoding with bit width 1.
+func encodeLevels(defs []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defs); {
+		j := i + 1
+		for j < len(defs) && defs[j] == defs[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defs[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// footer returns the thrift-encoded FileMetaData.
+func (w *Writer) footer() []byte {
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1) // version
+
+	t.listHeader(2, ctStruct, len(w.columns)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.end()
+	for _, c := range w.columns {
+		t.begin()
+		t.i32(1, c.physicalType())
+		if c.Optional {
+			t.i32(3, repOptional)
+		} else {
+			t.i32(3, repRequired)
+		}
+		t.binary(4, c.Name)
+		switch c.Type {
+		case String:
+			t.i32(6, convertedUTF8)
+			t.structField(10) // LogicalType
+			t.structField(1)  // STRING
+			t.end()
+			t.end()
+		case Timestamp:
+			t.i32(6, convertedTimestampMicros)
+			t.structField(10) // LogicalType
+			t.structField(8)  // TIMESTAMP
+			t.bool(1, true)   // isAdjustedToUTC
+			t.structField(2)  // unit
+			t.structField(2)  // MICROS
+			t.end()
+			t.end()
+			t.end()
+			t.end()
+		}
+		t.end()
+	}
+
+	t.i64(3, w.numRows)
+
+	t.listHeader(4, ctStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		t.begin()
+		t.listHeader(1, ctStruct, len(rg.chunks))
+		for i, ch := range rg.chunks {
+			c := w.columns[i]
+			t.begin()
+			t.i64(2, ch.offset)
+			t.structField(3) // ColumnMetaData
+			t.i32(1, c.physicalType())
+			t.i32List(2, []int32{encPlain, encRLE})
+			t.stringList(3, []string{c.Name})
+			t.i32(4, codecGzip)
+			t.i64(5, ch.numValues)
+			t.i64(6, ch.uncompressedSize)
+			t.i64(7, ch.compressedSize)
+			t.i64(9, ch.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, rg.size)
+		t.i64(3, rg.rows)
+		t.end()
+	}
+
+	if len(w.metadata) > 0 {
+		t.listHeader(5, ctStruct, len(w.metadata))
+		for _, kv := range w.metadata {
+			t.begin()
+			t.binary(1, kv[0])
+			t.binary(2, kv[1])
+			t.end()
+		}
+	}
+	t.binary(6, "vrclog-companion")
+	t.end()
+	return t.buf
+}
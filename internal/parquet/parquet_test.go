@@ -0,0 +1,85 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int64},
+		{Name: "ts", Type: Timestamp},
+		{Name: "name", Type: String, Optional: true},
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, columns, WithRowGroupSize(2))
+	w.SetMetadata("origin", "test")
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	for i, name := range []any{"Alice", nil, "Bob"} {
+		if err := w.Write([]any{int64(i), base, name}); err != nil {
+			t.Fatalf("Write row %d: %v", i, err)
+		}
+	}
+	if buf.Len() == 0 {
+		t.Error("the first full row group was not written")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte(magic)) || !bytes.HasSuffix(b, []byte(magic)) {
+		t.Fatalf("missing magic: % x", b)
+	}
+	n := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if n <= 0 || n > len(b)-12 {
+		t.Fatalf("footer length %d out of range for a %d byte file", n, len(b))
+	}
+	footer := b[len(b)-8-n : len(b)-8]
+	for _, want := range []string{"schema", "id", "ts", "name", "origin", "test"} {
+		if !bytes.Contains(footer, []byte(want)) {
+			t.Errorf("footer lacks %q", want)
+		}
+	}
+	if w.numRows != 3 || len(w.rowGroups) != 2 {
+		t.Errorf("rows = %d in %d row groups, want 3 in 2", w.numRows, len(w.rowGroups))
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	w := NewWriter(&bytes.Buffer{}, []Column{{Name: "id", Type: Int64}, {Name: "name", Type: String, Optional: true}})
+	for _, tt := range []struct {
+		row  []any
+		want string
+	}{
+		{[]any{int64(1)}, "row has 1 values"},
+		{[]any{nil, "Alice"}, "null in required column"},
+		{[]any{1, "Alice"}, "unexpected value of type int"},
+		{[]any{int64(1), []byte("Alice")}, "unexpected value of type []uint8"},
+	} {
+		if err := w.Write(tt.row); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Write(%v) = %v, want %q", tt.row, err, tt.want)
+		}
+	}
+	if w.rows != 0 {
+		t.Errorf("rejected rows were buffered: %d", w.rows)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Write([]any{int64(1), nil}); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]bool{true, true, true, false, true})
+	// Runs: 3 x 1, 1 x 0, 1 x 1; each header is count<<1, then the value
+	want := []byte{6, 1, 2, 0, 2, 1}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeLevels = % x, want % x", got, want)
+	}
+}
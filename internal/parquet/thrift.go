@@ -0,0 +1,108 @@
+package parquet
+
+import "encoding/binary"
+
+// Thrift compact protocol type codes.
+const (
+	ctBoolTrue  = 1
+	ctBoolFalse = 2
+	ctI32       = 5
+	ctI64       = 6
+	ctBinary    = 8
+	ctList      = 9
+	ctStruct    = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol, which Parquet uses for
+// page headers and the file footer. Only what those need is supported.
+type thriftWriter struct {
+	buf     []byte
+	lastIDs []int16 // field ID stack, one per open struct
+	lastID  int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(int64(id))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(v<<1^v>>63)) // zigzag
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, ctI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, ctI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.fieldHeader(id, ctBoolTrue)
+	} else {
+		t.fieldHeader(id, ctBoolFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.fieldHeader(id, ctBinary)
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// listHeader starts a list field of n elements of type elem; write the
+// elements right after.
+func (t *thriftWriter) listHeader(id int16, elem byte, n int) {
+	t.fieldHeader(id, ctList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xf0|elem)
+		t.buf = binary.AppendUvarint(t.buf, uint64(n))
+	}
+}
+
+// i32List writes a list field of i32 elements.
+func (t *thriftWriter) i32List(id int16, vs []int32) {
+	t.listHeader(id, ctI32, len(vs))
+	for _, v := range vs {
+		t.varint(int64(v))
+	}
+}
+
+// stringList writes a list field of string elements.
+func (t *thriftWriter) stringList(id int16, vs []string) {
+	t.listHeader(id, ctBinary, len(vs))
+	for _, v := range vs {
+		t.buf = binary.AppendUvarint(t.buf, uint64(len(v)))
+		t.buf = append(t.buf, v...)
+	}
+}
+
+// structField starts a struct field; close it with end.
+func (t *thriftWriter) structField(id int16) {
+	t.fieldHeader(id, ctStruct)
+	t.begin()
+}
+
+// begin starts a struct, as a list element or the top-level value.
+func (t *thriftWriter) begin() {
+	t.lastIDs = append(t.lastIDs, t.lastID)
+	t.lastID = 0
+}
+
+// end closes the innermost struct.
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, 0) // stop field
+	t.lastID = t.lastIDs[len(t.lastIDs)-1]
+	t.lastIDs = t.lastIDs[:len(t.lastIDs)-1]
+}
@@ -0,0 +1,295 @@
+// Package schedule runs periodic jobs such as backups, VACUUM and
+// retention pruning, and reports when each last ran, when it runs next
+// and how it went, so the jobs can be listed and triggered over the API.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownJob is returned when triggering a job that does not exist.
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning is returned when triggering a job that is running.
+	ErrJobRunning = errors.New("job already running")
+	// ErrNotRunning is returned when triggering a job while the scheduler
+	// is not running.
+	ErrNotRunning = errors.New("scheduler not running")
+)
+
+// Schedule decides when a job runs.
+type Schedule interface {
+	// Next returns the first run time after now.
+	Next(now time.Time) time.Time
+	// String describes the schedule, e.g. "every 1h0m0s".
+	String() string
+}
+
+// Every returns a schedule that runs every d.
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+type interval time.Duration
+
+func (d interval) Next(now time.Time) time.Time { return now.Add(time.Duration(d)) }
+func (d interval) String() string               { return "every " + time.Duration(d).String() }
+
+// Daily returns a schedule that runs once a day at the local time of day
+// at, in "HH:MM" form.
+func Daily(at string) (Schedule, error) {
+	tod, err := time.Parse("15:04", at)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time of day %q", at)
+	}
+	return daily{hour: tod.Hour(), minute: tod.Minute()}, nil
+}
+
+type daily struct{ hour, minute int }
+
+func (d daily) Next(now time.Time) time.Time {
+	now = now.Local()
+	next := time.Date(now.Year(), now.Month(), now.Day(), d.hour, d.minute, 0, 0, time.Local)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func (d daily) String() string { return fmt.Sprintf("daily at %02d:%02d", d.hour, d.minute) }
+
+// Job is a task run on a schedule.
+type Job struct {
+	Name     string
+	Schedule Schedule
+
+	// RunAtStart runs the job StartDelay after the scheduler starts, e.g.
+	// to catch up on a run missed while the app was not running, instead
+	// of waiting for the schedule.
+	RunAtStart bool
+	StartDelay time.Duration
+
+	Run func(ctx context.Context) error
+}
+
+// Status describes a job.
+type Status struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	Running        bool       `json:"running"`
+	NextRun        *time.Time `json:"next_run,omitempty"` // nil while the scheduler is not running
+	LastRun        *time.Time `json:"last_run,omitempty"` // start of the last finished run
+	LastDurationMs int64      `json:"last_duration_ms,omitempty"`
+	LastError      string     `json:"last_error,omitempty"` // empty if the last run succeeded
+}
+
+// job is a Job and its run state.
+type job struct {
+	Job
+	next    time.Time
+	running bool
+	status  Status
+}
+
+// Scheduler runs jobs on their schedules. Runs of a job never overlap: a
+// run that falls due while the previous one is still going is skipped.
+// Different jobs run concurrently.
+type Scheduler struct {
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	jobs    []*job
+	byName  map[string]*job
+	started bool
+	ctx     context.Context // of Run, for triggered jobs; nil while not running
+	wg      sync.WaitGroup  // running jobs
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithLogger sets the logger for job failures.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Scheduler) {
+		if logger != nil {
+			s.logger = logger
+		}
+	}
+}
+
+// New creates a Scheduler without jobs.
+func New(opts ...Option) *Scheduler {
+	s := &Scheduler{
+		logger: slog.Default(),
+		now:    time.Now,
+		byName: make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add registers a job. Jobs must be added before Run.
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" || j.Schedule == nil || j.Run == nil {
+		return errors.New("job needs a name, schedule and run function")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("add job %q: scheduler already started", j.Name)
+	}
+	if _, dup := s.byName[j.Name]; dup {
+		return fmt.Errorf("duplicate job %q", j.Name)
+	}
+	jb := &job{Job: j, status: Status{Name: j.Name, Schedule: j.Schedule.String()}}
+	s.jobs = append(s.jobs, jb)
+	s.byName[j.Name] = jb
+	return nil
+}
+
+// Run runs the jobs as they fall due until ctx is cancelled, then waits
+// for running jobs to return. Jobs with RunAtStart run again each time Run
+// is called.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return errors.New("scheduler already running")
+	}
+	s.started = true
+	s.ctx = ctx
+	now := s.now()
+	for _, j := range s.jobs {
+		if j.RunAtStart {
+			j.next = now.Add(j.StartDelay)
+		} else {
+			j.next = j.Schedule.Next(now)
+		}
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.ctx = nil
+		s.mu.Unlock()
+		s.wg.Wait()
+	}()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		timer.Reset(s.startDue())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// startDue starts the jobs that are due and returns how long until the
+// next one is.
+func (s *Scheduler) startDue() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	var earliest time.Time
+	for _, j := range s.jobs {
+		if !j.next.After(now) {
+			if !j.running {
+				s.start(j)
+			}
+			j.next = j.Schedule.Next(now)
+		}
+		if earliest.IsZero() || j.next.Before(earliest) {
+			earliest = j.next
+		}
+	}
+	if earliest.IsZero() {
+		return time.Hour // no jobs; wait for cancellation
+	}
+	return max(earliest.Sub(now), 0)
+}
+
+// Trigger runs a job now, in the background. The run does not move the
+// job's next scheduled run.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.byName[name]
+	switch {
+	case !ok:
+		return ErrUnknownJob
+	case s.ctx == nil:
+		return ErrNotRunning
+	case j.running:
+		return ErrJobRunning
+	}
+	s.start(j)
+	return nil
+}
+
+// start runs j in a goroutine. s.mu must be held.
+func (s *Scheduler) start(j *job) {
+	j.running = true
+	ctx := s.ctx
+	s.wg.Go(func() {
+		started := s.now()
+		err := s.call(ctx, j)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("scheduled job failed", "job", j.Name, "error", err)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		j.running = false
+		if ctx.Err() != nil {
+			return // interrupted by shutdown; keep the last completed run
+		}
+		j.status.LastRun = &started
+		j.status.LastDurationMs = s.now().Sub(started).Milliseconds()
+		j.status.LastError = ""
+		if err != nil {
+			j.status.LastError = err.Error()
+		}
+	})
+}
+
+// call runs j, turning a panic into an error so one broken job does not
+// take down the app.
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			s.logger.Error("scheduled job panicked", "job", j.Name, "panic", fmt.Sprint(v),
+				"stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return j.Run(ctx)
+}
+
+// Jobs returns the status of each job, in the order they were added.
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		st := j.status
+		st.Running = j.running
+		if s.ctx != nil {
+			next := j.next
+			st.NextRun = &next
+		}
+		items[i] = st
+	}
+	return items
+}
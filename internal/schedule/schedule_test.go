@@ -0,0 +1,138 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDaily(t *testing.T) {
+	sched, err := Daily("03:30")
+	if err != nil {
+		t.Fatalf("Daily: %v", err)
+	}
+	if got := sched.String(); got != "daily at 03:30" {
+		t.Errorf("String = %q", got)
+	}
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local)
+	if got, want := sched.Next(now), time.Date(2024, 1, 16, 3, 30, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", now, got, want)
+	}
+	now = time.Date(2024, 1, 15, 1, 0, 0, 0, time.Local)
+	if got, want := sched.Next(now), time.Date(2024, 1, 15, 3, 30, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", now, got, want)
+	}
+
+	if _, err := Daily("25:00"); err == nil {
+		t.Error("Daily accepted 25:00")
+	}
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// startScheduler runs s until the test ends.
+func startScheduler(t *testing.T, s *Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	waitFor(t, "scheduler start", func() bool { return s.Jobs()[0].NextRun != nil })
+}
+
+func TestScheduler_RunsOnSchedule(t *testing.T) {
+	var runs atomic.Int32
+	s := New()
+	if err := s.Add(Job{Name: "tick", Schedule: Every(5 * time.Millisecond), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := s.Add(Job{Name: "tick", Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }}); err == nil {
+		t.Error("Add accepted a duplicate name")
+	}
+	if st := s.Jobs()[0]; st.NextRun != nil || st.Schedule != "every 5ms" {
+		t.Errorf("status before Run = %+v", st)
+	}
+
+	startScheduler(t, s)
+	waitFor(t, "three runs", func() bool { return runs.Load() >= 3 })
+	st := s.Jobs()[0]
+	if st.LastRun == nil || st.LastError != "" || st.NextRun == nil {
+		t.Errorf("status = %+v", st)
+	}
+	if err := s.Add(Job{Name: "late", Schedule: Every(time.Hour), Run: func(context.Context) error { return nil }}); err == nil {
+		t.Error("Add succeeded after Run")
+	}
+}
+
+func TestScheduler_Trigger(t *testing.T) {
+	release := make(chan struct{})
+	var runs atomic.Int32
+	s := New()
+	s.Add(Job{Name: "backup", Schedule: Every(time.Hour), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		<-release
+		return errors.New("disk full")
+	}})
+	if err := s.Trigger("backup"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Trigger before Run = %v, want ErrNotRunning", err)
+	}
+	startScheduler(t, s)
+
+	if err := s.Trigger("nope"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Trigger unknown = %v, want ErrUnknownJob", err)
+	}
+	if err := s.Trigger("backup"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if !s.Jobs()[0].Running {
+		t.Error("job not running after Trigger")
+	}
+	if err := s.Trigger("backup"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("second Trigger = %v, want ErrJobRunning", err)
+	}
+	close(release)
+	waitFor(t, "run to finish", func() bool { return !s.Jobs()[0].Running })
+	if st := s.Jobs()[0]; st.LastError != "disk full" || st.LastRun == nil {
+		t.Errorf("status = %+v, want the failure recorded", st)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Errorf("ran %d times, want 1", n)
+	}
+}
+
+func TestScheduler_RunAtStartAndPanic(t *testing.T) {
+	s := New()
+	s.Add(Job{Name: "catch_up", Schedule: Every(time.Hour), RunAtStart: true, Run: func(ctx context.Context) error {
+		panic("broken job")
+	}})
+	startScheduler(t, s)
+	waitFor(t, "run at start", func() bool { return s.Jobs()[0].LastRun != nil })
+	st := s.Jobs()[0]
+	if st.LastError != "panic: broken job" {
+		t.Errorf("LastError = %q", st.LastError)
+	}
+	if st.NextRun == nil || time.Until(*st.NextRun) < 59*time.Minute {
+		t.Errorf("NextRun = %v, want an hour from now", st.NextRun)
+	}
+}
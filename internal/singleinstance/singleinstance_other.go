@@ -15,3 +15,8 @@ package singleinstance
 func AcquireLock() (release func(), ok bool, err error) {
 	return func() {}, true, nil
 }
+
+// AcquireProfileLock is a no-op on non-Windows platforms, like AcquireLock.
+func AcquireProfileLock(profile string) (release func(), ok bool, err error) {
+	return AcquireLock()
+}
@@ -19,11 +19,22 @@ import (
 // Usage:
 //
 //	release, ok, err := singleinstance.AcquireLock()
-//	if err != nil { log.Fatal(err) }
-//	if !ok { log.Println("Another instance is running"); return }
+//	if err != nil { slog.Error("single instance check failed", "error", err); os.Exit(1) }
+//	if !ok { slog.Info("another instance is running"); return }
 //	defer release()
 func AcquireLock() (release func(), ok bool, err error) {
-	name, err := windows.UTF16PtrFromString(appinfo.MutexName)
+	return AcquireProfileLock("")
+}
+
+// AcquireProfileLock is like AcquireLock, but only excludes other instances
+// of the same profile (see config.ProfileID). The empty profile is the
+// default one and uses the same lock as AcquireLock.
+func AcquireProfileLock(profile string) (release func(), ok bool, err error) {
+	mutexName := appinfo.MutexName
+	if profile != "" {
+		mutexName += "-" + profile
+	}
+	name, err := windows.UTF16PtrFromString(mutexName)
 	if err != nil {
 		return nil, false, err
 	}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package soundhook
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// players are the command-line audio players tried in order, for
+// development on macOS and Linux.
+var players = []string{"paplay", "aplay"}
+
+func init() {
+	if runtime.GOOS == "darwin" {
+		players = []string{"afplay"}
+	}
+}
+
+// playSound plays the sound file at path with the first audio player found
+// on PATH, returning when it ends or ctx is done.
+func playSound(ctx context.Context, path string) error {
+	for _, name := range players {
+		bin, err := exec.LookPath(name)
+		if err != nil {
+			continue
+		}
+		return exec.CommandContext(ctx, bin, path).Run()
+	}
+	return errors.New("no audio player found")
+}
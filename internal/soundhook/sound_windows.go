@@ -0,0 +1,40 @@
+//go:build windows
+
+package soundhook
+
+import (
+	"context"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procPlaySoundW = windows.NewLazySystemDLL("winmm.dll").NewProc("PlaySoundW")
+
+// PlaySound flags
+const (
+	sndSync      = 0x0000
+	sndNoDefault = 0x0002
+	sndFilename  = 0x00020000
+)
+
+// playSound plays the WAV file at path with PlaySoundW, returning when it
+// ends. ctx is not checked while playing; sounds are expected to be short.
+func playSound(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	if err := procPlaySoundW.Find(); err != nil {
+		return err
+	}
+	ok, _, callErr := procPlaySoundW.Call(uintptr(unsafe.Pointer(p)), 0, sndFilename|sndSync|sndNoDefault)
+	if ok == 0 {
+		return fmt.Errorf("PlaySoundW failed: %w", callErr)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package soundhook gives audio cues for derived events, so desktop users
+// hear when someone joins while they are tabbed out of VRChat. Each hook
+// calls a local URL, such as a soundboard app's trigger, plays a sound file
+// through the OS, or both.
+package soundhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// DefaultTimeout is the HTTP timeout for a single hook call, and the
+// longest a sound may play.
+const DefaultTimeout = 5 * time.Second
+
+// QueueSize is how many events Handle buffers; more are dropped.
+const QueueSize = 64
+
+// MaxEventAge is how old an event may be and still trigger hooks. Older
+// ones come from catching up on a log, when a sound would be meaningless.
+const MaxEventAge = time.Minute
+
+// StatusError is returned when a hook URL answers with a non-2xx status.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("sound hook returned HTTP %d", e.Code)
+	}
+	return fmt.Sprintf("sound hook returned HTTP %d: %s", e.Code, e.Body)
+}
+
+// Runner triggers the hooks matching each derived event.
+type Runner struct {
+	hooks      []config.SoundHook
+	playerTags map[string][]string
+	httpClient *http.Client
+	play       func(ctx context.Context, path string) error
+	logger     *slog.Logger
+	now        func() time.Time
+
+	queue chan *derive.DerivedEvent
+	wg    sync.WaitGroup
+	last  []time.Time // last trigger of each hook; only touched by Run
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithHTTPClient sets the HTTP client used for hook URLs.
+func WithHTTPClient(c *http.Client) Option {
+	return func(r *Runner) { r.httpClient = c }
+}
+
+// WithLogger sets the logger for failed hooks. The default is slog.Default().
+func WithLogger(l *slog.Logger) Option {
+	return func(r *Runner) { r.logger = l }
+}
+
+// New creates a Runner for hooks. playerTags is Config.PlayerTags, used by
+// the hooks' PlayerTags conditions.
+func New(hooks []config.SoundHook, playerTags map[string][]string, opts ...Option) *Runner {
+	r := &Runner{
+		hooks:      hooks,
+		playerTags: playerTags,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		play:       playSound,
+		logger:     slog.Default(),
+		now:        time.Now,
+		queue:      make(chan *derive.DerivedEvent, QueueSize),
+		last:       make([]time.Time, len(hooks)),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Handle queues a derived event for Run without blocking. Events are
+// dropped if the queue is full.
+func (r *Runner) Handle(e *derive.DerivedEvent) {
+	select {
+	case r.queue <- e:
+	default:
+	}
+}
+
+// Run triggers hooks for queued events until ctx is done, then waits for
+// the calls in flight. Hooks run concurrently, so a slow URL does not
+// delay the others.
+func (r *Runner) Run(ctx context.Context) {
+	defer r.wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-r.queue:
+			r.trigger(ctx, e)
+		}
+	}
+}
+
+// trigger starts the hooks matching e that are not cooling down.
+func (r *Runner) trigger(ctx context.Context, e *derive.DerivedEvent) {
+	if e.Event == nil {
+		return
+	}
+	switch e.Type {
+	case derive.DerivedPlayerJoined, derive.DerivedPlayerLeft, derive.DerivedWorldChanged:
+	default:
+		return
+	}
+	now := r.now()
+	if now.Sub(e.Event.Ts) > MaxEventAge {
+		return
+	}
+
+	for i, h := range r.hooks {
+		if !r.matches(h, e.Event) {
+			continue
+		}
+		cooldown := time.Duration(h.CooldownSec) * time.Second
+		if h.CooldownSec == 0 {
+			cooldown = config.DefaultSoundHookCooldownSec * time.Second
+		}
+		if !r.last[i].IsZero() && now.Sub(r.last[i]) < cooldown {
+			continue
+		}
+		r.last[i] = now
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			if err := r.fire(ctx, h, e); err != nil && ctx.Err() == nil {
+				r.logger.Warn("Sound hook failed", "hook", i, "error", err)
+			}
+		}()
+	}
+}
+
+// matches reports whether h is triggered by ev.
+func (r *Runner) matches(h config.SoundHook, ev *event.Event) bool {
+	types := h.EventTypes
+	if len(types) == 0 {
+		types = []string{event.TypePlayerJoin}
+	}
+	if !slices.Contains(types, ev.Type) {
+		return false
+	}
+	if len(h.PlayerTags) == 0 || ev.Type == event.TypeWorldJoin {
+		return true
+	}
+	for _, tag := range h.PlayerTags {
+		for _, p := range r.playerTags[tag] {
+			if (ev.PlayerID != nil && *ev.PlayerID == p) || (ev.PlayerName != nil && *ev.PlayerName == p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fire calls the hook's URL and plays its sound.
+func (r *Runner) fire(ctx context.Context, h config.SoundHook, e *derive.DerivedEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	if h.URL != "" {
+		if err := r.call(ctx, h, e); err != nil {
+			return err
+		}
+	}
+	if h.Sound != "" {
+		if err := r.play(ctx, h.Sound); err != nil {
+			return fmt.Errorf("play %s: %w", h.Sound, err)
+		}
+	}
+	return nil
+}
+
+// call requests the hook's URL, POSTing e as JSON unless the method is GET.
+func (r *Runner) call(ctx context.Context, h config.SoundHook, e *derive.DerivedEvent) error {
+	var req *http.Request
+	var err error
+	if h.Method == http.MethodGet {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, h.URL, nil)
+	} else {
+		body, merr := json.Marshal(e)
+		if merr != nil {
+			return fmt.Errorf("marshal event: %w", merr)
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+}
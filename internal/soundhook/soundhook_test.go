@@ -0,0 +1,129 @@
+package soundhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+	"github.com/graaaaa/vrclog-companion/internal/derive"
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestRunner_Trigger(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		var e struct {
+			Type  string       `json:"type"`
+			Event *event.Event `json:"event"`
+		}
+		if req.Method == http.MethodPost {
+			if err := json.Unmarshal(body, &e); err != nil || e.Type == "" || e.Event == nil {
+				t.Errorf("POST body = %s", body)
+			}
+		}
+		mu.Lock()
+		calls = append(calls, req.Method+" "+req.URL.Path)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	now := time.Date(2024, 6, 15, 21, 0, 0, 0, time.UTC)
+	var sounds []string
+	r := New([]config.SoundHook{
+		{URL: srv.URL + "/join"},
+		{EventTypes: []string{event.TypePlayerJoin, event.TypePlayerLeft}, PlayerTags: []string{"friend"}, URL: srv.URL + "/friend", Method: "GET", CooldownSec: 60},
+		{EventTypes: []string{event.TypeWorldJoin}, Sound: "world.wav"},
+	}, map[string][]string{"friend": {"usr_alice", "Bob"}})
+	r.now = func() time.Time { return now }
+	r.play = func(ctx context.Context, path string) error {
+		mu.Lock()
+		sounds = append(sounds, path)
+		mu.Unlock()
+		return nil
+	}
+
+	ev := func(typ derive.DerivedEventType, eventType, id, name string, ts time.Time) *derive.DerivedEvent {
+		e := &event.Event{Type: eventType, Ts: ts}
+		if id != "" {
+			e.PlayerID = strPtr(id)
+		}
+		if name != "" {
+			e.PlayerName = strPtr(name)
+		}
+		return &derive.DerivedEvent{Type: typ, Event: e}
+	}
+	steps := []struct {
+		name    string
+		advance time.Duration
+		event   *derive.DerivedEvent
+		want    []string
+	}{
+		{"friend joins", 0, ev(derive.DerivedPlayerJoined, event.TypePlayerJoin, "usr_alice", "Alice", now), []string{"POST /join", "GET /friend"}},
+		{"join cooling down", time.Second, ev(derive.DerivedPlayerJoined, event.TypePlayerJoin, "usr_carol", "Carol", now), nil},
+		{"stranger joins", 10 * time.Second, ev(derive.DerivedPlayerJoined, event.TypePlayerJoin, "usr_carol", "Carol", now.Add(10*time.Second)), []string{"POST /join"}},
+		{"friend leaves cooling down", 10 * time.Second, ev(derive.DerivedPlayerLeft, event.TypePlayerLeft, "", "Bob", now.Add(20*time.Second)), nil},
+		{"friend leaves", time.Minute, ev(derive.DerivedPlayerLeft, event.TypePlayerLeft, "", "Bob", now.Add(80*time.Second)), []string{"GET /friend"}},
+		{"stale join", time.Minute, ev(derive.DerivedPlayerJoined, event.TypePlayerJoin, "usr_dave", "Dave", now), nil},
+		{"world change", 0, ev(derive.DerivedWorldChanged, event.TypeWorldJoin, "", "", now.Add(140*time.Second)), []string{"sound world.wav"}},
+		{"milestone", 0, ev(derive.DerivedMilestone, event.TypePlayerJoin, "usr_erin", "Erin", now.Add(140*time.Second)), nil},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		calls, sounds = nil, nil
+		r.trigger(t.Context(), step.event)
+		r.wg.Wait()
+
+		got := map[string]bool{}
+		for _, c := range calls {
+			got[c] = true
+		}
+		for _, s := range sounds {
+			got["sound "+s] = true
+		}
+		if len(got) != len(step.want) {
+			t.Errorf("%s: triggered %v, want %v", step.name, got, step.want)
+			continue
+		}
+		for _, w := range step.want {
+			if !got[w] {
+				t.Errorf("%s: triggered %v, want %v", step.name, got, step.want)
+			}
+		}
+	}
+}
+
+func TestRunner_StatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "unknown clip", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	r := New(nil, nil)
+	e := &derive.DerivedEvent{Type: derive.DerivedPlayerJoined, Event: &event.Event{Type: event.TypePlayerJoin, Ts: time.Now()}}
+	err := r.fire(t.Context(), config.SoundHook{URL: srv.URL}, e)
+	se, ok := err.(*StatusError)
+	if !ok || se.Code != http.StatusNotFound || se.Body != "unknown clip" {
+		t.Errorf("fire() error = %v, want StatusError 404", err)
+	}
+}
+
+func TestRunner_HandleDropsWhenFull(t *testing.T) {
+	r := New(nil, nil)
+	e := &derive.DerivedEvent{Type: derive.DerivedPlayerJoined}
+	for range QueueSize + 10 {
+		r.Handle(e) // must not block
+	}
+	if len(r.queue) != QueueSize {
+		t.Errorf("queue length = %d, want %d", len(r.queue), QueueSize)
+	}
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Groupings of AggregateEvents.
+const (
+	GroupByType   = "type"
+	GroupByPlayer = "player"
+	GroupByWorld  = "world"
+)
+
+// IsValidGroupBy reports whether g is a grouping of AggregateEvents.
+func IsValidGroupBy(g string) bool {
+	return g == GroupByType || g == GroupByPlayer || g == GroupByWorld
+}
+
+// EventGroup counts the events of one group.
+type EventGroup struct {
+	Key   string `json:"key"`            // event type, player ID (the normalized name without one) or world ID
+	Name  string `json:"name,omitempty"` // latest player or world name
+	Count int64  `json:"count"`
+}
+
+// AggregateResult is the result of AggregateEvents.
+type AggregateResult struct {
+	GroupBy string       `json:"group_by"`
+	Total   int64        `json:"total"`  // events in all groups, including those past the limit
+	Groups  int          `json:"groups"` // number of groups, including those past the limit
+	Items   []EventGroup `json:"items"`  // most events first
+}
+
+// AggregateEvents counts the events matching the filter's conditions by
+// type, player or world in one query. Grouping by player only counts
+// events naming a player, and by world only those carrying a world ID:
+// the world joins themselves and the screenshots and videos stamped with
+// the world they were taken in.
+// Limit caps the groups returned (defaultLimit if <= 0, at most maxLimit);
+// Cursor and Order are ignored.
+func (s *Store) AggregateEvents(ctx context.Context, f QueryFilter, groupBy string) (AggregateResult, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	// The bare name column of the player grouping comes from the row with
+	// MAX(ts), so it is the latest name
+	var key, name, where string
+	switch groupBy {
+	case GroupByType:
+		key, name = "type", "NULL"
+	case GroupByPlayer:
+		key, name, where = "COALESCE(player_id, normalized_name)", "player_name", " AND (player_id IS NOT NULL OR normalized_name IS NOT NULL)"
+	case GroupByWorld:
+		key, name, where = "world_id", "NULL", " AND world_id IS NOT NULL"
+	default:
+		return AggregateResult{}, fmt.Errorf("invalid group_by %q", groupBy)
+	}
+	cond, args := f.conditions()
+
+	rows, err := s.query(ctx, `
+		SELECT `+key+` AS k, `+name+`, COUNT(*) AS n, MAX(ts)
+		FROM events
+		WHERE 1=1`+where+cond+`
+		GROUP BY k
+		ORDER BY n DESC, k ASC
+	`, args...)
+	if err != nil {
+		return AggregateResult{}, fmt.Errorf("aggregate events: %w", err)
+	}
+	defer rows.Close()
+
+	res := AggregateResult{GroupBy: groupBy, Items: []EventGroup{}}
+	for rows.Next() {
+		var (
+			g      EventGroup
+			n      sql.NullString
+			latest string
+		)
+		if err := rows.Scan(&g.Key, &n, &g.Count, &latest); err != nil {
+			return AggregateResult{}, fmt.Errorf("scan group: %w", err)
+		}
+		res.Total += g.Count
+		res.Groups++
+		if len(res.Items) < limit {
+			g.Name = n.String
+			res.Items = append(res.Items, g)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return AggregateResult{}, fmt.Errorf("rows error: %w", err)
+	}
+
+	// World join rows carry the ID but not the name, so the latest name is
+	// looked up for the groups returned
+	if groupBy == GroupByWorld {
+		for i := range res.Items {
+			if res.Items[i].Name, err = s.latestWorldName(ctx, res.Items[i].Key); err != nil {
+				return AggregateResult{}, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// latestWorldName returns the name logged for the latest visit to the
+// world, or else the name stamped on its latest screenshot or video.
+// Returns "" if none was logged.
+func (s *Store) latestWorldName(ctx context.Context, worldID string) (string, error) {
+	var (
+		join  event.Event
+		tsStr string
+		name  sql.NullString
+	)
+	err := s.queryRow(ctx, `
+		SELECT id, ts, world_name FROM events
+		WHERE type = ? AND world_id = ?
+		ORDER BY ts DESC, id DESC LIMIT 1
+	`, event.TypeWorldJoin, worldID).Scan(&join.ID, &tsStr, &name)
+	switch {
+	case err == nil:
+		if join.Ts, err = time.Parse(TimeFormat, tsStr); err != nil {
+			return "", fmt.Errorf("parse ts %q: %w", tsStr, err)
+		}
+		if name.Valid {
+			join.WorldName = &name.String
+		}
+		n, err := s.WorldNameFor(ctx, &join)
+		if err != nil || n != "" {
+			return n, err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return "", fmt.Errorf("query latest visit: %w", err)
+	}
+
+	var n string
+	err = s.queryRow(ctx, `
+		SELECT world_name FROM events
+		WHERE world_id = ? AND world_name IS NOT NULL
+		ORDER BY ts DESC, id DESC LIMIT 1
+	`, worldID).Scan(&n)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("query world name: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestAggregateEvents(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	base := time.Date(2024, 6, 15, 20, 0, 0, 0, time.UTC)
+	events := []struct {
+		typ, name, id, world, worldName string
+	}{
+		{event.TypeWorldJoin, "", "", "wrld_a", ""},
+		{event.TypeWorldJoin, "", "", "", "Old Name"},
+		{event.TypePlayerJoin, "Alice", "usr_a", "", ""},
+		{event.TypePlayerJoin, "Bob", "", "", ""},
+		{event.TypePlayerLeft, "Alice", "usr_a", "", ""},
+		{event.TypeWorldJoin, "", "", "wrld_b", ""},
+		{event.TypeWorldJoin, "", "", "", "Club B"},
+		{event.TypePlayerJoin, "Alice (new)", "usr_a", "", ""},
+		{event.TypeWorldJoin, "", "", "wrld_a", ""},
+		{event.TypeWorldJoin, "", "", "", "The Black Cat"},
+	}
+	for i, e := range events {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		ev := &event.Event{Ts: ts, Type: e.typ, DedupeKey: fmt.Sprintf("agg-%d", i), IngestedAt: ts}
+		if e.name != "" {
+			ev.PlayerName = event.StringPtr(e.name)
+		}
+		if e.id != "" {
+			ev.PlayerID = event.StringPtr(e.id)
+		}
+		if e.world != "" {
+			ev.WorldID = event.StringPtr(e.world)
+		}
+		if e.worldName != "" {
+			ev.WorldName = event.StringPtr(e.worldName)
+		}
+		if _, _, err := st.InsertEvent(ctx, ev); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	groups := func(res AggregateResult) string {
+		var s string
+		for _, g := range res.Items {
+			s += fmt.Sprintf("%s/%s=%d ", g.Key, g.Name, g.Count)
+		}
+		return s
+	}
+	tests := []struct {
+		groupBy string
+		filter  QueryFilter
+		want    string
+		total   int64
+		nGroups int
+	}{
+		{GroupByType, QueryFilter{}, "world_join/=6 player_join/=3 player_left/=1 ", 10, 3},
+		{GroupByPlayer, QueryFilter{}, "usr_a/Alice (new)=3 bob/Bob=1 ", 4, 2},
+		{GroupByPlayer, QueryFilter{Limit: 1}, "usr_a/Alice (new)=3 ", 4, 2},
+		{GroupByWorld, QueryFilter{}, "wrld_a/The Black Cat=2 wrld_b/Club B=1 ", 3, 2},
+		{GroupByWorld, QueryFilter{Type: event.StringPtr(event.TypePlayerJoin)}, "", 0, 0},
+	}
+	for _, tt := range tests {
+		res, err := st.AggregateEvents(ctx, tt.filter, tt.groupBy)
+		if err != nil {
+			t.Fatalf("AggregateEvents(%s): %v", tt.groupBy, err)
+		}
+		if got := groups(res); got != tt.want || res.Total != tt.total || res.Groups != tt.nGroups || res.GroupBy != tt.groupBy {
+			t.Errorf("AggregateEvents(%s, %+v) = %q total %d groups %d, want %q total %d groups %d",
+				tt.groupBy, tt.filter, got, res.Total, res.Groups, tt.want, tt.total, tt.nGroups)
+		}
+	}
+
+	if _, err := st.AggregateEvents(ctx, QueryFilter{}, "region"); err == nil {
+		t.Error("AggregateEvents accepted an unknown grouping")
+	}
+}
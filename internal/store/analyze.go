@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// AnalyzeThreshold is the number of inserted events after which
+// AnalyzeIfNeeded refreshes the query planner statistics.
+const AnalyzeThreshold = 10000
+
+// analysisLimit bounds the rows ANALYZE examines per index, so it stays
+// fast on large databases. See PRAGMA analysis_limit.
+const analysisLimit = 1000
+
+// Analyze refreshes the statistics the query planner uses to choose
+// indexes. It samples at most analysisLimit rows per index.
+func (s *Store) Analyze(ctx context.Context) error {
+	// analysis_limit is per connection, so both statements must share one
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("analyze: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA analysis_limit = %d", analysisLimit)); err != nil {
+		return fmt.Errorf("analyze: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "ANALYZE"); err != nil {
+		return fmt.Errorf("analyze: %w", err)
+	}
+	s.insertsSinceAnalyze.Store(0)
+	return nil
+}
+
+// AnalyzeIfNeeded runs Analyze if at least AnalyzeThreshold events were
+// inserted since the last run. Returns true if it ran.
+func (s *Store) AnalyzeIfNeeded(ctx context.Context) (bool, error) {
+	n := s.insertsSinceAnalyze.Load()
+	if n < AnalyzeThreshold {
+		return false, nil
+	}
+	if err := s.Analyze(ctx); err != nil {
+		return false, err
+	}
+	slog.Info("ANALYZE completed", "inserted_events", n)
+	return true, nil
+}
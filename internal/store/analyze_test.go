@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestAnalyzeIfNeeded(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	// New player indexes request an ANALYZE
+	ran, err := st.AnalyzeIfNeeded(ctx)
+	if err != nil || !ran {
+		t.Fatalf("AnalyzeIfNeeded after creating indexes = %v, %v; want true", ran, err)
+	}
+	ran, err = st.AnalyzeIfNeeded(ctx)
+	if err != nil || ran {
+		t.Fatalf("second AnalyzeIfNeeded = %v, %v; want false", ran, err)
+	}
+
+	now := time.Now().UTC()
+	if _, _, err := st.InsertEvent(ctx, &event.Event{
+		Ts: now, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"),
+		DedupeKey: "k1", IngestedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := st.insertsSinceAnalyze.Load(); got != 1 {
+		t.Errorf("inserts since analyze = %d, want 1", got)
+	}
+}
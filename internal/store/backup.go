@@ -0,0 +1,235 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// BackupTo writes a consistent copy of the database to path, which must not
+// exist yet. Safe to call while the database is in use.
+func (s *Store) BackupTo(ctx context.Context, path string) error {
+	if _, err := s.db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("backup database: %w", err)
+	}
+	return nil
+}
+
+// ExportFormat identifies the header line of a JSONL export.
+const ExportFormat = "vrclog-events"
+
+// metadataKeyExportCursor prefixes the metadata keys of export cursors.
+const metadataKeyExportCursor = "export_cursor:"
+
+// ExportHeader is the first line of a JSONL export.
+type ExportHeader struct {
+	Format     string    `json:"format"` // ExportFormat
+	Version    int       `json:"version"`
+	Since      string    `json:"since,omitempty"` // change log position an incremental export starts after
+	Cursor     string    `json:"cursor"`          // change log position of the export; pass as since_cursor for later changes
+	Events     int       `json:"events"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// ExportWriter writes an export in some file format. ExportEvents calls
+// WriteHeader once, then WriteEvent for each event with the text of its
+// notes, oldest first, then Close.
+type ExportWriter interface {
+	WriteHeader(h ExportHeader) error
+	WriteEvent(e *event.Event, notes []string) error
+	Close() error
+}
+
+// exportRow is a line of a JSONL export: an event and its notes.
+type exportRow struct {
+	*event.Event
+	Notes []string `json:"notes,omitempty"`
+}
+
+// ExportOption configures an ExportWriter.
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	filter func(*event.Event) *event.Event
+}
+
+// WithEventFilter has the writer write filter(e) instead of each event e,
+// e.g. to redact fields for clients that may not see them. filter must
+// not modify e.
+func WithEventFilter(filter func(*event.Event) *event.Event) ExportOption {
+	return func(o *exportOptions) { o.filter = filter }
+}
+
+func newExportOptions(opts []ExportOption) exportOptions {
+	var o exportOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// apply returns e after the filter, if any.
+func (o exportOptions) apply(e *event.Event) *event.Event {
+	if o.filter == nil {
+		return e
+	}
+	return o.filter(e)
+}
+
+// jsonlExportWriter writes the header and each event as one JSON object
+// per line.
+type jsonlExportWriter struct {
+	enc *json.Encoder
+	exportOptions
+}
+
+// NewJSONLExportWriter returns an ExportWriter for JSONL.
+func NewJSONLExportWriter(w io.Writer, opts ...ExportOption) ExportWriter {
+	return &jsonlExportWriter{enc: json.NewEncoder(w), exportOptions: newExportOptions(opts)}
+}
+
+func (j *jsonlExportWriter) WriteHeader(h ExportHeader) error {
+	return j.enc.Encode(h)
+}
+
+func (j *jsonlExportWriter) WriteEvent(e *event.Event, notes []string) error {
+	return j.enc.Encode(exportRow{Event: j.apply(e), Notes: notes})
+}
+
+func (j *jsonlExportWriter) Close() error {
+	return nil
+}
+
+// ExportJSONL writes an ExportHeader line and then every event to w as one
+// JSON object per line, oldest first, and returns how many events were
+// written. See ExportEvents.
+func (s *Store) ExportJSONL(ctx context.Context, w io.Writer) (int, error) {
+	header, err := s.ExportEvents(ctx, NewJSONLExportWriter(w), "")
+	return header.Events, err
+}
+
+// ExportEvents writes the ExportHeader and then events to ew, oldest
+// first, and closes ew once all were written. With an empty since it
+// writes every event; otherwise only the events inserted or updated after
+// that change log cursor (deletions are left out). It reads inside one
+// transaction, so the export is a snapshot: events ingested meanwhile are
+// neither half included nor counted twice, and the header cursor is
+// exactly where the snapshot ends. Notes do not count as changes, so an
+// incremental export leaves out events noted after they were exported.
+// Returns the header with the number of events written.
+func (s *Store) ExportEvents(ctx context.Context, ew ExportWriter, since string) (ExportHeader, error) {
+	header := ExportHeader{Format: ExportFormat, Version: 1, Since: since, ExportedAt: time.Now().UTC()}
+	sinceSeq, err := decodeChangeCursor(since)
+	if err != nil {
+		return header, err
+	}
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return header, fmt.Errorf("begin export: %w", err)
+	}
+	defer tx.Rollback()
+
+	where := ""
+	var args []any
+	if since != "" {
+		where = ` WHERE id IN (SELECT event_id FROM event_changes WHERE seq > ? AND op = '` + ChangeUpsert + `')`
+		args = append(args, sinceSeq)
+	}
+	var seq int64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COALESCE(MAX(seq), 0) FROM event_changes), (SELECT COUNT(*) FROM events`+where+`)
+	`, args...).Scan(&seq, &header.Events); err != nil {
+		return header, fmt.Errorf("read export position: %w", err)
+	}
+	header.Cursor = EncodeChangeCursor(seq)
+	notes, err := exportNotes(ctx, tx, where, args)
+	if err != nil {
+		return header, err
+	}
+	if err := ew.WriteHeader(header); err != nil {
+		return header, fmt.Errorf("write export header: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+eventColumns+` FROM events`+where+` ORDER BY ts ASC, id ASC`, args...)
+	if err != nil {
+		return header, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		row, err := scanEventRow(rows)
+		if err != nil {
+			return header, fmt.Errorf("scan event: %w", err)
+		}
+		e, err := row.toEvent()
+		if err != nil {
+			return header, fmt.Errorf("event %d: %w", row.ID, err)
+		}
+		if err := ew.WriteEvent(e, notes[row.ID]); err != nil {
+			return header, fmt.Errorf("write event %d: %w", row.ID, err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return header, fmt.Errorf("rows error: %w", err)
+	}
+	header.Events = n
+	if err := ew.Close(); err != nil {
+		return header, fmt.Errorf("finish export: %w", err)
+	}
+	return header, nil
+}
+
+// exportNotes returns the text of the notes on the events an export
+// selects with where and args, by event ID, oldest first.
+func exportNotes(ctx context.Context, tx *sql.Tx, where string, args []any) (map[int64][]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT event_id, text FROM notes WHERE event_id IN (SELECT id FROM events`+where+`) ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			return nil, fmt.Errorf("scan note: %w", err)
+		}
+		notes[id] = append(notes[id], text)
+	}
+	return notes, rows.Err()
+}
+
+// ExportCursor returns the cursor of the last successful export to
+// destination, or "" if there was none.
+func (s *Store) ExportCursor(ctx context.Context, destination string) (string, error) {
+	var cursor string
+	err := s.queryRow(ctx, `SELECT value FROM metadata WHERE key = ?`, metadataKeyExportCursor+destination).Scan(&cursor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get export cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+// SetExportCursor records cursor as the position of the last successful
+// export to destination.
+func (s *Store) SetExportCursor(ctx context.Context, destination, cursor string) error {
+	if _, err := s.exec(ctx, `INSERT OR REPLACE INTO metadata (key, value) VALUES (?, ?)`,
+		metadataKeyExportCursor+destination, cursor); err != nil {
+		return fmt.Errorf("set export cursor: %w", err)
+	}
+	return nil
+}
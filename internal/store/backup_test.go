@@ -0,0 +1,241 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestBackupTo(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	st := openTestStore(t)
+	defer st.Close()
+	insertTestEvent(t, st, base, event.TypePlayerJoin, "Alice", "k1")
+
+	path := filepath.Join(t.TempDir(), "backup.sqlite")
+	if err := st.BackupTo(ctx, path); err != nil {
+		t.Fatalf("BackupTo: %v", err)
+	}
+
+	backup, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open backup: %v", err)
+	}
+	defer backup.Close()
+	res, err := backup.QueryEvents(ctx, QueryFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(res.Items) != 1 || deref(res.Items[0].PlayerName) != "Alice" {
+		t.Errorf("backup events = %+v", res.Items)
+	}
+}
+
+// firstWriteHook calls hook before the first write to w.
+type firstWriteHook struct {
+	w    *bytes.Buffer
+	hook func()
+}
+
+func (h *firstWriteHook) Write(p []byte) (int, error) {
+	if h.hook != nil {
+		h.hook()
+		h.hook = nil
+	}
+	return h.w.Write(p)
+}
+
+func TestExportJSONL(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	st := openTestStore(t)
+	defer st.Close()
+	insertTestEvent(t, st, base.Add(time.Minute), event.TypePlayerLeft, "Alice", "k2")
+	insertTestEvent(t, st, base, event.TypePlayerJoin, "Alice", "k1")
+
+	// An event ingested while the export runs is not part of it
+	var buf bytes.Buffer
+	w := &firstWriteHook{w: &buf, hook: func() {
+		insertTestEvent(t, st, base.Add(-time.Minute), event.TypeWorldJoin, "", "k3")
+	}}
+	n, err := st.ExportJSONL(ctx, w)
+	if err != nil {
+		t.Fatalf("ExportJSONL: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("n = %d, want 2", n)
+	}
+
+	sc := bufio.NewScanner(&buf)
+	if !sc.Scan() {
+		t.Fatal("empty export")
+	}
+	var header ExportHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil {
+		t.Fatalf("header %q: %v", sc.Text(), err)
+	}
+	if header.Format != ExportFormat || header.Events != 2 {
+		t.Errorf("header = %+v, want %s with 2 events", header, ExportFormat)
+	}
+
+	var types []string
+	for sc.Scan() {
+		var e event.Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		types = append(types, e.Type)
+	}
+	if len(types) != 2 || types[0] != event.TypePlayerJoin {
+		t.Errorf("types = %v, want oldest first", types)
+	}
+
+	// The header cursor picks up exactly what the export missed
+	changes, err := st.EventChanges(ctx, header.Cursor, 0)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+	if len(changes.Items) != 1 || changes.Items[0].Event == nil || changes.Items[0].Event.DedupeKey != "k3" {
+		t.Errorf("changes after export = %+v, want only k3", changes.Items)
+	}
+}
+
+func TestExportEvents_Notes(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	st := openTestStore(t)
+	defer st.Close()
+	insertTestEvent(t, st, base, event.TypePlayerJoin, "Alice", "k1")
+	insertTestEvent(t, st, base.Add(time.Minute), event.TypePlayerJoin, "Bob", "k2")
+	var id int64
+	if err := st.db.QueryRowContext(ctx, `SELECT id FROM events WHERE dedupe_key = 'k1'`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"met at the beach", "added as friend"} {
+		if _, err := st.AddNote(ctx, id, text); err != nil {
+			t.Fatalf("AddNote: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := st.ExportEvents(ctx, NewJSONLExportWriter(&buf), ""); err != nil {
+		t.Fatalf("ExportEvents: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("export has %d lines, want header and 2 events", len(lines))
+	}
+	var alice, bob struct {
+		PlayerName string   `json:"player_name"`
+		Notes      []string `json:"notes"`
+	}
+	json.Unmarshal([]byte(lines[1]), &alice)
+	json.Unmarshal([]byte(lines[2]), &bob)
+	if alice.PlayerName != "Alice" || !slices.Equal(alice.Notes, []string{"met at the beach", "added as friend"}) {
+		t.Errorf("noted event = %s", lines[1])
+	}
+	if bob.PlayerName != "Bob" || strings.Contains(lines[2], `"notes"`) {
+		t.Errorf("event without notes = %s", lines[2])
+	}
+
+	// Parquet has them in a column of its own
+	buf.Reset()
+	if _, err := st.ExportEvents(ctx, NewParquetExportWriter(&buf), ""); err != nil {
+		t.Fatalf("Parquet export: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("notes")) {
+		t.Error("Parquet export lacks the notes column")
+	}
+}
+
+func TestExportEvents_Incremental(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	st := openTestStore(t)
+	defer st.Close()
+	insertTestEvent(t, st, base, event.TypePlayerJoin, "Alice", "k1")
+
+	var buf bytes.Buffer
+	full, err := st.ExportEvents(ctx, NewJSONLExportWriter(&buf), "")
+	if err != nil || full.Events != 1 {
+		t.Fatalf("full export = %+v, %v; want 1 event", full, err)
+	}
+	if err := st.SetExportCursor(ctx, "warehouse", full.Cursor); err != nil {
+		t.Fatalf("SetExportCursor: %v", err)
+	}
+
+	insertTestEvent(t, st, base.Add(time.Minute), event.TypePlayerJoin, "Bob", "k2")
+	since, err := st.ExportCursor(ctx, "warehouse")
+	if err != nil || since != full.Cursor {
+		t.Fatalf("ExportCursor = %q, %v; want %q", since, err, full.Cursor)
+	}
+	if other, _ := st.ExportCursor(ctx, "other"); other != "" {
+		t.Errorf("cursor of a new destination = %q, want none", other)
+	}
+
+	buf.Reset()
+	inc, err := st.ExportEvents(ctx, NewJSONLExportWriter(&buf), since)
+	if err != nil {
+		t.Fatalf("incremental export: %v", err)
+	}
+	if inc.Events != 1 || inc.Since != since || inc.Cursor == since {
+		t.Errorf("incremental header = %+v, want 1 event after %q", inc, since)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"Bob"`)) || bytes.Contains(buf.Bytes(), []byte(`"Alice"`)) {
+		t.Errorf("incremental export = %s, want only Bob", buf.String())
+	}
+
+	if _, err := st.ExportEvents(ctx, NewJSONLExportWriter(&buf), "bogus"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestExportEvents_Parquet(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	st := openTestStore(t)
+	defer st.Close()
+	insertTestEvent(t, st, base, event.TypePlayerJoin, "Alice", "k1")
+	insertTestEvent(t, st, base.Add(time.Minute), event.TypeWorldJoin, "", "k2")
+
+	var buf bytes.Buffer
+	filtered := 0
+	filter := func(e *event.Event) *event.Event {
+		filtered++
+		c := *e
+		c.PlayerID = nil
+		return &c
+	}
+	header, err := st.ExportEvents(ctx, NewParquetExportWriter(&buf, WithEventFilter(filter)), "")
+	if err != nil || header.Events != 2 {
+		t.Fatalf("ExportEvents = %+v, %v; want 2 events", header, err)
+	}
+	if filtered != 2 {
+		t.Errorf("filter called for %d events, want 2", filtered)
+	}
+	b := buf.Bytes()
+	if !bytes.HasPrefix(b, []byte("PAR1")) || !bytes.HasSuffix(b, []byte("PAR1")) {
+		t.Fatalf("export is not a Parquet file: % x", b)
+	}
+	// The header travels in the footer's key/value metadata
+	for _, want := range []string{ParquetHeaderKey, header.Cursor, "player_name", "ingested_at"} {
+		if !bytes.Contains(b, []byte(want)) {
+			t.Errorf("footer lacks %q", want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Bookmark is a saved instance the user may want to rejoin.
+type Bookmark struct {
+	ID         int64     `json:"id"`
+	WorldID    string    `json:"world_id"`
+	InstanceID string    `json:"instance_id"`
+	WorldName  string    `json:"world_name,omitempty"`
+	Label      string    `json:"label,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SaveBookmark stores a bookmark. Bookmarking the same instance again
+// updates its label and world name instead of creating a duplicate.
+func (s *Store) SaveBookmark(ctx context.Context, b Bookmark) (*Bookmark, error) {
+	now := time.Now().UTC()
+	var (
+		id        int64
+		createdAt string
+	)
+	err := s.queryRow(ctx, `
+		INSERT INTO bookmarks (world_id, instance_id, world_name, label, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(world_id, instance_id) DO UPDATE SET
+			world_name = COALESCE(excluded.world_name, bookmarks.world_name),
+			label = excluded.label
+		RETURNING id, created_at
+	`, b.WorldID, b.InstanceID, nullIfEmpty(b.WorldName), b.Label, now.Format(TimeFormat)).Scan(&id, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("save bookmark: %w", err)
+	}
+
+	b.ID = id
+	if b.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, fmt.Errorf("parse created_at %q: %w", createdAt, err)
+	}
+	return &b, nil
+}
+
+// ListBookmarks returns all bookmarks, newest first.
+func (s *Store) ListBookmarks(ctx context.Context) ([]Bookmark, error) {
+	rows, err := s.query(ctx, `
+		SELECT id, world_id, instance_id, world_name, label, created_at
+		FROM bookmarks
+		ORDER BY created_at DESC, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query bookmarks: %w", err)
+	}
+	defer rows.Close()
+
+	bookmarks := []Bookmark{}
+	for rows.Next() {
+		var (
+			b         Bookmark
+			worldName sql.NullString
+			createdAt string
+		)
+		if err := rows.Scan(&b.ID, &b.WorldID, &b.InstanceID, &worldName, &b.Label, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan bookmark: %w", err)
+		}
+		b.WorldName = worldName.String
+		if b.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+			return nil, fmt.Errorf("parse created_at %q: %w", createdAt, err)
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return bookmarks, nil
+}
+
+// DeleteBookmark removes a bookmark. Returns ErrNotFound if it does not exist.
+func (s *Store) DeleteBookmark(ctx context.Context, id int64) error {
+	result, err := s.exec(ctx, `DELETE FROM bookmarks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete bookmark: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// WorldNameFor returns the world name logged for the visit that started with
+// world_join event e. VRChat logs the name on a separate "Entering Room" line
+// shortly after the "Joining" line that carries the IDs. Returns "" if no
+// name was logged before the next visit.
+func (s *Store) WorldNameFor(ctx context.Context, e *event.Event) (string, error) {
+	if e.WorldName != nil {
+		return *e.WorldName, nil
+	}
+	tsStr := e.Ts.UTC().Format(TimeFormat)
+
+	var name string
+	err := s.queryRow(ctx, `
+		SELECT n.world_name FROM events n
+		WHERE n.type = ? AND n.world_name IS NOT NULL
+		  AND (n.ts > ? OR (n.ts = ? AND n.id > ?))
+		  AND NOT EXISTS (
+			SELECT 1 FROM events x
+			WHERE x.type = ? AND x.world_id IS NOT NULL
+			  AND (x.ts > ? OR (x.ts = ? AND x.id > ?))
+			  AND (x.ts < n.ts OR (x.ts = n.ts AND x.id < n.id))
+		  )
+		ORDER BY n.ts ASC, n.id ASC
+		LIMIT 1
+	`, event.TypeWorldJoin, tsStr, tsStr, e.ID,
+		event.TypeWorldJoin, tsStr, tsStr, e.ID).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("find world name: %w", err)
+	}
+	return name, nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestBookmarks_SaveListDelete(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	b, err := st.SaveBookmark(ctx, Bookmark{WorldID: "wrld_a", InstanceID: "1~region(jp)", WorldName: "World A", Label: "fun"})
+	if err != nil {
+		t.Fatalf("SaveBookmark: %v", err)
+	}
+
+	// Saving the same instance again updates the label
+	again, err := st.SaveBookmark(ctx, Bookmark{WorldID: "wrld_a", InstanceID: "1~region(jp)", Label: "very fun"})
+	if err != nil {
+		t.Fatalf("SaveBookmark again: %v", err)
+	}
+	if again.ID != b.ID {
+		t.Errorf("re-save ID = %d, want %d", again.ID, b.ID)
+	}
+
+	list, err := st.ListBookmarks(ctx)
+	if err != nil {
+		t.Fatalf("ListBookmarks: %v", err)
+	}
+	if len(list) != 1 || list[0].Label != "very fun" || list[0].WorldName != "World A" {
+		t.Errorf("ListBookmarks = %+v", list)
+	}
+
+	if err := st.DeleteBookmark(ctx, b.ID); err != nil {
+		t.Fatalf("DeleteBookmark: %v", err)
+	}
+	if err := st.DeleteBookmark(ctx, b.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteBookmark: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWorldNameFor(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	insert := func(e *event.Event) *event.Event {
+		t.Helper()
+		e.Type = event.TypeWorldJoin
+		e.IngestedAt = base
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		return e
+	}
+
+	first := insert(&event.Event{Ts: base, WorldID: event.StringPtr("wrld_a"), InstanceID: event.StringPtr("1"), DedupeKey: "a1"})
+	insert(&event.Event{Ts: base.Add(time.Second), WorldName: event.StringPtr("World A"), DedupeKey: "a2"})
+	// Second visit has no name line
+	second := insert(&event.Event{Ts: base.Add(time.Hour), WorldID: event.StringPtr("wrld_b"), InstanceID: event.StringPtr("2"), DedupeKey: "b1"})
+
+	if name, err := st.WorldNameFor(ctx, first); err != nil || name != "World A" {
+		t.Errorf("WorldNameFor(first) = %q, %v; want World A", name, err)
+	}
+	if name, err := st.WorldNameFor(ctx, second); err != nil || name != "" {
+		t.Errorf("WorldNameFor(second) = %q, %v; want empty", name, err)
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Change operations in the event change log.
+const (
+	ChangeUpsert = "upsert" // event inserted or modified; Event holds the current row
+	ChangeDelete = "delete" // tombstone; only ID is set
+)
+
+// EventChange is one entry of the event change log.
+type EventChange struct {
+	Op    string       `json:"op"`
+	ID    int64        `json:"id"`
+	Event *event.Event `json:"event,omitempty"`
+}
+
+// ChangesResult contains a page of the event change log.
+type ChangesResult struct {
+	Items      []EventChange
+	NextCursor string // pass back as since_cursor; never empty
+	HasMore    bool
+}
+
+// EncodeChangeCursor creates an opaque cursor for a change log position.
+func EncodeChangeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("seq|" + strconv.FormatInt(seq, 10)))
+}
+
+// decodeChangeCursor parses a cursor made by EncodeChangeCursor.
+// The empty cursor is the start of the log.
+func decodeChangeCursor(cur string) (int64, error) {
+	if cur == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cur)
+	if err != nil {
+		return 0, fmt.Errorf("%w: base64 decode failed", ErrInvalidCursor)
+	}
+	seqStr, ok := strings.CutPrefix(string(b), "seq|")
+	if !ok {
+		return 0, fmt.Errorf("%w: not a change cursor", ErrInvalidCursor)
+	}
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, fmt.Errorf("%w: invalid sequence", ErrInvalidCursor)
+	}
+	return seq, nil
+}
+
+// EventChanges returns changes to the events table made after sinceCursor,
+// oldest first. Only the latest change of each event is kept, so a client
+// that applies every page in order ends up with a replica of the table.
+// An empty sinceCursor starts from the beginning (a full sync).
+// limit <= 0 uses the default; larger limits are clamped.
+func (s *Store) EventChanges(ctx context.Context, sinceCursor string, limit int) (ChangesResult, error) {
+	seq, err := decodeChangeCursor(sinceCursor)
+	if err != nil {
+		return ChangesResult{}, err
+	}
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	// Fetch one extra row to detect more pages
+	rows, err := s.query(ctx, `
+		SELECT seq, event_id, op FROM event_changes
+		WHERE seq > ?
+		ORDER BY seq ASC
+		LIMIT ?
+	`, seq, limit+1)
+	if err != nil {
+		return ChangesResult{}, fmt.Errorf("query event changes: %w", err)
+	}
+	defer rows.Close()
+
+	result := ChangesResult{Items: []EventChange{}}
+	var upserts []any
+	for rows.Next() {
+		if len(result.Items) == limit {
+			result.HasMore = true
+			break
+		}
+		var change EventChange
+		if err := rows.Scan(&seq, &change.ID, &change.Op); err != nil {
+			return ChangesResult{}, fmt.Errorf("scan event change: %w", err)
+		}
+		if change.Op == ChangeUpsert {
+			upserts = append(upserts, change.ID)
+		}
+		result.Items = append(result.Items, change)
+	}
+	if err := rows.Err(); err != nil {
+		return ChangesResult{}, fmt.Errorf("rows error: %w", err)
+	}
+	rows.Close()
+	result.NextCursor = EncodeChangeCursor(seq)
+
+	if err := s.attachChangedEvents(ctx, result.Items, upserts); err != nil {
+		return ChangesResult{}, err
+	}
+	return result, nil
+}
+
+// attachChangedEvents loads the current rows of upserted events.
+// An event deleted since its upsert was logged turns into a tombstone; the
+// later delete entry repeats it, which clients must tolerate anyway.
+func (s *Store) attachChangedEvents(ctx context.Context, items []EventChange, ids []any) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err := s.query(ctx, `SELECT `+eventColumns+` FROM events WHERE id IN (`+placeholders+`)`, ids...)
+	if err != nil {
+		return fmt.Errorf("query changed events: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*event.Event, len(ids))
+	for rows.Next() {
+		r, err := scanEventRow(rows)
+		if err != nil {
+			return fmt.Errorf("scan event: %w", err)
+		}
+		e, err := r.toEvent()
+		if err != nil {
+			return err
+		}
+		byID[e.ID] = e
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	for i := range items {
+		if items[i].Op != ChangeUpsert {
+			continue
+		}
+		if e, ok := byID[items[i].ID]; ok {
+			items[i].Event = e
+		} else {
+			items[i].Op = ChangeDelete
+		}
+	}
+	return nil
+}
+
+// EventsChangedAt returns when the latest change to the events table was
+// first seen, going by the sequence of the change log: inserts, updates
+// and deletes all count. Changes made before the first call count as seen
+// then. Cheap enough to call on every request, e.g. for Last-Modified.
+func (s *Store) EventsChangedAt(ctx context.Context) (time.Time, error) {
+	var seq int64
+	if err := s.queryRow(ctx, `SELECT COALESCE(MAX(seq), 0) FROM event_changes`).Scan(&seq); err != nil {
+		return time.Time{}, fmt.Errorf("query change log: %w", err)
+	}
+	s.changedMu.Lock()
+	defer s.changedMu.Unlock()
+	if s.changedAt.IsZero() || seq != s.changedSeq {
+		s.changedSeq, s.changedAt = seq, time.Now()
+	}
+	return s.changedAt, nil
+}
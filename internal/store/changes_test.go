@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestEventChanges(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	var ids []int64
+	for i := range 3 {
+		e := &event.Event{
+			Ts:         base.Add(time.Duration(i) * time.Minute),
+			Type:       event.TypeWorldJoin,
+			WorldName:  event.StringPtr(fmt.Sprintf("World %d", i)),
+			DedupeKey:  fmt.Sprintf("w%d", i),
+			IngestedAt: base,
+		}
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	// Full sync in pages of two
+	page, err := st.EventChanges(ctx, "", 2)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+	if len(page.Items) != 2 || !page.HasMore || page.Items[0].ID != ids[0] || page.Items[0].Op != ChangeUpsert ||
+		page.Items[0].Event == nil || *page.Items[0].Event.WorldName != "World 0" {
+		t.Fatalf("first page = %+v", page)
+	}
+	page, err = st.EventChanges(ctx, page.NextCursor, 2)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+	if len(page.Items) != 1 || page.HasMore || page.Items[0].ID != ids[2] {
+		t.Fatalf("second page = %+v", page)
+	}
+	synced := page.NextCursor
+
+	// Nothing new: same cursor comes back
+	page, err = st.EventChanges(ctx, synced, 0)
+	if err != nil || len(page.Items) != 0 || page.NextCursor != synced {
+		t.Fatalf("empty page = %+v, %v", page, err)
+	}
+
+	// An update and a delete show up once each, after the synced position
+	if _, err := st.db.ExecContext(ctx, `UPDATE events SET world_name = 'Renamed' WHERE id = ?`, ids[0]); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := st.db.ExecContext(ctx, `UPDATE events SET world_name = 'Renamed again' WHERE id = ?`, ids[0]); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := st.db.ExecContext(ctx, `DELETE FROM events WHERE id = ?`, ids[1]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	page, err = st.EventChanges(ctx, synced, 0)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("changes = %+v", page.Items)
+	}
+	if c := page.Items[0]; c.ID != ids[0] || c.Op != ChangeUpsert || *c.Event.WorldName != "Renamed again" {
+		t.Errorf("update = %+v", c)
+	}
+	if c := page.Items[1]; c.ID != ids[1] || c.Op != ChangeDelete || c.Event != nil {
+		t.Errorf("tombstone = %+v", c)
+	}
+
+	// A full sync no longer carries the deleted event's row
+	page, err = st.EventChanges(ctx, "", 0)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+	upserts := 0
+	for _, c := range page.Items {
+		if c.Op == ChangeUpsert {
+			upserts++
+		}
+	}
+	if upserts != 2 || len(page.Items) != 3 {
+		t.Errorf("full sync = %+v", page.Items)
+	}
+}
+
+func TestEventChanges_InvalidCursor(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	for _, cur := range []string{"!!!", EncodeCursor(time.Now(), 1)} {
+		if _, err := st.EventChanges(context.Background(), cur, 0); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("EventChanges(%q): err = %v, want ErrInvalidCursor", cur, err)
+		}
+	}
+}
+
+func TestEventsChangedAt(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	first, err := st.EventsChangedAt(ctx)
+	if err != nil || first.IsZero() {
+		t.Fatalf("EventsChangedAt = %v, %v", first, err)
+	}
+	if again, _ := st.EventsChangedAt(ctx); !again.Equal(first) {
+		t.Errorf("EventsChangedAt moved without a change: %v -> %v", first, again)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	e := &event.Event{Ts: time.Now(), Type: event.TypeWorldJoin, DedupeKey: "changed", IngestedAt: time.Now()}
+	if _, _, err := st.InsertEvent(ctx, e); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+	if after, _ := st.EventsChangedAt(ctx); !after.After(first) {
+		t.Errorf("EventsChangedAt after insert = %v, want after %v", after, first)
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// CopresenceEntry is the time shared with one player.
+type CopresenceEntry struct {
+	PlayerName string `json:"player_name"`
+	PlayerID   string `json:"player_id,omitempty"`
+	Minutes    int64  `json:"minutes"`
+	Sessions   int    `json:"sessions"`
+}
+
+// GetCopresence returns how long each player shared an instance with the
+// user in the time range, longest first. A player session runs from a
+// player_join until the matching player_left or the next world_join;
+// sessions still open at the end of the range are cut at min(until, now).
+// Sessions are tracked per account; an empty account covers all accounts.
+// If limit > 0, only the top limit players are returned.
+func (s *Store) GetCopresence(ctx context.Context, since, until time.Time, account string, limit int) ([]CopresenceEntry, error) {
+	accountCond, accountArgs := accountClause(account)
+	args := append([]any{event.TypeWorldJoin, event.TypePlayerJoin, event.TypePlayerLeft,
+		since.UTC().Format(TimeFormat), until.UTC().Format(TimeFormat)}, accountArgs...)
+	rows, err := s.query(ctx, `
+		SELECT ts, type, account, player_name, player_id FROM events
+		WHERE type IN (?, ?, ?) AND ts >= ? AND ts < ?`+accountCond+`
+		ORDER BY ts ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	type total struct {
+		entry CopresenceEntry
+		dur   time.Duration
+	}
+	// A session is keyed by account and player; totals by player only
+	type sessionKey struct{ account, player string }
+	totals := make(map[string]*total)
+	open := make(map[sessionKey]time.Time) // -> join time
+
+	closeSession := func(key sessionKey, end time.Time) {
+		start, ok := open[key]
+		if !ok {
+			return
+		}
+		delete(open, key)
+		if end.After(start) {
+			totals[key.player].dur += end.Sub(start)
+		}
+	}
+
+	for rows.Next() {
+		var (
+			tsStr, typ                 string
+			acct, playerName, playerID sql.NullString
+		)
+		if err := rows.Scan(&tsStr, &typ, &acct, &playerName, &playerID); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		ts, err := time.Parse(TimeFormat, tsStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse ts %q: %w", tsStr, err)
+		}
+
+		if typ == event.TypeWorldJoin {
+			for key := range open {
+				if key.account == acct.String {
+					closeSession(key, ts)
+				}
+			}
+			continue
+		}
+
+		player := playerRefKey(PlayerRef{PlayerName: playerName.String, PlayerID: playerID.String})
+		if player == "" {
+			continue
+		}
+		key := sessionKey{account: acct.String, player: player}
+
+		switch typ {
+		case event.TypePlayerJoin:
+			if _, ok := open[key]; ok {
+				continue // duplicate join
+			}
+			t, ok := totals[player]
+			if !ok {
+				t = &total{entry: CopresenceEntry{PlayerID: playerID.String}}
+				totals[player] = t
+			}
+			if playerName.String != "" {
+				t.entry.PlayerName = playerName.String
+			}
+			t.entry.Sessions++
+			open[key] = ts
+		case event.TypePlayerLeft:
+			closeSession(key, ts)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	end := until
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+	for key := range open {
+		closeSession(key, end)
+	}
+
+	sorted := make([]*total, 0, len(totals))
+	for _, t := range totals {
+		sorted = append(sorted, t)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].dur != sorted[j].dur {
+			return sorted[i].dur > sorted[j].dur
+		}
+		return sorted[i].entry.PlayerName < sorted[j].entry.PlayerName
+	})
+
+	result := make([]CopresenceEntry, 0, len(sorted))
+	for _, t := range sorted {
+		t.entry.Minutes = int64(t.dur / time.Minute)
+		result = append(result, t.entry)
+	}
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
@@ -20,6 +20,12 @@ func encodeCursor(t time.Time, id int64) string {
 	return EncodeCursor(t, id)
 }
 
+// DecodeCursor parses a cursor created by EncodeCursor into timestamp and
+// ID. The error wraps ErrInvalidCursor if it is malformed.
+func DecodeCursor(cur string) (time.Time, int64, error) {
+	return decodeCursor(cur)
+}
+
 // decodeCursor parses a base64-encoded cursor into timestamp and ID.
 // Supports both RawURLEncoding (preferred) and StdEncoding (backward compatibility).
 func decodeCursor(cur string) (time.Time, int64, error) {
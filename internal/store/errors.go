@@ -1,6 +1,11 @@
 package store
 
-import "errors"
+import (
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
 
 // Sentinel errors for the store package.
 var (
@@ -9,4 +14,34 @@ var (
 
 	// ErrInvalidEvent is returned when an event fails validation.
 	ErrInvalidEvent = errors.New("invalid event")
+
+	// ErrNotFound is returned when a requested row does not exist.
+	ErrNotFound = errors.New("not found")
 )
+
+// IsBusy reports whether err means the database stayed locked by another
+// writer for longer than the busy timeout.
+func IsBusy(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	// Extended result codes keep the primary code in the low byte
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// IsWriteFailure reports whether err means the database cannot be written
+// at all, e.g. the disk is full or the file or filesystem is read-only.
+// Such errors persist until the cause is fixed, unlike IsBusy.
+func IsWriteFailure(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_FULL, sqlite3.SQLITE_READONLY, sqlite3.SQLITE_IOERR, sqlite3.SQLITE_CANTOPEN:
+		return true
+	}
+	return false
+}
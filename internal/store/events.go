@@ -24,23 +24,38 @@ func (s *Store) InsertEvent(ctx context.Context, e *event.Event) (id int64, inse
 	if err := validateEvent(e); err != nil {
 		return 0, false, err
 	}
+	if err := s.pairLeave(ctx, e); err != nil {
+		return 0, false, err
+	}
+	if err := s.attachWorld(ctx, e); err != nil {
+		return 0, false, err
+	}
+	if err := s.linkPlayer(ctx, e); err != nil {
+		return 0, false, err
+	}
 
 	const query = `
 	INSERT INTO events
-	(ts, type, player_name, player_id, world_id, world_name, instance_id, meta_json, dedupe_key, ingested_at, schema_version)
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	(ts, type, player_name, player_id, normalized_name, world_id, world_name, instance_id, instance_type, region, group_id, duration_sec, account, meta_json, dedupe_key, ingested_at, schema_version)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	ON CONFLICT(dedupe_key) DO NOTHING
 	`
 
 	row := eventToRow(e)
-	result, err := s.db.ExecContext(ctx, query,
+	result, err := s.exec(ctx, query,
 		row.Ts,
 		row.Type,
 		row.PlayerName,
 		row.PlayerID,
+		nullIfEmpty(event.NormalizeName(deref(e.PlayerName))),
 		row.WorldID,
 		row.WorldName,
 		row.InstanceID,
+		row.InstanceType,
+		row.Region,
+		row.GroupID,
+		row.DurationSec,
+		row.Account,
 		row.MetaJSON,
 		row.DedupeKey,
 		row.IngestedAt,
@@ -61,6 +76,7 @@ func (s *Store) InsertEvent(ctx context.Context, e *event.Event) (id int64, inse
 			return 0, false, fmt.Errorf("last insert id: %w", err)
 		}
 		e.ID = id
+		s.insertsSinceAnalyze.Add(1)
 		return id, true, nil
 	}
 
@@ -87,6 +103,90 @@ type QueryFilter struct {
 	Limit  int
 	Cursor *string
 	Order  QueryOrder // Default: QueryOrderDesc
+
+	InstanceType *string // e.g. "public", "friends"
+	Region       *string // e.g. "jp", "us"
+	GroupID      *string // grp_xxx
+	World        *string // world ID or exact world name
+	Player       *string // player ID or player name (see event.NormalizeName)
+	Account      *string // VRChat account; nil or empty matches all accounts
+}
+
+// Matches reports whether e passes the filter's conditions, as QueryEvents
+// would apply them. Limit, Cursor and Order are ignored. Used to filter
+// live events the same way as stored ones.
+func (f QueryFilter) Matches(e *event.Event) bool {
+	if f.Since != nil && e.Ts.Before(*f.Since) {
+		return false
+	}
+	if f.Until != nil && !e.Ts.Before(*f.Until) {
+		return false
+	}
+	eq := func(want *string, got *string) bool {
+		return want == nil || *want == "" || (got != nil && *got == *want)
+	}
+	if f.Type != nil && *f.Type != "" && e.Type != *f.Type {
+		return false
+	}
+	if !eq(f.InstanceType, e.InstanceType) || !eq(f.Region, e.Region) ||
+		!eq(f.GroupID, e.GroupID) || !eq(f.Account, e.Account) {
+		return false
+	}
+	if !eq(f.World, e.WorldID) && !eq(f.World, e.WorldName) {
+		return false
+	}
+	if f.Player != nil && *f.Player != "" && !eq(f.Player, e.PlayerID) &&
+		(e.PlayerName == nil || event.NormalizeName(*e.PlayerName) != event.NormalizeName(*f.Player)) {
+		return false
+	}
+	return true
+}
+
+// conditions returns the filter's conditions as " AND ..." SQL clauses for
+// a WHERE clause on events, with their arguments. Limit, Cursor and Order
+// are not conditions.
+func (f QueryFilter) conditions() (string, []any) {
+	var (
+		sb   strings.Builder
+		args []any
+	)
+	if f.Since != nil {
+		sb.WriteString(" AND ts >= ?")
+		args = append(args, f.Since.UTC().Format(TimeFormat))
+	}
+	if f.Until != nil {
+		sb.WriteString(" AND ts < ?")
+		args = append(args, f.Until.UTC().Format(TimeFormat))
+	}
+	if f.Type != nil && *f.Type != "" {
+		sb.WriteString(" AND type = ?")
+		args = append(args, *f.Type)
+	}
+	if f.InstanceType != nil && *f.InstanceType != "" {
+		sb.WriteString(" AND instance_type = ?")
+		args = append(args, *f.InstanceType)
+	}
+	if f.Region != nil && *f.Region != "" {
+		sb.WriteString(" AND region = ?")
+		args = append(args, *f.Region)
+	}
+	if f.GroupID != nil && *f.GroupID != "" {
+		sb.WriteString(" AND group_id = ?")
+		args = append(args, *f.GroupID)
+	}
+	if f.World != nil && *f.World != "" {
+		sb.WriteString(" AND (world_id = ? OR world_name = ?)")
+		args = append(args, *f.World, *f.World)
+	}
+	if f.Player != nil && *f.Player != "" {
+		sb.WriteString(" AND (player_id = ? OR normalized_name = ?)")
+		args = append(args, *f.Player, event.NormalizeName(*f.Player))
+	}
+	if f.Account != nil && *f.Account != "" {
+		sb.WriteString(" AND account = ?")
+		args = append(args, *f.Account)
+	}
+	return sb.String(), args
 }
 
 // QueryResult contains the result of a query.
@@ -104,29 +204,15 @@ func (s *Store) QueryEvents(ctx context.Context, f QueryFilter) (QueryResult, er
 		limit = maxLimit
 	}
 
-	var (
-		sb   strings.Builder
-		args []any
-	)
-
+	var sb strings.Builder
 	sb.WriteString(`
-SELECT id, ts, type, player_name, player_id, world_id, world_name, instance_id, meta_json, dedupe_key, ingested_at, schema_version
+SELECT ` + eventColumns + `
 FROM events
 WHERE 1=1
 `)
 
-	if f.Since != nil {
-		sb.WriteString(" AND ts >= ?")
-		args = append(args, f.Since.UTC().Format(TimeFormat))
-	}
-	if f.Until != nil {
-		sb.WriteString(" AND ts < ?")
-		args = append(args, f.Until.UTC().Format(TimeFormat))
-	}
-	if f.Type != nil && *f.Type != "" {
-		sb.WriteString(" AND type = ?")
-		args = append(args, *f.Type)
-	}
+	cond, args := f.conditions()
+	sb.WriteString(cond)
 
 	// Cursor handling (composite cursor: ts|id)
 	// Direction depends on Order: DESC moves backward, ASC moves forward.
@@ -155,7 +241,7 @@ WHERE 1=1
 	sb.WriteString(" LIMIT ?")
 	args = append(args, limit+1) // fetch one extra to detect next page
 
-	rows, err := s.db.QueryContext(ctx, sb.String(), args...)
+	rows, err := s.query(ctx, sb.String(), args...)
 	if err != nil {
 		return QueryResult{}, fmt.Errorf("query events: %w", err)
 	}
@@ -163,12 +249,8 @@ WHERE 1=1
 
 	items := make([]event.Event, 0, limit+1)
 	for rows.Next() {
-		var r eventRow
-		if err := rows.Scan(
-			&r.ID, &r.Ts, &r.Type, &r.PlayerName, &r.PlayerID,
-			&r.WorldID, &r.WorldName, &r.InstanceID, &r.MetaJSON,
-			&r.DedupeKey, &r.IngestedAt, &r.SchemaVersion,
-		); err != nil {
+		r, err := scanEventRow(rows)
+		if err != nil {
 			return QueryResult{}, fmt.Errorf("scan event: %w", err)
 		}
 		e, err := r.toEvent()
@@ -192,13 +274,92 @@ WHERE 1=1
 	return QueryResult{Items: items, NextCursor: nextCursor}, nil
 }
 
+// DefaultDeleteBatchSize is how many events DeleteEvents deletes per
+// statement.
+const DefaultDeleteBatchSize = 1000
+
+// CountMatchingEvents returns how many events match the filter's
+// conditions. Limit, Cursor and Order are ignored.
+func (s *Store) CountMatchingEvents(ctx context.Context, f QueryFilter) (int64, error) {
+	cond, args := f.conditions()
+	var n int64
+	if err := s.queryRow(ctx, `SELECT COUNT(*) FROM events WHERE 1=1`+cond, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count events: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteEvents deletes the events matching the filter's conditions and
+// returns how many it deleted. Limit, Cursor and Order are ignored.
+// Events are deleted batchSize at a time (DefaultDeleteBatchSize if <= 0),
+// each batch in its own statement, so ingest and queries are not blocked
+// for the whole deletion. If ctx is cancelled, the batches deleted so far
+// stay deleted. Notes on deleted events are deleted with them; the change
+// log records a tombstone for each.
+// With a trashBatch, the events and their notes are moved to that trash
+// batch, from which RestoreTrash can put them back; otherwise they are
+// gone for good. The current instances are rebuilt afterwards.
+func (s *Store) DeleteEvents(ctx context.Context, f QueryFilter, batchSize int, trashBatch string) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultDeleteBatchSize
+	}
+	cond, args := f.conditions()
+	query := `DELETE FROM events WHERE id IN (SELECT id FROM events WHERE 1=1` + cond + ` LIMIT ?)`
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		var n int64
+		if trashBatch != "" {
+			var err error
+			if n, err = s.trashEvents(ctx, trashBatch, cond, args, batchSize); err != nil {
+				return total, err
+			}
+		} else {
+			result, err := s.exec(ctx, query, append(args, batchSize)...)
+			if err != nil {
+				return total, fmt.Errorf("delete events: %w", err)
+			}
+			if n, err = result.RowsAffected(); err != nil {
+				return total, fmt.Errorf("rows affected: %w", err)
+			}
+		}
+		total += n
+		if n < int64(batchSize) {
+			break
+		}
+	}
+	if total > 0 {
+		// The deleted events may have been the current instance
+		if err := s.RebuildOnline(ctx); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// GetEvent returns the event with the given ID, or ErrNotFound.
+func (s *Store) GetEvent(ctx context.Context, id int64) (*event.Event, error) {
+	row := s.queryRow(ctx, `SELECT `+eventColumns+` FROM events WHERE id = ?`, id)
+	r, err := scanEventRow(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get event: %w", err)
+	}
+	return r.toEvent()
+}
+
 // GetLastEventTime returns the timestamp of the most recent event.
 // Returns zero time if no events exist.
 func (s *Store) GetLastEventTime(ctx context.Context) (time.Time, error) {
 	const query = `SELECT ts FROM events ORDER BY ts DESC, id DESC LIMIT 1`
 
 	var ts string
-	err := s.db.QueryRowContext(ctx, query).Scan(&ts)
+	err := s.queryRow(ctx, query).Scan(&ts)
 	if errors.Is(err, sql.ErrNoRows) {
 		return time.Time{}, nil
 	}
@@ -219,7 +380,7 @@ func (s *Store) CountEvents(ctx context.Context) (int64, error) {
 	const query = `SELECT COUNT(*) FROM events`
 
 	var count int64
-	if err := s.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+	if err := s.queryRow(ctx, query).Scan(&count); err != nil {
 		return 0, fmt.Errorf("count events: %w", err)
 	}
 	return count, nil
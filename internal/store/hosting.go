@@ -0,0 +1,215 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
+)
+
+// HostingStats compares the instances the user hosted with the ones they
+// visited.
+type HostingStats struct {
+	UserID  string         `json:"user_id,omitempty"` // the user's own ID; empty if unknown
+	Hosted  HostingCount   `json:"hosted"`            // instances owned by UserID
+	Visited HostingCount   `json:"visited"`           // all other instances, including public ones
+	Worlds  []HostingWorld `json:"worlds"`            // most hosted first, then most visited
+	Hosts   []InstanceHost `json:"hosts"`             // most instances first
+}
+
+// HostingCount counts distinct instances and the world joins into them.
+type HostingCount struct {
+	Instances int `json:"instances"`
+	Joins     int `json:"joins"`
+}
+
+// HostingWorld counts the instances of one world by who hosted them.
+type HostingWorld struct {
+	WorldID   string `json:"world_id"`
+	WorldName string `json:"world_name,omitempty"` // most recently logged name
+	Hosted    int    `json:"hosted"`
+	Visited   int    `json:"visited"`
+}
+
+// InstanceHost is the owner of instances the user joined: a user for
+// friends and invite instances, a group for group instances.
+type InstanceHost struct {
+	HostID    string `json:"host_id"`             // usr_ or grp_ ID
+	HostName  string `json:"host_name,omitempty"` // latest display name of a user; empty for groups
+	Instances int    `json:"instances"`
+	Joins     int    `json:"joins"`
+	Self      bool   `json:"self,omitempty"` // the host is the user
+}
+
+// GetHostingStats counts the distinct instances joined in the time range
+// by who owns them, according to the owner in the instance ID. Instances
+// owned by userID are hosted, all others visited; public instances have
+// no owner. An empty userID is replaced by GuessUserID. If limit > 0, only
+// the top limit worlds and hosts are returned. An empty account covers all
+// accounts.
+func (s *Store) GetHostingStats(ctx context.Context, since, until time.Time, account, userID string, limit int) (*HostingStats, error) {
+	if userID == "" {
+		var err error
+		if userID, err = s.GuessUserID(ctx, account); err != nil {
+			return nil, err
+		}
+	}
+
+	accountCond, accountArgs := accountClause(account)
+	args := append([]any{event.TypeWorldJoin, since.UTC().Format(TimeFormat), until.UTC().Format(TimeFormat)}, accountArgs...)
+	rows, err := s.query(ctx, `
+		SELECT account, world_id, world_name, instance_id FROM events
+		WHERE type = ? AND ts >= ? AND ts < ?`+accountCond+`
+		ORDER BY ts ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query world joins: %w", err)
+	}
+	defer rows.Close()
+
+	stats := &HostingStats{UserID: userID}
+	worlds := make(map[string]*HostingWorld)
+	hosts := make(map[string]*InstanceHost)
+	seen := make(map[string]bool)      // world_id:instance_id
+	current := make(map[string]string) // account -> world ID of the latest join
+	for rows.Next() {
+		var acct, worldID, worldName, instanceID sql.NullString
+		if err := rows.Scan(&acct, &worldID, &worldName, &instanceID); err != nil {
+			return nil, fmt.Errorf("scan world join: %w", err)
+		}
+		// The "Entering Room" line names the world joined just before
+		if !worldID.Valid {
+			if w := worlds[current[acct.String]]; w != nil && worldName.String != "" {
+				w.WorldName = worldName.String
+			}
+			continue
+		}
+		current[acct.String] = worldID.String
+		if !instanceID.Valid {
+			continue
+		}
+
+		w := worlds[worldID.String]
+		if w == nil {
+			w = &HostingWorld{WorldID: worldID.String}
+			worlds[worldID.String] = w
+		}
+		if worldName.String != "" {
+			w.WorldName = worldName.String
+		}
+
+		info := instance.Parse(instanceID.String)
+		hostID := cmp.Or(info.OwnerID, info.GroupID)
+		hosted := userID != "" && info.OwnerID == userID
+		count := &stats.Visited
+		if hosted {
+			count = &stats.Hosted
+		}
+		var h *InstanceHost
+		if hostID != "" {
+			if h = hosts[hostID]; h == nil {
+				h = &InstanceHost{HostID: hostID, Self: hosted}
+				hosts[hostID] = h
+			}
+			h.Joins++
+		}
+		count.Joins++
+
+		key := worldID.String + ":" + instanceID.String
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		count.Instances++
+		if hosted {
+			w.Hosted++
+		} else {
+			w.Visited++
+		}
+		if h != nil {
+			h.Instances++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	stats.Worlds = make([]HostingWorld, 0, len(worlds))
+	for _, w := range worlds {
+		stats.Worlds = append(stats.Worlds, *w)
+	}
+	slices.SortFunc(stats.Worlds, func(a, b HostingWorld) int {
+		return cmp.Or(cmp.Compare(b.Hosted, a.Hosted), cmp.Compare(b.Visited, a.Visited), cmp.Compare(a.WorldID, b.WorldID))
+	})
+	stats.Hosts = make([]InstanceHost, 0, len(hosts))
+	for _, h := range hosts {
+		stats.Hosts = append(stats.Hosts, *h)
+	}
+	slices.SortFunc(stats.Hosts, func(a, b InstanceHost) int {
+		return cmp.Or(cmp.Compare(b.Instances, a.Instances), cmp.Compare(b.Joins, a.Joins), cmp.Compare(a.HostID, b.HostID))
+	})
+	if limit > 0 {
+		stats.Worlds = stats.Worlds[:min(limit, len(stats.Worlds))]
+		stats.Hosts = stats.Hosts[:min(limit, len(stats.Hosts))]
+	}
+
+	for i := range stats.Hosts {
+		h := &stats.Hosts[i]
+		if !strings.HasPrefix(h.HostID, "usr_") {
+			continue
+		}
+		err := s.queryRow(ctx, `
+			SELECT player_name FROM events
+			WHERE type = ? AND player_id = ? AND player_name IS NOT NULL
+			ORDER BY ts DESC, id DESC LIMIT 1
+		`, event.TypePlayerJoin, h.HostID).Scan(&h.HostName)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("query host name: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// guessUserIDJoins is how many of the latest world joins GuessUserID
+// looks at.
+const guessUserIDJoins = 100
+
+// GuessUserID returns the user's own ID. VRChat logs the user joining each
+// instance before anyone already there, so it is the player ID logged most
+// often first after one of the latest world joins. Returns "" if none
+// carried an ID. An empty account covers all accounts, and returns the ID
+// of the one joining most.
+func (s *Store) GuessUserID(ctx context.Context, account string) (string, error) {
+	accountCond, accountArgs := accountClause(account)
+	args := append([]any{event.TypePlayerJoin, event.TypeWorldJoin}, accountArgs...)
+	args = append(args, guessUserIDJoins)
+	var userID string
+	err := s.queryRow(ctx, `
+		SELECT first FROM (
+			SELECT (
+				SELECT p.player_id FROM events p
+				WHERE p.type = ? AND p.account IS w.account AND (p.ts > w.ts OR (p.ts = w.ts AND p.id > w.id))
+				ORDER BY p.ts, p.id LIMIT 1
+			) AS first
+			FROM events w
+			WHERE w.type = ? AND w.world_id IS NOT NULL`+accountCond+`
+			ORDER BY w.ts DESC, w.id DESC LIMIT ?
+		)
+		WHERE first IS NOT NULL
+		GROUP BY first ORDER BY COUNT(*) DESC, first LIMIT 1
+	`, args...).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("guess user ID: %w", err)
+	}
+	return userID, nil
+}
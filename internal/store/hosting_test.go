@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestGetHostingStats(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	const me, alice = "usr_me", "usr_alice"
+	base := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	var n int
+	insert := func(e *event.Event) {
+		t.Helper()
+		n++
+		e.Ts = base.Add(time.Duration(n) * time.Minute)
+		e.DedupeKey = fmt.Sprintf("host-%d", n)
+		e.IngestedAt = e.Ts
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	join := func(world, inst string) {
+		insert(&event.Event{Type: event.TypeWorldJoin, WorldID: event.StringPtr(world), InstanceID: event.StringPtr(inst)})
+		insert(&event.Event{Type: event.TypeWorldJoin, WorldName: event.StringPtr("World " + world[5:])})
+		insert(&event.Event{Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Me"), PlayerID: event.StringPtr(me)})
+	}
+
+	join("wrld_a", "100~private("+me+")~region(jp)")
+	join("wrld_a", "200~friends("+me+")")
+	join("wrld_a", "100~private("+me+")~region(jp)") // rejoin of the same instance
+	join("wrld_b", "300~hidden("+alice+")")
+	for range 4 { // Alice rejoining often does not make her the user
+		insert(&event.Event{Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr(alice)})
+	}
+	join("wrld_b", "400~group(grp_club)~groupAccessType(public)")
+	join("wrld_c", "500")
+
+	got, err := st.GetHostingStats(ctx, base, base.Add(24*time.Hour), "", "", 0)
+	if err != nil {
+		t.Fatalf("GetHostingStats: %v", err)
+	}
+	if got.UserID != me {
+		t.Errorf("UserID = %q, want the first joiner after world joins %q", got.UserID, me)
+	}
+	if got.Hosted != (HostingCount{Instances: 2, Joins: 3}) || got.Visited != (HostingCount{Instances: 3, Joins: 3}) {
+		t.Errorf("hosted = %+v, visited = %+v", got.Hosted, got.Visited)
+	}
+
+	wantWorlds := []HostingWorld{
+		{WorldID: "wrld_a", WorldName: "World a", Hosted: 2},
+		{WorldID: "wrld_b", WorldName: "World b", Visited: 2},
+		{WorldID: "wrld_c", WorldName: "World c", Visited: 1},
+	}
+	if fmt.Sprint(got.Worlds) != fmt.Sprint(wantWorlds) {
+		t.Errorf("worlds = %+v, want %+v", got.Worlds, wantWorlds)
+	}
+	wantHosts := []InstanceHost{
+		{HostID: me, HostName: "Me", Instances: 2, Joins: 3, Self: true},
+		{HostID: "grp_club", Instances: 1, Joins: 1},
+		{HostID: alice, HostName: "Alice", Instances: 1, Joins: 1},
+	}
+	if fmt.Sprint(got.Hosts) != fmt.Sprint(wantHosts) {
+		t.Errorf("hosts = %+v, want %+v", got.Hosts, wantHosts)
+	}
+
+	// An explicit user ID and a limit
+	got, err = st.GetHostingStats(ctx, base, base.Add(24*time.Hour), "", alice, 1)
+	if err != nil {
+		t.Fatalf("GetHostingStats: %v", err)
+	}
+	if got.Hosted != (HostingCount{Instances: 1, Joins: 1}) || len(got.Worlds) != 1 || len(got.Hosts) != 1 {
+		t.Errorf("as alice: %+v", got)
+	}
+
+	// Nothing in range
+	got, err = st.GetHostingStats(ctx, base.Add(-time.Hour), base, "", "", 0)
+	if err != nil {
+		t.Fatalf("GetHostingStats: %v", err)
+	}
+	if got.Hosted.Instances+got.Visited.Instances != 0 || len(got.Worlds) != 0 || got.Hosts == nil {
+		t.Errorf("empty range: %+v", got)
+	}
+}
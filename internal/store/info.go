@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DatabaseInfo describes the database file and its schema, for bug
+// reports.
+type DatabaseInfo struct {
+	SchemaVersion int         `json:"schema_version"`
+	SQLiteVersion string      `json:"sqlite_version"`
+	JournalMode   string      `json:"journal_mode"`
+	SizeBytes     int64       `json:"size_bytes"`
+	FreeBytes     int64       `json:"free_bytes"` // in free pages, reclaimed by VACUUM
+	LastVacuum    *time.Time  `json:"last_vacuum,omitempty"`
+	Tables        []TableInfo `json:"tables"`
+	Schema        []string    `json:"schema"` // CREATE statements of tables, indexes and triggers
+}
+
+// TableInfo is a table and its row count.
+type TableInfo struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// DatabaseInfo returns the versions, size and schema of the database and
+// the row count of each table. Counting scans every table, so this is for
+// occasional use only.
+func (s *Store) DatabaseInfo(ctx context.Context) (DatabaseInfo, error) {
+	info := DatabaseInfo{SchemaVersion: CurrentSchemaVersion}
+	if err := s.queryRow(ctx, "SELECT sqlite_version()").Scan(&info.SQLiteVersion); err != nil {
+		return info, fmt.Errorf("sqlite version: %w", err)
+	}
+	if err := s.queryRow(ctx, "PRAGMA journal_mode").Scan(&info.JournalMode); err != nil {
+		return info, fmt.Errorf("journal mode: %w", err)
+	}
+	var pageSize, pageCount, freePages int64
+	if err := s.queryRow(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return info, fmt.Errorf("page size: %w", err)
+	}
+	if err := s.queryRow(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return info, fmt.Errorf("page count: %w", err)
+	}
+	if err := s.queryRow(ctx, "PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return info, fmt.Errorf("freelist count: %w", err)
+	}
+	info.SizeBytes = pageSize * pageCount
+	info.FreeBytes = pageSize * freePages
+
+	lastVacuum, err := s.LastVacuum(ctx)
+	if err != nil {
+		return info, fmt.Errorf("last vacuum: %w", err)
+	}
+	if !lastVacuum.IsZero() {
+		info.LastVacuum = &lastVacuum
+	}
+
+	rows, err := s.query(ctx, `
+	SELECT type, name, sql FROM sqlite_master
+	WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+	ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'index' THEN 1 ELSE 2 END, name`)
+	if err != nil {
+		return info, fmt.Errorf("query schema: %w", err)
+	}
+	var tables []string
+	for rows.Next() {
+		var typ, name, sql string
+		if err := rows.Scan(&typ, &name, &sql); err != nil {
+			rows.Close()
+			return info, fmt.Errorf("scan schema: %w", err)
+		}
+		info.Schema = append(info.Schema, sql)
+		if typ == "table" {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return info, fmt.Errorf("query schema: %w", err)
+	}
+
+	info.Tables = make([]TableInfo, 0, len(tables))
+	for _, name := range tables {
+		t := TableInfo{Name: name}
+		// Names come from sqlite_master, quoted in case of odd characters
+		quoted := `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		if err := s.queryRow(ctx, "SELECT COUNT(*) FROM "+quoted).Scan(&t.Rows); err != nil {
+			return info, fmt.Errorf("count %s: %w", name, err)
+		}
+		info.Tables = append(info.Tables, t)
+	}
+	return info, nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestDatabaseInfo(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	name := "Alice"
+	if _, _, err := store.InsertEvent(ctx, &event.Event{
+		Type:       event.TypePlayerJoin,
+		Ts:         time.Now().UTC(),
+		PlayerName: &name,
+		DedupeKey:  "info-1",
+		IngestedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+
+	info, err := store.DatabaseInfo(ctx)
+	if err != nil {
+		t.Fatalf("DatabaseInfo: %v", err)
+	}
+	if info.SchemaVersion != CurrentSchemaVersion || info.SQLiteVersion == "" || info.SizeBytes <= 0 {
+		t.Errorf("info = %+v", info)
+	}
+	if info.LastVacuum != nil {
+		t.Errorf("last vacuum = %v for a new database", info.LastVacuum)
+	}
+
+	rows := map[string]int64{}
+	for _, table := range info.Tables {
+		rows[table.Name] = table.Rows
+	}
+	if n, ok := rows["events"]; !ok || n != 1 {
+		t.Errorf("events rows = %d, %v; want 1", n, ok)
+	}
+	if _, ok := rows["parse_failures"]; !ok {
+		t.Errorf("tables = %+v, missing parse_failures", info.Tables)
+	}
+
+	schema := strings.Join(info.Schema, "\n")
+	if !strings.Contains(schema, "CREATE TABLE events") || !strings.Contains(schema, "CREATE INDEX") {
+		t.Errorf("schema is missing events or its indexes:\n%s", schema)
+	}
+}
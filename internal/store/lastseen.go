@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// PlayerRef identifies a player.
+type PlayerRef struct {
+	PlayerName string `json:"player_name"`
+	PlayerID   string `json:"player_id,omitempty"`
+}
+
+// LastSeen is the most recent sighting of a player.
+type LastSeen struct {
+	Event event.Event `json:"event"`           // newest player_join or player_left of the player
+	World *SeenWorld  `json:"world,omitempty"` // instance the user was in then; nil if unknown
+	// With lists the other players in the instance at the time, in join
+	// order. Empty if the world is unknown.
+	With []PlayerRef `json:"with"`
+}
+
+// LastSeen returns the most recent join or leave of a player, matched by
+// player ID or display name (see event.NormalizeName) and through player
+// links, with the instance it happened in and who else was there. An empty
+// account covers all accounts.
+// Returns ErrNotFound if the player was never seen.
+func (s *Store) LastSeen(ctx context.Context, player, account string) (*LastSeen, error) {
+	id, name, err := s.resolvePlayer(ctx, player)
+	if err != nil {
+		return nil, err
+	}
+	query, args := lastSeenQuery(id, name, account, time.Time{})
+	r, err := scanEventRow(s.queryRow(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query last seen: %w", err)
+	}
+	e, err := r.toEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := &LastSeen{Event: *e, With: []PlayerRef{}}
+	seen.World, err = s.worldAt(ctx, deref(e.Account), e.Ts)
+	if err != nil {
+		return nil, err
+	}
+	if seen.World != nil {
+		seen.With, err = s.playersAt(ctx, e, seen.World.JoinedAt)
+		if err != nil {
+			return nil, err
+		}
+		seen.With = excludePlayer(seen.With, e)
+	}
+	return seen, nil
+}
+
+// SeenBefore returns the time of the newest join or leave of a player before
+// the given time, matched as in LastSeen. An empty account covers all
+// accounts.
+// Returns ErrNotFound if the player was not seen before then.
+func (s *Store) SeenBefore(ctx context.Context, player, account string, before time.Time) (time.Time, error) {
+	id, name, err := s.resolvePlayer(ctx, player)
+	if err != nil {
+		return time.Time{}, err
+	}
+	query, args := lastSeenQuery(id, name, account, before)
+	r, err := scanEventRow(s.queryRow(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrNotFound
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query last seen: %w", err)
+	}
+	e, err := r.toEvent()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return e.Ts, nil
+}
+
+// lastSeenQuery builds the query for the newest join or leave of the player
+// with the given ID or normalized name, or a name linked to the ID. A
+// non-zero before only considers events older than it.
+func lastSeenQuery(id, name, account string, before time.Time) (string, []any) {
+	cond, condArgs := accountClause(account)
+	if !before.IsZero() {
+		cond += " AND ts < ?"
+		condArgs = append(condArgs, before.UTC().Format(TimeFormat))
+	}
+
+	// One branch per player index, so no lookup scans the table
+	branch := `SELECT * FROM (
+		SELECT ` + eventColumns + ` FROM events
+		WHERE %s AND type IN (?, ?)` + cond + `
+		ORDER BY ts DESC, id DESC
+		LIMIT 1
+	)`
+	query := fmt.Sprintf(branch, "player_id = ?") +
+		" UNION ALL " + fmt.Sprintf(branch, "normalized_name = ?") +
+		" UNION ALL " + fmt.Sprintf(branch, linkedNamesCond) +
+		" ORDER BY ts DESC, id DESC LIMIT 1"
+
+	var args []any
+	for _, key := range []string{id, name, id} {
+		args = append(args, key, event.TypePlayerJoin, event.TypePlayerLeft)
+		args = append(args, condArgs...)
+	}
+	return query, args
+}
+
+// playersAt returns the players present right after e in the instance the
+// user joined at joinedAt, by replaying the joins and leaves since then.
+func (s *Store) playersAt(ctx context.Context, e *event.Event, joinedAt time.Time) ([]PlayerRef, error) {
+	tsStr := e.Ts.UTC().Format(TimeFormat)
+	rows, err := s.query(ctx, `
+		SELECT type, player_name, player_id FROM events
+		WHERE type IN (?, ?) AND account IS ? AND ts >= ?
+		  AND (ts < ? OR (ts = ? AND id <= ?))
+		ORDER BY ts ASC, id ASC
+	`, event.TypePlayerJoin, event.TypePlayerLeft, nullIfEmpty(deref(e.Account)),
+		joinedAt.UTC().Format(TimeFormat), tsStr, tsStr, e.ID)
+	if err != nil {
+		return nil, fmt.Errorf("query instance players: %w", err)
+	}
+	defer rows.Close()
+
+	var order []string
+	present := make(map[string]PlayerRef)
+	for rows.Next() {
+		var typ string
+		var name, id sql.NullString
+		if err := rows.Scan(&typ, &name, &id); err != nil {
+			return nil, fmt.Errorf("scan instance player: %w", err)
+		}
+		ref := PlayerRef{PlayerName: name.String, PlayerID: id.String}
+		key := playerRefKey(ref)
+		if key == "" {
+			continue
+		}
+		switch typ {
+		case event.TypePlayerJoin:
+			if _, ok := present[key]; !ok {
+				order = append(order, key)
+			}
+			present[key] = ref
+		case event.TypePlayerLeft:
+			delete(present, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	players := make([]PlayerRef, 0, len(present))
+	for _, key := range order {
+		if ref, ok := present[key]; ok {
+			players = append(players, ref)
+			delete(present, key) // a rejoin appears once
+		}
+	}
+	return players, nil
+}
+
+// excludePlayer removes the player of e from players.
+func excludePlayer(players []PlayerRef, e *event.Event) []PlayerRef {
+	key := playerRefKey(PlayerRef{PlayerName: deref(e.PlayerName), PlayerID: deref(e.PlayerID)})
+	out := players[:0]
+	for _, p := range players {
+		if playerRefKey(p) != key {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// playerRefKey identifies a player by ID, or by normalized name when no ID
+// is known.
+func playerRefKey(p PlayerRef) string {
+	if p.PlayerID != "" {
+		return p.PlayerID
+	}
+	return event.NormalizeName(p.PlayerName)
+}
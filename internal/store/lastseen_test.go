@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestLastSeen(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	n := 0
+	insert := func(offset time.Duration, typ string, e event.Event) {
+		t.Helper()
+		n++
+		e.Ts = base.Add(offset)
+		e.Type = typ
+		e.IngestedAt = base
+		e.DedupeKey = "k" + string(rune('a'+n))
+		if _, _, err := st.InsertEvent(ctx, &e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	player := func(name, id string) event.Event {
+		e := event.Event{PlayerName: event.StringPtr(name)}
+		if id != "" {
+			e.PlayerID = event.StringPtr(id)
+		}
+		return e
+	}
+
+	insert(0, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_a"), InstanceID: event.StringPtr("1~friends(usr_x)")})
+	insert(time.Second, event.TypeWorldJoin, event.Event{WorldName: event.StringPtr("World A")})
+	insert(time.Minute, event.TypePlayerJoin, player("Bob", ""))
+	insert(2*time.Minute, event.TypePlayerJoin, player("Alice", "usr_alice"))
+	insert(3*time.Minute, event.TypePlayerJoin, player("Carol", ""))
+	insert(4*time.Minute, event.TypePlayerLeft, player("Carol", ""))
+	insert(5*time.Minute, event.TypePlayerLeft, player("Alice", "usr_alice"))
+	insert(time.Hour, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_b"), InstanceID: event.StringPtr("2")})
+
+	for _, key := range []string{"Alice", "usr_alice", "ＡＬＩＣＥ"} {
+		seen, err := st.LastSeen(ctx, key, "")
+		if err != nil {
+			t.Fatalf("LastSeen(%q): %v", key, err)
+		}
+		if seen.Event.Type != event.TypePlayerLeft || !seen.Event.Ts.Equal(base.Add(5*time.Minute)) {
+			t.Errorf("LastSeen(%q).Event = %s at %v, want the leave", key, seen.Event.Type, seen.Event.Ts)
+		}
+		if seen.World == nil || deref(seen.World.WorldName) != "World A" {
+			t.Errorf("LastSeen(%q).World = %+v, want World A", key, seen.World)
+		}
+		if len(seen.With) != 1 || seen.With[0].PlayerName != "Bob" {
+			t.Errorf("LastSeen(%q).With = %+v, want only Bob", key, seen.With)
+		}
+	}
+
+	ts, err := st.SeenBefore(ctx, "usr_alice", "", base.Add(5*time.Minute))
+	if err != nil {
+		t.Fatalf("SeenBefore: %v", err)
+	}
+	if !ts.Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("SeenBefore = %v, want the join", ts)
+	}
+	if _, err := st.SeenBefore(ctx, "Alice", "", base.Add(2*time.Minute)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SeenBefore(first join): err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := st.LastSeen(ctx, "Dave", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LastSeen(Dave): err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.LastSeen(ctx, "Alice", "alt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LastSeen(Alice, alt): err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLastSeen_UsesPlayerIndexes(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	query, args := lastSeenQuery("usr_alice", "alice", "", time.Time{})
+	plan, err := st.explain(context.Background(), query, args...)
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	joined := strings.Join(plan, "\n")
+	for _, idx := range []string{"idx_events_player_id_ts", "idx_events_normalized_name_ts"} {
+		if !strings.Contains(joined, idx) {
+			t.Errorf("plan does not use %s:\n%s", idx, joined)
+		}
+	}
+}
+
+func TestPlayerProfile(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	n := 0
+	insert := func(offset time.Duration, typ string, e event.Event) {
+		t.Helper()
+		n++
+		e.Ts = base.Add(offset)
+		e.Type = typ
+		e.IngestedAt = base
+		e.DedupeKey = "k" + string(rune('a'+n))
+		if _, _, err := st.InsertEvent(ctx, &e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	alice := func(name string) event.Event {
+		return event.Event{PlayerName: event.StringPtr(name), PlayerID: event.StringPtr("usr_alice")}
+	}
+
+	insert(0, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_a")})
+	insert(time.Second, event.TypeWorldJoin, event.Event{WorldName: event.StringPtr("World A")})
+	insert(time.Minute, event.TypePlayerJoin, alice("Alice"))
+	insert(11*time.Minute, event.TypePlayerLeft, alice("Alice"))
+	insert(20*time.Minute, event.TypePlayerJoin, alice("Alice"))
+	// Moving on ends the session without a leave
+	insert(50*time.Minute, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_b")})
+	insert(50*time.Minute+time.Second, event.TypeWorldJoin, event.Event{WorldName: event.StringPtr("World B")})
+	insert(time.Hour, event.TypePlayerJoin, alice("Alicia"))
+	insert(time.Hour+5*time.Minute, event.TypePlayerLeft, alice("Alicia"))
+	insert(2*time.Hour, event.TypePlayerJoin, event.Event{PlayerName: event.StringPtr("Bob")})
+	insert(2*time.Hour+time.Minute, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_c")})
+
+	p, err := st.PlayerProfile(ctx, "usr_alice", "")
+	if err != nil {
+		t.Fatalf("PlayerProfile: %v", err)
+	}
+	if p.PlayerName != "Alicia" || p.PlayerID != "usr_alice" || strings.Join(p.Names, ",") != "Alice,Alicia" {
+		t.Errorf("identity = %q %q %v", p.PlayerName, p.PlayerID, p.Names)
+	}
+	if !p.FirstSeen.Equal(base.Add(time.Minute)) || !p.LastSeen.Equal(base.Add(time.Hour+5*time.Minute)) {
+		t.Errorf("seen = %v .. %v", p.FirstSeen, p.LastSeen)
+	}
+	if p.Sessions != 3 || p.Minutes != 45 {
+		t.Errorf("sessions = %d, minutes = %d, want 3 and 45", p.Sessions, p.Minutes)
+	}
+	if len(p.Worlds) != 2 {
+		t.Fatalf("worlds = %+v, want 2", p.Worlds)
+	}
+	if w := p.Worlds[0]; w.WorldID != "wrld_a" || w.WorldName != "World A" || w.Sessions != 2 || w.Minutes != 40 ||
+		!w.LastSeen.Equal(base.Add(50*time.Minute)) {
+		t.Errorf("worlds[0] = %+v", w)
+	}
+	if w := p.Worlds[1]; w.WorldID != "wrld_b" || w.WorldName != "World B" || w.Sessions != 1 || w.Minutes != 5 {
+		t.Errorf("worlds[1] = %+v", w)
+	}
+
+	// A session still open runs until the next world join
+	bob, err := st.PlayerProfile(ctx, "Bob", "")
+	if err != nil {
+		t.Fatalf("PlayerProfile(Bob): %v", err)
+	}
+	if bob.Minutes != 1 || len(bob.Worlds) != 1 || bob.Worlds[0].WorldID != "wrld_b" {
+		t.Errorf("Bob = %+v", bob)
+	}
+
+	if _, err := st.PlayerProfile(ctx, "Dave", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PlayerProfile(Dave): err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,292 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// PlayerLink ties a display name to a player ID, so that join and leave
+// events logged with the name only count towards that player. Older VRChat
+// logs carry names without IDs.
+type PlayerLink struct {
+	PlayerName string `json:"player_name"` // name as first linked
+	// PlayerID is the player the name belongs to. Empty for a name that was
+	// unlinked by hand and is never linked automatically again.
+	PlayerID  string    `json:"player_id,omitempty"`
+	Manual    bool      `json:"manual"` // set or unlinked by hand rather than inferred
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createPlayerLinksTable creates the player_links table. A new table is
+// filled from the events already stored.
+func (s *Store) createPlayerLinksTable(ctx context.Context) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'player_links'`).Scan(&exists); err != nil {
+		return fmt.Errorf("check player_links table: %w", err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS player_links (
+		name_key     TEXT PRIMARY KEY, -- normalized display name
+		display_name TEXT NOT NULL,
+		linked_id    TEXT,             -- NULL: unlinked by hand
+		manual       INTEGER NOT NULL DEFAULT 0,
+		created_at   TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_player_links_linked_id ON player_links(linked_id);
+	`
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create player_links table: %w", err)
+	}
+	if exists == 0 {
+		return s.backfillPlayerLinks(ctx)
+	}
+	return nil
+}
+
+// backfillPlayerLinks links names seen both with and without an ID in the
+// same instance, replaying the events stored before the table existed.
+func (s *Store) backfillPlayerLinks(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT type, account, player_name, player_id, normalized_name FROM events
+		WHERE (type = ? AND world_id IS NOT NULL) OR (type IN (?, ?) AND normalized_name IS NOT NULL)
+		ORDER BY ts ASC, id ASC
+	`, event.TypeWorldJoin, event.TypePlayerJoin, event.TypePlayerLeft)
+	if err != nil {
+		return fmt.Errorf("select player events: %w", err)
+	}
+
+	// Per account, the names seen in the current instance
+	type seen struct {
+		name     string
+		id       string
+		nameOnly bool
+	}
+	visits := make(map[string]map[string]*seen)
+	links := make(map[string]PlayerLink) // name key -> link; first wins
+	var order []string
+	flush := func(account string) {
+		for key, sn := range visits[account] {
+			if _, ok := links[key]; ok || sn.id == "" || !sn.nameOnly {
+				continue
+			}
+			links[key] = PlayerLink{PlayerName: sn.name, PlayerID: sn.id}
+			order = append(order, key)
+		}
+		delete(visits, account)
+	}
+
+	for rows.Next() {
+		var typ string
+		var account, name, id, key sql.NullString
+		if err := rows.Scan(&typ, &account, &name, &id, &key); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan player event: %w", err)
+		}
+		if typ == event.TypeWorldJoin {
+			flush(account.String)
+			continue
+		}
+		v := visits[account.String]
+		if v == nil {
+			v = make(map[string]*seen)
+			visits[account.String] = v
+		}
+		sn := v[key.String]
+		if sn == nil {
+			sn = &seen{name: name.String}
+			v[key.String] = sn
+		}
+		if id.String != "" {
+			sn.id = id.String
+		} else {
+			sn.nameOnly = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	for account := range visits {
+		flush(account)
+	}
+	if len(order) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(TimeFormat)
+	for _, key := range order {
+		l := links[key]
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO player_links (name_key, display_name, linked_id, created_at) VALUES (?, ?, ?, ?)
+		`, key, l.PlayerName, l.PlayerID, now); err != nil {
+			return fmt.Errorf("backfill player link: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// linkPlayer links the name of a join or leave event that is about to be
+// inserted to a player ID, when the same name appears in the current
+// instance both with and without an ID. Names already linked, or unlinked
+// by hand, are left alone.
+func (s *Store) linkPlayer(ctx context.Context, e *event.Event) error {
+	if (e.Type != event.TypePlayerJoin && e.Type != event.TypePlayerLeft) || e.PlayerName == nil {
+		return nil
+	}
+	key := event.NormalizeName(*e.PlayerName)
+	if key == "" {
+		return nil
+	}
+	var n int
+	if err := s.queryRow(ctx, `SELECT COUNT(*) FROM player_links WHERE name_key = ?`, key).Scan(&n); err != nil {
+		return fmt.Errorf("check player link: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	w, err := s.worldAt(ctx, deref(e.Account), e.Ts)
+	if err != nil || w == nil {
+		return err
+	}
+
+	// Look for the other half: the name without an ID if e has one, or
+	// with an ID if it has not
+	playerID := deref(e.PlayerID)
+	cond := "player_id IS NULL"
+	if playerID == "" {
+		cond = "player_id IS NOT NULL"
+	}
+	var other sql.NullString
+	err = s.queryRow(ctx, `
+		SELECT player_id FROM events
+		WHERE normalized_name = ? AND `+cond+` AND type IN (?, ?) AND account IS ?
+		  AND ts >= ? AND ts <= ?
+		ORDER BY ts DESC, id DESC
+		LIMIT 1
+	`, key, event.TypePlayerJoin, event.TypePlayerLeft, nullIfEmpty(deref(e.Account)),
+		w.JoinedAt.UTC().Format(TimeFormat), e.Ts.UTC().Format(TimeFormat)).Scan(&other)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("find player in instance: %w", err)
+	}
+	if playerID == "" {
+		playerID = other.String
+	}
+
+	_, err = s.exec(ctx, `
+		INSERT INTO player_links (name_key, display_name, linked_id, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name_key) DO NOTHING
+	`, key, *e.PlayerName, playerID, time.Now().UTC().Format(TimeFormat))
+	if err != nil {
+		return fmt.Errorf("link player: %w", err)
+	}
+	return nil
+}
+
+// ListPlayerLinks returns all player links ordered by name.
+func (s *Store) ListPlayerLinks(ctx context.Context) ([]PlayerLink, error) {
+	rows, err := s.query(ctx, `
+		SELECT display_name, linked_id, manual, created_at FROM player_links ORDER BY name_key
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query player links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []PlayerLink{}
+	for rows.Next() {
+		var (
+			l         PlayerLink
+			id        sql.NullString
+			createdAt string
+		)
+		if err := rows.Scan(&l.PlayerName, &id, &l.Manual, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan player link: %w", err)
+		}
+		l.PlayerID = id.String
+		if l.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+			return nil, fmt.Errorf("parse created_at %q: %w", createdAt, err)
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return links, nil
+}
+
+// LinkPlayer links a display name to a player ID by hand, replacing any
+// link the name had.
+func (s *Store) LinkPlayer(ctx context.Context, name, playerID string) (*PlayerLink, error) {
+	now := time.Now().UTC()
+	_, err := s.exec(ctx, `
+		INSERT INTO player_links (name_key, display_name, linked_id, manual, created_at) VALUES (?, ?, ?, 1, ?)
+		ON CONFLICT(name_key) DO UPDATE SET
+			display_name = excluded.display_name,
+			linked_id = excluded.linked_id,
+			manual = 1,
+			created_at = excluded.created_at
+	`, event.NormalizeName(name), name, playerID, now.Format(TimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("link player: %w", err)
+	}
+	return &PlayerLink{PlayerName: name, PlayerID: playerID, Manual: true, CreatedAt: now}, nil
+}
+
+// UnlinkPlayer removes the link of a display name and keeps it from being
+// linked automatically again. Returns ErrNotFound if the name is not linked.
+func (s *Store) UnlinkPlayer(ctx context.Context, name string) error {
+	result, err := s.exec(ctx, `
+		UPDATE player_links SET linked_id = NULL, manual = 1, created_at = ?
+		WHERE name_key = ? AND linked_id IS NOT NULL
+	`, time.Now().UTC().Format(TimeFormat), event.NormalizeName(name))
+	if err != nil {
+		return fmt.Errorf("unlink player: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// resolvePlayer returns the keys a player lookup matches: an ID and a
+// normalized name. player is taken as an ID, unless it is a name linked to
+// one.
+func (s *Store) resolvePlayer(ctx context.Context, player string) (id, name string, err error) {
+	id, name = player, event.NormalizeName(player)
+	var linked string
+	err = s.queryRow(ctx, `
+		SELECT linked_id FROM player_links WHERE name_key = ? AND linked_id IS NOT NULL
+	`, name).Scan(&linked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return id, name, nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("resolve player: %w", err)
+	}
+	return linked, name, nil
+}
+
+// linkedNamesCond matches name-only rows whose name is linked to the player
+// ID bound to its one parameter.
+const linkedNamesCond = `(player_id IS NULL AND normalized_name IN (SELECT name_key FROM player_links WHERE linked_id = ?))`
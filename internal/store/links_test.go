@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestPlayerLinks(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	n := 0
+	insert := func(offset time.Duration, typ string, e event.Event) {
+		t.Helper()
+		n++
+		e.Ts = base.Add(offset)
+		e.Type = typ
+		e.IngestedAt = base
+		e.DedupeKey = "k" + string(rune('a'+n))
+		if _, _, err := st.InsertEvent(ctx, &e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	player := func(name, id string) event.Event {
+		e := event.Event{PlayerName: event.StringPtr(name)}
+		if id != "" {
+			e.PlayerID = event.StringPtr(id)
+		}
+		return e
+	}
+
+	// Alice shows up name-only in one instance, then with her ID in the
+	// next; Bob appears both ways within one instance
+	insert(0, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_a")})
+	insert(time.Minute, event.TypePlayerJoin, player("Alice", ""))
+	insert(2*time.Minute, event.TypePlayerJoin, player("Bob", ""))
+	insert(11*time.Minute, event.TypePlayerLeft, player("Alice", ""))
+	insert(12*time.Minute, event.TypePlayerLeft, player("Bob", "usr_bob"))
+	insert(time.Hour, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr("wrld_b")})
+	insert(time.Hour+time.Minute, event.TypePlayerJoin, player("Alice", "usr_alice"))
+
+	links, err := st.ListPlayerLinks(ctx)
+	if err != nil {
+		t.Fatalf("ListPlayerLinks: %v", err)
+	}
+	if len(links) != 1 || links[0].PlayerName != "Bob" || links[0].PlayerID != "usr_bob" || links[0].Manual {
+		t.Fatalf("links = %+v, want Bob inferred as usr_bob", links)
+	}
+
+	p, err := st.PlayerProfile(ctx, "usr_bob", "")
+	if err != nil {
+		t.Fatalf("PlayerProfile(usr_bob): %v", err)
+	}
+	if p.Sessions != 1 || p.Minutes != 10 {
+		t.Errorf("usr_bob: sessions %d, minutes %d, want 1 and 10", p.Sessions, p.Minutes)
+	}
+	seen, err := st.LastSeen(ctx, "bob", "")
+	if err != nil {
+		t.Fatalf("LastSeen(bob): %v", err)
+	}
+	if seen.Event.Type != event.TypePlayerLeft {
+		t.Errorf("LastSeen(bob) = %s, want the leave", seen.Event.Type)
+	}
+
+	// A manual merge pulls in Alice's name-only visit
+	link, err := st.LinkPlayer(ctx, "Alice", "usr_alice")
+	if err != nil {
+		t.Fatalf("LinkPlayer: %v", err)
+	}
+	if !link.Manual {
+		t.Errorf("LinkPlayer = %+v, want manual", link)
+	}
+	p, err = st.PlayerProfile(ctx, "usr_alice", "")
+	if err != nil {
+		t.Fatalf("PlayerProfile(usr_alice): %v", err)
+	}
+	if p.Sessions != 2 || len(p.Worlds) != 2 {
+		t.Errorf("usr_alice: sessions %d, worlds %+v, want 2 sessions in 2 worlds", p.Sessions, p.Worlds)
+	}
+
+	page, err := st.ListPlayers(ctx, PlayerFilter{})
+	if err != nil {
+		t.Fatalf("ListPlayers: %v", err)
+	}
+	if len(page.Items) != 2 {
+		t.Fatalf("ListPlayers = %+v, want Alice and Bob", page.Items)
+	}
+	for _, item := range page.Items {
+		want := map[string]int{"usr_alice": 2, "usr_bob": 1}[item.PlayerID]
+		if want == 0 || item.Sessions != want {
+			t.Errorf("player %+v not merged", item)
+		}
+	}
+
+	// Unlinking splits Bob again and keeps him from being relinked
+	if err := st.UnlinkPlayer(ctx, "ＢＯＢ"); err != nil {
+		t.Fatalf("UnlinkPlayer: %v", err)
+	}
+	if err := st.UnlinkPlayer(ctx, "Bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UnlinkPlayer twice: err = %v, want ErrNotFound", err)
+	}
+	insert(2*time.Hour, event.TypePlayerJoin, player("Bob", ""))
+	insert(2*time.Hour+time.Minute, event.TypePlayerLeft, player("Bob", "usr_bob"))
+	p, err = st.PlayerProfile(ctx, "usr_bob", "")
+	if err != nil {
+		t.Fatalf("PlayerProfile(usr_bob) after unlink: %v", err)
+	}
+	if p.Sessions != 0 {
+		t.Errorf("usr_bob after unlink: sessions %d, want 0", p.Sessions)
+	}
+	links, err = st.ListPlayerLinks(ctx)
+	if err != nil {
+		t.Fatalf("ListPlayerLinks: %v", err)
+	}
+	for _, l := range links {
+		if l.PlayerName == "Bob" && (l.PlayerID != "" || !l.Manual) {
+			t.Errorf("Bob link = %+v, want unlinked by hand", l)
+		}
+	}
+}
+
+func TestOpen_BackfillsPlayerLinks(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "old.sqlite")
+
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY, ts TEXT NOT NULL, type TEXT NOT NULL,
+		player_name TEXT, player_id TEXT, world_id TEXT, world_name TEXT,
+		instance_id TEXT, meta_json TEXT, dedupe_key TEXT NOT NULL,
+		ingested_at TEXT NOT NULL, schema_version INTEGER NOT NULL,
+		UNIQUE(dedupe_key)
+	);
+	INSERT INTO events (ts, type, world_id, player_name, player_id, dedupe_key, ingested_at, schema_version) VALUES
+		('2024-01-01T00:00:00.000000000Z', 'world_join', 'wrld_a', NULL, NULL, 'k1', '2024-01-01T00:00:00.000000000Z', 1),
+		('2024-01-01T00:01:00.000000000Z', 'player_join', NULL, 'Miku', NULL, 'k2', '2024-01-01T00:00:00.000000000Z', 1),
+		('2024-01-01T00:02:00.000000000Z', 'player_left', NULL, 'Ｍｉｋｕ', 'usr_miku', 'k3', '2024-01-01T00:00:00.000000000Z', 1),
+		('2024-01-01T01:00:00.000000000Z', 'world_join', 'wrld_b', NULL, NULL, 'k4', '2024-01-01T00:00:00.000000000Z', 1),
+		('2024-01-01T01:01:00.000000000Z', 'player_join', NULL, 'Rin', 'usr_rin', 'k5', '2024-01-01T00:00:00.000000000Z', 1);
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+
+	links, err := store.ListPlayerLinks(context.Background())
+	if err != nil {
+		t.Fatalf("ListPlayerLinks: %v", err)
+	}
+	if len(links) != 1 || links[0].PlayerID != "usr_miku" {
+		t.Errorf("links = %+v, want Miku linked to usr_miku", links)
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// MemoryWorld is a world and how often it was joined on a day.
+type MemoryWorld struct {
+	WorldID   string `json:"world_id"`
+	WorldName string `json:"world_name,omitempty"`
+	Visits    int    `json:"visits"`
+}
+
+// DayMemory is what happened on one day of an earlier year.
+type DayMemory struct {
+	Date        string            `json:"date"` // YYYY-MM-DD
+	YearsAgo    int               `json:"years_ago"`
+	Events      int               `json:"events"`
+	TopWorld    *MemoryWorld      `json:"top_world,omitempty"` // most visited world
+	Worlds      int               `json:"worlds"`              // distinct worlds visited
+	Players     []CopresenceEntry `json:"players"`             // who you were with, longest first
+	PlayerCount int               `json:"player_count"`        // distinct players met
+}
+
+// GetMemories returns what happened on the month and day of date in each
+// earlier year that has events, most recent first. Days run from midnight
+// to midnight in date's location; February 29 only matches leap years.
+// Each memory lists up to players players. An empty account covers all
+// accounts.
+func (s *Store) GetMemories(ctx context.Context, date time.Time, account string, players int) ([]DayMemory, error) {
+	accountCond, accountArgs := accountClause(account)
+	var first sql.NullString
+	if err := s.queryRow(ctx, `SELECT MIN(ts) FROM events WHERE 1 = 1`+accountCond, accountArgs...).Scan(&first); err != nil {
+		return nil, fmt.Errorf("query first event: %w", err)
+	}
+	if !first.Valid {
+		return []DayMemory{}, nil
+	}
+	firstTs, err := time.Parse(TimeFormat, first.String)
+	if err != nil {
+		return nil, fmt.Errorf("parse ts %q: %w", first.String, err)
+	}
+
+	loc := date.Location()
+	y, m, d := date.Date()
+	memories := []DayMemory{}
+	for year := y - 1; year >= firstTs.In(loc).Year(); year-- {
+		since := time.Date(year, m, d, 0, 0, 0, 0, loc)
+		if since.Day() != d {
+			continue // February 29 in a common year
+		}
+		memory, err := s.dayMemory(ctx, since, since.AddDate(0, 0, 1), account, players)
+		if err != nil {
+			return nil, err
+		}
+		if memory.Events == 0 {
+			continue
+		}
+		memory.YearsAgo = y - year
+		memories = append(memories, memory)
+	}
+	return memories, nil
+}
+
+// dayMemory summarizes the events in [since, until).
+func (s *Store) dayMemory(ctx context.Context, since, until time.Time, account string, players int) (DayMemory, error) {
+	memory := DayMemory{Date: since.Format("2006-01-02"), Players: []CopresenceEntry{}}
+	accountCond, accountArgs := accountClause(account)
+	rangeArgs := []any{since.UTC().Format(TimeFormat), until.UTC().Format(TimeFormat)}
+
+	if err := s.queryRow(ctx, `SELECT COUNT(*) FROM events WHERE ts >= ? AND ts < ?`+accountCond,
+		append(rangeArgs, accountArgs...)...).Scan(&memory.Events); err != nil {
+		return memory, fmt.Errorf("count events: %w", err)
+	}
+	if memory.Events == 0 {
+		return memory, nil
+	}
+
+	rows, err := s.query(ctx, `
+		SELECT world_id, MAX(world_name), COUNT(*) FROM events
+		WHERE type = ? AND world_id IS NOT NULL AND ts >= ? AND ts < ?`+accountCond+`
+		GROUP BY world_id
+		ORDER BY COUNT(*) DESC, MAX(ts) DESC
+	`, append(append([]any{event.TypeWorldJoin}, rangeArgs...), accountArgs...)...)
+	if err != nil {
+		return memory, fmt.Errorf("query worlds: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w MemoryWorld
+		var name sql.NullString
+		if err := rows.Scan(&w.WorldID, &name, &w.Visits); err != nil {
+			return memory, fmt.Errorf("scan world: %w", err)
+		}
+		w.WorldName = name.String
+		if memory.TopWorld == nil {
+			memory.TopWorld = &w
+		}
+		memory.Worlds++
+	}
+	if err := rows.Err(); err != nil {
+		return memory, fmt.Errorf("rows error: %w", err)
+	}
+
+	met, err := s.GetCopresence(ctx, since, until, account, 0)
+	if err != nil {
+		return memory, err
+	}
+	memory.PlayerCount = len(met)
+	if players > 0 && len(met) > players {
+		met = met[:players]
+	}
+	memory.Players = met
+	return memory, nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestGetMemories(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	jst := time.FixedZone("JST", 9*3600)
+
+	events := []struct {
+		ts     time.Time
+		typ    string
+		player string
+		world  string
+	}{
+		{time.Date(2021, 6, 14, 23, 30, 0, 0, time.UTC), event.TypeWorldJoin, "", "wrld_b"}, // June 15 in JST
+		{time.Date(2023, 3, 1, 12, 0, 0, 0, jst), event.TypeWorldJoin, "", "wrld_d"},
+		{time.Date(2023, 6, 15, 10, 0, 0, 0, jst), event.TypeWorldJoin, "", "wrld_a"},
+		{time.Date(2023, 6, 15, 10, 1, 0, 0, jst), event.TypePlayerJoin, "Alice", ""},
+		{time.Date(2023, 6, 15, 10, 31, 0, 0, jst), event.TypePlayerLeft, "Alice", ""},
+		{time.Date(2023, 6, 15, 11, 0, 0, 0, jst), event.TypeWorldJoin, "", "wrld_b"},
+		{time.Date(2023, 6, 15, 12, 0, 0, 0, jst), event.TypeWorldJoin, "", "wrld_a"},
+		{time.Date(2023, 6, 15, 12, 5, 0, 0, jst), event.TypePlayerJoin, "Bob", ""},
+		{time.Date(2023, 6, 15, 12, 15, 0, 0, jst), event.TypePlayerLeft, "Bob", ""},
+		{time.Date(2023, 6, 16, 0, 30, 0, 0, jst), event.TypeWorldJoin, "", "wrld_c"}, // the next day
+		{time.Date(2024, 6, 15, 9, 0, 0, 0, jst), event.TypeWorldJoin, "", "wrld_c"},  // this year
+	}
+	for i, e := range events {
+		ev := &event.Event{Ts: e.ts, Type: e.typ, DedupeKey: fmt.Sprintf("mem-%d", i), IngestedAt: e.ts}
+		if e.player != "" {
+			ev.PlayerName = event.StringPtr(e.player)
+		}
+		if e.world != "" {
+			ev.WorldID = event.StringPtr(e.world)
+			ev.WorldName = event.StringPtr("World " + e.world[5:])
+		}
+		if _, _, err := st.InsertEvent(ctx, ev); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	got, err := st.GetMemories(ctx, time.Date(2024, 6, 15, 20, 0, 0, 0, jst), "", 1)
+	if err != nil {
+		t.Fatalf("GetMemories: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d memories, want 2023 and 2021: %+v", len(got), got)
+	}
+
+	m := got[0]
+	if m.Date != "2023-06-15" || m.YearsAgo != 1 || m.Events != 7 || m.Worlds != 2 || m.PlayerCount != 2 {
+		t.Errorf("2023 memory = %+v", m)
+	}
+	if m.TopWorld == nil || *m.TopWorld != (MemoryWorld{WorldID: "wrld_a", WorldName: "World a", Visits: 2}) {
+		t.Errorf("top world = %+v, want wrld_a", m.TopWorld)
+	}
+	if len(m.Players) != 1 || m.Players[0].PlayerName != "Alice" || m.Players[0].Minutes != 30 {
+		t.Errorf("players = %+v, want Alice only", m.Players)
+	}
+
+	m = got[1]
+	if m.Date != "2021-06-15" || m.YearsAgo != 3 || m.Events != 1 || m.TopWorld == nil || m.TopWorld.WorldID != "wrld_b" {
+		t.Errorf("2021 memory = %+v", m)
+	}
+	if m.Players == nil || m.PlayerCount != 0 {
+		t.Errorf("2021 players = %v, %d; want none", m.Players, m.PlayerCount)
+	}
+
+	// February 29 has no counterpart in common years
+	got, err = st.GetMemories(ctx, time.Date(2024, 2, 29, 12, 0, 0, 0, jst), "", 5)
+	if err != nil {
+		t.Fatalf("GetMemories: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %+v for February 29, want none", got)
+	}
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// MergeResult reports what MergeFrom imported.
+type MergeResult struct {
+	Events                 int `json:"events"`
+	DuplicateEvents        int `json:"duplicate_events"`
+	ParseFailures          int `json:"parse_failures"`
+	DuplicateParseFailures int `json:"duplicate_parse_failures"`
+}
+
+// MergeFrom imports the events and parse failures of another VRClog
+// Companion database, skipping ones already present (by dedupe key).
+// Events are inserted oldest first, so presence durations and world
+// attribution are computed as if they had been ingested here.
+//
+// The other database is read from a temporary copy, which is migrated to
+// the current schema; the file at path is not modified.
+func (s *Store) MergeFrom(ctx context.Context, path string) (MergeResult, error) {
+	var result MergeResult
+
+	tmpDir, err := os.MkdirTemp("", "vrclog-merge-")
+	if err != nil {
+		return result, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	copyPath := filepath.Join(tmpDir, "merge.sqlite")
+	if err := copyDatabaseFiles(path, copyPath); err != nil {
+		return result, err
+	}
+	other, err := Open(copyPath)
+	if err != nil {
+		return result, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer other.Close()
+
+	if err := s.mergeEvents(ctx, other, &result); err != nil {
+		return result, err
+	}
+	if err := s.mergeParseFailures(ctx, other, &result); err != nil {
+		return result, err
+	}
+	if result.Events > 0 {
+		// A large merge leaves the planner statistics stale
+		if err := s.Analyze(ctx); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// mergeEvents inserts every event of other, oldest first.
+func (s *Store) mergeEvents(ctx context.Context, other *Store, result *MergeResult) error {
+	rows, err := other.db.QueryContext(ctx,
+		`SELECT `+eventColumns+` FROM events ORDER BY ts ASC, id ASC`)
+	if err != nil {
+		return fmt.Errorf("query events to merge: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scanEventRow(rows)
+		if err != nil {
+			return fmt.Errorf("scan event to merge: %w", err)
+		}
+		e, err := row.toEvent()
+		if err != nil {
+			return fmt.Errorf("event %d: %w", row.ID, err)
+		}
+		e.ID = 0
+
+		_, inserted, err := s.InsertEvent(ctx, e)
+		if err != nil {
+			return fmt.Errorf("merge event %d: %w", row.ID, err)
+		}
+		if inserted {
+			result.Events++
+		} else {
+			result.DuplicateEvents++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	return nil
+}
+
+// mergeParseFailures copies the parse failures of other, keeping their
+// original timestamps and counts, then trims the table to its limit.
+func (s *Store) mergeParseFailures(ctx context.Context, other *Store, result *MergeResult) error {
+	rows, err := other.db.QueryContext(ctx,
+		`SELECT ts, raw_line, error_msg, dedupe_key, count, last_ts FROM parse_failures ORDER BY ts ASC, id ASC`)
+	if err != nil {
+		return fmt.Errorf("query parse failures to merge: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ts, rawLine, errorMsg, dedupeKey string
+		var count int64
+		var lastTs sql.NullString
+		if err := rows.Scan(&ts, &rawLine, &errorMsg, &dedupeKey, &count, &lastTs); err != nil {
+			return fmt.Errorf("scan parse failure to merge: %w", err)
+		}
+		res, err := s.db.ExecContext(ctx, `
+			INSERT INTO parse_failures (ts, raw_line, error_msg, dedupe_key, pattern_key, count, last_ts)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(dedupe_key) DO NOTHING
+		`, ts, rawLine, errorMsg, dedupeKey, parseFailurePatternKey(rawLine, errorMsg), count, lastTs)
+		if err != nil {
+			return fmt.Errorf("merge parse failure: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("rows affected: %w", err)
+		} else if n > 0 {
+			result.ParseFailures++
+		} else {
+			result.DuplicateParseFailures++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	var newest sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(id) FROM parse_failures`).Scan(&newest); err != nil {
+		return fmt.Errorf("find newest parse failure: %w", err)
+	}
+	if newest.Valid {
+		return s.trimParseFailures(ctx, newest.Int64)
+	}
+	return nil
+}
+
+// copyDatabaseFiles copies a SQLite database and its WAL files, if any,
+// so that committed data still in the WAL is included.
+func copyDatabaseFiles(src, dst string) error {
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		err := copyFile(src+suffix, dst+suffix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy %s: %w", src, err)
+	}
+	return out.Close()
+}
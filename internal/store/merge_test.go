@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestMergeFrom(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	st := openTestStore(t)
+	defer st.Close()
+
+	otherPath := filepath.Join(t.TempDir(), "old.sqlite")
+	other, err := Open(otherPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	// "shared" exists in both databases; the old PC saw Alice join and
+	// leave, but only the join was also ingested here
+	insertTestEvent(t, st, base, event.TypePlayerJoin, "Alice", "shared")
+	insertTestEvent(t, other, base, event.TypePlayerJoin, "Alice", "shared")
+	insertTestEvent(t, other, base.Add(10*time.Minute), event.TypePlayerLeft, "Alice", "old-leave")
+	insertTestEvent(t, other, base.Add(-time.Hour), event.TypeWorldJoin, "", "old-world")
+	if _, err := other.InsertParseFailure(ctx, "garbled line", "no match"); err != nil {
+		t.Fatalf("InsertParseFailure: %v", err)
+	}
+	other.Close()
+
+	result, err := st.MergeFrom(ctx, otherPath)
+	if err != nil {
+		t.Fatalf("MergeFrom: %v", err)
+	}
+	want := MergeResult{Events: 2, DuplicateEvents: 1, ParseFailures: 1}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	res, err := st.QueryEvents(ctx, QueryFilter{Order: QueryOrderAsc})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(res.Items) != 3 || res.Items[0].Type != event.TypeWorldJoin {
+		t.Fatalf("events = %+v", res.Items)
+	}
+	leave := res.Items[2]
+	if leave.DurationSec == nil || *leave.DurationSec != 10*60 {
+		t.Errorf("leave DurationSec = %v, want 600", leave.DurationSec)
+	}
+
+	// Merging again imports nothing
+	result, err = st.MergeFrom(ctx, otherPath)
+	if err != nil {
+		t.Fatalf("MergeFrom again: %v", err)
+	}
+	want = MergeResult{DuplicateEvents: 3, DuplicateParseFailures: 1}
+	if result != want {
+		t.Errorf("second result = %+v, want %+v", result, want)
+	}
+
+	if _, err := st.MergeFrom(ctx, filepath.Join(t.TempDir(), "missing.sqlite")); err == nil {
+		t.Error("expected error merging a missing file")
+	}
+}
@@ -2,7 +2,11 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/instance"
 )
 
 // CurrentSchemaVersion is the current database schema version.
@@ -15,6 +19,34 @@ func (s *Store) migrate(ctx context.Context) error {
 		return err
 	}
 
+	// Add instance columns to databases created before they existed
+	if err := s.migrateInstanceColumns(ctx); err != nil {
+		return err
+	}
+
+	// Add account tags to databases created before they existed.
+	// Must run before the duration backfill, which pairs per account.
+	if err := s.migrateAccountColumn(ctx); err != nil {
+		return err
+	}
+
+	// Index player lookups (presence, player history), also used by the
+	// backfills below
+	if err := s.createPlayerIndexes(ctx); err != nil {
+		return err
+	}
+
+	// Add normalized player names to databases created before they
+	// existed. Must run before the duration backfill, which pairs by them.
+	if err := s.migrateNormalizedNameColumn(ctx); err != nil {
+		return err
+	}
+
+	// Add presence durations to databases created before they existed
+	if err := s.migrateDurationColumn(ctx); err != nil {
+		return err
+	}
+
 	// Create ingest_cursor table (for future use)
 	if err := s.createIngestCursorTable(ctx); err != nil {
 		return err
@@ -25,11 +57,80 @@ func (s *Store) migrate(ctx context.Context) error {
 		return err
 	}
 
+	// Add failure patterns and counts to databases created before they
+	// existed, trimming tables flooded by a corrupted log
+	if err := s.migrateParseFailurePatterns(ctx); err != nil {
+		return err
+	}
+
 	// Create metadata table
 	if err := s.createMetadataTable(ctx); err != nil {
 		return err
 	}
 
+	// Create notes table
+	if err := s.createNotesTable(ctx); err != nil {
+		return err
+	}
+
+	// Create bookmarks table
+	if err := s.createBookmarksTable(ctx); err != nil {
+		return err
+	}
+
+	// Create state_snapshots table
+	if err := s.createStateSnapshotsTable(ctx); err != nil {
+		return err
+	}
+
+	// Create pins table
+	if err := s.createPinsTable(ctx); err != nil {
+		return err
+	}
+
+	// Create views table
+	if err := s.createViewsTable(ctx); err != nil {
+		return err
+	}
+
+	// Create player_links table
+	if err := s.createPlayerLinksTable(ctx); err != nil {
+		return err
+	}
+
+	// Create trash table
+	if err := s.createTrashTable(ctx); err != nil {
+		return err
+	}
+
+	// Create revoked_sessions table
+	if err := s.createRevokedSessionsTable(ctx); err != nil {
+		return err
+	}
+
+	// Create the current instance tables, filled from the events
+	if err := s.createOnlineTables(ctx); err != nil {
+		return err
+	}
+
+	// Create the daily rollups after the backfills above, which they are
+	// seeded with
+	if err := s.createRollupTables(ctx); err != nil {
+		return err
+	}
+
+	// Create the milestones after the backfills above, which they are
+	// found from
+	if err := s.createMilestonesTable(ctx); err != nil {
+		return err
+	}
+
+	// Create the event change log last, so backfills above are not logged
+	// one by one
+	if err := s.createEventChangesTable(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -41,9 +142,15 @@ func (s *Store) createEventsTable(ctx context.Context) error {
 		type           TEXT NOT NULL,
 		player_name    TEXT,
 		player_id      TEXT,
+		normalized_name TEXT,
 		world_id       TEXT,
 		world_name     TEXT,
 		instance_id    TEXT,
+		instance_type  TEXT,
+		region         TEXT,
+		group_id       TEXT,
+		duration_sec   INTEGER,
+		account        TEXT,
 		meta_json      TEXT,
 		dedupe_key     TEXT NOT NULL,
 		ingested_at    TEXT NOT NULL,
@@ -87,6 +194,9 @@ func (s *Store) createParseFailuresTable(ctx context.Context) error {
 		raw_line    TEXT NOT NULL,
 		error_msg   TEXT NOT NULL,
 		dedupe_key  TEXT NOT NULL,
+		pattern_key TEXT,
+		count       INTEGER NOT NULL DEFAULT 1,
+		last_ts     TEXT,
 		UNIQUE(dedupe_key)
 	);
 
@@ -99,6 +209,96 @@ func (s *Store) createParseFailuresTable(ctx context.Context) error {
 	return nil
 }
 
+// migrateParseFailurePatterns adds the pattern_key, count and last_ts
+// columns to an existing parse_failures table, trims it to the limit and
+// fills in the patterns of the rows kept.
+func (s *Store) migrateParseFailurePatterns(ctx context.Context) error {
+	added, err := s.addColumnIfMissing(ctx, "parse_failures", "pattern_key", "TEXT")
+	if err != nil {
+		return err
+	}
+	if _, err := s.addColumnIfMissing(ctx, "parse_failures", "count", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if _, err := s.addColumnIfMissing(ctx, "parse_failures", "last_ts", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`CREATE INDEX IF NOT EXISTS idx_parse_failures_pattern ON parse_failures(pattern_key)`); err != nil {
+		return fmt.Errorf("create parse failure pattern index: %w", err)
+	}
+	if !added {
+		return nil
+	}
+
+	var newest sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(id) FROM parse_failures`).Scan(&newest); err != nil {
+		return fmt.Errorf("find newest parse failure: %w", err)
+	}
+	if !newest.Valid {
+		return nil
+	}
+	if err := s.trimParseFailures(ctx, newest.Int64); err != nil {
+		return err
+	}
+	return s.backfillParseFailurePatterns(ctx)
+}
+
+// backfillParseFailurePatterns sets the pattern_key of the rows stored
+// before patterns were recorded.
+func (s *Store) backfillParseFailurePatterns(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, raw_line, error_msg FROM parse_failures WHERE pattern_key IS NULL`)
+	if err != nil {
+		return fmt.Errorf("query parse failures to backfill: %w", err)
+	}
+	type update struct {
+		id  int64
+		key string
+	}
+	var updates []update
+	for rows.Next() {
+		var id int64
+		var rawLine, errorMsg string
+		if err := rows.Scan(&id, &rawLine, &errorMsg); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan parse failure to backfill: %w", err)
+		}
+		updates = append(updates, update{id, parseFailurePatternKey(rawLine, errorMsg)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin backfill: %w", err)
+	}
+	defer tx.Rollback()
+	for _, u := range updates {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE parse_failures SET pattern_key = ? WHERE id = ?`, u.key, u.id); err != nil {
+			return fmt.Errorf("backfill parse failure patterns: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Store) createRevokedSessionsTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS revoked_sessions (
+		token_hash TEXT PRIMARY KEY,
+		expires_at TEXT NOT NULL
+	);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create revoked_sessions table: %w", err)
+	}
+	return nil
+}
+
 func (s *Store) createMetadataTable(ctx context.Context) error {
 	const schema = `
 	CREATE TABLE IF NOT EXISTS metadata (
@@ -112,3 +312,380 @@ func (s *Store) createMetadataTable(ctx context.Context) error {
 	}
 	return nil
 }
+
+func (s *Store) createNotesTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS notes (
+		id         INTEGER PRIMARY KEY,
+		event_id   INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+		text       TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_notes_event_id ON notes(event_id);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create notes table: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) createBookmarksTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS bookmarks (
+		id          INTEGER PRIMARY KEY,
+		world_id    TEXT NOT NULL,
+		instance_id TEXT NOT NULL,
+		world_name  TEXT,
+		label       TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		UNIQUE(world_id, instance_id)
+	);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create bookmarks table: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) createStateSnapshotsTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS state_snapshots (
+		id           INTEGER PRIMARY KEY,
+		ts           TEXT NOT NULL,
+		account      TEXT,
+		world_id     TEXT,
+		world_name   TEXT,
+		instance_id  TEXT,
+		joined_at    TEXT,
+		player_count INTEGER NOT NULL,
+		peak_players INTEGER NOT NULL,
+		players_json TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_state_snapshots_ts ON state_snapshots(ts);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create state_snapshots table: %w", err)
+	}
+
+	// Snapshots saved before multi-account support belong to the default account
+	if _, err := s.addColumnIfMissing(ctx, "state_snapshots", "account", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`CREATE INDEX IF NOT EXISTS idx_state_snapshots_account_ts ON state_snapshots(account, ts)`); err != nil {
+		return fmt.Errorf("create state_snapshots account index: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) createPinsTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS pins (
+		id         INTEGER PRIMARY KEY,
+		since      TEXT NOT NULL,
+		until      TEXT,
+		event_id   INTEGER,
+		label      TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create pins table: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) createViewsTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS views (
+		name        TEXT PRIMARY KEY,
+		filter_json TEXT NOT NULL,
+		created_at  TEXT NOT NULL,
+		updated_at  TEXT NOT NULL
+	);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create views table: %w", err)
+	}
+	return nil
+}
+
+// createEventChangesTable creates the change log read by EventChanges and
+// the triggers that fill it. Each event keeps only its latest entry. An
+// existing database is seeded with an upsert for every event, so the first
+// sync of a client is a full copy.
+func (s *Store) createEventChangesTable(ctx context.Context) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'event_changes'`).Scan(&exists); err != nil {
+		return fmt.Errorf("check event_changes table: %w", err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS event_changes (
+		seq      INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		op       TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_event_changes_event_id ON event_changes(event_id);
+	`
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create event_changes table: %w", err)
+	}
+
+	if exists == 0 {
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO event_changes (event_id, op)
+			SELECT id, 'upsert' FROM events ORDER BY id
+		`); err != nil {
+			return fmt.Errorf("seed event_changes: %w", err)
+		}
+	}
+
+	const triggers = `
+	CREATE TRIGGER IF NOT EXISTS events_changes_insert AFTER INSERT ON events BEGIN
+		DELETE FROM event_changes WHERE event_id = NEW.id;
+		INSERT INTO event_changes (event_id, op) VALUES (NEW.id, 'upsert');
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changes_update AFTER UPDATE ON events BEGIN
+		DELETE FROM event_changes WHERE event_id = NEW.id;
+		INSERT INTO event_changes (event_id, op) VALUES (NEW.id, 'upsert');
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_changes_delete AFTER DELETE ON events BEGIN
+		DELETE FROM event_changes WHERE event_id = OLD.id;
+		INSERT INTO event_changes (event_id, op) VALUES (OLD.id, 'delete');
+	END;
+	`
+	if _, err := s.db.ExecContext(ctx, triggers); err != nil {
+		return fmt.Errorf("create event_changes triggers: %w", err)
+	}
+	return nil
+}
+
+// migrateInstanceColumns adds the instance_type, region, and group_id columns
+// to an existing events table and backfills them from instance_id.
+func (s *Store) migrateInstanceColumns(ctx context.Context) error {
+	added := false
+	for _, col := range []string{"instance_type", "region", "group_id"} {
+		ok, err := s.addColumnIfMissing(ctx, "events", col, "TEXT")
+		if err != nil {
+			return err
+		}
+		added = added || ok
+	}
+
+	const indexes = `
+	CREATE INDEX IF NOT EXISTS idx_events_instance_type_ts ON events(instance_type, ts);
+	CREATE INDEX IF NOT EXISTS idx_events_group_id_ts ON events(group_id, ts);
+	`
+	if _, err := s.db.ExecContext(ctx, indexes); err != nil {
+		return fmt.Errorf("create instance indexes: %w", err)
+	}
+
+	if !added {
+		return nil
+	}
+	return s.backfillInstanceColumns(ctx)
+}
+
+// backfillInstanceColumns parses instance_id for rows written before the
+// instance columns existed. Runs once, right after the columns are added.
+func (s *Store) backfillInstanceColumns(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, instance_id FROM events WHERE instance_id IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("select instance ids: %w", err)
+	}
+	type pending struct {
+		id   int64
+		info instance.Info
+	}
+	var updates []pending
+	for rows.Next() {
+		var id int64
+		var instanceID string
+		if err := rows.Scan(&id, &instanceID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan instance id: %w", err)
+		}
+		updates = append(updates, pending{id: id, info: instance.Parse(instanceID)})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, u := range updates {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE events SET instance_type = ?, region = ?, group_id = ? WHERE id = ?`,
+			nullIfEmpty(u.info.Type), nullIfEmpty(u.info.Region), nullIfEmpty(u.info.GroupID), u.id,
+		); err != nil {
+			return fmt.Errorf("backfill instance columns: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// migrateDurationColumn adds the duration_sec column to an existing events
+// table and pairs the player_left rows already stored.
+func (s *Store) migrateDurationColumn(ctx context.Context) error {
+	added, err := s.addColumnIfMissing(ctx, "events", "duration_sec", "INTEGER")
+	if err != nil || !added {
+		return err
+	}
+	return s.backfillPresenceDurations(ctx)
+}
+
+// migrateAccountColumn adds the account column to an existing events table.
+// Existing rows belong to the default account (NULL).
+func (s *Store) migrateAccountColumn(ctx context.Context) error {
+	if _, err := s.addColumnIfMissing(ctx, "events", "account", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`CREATE INDEX IF NOT EXISTS idx_events_account_ts ON events(account, ts)`); err != nil {
+		return fmt.Errorf("create account index: %w", err)
+	}
+	return nil
+}
+
+// migrateNormalizedNameColumn adds the normalized_name column to an existing
+// events table, fills it for the rows already stored, and indexes it.
+func (s *Store) migrateNormalizedNameColumn(ctx context.Context) error {
+	added, err := s.addColumnIfMissing(ctx, "events", "normalized_name", "TEXT")
+	if err != nil {
+		return err
+	}
+	if added {
+		if err := s.backfillNormalizedNames(ctx); err != nil {
+			return err
+		}
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_events_normalized_name_ts'`).Scan(&n); err != nil {
+		return fmt.Errorf("check normalized name index: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx,
+		`CREATE INDEX IF NOT EXISTS idx_events_normalized_name_ts ON events(normalized_name, ts)`); err != nil {
+		return fmt.Errorf("create normalized name index: %w", err)
+	}
+	s.insertsSinceAnalyze.Store(AnalyzeThreshold)
+	return nil
+}
+
+// backfillNormalizedNames fills normalized_name for rows written before the
+// column existed. Runs once, right after the column is added.
+func (s *Store) backfillNormalizedNames(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT DISTINCT player_name FROM events WHERE player_name IS NOT NULL`)
+	if err != nil {
+		return fmt.Errorf("select player names: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan player name: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, name := range names {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE events SET normalized_name = ? WHERE player_name = ?`,
+			nullIfEmpty(event.NormalizeName(name)), name,
+		); err != nil {
+			return fmt.Errorf("backfill normalized name: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// createPlayerIndexes adds the (player_name, ts) and (player_id, ts)
+// indexes. When they are new, the next AnalyzeIfNeeded refreshes the planner
+// statistics so they are used.
+func (s *Store) createPlayerIndexes(ctx context.Context) error {
+	var n int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_events_player_name_ts'`).Scan(&n); err != nil {
+		return fmt.Errorf("check player indexes: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, `
+	CREATE INDEX IF NOT EXISTS idx_events_player_name_ts ON events(player_name, ts);
+	CREATE INDEX IF NOT EXISTS idx_events_player_id_ts ON events(player_id, ts);
+	`); err != nil {
+		return fmt.Errorf("create player indexes: %w", err)
+	}
+	s.insertsSinceAnalyze.Store(AnalyzeThreshold)
+	return nil
+}
+
+// addColumnIfMissing adds a column to table unless it already exists.
+// Returns true if the column was added.
+func (s *Store) addColumnIfMissing(ctx context.Context, table, column, decl string) (bool, error) {
+	exists, err := s.columnExists(ctx, table, column)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl)
+	if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+		return false, fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
+	return true, nil
+}
+
+// columnExists reports whether table has the named column.
+func (s *Store) columnExists(ctx context.Context, table, column string) (bool, error) {
+	var n int
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("check column %s.%s: %w", table, column, err)
+	}
+	return n > 0, nil
+}
+
+// nullIfEmpty converts an empty string to SQL NULL.
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
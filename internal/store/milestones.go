@@ -0,0 +1,398 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Milestone kinds.
+const (
+	MilestoneWorldVisits       = "world_visits"       // Value-th visit to a world
+	MilestonePlayerAnniversary = "player_anniversary" // Value years since first meeting a player
+	MilestonePlayStreak        = "play_streak"        // Value days in a row with a world join
+)
+
+// Milestone values worth recording. Anniversaries count every year.
+var (
+	WorldVisitMilestones = []int{10, 25, 50, 100, 250, 500, 1000}
+	PlayStreakMilestones = []int{7, 14, 30, 100, 365}
+)
+
+// Milestone is a milestone reached by an event.
+type Milestone struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Account   string    `json:"account,omitempty"`
+	Subject   string    `json:"subject,omitempty"` // world ID or player key; empty for streaks
+	Name      string    `json:"name,omitempty"`    // world or player name
+	Value     int       `json:"value"`             // visits, years or days
+	ReachedAt time.Time `json:"reached_at"`
+	EventID   int64     `json:"event_id,omitempty"`
+}
+
+// MilestoneFilter selects milestones for ListMilestones.
+type MilestoneFilter struct {
+	Kind    string // empty for all kinds
+	Account string // empty for all accounts
+	Limit   int    // <= 0 uses the default; larger limits are clamped
+}
+
+// createMilestonesTable creates the milestones table. Milestones are found
+// at ingest by RecordMilestones; when the table is first created, they are
+// found in the events already stored, so history counts.
+func (s *Store) createMilestonesTable(ctx context.Context) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'milestones'`).Scan(&exists); err != nil {
+		return fmt.Errorf("check milestones table: %w", err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS milestones (
+		id         INTEGER PRIMARY KEY,
+		kind       TEXT NOT NULL,
+		account    TEXT NOT NULL,
+		subject    TEXT NOT NULL,
+		name       TEXT NOT NULL,
+		value      INTEGER NOT NULL,
+		reached_at TEXT NOT NULL,
+		event_id   INTEGER,
+		UNIQUE(kind, account, subject, value)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_milestones_reached_at ON milestones(reached_at);
+
+	-- Visit counts per world
+	CREATE INDEX IF NOT EXISTS idx_events_world_id_ts ON events(world_id, ts);
+	`
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create milestones table: %w", err)
+	}
+	if exists == 0 {
+		return s.backfillMilestones(ctx)
+	}
+	return nil
+}
+
+// backfillMilestones runs the stored world and player joins through a
+// milestoneTracker in order.
+func (s *Store) backfillMilestones(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, ts, type, COALESCE(account, ''), world_id, world_name, player_id, player_name, normalized_name
+		FROM events WHERE type IN (?, ?)
+		ORDER BY ts ASC, id ASC
+	`, event.TypeWorldJoin, event.TypePlayerJoin)
+	if err != nil {
+		return fmt.Errorf("query milestone events: %w", err)
+	}
+	defer rows.Close()
+
+	t := newMilestoneTracker()
+	var reached []Milestone
+	for rows.Next() {
+		var (
+			id                                   int64
+			ts, typ, account                     string
+			worldID, worldName                   sql.NullString
+			playerID, playerName, normalizedName sql.NullString
+		)
+		if err := rows.Scan(&id, &ts, &typ, &account, &worldID, &worldName, &playerID, &playerName, &normalizedName); err != nil {
+			return fmt.Errorf("scan milestone event: %w", err)
+		}
+		at, err := time.Parse(TimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		switch typ {
+		case event.TypeWorldJoin:
+			reached = append(reached, t.worldJoin(id, at, account, worldID.String, worldName.String)...)
+		case event.TypePlayerJoin:
+			key := playerID.String
+			if key == "" {
+				key = normalizedName.String
+			}
+			reached = append(reached, t.playerJoin(id, at, account, key, playerName.String)...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query milestone events: %w", err)
+	}
+	rows.Close()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("backfill milestones: %w", err)
+	}
+	defer tx.Rollback()
+	for _, m := range reached {
+		if _, err := tx.ExecContext(ctx, insertMilestoneQuery, milestoneArgs(m)...); err != nil {
+			return fmt.Errorf("backfill milestones: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+const insertMilestoneQuery = `
+	INSERT INTO milestones (kind, account, subject, name, value, reached_at, event_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(kind, account, subject, value) DO NOTHING`
+
+func milestoneArgs(m Milestone) []any {
+	return []any{m.Kind, m.Account, m.Subject, m.Name, m.Value, m.ReachedAt.UTC().Format(TimeFormat), m.EventID}
+}
+
+// RecordMilestones records the milestones e reached, if any, and returns
+// those not recorded before. e must have been inserted, so that it counts
+// itself. Only world and player joins reach milestones:
+//
+//   - the visits to a world reach one of WorldVisitMilestones
+//   - a player is met again a whole number of years after first meeting
+//   - the first world join of a local day makes a streak of days in a row
+//     with world joins that is one of PlayStreakMilestones
+//
+// Events ingested out of order may miss milestones.
+func (s *Store) RecordMilestones(ctx context.Context, e *event.Event) ([]Milestone, error) {
+	account := deref(e.Account)
+	ts := e.Ts.UTC().Format(TimeFormat)
+	var reached []Milestone
+
+	switch e.Type {
+	case event.TypeWorldJoin:
+		if worldID := deref(e.WorldID); worldID != "" {
+			var visits int
+			if err := s.queryRow(ctx, `
+				SELECT COUNT(*) FROM events
+				WHERE world_id = ? AND type = ? AND COALESCE(account, '') = ? AND (ts < ? OR (ts = ? AND id <= ?))
+			`, worldID, event.TypeWorldJoin, account, ts, ts, e.ID).Scan(&visits); err != nil {
+				return nil, fmt.Errorf("count world visits: %w", err)
+			}
+			if slices.Contains(WorldVisitMilestones, visits) {
+				reached = append(reached, Milestone{Kind: MilestoneWorldVisits, Subject: worldID, Name: deref(e.WorldName), Value: visits})
+			}
+		}
+
+		day := localDay(e.Ts)
+		var earlier int
+		if err := s.queryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM events
+			WHERE type = ? AND COALESCE(account, '') = ? AND ts >= ? AND (ts < ? OR (ts = ? AND id < ?)))
+		`, event.TypeWorldJoin, account, day.UTC().Format(TimeFormat), ts, ts, e.ID).Scan(&earlier); err != nil {
+			return nil, fmt.Errorf("check play day: %w", err)
+		}
+		if earlier == 0 {
+			cond, args := " AND COALESCE(account, '') = ?", []any{account}
+			streak, err := s.playStreak(ctx, day, cond, args)
+			if err != nil {
+				return nil, err
+			}
+			if slices.Contains(PlayStreakMilestones, streak) {
+				reached = append(reached, Milestone{Kind: MilestonePlayStreak, Value: streak})
+			}
+		}
+
+	case event.TypePlayerJoin:
+		key, cond := deref(e.PlayerID), "player_id = ?"
+		if key == "" {
+			key, cond = event.NormalizeName(deref(e.PlayerName)), "normalized_name = ? AND player_id IS NULL"
+		}
+		if key == "" {
+			return nil, nil
+		}
+		var first sql.NullString
+		if err := s.queryRow(ctx, `
+			SELECT MIN(ts) FROM events WHERE `+cond+` AND type = ? AND COALESCE(account, '') = ?
+		`, key, event.TypePlayerJoin, account).Scan(&first); err != nil {
+			return nil, fmt.Errorf("query first meeting: %w", err)
+		}
+		firstMet, err := time.Parse(TimeFormat, first.String)
+		if err != nil {
+			return nil, nil
+		}
+		if years := yearsBetween(firstMet, e.Ts); years >= 1 {
+			reached = append(reached, Milestone{Kind: MilestonePlayerAnniversary, Subject: key, Name: deref(e.PlayerName), Value: years})
+		}
+	}
+
+	var recorded []Milestone
+	for _, m := range reached {
+		m.Account, m.ReachedAt, m.EventID = account, e.Ts, e.ID
+		result, err := s.exec(ctx, insertMilestoneQuery, milestoneArgs(m)...)
+		if err != nil {
+			return recorded, fmt.Errorf("insert milestone: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			m.ID, _ = result.LastInsertId()
+			recorded = append(recorded, m)
+		}
+	}
+	return recorded, nil
+}
+
+// ListMilestones returns milestones, most recently reached first.
+func (s *Store) ListMilestones(ctx context.Context, f MilestoneFilter) ([]Milestone, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	query := `SELECT id, kind, account, subject, name, value, reached_at, event_id FROM milestones WHERE 1 = 1`
+	var args []any
+	if f.Kind != "" {
+		query += " AND kind = ?"
+		args = append(args, f.Kind)
+	}
+	if f.Account != "" {
+		query += " AND account = ?"
+		args = append(args, f.Account)
+	}
+	query += " ORDER BY reached_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query milestones: %w", err)
+	}
+	defer rows.Close()
+
+	milestones := []Milestone{}
+	for rows.Next() {
+		var m Milestone
+		var reachedAt string
+		var eventID sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.Kind, &m.Account, &m.Subject, &m.Name, &m.Value, &reachedAt, &eventID); err != nil {
+			return nil, fmt.Errorf("scan milestone: %w", err)
+		}
+		m.ReachedAt, _ = time.Parse(TimeFormat, reachedAt)
+		m.EventID = eventID.Int64
+		milestones = append(milestones, m)
+	}
+	return milestones, rows.Err()
+}
+
+// CurrentStreak returns the number of local days in a row, up to now, with
+// a world join. A streak not yet continued today still counts. An empty
+// account covers all accounts.
+func (s *Store) CurrentStreak(ctx context.Context, account string, now time.Time) (int, error) {
+	cond, args := accountClause(account)
+	day := localDay(now)
+	streak, err := s.playStreak(ctx, day, cond, args)
+	if err != nil || streak > 0 {
+		return streak, err
+	}
+	return s.playStreak(ctx, day.AddDate(0, 0, -1), cond, args)
+}
+
+// playStreak counts the local days in a row with a world join that end with
+// day, up to the longest of PlayStreakMilestones. cond restricts the events
+// (with leading " AND").
+func (s *Store) playStreak(ctx context.Context, day time.Time, cond string, args []any) (int, error) {
+	longest := slices.Max(PlayStreakMilestones)
+	streak := 0
+	for ; streak < longest; streak++ {
+		var played int
+		next := day.AddDate(0, 0, 1)
+		if err := s.queryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM events WHERE type = ? AND ts >= ? AND ts < ?`+cond+`)
+		`, append([]any{event.TypeWorldJoin, day.UTC().Format(TimeFormat), next.UTC().Format(TimeFormat)}, args...)...).Scan(&played); err != nil {
+			return 0, fmt.Errorf("check play day: %w", err)
+		}
+		if played == 0 {
+			break
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return streak, nil
+}
+
+// milestoneTracker finds the milestones of events fed to it in time order,
+// with the same rules as RecordMilestones.
+type milestoneTracker struct {
+	visits   map[[2]string]int       // account, world ID
+	firstMet map[[2]string]time.Time // account, player key
+	playDay  map[string]time.Time    // account -> last local day with a world join
+	streak   map[string]int          // account -> days in a row up to playDay
+}
+
+func newMilestoneTracker() *milestoneTracker {
+	return &milestoneTracker{
+		visits:   make(map[[2]string]int),
+		firstMet: make(map[[2]string]time.Time),
+		playDay:  make(map[string]time.Time),
+		streak:   make(map[string]int),
+	}
+}
+
+func (t *milestoneTracker) worldJoin(id int64, ts time.Time, account, worldID, worldName string) []Milestone {
+	var reached []Milestone
+	if worldID != "" {
+		key := [2]string{account, worldID}
+		t.visits[key]++
+		if v := t.visits[key]; slices.Contains(WorldVisitMilestones, v) {
+			reached = append(reached, Milestone{Kind: MilestoneWorldVisits, Subject: worldID, Name: worldName, Value: v})
+		}
+	}
+
+	day := localDay(ts)
+	last, ok := t.playDay[account]
+	if ok && last.Equal(day) {
+		return t.stamp(reached, id, ts, account)
+	}
+	if ok && last.AddDate(0, 0, 1).Equal(day) {
+		t.streak[account]++
+	} else {
+		t.streak[account] = 1
+	}
+	t.playDay[account] = day
+	if v := t.streak[account]; slices.Contains(PlayStreakMilestones, v) {
+		reached = append(reached, Milestone{Kind: MilestonePlayStreak, Value: v})
+	}
+	return t.stamp(reached, id, ts, account)
+}
+
+func (t *milestoneTracker) playerJoin(id int64, ts time.Time, account, player, playerName string) []Milestone {
+	if player == "" {
+		return nil
+	}
+	key := [2]string{account, player}
+	first, ok := t.firstMet[key]
+	if !ok {
+		t.firstMet[key] = ts
+		return nil
+	}
+	years := yearsBetween(first, ts)
+	if years < 1 {
+		return nil
+	}
+	// Repeats of a year are dropped on insert
+	return t.stamp([]Milestone{{Kind: MilestonePlayerAnniversary, Subject: player, Name: playerName, Value: years}}, id, ts, account)
+}
+
+func (t *milestoneTracker) stamp(reached []Milestone, id int64, ts time.Time, account string) []Milestone {
+	for i := range reached {
+		reached[i].Account, reached[i].ReachedAt, reached[i].EventID = account, ts, id
+	}
+	return reached
+}
+
+// localDay returns local midnight of the day of t.
+func localDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// yearsBetween returns the whole years from a to b, in local time.
+func yearsBetween(a, b time.Time) int {
+	a, b = a.Local(), b.Local()
+	years := b.Year() - a.Year()
+	if a.AddDate(years, 0, 0).After(b) {
+		years--
+	}
+	return years
+}
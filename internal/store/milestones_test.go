@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// insertJoin inserts a world join (player empty) or player join and
+// returns the event.
+func insertJoin(t *testing.T, st *Store, ts time.Time, world, player string) *event.Event {
+	t.Helper()
+	ev := &event.Event{Ts: ts, IngestedAt: ts, DedupeKey: fmt.Sprintf("ms-%s-%s-%d", world, player, ts.UnixNano())}
+	if player != "" {
+		ev.Type = event.TypePlayerJoin
+		ev.PlayerName = event.StringPtr(player)
+	} else {
+		ev.Type = event.TypeWorldJoin
+		ev.WorldID = event.StringPtr(world)
+		ev.WorldName = event.StringPtr("World " + world)
+	}
+	if _, _, err := st.InsertEvent(context.Background(), ev); err != nil {
+		t.Fatalf("InsertEvent: %v", err)
+	}
+	return ev
+}
+
+func TestRecordMilestones(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 20, 0, 0, 0, time.Local)
+
+	// Ten visits to one world over seven evenings in a row
+	var got []Milestone
+	for i := range 10 {
+		ev := insertJoin(t, st, start.AddDate(0, 0, min(i, 6)).Add(time.Duration(i)*time.Minute), "wrld_a", "")
+		reached, err := st.RecordMilestones(ctx, ev)
+		if err != nil {
+			t.Fatalf("RecordMilestones: %v", err)
+		}
+		got = append(got, reached...)
+	}
+	if len(got) != 2 {
+		t.Fatalf("milestones = %+v, want a 7-day streak and 10 visits", got)
+	}
+	if got[0].Kind != MilestonePlayStreak || got[0].Value != 7 || !got[0].ReachedAt.Equal(start.AddDate(0, 0, 6).Add(6*time.Minute)) {
+		t.Errorf("first milestone = %+v, want the 7-day streak", got[0])
+	}
+	if got[1].Kind != MilestoneWorldVisits || got[1].Value != 10 || got[1].Subject != "wrld_a" || got[1].Name != "World wrld_a" || got[1].ID == 0 {
+		t.Errorf("second milestone = %+v, want the 10th visit", got[1])
+	}
+
+	// Meeting Alice again a year later, twice
+	alice := insertJoin(t, st, start, "", "Alice")
+	if reached, _ := st.RecordMilestones(ctx, alice); len(reached) != 0 {
+		t.Errorf("first meeting reached %+v", reached)
+	}
+	for i, want := range []int{1, 0} {
+		ev := insertJoin(t, st, start.AddDate(1, 0, i), "", "Alice")
+		reached, err := st.RecordMilestones(ctx, ev)
+		if err != nil {
+			t.Fatalf("RecordMilestones: %v", err)
+		}
+		if len(reached) != want {
+			t.Fatalf("meeting %d a year later reached %+v, want %d", i+1, reached, want)
+		}
+		if want == 1 && (reached[0].Kind != MilestonePlayerAnniversary || reached[0].Value != 1 || reached[0].Subject != "alice") {
+			t.Errorf("anniversary = %+v", reached[0])
+		}
+	}
+
+	all, err := st.ListMilestones(ctx, MilestoneFilter{})
+	if err != nil {
+		t.Fatalf("ListMilestones: %v", err)
+	}
+	if len(all) != 3 || all[0].Kind != MilestonePlayerAnniversary {
+		t.Errorf("ListMilestones = %+v, want 3, anniversary first", all)
+	}
+	streaks, _ := st.ListMilestones(ctx, MilestoneFilter{Kind: MilestonePlayStreak})
+	if len(streaks) != 1 {
+		t.Errorf("streak milestones = %+v", streaks)
+	}
+}
+
+func TestMilestones_Backfill(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	start := time.Date(2023, 5, 1, 21, 0, 0, 0, time.Local)
+
+	for i := range 25 {
+		insertJoin(t, st, start.AddDate(0, 0, i), "wrld_b", "")
+	}
+	insertJoin(t, st, start, "", "Bob")
+	insertJoin(t, st, start.AddDate(2, 0, 1), "", "Bob")
+
+	// As if upgrading from a version without milestones
+	if _, err := st.db.ExecContext(ctx, "DROP TABLE milestones"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.createMilestonesTable(ctx); err != nil {
+		t.Fatalf("createMilestonesTable: %v", err)
+	}
+
+	got, err := st.ListMilestones(ctx, MilestoneFilter{Limit: 100})
+	if err != nil {
+		t.Fatalf("ListMilestones: %v", err)
+	}
+	var summary []string
+	for _, m := range got {
+		summary = append(summary, fmt.Sprintf("%s:%d", m.Kind, m.Value))
+	}
+	want := "player_anniversary:2 world_visits:25 play_streak:14 world_visits:10 play_streak:7"
+	if fmt.Sprint(summary) != "["+want+"]" {
+		t.Errorf("backfilled %v, want %s", summary, want)
+	}
+	if !got[3].ReachedAt.Equal(start.AddDate(0, 0, 9)) {
+		t.Errorf("10th visit reached at %v, want %v", got[3].ReachedAt, start.AddDate(0, 0, 9))
+	}
+}
+
+func TestCurrentStreak(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	today := localDay(time.Now()).Add(12 * time.Hour)
+
+	if n, err := st.CurrentStreak(ctx, "", time.Now()); err != nil || n != 0 {
+		t.Errorf("CurrentStreak = %d, %v on an empty store", n, err)
+	}
+
+	// Three days in a row ending yesterday, after a gap
+	insertJoin(t, st, today.AddDate(0, 0, -5), "wrld_c", "")
+	for i := 1; i <= 3; i++ {
+		insertJoin(t, st, today.AddDate(0, 0, -i), "wrld_c", "")
+	}
+	if n, _ := st.CurrentStreak(ctx, "", today); n != 3 {
+		t.Errorf("CurrentStreak = %d before playing today, want 3", n)
+	}
+	insertJoin(t, st, today, "wrld_c", "")
+	if n, _ := st.CurrentStreak(ctx, "", today); n != 4 {
+		t.Errorf("CurrentStreak = %d after playing today, want 4", n)
+	}
+}
+
+func TestYearsBetween(t *testing.T) {
+	first := time.Date(2020, 2, 29, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		t    time.Time
+		want int
+	}{
+		{time.Date(2021, 2, 28, 12, 0, 0, 0, time.Local), 0},
+		{time.Date(2021, 3, 1, 12, 0, 0, 0, time.Local), 1},
+		{time.Date(2024, 2, 29, 11, 0, 0, 0, time.Local), 3},
+		{time.Date(2024, 2, 29, 12, 0, 0, 0, time.Local), 4},
+	}
+	for _, tt := range tests {
+		if got := yearsBetween(first, tt.t); got != tt.want {
+			t.Errorf("yearsBetween(%v, %v) = %d, want %d", first, tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Note is a user-written annotation attached to an event. Notes on a
+// world_join event annotate the whole instance session.
+type Note struct {
+	ID        int64     `json:"id"`
+	EventID   int64     `json:"event_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NoteFilter contains filter options for listing notes.
+type NoteFilter struct {
+	EventID *int64 // only notes on this event
+	Query   string // case-insensitive substring match on text
+	Limit   int
+}
+
+// AddNote attaches a note to an event. Returns ErrNotFound if the event
+// does not exist.
+func (s *Store) AddNote(ctx context.Context, eventID int64, text string) (*Note, error) {
+	now := time.Now().UTC()
+	result, err := s.exec(ctx, `
+		INSERT INTO notes (event_id, text, created_at)
+		SELECT id, ?, ? FROM events WHERE id = ?
+	`, text, now.Format(TimeFormat), eventID)
+	if err != nil {
+		return nil, fmt.Errorf("insert note: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return nil, ErrNotFound
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("last insert id: %w", err)
+	}
+	return &Note{ID: id, EventID: eventID, Text: text, CreatedAt: now}, nil
+}
+
+// ListNotes returns notes matching the filter, newest first.
+func (s *Store) ListNotes(ctx context.Context, f NoteFilter) ([]Note, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	var (
+		sb   strings.Builder
+		args []any
+	)
+	sb.WriteString(`SELECT id, event_id, text, created_at FROM notes WHERE 1=1`)
+	if f.EventID != nil {
+		sb.WriteString(" AND event_id = ?")
+		args = append(args, *f.EventID)
+	}
+	if f.Query != "" {
+		sb.WriteString(` AND text LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(f.Query)+"%")
+	}
+	sb.WriteString(" ORDER BY created_at DESC, id DESC LIMIT ?")
+	args = append(args, limit)
+
+	rows, err := s.query(ctx, sb.String(), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []Note{}
+	for rows.Next() {
+		var (
+			n         Note
+			createdAt string
+		)
+		if err := rows.Scan(&n.ID, &n.EventID, &n.Text, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan note: %w", err)
+		}
+		if n.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+			return nil, fmt.Errorf("parse created_at %q: %w", createdAt, err)
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return notes, nil
+}
+
+// DeleteNote removes a note. Returns ErrNotFound if it does not exist.
+func (s *Store) DeleteNote(ctx context.Context, id int64) error {
+	result, err := s.exec(ctx, `DELETE FROM notes WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete note: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// escapeLike escapes LIKE wildcards so s matches literally (ESCAPE '\').
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestNotes_AddListDelete(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	e := &event.Event{Ts: now, Type: event.TypeWorldJoin, DedupeKey: "w", IngestedAt: now}
+	if _, _, err := st.InsertEvent(ctx, e); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if _, err := st.AddNote(ctx, 999, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddNote on missing event: err = %v, want ErrNotFound", err)
+	}
+
+	first, err := st.AddNote(ctx, e.ID, "Great 100% fireworks show")
+	if err != nil {
+		t.Fatalf("AddNote: %v", err)
+	}
+	if _, err := st.AddNote(ctx, e.ID, "met Alice here"); err != nil {
+		t.Fatalf("AddNote: %v", err)
+	}
+
+	notes, err := st.ListNotes(ctx, NoteFilter{EventID: &e.ID})
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	if len(notes) != 2 {
+		t.Fatalf("got %d notes, want 2", len(notes))
+	}
+
+	// Search is case-insensitive and treats wildcards literally
+	for q, want := range map[string]int{"FIREWORKS": 1, "100%": 1, "%": 1, "_": 0, "alice": 1} {
+		notes, err := st.ListNotes(ctx, NoteFilter{Query: q})
+		if err != nil {
+			t.Fatalf("ListNotes(%q): %v", q, err)
+		}
+		if len(notes) != want {
+			t.Errorf("ListNotes(%q) = %d notes, want %d", q, len(notes), want)
+		}
+	}
+
+	if err := st.DeleteNote(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteNote: %v", err)
+	}
+	if err := st.DeleteNote(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteNote: err = %v, want ErrNotFound", err)
+	}
+}
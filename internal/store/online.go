@@ -0,0 +1,249 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// CurrentInstance is the instance an account is in and the players there,
+// as kept by the store in step with inserted events.
+type CurrentInstance struct {
+	Account    string
+	WorldID    string
+	WorldName  string
+	InstanceID string
+	JoinedAt   time.Time
+	Players    []OnlinePlayer // oldest join first
+}
+
+// OnlinePlayer is a player in the current instance.
+type OnlinePlayer struct {
+	PlayerName string
+	PlayerID   string
+	JoinedAt   time.Time
+}
+
+// CurrentInstance returns the instance account is in, from the latest
+// world join, with the players who joined since and have not left. It
+// reads two small tables instead of the events. Returns ErrNotFound if
+// the account never joined a world.
+func (s *Store) CurrentInstance(ctx context.Context, account string) (*CurrentInstance, error) {
+	ci := &CurrentInstance{Account: account, Players: []OnlinePlayer{}}
+	var worldID, worldName, instanceID sql.NullString
+	var joinedAt string
+	err := s.queryRow(ctx, `
+		SELECT world_id, world_name, instance_id, joined_at FROM current_instances WHERE account = ?
+	`, account).Scan(&worldID, &worldName, &instanceID, &joinedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get current instance: %w", err)
+	}
+	ci.WorldID, ci.WorldName, ci.InstanceID = worldID.String, worldName.String, instanceID.String
+	if ci.JoinedAt, err = time.Parse(TimeFormat, joinedAt); err != nil {
+		return nil, fmt.Errorf("parse joined_at %q: %w", joinedAt, err)
+	}
+
+	rows, err := s.query(ctx, `
+		SELECT player_name, player_id, joined_at FROM online_players
+		WHERE account = ? ORDER BY joined_at, player_key
+	`, account)
+	if err != nil {
+		return nil, fmt.Errorf("query online players: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, id sql.NullString
+		var ts string
+		if err := rows.Scan(&name, &id, &ts); err != nil {
+			return nil, fmt.Errorf("scan online player: %w", err)
+		}
+		p := OnlinePlayer{PlayerName: name.String, PlayerID: id.String}
+		if p.JoinedAt, err = time.Parse(TimeFormat, ts); err != nil {
+			return nil, fmt.Errorf("parse joined_at %q: %w", ts, err)
+		}
+		ci.Players = append(ci.Players, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return ci, nil
+}
+
+// createOnlineTables creates the current_instances and online_players
+// tables and the triggers that update them with each inserted event, the
+// way derive.State follows events: a world join replaces the account's
+// instance and empties its player list, a player join adds the player
+// (keyed by ID, or name without one) and a leave removes them. Events
+// older than the current instance or the player's join are ignored, so
+// late or replayed events cannot roll the state back. The tables are
+// filled from the events when first created.
+func (s *Store) createOnlineTables(ctx context.Context) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'current_instances'`).Scan(&exists); err != nil {
+		return fmt.Errorf("check current_instances table: %w", err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS current_instances (
+		account     TEXT PRIMARY KEY,
+		world_id    TEXT,
+		world_name  TEXT,
+		instance_id TEXT,
+		joined_at   TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS online_players (
+		account     TEXT NOT NULL,
+		player_key  TEXT NOT NULL,
+		player_name TEXT,
+		player_id   TEXT,
+		joined_at   TEXT NOT NULL,
+		PRIMARY KEY (account, player_key)
+	) WITHOUT ROWID;
+
+	CREATE TRIGGER IF NOT EXISTS events_online_world AFTER INSERT ON events
+	WHEN NEW.type = 'world_join' AND NEW.ts >= COALESCE(
+		(SELECT joined_at FROM current_instances WHERE account = COALESCE(NEW.account, '')), '')
+	BEGIN
+		DELETE FROM online_players WHERE account = COALESCE(NEW.account, '');
+		INSERT INTO current_instances (account, world_id, world_name, instance_id, joined_at)
+		VALUES (COALESCE(NEW.account, ''), NEW.world_id, NEW.world_name, NEW.instance_id, NEW.ts)
+		ON CONFLICT (account) DO UPDATE SET world_id = excluded.world_id, world_name = excluded.world_name,
+			instance_id = excluded.instance_id, joined_at = excluded.joined_at;
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_online_join AFTER INSERT ON events
+	WHEN NEW.type = 'player_join' AND COALESCE(NULLIF(NEW.player_id, ''), NEW.player_name, '') != ''
+		AND NEW.ts >= COALESCE((SELECT joined_at FROM current_instances WHERE account = COALESCE(NEW.account, '')), '')
+	BEGIN
+		INSERT INTO online_players (account, player_key, player_name, player_id, joined_at)
+		VALUES (COALESCE(NEW.account, ''), COALESCE(NULLIF(NEW.player_id, ''), NEW.player_name),
+			NEW.player_name, NEW.player_id, NEW.ts)
+		ON CONFLICT DO NOTHING;
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_online_left AFTER INSERT ON events
+	WHEN NEW.type = 'player_left'
+	BEGIN
+		DELETE FROM online_players
+		WHERE account = COALESCE(NEW.account, '') AND player_key = COALESCE(NULLIF(NEW.player_id, ''), NEW.player_name)
+			AND joined_at <= NEW.ts;
+	END;
+	`
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create online tables: %w", err)
+	}
+	if exists == 0 {
+		return s.RebuildOnline(ctx)
+	}
+	return nil
+}
+
+// RebuildOnline refills current_instances and online_players from the
+// events, e.g. after events were deleted.
+func (s *Store) RebuildOnline(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin rebuild: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM online_players; DELETE FROM current_instances;`); err != nil {
+		return fmt.Errorf("clear online tables: %w", err)
+	}
+	// The bare columns come from the row with the latest ts of each account
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO current_instances (account, world_id, world_name, instance_id, joined_at)
+		SELECT COALESCE(account, ''), world_id, world_name, instance_id, MAX(ts)
+		FROM events WHERE type = ? GROUP BY account
+	`, event.TypeWorldJoin); err != nil {
+		return fmt.Errorf("rebuild current instances: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT account, joined_at FROM current_instances`)
+	if err != nil {
+		return fmt.Errorf("query current instances: %w", err)
+	}
+	since := make(map[string]string)
+	for rows.Next() {
+		var account, joinedAt string
+		if err := rows.Scan(&account, &joinedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan current instance: %w", err)
+		}
+		since[account] = joinedAt
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	for account, joinedAt := range since {
+		if err := rebuildOnlinePlayers(ctx, tx, account, joinedAt); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rebuild: %w", err)
+	}
+	return nil
+}
+
+// rebuildOnlinePlayers replays the player joins and leaves of account
+// since its world join at joinedAt into online_players.
+func rebuildOnlinePlayers(ctx context.Context, tx *sql.Tx, account, joinedAt string) error {
+	var accountArg any
+	if account != "" {
+		accountArg = account
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT type, player_name, player_id, ts FROM events
+		WHERE account IS ? AND ts >= ? AND type IN (?, ?)
+		ORDER BY ts, id
+	`, accountArg, joinedAt, event.TypePlayerJoin, event.TypePlayerLeft)
+	if err != nil {
+		return fmt.Errorf("query player events: %w", err)
+	}
+	defer rows.Close()
+
+	type player struct{ name, id sql.NullString }
+	online := make(map[string]player)
+	joined := make(map[string]string)
+	for rows.Next() {
+		var typ, ts string
+		var name, id sql.NullString
+		if err := rows.Scan(&typ, &name, &id, &ts); err != nil {
+			return fmt.Errorf("scan player event: %w", err)
+		}
+		key := id.String
+		if key == "" {
+			key = name.String
+		}
+		if key == "" {
+			continue
+		}
+		if typ == event.TypePlayerLeft {
+			delete(online, key)
+		} else if _, ok := online[key]; !ok {
+			online[key] = player{name, id}
+			joined[key] = ts
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+
+	for key, p := range online {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO online_players (account, player_key, player_name, player_id, joined_at) VALUES (?, ?, ?, ?, ?)
+		`, account, key, p.name, p.id, joined[key]); err != nil {
+			return fmt.Errorf("insert online player: %w", err)
+		}
+	}
+	return nil
+}
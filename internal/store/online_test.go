@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestCurrentInstance(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+	defer st.Close()
+
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	n := 0
+	insert := func(offset time.Duration, typ, name, id string, account *string) {
+		t.Helper()
+		n++
+		e := &event.Event{Ts: base.Add(offset), Type: typ, Account: account,
+			DedupeKey: fmt.Sprintf("k%d", n), IngestedAt: base}
+		if typ == event.TypeWorldJoin {
+			e.WorldID, e.WorldName, e.InstanceID = event.StringPtr(id), event.StringPtr(name), event.StringPtr("1")
+		} else {
+			e.PlayerName = event.StringPtr(name)
+			if id != "" {
+				e.PlayerID = event.StringPtr(id)
+			}
+		}
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+	names := func(ci *CurrentInstance) []string {
+		var out []string
+		for _, p := range ci.Players {
+			out = append(out, p.PlayerName)
+		}
+		return out
+	}
+
+	if _, err := st.CurrentInstance(ctx, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("CurrentInstance before any world join: err = %v, want ErrNotFound", err)
+	}
+
+	alt := event.StringPtr("alt")
+	insert(0, event.TypeWorldJoin, "Old World", "wrld_old", nil)
+	insert(time.Minute, event.TypePlayerJoin, "Carol", "", nil)
+	insert(2*time.Minute, event.TypeWorldJoin, "Home", "wrld_home", nil)
+	insert(3*time.Minute, event.TypePlayerJoin, "Alice", "usr_a", nil)
+	insert(4*time.Minute, event.TypePlayerJoin, "Bob", "", nil)
+	insert(5*time.Minute, event.TypePlayerJoin, "Dave", "", nil)
+	insert(6*time.Minute, event.TypePlayerLeft, "Dave", "", nil)
+	insert(7*time.Minute, event.TypePlayerJoin, "Erin", "", alt)
+	// Late events from before the current instance change nothing
+	insert(90*time.Second, event.TypePlayerJoin, "Frank", "", nil)
+	insert(-time.Minute, event.TypeWorldJoin, "Older World", "wrld_older", nil)
+
+	ci, err := st.CurrentInstance(ctx, "")
+	if err != nil {
+		t.Fatalf("CurrentInstance: %v", err)
+	}
+	if ci.WorldID != "wrld_home" || !ci.JoinedAt.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("world = %s joined %v, want wrld_home", ci.WorldID, ci.JoinedAt)
+	}
+	if got := names(ci); !reflect.DeepEqual(got, []string{"Alice", "Bob"}) {
+		t.Errorf("players = %v, want [Alice Bob]", got)
+	}
+	// Erin joined on another account that never joined a world
+	if _, err := st.CurrentInstance(ctx, "alt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("alt account: err = %v, want ErrNotFound", err)
+	}
+
+	// Rebuilding from the events gives the same state
+	if err := st.RebuildOnline(ctx); err != nil {
+		t.Fatalf("RebuildOnline: %v", err)
+	}
+	rebuilt, err := st.CurrentInstance(ctx, "")
+	if err != nil || !reflect.DeepEqual(rebuilt, ci) {
+		t.Errorf("rebuilt = %+v, %v; want %+v", rebuilt, err, ci)
+	}
+
+	// Deleting the current world join goes back to the previous instance
+	world := "wrld_home"
+	if _, err := st.DeleteEvents(ctx, QueryFilter{World: &world}, 0, ""); err != nil {
+		t.Fatalf("DeleteEvents: %v", err)
+	}
+	ci, err = st.CurrentInstance(ctx, "")
+	if err != nil {
+		t.Fatalf("CurrentInstance after delete: %v", err)
+	}
+	if ci.WorldID != "wrld_old" || len(ci.Players) != 4 {
+		t.Errorf("after delete: world %s with %v, want wrld_old with Carol, Frank, Alice and Bob", ci.WorldID, names(ci))
+	}
+}
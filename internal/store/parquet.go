@@ -0,0 +1,109 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/parquet"
+)
+
+// ParquetHeaderKey is the file metadata key that holds the ExportHeader,
+// as JSON, in a Parquet export.
+const ParquetHeaderKey = "vrclog.export"
+
+// parquetColumns maps event.Event to Parquet columns, one per JSON field,
+// plus the notes on the event. meta stays a JSON string, and notes is a
+// JSON array of strings.
+var parquetColumns = []parquet.Column{
+	{Name: "id", Type: parquet.Int64},
+	{Name: "ts", Type: parquet.Timestamp},
+	{Name: "type", Type: parquet.String},
+	{Name: "player_name", Type: parquet.String, Optional: true},
+	{Name: "player_id", Type: parquet.String, Optional: true},
+	{Name: "world_id", Type: parquet.String, Optional: true},
+	{Name: "world_name", Type: parquet.String, Optional: true},
+	{Name: "instance_id", Type: parquet.String, Optional: true},
+	{Name: "instance_type", Type: parquet.String, Optional: true},
+	{Name: "region", Type: parquet.String, Optional: true},
+	{Name: "group_id", Type: parquet.String, Optional: true},
+	{Name: "duration_sec", Type: parquet.Int64, Optional: true},
+	{Name: "account", Type: parquet.String, Optional: true},
+	{Name: "meta", Type: parquet.String, Optional: true},
+	{Name: "ingested_at", Type: parquet.Timestamp},
+	{Name: "notes", Type: parquet.String, Optional: true},
+}
+
+// parquetExportWriter writes events as rows of a Parquet file, with the
+// header in the file metadata.
+type parquetExportWriter struct {
+	pw  *parquet.Writer
+	row []any
+	exportOptions
+}
+
+// NewParquetExportWriter returns an ExportWriter for Parquet. Nothing is
+// written to w before the first row group is full or the export ends.
+func NewParquetExportWriter(w io.Writer, opts ...ExportOption) ExportWriter {
+	return &parquetExportWriter{
+		pw:            parquet.NewWriter(w, parquetColumns),
+		row:           make([]any, len(parquetColumns)),
+		exportOptions: newExportOptions(opts),
+	}
+}
+
+func (p *parquetExportWriter) WriteHeader(h ExportHeader) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	p.pw.SetMetadata(ParquetHeaderKey, string(b))
+	return nil
+}
+
+func (p *parquetExportWriter) WriteEvent(e *event.Event, notes []string) error {
+	e = p.apply(e)
+	r := p.row
+	r[0] = e.ID
+	r[1] = e.Ts
+	r[2] = e.Type
+	r[3] = optString(e.PlayerName)
+	r[4] = optString(e.PlayerID)
+	r[5] = optString(e.WorldID)
+	r[6] = optString(e.WorldName)
+	r[7] = optString(e.InstanceID)
+	r[8] = optString(e.InstanceType)
+	r[9] = optString(e.Region)
+	r[10] = optString(e.GroupID)
+	r[11] = nil
+	if e.DurationSec != nil {
+		r[11] = *e.DurationSec
+	}
+	r[12] = optString(e.Account)
+	r[13] = nil
+	if len(e.MetaJSON) > 0 {
+		r[13] = string(e.MetaJSON)
+	}
+	r[14] = e.IngestedAt
+	r[15] = nil
+	if len(notes) > 0 {
+		b, err := json.Marshal(notes)
+		if err != nil {
+			return err
+		}
+		r[15] = string(b)
+	}
+	return p.pw.Write(r)
+}
+
+func (p *parquetExportWriter) Close() error {
+	return p.pw.Close()
+}
+
+// optString returns *s, or nil for a nil s.
+func optString(s *string) any {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
@@ -3,29 +3,72 @@ package store
 import (
 	"context"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
-// InsertParseFailure inserts a parse failure into the database.
-// Returns true if the failure was inserted, false if it was a duplicate.
-// Uses ON CONFLICT(dedupe_key) DO NOTHING for deduplication.
+// Limits of the parse_failures table, so a corrupted log cannot make it
+// dominate the database.
+const (
+	// DefaultParseFailureLimit is the number of rows kept; the oldest are
+	// deleted beyond it.
+	DefaultParseFailureLimit = 10000
+	// ParseFailureSamples is the number of lines stored per failure
+	// pattern. Further lines of the pattern are only counted.
+	ParseFailureSamples = 5
+	// maxParseFailurePattern is the length in bytes a pattern is cut to.
+	maxParseFailurePattern = 200
+)
+
+// InsertParseFailure records a parse failure. Lines that differ only in
+// timestamps, numbers and IDs share a pattern: the first
+// ParseFailureSamples lines of a pattern are stored, later ones increment
+// the count of the newest stored line instead. Returns true if the failure
+// was recorded either way, false if the same line was already stored.
 func (s *Store) InsertParseFailure(ctx context.Context, rawLine, errorMsg string) (inserted bool, err error) {
 	if rawLine == "" {
 		return false, fmt.Errorf("raw_line is required")
 	}
 
-	const query = `
-	INSERT INTO parse_failures (ts, raw_line, error_msg, dedupe_key)
-	VALUES (?, ?, ?, ?)
-	ON CONFLICT(dedupe_key) DO NOTHING
-	`
-
 	dedupeKey := sha256Hex(rawLine)
+	patternKey := parseFailurePatternKey(rawLine, errorMsg)
 	ts := time.Now().UTC().Format(TimeFormat)
 
-	result, err := s.db.ExecContext(ctx, query, ts, rawLine, errorMsg, dedupeKey)
+	var exists int
+	err = s.queryRow(ctx, `SELECT 1 FROM parse_failures WHERE dedupe_key = ?`, dedupeKey).Scan(&exists)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("check parse failure: %w", err)
+	}
+
+	var samples int
+	var newest sql.NullInt64
+	if err := s.queryRow(ctx, `SELECT COUNT(*), MAX(id) FROM parse_failures WHERE pattern_key = ?`,
+		patternKey).Scan(&samples, &newest); err != nil {
+		return false, fmt.Errorf("count parse failure samples: %w", err)
+	}
+	if samples >= ParseFailureSamples {
+		if _, err := s.exec(ctx, `UPDATE parse_failures SET count = count + 1, last_ts = ? WHERE id = ?`,
+			ts, newest.Int64); err != nil {
+			return false, fmt.Errorf("count parse failure: %w", err)
+		}
+		return true, nil
+	}
+
+	const query = `
+	INSERT INTO parse_failures (ts, raw_line, error_msg, dedupe_key, pattern_key, count, last_ts)
+	VALUES (?, ?, ?, ?, ?, 1, ?)
+	ON CONFLICT(dedupe_key) DO NOTHING
+	`
+	result, err := s.exec(ctx, query, ts, rawLine, errorMsg, dedupeKey, patternKey, ts)
 	if err != nil {
 		return false, fmt.Errorf("insert parse failure: %w", err)
 	}
@@ -34,8 +77,151 @@ func (s *Store) InsertParseFailure(ctx context.Context, rawLine, errorMsg string
 	if err != nil {
 		return false, fmt.Errorf("rows affected: %w", err)
 	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		if err := s.trimParseFailures(ctx, id); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// trimParseFailures deletes the oldest parse failures beyond the limit,
+// given the newest row's id. Row ids only grow, so the rows more than the
+// limit below it are the oldest.
+func (s *Store) trimParseFailures(ctx context.Context, newestID int64) error {
+	limit := int64(s.parseFailureLimit)
+	if limit <= 0 || newestID <= limit {
+		return nil
+	}
+	if _, err := s.exec(ctx, `DELETE FROM parse_failures WHERE id <= ?`, newestID-limit); err != nil {
+		return fmt.Errorf("trim parse failures: %w", err)
+	}
+	return nil
+}
+
+var (
+	parseFailureTimestamp = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2} \d{2}:\d{2}:\d{2}\s+`)
+	parseFailureUUID      = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	parseFailureNumber    = regexp.MustCompile(`\d+`)
+)
+
+// ParseFailurePattern returns the pattern of a log line or parse error:
+// the line without its timestamp, with UUIDs replaced by "*" and numbers
+// by "#", cut to a bounded length.
+func ParseFailurePattern(s string) string {
+	p := parseFailureTimestamp.ReplaceAllString(s, "")
+	p = parseFailureUUID.ReplaceAllString(p, "*")
+	p = parseFailureNumber.ReplaceAllString(p, "#")
+	p = strings.TrimSpace(p)
+	if len(p) > maxParseFailurePattern {
+		p = p[:maxParseFailurePattern]
+		for !utf8.ValidString(p) {
+			p = p[:len(p)-1]
+		}
+	}
+	return p
+}
+
+// parseFailurePatternKey groups parse failures by the patterns of the
+// line and the error.
+func parseFailurePatternKey(rawLine, errorMsg string) string {
+	return sha256Hex(ParseFailurePattern(rawLine) + "\x00" + ParseFailurePattern(errorMsg))
+}
+
+// ParseFailure is a log line the parser could not read.
+type ParseFailure struct {
+	Ts      time.Time `json:"ts"`
+	RawLine string    `json:"raw_line"`
+	Error   string    `json:"error"`
+	Count   int64     `json:"count"` // this line and later lines of its pattern that were only counted
+}
+
+// ParseFailurePatternStats describes the failures of one pattern.
+type ParseFailurePatternStats struct {
+	Pattern   string    `json:"pattern"` // of the newest stored line
+	Error     string    `json:"error"`   // of the newest stored line
+	Count     int64     `json:"count"`   // failures recorded, including those only counted
+	Samples   int       `json:"samples"` // lines stored
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ParseFailureStats summarizes the parse_failures table.
+type ParseFailureStats struct {
+	Rows     int                        `json:"rows"`     // lines stored
+	Limit    int                        `json:"limit"`    // rows kept before the oldest are deleted
+	Failures int64                      `json:"failures"` // failures recorded, including those only counted
+	Patterns int                        `json:"patterns"`
+	Top      []ParseFailurePatternStats `json:"top"` // most frequent patterns first
+}
 
-	return rowsAffected > 0, nil
+// ParseFailureStats returns the table's size and up to limit of the most
+// frequent failure patterns. Rows stored before patterns were recorded
+// are grouped by their own line.
+func (s *Store) ParseFailureStats(ctx context.Context, limit int) (ParseFailureStats, error) {
+	stats := ParseFailureStats{Limit: s.parseFailureLimit, Top: []ParseFailurePatternStats{}}
+	if err := s.queryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(count), 0), COUNT(DISTINCT COALESCE(pattern_key, dedupe_key))
+		FROM parse_failures
+	`).Scan(&stats.Rows, &stats.Failures, &stats.Patterns); err != nil {
+		return stats, fmt.Errorf("count parse failures: %w", err)
+	}
+
+	rows, err := s.query(ctx, `
+		WITH patterns AS (
+			SELECT MAX(id) AS newest, SUM(count) AS failures, COUNT(*) AS samples,
+				MIN(ts) AS first_seen, MAX(COALESCE(last_ts, ts)) AS last_seen
+			FROM parse_failures
+			GROUP BY COALESCE(pattern_key, dedupe_key)
+			ORDER BY failures DESC, last_seen DESC
+			LIMIT ?
+		)
+		SELECT p.raw_line, p.error_msg, g.failures, g.samples, g.first_seen, g.last_seen
+		FROM patterns g JOIN parse_failures p ON p.id = g.newest
+		ORDER BY g.failures DESC, g.last_seen DESC
+	`, limit)
+	if err != nil {
+		return stats, fmt.Errorf("query parse failure patterns: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p ParseFailurePatternStats
+		var line, first, last string
+		if err := rows.Scan(&line, &p.Error, &p.Count, &p.Samples, &first, &last); err != nil {
+			return stats, fmt.Errorf("scan parse failure pattern: %w", err)
+		}
+		p.Pattern = ParseFailurePattern(line)
+		p.FirstSeen, _ = time.Parse(TimeFormat, first)
+		p.LastSeen, _ = time.Parse(TimeFormat, last)
+		stats.Top = append(stats.Top, p)
+	}
+	return stats, rows.Err()
+}
+
+// RecentParseFailures returns up to limit of the most recent parse
+// failures, newest first.
+func (s *Store) RecentParseFailures(ctx context.Context, limit int) ([]ParseFailure, error) {
+	rows, err := s.query(ctx,
+		"SELECT ts, raw_line, error_msg, count FROM parse_failures ORDER BY ts DESC, id DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, fmt.Errorf("query parse failures: %w", err)
+	}
+	defer rows.Close()
+
+	failures := []ParseFailure{}
+	for rows.Next() {
+		var f ParseFailure
+		var ts string
+		if err := rows.Scan(&ts, &f.RawLine, &f.Error, &f.Count); err != nil {
+			return nil, fmt.Errorf("scan parse failure: %w", err)
+		}
+		f.Ts, _ = time.Parse(TimeFormat, ts)
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
 }
 
 // sha256Hex returns the SHA256 hash of the input string as a hex string.
@@ -2,6 +2,8 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -146,3 +148,151 @@ func TestSha256Hex(t *testing.T) {
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
+
+func TestRecentParseFailures(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	for _, line := range []string{"line 1", "line 2", "line 3"} {
+		if _, err := store.InsertParseFailure(ctx, line, "bad "+line); err != nil {
+			t.Fatalf("insert %q: %v", line, err)
+		}
+	}
+
+	got, err := store.RecentParseFailures(ctx, 2)
+	if err != nil {
+		t.Fatalf("RecentParseFailures: %v", err)
+	}
+	if len(got) != 2 || got[0].RawLine != "line 3" || got[1].RawLine != "line 2" {
+		t.Fatalf("failures = %+v, want lines 3 and 2", got)
+	}
+	if got[0].Error != "bad line 3" || got[0].Ts.IsZero() {
+		t.Errorf("failure = %+v", got[0])
+	}
+}
+
+func TestParseFailurePattern(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"2024.01.15 12:34:56 Log        -  [Behaviour] OnPlayerJoined ???", "Log        -  [Behaviour] OnPlayerJoined ???"},
+		{"Joining wrld_4cf554b4-430c-4f8f-b53e-1f294eed230b:12345~private", "Joining wrld_*:#~private"},
+		{"unexpected token at offset 1024", "unexpected token at offset #"},
+	}
+	for _, tt := range tests {
+		if got := ParseFailurePattern(tt.in); got != tt.want {
+			t.Errorf("ParseFailurePattern(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := ParseFailurePattern(string(make([]byte, 1000))); len(got) > maxParseFailurePattern {
+		t.Errorf("pattern of a long line is %d bytes", len(got))
+	}
+}
+
+func TestInsertParseFailure_SamplesRepeatedPatterns(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	for i := range 20 {
+		line := fmt.Sprintf("2024.01.15 12:00:%02d Log - garbled entry %d", i, i)
+		if inserted, err := store.InsertParseFailure(ctx, line, "no match"); err != nil || !inserted {
+			t.Fatalf("insert %d = %v, %v", i, inserted, err)
+		}
+	}
+	if _, err := store.InsertParseFailure(ctx, "something else", "no match"); err != nil {
+		t.Fatal(err)
+	}
+	// Re-reading a stored line is not counted again
+	if inserted, _ := store.InsertParseFailure(ctx, "2024.01.15 12:00:00 Log - garbled entry 0", "no match"); inserted {
+		t.Error("stored line recorded again")
+	}
+
+	stats, err := store.ParseFailureStats(ctx, 10)
+	if err != nil {
+		t.Fatalf("ParseFailureStats: %v", err)
+	}
+	if stats.Rows != ParseFailureSamples+1 || stats.Failures != 21 || stats.Patterns != 2 {
+		t.Errorf("stats = %+v, want %d rows, 21 failures, 2 patterns", stats, ParseFailureSamples+1)
+	}
+	if len(stats.Top) != 2 {
+		t.Fatalf("top = %+v, want 2 patterns", stats.Top)
+	}
+	top := stats.Top[0]
+	if top.Pattern != "Log - garbled entry #" || top.Count != 20 || top.Samples != ParseFailureSamples || top.Error != "no match" {
+		t.Errorf("top pattern = %+v", top)
+	}
+	if top.LastSeen.Before(top.FirstSeen) {
+		t.Errorf("last seen %v before first seen %v", top.LastSeen, top.FirstSeen)
+	}
+
+	recent, err := store.RecentParseFailures(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, f := range recent {
+		total += f.Count
+	}
+	if total != 21 {
+		t.Errorf("counts of recent failures add up to %d, want 21", total)
+	}
+}
+
+func TestInsertParseFailure_Limit(t *testing.T) {
+	store := openTestStore(t)
+	store.parseFailureLimit = 10
+	ctx := context.Background()
+
+	// Distinct patterns, so each line is stored
+	for i := range 25 {
+		line := fmt.Sprintf("garbled %c%c", 'a'+i%26, 'a'+i/26)
+		if _, err := store.InsertParseFailure(ctx, line, "no match"); err != nil {
+			t.Fatalf("insert %d: %v", i, err)
+		}
+	}
+	recent, err := store.RecentParseFailures(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 10 {
+		t.Fatalf("kept %d rows, want the limit of 10", len(recent))
+	}
+	if recent[0].RawLine != "garbled ya" || recent[9].RawLine != "garbled pa" {
+		t.Errorf("kept %q to %q, want the newest", recent[9].RawLine, recent[0].RawLine)
+	}
+}
+
+func TestOpen_MigratesParseFailures(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.sqlite")
+
+	// Create a parse_failures table from before patterns were recorded
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE parse_failures (
+		id INTEGER PRIMARY KEY, ts TEXT NOT NULL, raw_line TEXT NOT NULL,
+		error_msg TEXT NOT NULL, dedupe_key TEXT NOT NULL, UNIQUE(dedupe_key)
+	);
+	INSERT INTO parse_failures (ts, raw_line, error_msg, dedupe_key) VALUES
+		('2024-01-01T00:00:00.000000000Z', 'garbled 1', 'no match', 'k1'),
+		('2024-01-01T00:00:01.000000000Z', 'garbled 2', 'no match', 'k2');
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+
+	stats, err := store.ParseFailureStats(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("ParseFailureStats: %v", err)
+	}
+	if stats.Rows != 2 || stats.Failures != 2 || stats.Patterns != 1 {
+		t.Errorf("stats = %+v, want the old rows grouped into one pattern", stats)
+	}
+}
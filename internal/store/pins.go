@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Pin marks a time range whose history must be kept: retention never
+// deletes data inside a pinned range.
+type Pin struct {
+	ID        int64      `json:"id"`
+	Since     time.Time  `json:"since"`
+	Until     *time.Time `json:"until,omitempty"`    // nil while the pinned session is still open
+	EventID   int64      `json:"event_id,omitempty"` // world_join that started a pinned session
+	Label     string     `json:"label,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// pinnedCond is an SQL expression that is true when the ts column of the
+// outer query falls inside a pinned range.
+const pinnedCond = `EXISTS (
+	SELECT 1 FROM pins p WHERE ts >= p.since AND (p.until IS NULL OR ts < p.until)
+)`
+
+// SavePin stores a pin and returns it with its ID.
+func (s *Store) SavePin(ctx context.Context, p Pin) (*Pin, error) {
+	now := time.Now().UTC()
+	var until, eventID any
+	if p.Until != nil {
+		until = p.Until.UTC().Format(TimeFormat)
+	}
+	if p.EventID != 0 {
+		eventID = p.EventID
+	}
+
+	res, err := s.exec(ctx, `
+		INSERT INTO pins (since, until, event_id, label, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, p.Since.UTC().Format(TimeFormat), until, eventID, p.Label, now.Format(TimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("save pin: %w", err)
+	}
+	if p.ID, err = res.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("last insert id: %w", err)
+	}
+	p.CreatedAt = now
+	return &p, nil
+}
+
+// ListPins returns all pins, newest range first.
+func (s *Store) ListPins(ctx context.Context) ([]Pin, error) {
+	if err := s.closeOpenPins(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := s.query(ctx, `
+		SELECT id, since, until, event_id, label, created_at
+		FROM pins
+		ORDER BY since DESC, id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query pins: %w", err)
+	}
+	defer rows.Close()
+
+	pins := []Pin{}
+	for rows.Next() {
+		var (
+			p                Pin
+			since, createdAt string
+			until            sql.NullString
+			eventID          sql.NullInt64
+		)
+		if err := rows.Scan(&p.ID, &since, &until, &eventID, &p.Label, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan pin: %w", err)
+		}
+		if p.Since, err = time.Parse(TimeFormat, since); err != nil {
+			return nil, fmt.Errorf("parse since %q: %w", since, err)
+		}
+		if until.Valid {
+			t, err := time.Parse(TimeFormat, until.String)
+			if err != nil {
+				return nil, fmt.Errorf("parse until %q: %w", until.String, err)
+			}
+			p.Until = &t
+		}
+		p.EventID = eventID.Int64
+		if p.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+			return nil, fmt.Errorf("parse created_at %q: %w", createdAt, err)
+		}
+		pins = append(pins, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return pins, nil
+}
+
+// DeletePin removes a pin. Returns ErrNotFound if it does not exist.
+func (s *Store) DeletePin(ctx context.Context, id int64) error {
+	result, err := s.exec(ctx, `DELETE FROM pins WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete pin: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SessionEnd returns when the instance session started by world_join event
+// e ended: the next world_join carrying a world ID (not the "Entering Room"
+// name line) of the same account. Returns nil if the session is still open.
+func (s *Store) SessionEnd(ctx context.Context, e *event.Event) (*time.Time, error) {
+	tsStr := e.Ts.UTC().Format(TimeFormat)
+	var account any
+	if e.Account != nil {
+		account = *e.Account
+	}
+
+	var next string
+	err := s.queryRow(ctx, `
+		SELECT ts FROM events
+		WHERE type = ? AND world_id IS NOT NULL AND account IS ?
+		  AND (ts > ? OR (ts = ? AND id > ?))
+		ORDER BY ts ASC, id ASC
+		LIMIT 1
+	`, event.TypeWorldJoin, account, tsStr, tsStr, e.ID).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find session end: %w", err)
+	}
+	end, err := time.Parse(TimeFormat, next)
+	if err != nil {
+		return nil, fmt.Errorf("parse ts %q: %w", next, err)
+	}
+	return &end, nil
+}
+
+// closeOpenPins sets the end of pinned sessions that were still open when
+// pinned and have since been followed by another world_join.
+func (s *Store) closeOpenPins(ctx context.Context) error {
+	_, err := s.exec(ctx, `
+		UPDATE pins SET until = (
+			SELECT MIN(n.ts) FROM events j
+			JOIN events n ON n.type = ? AND n.world_id IS NOT NULL AND n.account IS j.account
+			  AND (n.ts > j.ts OR (n.ts = j.ts AND n.id > j.id))
+			WHERE j.id = pins.event_id
+		)
+		WHERE until IS NULL AND event_id IS NOT NULL
+	`, event.TypeWorldJoin)
+	if err != nil {
+		return fmt.Errorf("close open pins: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestPins_SaveListDelete(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	until := base.Add(time.Hour)
+	p, err := st.SavePin(ctx, Pin{Since: base, Until: &until, Label: "birthday"})
+	if err != nil {
+		t.Fatalf("SavePin: %v", err)
+	}
+
+	list, err := st.ListPins(ctx)
+	if err != nil {
+		t.Fatalf("ListPins: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != p.ID || !list[0].Since.Equal(base) ||
+		list[0].Until == nil || !list[0].Until.Equal(until) || list[0].Label != "birthday" {
+		t.Errorf("ListPins = %+v", list)
+	}
+
+	if err := st.DeletePin(ctx, p.ID); err != nil {
+		t.Fatalf("DeletePin: %v", err)
+	}
+	if err := st.DeletePin(ctx, p.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeletePin: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPins_SessionEnd(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	insert := func(e *event.Event) *event.Event {
+		t.Helper()
+		e.Type = event.TypeWorldJoin
+		e.IngestedAt = base
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		return e
+	}
+
+	join := insert(&event.Event{Ts: base, WorldID: event.StringPtr("wrld_a"), InstanceID: event.StringPtr("1"), DedupeKey: "a1"})
+	// The "Entering Room" line does not end the session
+	insert(&event.Event{Ts: base.Add(time.Second), WorldName: event.StringPtr("World A"), DedupeKey: "a2"})
+
+	end, err := st.SessionEnd(ctx, join)
+	if err != nil || end != nil {
+		t.Fatalf("SessionEnd of open session = %v, %v; want nil", end, err)
+	}
+
+	// Pin the open session, then move on to another world
+	if _, err := st.SavePin(ctx, Pin{Since: join.Ts, EventID: join.ID}); err != nil {
+		t.Fatalf("SavePin: %v", err)
+	}
+	next := base.Add(time.Hour)
+	insert(&event.Event{Ts: next, WorldID: event.StringPtr("wrld_b"), InstanceID: event.StringPtr("2"), DedupeKey: "b1"})
+
+	end, err = st.SessionEnd(ctx, join)
+	if err != nil || end == nil || !end.Equal(next) {
+		t.Errorf("SessionEnd = %v, %v; want %v", end, err, next)
+	}
+	list, err := st.ListPins(ctx)
+	if err != nil {
+		t.Fatalf("ListPins: %v", err)
+	}
+	if len(list) != 1 || list[0].Until == nil || !list[0].Until.Equal(next) {
+		t.Errorf("open pin not closed: %+v", list)
+	}
+}
+
+func TestPruneStateSnapshots_KeepsPinned(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	for i := range 3 {
+		if _, err := st.SaveStateSnapshot(ctx, StateSnapshot{Ts: base.Add(time.Duration(i) * time.Hour)}); err != nil {
+			t.Fatalf("SaveStateSnapshot: %v", err)
+		}
+	}
+	until := base.Add(90 * time.Minute)
+	if _, err := st.SavePin(ctx, Pin{Since: base.Add(30 * time.Minute), Until: &until}); err != nil {
+		t.Fatalf("SavePin: %v", err)
+	}
+
+	list, err := st.ListStateSnapshots(ctx, base, base.Add(24*time.Hour), "", 0)
+	if err != nil {
+		t.Fatalf("ListStateSnapshots: %v", err)
+	}
+	if len(list) != 3 || list[0].Pinned || !list[1].Pinned || list[2].Pinned {
+		t.Fatalf("pinned flags = %+v", list)
+	}
+
+	n, err := st.PruneStateSnapshots(ctx, base.Add(24*time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("PruneStateSnapshots = %d, %v; want 2", n, err)
+	}
+	list, err = st.ListStateSnapshots(ctx, base, base.Add(24*time.Hour), "", 0)
+	if err != nil {
+		t.Fatalf("ListStateSnapshots: %v", err)
+	}
+	if len(list) != 1 || !list[0].Ts.Equal(base.Add(time.Hour)) {
+		t.Errorf("after prune = %+v", list)
+	}
+}
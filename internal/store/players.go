@@ -0,0 +1,199 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Players directory sort orders.
+const (
+	PlayerSortLastSeen  = "last_seen"  // most recently seen first
+	PlayerSortTotalTime = "total_time" // longest shared time first
+)
+
+// PlayerSummary is one player in the players directory.
+type PlayerSummary struct {
+	PlayerName string    `json:"player_name"` // most recent display name
+	PlayerID   string    `json:"player_id,omitempty"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Sessions   int       `json:"sessions"` // player_join count
+	Minutes    int64     `json:"minutes"`  // summed durations of paired player_left events
+}
+
+// PlayerFilter selects and orders players for ListPlayers.
+type PlayerFilter struct {
+	Search  string // substring of any display name or the ID, compared normalized (see event.NormalizeName)
+	Sort    string // PlayerSortLastSeen (default) or PlayerSortTotalTime
+	Account string // empty covers all accounts
+	Limit   int
+	Cursor  *string
+}
+
+// PlayerPage is one page of the players directory.
+type PlayerPage struct {
+	Items      []PlayerSummary `json:"items"`
+	NextCursor *string         `json:"next_cursor,omitempty"`
+}
+
+// ListPlayers returns the distinct players seen in join and leave events,
+// one row per player ID (or normalized display name when no ID is known or
+// linked), with their aggregates. Returns ErrInvalidCursor for a cursor from another sort order.
+func (s *Store) ListPlayers(ctx context.Context, f PlayerFilter) (PlayerPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+	sortCol := "last_seen"
+	switch f.Sort {
+	case "", PlayerSortLastSeen:
+		f.Sort = PlayerSortLastSeen
+	case PlayerSortTotalTime:
+		sortCol = "total_sec"
+	default:
+		return PlayerPage{}, fmt.Errorf("unknown sort %q", f.Sort)
+	}
+
+	accountCond, accountArgs := accountClause(f.Account)
+	args := append([]any{event.TypePlayerJoin, event.TypePlayerLeft}, accountArgs...)
+	args = append(args, event.TypePlayerJoin, event.TypePlayerJoin, event.TypePlayerLeft)
+	args = append(args, accountArgs...)
+
+	// latest takes player_name from the row holding MAX(ts): SQLite's bare
+	// column rule for a single min/max aggregate
+	var sb strings.Builder
+	sb.WriteString(`
+		WITH linked AS (
+			SELECT e.ts, e.type, e.account, e.player_name, e.normalized_name, e.duration_sec,
+				COALESCE(e.player_id, pl.linked_id) AS player_id,
+				COALESCE(e.player_id, pl.linked_id, e.normalized_name) AS pkey
+			FROM events e
+			LEFT JOIN player_links pl ON e.player_id IS NULL AND pl.name_key = e.normalized_name
+		), latest AS (
+			SELECT pkey, player_name, MAX(ts) AS last_seen
+			FROM linked
+			WHERE type IN (?, ?) AND pkey IS NOT NULL` + accountCond + `
+			GROUP BY pkey
+		), totals AS (
+			SELECT pkey, MAX(player_id) AS player_id,
+				MIN(ts) AS first_seen, SUM(type = ?) AS sessions,
+				COALESCE(SUM(duration_sec), 0) AS total_sec,
+				GROUP_CONCAT(normalized_name, char(10)) AS names
+			FROM linked
+			WHERE type IN (?, ?) AND pkey IS NOT NULL` + accountCond + `
+			GROUP BY pkey
+		)
+		SELECT t.pkey, l.player_name, t.player_id, t.first_seen, l.last_seen, t.sessions, t.total_sec
+		FROM totals t JOIN latest l ON l.pkey = t.pkey
+		WHERE 1=1`)
+	if f.Search != "" {
+		pattern := "%" + escapeLike(event.NormalizeName(f.Search)) + "%"
+		sb.WriteString(` AND (t.names LIKE ? ESCAPE '\' OR t.player_id LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if f.Cursor != nil {
+		value, key, err := decodePlayerCursor(*f.Cursor, f.Sort)
+		if err != nil {
+			return PlayerPage{}, err
+		}
+		fmt.Fprintf(&sb, " AND (%[1]s < ? OR (%[1]s = ? AND t.pkey > ?))", sortCol)
+		args = append(args, value, value, key)
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s DESC, t.pkey ASC LIMIT ?", sortCol)
+	args = append(args, limit+1)
+
+	rows, err := s.query(ctx, sb.String(), args...)
+	if err != nil {
+		return PlayerPage{}, fmt.Errorf("query players: %w", err)
+	}
+	defer rows.Close()
+
+	page := PlayerPage{Items: []PlayerSummary{}}
+	var keys []string
+	var totals []int64
+	for rows.Next() {
+		var (
+			p                PlayerSummary
+			key, first, last string
+			name, id         sql.NullString
+			totalSec         int64
+		)
+		if err := rows.Scan(&key, &name, &id, &first, &last, &p.Sessions, &totalSec); err != nil {
+			return PlayerPage{}, fmt.Errorf("scan player: %w", err)
+		}
+		p.PlayerName, p.PlayerID = name.String, id.String
+		if p.FirstSeen, err = time.Parse(TimeFormat, first); err != nil {
+			return PlayerPage{}, fmt.Errorf("parse ts %q: %w", first, err)
+		}
+		if p.LastSeen, err = time.Parse(TimeFormat, last); err != nil {
+			return PlayerPage{}, fmt.Errorf("parse ts %q: %w", last, err)
+		}
+		p.Minutes = totalSec / 60
+		page.Items = append(page.Items, p)
+		keys = append(keys, key)
+		totals = append(totals, totalSec)
+	}
+	if err := rows.Err(); err != nil {
+		return PlayerPage{}, fmt.Errorf("rows error: %w", err)
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := page.Items[limit-1]
+		value := last.LastSeen.UTC().Format(TimeFormat)
+		if f.Sort == PlayerSortTotalTime {
+			value = strconv.FormatInt(totals[limit-1], 10)
+		}
+		cur := encodeSortCursor(f.Sort, value, keys[limit-1])
+		page.NextCursor = &cur
+	}
+	return page, nil
+}
+
+// encodeSortCursor encodes the position after an item of a directory
+// sorted by one value: the sort order, the item's sort value and its key.
+func encodeSortCursor(sort, value, key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sort + "|" + value + "|" + key))
+}
+
+// decodeSortCursor parses a cursor made by encodeSortCursor for sort.
+func decodeSortCursor(cur, sort string) (value, key string, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cur)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: base64 decode failed", ErrInvalidCursor)
+	}
+	parts := strings.SplitN(string(b), "|", 3)
+	if len(parts) != 3 || parts[0] != sort {
+		return "", "", fmt.Errorf("%w: not a %s cursor", ErrInvalidCursor, sort)
+	}
+	return parts[1], parts[2], nil
+}
+
+// decodePlayerCursor parses a players directory cursor. The returned value
+// has the type of the sort column.
+func decodePlayerCursor(cur, sort string) (any, string, error) {
+	value, key, err := decodeSortCursor(cur, sort)
+	if err != nil {
+		return nil, "", err
+	}
+	if sort == PlayerSortTotalTime {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: invalid total", ErrInvalidCursor)
+		}
+		return n, key, nil
+	}
+	if _, err := time.Parse(TimeFormat, value); err != nil {
+		return nil, "", fmt.Errorf("%w: invalid timestamp", ErrInvalidCursor)
+	}
+	return value, key, nil
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestListPlayers(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	n := 0
+	insert := func(offset time.Duration, typ, name, id string) {
+		t.Helper()
+		n++
+		e := event.Event{
+			Ts:         base.Add(offset),
+			Type:       typ,
+			PlayerName: event.StringPtr(name),
+			IngestedAt: base,
+			DedupeKey:  "k" + string(rune('a'+n)),
+		}
+		if id != "" {
+			e.PlayerID = event.StringPtr(id)
+		}
+		if _, _, err := st.InsertEvent(ctx, &e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	// Alice: 30 minutes over two sessions, renamed to Alicia; seen last
+	insert(0, event.TypePlayerJoin, "Alice", "usr_alice")
+	insert(10*time.Minute, event.TypePlayerLeft, "Alice", "usr_alice")
+	insert(time.Hour, event.TypePlayerJoin, "Alicia", "usr_alice")
+	insert(time.Hour+20*time.Minute, event.TypePlayerLeft, "Alicia", "usr_alice")
+	// Bob: 60 minutes
+	insert(time.Minute, event.TypePlayerJoin, "Bob", "")
+	insert(61*time.Minute, event.TypePlayerLeft, "Bob", "")
+	// Carol: never left
+	insert(2*time.Minute, event.TypePlayerJoin, "Carol", "")
+
+	names := func(p PlayerPage) []string {
+		var out []string
+		for _, s := range p.Items {
+			out = append(out, s.PlayerName)
+		}
+		return out
+	}
+
+	page, err := st.ListPlayers(ctx, PlayerFilter{})
+	if err != nil {
+		t.Fatalf("ListPlayers: %v", err)
+	}
+	if got := names(page); len(got) != 3 || got[0] != "Alicia" || got[1] != "Bob" || got[2] != "Carol" {
+		t.Errorf("by last seen = %v, want [Alicia Bob Carol]", got)
+	}
+	alice := page.Items[0]
+	if alice.PlayerID != "usr_alice" || alice.Sessions != 2 || alice.Minutes != 30 ||
+		!alice.FirstSeen.Equal(base) || !alice.LastSeen.Equal(base.Add(time.Hour+20*time.Minute)) {
+		t.Errorf("Alice = %+v", alice)
+	}
+
+	// Pages of one by total time
+	var got []string
+	filter := PlayerFilter{Sort: PlayerSortTotalTime, Limit: 1}
+	for range 4 {
+		page, err := st.ListPlayers(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListPlayers: %v", err)
+		}
+		got = append(got, names(page)...)
+		if page.NextCursor == nil {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if len(got) != 3 || got[0] != "Bob" || got[1] != "Alicia" || got[2] != "Carol" {
+		t.Errorf("by total time = %v, want [Bob Alicia Carol]", got)
+	}
+
+	// Search matches former names, normalized
+	page, err = st.ListPlayers(ctx, PlayerFilter{Search: "ａｌｉｃｅ"})
+	if err != nil {
+		t.Fatalf("ListPlayers: %v", err)
+	}
+	if got := names(page); len(got) != 1 || got[0] != "Alicia" {
+		t.Errorf("search ａｌｉｃｅ = %v, want [Alicia]", got)
+	}
+	page, err = st.ListPlayers(ctx, PlayerFilter{Search: "%"})
+	if err != nil {
+		t.Fatalf("ListPlayers: %v", err)
+	}
+	if len(page.Items) != 0 {
+		t.Errorf("search %% = %v, want none", names(page))
+	}
+
+	// A cursor only fits the sort order it came from
+	cur := encodeSortCursor(PlayerSortTotalTime, "0", "Bob")
+	if _, err := st.ListPlayers(ctx, PlayerFilter{Cursor: &cur}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("mismatched cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// presenceDuration returns how long the player of a player_left event was
+// present, measured from the matching player_join in the same instance.
+//
+// The matching join is the most recent join of the same player (by ID, or by
+// normalized name when no ID is known) in the same account's log, positioned before the
+// leave, with no world_join and no other leave of that player in between. beforeID positions the leave
+// among rows sharing its timestamp; pass math.MaxInt64 for a leave that has
+// not been inserted yet. Returns ok=false if no matching join exists.
+func presenceDuration(ctx context.Context, q querier, leaveTs time.Time, beforeID int64, account, playerID, playerName string) (d time.Duration, ok bool, err error) {
+	keyCol, key := "player_id", playerID
+	if key == "" {
+		keyCol, key = "normalized_name", event.NormalizeName(playerName)
+	}
+	if key == "" {
+		return 0, false, nil
+	}
+
+	query := `
+	SELECT j.ts FROM events j
+	WHERE j.type = ? AND j.` + keyCol + ` = ? AND j.account IS ?
+	  AND (j.ts < ? OR (j.ts = ? AND j.id < ?))
+	  AND NOT EXISTS (
+		SELECT 1 FROM events x
+		WHERE (x.type = ? OR (x.type = ? AND x.` + keyCol + ` = ?)) AND x.account IS ?
+		  AND (x.ts > j.ts OR (x.ts = j.ts AND x.id > j.id))
+		  AND (x.ts < ? OR (x.ts = ? AND x.id < ?))
+	  )
+	ORDER BY j.ts DESC, j.id DESC
+	LIMIT 1
+	`
+
+	leaveStr := leaveTs.UTC().Format(TimeFormat)
+	var joinStr string
+	err = q.QueryRowContext(ctx, query,
+		event.TypePlayerJoin, key, nullIfEmpty(account),
+		leaveStr, leaveStr, beforeID,
+		event.TypeWorldJoin, event.TypePlayerLeft, key, nullIfEmpty(account),
+		leaveStr, leaveStr, beforeID,
+	).Scan(&joinStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("find matching join: %w", err)
+	}
+
+	joinTs, err := time.Parse(TimeFormat, joinStr)
+	if err != nil {
+		return 0, false, fmt.Errorf("parse ts %q: %w", joinStr, err)
+	}
+	return leaveTs.Sub(joinTs), true, nil
+}
+
+// pairLeave sets e.DurationSec for a player_left event that is about to be
+// inserted. Leaves without a matching join are left unchanged.
+func (s *Store) pairLeave(ctx context.Context, e *event.Event) error {
+	if e.Type != event.TypePlayerLeft || e.DurationSec != nil {
+		return nil
+	}
+	d, ok, err := presenceDuration(ctx, s.db, e.Ts, math.MaxInt64, deref(e.Account), deref(e.PlayerID), deref(e.PlayerName))
+	if err != nil || !ok {
+		return err
+	}
+	sec := int64(d / time.Second)
+	e.DurationSec = &sec
+	return nil
+}
+
+// backfillPresenceDurations pairs player_left rows written before the
+// duration_sec column existed. Runs once, right after the column is added.
+func (s *Store) backfillPresenceDurations(ctx context.Context) error {
+	rows, err := s.query(ctx, `
+		SELECT id, ts, account, player_id, player_name FROM events
+		WHERE type = ? AND duration_sec IS NULL
+	`, event.TypePlayerLeft)
+	if err != nil {
+		return fmt.Errorf("select leaves: %w", err)
+	}
+	type leave struct {
+		id         int64
+		ts         time.Time
+		account    string
+		playerID   string
+		playerName string
+	}
+	var leaves []leave
+	for rows.Next() {
+		var (
+			l                             leave
+			ts                            string
+			account, playerID, playerName sql.NullString
+		)
+		if err := rows.Scan(&l.id, &ts, &account, &playerID, &playerName); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan leave: %w", err)
+		}
+		if l.ts, err = time.Parse(TimeFormat, ts); err != nil {
+			rows.Close()
+			return fmt.Errorf("parse ts %q: %w", ts, err)
+		}
+		l.account, l.playerID, l.playerName = account.String, playerID.String, playerName.String
+		leaves = append(leaves, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
+	}
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin backfill: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, l := range leaves {
+		d, ok, err := presenceDuration(ctx, tx, l.ts, l.id, l.account, l.playerID, l.playerName)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE events SET duration_sec = ? WHERE id = ?`, int64(d/time.Second), l.id,
+		); err != nil {
+			return fmt.Errorf("backfill duration: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
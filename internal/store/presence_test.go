@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestInsertEvent_PairsLeaveWithJoin(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	n := 0
+	insert := func(typ, player string, offset time.Duration) *event.Event {
+		t.Helper()
+		n++
+		e := &event.Event{
+			Ts:         base.Add(offset),
+			Type:       typ,
+			DedupeKey:  fmt.Sprintf("k-%d", n),
+			IngestedAt: base,
+		}
+		if player != "" {
+			e.PlayerName = event.StringPtr(player)
+		}
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		return e
+	}
+
+	insert(event.TypeWorldJoin, "", 0)
+	insert(event.TypePlayerJoin, "Alice", time.Minute)
+	insert(event.TypePlayerJoin, "Bob", 2*time.Minute)
+	alice := insert(event.TypePlayerLeft, "Alice", 48*time.Minute)
+	insert(event.TypeWorldJoin, "", time.Hour)
+	// Bob's join belongs to the previous instance
+	bob := insert(event.TypePlayerLeft, "Bob", 2*time.Hour)
+	// A second leave without a new join is not paired again
+	again := insert(event.TypePlayerLeft, "Alice", 2*time.Hour)
+
+	if alice.DurationSec == nil || *alice.DurationSec != 47*60 {
+		t.Errorf("Alice DurationSec = %v, want %d", alice.DurationSec, 47*60)
+	}
+	if bob.DurationSec != nil {
+		t.Errorf("Bob DurationSec = %d, want nil", *bob.DurationSec)
+	}
+	if again.DurationSec != nil {
+		t.Errorf("repeated leave DurationSec = %d, want nil", *again.DurationSec)
+	}
+
+	// Duration is persisted and returned by queries
+	typ := event.TypePlayerLeft
+	result, err := st.QueryEvents(ctx, QueryFilter{Type: &typ, Order: QueryOrderAsc})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if got := result.Items[0].DurationSec; got == nil || *got != 47*60 {
+		t.Errorf("queried DurationSec = %v, want %d", got, 47*60)
+	}
+}
+
+func TestOpen_BackfillsPresenceDurations(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "old.sqlite")
+
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY, ts TEXT NOT NULL, type TEXT NOT NULL,
+		player_name TEXT, player_id TEXT, world_id TEXT, world_name TEXT,
+		instance_id TEXT, meta_json TEXT, dedupe_key TEXT NOT NULL,
+		ingested_at TEXT NOT NULL, schema_version INTEGER NOT NULL,
+		UNIQUE(dedupe_key)
+	);
+	INSERT INTO events (ts, type, player_name, player_id, dedupe_key, ingested_at, schema_version) VALUES
+	('2024-01-01T00:00:00.000000000Z', 'player_join', 'Alice', 'usr_a', 'k1', '2024-01-01T00:00:00.000000000Z', 1),
+	('2024-01-01T00:10:00.000000000Z', 'player_left', 'Alice', 'usr_a', 'k2', '2024-01-01T00:00:00.000000000Z', 1);
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	st, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer st.Close()
+
+	typ := event.TypePlayerLeft
+	result, err := st.QueryEvents(context.Background(), QueryFilter{Type: &typ})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(result.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(result.Items))
+	}
+	if got := result.Items[0].DurationSec; got == nil || *got != 600 {
+		t.Errorf("DurationSec = %v, want 600", got)
+	}
+}
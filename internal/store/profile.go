@@ -0,0 +1,274 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// PlayerProfile aggregates what the log tells about one player.
+type PlayerProfile struct {
+	PlayerName string    `json:"player_name"` // most recent display name
+	PlayerID   string    `json:"player_id,omitempty"`
+	Names      []string  `json:"names"` // every display name seen, oldest first
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	Sessions   int       `json:"sessions"`
+	Minutes    int64     `json:"minutes"` // total time shared with the user
+	// Worlds lists the worlds the player was met in, longest shared time
+	// first. Sessions in an unknown world count towards the totals only.
+	Worlds []PlayerWorld `json:"worlds"`
+}
+
+// PlayerWorld is the time shared with a player in one world.
+type PlayerWorld struct {
+	WorldID   string    `json:"world_id,omitempty"`
+	WorldName string    `json:"world_name,omitempty"`
+	Sessions  int       `json:"sessions"`
+	Minutes   int64     `json:"minutes"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// profileRow is a player_join, player_left or world_join row read while
+// building a profile.
+type profileRow struct {
+	ts                   time.Time
+	id                   int64
+	typ                  string
+	account              string
+	worldID, worldName   string
+	playerName, playerID string
+}
+
+// PlayerProfile returns the profile of a player, matched by player ID or
+// display name (see event.NormalizeName) and through player links. Sessions
+// are paired as in
+// GetCopresence: from a player_join until the matching player_left or the
+// next world_join, with a session still open cut at now. An empty account covers all accounts.
+// Returns ErrNotFound if the player was never seen.
+func (s *Store) PlayerProfile(ctx context.Context, player, account string) (*PlayerProfile, error) {
+	id, name, err := s.resolvePlayer(ctx, player)
+	if err != nil {
+		return nil, err
+	}
+	accountCond, accountArgs := accountClause(account)
+	args := append([]any{event.TypePlayerJoin, event.TypePlayerLeft, id, name, id}, accountArgs...)
+	seen, err := s.profileRows(ctx, `
+		SELECT ts, id, type, account, world_id, world_name, player_name, player_id FROM events
+		WHERE type IN (?, ?) AND (player_id = ? OR normalized_name = ? OR `+linkedNamesCond+`)`+accountCond+`
+		ORDER BY ts ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query player events: %w", err)
+	}
+	if len(seen) == 0 {
+		return nil, ErrNotFound
+	}
+
+	p := &PlayerProfile{
+		Names:     []string{},
+		FirstSeen: seen[0].ts,
+		LastSeen:  seen[len(seen)-1].ts,
+		Worlds:    []PlayerWorld{},
+	}
+	for _, r := range seen {
+		if r.playerName != "" {
+			p.PlayerName = r.playerName
+			if !slices.Contains(p.Names, r.playerName) {
+				p.Names = append(p.Names, r.playerName)
+			}
+		}
+		if r.playerID != "" {
+			p.PlayerID = r.playerID
+		}
+	}
+
+	// The world each account was in when the player first showed up there
+	type worldRef struct{ id, name string }
+	current := make(map[string]worldRef)
+	for _, r := range seen {
+		if _, ok := current[r.account]; ok {
+			continue
+		}
+		w, err := s.worldAt(ctx, r.account, p.FirstSeen)
+		if err != nil {
+			return nil, err
+		}
+		current[r.account] = worldRef{}
+		if w != nil {
+			current[r.account] = worldRef{deref(w.WorldID), deref(w.WorldName)}
+		}
+	}
+
+	worlds := make(map[string]*PlayerWorld)
+	var total time.Duration
+	durations := make(map[string]time.Duration)
+	type session struct {
+		start time.Time
+		world string // key into worlds; "" if unknown
+	}
+	open := make(map[string]session) // account -> open session
+
+	closeSession := func(account string, end time.Time) {
+		sess, ok := open[account]
+		if !ok {
+			return
+		}
+		delete(open, account)
+		d := max(end.Sub(sess.start), 0)
+		total += d
+		if w := worlds[sess.world]; w != nil {
+			durations[sess.world] += d
+			if end.After(w.LastSeen) {
+				w.LastSeen = end
+			}
+		}
+	}
+	apply := func(r profileRow) {
+		switch r.typ {
+		case event.TypeWorldJoin:
+			if _, tracked := current[r.account]; !tracked {
+				return
+			}
+			if r.worldID != "" {
+				closeSession(r.account, r.ts)
+				current[r.account] = worldRef{id: r.worldID}
+			} else if w := current[r.account]; w.name == "" {
+				w.name = r.worldName
+				current[r.account] = w
+				if pw := worlds[w.id]; pw != nil && pw.WorldName == "" {
+					pw.WorldName = w.name
+				}
+			}
+		case event.TypePlayerJoin:
+			if _, ok := open[r.account]; ok {
+				return // duplicate join
+			}
+			p.Sessions++
+			w := current[r.account]
+			key := cmp.Or(w.id, w.name)
+			if key != "" {
+				pw := worlds[key]
+				if pw == nil {
+					pw = &PlayerWorld{WorldID: w.id}
+					worlds[key] = pw
+				}
+				pw.WorldName = cmp.Or(w.name, pw.WorldName)
+				pw.Sessions++
+				if r.ts.After(pw.LastSeen) {
+					pw.LastSeen = r.ts
+				}
+			}
+			open[r.account] = session{start: r.ts, world: key}
+		case event.TypePlayerLeft:
+			closeSession(r.account, r.ts)
+		}
+	}
+
+	// Merge the player's rows with the world joins since the first sighting,
+	// stopping once every session is closed
+	args = append([]any{event.TypeWorldJoin, p.FirstSeen.UTC().Format(TimeFormat)}, accountArgs...)
+	rows, err := s.query(ctx, `
+		SELECT ts, id, type, account, world_id, world_name, player_name, player_id FROM events
+		WHERE type = ? AND ts >= ?`+accountCond+`
+		ORDER BY ts ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query world joins: %w", err)
+	}
+	defer rows.Close()
+	next := 0
+	for rows.Next() {
+		wj, err := scanProfileRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		for next < len(seen) && profileRowBefore(seen[next], wj) {
+			apply(seen[next])
+			next++
+		}
+		if next == len(seen) && len(open) == 0 {
+			break
+		}
+		apply(wj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	for ; next < len(seen); next++ {
+		apply(seen[next])
+	}
+	now := time.Now()
+	for acct := range open {
+		closeSession(acct, now)
+	}
+
+	p.Minutes = int64(total / time.Minute)
+	for key, w := range worlds {
+		w.Minutes = int64(durations[key] / time.Minute)
+		p.Worlds = append(p.Worlds, *w)
+	}
+	slices.SortFunc(p.Worlds, func(a, b PlayerWorld) int {
+		return cmp.Or(
+			cmp.Compare(b.Minutes, a.Minutes),
+			cmp.Compare(b.Sessions, a.Sessions),
+			cmp.Compare(a.WorldName, b.WorldName),
+			cmp.Compare(a.WorldID, b.WorldID),
+		)
+	})
+	return p, nil
+}
+
+// profileRows runs query and reads all rows.
+func (s *Store) profileRows(ctx context.Context, query string, args ...any) ([]profileRow, error) {
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []profileRow
+	for rows.Next() {
+		r, err := scanProfileRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return out, nil
+}
+
+func scanProfileRow(rows *sql.Rows) (profileRow, error) {
+	var (
+		r                                              profileRow
+		tsStr                                          string
+		acct, worldID, worldName, playerName, playerID sql.NullString
+	)
+	if err := rows.Scan(&tsStr, &r.id, &r.typ, &acct, &worldID, &worldName, &playerName, &playerID); err != nil {
+		return r, fmt.Errorf("scan event: %w", err)
+	}
+	ts, err := time.Parse(TimeFormat, tsStr)
+	if err != nil {
+		return r, fmt.Errorf("parse ts %q: %w", tsStr, err)
+	}
+	r.ts = ts
+	r.account = acct.String
+	r.worldID, r.worldName = worldID.String, worldName.String
+	r.playerName, r.playerID = playerName.String, playerID.String
+	return r, nil
+}
+
+// profileRowBefore reports whether a sorts before b in log order.
+func profileRowBefore(a, b profileRow) bool {
+	if !a.ts.Equal(b.ts) {
+		return a.ts.Before(b.ts)
+	}
+	return a.id < b.id
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is the default duration above which a query is
+// logged as slow.
+const DefaultSlowQueryThreshold = 500 * time.Millisecond
+
+// SlowQueryStats counts the queries that exceeded the slow query threshold
+// since the store was opened.
+type SlowQueryStats struct {
+	ThresholdMs int64      `json:"threshold_ms"` // 0 means slow query logging is off
+	Count       int64      `json:"count"`
+	SlowestMs   int64      `json:"slowest_ms"`
+	Last        *time.Time `json:"last,omitempty"`
+	LastQuery   string     `json:"last_query,omitempty"` // whitespace-collapsed SQL, without parameters
+}
+
+// queryLog holds the slow query settings and counters of a Store.
+type queryLog struct {
+	slowAbove    time.Duration // log slower queries; 0 = off
+	explainAbove time.Duration // log query plans of slower reads; 0 = off
+
+	mu    sync.Mutex
+	stats SlowQueryStats
+}
+
+// SetSlowQueryThreshold makes the store log queries that take longer than
+// d, with their parameters sanitized, and count them in SlowQueries.
+// 0 disables it. Must be called before the store is used concurrently.
+func (s *Store) SetSlowQueryThreshold(d time.Duration) {
+	s.queryLog.slowAbove = d
+}
+
+// SetExplainThreshold makes the store log the query plan of read queries
+// that take longer than d, for diagnosing slow pages in debug mode.
+// 0 disables it. Must be called before the store is used concurrently.
+func (s *Store) SetExplainThreshold(d time.Duration) {
+	s.queryLog.explainAbove = d
+}
+
+// SlowQueries returns the slow query counters.
+func (s *Store) SlowQueries() SlowQueryStats {
+	s.queryLog.mu.Lock()
+	defer s.queryLog.mu.Unlock()
+	stats := s.queryLog.stats
+	stats.ThresholdMs = s.queryLog.slowAbove.Milliseconds()
+	return stats
+}
+
+// query runs QueryContext, logging it if slow.
+func (s *Store) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	s.observe(ctx, time.Since(start), err, true, query, args)
+	return rows, err
+}
+
+// queryRow runs QueryRowContext, logging it if slow.
+func (s *Store) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	start := time.Now()
+	row := s.db.QueryRowContext(ctx, query, args...)
+	s.observe(ctx, time.Since(start), row.Err(), true, query, args)
+	return row
+}
+
+// exec runs ExecContext, logging it if slow.
+func (s *Store) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := s.db.ExecContext(ctx, query, args...)
+	s.observe(ctx, time.Since(start), err, false, query, args)
+	return result, err
+}
+
+// observe records and logs a query that took elapsed, if it was slow.
+// Plans are only explained for reads; slow writes are usually waiting for
+// the write lock rather than badly planned.
+func (s *Store) observe(ctx context.Context, elapsed time.Duration, err error, read bool, query string, args []any) {
+	ql := &s.queryLog
+	if err != nil {
+		return
+	}
+	if ql.slowAbove > 0 && elapsed > ql.slowAbove {
+		sql := strings.Join(strings.Fields(query), " ")
+		now := time.Now()
+		ql.mu.Lock()
+		ql.stats.Count++
+		ql.stats.SlowestMs = max(ql.stats.SlowestMs, elapsed.Milliseconds())
+		ql.stats.Last = &now
+		ql.stats.LastQuery = sql
+		ql.mu.Unlock()
+		slog.Warn("slow query", "elapsed", elapsed.Round(time.Millisecond), "sql", sql, "args", sanitizeArgs(args))
+	}
+	if read && ql.explainAbove > 0 && elapsed > ql.explainAbove {
+		s.logQueryPlan(ctx, elapsed, query, args)
+	}
+}
+
+// sanitizeArgs formats query parameters for logs. Timestamps and numbers
+// are shown; other strings, which may be player names or log lines, are
+// reduced to their length.
+func sanitizeArgs(args []any) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case nil:
+			parts[i] = "NULL"
+		case string:
+			if _, err := time.Parse(TimeFormat, v); err == nil {
+				parts[i] = v
+			} else {
+				parts[i] = fmt.Sprintf("<%d chars>", len(v))
+			}
+		case sql.NullString:
+			if v.Valid {
+				parts[i] = fmt.Sprintf("<%d chars>", len(v.String))
+			} else {
+				parts[i] = "NULL"
+			}
+		case int, int64, float64, bool, sql.NullInt64:
+			parts[i] = fmt.Sprint(v)
+		default:
+			parts[i] = fmt.Sprintf("<%T>", v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// logQueryPlan logs the EXPLAIN QUERY PLAN output of a slow query.
+func (s *Store) logQueryPlan(ctx context.Context, elapsed time.Duration, query string, args []any) {
+	plan, err := s.explain(ctx, query, args...)
+	if err != nil {
+		slog.Warn("slow query explain failed", "elapsed", elapsed.Round(time.Millisecond), "error", err)
+		return
+	}
+	slog.Warn("slow query plan", "elapsed", elapsed.Round(time.Millisecond),
+		"sql", strings.Join(strings.Fields(query), " "), "plan", strings.Join(plan, "; "))
+}
+
+// explain returns the EXPLAIN QUERY PLAN details of query, one per step.
+func (s *Store) explain(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		plan = append(plan, detail)
+	}
+	return plan, rows.Err()
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestExplain_UsesPlayerIndex(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	for i := range 50 {
+		if _, _, err := st.InsertEvent(ctx, &event.Event{
+			Ts: now.Add(time.Duration(i) * time.Second), Type: event.TypePlayerJoin,
+			PlayerName: event.StringPtr(fmt.Sprintf("Player%d", i)),
+			DedupeKey:  fmt.Sprintf("k%d", i), IngestedAt: now,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Analyze(ctx); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	plan, err := st.explain(ctx, `SELECT id FROM events WHERE player_name = ? ORDER BY ts DESC`, "Player7")
+	if err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	if !strings.Contains(strings.Join(plan, "\n"), "idx_events_player_name_ts") {
+		t.Errorf("plan = %q, want idx_events_player_name_ts", plan)
+	}
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	// Every query counts as slow with a 1ns threshold
+	st.SetSlowQueryThreshold(time.Nanosecond)
+	if _, err := st.GetLastEventTime(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := st.SlowQueries()
+	if stats.Count == 0 || stats.Last == nil || !strings.HasPrefix(stats.LastQuery, "SELECT") {
+		t.Errorf("stats = %+v, want a recorded SELECT", stats)
+	}
+}
+
+func TestSanitizeArgs(t *testing.T) {
+	ts := "2024-01-01T00:00:00.000000000Z"
+	got := sanitizeArgs([]any{ts, "Alice", 42, nil})
+	want := ts + ", <5 chars>, 42, NULL"
+	if got != want {
+		t.Errorf("sanitizeArgs = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultRollupAfter is how old a day must be before stats read it from the
+// daily rollups instead of counting events.
+const DefaultRollupAfter = 30 * 24 * time.Hour
+
+// rollupDayFormat is the format of the day column of the rollup tables, a
+// UTC date and the first 10 characters of TimeFormat.
+const rollupDayFormat = "2006-01-02"
+
+// SetRollupAfter makes stats read days older than d from the daily rollups.
+// 0 makes them always count events. Must be called before the store is
+// used concurrently.
+func (s *Store) SetRollupAfter(d time.Duration) {
+	s.rollupAfter = d
+}
+
+// rollupSpan splits [since, until) for stats: days is the range of whole
+// UTC days old enough to read from the rollups (from inclusive, to
+// exclusive; empty if none), and raw are the time ranges left over, to be
+// counted from events.
+func (s *Store) rollupSpan(since, until time.Time) (days [2]string, raw [][2]string) {
+	since, until = since.UTC(), until.UTC()
+	span := func(from, to time.Time) [2]string {
+		return [2]string{from.Format(TimeFormat), to.Format(TimeFormat)}
+	}
+	if s.rollupAfter <= 0 || !since.Before(until) {
+		return days, [][2]string{span(since, until)}
+	}
+
+	first := since.Truncate(24 * time.Hour)
+	if first.Before(since) {
+		first = first.Add(24 * time.Hour)
+	}
+	last := until.Truncate(24 * time.Hour)
+	if cutoff := time.Now().UTC().Add(-s.rollupAfter).Truncate(24 * time.Hour); cutoff.Before(last) {
+		last = cutoff
+	}
+	if !first.Before(last) {
+		return days, [][2]string{span(since, until)}
+	}
+
+	days = [2]string{first.Format(rollupDayFormat), last.Format(rollupDayFormat)}
+	if since.Before(first) {
+		raw = append(raw, span(since, first))
+	}
+	if last.Before(until) {
+		raw = append(raw, span(last, until))
+	}
+	return days, raw
+}
+
+// createRollupTables creates the daily rollups and the triggers that keep
+// them in step with the events table, so multi-year stats need not count
+// every event. Days are UTC dates and the default account is empty. Existing
+// events are rolled up when the tables are first created.
+//
+//   - rollup_daily_types: events per type
+//   - rollup_daily_worlds: world joins with an instance ID, per world and
+//     instance type, region and group (empty when unknown)
+//   - rollup_daily_players: joins and time in instances (the duration_sec
+//     of leaves) per player ID, or normalized name without one
+func (s *Store) createRollupTables(ctx context.Context) error {
+	var exists int
+	if err := s.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'rollup_daily_types'`).Scan(&exists); err != nil {
+		return fmt.Errorf("check rollup tables: %w", err)
+	}
+
+	const schema = `
+	CREATE TABLE IF NOT EXISTS rollup_daily_types (
+		day     TEXT NOT NULL,
+		account TEXT NOT NULL,
+		type    TEXT NOT NULL,
+		events  INTEGER NOT NULL,
+		PRIMARY KEY (day, account, type)
+	) WITHOUT ROWID;
+	CREATE TABLE IF NOT EXISTS rollup_daily_worlds (
+		day           TEXT NOT NULL,
+		account       TEXT NOT NULL,
+		world_id      TEXT NOT NULL,
+		instance_type TEXT NOT NULL,
+		region        TEXT NOT NULL,
+		group_id      TEXT NOT NULL,
+		joins         INTEGER NOT NULL,
+		PRIMARY KEY (day, account, world_id, instance_type, region, group_id)
+	) WITHOUT ROWID;
+	CREATE TABLE IF NOT EXISTS rollup_daily_players (
+		day        TEXT NOT NULL,
+		account    TEXT NOT NULL,
+		player_key TEXT NOT NULL,
+		joins      INTEGER NOT NULL,
+		total_sec  INTEGER NOT NULL,
+		PRIMARY KEY (day, account, player_key)
+	) WITHOUT ROWID;
+	`
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create rollup tables: %w", err)
+	}
+
+	if exists == 0 {
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO rollup_daily_types (day, account, type, events)
+			SELECT substr(ts, 1, 10), COALESCE(account, ''), type, COUNT(*)
+			FROM events GROUP BY 1, 2, 3;
+			INSERT INTO rollup_daily_worlds (day, account, world_id, instance_type, region, group_id, joins)
+			SELECT substr(ts, 1, 10), COALESCE(account, ''), COALESCE(world_id, ''),
+				COALESCE(instance_type, ''), COALESCE(region, ''), COALESCE(group_id, ''), COUNT(*)
+			FROM events WHERE type = 'world_join' AND instance_id IS NOT NULL GROUP BY 1, 2, 3, 4, 5, 6;
+			INSERT INTO rollup_daily_players (day, account, player_key, joins, total_sec)
+			SELECT substr(ts, 1, 10), COALESCE(account, ''), COALESCE(player_id, normalized_name),
+				SUM(type = 'player_join'), SUM(CASE WHEN type = 'player_left' THEN COALESCE(duration_sec, 0) ELSE 0 END)
+			FROM events
+			WHERE type IN ('player_join', 'player_left') AND COALESCE(player_id, normalized_name) IS NOT NULL
+			GROUP BY 1, 2, 3;
+		`); err != nil {
+			return fmt.Errorf("seed rollup tables: %w", err)
+		}
+	}
+
+	// The same statements add NEW and subtract OLD; rows that drop to zero
+	// are removed
+	add := func(row string) string {
+		return `
+		INSERT INTO rollup_daily_types (day, account, type, events)
+		VALUES (substr(` + row + `.ts, 1, 10), COALESCE(` + row + `.account, ''), ` + row + `.type, 1)
+		ON CONFLICT DO UPDATE SET events = events + 1;
+		INSERT INTO rollup_daily_worlds (day, account, world_id, instance_type, region, group_id, joins)
+		SELECT substr(` + row + `.ts, 1, 10), COALESCE(` + row + `.account, ''), COALESCE(` + row + `.world_id, ''),
+			COALESCE(` + row + `.instance_type, ''), COALESCE(` + row + `.region, ''), COALESCE(` + row + `.group_id, ''), 1
+		WHERE ` + row + `.type = 'world_join' AND ` + row + `.instance_id IS NOT NULL
+		ON CONFLICT DO UPDATE SET joins = joins + 1;
+		INSERT INTO rollup_daily_players (day, account, player_key, joins, total_sec)
+		SELECT substr(` + row + `.ts, 1, 10), COALESCE(` + row + `.account, ''), COALESCE(` + row + `.player_id, ` + row + `.normalized_name),
+			` + row + `.type = 'player_join', CASE WHEN ` + row + `.type = 'player_left' THEN COALESCE(` + row + `.duration_sec, 0) ELSE 0 END
+		WHERE ` + row + `.type IN ('player_join', 'player_left') AND COALESCE(` + row + `.player_id, ` + row + `.normalized_name) IS NOT NULL
+		ON CONFLICT DO UPDATE SET joins = joins + excluded.joins, total_sec = total_sec + excluded.total_sec;`
+	}
+	const subtract = `
+		UPDATE rollup_daily_types SET events = events - 1
+		WHERE day = substr(OLD.ts, 1, 10) AND account = COALESCE(OLD.account, '') AND type = OLD.type;
+		DELETE FROM rollup_daily_types
+		WHERE day = substr(OLD.ts, 1, 10) AND account = COALESCE(OLD.account, '') AND type = OLD.type AND events <= 0;
+		UPDATE rollup_daily_worlds SET joins = joins - 1
+		WHERE OLD.type = 'world_join' AND OLD.instance_id IS NOT NULL
+			AND day = substr(OLD.ts, 1, 10) AND account = COALESCE(OLD.account, '') AND world_id = COALESCE(OLD.world_id, '')
+			AND instance_type = COALESCE(OLD.instance_type, '') AND region = COALESCE(OLD.region, '') AND group_id = COALESCE(OLD.group_id, '');
+		DELETE FROM rollup_daily_worlds
+		WHERE day = substr(OLD.ts, 1, 10) AND account = COALESCE(OLD.account, '') AND joins <= 0;
+		UPDATE rollup_daily_players SET
+			joins = joins - (OLD.type = 'player_join'),
+			total_sec = total_sec - CASE WHEN OLD.type = 'player_left' THEN COALESCE(OLD.duration_sec, 0) ELSE 0 END
+		WHERE OLD.type IN ('player_join', 'player_left')
+			AND day = substr(OLD.ts, 1, 10) AND account = COALESCE(OLD.account, '') AND player_key = COALESCE(OLD.player_id, OLD.normalized_name);
+		DELETE FROM rollup_daily_players
+		WHERE day = substr(OLD.ts, 1, 10) AND account = COALESCE(OLD.account, '') AND joins <= 0 AND total_sec <= 0;`
+
+	triggers := `
+	CREATE TRIGGER IF NOT EXISTS events_rollup_insert AFTER INSERT ON events BEGIN` + add("NEW") + `
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_rollup_update AFTER UPDATE OF
+		ts, type, account, world_id, instance_id, instance_type, region, group_id, player_id, normalized_name, duration_sec
+	ON events BEGIN` + subtract + add("NEW") + `
+	END;
+	CREATE TRIGGER IF NOT EXISTS events_rollup_delete AFTER DELETE ON events BEGIN` + subtract + `
+	END;
+	`
+	if _, err := s.db.ExecContext(ctx, triggers); err != nil {
+		return fmt.Errorf("create rollup triggers: %w", err)
+	}
+	return nil
+}
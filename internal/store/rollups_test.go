@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestRollupSpan(t *testing.T) {
+	st := &Store{rollupAfter: 30 * 24 * time.Hour}
+	day := func(d int, h int) time.Time { return time.Date(2024, 1, d, h, 0, 0, 0, time.UTC) }
+	ts := func(t time.Time) string { return t.Format(TimeFormat) }
+
+	days, raw := st.rollupSpan(day(14, 6), day(17, 18))
+	if days != [2]string{"2024-01-15", "2024-01-17"} {
+		t.Errorf("days = %v", days)
+	}
+	want := [][2]string{{ts(day(14, 6)), ts(day(15, 0))}, {ts(day(17, 0)), ts(day(17, 18))}}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("raw = %v, want %v", raw, want)
+	}
+
+	// Within one day, recent days and no rollups at all: events only
+	recent := time.Now().Add(-48 * time.Hour)
+	for _, r := range [][2]time.Time{{day(14, 6), day(14, 18)}, {recent, recent.Add(24 * time.Hour)}} {
+		if days, raw := st.rollupSpan(r[0], r[1]); days[0] != "" || len(raw) != 1 {
+			t.Errorf("rollupSpan(%v) = %v, %v; want events only", r, days, raw)
+		}
+	}
+	st.rollupAfter = 0
+	if days, _ := st.rollupSpan(day(1, 0), day(20, 0)); days[0] != "" {
+		t.Errorf("rollups used while disabled: %v", days)
+	}
+}
+
+// rollupRows returns the contents of the rollup tables.
+func rollupRows(t *testing.T, st *Store) []string {
+	t.Helper()
+	var out []string
+	for _, q := range []string{
+		`SELECT day, account, type, events FROM rollup_daily_types ORDER BY 1, 2, 3`,
+		`SELECT day, account, world_id, instance_type, region, group_id, joins FROM rollup_daily_worlds ORDER BY 1, 2, 3, 4, 5, 6`,
+		`SELECT day, account, player_key, joins, total_sec FROM rollup_daily_players ORDER BY 1, 2, 3`,
+	} {
+		rows, err := st.db.Query(q)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		cols, _ := rows.Columns()
+		for rows.Next() {
+			vals := make([]any, len(cols))
+			ptrs := make([]any, len(cols))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			out = append(out, fmt.Sprint(vals))
+		}
+		rows.Close()
+	}
+	return out
+}
+
+func TestRollups(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+	defer st.Close()
+
+	// Three days of events on two accounts
+	base := time.Date(2024, 1, 14, 3, 0, 0, 0, time.UTC)
+	n := 0
+	insert := func(e *event.Event) {
+		t.Helper()
+		n++
+		e.DedupeKey = fmt.Sprintf("k%d", n)
+		e.IngestedAt = e.Ts
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+	for d := range 3 {
+		for h := 0; h < 24; h += 6 {
+			ts := base.Add(time.Duration(d*24+h) * time.Hour)
+			var account *string
+			if h == 12 {
+				account = event.StringPtr("alt")
+			}
+			insert(&event.Event{Ts: ts, Type: event.TypeWorldJoin, Account: account,
+				WorldID: event.StringPtr("wrld_a"), InstanceID: event.StringPtr("1~region(jp)"),
+				InstanceType: event.StringPtr("public"), Region: event.StringPtr("jp")})
+			insert(&event.Event{Ts: ts.Add(time.Minute), Type: event.TypeWorldJoin, Account: account})
+			insert(&event.Event{Ts: ts.Add(2 * time.Minute), Type: event.TypePlayerJoin, Account: account,
+				PlayerName: event.StringPtr("Alice")})
+			insert(&event.Event{Ts: ts.Add(time.Hour), Type: event.TypePlayerLeft, Account: account,
+				PlayerName: event.StringPtr("Alice")})
+		}
+	}
+
+	// Stats read from the rollups match counting the events
+	since, until := base.Add(5*time.Hour), base.Add(60*time.Hour)
+	stats := func() (*BasicStats, *InstanceStats) {
+		t.Helper()
+		basic, err := st.GetBasicStats(ctx, since, until, "alt")
+		if err != nil {
+			t.Fatalf("GetBasicStats: %v", err)
+		}
+		inst, err := st.GetInstanceStats(ctx, since, until, "")
+		if err != nil {
+			t.Fatalf("GetInstanceStats: %v", err)
+		}
+		return basic, inst
+	}
+	check := func(when string) {
+		t.Helper()
+		st.SetRollupAfter(DefaultRollupAfter)
+		if days, _ := st.rollupSpan(since, until); days[0] == "" {
+			t.Fatal("range not served from rollups")
+		}
+		basic, inst := stats()
+		st.SetRollupAfter(0)
+		wantBasic, wantInst := stats()
+		if !reflect.DeepEqual(basic, wantBasic) || !reflect.DeepEqual(inst, wantInst) {
+			t.Errorf("%s: rollup stats = %+v %+v, want %+v %+v", when, basic, inst, wantBasic, wantInst)
+		}
+	}
+	check("after ingest")
+
+	// Rollups follow updates and deletes
+	if _, err := st.db.Exec(`UPDATE events SET region = 'us' WHERE id = 1`); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	region := "jp"
+	if _, err := st.DeleteEvents(ctx, QueryFilter{Region: &region, Account: event.StringPtr("alt")}, 0, ""); err != nil {
+		t.Fatalf("DeleteEvents: %v", err)
+	}
+	check("after update and delete")
+
+	// Seeding an existing database gives the same rollups
+	before := rollupRows(t, st)
+	if _, err := st.db.Exec(`
+		DROP TRIGGER events_rollup_insert; DROP TRIGGER events_rollup_update; DROP TRIGGER events_rollup_delete;
+		DROP TABLE rollup_daily_types; DROP TABLE rollup_daily_worlds; DROP TABLE rollup_daily_players;
+	`); err != nil {
+		t.Fatalf("drop rollups: %v", err)
+	}
+	if err := st.createRollupTables(ctx); err != nil {
+		t.Fatalf("createRollupTables: %v", err)
+	}
+	if after := rollupRows(t, st); !reflect.DeepEqual(after, before) {
+		t.Errorf("seeded rollups =\n%v\nwant\n%v", after, before)
+	}
+	if len(before) == 0 {
+		t.Error("no rollups")
+	}
+}
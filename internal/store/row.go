@@ -9,6 +9,29 @@ import (
 	"github.com/graaaaa/vrclog-companion/internal/event"
 )
 
+// eventColumns is the column list for selecting full event rows.
+// Must match the field order in scanEventRow.
+const eventColumns = `id, ts, type, player_name, player_id, world_id, world_name, instance_id,
+instance_type, region, group_id, duration_sec, account, meta_json, dedupe_key, ingested_at, schema_version`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanEventRow scans a row selected with eventColumns.
+func scanEventRow(sc rowScanner) (*eventRow, error) {
+	var r eventRow
+	if err := sc.Scan(
+		&r.ID, &r.Ts, &r.Type, &r.PlayerName, &r.PlayerID,
+		&r.WorldID, &r.WorldName, &r.InstanceID, &r.InstanceType, &r.Region,
+		&r.GroupID, &r.DurationSec, &r.Account, &r.MetaJSON, &r.DedupeKey, &r.IngestedAt, &r.SchemaVersion,
+	); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
 // eventRow is the internal type representing a database row.
 type eventRow struct {
 	ID            int64
@@ -19,6 +42,11 @@ type eventRow struct {
 	WorldID       sql.NullString
 	WorldName     sql.NullString
 	InstanceID    sql.NullString
+	InstanceType  sql.NullString
+	Region        sql.NullString
+	GroupID       sql.NullString
+	DurationSec   sql.NullInt64
+	Account       sql.NullString
 	MetaJSON      sql.NullString
 	DedupeKey     string
 	IngestedAt    string
@@ -61,6 +89,21 @@ func (r *eventRow) toEvent() (*event.Event, error) {
 	if r.InstanceID.Valid {
 		e.InstanceID = &r.InstanceID.String
 	}
+	if r.InstanceType.Valid {
+		e.InstanceType = &r.InstanceType.String
+	}
+	if r.Region.Valid {
+		e.Region = &r.Region.String
+	}
+	if r.GroupID.Valid {
+		e.GroupID = &r.GroupID.String
+	}
+	if r.DurationSec.Valid {
+		e.DurationSec = &r.DurationSec.Int64
+	}
+	if r.Account.Valid {
+		e.Account = &r.Account.String
+	}
 	if r.MetaJSON.Valid && r.MetaJSON.String != "" {
 		e.MetaJSON = json.RawMessage(r.MetaJSON.String)
 	}
@@ -94,6 +137,21 @@ func eventToRow(e *event.Event) *eventRow {
 	if e.InstanceID != nil {
 		r.InstanceID = sql.NullString{String: *e.InstanceID, Valid: true}
 	}
+	if e.InstanceType != nil {
+		r.InstanceType = sql.NullString{String: *e.InstanceType, Valid: true}
+	}
+	if e.Region != nil {
+		r.Region = sql.NullString{String: *e.Region, Valid: true}
+	}
+	if e.GroupID != nil {
+		r.GroupID = sql.NullString{String: *e.GroupID, Valid: true}
+	}
+	if e.DurationSec != nil {
+		r.DurationSec = sql.NullInt64{Int64: *e.DurationSec, Valid: true}
+	}
+	if e.Account != nil && *e.Account != "" {
+		r.Account = sql.NullString{String: *e.Account, Valid: true}
+	}
 	if len(e.MetaJSON) > 0 {
 		r.MetaJSON = sql.NullString{String: string(e.MetaJSON), Valid: true}
 	}
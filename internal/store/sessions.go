@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// metadataKeySessionGeneration holds the web UI session generation.
+const metadataKeySessionGeneration = "session_generation"
+
+// SessionGeneration returns the web UI session generation, 0 if it was
+// never bumped. Sessions are signed with a key derived from it, so bumping
+// it ends every session.
+func (s *Store) SessionGeneration(ctx context.Context) (int64, error) {
+	var value string
+	err := s.queryRow(ctx, `SELECT value FROM metadata WHERE key = ?`, metadataKeySessionGeneration).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get session generation: %w", err)
+	}
+	gen, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session generation %q", value)
+	}
+	return gen, nil
+}
+
+// BumpSessionGeneration increments the web UI session generation and
+// returns the new one.
+func (s *Store) BumpSessionGeneration(ctx context.Context) (int64, error) {
+	var gen int64
+	err := s.queryRow(ctx, `
+		INSERT INTO metadata (key, value) VALUES (?, '1')
+		ON CONFLICT(key) DO UPDATE SET value = CAST(value AS INTEGER) + 1
+		RETURNING value
+	`, metadataKeySessionGeneration).Scan(&gen)
+	if err != nil {
+		return 0, fmt.Errorf("bump session generation: %w", err)
+	}
+	return gen, nil
+}
+
+// RevokeSession records that the web UI session with the given token hash
+// was logged out, until it expires. Expired revocations are deleted.
+func (s *Store) RevokeSession(ctx context.Context, tokenHash string, expiresAt time.Time) error {
+	if _, err := s.exec(ctx, `DELETE FROM revoked_sessions WHERE expires_at < ?`,
+		time.Now().UTC().Format(TimeFormat)); err != nil {
+		return fmt.Errorf("delete expired session revocations: %w", err)
+	}
+	if _, err := s.exec(ctx, `INSERT OR REPLACE INTO revoked_sessions (token_hash, expires_at) VALUES (?, ?)`,
+		tokenHash, expiresAt.UTC().Format(TimeFormat)); err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokedSessions returns the token hashes of the sessions revoked and not
+// yet expired at now, with their expiry.
+func (s *Store) RevokedSessions(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	rows, err := s.query(ctx, `SELECT token_hash, expires_at FROM revoked_sessions WHERE expires_at >= ?`,
+		now.UTC().Format(TimeFormat))
+	if err != nil {
+		return nil, fmt.Errorf("query revoked sessions: %w", err)
+	}
+	defer rows.Close()
+
+	revoked := make(map[string]time.Time)
+	for rows.Next() {
+		var hash, exp string
+		if err := rows.Scan(&hash, &exp); err != nil {
+			return nil, fmt.Errorf("scan revoked session: %w", err)
+		}
+		revoked[hash], _ = time.Parse(TimeFormat, exp)
+	}
+	return revoked, rows.Err()
+}
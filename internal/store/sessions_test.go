@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSessionGeneration(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	if gen, err := store.SessionGeneration(ctx); err != nil || gen != 0 {
+		t.Fatalf("SessionGeneration = %d, %v, want 0", gen, err)
+	}
+	for want := int64(1); want <= 2; want++ {
+		if gen, err := store.BumpSessionGeneration(ctx); err != nil || gen != want {
+			t.Fatalf("BumpSessionGeneration = %d, %v, want %d", gen, err, want)
+		}
+	}
+	if gen, _ := store.SessionGeneration(ctx); gen != 2 {
+		t.Errorf("SessionGeneration = %d, want 2", gen)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := store.RevokeSession(ctx, "expired", now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeSession(ctx, "live", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := store.RevokedSessions(ctx, now)
+	if err != nil {
+		t.Fatalf("RevokedSessions: %v", err)
+	}
+	if len(revoked) != 1 || revoked["live"].IsZero() {
+		t.Errorf("revoked = %v, want only the live session", revoked)
+	}
+
+	// The next revocation deletes the expired one
+	var n int
+	store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM revoked_sessions").Scan(&n)
+	if n != 1 {
+		t.Errorf("%d rows, want the expired revocation deleted", n)
+	}
+}
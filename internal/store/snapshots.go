@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StateSnapshot is a saved copy of the derived state of an account: the
+// current world and the players in it at Ts.
+type StateSnapshot struct {
+	ID          int64            `json:"id"`
+	Ts          time.Time        `json:"ts"`
+	Account     string           `json:"account,omitempty"` // empty for the default account
+	WorldID     string           `json:"world_id,omitempty"`
+	WorldName   string           `json:"world_name,omitempty"`
+	InstanceID  string           `json:"instance_id,omitempty"`
+	JoinedAt    *time.Time       `json:"joined_at,omitempty"`
+	PlayerCount int              `json:"player_count"`
+	PeakPlayers int              `json:"peak_players"`
+	Pinned      bool             `json:"pinned"` // inside a pinned range, exempt from retention
+	Players     []SnapshotPlayer `json:"players,omitempty"`
+}
+
+// SnapshotPlayer is a player present at the time of a snapshot.
+type SnapshotPlayer struct {
+	PlayerName string    `json:"player_name"`
+	PlayerID   string    `json:"player_id,omitempty"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+// SaveStateSnapshot stores a snapshot and returns its ID.
+// PlayerCount is derived from Players.
+func (s *Store) SaveStateSnapshot(ctx context.Context, snap StateSnapshot) (int64, error) {
+	players := snap.Players
+	if players == nil {
+		players = []SnapshotPlayer{}
+	}
+	playersJSON, err := json.Marshal(players)
+	if err != nil {
+		return 0, fmt.Errorf("marshal players: %w", err)
+	}
+
+	var joinedAt sql.NullString
+	if snap.JoinedAt != nil {
+		joinedAt = sql.NullString{String: snap.JoinedAt.UTC().Format(TimeFormat), Valid: true}
+	}
+
+	res, err := s.exec(ctx, `
+		INSERT INTO state_snapshots
+			(ts, account, world_id, world_name, instance_id, joined_at, player_count, peak_players, players_json)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, snap.Ts.UTC().Format(TimeFormat), nullIfEmpty(snap.Account),
+		nullIfEmpty(snap.WorldID), nullIfEmpty(snap.WorldName), nullIfEmpty(snap.InstanceID),
+		joinedAt, len(players), snap.PeakPlayers, string(playersJSON))
+	if err != nil {
+		return 0, fmt.Errorf("insert state snapshot: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// LatestStateSnapshot returns the most recent snapshot of account including
+// its players, or ErrNotFound if none has been saved. The default account
+// is "".
+func (s *Store) LatestStateSnapshot(ctx context.Context, account string) (*StateSnapshot, error) {
+	row := s.queryRow(ctx, `
+		SELECT id, ts, account, world_id, world_name, instance_id, joined_at, player_count, peak_players,
+			`+pinnedCond+`, players_json
+		FROM state_snapshots
+		WHERE account IS ?
+		ORDER BY ts DESC, id DESC
+		LIMIT 1
+	`, nullIfEmpty(account))
+
+	var playersJSON string
+	snap, err := scanStateSnapshot(row.Scan, &playersJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(playersJSON), &snap.Players); err != nil {
+		return nil, fmt.Errorf("decode snapshot %d players: %w", snap.ID, err)
+	}
+	return snap, nil
+}
+
+// ListStateSnapshots returns snapshots in [since, until), oldest first and
+// without their player lists. An empty account covers all accounts.
+// limit defaults to 100 and is capped at 500, as for events.
+func (s *Store) ListStateSnapshots(ctx context.Context, since, until time.Time, account string, limit int) ([]StateSnapshot, error) {
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+	accountCond, accountArgs := accountClause(account)
+	args := append([]any{since.UTC().Format(TimeFormat), until.UTC().Format(TimeFormat)}, accountArgs...)
+	rows, err := s.query(ctx, `
+		SELECT id, ts, account, world_id, world_name, instance_id, joined_at, player_count, peak_players,
+			`+pinnedCond+`, ''
+		FROM state_snapshots
+		WHERE ts >= ? AND ts < ?`+accountCond+`
+		ORDER BY ts ASC, id ASC
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("query state snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snaps := []StateSnapshot{}
+	for rows.Next() {
+		var ignored string
+		snap, err := scanStateSnapshot(rows.Scan, &ignored)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, *snap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return snaps, nil
+}
+
+// PruneStateSnapshots deletes snapshots taken before cutoff, except those in
+// a pinned range, and returns how many were deleted.
+func (s *Store) PruneStateSnapshots(ctx context.Context, cutoff time.Time) (int64, error) {
+	if err := s.closeOpenPins(ctx); err != nil {
+		return 0, err
+	}
+	res, err := s.exec(ctx,
+		`DELETE FROM state_snapshots WHERE ts < ? AND NOT `+pinnedCond, cutoff.UTC().Format(TimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("prune state snapshots: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func scanStateSnapshot(scan func(dest ...any) error, playersJSON *string) (*StateSnapshot, error) {
+	var (
+		snap                           StateSnapshot
+		ts                             string
+		account                        sql.NullString
+		worldID, worldName, instanceID sql.NullString
+		joinedAt                       sql.NullString
+	)
+	if err := scan(&snap.ID, &ts, &account, &worldID, &worldName, &instanceID, &joinedAt,
+		&snap.PlayerCount, &snap.PeakPlayers, &snap.Pinned, playersJSON); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("scan state snapshot: %w", err)
+	}
+
+	var err error
+	if snap.Ts, err = time.Parse(TimeFormat, ts); err != nil {
+		return nil, fmt.Errorf("parse ts %q: %w", ts, err)
+	}
+	if joinedAt.Valid {
+		t, err := time.Parse(TimeFormat, joinedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("parse joined_at %q: %w", joinedAt.String, err)
+		}
+		snap.JoinedAt = &t
+	}
+	snap.Account = account.String
+	snap.WorldID, snap.WorldName, snap.InstanceID = worldID.String, worldName.String, instanceID.String
+	return &snap, nil
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateSnapshots(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	if _, err := st.LatestStateSnapshot(ctx, ""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("LatestStateSnapshot on empty store: err = %v, want ErrNotFound", err)
+	}
+
+	// An empty state, then a world with two players
+	if _, err := st.SaveStateSnapshot(ctx, StateSnapshot{Ts: base}); err != nil {
+		t.Fatalf("SaveStateSnapshot: %v", err)
+	}
+	joined := base.Add(time.Minute)
+	_, err := st.SaveStateSnapshot(ctx, StateSnapshot{
+		Ts:          base.Add(2 * time.Minute),
+		WorldID:     "wrld_a",
+		WorldName:   "World A",
+		InstanceID:  "1~region(jp)",
+		JoinedAt:    &joined,
+		PeakPlayers: 3,
+		Players: []SnapshotPlayer{
+			{PlayerName: "Alice", PlayerID: "usr_a", JoinedAt: joined},
+			{PlayerName: "Bob", JoinedAt: joined},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SaveStateSnapshot: %v", err)
+	}
+
+	latest, err := st.LatestStateSnapshot(ctx, "")
+	if err != nil {
+		t.Fatalf("LatestStateSnapshot: %v", err)
+	}
+	if latest.WorldName != "World A" || latest.PlayerCount != 2 || latest.PeakPlayers != 3 {
+		t.Errorf("latest = %+v", latest)
+	}
+	if latest.JoinedAt == nil || !latest.JoinedAt.Equal(joined) {
+		t.Errorf("joined_at = %v, want %v", latest.JoinedAt, joined)
+	}
+	if len(latest.Players) != 2 || latest.Players[0].PlayerID != "usr_a" {
+		t.Errorf("players = %+v", latest.Players)
+	}
+
+	list, err := st.ListStateSnapshots(ctx, base, base.Add(time.Hour), "", 0)
+	if err != nil {
+		t.Fatalf("ListStateSnapshots: %v", err)
+	}
+	if len(list) != 2 || list[0].PlayerCount != 0 || list[1].PlayerCount != 2 {
+		t.Fatalf("list = %+v", list)
+	}
+	if list[1].Players != nil {
+		t.Errorf("expected list to omit players, got %+v", list[1].Players)
+	}
+
+	n, err := st.PruneStateSnapshots(ctx, base.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PruneStateSnapshots: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("pruned %d, want 1", n)
+	}
+}
+
+func TestStateSnapshots_Accounts(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	for i, s := range []StateSnapshot{
+		{Ts: base, WorldName: "Main"},
+		{Ts: base.Add(time.Minute), Account: "alt", WorldName: "Alt"},
+	} {
+		if _, err := st.SaveStateSnapshot(ctx, s); err != nil {
+			t.Fatalf("SaveStateSnapshot %d: %v", i, err)
+		}
+	}
+
+	// The alt's later snapshot does not replace the default account's latest
+	latest, err := st.LatestStateSnapshot(ctx, "")
+	if err != nil || latest.WorldName != "Main" || latest.Account != "" {
+		t.Errorf("default latest = %+v, %v", latest, err)
+	}
+	latest, err = st.LatestStateSnapshot(ctx, "alt")
+	if err != nil || latest.WorldName != "Alt" || latest.Account != "alt" {
+		t.Errorf("alt latest = %+v, %v", latest, err)
+	}
+
+	list, err := st.ListStateSnapshots(ctx, base, base.Add(time.Hour), "alt", 0)
+	if err != nil {
+		t.Fatalf("ListStateSnapshots: %v", err)
+	}
+	if len(list) != 1 || list[0].Account != "alt" {
+		t.Errorf("alt list = %+v", list)
+	}
+}
+
+func TestListStateSnapshots_Limit(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < maxLimit+1; i++ {
+		if _, err := st.SaveStateSnapshot(ctx, StateSnapshot{Ts: base.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("SaveStateSnapshot: %v", err)
+		}
+	}
+
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, defaultLimit},
+		{10, 10},
+		{maxLimit + 1, maxLimit},
+	}
+	for _, tt := range tests {
+		list, err := st.ListStateSnapshots(ctx, base, base.Add(time.Hour), "", tt.limit)
+		if err != nil {
+			t.Fatalf("ListStateSnapshots(limit=%d): %v", tt.limit, err)
+		}
+		if len(list) != tt.want {
+			t.Errorf("ListStateSnapshots(limit=%d) returned %d, want %d", tt.limit, len(list), tt.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package store
 import (
 	"context"
 	"database/sql"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/graaaaa/vrclog-companion/internal/event"
@@ -18,37 +20,58 @@ type BasicStats struct {
 }
 
 // GetBasicStats retrieves basic statistics for the specified time range.
-// Uses local time for "today" calculation.
-func (s *Store) GetBasicStats(ctx context.Context, since, until time.Time) (*BasicStats, error) {
+// Uses local time for "today" calculation. An empty account covers all accounts.
+func (s *Store) GetBasicStats(ctx context.Context, since, until time.Time, account string) (*BasicStats, error) {
 	stats := &BasicStats{
 		RecentPlayers: []string{},
 	}
 
-	// Format times for SQL query
-	sinceStr := since.UTC().Format(TimeFormat)
-	untilStr := until.UTC().Format(TimeFormat)
+	accountCond, accountArgs := accountClause(account)
 
-	// Get aggregated counts in a single query
-	err := s.db.QueryRowContext(ctx, `
-		SELECT
-			COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS join_count,
-			COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS leave_count,
-			COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS world_count
-		FROM events
-		WHERE ts >= ? AND ts < ?
-	`, event.TypePlayerJoin, event.TypePlayerLeft, event.TypeWorldJoin, sinceStr, untilStr).
-		Scan(&stats.JoinCount, &stats.LeaveCount, &stats.WorldChangeCount)
-	if err != nil {
-		return nil, err
+	// Get aggregated counts, from the rollups for old days
+	days, raw := s.rollupSpan(since, until)
+	counts := func(query string, args ...any) error {
+		var joins, leaves, worlds int
+		err := s.queryRow(ctx, query, append(
+			[]any{event.TypePlayerJoin, event.TypePlayerLeft, event.TypeWorldJoin}, append(args, accountArgs...)...)...).
+			Scan(&joins, &leaves, &worlds)
+		stats.JoinCount += joins
+		stats.LeaveCount += leaves
+		stats.WorldChangeCount += worlds
+		return err
+	}
+	for _, r := range raw {
+		if err := counts(`
+			SELECT
+				COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS join_count,
+				COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS leave_count,
+				COALESCE(SUM(CASE WHEN type = ? THEN 1 ELSE 0 END), 0) AS world_count
+			FROM events
+			WHERE ts >= ? AND ts < ?`+accountCond+`
+		`, r[0], r[1]); err != nil {
+			return nil, err
+		}
+	}
+	if days[0] != "" {
+		if err := counts(`
+			SELECT
+				COALESCE(SUM(CASE WHEN type = ? THEN events ELSE 0 END), 0) AS join_count,
+				COALESCE(SUM(CASE WHEN type = ? THEN events ELSE 0 END), 0) AS leave_count,
+				COALESCE(SUM(CASE WHEN type = ? THEN events ELSE 0 END), 0) AS world_count
+			FROM rollup_daily_types
+			WHERE day >= ? AND day < ?`+accountCond+`
+		`, days[0], days[1]); err != nil {
+			return nil, err
+		}
 	}
 
 	// Get recent unique players (last 5 who joined)
-	rows, err := s.db.QueryContext(ctx, `
+	rows, err := s.query(ctx, `
 		SELECT DISTINCT player_name FROM events
-		WHERE type = ? AND player_name IS NOT NULL AND player_name != ''
+		WHERE type = ? AND player_name IS NOT NULL AND player_name != ''`+accountCond+`
 		ORDER BY ts DESC
 		LIMIT 5
-	`, event.TypePlayerJoin)
+	`, append([]any{event.TypePlayerJoin}, accountArgs...)...)
 	if err != nil {
 		return nil, err
 	}
@@ -67,11 +90,12 @@ func (s *Store) GetBasicStats(ctx context.Context, since, until time.Time) (*Bas
 
 	// Get last event timestamp
 	var lastTs sql.NullString
-	err = s.db.QueryRowContext(ctx, `
+	err = s.queryRow(ctx, `
 		SELECT ts FROM events
+		WHERE 1=1`+accountCond+`
 		ORDER BY ts DESC, id DESC
 		LIMIT 1
-	`).Scan(&lastTs)
+	`, accountArgs...).Scan(&lastTs)
 	if err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
@@ -82,6 +106,156 @@ func (s *Store) GetBasicStats(ctx context.Context, since, until time.Time) (*Bas
 	return stats, nil
 }
 
+// GroupCount is a count of events sharing a grouping key.
+type GroupCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// InstanceStats holds world join counts grouped by instance attributes.
+type InstanceStats struct {
+	ByType   []GroupCount `json:"by_type"`
+	ByRegion []GroupCount `json:"by_region"`
+	ByGroup  []GroupCount `json:"by_group"` // group instances only
+}
+
+// GetInstanceStats counts world joins in the time range grouped by instance
+// type, region, and group. Only joins that carry an instance ID are counted;
+// missing attributes are grouped under "unknown". An empty account covers
+// all accounts.
+func (s *Store) GetInstanceStats(ctx context.Context, since, until time.Time, account string) (*InstanceStats, error) {
+	days, raw := s.rollupSpan(since, until)
+
+	byType, err := s.countWorldJoinsBy(ctx, "instance_type", true, days, raw, account)
+	if err != nil {
+		return nil, err
+	}
+	byRegion, err := s.countWorldJoinsBy(ctx, "region", true, days, raw, account)
+	if err != nil {
+		return nil, err
+	}
+	byGroup, err := s.countWorldJoinsBy(ctx, "group_id", false, days, raw, account)
+	if err != nil {
+		return nil, err
+	}
+	return &InstanceStats{ByType: byType, ByRegion: byRegion, ByGroup: byGroup}, nil
+}
+
+// countWorldJoinsBy groups world_join events by column, counting the time
+// ranges raw from events and the days from rollup_daily_worlds (see
+// rollupSpan). column must be a trusted identifier (never user input). If
+// includeNull is false, rows with a NULL column are skipped instead of
+// being grouped under "unknown".
+func (s *Store) countWorldJoinsBy(ctx context.Context, column string, includeNull bool, days [2]string, raw [][2]string, account string) ([]GroupCount, error) {
+	nullFilter, rollupNullFilter := "", ""
+	if !includeNull {
+		nullFilter = " AND " + column + " IS NOT NULL"
+		rollupNullFilter = " AND " + column + " != ''"
+	}
+	accountCond, accountArgs := accountClause(account)
+
+	counts := make(map[string]int)
+	add := func(query string, args ...any) error {
+		rows, err := s.query(ctx, query, append(args, accountArgs...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var g GroupCount
+			if err := rows.Scan(&g.Key, &g.Count); err != nil {
+				return err
+			}
+			counts[g.Key] += g.Count
+		}
+		return rows.Err()
+	}
+	for _, r := range raw {
+		if err := add(`
+			SELECT COALESCE(`+column+`, 'unknown') AS k, COUNT(*) AS n
+			FROM events
+			WHERE type = ? AND instance_id IS NOT NULL AND ts >= ? AND ts < ?`+nullFilter+accountCond+`
+			GROUP BY k
+		`, event.TypeWorldJoin, r[0], r[1]); err != nil {
+			return nil, err
+		}
+	}
+	if days[0] != "" {
+		if err := add(`
+			SELECT COALESCE(NULLIF(`+column+`, ''), 'unknown') AS k, SUM(joins) AS n
+			FROM rollup_daily_worlds
+			WHERE day >= ? AND day < ?`+rollupNullFilter+accountCond+`
+			GROUP BY k
+		`, days[0], days[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]GroupCount, 0, len(counts))
+	for k, n := range counts {
+		result = append(result, GroupCount{Key: k, Count: n})
+	}
+	slices.SortFunc(result, func(a, b GroupCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return result, nil
+}
+
+// Heatmap holds event counts per local weekday and hour.
+// Counts is indexed by time.Weekday (0 = Sunday) then hour (0-23).
+type Heatmap struct {
+	Counts [7][24]int `json:"counts"`
+	Total  int        `json:"total"`
+}
+
+// GetHeatmap counts events in the time range per weekday and hour of the
+// local time zone. If typ is non-empty, only events of that type are counted;
+// if account is non-empty, only events of that account.
+// Events are grouped by UTC hour in SQL and shifted to local time here, so
+// zones with a fractional-hour offset are bucketed by the hour they start in.
+func (s *Store) GetHeatmap(ctx context.Context, since, until time.Time, account, typ string) (*Heatmap, error) {
+	query := `
+		SELECT substr(ts, 1, 13) AS hour, COUNT(*) AS n
+		FROM events
+		WHERE ts >= ? AND ts < ?`
+	args := []any{since.UTC().Format(TimeFormat), until.UTC().Format(TimeFormat)}
+	if typ != "" {
+		query += " AND type = ?"
+		args = append(args, typ)
+	}
+	accountCond, accountArgs := accountClause(account)
+	query += accountCond + " GROUP BY hour"
+	args = append(args, accountArgs...)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	h := &Heatmap{}
+	for rows.Next() {
+		var (
+			hour string
+			n    int
+		)
+		if err := rows.Scan(&hour, &n); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("2006-01-02T15", hour)
+		if err != nil {
+			return nil, err
+		}
+		local := t.Local()
+		h.Counts[local.Weekday()][local.Hour()] += n
+		h.Total += n
+	}
+	return h, rows.Err()
+}
+
 // GetTodayBoundary returns the start and end times for "today" in local time.
 func GetTodayBoundary() (since, until time.Time) {
 	now := time.Now()
@@ -93,3 +267,12 @@ func GetTodayBoundary() (since, until time.Time) {
 	until = since.AddDate(0, 0, 1)
 	return since, until
 }
+
+// accountClause returns an SQL condition (with leading " AND") restricting
+// events to account, or an empty condition if account is empty.
+func accountClause(account string) (string, []any) {
+	if account == "" {
+		return "", nil
+	}
+	return " AND account = ?", []any{account}
+}
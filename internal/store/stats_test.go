@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -32,7 +33,7 @@ func TestGetBasicStats_Empty(t *testing.T) {
 	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	until := since.Add(24 * time.Hour)
 
-	stats, err := st.GetBasicStats(context.Background(), since, until)
+	stats, err := st.GetBasicStats(context.Background(), since, until, "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -75,7 +76,7 @@ func TestGetBasicStats_Counts(t *testing.T) {
 	insertTestEvent(t, st, since.Add(3*time.Hour), event.TypePlayerLeft, "player1", "k3")
 	insertTestEvent(t, st, since.Add(4*time.Hour), event.TypeWorldJoin, "", "k4")
 
-	stats, err := st.GetBasicStats(context.Background(), since, until)
+	stats, err := st.GetBasicStats(context.Background(), since, until, "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -107,7 +108,7 @@ func TestGetBasicStats_Boundary(t *testing.T) {
 	// Event within range
 	insertTestEvent(t, st, since.Add(12*time.Hour), event.TypePlayerJoin, "p3", "k3")
 
-	stats, err := st.GetBasicStats(context.Background(), since, until)
+	stats, err := st.GetBasicStats(context.Background(), since, until, "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -130,7 +131,7 @@ func TestGetBasicStats_RecentPlayersLimit(t *testing.T) {
 		insertTestEvent(t, st, base.Add(time.Duration(i)*time.Minute), event.TypePlayerJoin, "player"+name, "k"+name)
 	}
 
-	stats, err := st.GetBasicStats(context.Background(), base, base.Add(24*time.Hour))
+	stats, err := st.GetBasicStats(context.Background(), base, base.Add(24*time.Hour), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -157,7 +158,7 @@ func TestGetBasicStats_RecentPlayersUnique(t *testing.T) {
 	insertTestEvent(t, st, base.Add(2*time.Minute), event.TypePlayerJoin, "bob", "k2")
 	insertTestEvent(t, st, base.Add(3*time.Minute), event.TypePlayerJoin, "alice", "k3") // Duplicate
 
-	stats, err := st.GetBasicStats(context.Background(), base, base.Add(24*time.Hour))
+	stats, err := st.GetBasicStats(context.Background(), base, base.Add(24*time.Hour), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -179,7 +180,7 @@ func TestGetBasicStats_LastEventAt(t *testing.T) {
 	insertTestEvent(t, st, lastTs, event.TypePlayerJoin, "p2", "k2")
 	insertTestEvent(t, st, base.Add(3*time.Hour), event.TypePlayerJoin, "p3", "k3")
 
-	stats, err := st.GetBasicStats(context.Background(), base, base.Add(24*time.Hour))
+	stats, err := st.GetBasicStats(context.Background(), base, base.Add(24*time.Hour), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -212,7 +213,7 @@ func TestGetBasicStats_LastEventAtGlobal(t *testing.T) {
 	// Insert event within query range
 	insertTestEvent(t, st, queryRange.Add(1*time.Hour), event.TypePlayerJoin, "current", "k2")
 
-	stats, err := st.GetBasicStats(context.Background(), queryRange, queryRange.Add(24*time.Hour))
+	stats, err := st.GetBasicStats(context.Background(), queryRange, queryRange.Add(24*time.Hour), "")
 	if err != nil {
 		t.Fatalf("GetBasicStats: %v", err)
 	}
@@ -232,6 +233,107 @@ func TestGetBasicStats_LastEventAtGlobal(t *testing.T) {
 	}
 }
 
+func TestGetInstanceStats(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	joins := []struct {
+		instanceID, instanceType, region string
+	}{
+		{"1~region(jp)", "public", "jp"},
+		{"2~region(jp)", "public", "jp"},
+		{"3~friends(usr_a)~region(us)", "friends", "us"},
+		{"4", "public", ""},
+	}
+	for i, j := range joins {
+		e := &event.Event{
+			Ts:           base.Add(time.Duration(i) * time.Minute),
+			Type:         event.TypeWorldJoin,
+			InstanceID:   event.StringPtr(j.instanceID),
+			InstanceType: event.StringPtr(j.instanceType),
+			DedupeKey:    j.instanceID,
+			IngestedAt:   base,
+		}
+		if j.region != "" {
+			e.Region = event.StringPtr(j.region)
+		}
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	// World join without instance info ("Entering Room") is not counted
+	insertTestEvent(t, st, base, event.TypeWorldJoin, "", "entering-room")
+
+	stats, err := st.GetInstanceStats(ctx, base.Add(-time.Hour), base.Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("GetInstanceStats: %v", err)
+	}
+
+	wantType := []GroupCount{{"public", 3}, {"friends", 1}}
+	if len(stats.ByType) != len(wantType) {
+		t.Fatalf("ByType = %+v, want %+v", stats.ByType, wantType)
+	}
+	for i := range wantType {
+		if stats.ByType[i] != wantType[i] {
+			t.Errorf("ByType[%d] = %+v, want %+v", i, stats.ByType[i], wantType[i])
+		}
+	}
+
+	wantRegion := []GroupCount{{"jp", 2}, {"unknown", 1}, {"us", 1}}
+	if len(stats.ByRegion) != len(wantRegion) {
+		t.Fatalf("ByRegion = %+v, want %+v", stats.ByRegion, wantRegion)
+	}
+	for i := range wantRegion {
+		if stats.ByRegion[i] != wantRegion[i] {
+			t.Errorf("ByRegion[%d] = %+v, want %+v", i, stats.ByRegion[i], wantRegion[i])
+		}
+	}
+}
+
+func TestGetInstanceStats_ByGroup(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	groups := []string{"grp_a", "grp_b", "grp_a", ""}
+	for i, g := range groups {
+		e := &event.Event{
+			Ts:         base.Add(time.Duration(i) * time.Minute),
+			Type:       event.TypeWorldJoin,
+			InstanceID: event.StringPtr(fmt.Sprintf("%d", i)),
+			DedupeKey:  fmt.Sprintf("group-%d", i),
+			IngestedAt: base,
+		}
+		if g != "" {
+			e.GroupID = event.StringPtr(g)
+		}
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	stats, err := st.GetInstanceStats(ctx, base.Add(-time.Hour), base.Add(time.Hour), "")
+	if err != nil {
+		t.Fatalf("GetInstanceStats: %v", err)
+	}
+
+	// Non-group instances are not listed
+	want := []GroupCount{{"grp_a", 2}, {"grp_b", 1}}
+	if len(stats.ByGroup) != len(want) {
+		t.Fatalf("ByGroup = %+v, want %+v", stats.ByGroup, want)
+	}
+	for i := range want {
+		if stats.ByGroup[i] != want[i] {
+			t.Errorf("ByGroup[%d] = %+v, want %+v", i, stats.ByGroup[i], want[i])
+		}
+	}
+}
+
 func TestGetTodayBoundary(t *testing.T) {
 	since, until := GetTodayBoundary()
 
@@ -246,3 +348,204 @@ func TestGetTodayBoundary(t *testing.T) {
 		t.Errorf("until - since = %v, want 24h", diff)
 	}
 }
+
+func TestGetCopresence(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	events := []struct {
+		typ    string
+		player string
+		offset time.Duration
+	}{
+		{event.TypeWorldJoin, "", 0},
+		{event.TypePlayerJoin, "Alice", time.Minute},
+		{event.TypePlayerJoin, "Bob", time.Minute},
+		{event.TypePlayerLeft, "Bob", 11 * time.Minute},
+		{event.TypeWorldJoin, "", 31 * time.Minute}, // closes Alice's session (30m)
+		{event.TypePlayerJoin, "Bob", 32 * time.Minute},
+		{event.TypePlayerLeft, "Bob", 62 * time.Minute},
+		{event.TypePlayerJoin, "Carol", 70 * time.Minute},
+	}
+	for i, e := range events {
+		ev := &event.Event{
+			Ts:         base.Add(e.offset),
+			Type:       e.typ,
+			DedupeKey:  fmt.Sprintf("co-%d", i),
+			IngestedAt: base,
+		}
+		if e.player != "" {
+			ev.PlayerName = event.StringPtr(e.player)
+		}
+		if _, _, err := st.InsertEvent(ctx, ev); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	// Carol's open session is cut at until
+	got, err := st.GetCopresence(ctx, base, base.Add(75*time.Minute), "", 0)
+	if err != nil {
+		t.Fatalf("GetCopresence: %v", err)
+	}
+	want := []CopresenceEntry{
+		{PlayerName: "Bob", Minutes: 40, Sessions: 2},
+		{PlayerName: "Alice", Minutes: 30, Sessions: 1},
+		{PlayerName: "Carol", Minutes: 5, Sessions: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	got, err = st.GetCopresence(ctx, base, base.Add(75*time.Minute), "", 1)
+	if err != nil {
+		t.Fatalf("GetCopresence: %v", err)
+	}
+	if len(got) != 1 || got[0].PlayerName != "Bob" {
+		t.Errorf("limit 1: got %+v, want Bob only", got)
+	}
+}
+
+func TestGetHeatmap(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	// Local times so the expected buckets do not depend on the test machine's zone
+	mon9 := time.Date(2024, 1, 15, 9, 10, 0, 0, time.Local)
+	mon9b := time.Date(2024, 1, 15, 9, 50, 0, 0, time.Local)
+	sat22 := time.Date(2024, 1, 20, 22, 0, 0, 0, time.Local)
+
+	for i, e := range []struct {
+		ts  time.Time
+		typ string
+	}{
+		{mon9, event.TypePlayerJoin},
+		{mon9b, event.TypeWorldJoin},
+		{sat22, event.TypePlayerJoin},
+	} {
+		ev := &event.Event{Ts: e.ts, Type: e.typ, DedupeKey: fmt.Sprintf("hm-%d", i), IngestedAt: e.ts}
+		if _, _, err := st.InsertEvent(ctx, ev); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	since := time.Date(2024, 1, 14, 0, 0, 0, 0, time.Local)
+	until := since.AddDate(0, 0, 7)
+
+	h, err := st.GetHeatmap(ctx, since, until, "", "")
+	if err != nil {
+		t.Fatalf("GetHeatmap: %v", err)
+	}
+	if h.Total != 3 {
+		t.Errorf("Total = %d, want 3", h.Total)
+	}
+	if got := h.Counts[time.Monday][9]; got != 2 {
+		t.Errorf("Monday 09h = %d, want 2", got)
+	}
+	if got := h.Counts[time.Saturday][22]; got != 1 {
+		t.Errorf("Saturday 22h = %d, want 1", got)
+	}
+
+	h, err = st.GetHeatmap(ctx, since, until, "", event.TypeWorldJoin)
+	if err != nil {
+		t.Fatalf("GetHeatmap: %v", err)
+	}
+	if h.Total != 1 || h.Counts[time.Monday][9] != 1 {
+		t.Errorf("world_join heatmap = total %d, Monday 09h %d; want 1, 1", h.Total, h.Counts[time.Monday][9])
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	// Both accounts meet Alice; the alt then changes world, which must not
+	// end the default account's session with her
+	events := []struct {
+		account string
+		typ     string
+		player  string
+		offset  time.Duration
+	}{
+		{"", event.TypeWorldJoin, "", 0},
+		{"alt", event.TypeWorldJoin, "", 0},
+		{"", event.TypePlayerJoin, "Alice", time.Minute},
+		{"alt", event.TypePlayerJoin, "Alice", time.Minute},
+		{"alt", event.TypeWorldJoin, "", 11 * time.Minute},
+		{"", event.TypePlayerLeft, "Alice", 21 * time.Minute},
+	}
+	for i, e := range events {
+		ev := &event.Event{
+			Ts:         base.Add(e.offset),
+			Type:       e.typ,
+			DedupeKey:  fmt.Sprintf("acct-%d", i),
+			IngestedAt: base,
+		}
+		if e.account != "" {
+			ev.Account = event.StringPtr(e.account)
+		}
+		if e.player != "" {
+			ev.PlayerName = event.StringPtr(e.player)
+		}
+		if _, _, err := st.InsertEvent(ctx, ev); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	alt := "alt"
+	res, err := st.QueryEvents(ctx, QueryFilter{Account: &alt})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(res.Items) != 3 {
+		t.Fatalf("alt events = %d, want 3", len(res.Items))
+	}
+	for _, e := range res.Items {
+		if e.Account == nil || *e.Account != "alt" {
+			t.Errorf("event %d account = %v, want alt", e.ID, e.Account)
+		}
+	}
+
+	stats, err := st.GetBasicStats(ctx, base, base.Add(time.Hour), "alt")
+	if err != nil {
+		t.Fatalf("GetBasicStats: %v", err)
+	}
+	if stats.JoinCount != 1 || stats.LeaveCount != 0 || stats.WorldChangeCount != 2 {
+		t.Errorf("alt stats = %+v", stats)
+	}
+
+	// The leave is paired with the default account's join only
+	res, err = st.QueryEvents(ctx, QueryFilter{Type: event.StringPtr(event.TypePlayerLeft)})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(res.Items) != 1 || res.Items[0].DurationSec == nil || *res.Items[0].DurationSec != 20*60 {
+		t.Errorf("leave = %+v, want a 20 minute duration", res.Items)
+	}
+
+	got, err := st.GetCopresence(ctx, base, base.Add(30*time.Minute), "", 0)
+	if err != nil {
+		t.Fatalf("GetCopresence: %v", err)
+	}
+	if len(got) != 1 || got[0].Minutes != 30 || got[0].Sessions != 2 {
+		t.Errorf("all accounts copresence = %+v, want 20m + 10m over 2 sessions", got)
+	}
+	got, err = st.GetCopresence(ctx, base, base.Add(30*time.Minute), "alt", 0)
+	if err != nil {
+		t.Fatalf("GetCopresence: %v", err)
+	}
+	if len(got) != 1 || got[0].Minutes != 10 {
+		t.Errorf("alt copresence = %+v, want 10m", got)
+	}
+}
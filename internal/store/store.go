@@ -6,6 +6,9 @@ import (
 	"database/sql"
 	"fmt"
 	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -17,6 +20,15 @@ const TimeFormat = "2006-01-02T15:04:05.000000000Z"
 // Store wraps a SQLite database connection.
 type Store struct {
 	db *sql.DB
+
+	insertsSinceAnalyze atomic.Int64 // events inserted since the last Analyze
+	queryLog            queryLog
+	rollupAfter         time.Duration // see SetRollupAfter
+	parseFailureLimit   int           // rows kept in parse_failures
+
+	changedMu  sync.Mutex // guards changedSeq and changedAt, see EventsChangedAt
+	changedSeq int64
+	changedAt  time.Time
 }
 
 // Open opens a SQLite database with WAL mode and busy_timeout.
@@ -48,6 +60,9 @@ func Open(path string) (*Store, error) {
 	db.SetMaxOpenConns(4)
 
 	store := &Store{db: db}
+	store.queryLog.slowAbove = DefaultSlowQueryThreshold
+	store.rollupAfter = DefaultRollupAfter
+	store.parseFailureLimit = DefaultParseFailureLimit
 
 	// Run migrations
 	if err := store.migrate(context.Background()); err != nil {
@@ -2,10 +2,12 @@ package store
 
 import (
 	"context"
+	"database/sql"
 	"encoding/base64"
 	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -476,6 +478,192 @@ func TestQueryEvents_FilterByType(t *testing.T) {
 	}
 }
 
+func TestQueryEvents_FilterByInstance(t *testing.T) {
+	store := openTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	events := []*event.Event{
+		{Ts: now, Type: event.TypeWorldJoin, InstanceType: event.StringPtr("public"), Region: event.StringPtr("jp"), DedupeKey: "key-1", IngestedAt: now},
+		{Ts: now, Type: event.TypeWorldJoin, InstanceType: event.StringPtr("friends"), Region: event.StringPtr("jp"), DedupeKey: "key-2", IngestedAt: now},
+		{Ts: now, Type: event.TypeWorldJoin, InstanceType: event.StringPtr("public"), Region: event.StringPtr("us"), DedupeKey: "key-3", IngestedAt: now},
+		{Ts: now, Type: event.TypeWorldJoin, InstanceType: event.StringPtr("group"), GroupID: event.StringPtr("grp_a"), DedupeKey: "key-4", IngestedAt: now},
+	}
+	for _, e := range events {
+		if _, _, err := store.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	public := "public"
+	result, err := store.QueryEvents(ctx, QueryFilter{InstanceType: &public})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(result.Items) != 2 {
+		t.Errorf("got %d public items, want 2", len(result.Items))
+	}
+
+	jp := "jp"
+	result, err = store.QueryEvents(ctx, QueryFilter{InstanceType: &public, Region: &jp})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(result.Items) != 1 || *result.Items[0].Region != "jp" {
+		t.Errorf("got %d items, want 1 public jp item", len(result.Items))
+	}
+
+	group := "grp_a"
+	result, err = store.QueryEvents(ctx, QueryFilter{GroupID: &group})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(result.Items) != 1 || *result.Items[0].GroupID != "grp_a" {
+		t.Errorf("got %d items, want 1 grp_a item", len(result.Items))
+	}
+}
+
+func TestDeleteEvents(t *testing.T) {
+	store := openTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	for i := range 7 {
+		typ := event.TypePlayerJoin
+		if i%3 == 2 {
+			typ = event.TypeWorldJoin
+		}
+		e := &event.Event{Ts: base.Add(time.Duration(i) * time.Minute), Type: typ, DedupeKey: "key-" + strconv.Itoa(i), IngestedAt: base}
+		if _, _, err := store.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	changes, err := store.EventChanges(ctx, "", 0)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+
+	joinType := event.TypePlayerJoin
+	since := base.Add(time.Minute)
+	filter := QueryFilter{Type: &joinType, Since: &since, Limit: 1}
+	n, err := store.CountMatchingEvents(ctx, filter)
+	if err != nil || n != 4 {
+		t.Fatalf("CountMatchingEvents = %d, %v; want 4", n, err)
+	}
+
+	// Several batches, the last one short
+	n, err = store.DeleteEvents(ctx, filter, 3, "")
+	if err != nil || n != 4 {
+		t.Fatalf("DeleteEvents = %d, %v; want 4", n, err)
+	}
+	if total, _ := store.CountEvents(ctx); total != 3 {
+		t.Errorf("%d events left, want 3", total)
+	}
+	if n, _ := store.CountMatchingEvents(ctx, filter); n != 0 {
+		t.Errorf("%d matching events left, want 0", n)
+	}
+
+	tombstones, err := store.EventChanges(ctx, changes.NextCursor, 0)
+	if err != nil {
+		t.Fatalf("EventChanges: %v", err)
+	}
+	if len(tombstones.Items) != 4 || tombstones.Items[0].Op != ChangeDelete {
+		t.Errorf("changes after delete = %+v, want 4 tombstones", tombstones.Items)
+	}
+}
+
+func TestOpen_MigratesInstanceColumns(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "old.sqlite")
+
+	// Create a database with the schema from before instance columns existed
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY, ts TEXT NOT NULL, type TEXT NOT NULL,
+		player_name TEXT, player_id TEXT, world_id TEXT, world_name TEXT,
+		instance_id TEXT, meta_json TEXT, dedupe_key TEXT NOT NULL,
+		ingested_at TEXT NOT NULL, schema_version INTEGER NOT NULL,
+		UNIQUE(dedupe_key)
+	);
+	INSERT INTO events (ts, type, instance_id, dedupe_key, ingested_at, schema_version)
+	VALUES ('2024-01-01T00:00:00.000000000Z', 'world_join', '1~group(grp_a)~region(eu)', 'k', '2024-01-01T00:00:00.000000000Z', 1);
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+
+	result, err := store.QueryEvents(context.Background(), QueryFilter{})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(result.Items) != 1 {
+		t.Fatalf("got %d items, want 1", len(result.Items))
+	}
+	e := result.Items[0]
+	if e.InstanceType == nil || *e.InstanceType != "group" {
+		t.Errorf("InstanceType = %v, want group", e.InstanceType)
+	}
+	if e.GroupID == nil || *e.GroupID != "grp_a" {
+		t.Errorf("GroupID = %v, want grp_a", e.GroupID)
+	}
+	if e.Region == nil || *e.Region != "eu" {
+		t.Errorf("Region = %v, want eu", e.Region)
+	}
+}
+
+func TestOpen_BackfillsNormalizedNames(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "old.sqlite")
+
+	old, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	_, err = old.Exec(`
+	CREATE TABLE events (
+		id INTEGER PRIMARY KEY, ts TEXT NOT NULL, type TEXT NOT NULL,
+		player_name TEXT, player_id TEXT, world_id TEXT, world_name TEXT,
+		instance_id TEXT, meta_json TEXT, dedupe_key TEXT NOT NULL,
+		ingested_at TEXT NOT NULL, schema_version INTEGER NOT NULL,
+		UNIQUE(dedupe_key)
+	);
+	INSERT INTO events (ts, type, player_name, dedupe_key, ingested_at, schema_version)
+	VALUES ('2024-01-01T00:00:00.000000000Z', 'player_join', 'Ｍｉｋｕ', 'k', '2024-01-01T00:00:00.000000000Z', 1);
+	`)
+	old.Close()
+	if err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+
+	result, err := store.QueryEvents(context.Background(), QueryFilter{Player: event.StringPtr("miku")})
+	if err != nil {
+		t.Fatalf("QueryEvents: %v", err)
+	}
+	if len(result.Items) != 1 {
+		t.Errorf("player miku: got %d items, want 1", len(result.Items))
+	}
+}
+
 func TestDecodeCursor_Invalid(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -541,6 +729,62 @@ func TestCursor_RoundTrip(t *testing.T) {
 	}
 }
 
+// FuzzDecodeCursor checks that any cursor a client sends either fails with
+// ErrInvalidCursor or decodes to a position that encodes back to itself.
+func FuzzDecodeCursor(f *testing.F) {
+	f.Add(encodeCursor(time.Date(2024, 6, 15, 10, 30, 45, 123456789, time.UTC), 42))
+	f.Add(base64.StdEncoding.EncodeToString([]byte("2024-01-01T12:00:00.000000000Z|123")))
+	f.Add(base64.RawURLEncoding.EncodeToString([]byte("2024-01-01T12:00:00+09:00|-1")))
+	f.Add(base64.RawURLEncoding.EncodeToString([]byte("invalid|123")))
+	f.Add(base64.RawURLEncoding.EncodeToString([]byte("||")))
+	f.Add("not-valid-base64!!!")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, cur string) {
+		ts, id, err := decodeCursor(cur)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("decodeCursor(%q) error = %v, want ErrInvalidCursor", cur, err)
+			}
+			return
+		}
+		ts2, id2, err := decodeCursor(encodeCursor(ts, id))
+		if err != nil {
+			t.Fatalf("re-encoded cursor of %q: %v", cur, err)
+		}
+		if !ts2.Equal(ts) || id2 != id {
+			t.Errorf("round trip of %q = (%v, %d), want (%v, %d)", cur, ts2, id2, ts, id)
+		}
+	})
+}
+
+// FuzzDecodeChangeCursor checks that a change cursor either fails with
+// ErrInvalidCursor or decodes to a sequence number that is not negative.
+func FuzzDecodeChangeCursor(f *testing.F) {
+	f.Add(EncodeChangeCursor(0))
+	f.Add(EncodeChangeCursor(1234))
+	f.Add(base64.RawURLEncoding.EncodeToString([]byte("seq|-5")))
+	f.Add(base64.RawURLEncoding.EncodeToString([]byte("seq|99999999999999999999")))
+	f.Add("%%%")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, cur string) {
+		seq, err := decodeChangeCursor(cur)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("decodeChangeCursor(%q) error = %v, want ErrInvalidCursor", cur, err)
+			}
+			return
+		}
+		if seq < 0 {
+			t.Fatalf("decodeChangeCursor(%q) = %d", cur, seq)
+		}
+		if got, err := decodeChangeCursor(EncodeChangeCursor(seq)); err != nil || got != seq {
+			t.Errorf("round trip of %q = %d, %v; want %d", cur, got, err, seq)
+		}
+	})
+}
+
 func openTestStore(t *testing.T) *Store {
 	t.Helper()
 	dir := t.TempDir()
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of rows in the trash.
+const (
+	TrashKindEvent = "event"
+	TrashKindNote  = "note"
+)
+
+// trashEventColumns are the events columns kept in the trash, so that a
+// restored event is the deleted row.
+var trashEventColumns = []string{
+	"id", "ts", "type", "player_name", "player_id", "normalized_name", "world_id", "world_name",
+	"instance_id", "instance_type", "region", "group_id", "duration_sec", "account", "meta_json",
+	"dedupe_key", "ingested_at", "schema_version",
+}
+
+// trashNoteColumns are the notes columns kept in the trash.
+var trashNoteColumns = []string{"id", "event_id", "text", "created_at"}
+
+// TrashBatch is the rows one destructive operation moved to the trash.
+type TrashBatch struct {
+	ID        string    `json:"batch_id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Events    int       `json:"events"`
+	Notes     int       `json:"notes"`
+}
+
+// RestoreResult reports what RestoreTrash put back.
+type RestoreResult struct {
+	Events        int `json:"events"`
+	Notes         int `json:"notes"`
+	SkippedEvents int `json:"skipped_events"` // ingested again since they were deleted
+}
+
+func (s *Store) createTrashTable(ctx context.Context) error {
+	const schema = `
+	CREATE TABLE IF NOT EXISTS trash (
+		id         INTEGER PRIMARY KEY,
+		batch_id   TEXT NOT NULL,
+		kind       TEXT NOT NULL,
+		row_id     INTEGER NOT NULL,
+		row_json   TEXT NOT NULL,
+		deleted_at TEXT NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_trash_batch ON trash(batch_id, kind, row_id);
+	CREATE INDEX IF NOT EXISTS idx_trash_deleted_at ON trash(deleted_at);
+	`
+
+	if _, err := s.db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("create trash table: %w", err)
+	}
+	return nil
+}
+
+// jsonObject returns a json_object() SQL expression of columns.
+func jsonObject(columns []string) string {
+	args := make([]string, 0, 2*len(columns))
+	for _, c := range columns {
+		args = append(args, "'"+c+"'", c)
+	}
+	return "json_object(" + strings.Join(args, ", ") + ")"
+}
+
+// jsonColumns returns a json_extract() SQL expression of each of columns
+// from the row_json column.
+func jsonColumns(columns []string) string {
+	exprs := make([]string, len(columns))
+	for i, c := range columns {
+		exprs[i] = "json_extract(row_json, '$." + c + "')"
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// trashEvents moves up to limit events matching cond, and their notes, to
+// trash batch batchID. Returns how many events it moved.
+func (s *Store) trashEvents(ctx context.Context, batchID, cond string, args []any, limit int) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin trash: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(TimeFormat)
+	trashed := `SELECT row_id FROM trash WHERE batch_id = ? AND kind = '` + TrashKindEvent + `'`
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO trash (batch_id, kind, row_id, row_json, deleted_at)
+		SELECT ?, '`+TrashKindEvent+`', id, `+jsonObject(trashEventColumns)+`, ?
+		FROM events WHERE 1=1`+cond+` LIMIT ?
+	`, append(append([]any{batchID, now}, args...), limit)...)
+	if err != nil {
+		return 0, fmt.Errorf("trash events: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+	// Earlier batches' events are gone already, along with their notes
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO trash (batch_id, kind, row_id, row_json, deleted_at)
+		SELECT ?, '`+TrashKindNote+`', id, `+jsonObject(trashNoteColumns)+`, ?
+		FROM notes WHERE event_id IN (`+trashed+`)
+	`, batchID, now, batchID); err != nil {
+		return 0, fmt.Errorf("trash notes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id IN (`+trashed+`)`, batchID); err != nil {
+		return 0, fmt.Errorf("delete events: %w", err)
+	}
+	return n, tx.Commit()
+}
+
+// ListTrash returns the batches in the trash, newest first.
+func (s *Store) ListTrash(ctx context.Context) ([]TrashBatch, error) {
+	rows, err := s.query(ctx, `
+		SELECT batch_id, MIN(deleted_at),
+			SUM(kind = '`+TrashKindEvent+`'), SUM(kind = '`+TrashKindNote+`')
+		FROM trash
+		GROUP BY batch_id
+		ORDER BY MIN(deleted_at) DESC, batch_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []TrashBatch{}
+	for rows.Next() {
+		var (
+			b         TrashBatch
+			deletedAt string
+		)
+		if err := rows.Scan(&b.ID, &deletedAt, &b.Events, &b.Notes); err != nil {
+			return nil, fmt.Errorf("scan trash batch: %w", err)
+		}
+		if b.DeletedAt, err = time.Parse(TimeFormat, deletedAt); err != nil {
+			return nil, fmt.Errorf("parse deleted_at %q: %w", deletedAt, err)
+		}
+		batches = append(batches, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return batches, nil
+}
+
+// RestoreTrash puts the rows of trash batch batchID back and empties it.
+// Rows get their old IDs unless those were taken since; an event whose
+// dedupe key was ingested again is skipped, and its notes go to the
+// event that has it. Returns ErrNotFound if the batch is not in the
+// trash.
+func (s *Store) RestoreTrash(ctx context.Context, batchID string) (RestoreResult, error) {
+	var result RestoreResult
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("begin restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM trash WHERE batch_id = ?`, batchID).Scan(&n); err != nil {
+		return result, fmt.Errorf("check trash batch: %w", err)
+	}
+	if n == 0 {
+		return result, ErrNotFound
+	}
+
+	// Restore each event, keeping its ID if free. Events ingested again
+	// since the deletion stay as they are. Row by row, as an event given a
+	// new ID may take the old ID of a later one.
+	eventCols := trashEventColumns[1:]
+	events, err := restoreTrashRows(ctx, tx, batchID, TrashKindEvent, `
+		INSERT INTO events (id, `+strings.Join(eventCols, ", ")+`)
+		SELECT CASE WHEN EXISTS (SELECT 1 FROM events e WHERE e.id = t.row_id) THEN NULL ELSE t.row_id END,
+			`+jsonColumns(eventCols)+`
+		FROM trash t WHERE t.id = ?
+		ON CONFLICT(dedupe_key) DO NOTHING
+	`)
+	if err != nil {
+		return result, fmt.Errorf("restore events: %w", err)
+	}
+	result.Events = events
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM trash WHERE batch_id = ? AND kind = '`+TrashKindEvent+`'
+	`, batchID).Scan(&n); err != nil {
+		return result, fmt.Errorf("count trashed events: %w", err)
+	}
+	result.SkippedEvents = n - result.Events
+
+	// Notes follow their event, found by dedupe key as its ID may have
+	// changed
+	notes, err := restoreTrashRows(ctx, tx, batchID, TrashKindNote, `
+		INSERT INTO notes (id, event_id, text, created_at)
+		SELECT CASE WHEN EXISTS (SELECT 1 FROM notes n WHERE n.id = t.row_id) THEN NULL ELSE t.row_id END,
+			e.id, json_extract(t.row_json, '$.text'), json_extract(t.row_json, '$.created_at')
+		FROM trash t
+		JOIN trash te ON te.batch_id = t.batch_id AND te.kind = '`+TrashKindEvent+`'
+			AND te.row_id = json_extract(t.row_json, '$.event_id')
+		JOIN events e ON e.dedupe_key = json_extract(te.row_json, '$.dedupe_key')
+		WHERE t.id = ?
+	`)
+	if err != nil {
+		return result, fmt.Errorf("restore notes: %w", err)
+	}
+	result.Notes = notes
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trash WHERE batch_id = ?`, batchID); err != nil {
+		return result, fmt.Errorf("empty trash batch: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("commit restore: %w", err)
+	}
+	if result.Events > 0 {
+		// Restored events may change the current instances
+		if err := s.RebuildOnline(ctx); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// restoreTrashRows runs insert, which restores the trash row with the ID
+// given as its argument, for each row of kind in batch batchID, oldest
+// row ID first. Returns how many rows insert restored.
+func restoreTrashRows(ctx context.Context, tx *sql.Tx, batchID, kind, insert string) (int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM trash WHERE batch_id = ? AND kind = ? ORDER BY row_id
+	`, batchID, kind)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	restored := 0
+	for _, id := range ids {
+		result, err := stmt.ExecContext(ctx, id)
+		if err != nil {
+			return restored, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return restored, err
+		}
+		restored += int(n)
+	}
+	return restored, nil
+}
+
+// PurgeTrash permanently deletes the rows moved to the trash before
+// before. Returns how many rows it deleted.
+func (s *Store) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.exec(ctx, `DELETE FROM trash WHERE deleted_at < ?`, before.UTC().Format(TimeFormat))
+	if err != nil {
+		return 0, fmt.Errorf("purge trash: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestTrash_DeleteAndRestore(t *testing.T) {
+	store := openTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	insert := func(i int, typ string) *event.Event {
+		t.Helper()
+		e := &event.Event{Ts: base.Add(time.Duration(i) * time.Minute), Type: typ, DedupeKey: "key-" + strconv.Itoa(i), IngestedAt: base}
+		if _, _, err := store.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		return e
+	}
+	insert(0, event.TypeWorldJoin)
+	first := insert(1, event.TypePlayerJoin)
+	insert(2, event.TypePlayerJoin)
+	last := insert(3, event.TypePlayerJoin)
+	if _, err := store.AddNote(ctx, first.ID, "first"); err != nil {
+		t.Fatalf("AddNote: %v", err)
+	}
+	if _, err := store.AddNote(ctx, last.ID, "last"); err != nil {
+		t.Fatalf("AddNote: %v", err)
+	}
+
+	joinType := event.TypePlayerJoin
+	n, err := store.DeleteEvents(ctx, QueryFilter{Type: &joinType}, 2, "b1")
+	if err != nil || n != 3 {
+		t.Fatalf("DeleteEvents = %d, %v; want 3", n, err)
+	}
+	batches, err := store.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if len(batches) != 1 || batches[0].ID != "b1" || batches[0].Events != 3 || batches[0].Notes != 2 {
+		t.Fatalf("trash = %+v, want b1 with 3 events and 2 notes", batches)
+	}
+
+	// New events take the freed IDs, and the first deleted event is
+	// ingested again
+	reused := insert(4, event.TypePlayerLeft)
+	if reused.ID != first.ID {
+		t.Fatalf("new event got ID %d, want the freed %d", reused.ID, first.ID)
+	}
+	again := insert(1, event.TypePlayerJoin)
+
+	res, err := store.RestoreTrash(ctx, "b1")
+	if err != nil {
+		t.Fatalf("RestoreTrash: %v", err)
+	}
+	if res.Events != 2 || res.SkippedEvents != 1 || res.Notes != 2 {
+		t.Errorf("RestoreTrash = %+v, want 2 events, 1 skipped and 2 notes", res)
+	}
+	if total, _ := store.CountEvents(ctx); total != 5 {
+		t.Errorf("%d events after restore, want 5", total)
+	}
+	notes, err := store.ListNotes(ctx, NoteFilter{})
+	if err != nil {
+		t.Fatalf("ListNotes: %v", err)
+	}
+	for _, note := range notes {
+		e, err := store.GetEvent(ctx, note.EventID)
+		if err != nil {
+			t.Fatalf("note %q on missing event %d: %v", note.Text, note.EventID, err)
+		}
+		want := map[string]string{"first": "key-1", "last": "key-3"}[note.Text]
+		if e.DedupeKey != want {
+			t.Errorf("note %q on %s, want %s", note.Text, e.DedupeKey, want)
+		}
+		if note.Text == "first" && note.EventID != again.ID {
+			t.Errorf("note on re-ingested event: event %d, want %d", note.EventID, again.ID)
+		}
+	}
+
+	if _, err := store.RestoreTrash(ctx, "b1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("restore twice: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTrash_Purge(t *testing.T) {
+	store := openTestStore(t)
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	e := &event.Event{Ts: now, Type: event.TypePlayerJoin, DedupeKey: "key-1", IngestedAt: now}
+	if _, _, err := store.InsertEvent(ctx, e); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := store.DeleteEvents(ctx, QueryFilter{}, 0, "b1"); err != nil {
+		t.Fatalf("DeleteEvents: %v", err)
+	}
+
+	if n, err := store.PurgeTrash(ctx, now.Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("PurgeTrash before the deletion = %d, %v; want 0", n, err)
+	}
+	if n, err := store.PurgeTrash(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Errorf("PurgeTrash after the deletion = %d, %v; want 1", n, err)
+	}
+	if _, err := store.RestoreTrash(ctx, "b1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("restore purged batch: err = %v, want ErrNotFound", err)
+	}
+}
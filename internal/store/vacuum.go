@@ -3,43 +3,56 @@ package store
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
 	"time"
 )
 
-// VacuumInterval is the minimum interval between VACUUM operations.
+// VacuumInterval is the default minimum interval between VACUUM operations.
 const VacuumInterval = 30 * 24 * time.Hour // 30 days
 
 const metadataKeyLastVacuum = "last_vacuum_at"
 
-// VacuumIfNeeded runs VACUUM if the last vacuum was more than VacuumInterval ago.
+// VacuumIfNeeded runs VACUUM if the last vacuum was more than interval ago.
 // Returns true if VACUUM was performed, false if skipped.
-func (s *Store) VacuumIfNeeded(ctx context.Context) (bool, error) {
+func (s *Store) VacuumIfNeeded(ctx context.Context, interval time.Duration) (bool, error) {
 	lastVacuum, err := s.getLastVacuumTime(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	if time.Since(lastVacuum) < VacuumInterval {
+	if time.Since(lastVacuum) < interval {
 		return false, nil
 	}
 
-	log.Println("Running VACUUM (last run:", lastVacuum.Format(time.RFC3339), ")")
+	slog.Info("running VACUUM", "last_run", lastVacuum.Format(time.RFC3339))
+	if err := s.Vacuum(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Vacuum runs VACUUM now and records the time. Writers wait for it to
+// finish.
+func (s *Store) Vacuum(ctx context.Context) error {
 	start := time.Now()
 
 	if _, err := s.db.ExecContext(ctx, "VACUUM"); err != nil {
-		return false, err
+		return err
 	}
 
 	elapsed := time.Since(start)
-	log.Printf("VACUUM completed in %v", elapsed)
+	slog.Info("VACUUM completed", "elapsed", elapsed.Round(time.Millisecond))
 
 	if err := s.setLastVacuumTime(ctx, time.Now()); err != nil {
 		// Log but don't fail - VACUUM succeeded
-		log.Printf("Warning: failed to update last_vacuum_at: %v", err)
+		slog.Warn("failed to update last_vacuum_at", "error", err)
 	}
+	return nil
+}
 
-	return true, nil
+// LastVacuum returns when VACUUM last ran, or the zero time if never.
+func (s *Store) LastVacuum(ctx context.Context) (time.Time, error) {
+	return s.getLastVacuumTime(ctx)
 }
 
 func (s *Store) getLastVacuumTime(ctx context.Context) (time.Time, error) {
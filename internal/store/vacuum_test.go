@@ -13,7 +13,7 @@ func TestVacuumIfNeeded_FirstRun(t *testing.T) {
 	ctx := context.Background()
 
 	// First run should trigger VACUUM (no last_vacuum_at record)
-	vacuumed, err := st.VacuumIfNeeded(ctx)
+	vacuumed, err := st.VacuumIfNeeded(ctx, VacuumInterval)
 	if err != nil {
 		t.Fatalf("VacuumIfNeeded failed: %v", err)
 	}
@@ -22,7 +22,7 @@ func TestVacuumIfNeeded_FirstRun(t *testing.T) {
 	}
 
 	// Second run should skip (just ran)
-	vacuumed, err = st.VacuumIfNeeded(ctx)
+	vacuumed, err = st.VacuumIfNeeded(ctx, VacuumInterval)
 	if err != nil {
 		t.Fatalf("VacuumIfNeeded failed: %v", err)
 	}
@@ -44,7 +44,7 @@ func TestVacuumIfNeeded_OldTimestamp(t *testing.T) {
 	}
 
 	// Should trigger VACUUM
-	vacuumed, err := st.VacuumIfNeeded(ctx)
+	vacuumed, err := st.VacuumIfNeeded(ctx, VacuumInterval)
 	if err != nil {
 		t.Fatalf("VacuumIfNeeded failed: %v", err)
 	}
@@ -66,7 +66,7 @@ func TestVacuumIfNeeded_RecentTimestamp(t *testing.T) {
 	}
 
 	// Should skip VACUUM
-	vacuumed, err := st.VacuumIfNeeded(ctx)
+	vacuumed, err := st.VacuumIfNeeded(ctx, VacuumInterval)
 	if err != nil {
 		t.Fatalf("VacuumIfNeeded failed: %v", err)
 	}
@@ -74,3 +74,23 @@ func TestVacuumIfNeeded_RecentTimestamp(t *testing.T) {
 		t.Error("expected VACUUM to be skipped when last vacuum was 1 day ago")
 	}
 }
+
+func TestVacuum_RecordsTime(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	last, err := st.LastVacuum(ctx)
+	if err != nil || !last.IsZero() {
+		t.Fatalf("LastVacuum = %v, %v; want zero time", last, err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	if err := st.Vacuum(ctx); err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+	last, err = st.LastVacuum(ctx)
+	if err != nil || last.Before(before) {
+		t.Errorf("LastVacuum = %v, %v; want after %v", last, err, before)
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ViewFilter is the saved form of an event filter. Empty fields match
+// everything.
+type ViewFilter struct {
+	Type         string     `json:"type,omitempty"`
+	Player       string     `json:"player,omitempty"` // player ID or name (see event.NormalizeName)
+	World        string     `json:"world,omitempty"`  // world ID or exact name
+	InstanceType string     `json:"instance_type,omitempty"`
+	Region       string     `json:"region,omitempty"`
+	GroupID      string     `json:"group_id,omitempty"`
+	Account      string     `json:"account,omitempty"`
+	Since        *time.Time `json:"since,omitempty"`
+	Until        *time.Time `json:"until,omitempty"`
+	Within       string     `json:"within,omitempty"` // window ending now, e.g. "24h"
+}
+
+// View is a named, saved event filter.
+type View struct {
+	Name      string     `json:"name"`
+	Filter    ViewFilter `json:"filter"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// SaveView stores a view, replacing the filter of an existing view with the
+// same name.
+func (s *Store) SaveView(ctx context.Context, name string, f ViewFilter) (*View, error) {
+	filterJSON, err := json.Marshal(f)
+	if err != nil {
+		return nil, fmt.Errorf("marshal view filter: %w", err)
+	}
+
+	now := time.Now().UTC().Format(TimeFormat)
+	var createdAt, updatedAt string
+	err = s.queryRow(ctx, `
+		INSERT INTO views (name, filter_json, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			filter_json = excluded.filter_json,
+			updated_at = excluded.updated_at
+		RETURNING created_at, updated_at
+	`, name, string(filterJSON), now, now).Scan(&createdAt, &updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("save view: %w", err)
+	}
+	return parseView(name, string(filterJSON), createdAt, updatedAt)
+}
+
+// GetView returns the view with the given name, or ErrNotFound.
+func (s *Store) GetView(ctx context.Context, name string) (*View, error) {
+	var filterJSON, createdAt, updatedAt string
+	err := s.queryRow(ctx, `
+		SELECT filter_json, created_at, updated_at FROM views WHERE name = ?
+	`, name).Scan(&filterJSON, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get view: %w", err)
+	}
+	return parseView(name, filterJSON, createdAt, updatedAt)
+}
+
+// ListViews returns all views ordered by name.
+func (s *Store) ListViews(ctx context.Context) ([]View, error) {
+	rows, err := s.query(ctx, `
+		SELECT name, filter_json, created_at, updated_at FROM views ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("query views: %w", err)
+	}
+	defer rows.Close()
+
+	views := []View{}
+	for rows.Next() {
+		var name, filterJSON, createdAt, updatedAt string
+		if err := rows.Scan(&name, &filterJSON, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scan view: %w", err)
+		}
+		v, err := parseView(name, filterJSON, createdAt, updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, *v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return views, nil
+}
+
+// DeleteView removes a view. Returns ErrNotFound if it does not exist.
+func (s *Store) DeleteView(ctx context.Context, name string) error {
+	result, err := s.exec(ctx, `DELETE FROM views WHERE name = ?`, name)
+	if err != nil {
+		return fmt.Errorf("delete view: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func parseView(name, filterJSON, createdAt, updatedAt string) (*View, error) {
+	v := View{Name: name}
+	if err := json.Unmarshal([]byte(filterJSON), &v.Filter); err != nil {
+		return nil, fmt.Errorf("decode view %q filter: %w", name, err)
+	}
+	var err error
+	if v.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, fmt.Errorf("parse created_at %q: %w", createdAt, err)
+	}
+	if v.UpdatedAt, err = time.Parse(TimeFormat, updatedAt); err != nil {
+		return nil, fmt.Errorf("parse updated_at %q: %w", updatedAt, err)
+	}
+	return &v, nil
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestViews_SaveGetListDelete(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	v, err := st.SaveView(ctx, "alice", ViewFilter{Type: event.TypePlayerJoin, Player: "Alice", Within: "24h"})
+	if err != nil {
+		t.Fatalf("SaveView: %v", err)
+	}
+	if v.Filter.Player != "Alice" || v.CreatedAt.IsZero() {
+		t.Errorf("SaveView = %+v", v)
+	}
+
+	// Saving under the same name replaces the filter
+	if _, err := st.SaveView(ctx, "alice", ViewFilter{Player: "usr_alice"}); err != nil {
+		t.Fatalf("SaveView again: %v", err)
+	}
+	got, err := st.GetView(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetView: %v", err)
+	}
+	if got.Filter != (ViewFilter{Player: "usr_alice"}) || !got.CreatedAt.Equal(v.CreatedAt) {
+		t.Errorf("GetView = %+v", got)
+	}
+
+	if _, err := st.SaveView(ctx, "worlds", ViewFilter{Type: event.TypeWorldJoin}); err != nil {
+		t.Fatalf("SaveView: %v", err)
+	}
+	list, err := st.ListViews(ctx)
+	if err != nil {
+		t.Fatalf("ListViews: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "alice" || list[1].Name != "worlds" {
+		t.Errorf("ListViews = %+v", list)
+	}
+
+	if err := st.DeleteView(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteView: %v", err)
+	}
+	if err := st.DeleteView(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteView: err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.GetView(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetView after delete: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestQueryFilter_PlayerAndMatches(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	events := []*event.Event{
+		{Ts: base, Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr("usr_a"), DedupeKey: "1"},
+		{Ts: base.Add(time.Minute), Type: event.TypePlayerJoin, PlayerName: event.StringPtr("Bob"), DedupeKey: "2"},
+		{Ts: base.Add(2 * time.Minute), Type: event.TypePlayerLeft, PlayerName: event.StringPtr("Alice"), PlayerID: event.StringPtr("usr_a"), DedupeKey: "3"},
+	}
+	for _, e := range events {
+		e.IngestedAt = base
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("InsertEvent: %v", err)
+		}
+	}
+
+	joinType := event.TypePlayerJoin
+	since := base.Add(30 * time.Second)
+	tests := []struct {
+		name   string
+		filter QueryFilter
+		want   int
+	}{
+		{"player by name", QueryFilter{Player: event.StringPtr("Alice")}, 2},
+		{"player by id", QueryFilter{Player: event.StringPtr("usr_a")}, 2},
+		{"player by normalized name", QueryFilter{Player: event.StringPtr("ａｌｉｃｅ")}, 2},
+		{"player and type", QueryFilter{Player: event.StringPtr("Alice"), Type: &joinType}, 1},
+		{"since", QueryFilter{Since: &since}, 2},
+		{"no match", QueryFilter{Player: event.StringPtr("Carol")}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := st.QueryEvents(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("QueryEvents: %v", err)
+			}
+			if len(res.Items) != tt.want {
+				t.Errorf("QueryEvents returned %d events, want %d", len(res.Items), tt.want)
+			}
+
+			// Matches agrees with the query
+			matched := 0
+			for _, e := range events {
+				if tt.filter.Matches(e) {
+					matched++
+				}
+			}
+			if matched != tt.want {
+				t.Errorf("Matches accepted %d events, want %d", matched, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// vrTimeGap is the longest gap between the last event of an instance visit
+// and the next world join that TimeInVR counts as moving on to another
+// instance. A longer gap means VRChat was closed in between.
+const vrTimeGap = time.Hour
+
+// vrTimeLookback is how far before the range TimeInVR looks for a visit in
+// progress.
+const vrTimeLookback = 24 * time.Hour
+
+// TimeInVR returns how long the user was in VRChat in the time range. Each
+// instance visit runs from its world_join to the next one, or only to its
+// last event if the next world_join came more than vrTimeGap later. Visits
+// are cut at the ends of the range. An empty account covers all accounts,
+// adding up the time of each.
+func (s *Store) TimeInVR(ctx context.Context, since, until time.Time, account string) (time.Duration, error) {
+	accountCond, accountArgs := accountClause(account)
+	args := append([]any{
+		since.Add(-vrTimeLookback).UTC().Format(TimeFormat), until.Add(vrTimeGap).UTC().Format(TimeFormat),
+		event.TypeSystem,
+	}, accountArgs...)
+	rows, err := s.query(ctx, `
+		SELECT ts, type, world_id IS NOT NULL, account FROM events
+		WHERE ts >= ? AND ts < ? AND type != ?`+accountCond+`
+		ORDER BY ts ASC, id ASC
+	`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("query events: %w", err)
+	}
+	defer rows.Close()
+
+	type visit struct{ start, last time.Time }
+	open := make(map[string]*visit) // account -> visit in progress
+	var total time.Duration
+	add := func(start, end time.Time) {
+		start, end = later(start, since), earlier(end, until)
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+
+	for rows.Next() {
+		var (
+			tsStr, typ string
+			hasWorld   bool
+			acct       sql.NullString
+		)
+		if err := rows.Scan(&tsStr, &typ, &hasWorld, &acct); err != nil {
+			return 0, fmt.Errorf("scan event: %w", err)
+		}
+		ts, err := time.Parse(TimeFormat, tsStr)
+		if err != nil {
+			return 0, fmt.Errorf("parse ts %q: %w", tsStr, err)
+		}
+
+		v := open[acct.String]
+		if typ == event.TypeWorldJoin && hasWorld {
+			if v != nil {
+				end := ts
+				if ts.Sub(v.last) > vrTimeGap {
+					end = v.last
+				}
+				add(v.start, end)
+			}
+			open[acct.String] = &visit{start: ts, last: ts}
+			continue
+		}
+		if v != nil {
+			v.last = ts
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows error: %w", err)
+	}
+	for _, v := range open {
+		add(v.start, v.last)
+	}
+	return total, nil
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestTimeInVR(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+
+	day := time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	events := []struct {
+		ts      time.Time
+		typ     string
+		world   string
+		account string
+	}{
+		{at(-1, 0), event.TypeWorldJoin, "wrld_a", ""}, // the day before
+		{at(0, 30), event.TypePlayerJoin, "", ""},
+		{at(10, 0), event.TypeWorldJoin, "wrld_b", ""}, // 30 min carried over, then VRChat closed
+		{at(10, 0), event.TypeWorldJoin, "", ""},       // the "Entering Room" line
+		{at(10, 45), event.TypePlayerJoin, "", ""},
+		{at(11, 0), event.TypeWorldJoin, "wrld_c", ""}, // 60 min in wrld_b
+		{at(11, 20), event.TypePlayerLeft, "", ""},     // 20 min, then VRChat closed
+		{at(15, 0), event.TypeSystem, "", ""},          // the app, not VRChat
+		{at(20, 0), event.TypeWorldJoin, "wrld_a", "alt"},
+		{at(20, 40), event.TypePlayerJoin, "", "alt"}, // 40 min on another account
+		{at(23, 50), event.TypeWorldJoin, "wrld_d", ""},
+		{at(24, 10), event.TypePlayerJoin, "", ""}, // 10 min before midnight, 10 after
+	}
+	for i, e := range events {
+		ev := &event.Event{Ts: e.ts, Type: e.typ, DedupeKey: fmt.Sprintf("vr-%d", i), IngestedAt: e.ts}
+		if e.world != "" {
+			ev.WorldID = event.StringPtr(e.world)
+		}
+		if e.account != "" {
+			ev.Account = event.StringPtr(e.account)
+		}
+		if e.typ == event.TypeSystem {
+			ev.MetaJSON = []byte(`{"kind":"app_started"}`)
+		}
+		if _, _, err := st.InsertEvent(ctx, ev); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	tests := []struct {
+		account string
+		want    time.Duration
+	}{
+		{"", (30 + 60 + 20 + 40 + 10) * time.Minute},
+		{"alt", 40 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := st.TimeInVR(ctx, day, day.Add(24*time.Hour), tt.account)
+		if err != nil {
+			t.Fatalf("TimeInVR(%q): %v", tt.account, err)
+		}
+		if got != tt.want {
+			t.Errorf("TimeInVR(%q) = %v, want %v", tt.account, got, tt.want)
+		}
+	}
+
+	got, err := st.TimeInVR(ctx, day.Add(24*time.Hour), day.Add(48*time.Hour), "")
+	if err != nil || got != 10*time.Minute {
+		t.Errorf("next day = %v, %v; want 10m", got, err)
+	}
+}
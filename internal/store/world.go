@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// SeenWorld is the world and instance the user was in at some moment.
+type SeenWorld struct {
+	WorldID      *string   `json:"world_id,omitempty"`
+	WorldName    *string   `json:"world_name,omitempty"`
+	InstanceID   *string   `json:"instance_id,omitempty"`
+	InstanceType *string   `json:"instance_type,omitempty"`
+	Region       *string   `json:"region,omitempty"`
+	GroupID      *string   `json:"group_id,omitempty"`
+	JoinedAt     time.Time `json:"joined_at"` // when the user joined the instance
+}
+
+// attachWorld stamps a world-scoped event (see event.IsWorldScoped) with the
+// world and instance the user was in at e.Ts. Events that already name a
+// world are left unchanged.
+func (s *Store) attachWorld(ctx context.Context, e *event.Event) error {
+	if !event.IsWorldScoped(e.Type) || e.WorldID != nil || e.WorldName != nil {
+		return nil
+	}
+	w, err := s.worldAt(ctx, deref(e.Account), e.Ts)
+	if err != nil || w == nil {
+		return err
+	}
+	e.WorldID = w.WorldID
+	e.WorldName = w.WorldName
+	e.InstanceID = w.InstanceID
+	e.InstanceType = w.InstanceType
+	e.Region = w.Region
+	e.GroupID = w.GroupID
+	return nil
+}
+
+// worldAt returns the world the account's user was in at ts, taken from the
+// preceding world_join rows, or nil if there are none. VRChat logs a world
+// join as two lines: "Joining" carries the world and instance IDs,
+// "Entering Room" the world name.
+func (s *Store) worldAt(ctx context.Context, account string, ts time.Time) (*SeenWorld, error) {
+	tsStr := ts.UTC().Format(TimeFormat)
+
+	var joinTs string
+	var worldID, instanceID, instanceType, region, groupID sql.NullString
+	err := s.queryRow(ctx, `
+		SELECT ts, world_id, instance_id, instance_type, region, group_id FROM events
+		WHERE type = ? AND world_id IS NOT NULL AND account IS ? AND ts <= ?
+		ORDER BY ts DESC, id DESC
+		LIMIT 1
+	`, event.TypeWorldJoin, nullIfEmpty(account), tsStr).Scan(&joinTs, &worldID, &instanceID, &instanceType, &region, &groupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find current world: %w", err)
+	}
+
+	var worldName sql.NullString
+	err = s.queryRow(ctx, `
+		SELECT world_name FROM events
+		WHERE type = ? AND world_name IS NOT NULL AND account IS ? AND ts >= ? AND ts <= ?
+		ORDER BY ts DESC, id DESC
+		LIMIT 1
+	`, event.TypeWorldJoin, nullIfEmpty(account), joinTs, tsStr).Scan(&worldName)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("find current world name: %w", err)
+	}
+
+	joinedAt, err := time.Parse(TimeFormat, joinTs)
+	if err != nil {
+		return nil, fmt.Errorf("parse ts %q: %w", joinTs, err)
+	}
+	return &SeenWorld{
+		WorldID:      nullStringPtr(worldID),
+		WorldName:    nullStringPtr(worldName),
+		InstanceID:   nullStringPtr(instanceID),
+		InstanceType: nullStringPtr(instanceType),
+		Region:       nullStringPtr(region),
+		GroupID:      nullStringPtr(groupID),
+		JoinedAt:     joinedAt,
+	}, nil
+}
+
+// nullStringPtr returns a pointer to the string, or nil for SQL NULL.
+func nullStringPtr(ns sql.NullString) *string {
+	if !ns.Valid {
+		return nil
+	}
+	return &ns.String
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestInsertEvent_AttachesWorldToScreenshots(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	for _, e := range []*event.Event{
+		// "Joining" line, then "Entering Room" line
+		{Ts: base, Type: event.TypeWorldJoin, WorldID: event.StringPtr("wrld_a"), InstanceID: event.StringPtr("1~region(jp)"), Region: event.StringPtr("jp"), DedupeKey: "w1", IngestedAt: base},
+		{Ts: base, Type: event.TypeWorldJoin, WorldName: event.StringPtr("World A"), DedupeKey: "w2", IngestedAt: base},
+	} {
+		if _, _, err := st.InsertEvent(ctx, e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	shot := &event.Event{Ts: base.Add(time.Minute), Type: event.TypeScreenshot, DedupeKey: "s1", IngestedAt: base}
+	if _, _, err := st.InsertEvent(ctx, shot); err != nil {
+		t.Fatalf("insert screenshot: %v", err)
+	}
+
+	got, err := st.GetEvent(ctx, shot.ID)
+	if err != nil {
+		t.Fatalf("GetEvent: %v", err)
+	}
+	if got.WorldID == nil || *got.WorldID != "wrld_a" {
+		t.Errorf("WorldID = %v, want wrld_a", got.WorldID)
+	}
+	if got.WorldName == nil || *got.WorldName != "World A" {
+		t.Errorf("WorldName = %v, want World A", got.WorldName)
+	}
+	if got.InstanceID == nil || *got.InstanceID != "1~region(jp)" {
+		t.Errorf("InstanceID = %v, want 1~region(jp)", got.InstanceID)
+	}
+
+	// Filter by world name or ID
+	for _, world := range []string{"World A", "wrld_a"} {
+		typ := event.TypeScreenshot
+		result, err := st.QueryEvents(ctx, QueryFilter{Type: &typ, World: &world})
+		if err != nil {
+			t.Fatalf("QueryEvents: %v", err)
+		}
+		if len(result.Items) != 1 {
+			t.Errorf("world=%q: got %d items, want 1", world, len(result.Items))
+		}
+	}
+
+	if _, err := st.GetEvent(ctx, 999); err != ErrNotFound {
+		t.Errorf("GetEvent(999) error = %v, want ErrNotFound", err)
+	}
+}
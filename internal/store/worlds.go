@@ -0,0 +1,233 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// Worlds directory sort orders.
+const (
+	WorldSortLastVisited = "last_visited" // most recently visited first
+	WorldSortVisits      = "visits"       // most visits first
+	WorldSortTotalTime   = "total_time"   // longest total time first
+)
+
+// WorldSummary is one world in the worlds directory.
+type WorldSummary struct {
+	WorldID      string    `json:"world_id"`
+	WorldName    string    `json:"world_name,omitempty"` // most recently logged name
+	Visits       int       `json:"visits"`
+	FirstVisited time.Time `json:"first_visited"`
+	LastVisited  time.Time `json:"last_visited"`
+	Minutes      int64     `json:"minutes"`
+}
+
+// WorldFilter selects and orders worlds for ListWorlds.
+type WorldFilter struct {
+	Search  string // case-insensitive substring of the world name or ID
+	Sort    string // WorldSortLastVisited (default), WorldSortVisits or WorldSortTotalTime
+	Account string // empty covers all accounts
+	Limit   int
+	Cursor  *string
+}
+
+// WorldPage is one page of the worlds directory.
+type WorldPage struct {
+	Items      []WorldSummary `json:"items"`
+	NextCursor *string        `json:"next_cursor,omitempty"`
+}
+
+// ListWorlds returns the distinct worlds joined, with visit counts and time
+// spent. A visit runs from a world_join carrying a world ID until the next
+// one of the same account; an account's latest visit ends at its newest
+// event. Returns ErrInvalidCursor for a cursor from another sort order.
+func (s *Store) ListWorlds(ctx context.Context, f WorldFilter) (WorldPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+	switch f.Sort {
+	case "":
+		f.Sort = WorldSortLastVisited
+	case WorldSortLastVisited, WorldSortVisits, WorldSortTotalTime:
+	default:
+		return WorldPage{}, fmt.Errorf("unknown sort %q", f.Sort)
+	}
+	var after *worldSortKey
+	if f.Cursor != nil {
+		value, key, err := decodeSortCursor(*f.Cursor, f.Sort)
+		if err != nil {
+			return WorldPage{}, err
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return WorldPage{}, fmt.Errorf("%w: invalid value", ErrInvalidCursor)
+		}
+		after = &worldSortKey{n, key}
+	}
+
+	worlds, err := s.worldVisits(ctx, f.Account)
+	if err != nil {
+		return WorldPage{}, err
+	}
+
+	search := strings.ToLower(f.Search)
+	keyed := make([]worldSortKey, 0, len(worlds))
+	byID := make(map[string]*WorldSummary, len(worlds))
+	for _, w := range worlds {
+		if search != "" && !strings.Contains(strings.ToLower(w.WorldName), search) &&
+			!strings.Contains(strings.ToLower(w.WorldID), search) {
+			continue
+		}
+		k := worldSortKey{key: w.WorldID}
+		switch f.Sort {
+		case WorldSortLastVisited:
+			k.value = w.LastVisited.UnixNano()
+		case WorldSortVisits:
+			k.value = int64(w.Visits)
+		case WorldSortTotalTime:
+			k.value = w.Minutes
+		}
+		if after != nil && compareWorldSortKeys(k, *after) <= 0 {
+			continue
+		}
+		keyed = append(keyed, k)
+		byID[w.WorldID] = w
+	}
+	slices.SortFunc(keyed, compareWorldSortKeys)
+
+	page := WorldPage{Items: []WorldSummary{}}
+	for _, k := range keyed[:min(limit, len(keyed))] {
+		page.Items = append(page.Items, *byID[k.key])
+	}
+	if len(keyed) > limit {
+		last := keyed[limit-1]
+		cur := encodeSortCursor(f.Sort, strconv.FormatInt(last.value, 10), last.key)
+		page.NextCursor = &cur
+	}
+	return page, nil
+}
+
+// worldSortKey positions a world in the directory: by value descending,
+// then by world ID.
+type worldSortKey struct {
+	value int64
+	key   string
+}
+
+func compareWorldSortKeys(a, b worldSortKey) int {
+	return cmp.Or(cmp.Compare(b.value, a.value), cmp.Compare(a.key, b.key))
+}
+
+// worldVisits replays the world_join rows to total the visits per world.
+// VRChat logs a world join as a "Joining" line with the IDs followed by an
+// "Entering Room" line with the name; the name is credited to the visit the
+// account is on.
+func (s *Store) worldVisits(ctx context.Context, account string) ([]*WorldSummary, error) {
+	accountCond, accountArgs := accountClause(account)
+
+	// The newest event of each account ends its latest visit
+	lastEvent := make(map[string]time.Time)
+	rows, err := s.query(ctx, `SELECT account, MAX(ts) FROM events WHERE 1=1`+accountCond+` GROUP BY account`, accountArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("query newest events: %w", err)
+	}
+	for rows.Next() {
+		var acct sql.NullString
+		var tsStr string
+		if err := rows.Scan(&acct, &tsStr); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan newest event: %w", err)
+		}
+		ts, err := time.Parse(TimeFormat, tsStr)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("parse ts %q: %w", tsStr, err)
+		}
+		lastEvent[acct.String] = ts
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	rows, err = s.query(ctx, `
+		SELECT ts, account, world_id, world_name FROM events
+		WHERE type = ?`+accountCond+`
+		ORDER BY ts ASC, id ASC
+	`, append([]any{event.TypeWorldJoin}, accountArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("query world joins: %w", err)
+	}
+	defer rows.Close()
+
+	type visit struct {
+		world *WorldSummary
+		start time.Time
+	}
+	var worlds []*WorldSummary
+	byID := make(map[string]*WorldSummary)
+	durations := make(map[*WorldSummary]time.Duration)
+	current := make(map[string]visit) // account -> visit in progress
+
+	end := func(v visit, at time.Time) {
+		durations[v.world] += max(at.Sub(v.start), 0)
+	}
+
+	for rows.Next() {
+		var (
+			tsStr                    string
+			acct, worldID, worldName sql.NullString
+		)
+		if err := rows.Scan(&tsStr, &acct, &worldID, &worldName); err != nil {
+			return nil, fmt.Errorf("scan world join: %w", err)
+		}
+		ts, err := time.Parse(TimeFormat, tsStr)
+		if err != nil {
+			return nil, fmt.Errorf("parse ts %q: %w", tsStr, err)
+		}
+
+		if !worldID.Valid {
+			if v, ok := current[acct.String]; ok && worldName.String != "" {
+				v.world.WorldName = worldName.String
+			}
+			continue
+		}
+		if v, ok := current[acct.String]; ok {
+			end(v, ts)
+		}
+		w := byID[worldID.String]
+		if w == nil {
+			w = &WorldSummary{WorldID: worldID.String, FirstVisited: ts}
+			byID[worldID.String] = w
+			worlds = append(worlds, w)
+		}
+		w.Visits++
+		w.LastVisited = ts
+		if worldName.String != "" {
+			w.WorldName = worldName.String
+		}
+		current[acct.String] = visit{world: w, start: ts}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	for acct, v := range current {
+		end(v, lastEvent[acct])
+	}
+	for _, w := range worlds {
+		w.Minutes = int64(durations[w] / time.Minute)
+	}
+	return worlds, nil
+}
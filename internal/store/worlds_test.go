@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+func TestListWorlds(t *testing.T) {
+	st := openTestStore(t)
+	defer st.Close()
+	ctx := context.Background()
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	n := 0
+	insert := func(offset time.Duration, typ string, e event.Event) {
+		t.Helper()
+		n++
+		e.Ts = base.Add(offset)
+		e.Type = typ
+		e.IngestedAt = base
+		e.DedupeKey = "k" + string(rune('a'+n))
+		if _, _, err := st.InsertEvent(ctx, &e); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	join := func(offset time.Duration, id, name string) {
+		t.Helper()
+		insert(offset, event.TypeWorldJoin, event.Event{WorldID: event.StringPtr(id)})
+		insert(offset+time.Second, event.TypeWorldJoin, event.Event{WorldName: event.StringPtr(name)})
+	}
+
+	join(0, "wrld_a", "Club")
+	join(10*time.Minute, "wrld_b", "Home")
+	join(30*time.Minute, "wrld_a", "Club Night")
+	join(35*time.Minute, "wrld_c", "Cafe")
+	// The latest visit ends at the newest event
+	insert(95*time.Minute, event.TypePlayerJoin, event.Event{PlayerName: event.StringPtr("Alice")})
+
+	page, err := st.ListWorlds(ctx, WorldFilter{})
+	if err != nil {
+		t.Fatalf("ListWorlds: %v", err)
+	}
+	if len(page.Items) != 3 {
+		t.Fatalf("items = %+v, want 3", page.Items)
+	}
+	want := []struct {
+		id, name string
+		visits   int
+		minutes  int64
+	}{
+		{"wrld_c", "Cafe", 1, 60},
+		{"wrld_a", "Club Night", 2, 15},
+		{"wrld_b", "Home", 1, 20},
+	}
+	for i, w := range want {
+		got := page.Items[i]
+		if got.WorldID != w.id || got.WorldName != w.name || got.Visits != w.visits || got.Minutes != w.minutes {
+			t.Errorf("items[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+	if a := page.Items[1]; !a.FirstVisited.Equal(base) || !a.LastVisited.Equal(base.Add(30*time.Minute)) {
+		t.Errorf("wrld_a visited %v .. %v", a.FirstVisited, a.LastVisited)
+	}
+
+	// Pages of one by visits
+	var got []string
+	filter := WorldFilter{Sort: WorldSortVisits, Limit: 1}
+	for range 4 {
+		page, err := st.ListWorlds(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListWorlds: %v", err)
+		}
+		for _, w := range page.Items {
+			got = append(got, w.WorldID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if len(got) != 3 || got[0] != "wrld_a" || got[1] != "wrld_b" || got[2] != "wrld_c" {
+		t.Errorf("by visits = %v, want [wrld_a wrld_b wrld_c]", got)
+	}
+
+	page, err = st.ListWorlds(ctx, WorldFilter{Search: "club", Sort: WorldSortTotalTime})
+	if err != nil {
+		t.Fatalf("ListWorlds: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].WorldID != "wrld_a" {
+		t.Errorf("search club = %+v, want wrld_a", page.Items)
+	}
+
+	cur := encodeSortCursor(WorldSortVisits, "1", "wrld_b")
+	if _, err := st.ListWorlds(ctx, WorldFilter{Cursor: &cur}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("mismatched cursor: err = %v, want ErrInvalidCursor", err)
+	}
+}
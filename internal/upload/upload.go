@@ -0,0 +1,150 @@
+// Package upload copies backup files to remote storage: an S3-compatible
+// bucket or a WebDAV share. Every upload is read back and compared with the
+// local file, so a backup is only reported as uploaded once it is known to
+// be intact.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/config"
+)
+
+// DefaultTimeout is the HTTP timeout for a single request. Backups can be
+// large, so it is generous.
+const DefaultTimeout = 10 * time.Minute
+
+// ErrVerifyFailed is returned when the uploaded copy differs from the local
+// file.
+var ErrVerifyFailed = errors.New("uploaded backup does not match local file")
+
+// Uploader uploads a local file under the given name.
+type Uploader interface {
+	Upload(ctx context.Context, name, path string) error
+}
+
+// StatusError is returned when the remote answers with an unexpected status.
+type StatusError struct {
+	Op   string
+	Code int
+	Body string
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s: HTTP %d", e.Op, e.Code)
+	}
+	return fmt.Sprintf("%s: HTTP %d: %s", e.Op, e.Code, e.Body)
+}
+
+// Option configures an Uploader.
+type Option func(*options)
+
+type options struct {
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// WithHTTPClient sets the HTTP client used for requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) { o.httpClient = c }
+}
+
+// New creates an Uploader for the remote.
+func New(remote config.BackupRemote, opts ...Option) (Uploader, error) {
+	if err := config.ValidateBackupRemote(remote); err != nil {
+		return nil, fmt.Errorf("invalid backup remote: %w", err)
+	}
+	o := options{
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	base := strings.TrimSuffix(remote.URL, "/")
+	switch remote.Type {
+	case config.BackupRemoteS3:
+		region := remote.Region
+		if region == "" {
+			region = config.DefaultBackupRemoteRegion
+		}
+		return &S3{
+			base:       base,
+			region:     region,
+			accessKey:  remote.Username,
+			secretKey:  remote.Password.Value(),
+			httpClient: o.httpClient,
+			now:        o.now,
+		}, nil
+	default:
+		return &WebDAV{
+			base:       base,
+			username:   remote.Username,
+			password:   remote.Password.Value(),
+			httpClient: o.httpClient,
+		}, nil
+	}
+}
+
+// objectURL returns the URL of name under base.
+func objectURL(base, name string) string {
+	return base + "/" + url.PathEscape(name)
+}
+
+// fileSHA256 returns the SHA-256 of the file at path and its size.
+func fileSHA256(path string) ([]byte, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("hash %s: %w", path, err)
+	}
+	return h.Sum(nil), n, nil
+}
+
+// verifyBody checks that the response body hashes to want.
+func verifyBody(body io.Reader, want []byte) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return fmt.Errorf("read back upload: %w", err)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: sha256 %s, want %s", ErrVerifyFailed, hex.EncodeToString(got), hex.EncodeToString(want))
+	}
+	return nil
+}
+
+// do sends req and returns the response if its status is one of ok.
+// Otherwise the body is drained into a *StatusError.
+func do(c *http.Client, req *http.Request, op string, ok ...int) (*http.Response, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return nil, &StatusError{Op: op, Code: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+}
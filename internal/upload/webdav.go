@@ -0,0 +1,96 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// WebDAV uploads into a WebDAV collection (e.g. Nextcloud, a NAS share).
+type WebDAV struct {
+	base       string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// Upload PUTs the file, creating the collection if the server reports it
+// missing, then GETs it back to verify it.
+func (w *WebDAV) Upload(ctx context.Context, name, path string) error {
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	target := objectURL(w.base, name)
+
+	err = w.put(ctx, target, path, size)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && (statusErr.Code == http.StatusConflict || statusErr.Code == http.StatusNotFound) {
+		// RFC 4918: 409 means an intermediate collection is missing
+		if err := w.mkcol(ctx); err != nil {
+			return err
+		}
+		err = w.put(ctx, target, path, size)
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := w.newRequest(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := do(w.httpClient, req, "verify upload", http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return verifyBody(resp.Body, sum)
+}
+
+func (w *WebDAV) put(ctx context.Context, target, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	req, err := w.newRequest(ctx, http.MethodPut, target, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := do(w.httpClient, req, "upload", http.StatusOK, http.StatusCreated, http.StatusNoContent)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// mkcol creates the upload collection. Its parent must exist.
+func (w *WebDAV) mkcol(ctx context.Context) error {
+	req, err := w.newRequest(ctx, "MKCOL", w.base+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := do(w.httpClient, req, "create collection", http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (w *WebDAV) newRequest(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	return req, nil
+}
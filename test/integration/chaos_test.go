@@ -0,0 +1,234 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/notify"
+)
+
+// joinEvent returns a player join event of name, unique by name.
+func joinEvent(name string) *event.Event {
+	return &event.Event{
+		Type:       event.TypePlayerJoin,
+		Ts:         time.Now().UTC(),
+		PlayerName: &name,
+		DedupeKey:  "chaos-" + name,
+		IngestedAt: time.Now().UTC(),
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after timeout.
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestChaos_StoreLatency checks that queries slower than the request
+// timeout fail fast with 503 busy and Retry-After, so clients back off,
+// while endpoints that don't query events keep working.
+func TestChaos_StoreLatency(t *testing.T) {
+	tests := []struct {
+		name       string
+		latency    time.Duration
+		wantStatus int
+	}{
+		{"within timeout", 20 * time.Millisecond, http.StatusOK},
+		{"beyond timeout", 2 * time.Second, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := NewTestApp(t, WithStoreLatency(tt.latency), WithRequestTimeout(200*time.Millisecond))
+			defer app.Close()
+			app.InsertTestEvent(t, event.TypePlayerJoin, "Alice")
+
+			start := time.Now()
+			resp, err := http.Get(app.URL() + "/api/v1/events")
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("request took %v, want it cut off by the request timeout", elapsed)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Code string `json:"code"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode error: %v", err)
+			}
+			if body.Code != "busy" {
+				t.Errorf("expected error code busy, got %q", body.Code)
+			}
+			if resp.Header.Get("Retry-After") == "" {
+				t.Error("expected a Retry-After header")
+			}
+
+			health, err := http.Get(app.URL() + "/api/v1/health")
+			if err != nil {
+				t.Fatalf("health request failed: %v", err)
+			}
+			health.Body.Close()
+			if health.StatusCode != http.StatusOK {
+				t.Errorf("expected health status 200 while the store is slow, got %d", health.StatusCode)
+			}
+		})
+	}
+}
+
+// TestChaos_NotifierBackoff checks that after a retryable failure the
+// notifier holds later notifications until the Retry-After has passed,
+// then delivers them and stays enabled.
+func TestChaos_NotifierBackoff(t *testing.T) {
+	app := NewTestApp(t, WithNotifierFailures(notify.SendRetryable))
+	defer app.Close()
+
+	if _, err := app.PublishEvent(joinEvent("Alice")); err != nil {
+		t.Fatalf("PublishEvent: %v", err)
+	}
+	waitFor(t, 5*time.Second, "the first send", func() bool { return len(app.Sender.Attempts()) == 1 })
+
+	// Queued while backing off; its batch comes due before the backoff ends
+	if _, err := app.PublishEvent(joinEvent("Bob")); err != nil {
+		t.Fatalf("PublishEvent: %v", err)
+	}
+	waitFor(t, 5*time.Second, "the send after backoff", func() bool { return len(app.Sender.Attempts()) == 2 })
+
+	attempts := app.Sender.Attempts()
+	if attempts[0].result != notify.SendRetryable || attempts[1].result != notify.SendOK {
+		t.Errorf("send results = %v, %v; want retryable, then ok", attempts[0].result, attempts[1].result)
+	}
+	if gap := attempts[1].at.Sub(attempts[0].at); gap < chaosRetryAfter {
+		t.Errorf("sent again after %v, want at least the Retry-After of %v", gap, chaosRetryAfter)
+	}
+	if payload, _ := json.Marshal(attempts[1].payload); !strings.Contains(string(payload), "Bob") {
+		t.Errorf("notification after backoff is missing the queued join: %s", payload)
+	}
+	if status := app.Notifier.Status(); status.Disabled {
+		t.Errorf("notifier disabled after a retryable failure: %s", status.DisabledReason)
+	}
+}
+
+// TestChaos_NotifierFatalFailure checks that a fatal failure disables the
+// notifier, which then drops notifications instead of queueing them, while
+// events are still stored and broadcast.
+func TestChaos_NotifierFatalFailure(t *testing.T) {
+	app := NewTestApp(t, WithNotifierFailures(notify.SendFatal))
+	defer app.Close()
+
+	if _, err := app.PublishEvent(joinEvent("Alice")); err != nil {
+		t.Fatalf("PublishEvent: %v", err)
+	}
+	waitFor(t, 5*time.Second, "the notifier to be disabled", func() bool { return app.Notifier.Status().Disabled })
+
+	sub := app.Hub.Subscribe()
+	defer app.Hub.Unsubscribe(sub)
+	if _, err := app.PublishEvent(joinEvent("Bob")); err != nil {
+		t.Fatalf("PublishEvent: %v", err)
+	}
+	select {
+	case ev := <-sub.Events():
+		if ev.PlayerName == nil || *ev.PlayerName != "Bob" {
+			t.Errorf("expected Bob's join to be broadcast, got %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not broadcast while notifications are disabled")
+	}
+
+	// Longer than the batch delay: nothing more may be sent
+	time.Sleep(1500 * time.Millisecond)
+	if n := len(app.Sender.Attempts()); n != 1 {
+		t.Errorf("expected 1 send attempt, got %d", n)
+	}
+	if n := app.Notifier.QueueLength(); n != 0 {
+		t.Errorf("expected an empty queue while disabled, got %d", n)
+	}
+}
+
+// TestChaos_StuckSubscriber checks that a subscriber that never reads
+// cannot hold up publishing, and that an SSE client that was away while
+// events were published in a burst gets all of them on reconnecting.
+func TestChaos_StuckSubscriber(t *testing.T) {
+	app := NewTestApp(t, WithHubSubscriberBuffer(2))
+	defer app.Close()
+
+	stuck := app.Hub.Subscribe()
+	defer app.Hub.Unsubscribe(stuck)
+
+	p := newProducer(app)
+	r := newReceiver(t)
+
+	conn := openSSE(t, app.URL()+"/api/v1/stream", "")
+	p.produce(t, 5, 5*time.Millisecond)
+	r.read(conn, 5, 5*time.Second)
+	conn.close()
+
+	// A burst far beyond the subscriber buffers
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.produce(t, 200, 0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("publishing blocked by a subscriber that does not read")
+	}
+
+	conn = openSSE(t, app.URL()+"/api/v1/stream", r.lastID)
+	defer conn.close()
+	r.read(conn, 200, 5*time.Second)
+	r.checkComplete(p.produced())
+
+	if n := len(stuck.Events()); n != 2 {
+		t.Errorf("stuck subscriber holds %d events, want its buffer of 2", n)
+	}
+}
+
+// TestChaos_HubDelay checks that events stored well before they are
+// broadcast reach a live client once, whether replay or the broadcast
+// delivers them.
+func TestChaos_HubDelay(t *testing.T) {
+	app := NewTestApp(t, WithHubDelay(20*time.Millisecond))
+	defer app.Close()
+
+	p := newProducer(app)
+	r := newReceiver(t)
+
+	conn := openSSE(t, app.URL()+"/api/v1/stream", "")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.produce(t, 30, 0)
+	}()
+
+	// Reconnect while events are between the store and the hub
+	for i := 0; i < 3; i++ {
+		r.read(conn, 5, 5*time.Second)
+		conn.close()
+		conn = openSSE(t, app.URL()+"/api/v1/stream", r.lastID)
+	}
+	<-done
+	defer conn.close()
+	r.read(conn, 30-len(r.order), 5*time.Second)
+	r.checkComplete(p.produced())
+}
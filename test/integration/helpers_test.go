@@ -5,15 +5,19 @@ package integration
 
 import (
 	"context"
+	"fmt"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/graaaaa/vrclog-companion/internal/api"
 	"github.com/graaaaa/vrclog-companion/internal/app"
+	"github.com/graaaaa/vrclog-companion/internal/derive"
 	"github.com/graaaaa/vrclog-companion/internal/event"
+	"github.com/graaaaa/vrclog-companion/internal/notify"
 	"github.com/graaaaa/vrclog-companion/internal/store"
 )
 
@@ -23,6 +27,15 @@ type TestApp struct {
 	Store  *store.Store
 	Hub    *api.Hub
 
+	// Notifier and Sender are set by WithNotifierFailures. Events published
+	// with PublishEvent are queued for notification, and the notifications
+	// are sent to Sender.
+	Notifier *notify.Notifier
+	Sender   *chaosSender
+
+	state    *derive.MultiState
+	hubDelay time.Duration
+
 	// Cleanup function to release resources
 	cleanup func()
 }
@@ -57,8 +70,16 @@ func NewTestApp(t *testing.T, opts ...TestAppOption) *TestApp {
 
 	// Create services
 	healthService := &app.HealthService{}
-	eventsService := &app.EventsService{Store: st}
-	hub := api.NewHub()
+	var events app.EventStore = st
+	if cfg.storeLatency > 0 {
+		events = slowStore{EventStore: st, latency: cfg.storeLatency}
+	}
+	eventsService := &app.EventsService{Store: events}
+	var hubOpts []api.HubOption
+	if cfg.hubBufferSize > 0 {
+		hubOpts = append(hubOpts, api.WithHubSubscriberBufferSize(cfg.hubBufferSize))
+	}
+	hub := api.NewHub(hubOpts...)
 
 	// Start hub
 	go hub.Run()
@@ -73,6 +94,9 @@ func NewTestApp(t *testing.T, opts ...TestAppOption) *TestApp {
 	if cfg.authEnabled {
 		serverOpts = append(serverOpts, api.WithBasicAuth(cfg.username, cfg.password))
 	}
+	if cfg.requestTimeout > 0 {
+		serverOpts = append(serverOpts, api.WithRequestTimeout(cfg.requestTimeout))
+	}
 
 	// Create server (addr is ignored for httptest)
 	server := api.NewServer("127.0.0.1:0", healthService, serverOpts...)
@@ -80,18 +104,38 @@ func NewTestApp(t *testing.T, opts ...TestAppOption) *TestApp {
 	// Create test server
 	ts := httptest.NewServer(server.Handler())
 
+	// Start the notifier, if notifications are under test
+	var notifier *notify.Notifier
+	var sender *chaosSender
+	notifierCtx, stopNotifier := context.WithCancel(context.Background())
+	if cfg.notifierFailures != nil {
+		sender = &chaosSender{failures: cfg.notifierFailures}
+		notifier = notify.NewNotifier(sender, 1, notify.FilterConfig{NotifyOnJoin: true})
+		go notifier.Run(notifierCtx)
+	}
+
 	cleanup := func() {
 		ts.Close()
+		if notifier != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			notifier.Stop(ctx)
+			cancel()
+		}
+		stopNotifier()
 		hub.Stop()
 		st.Close()
 		os.RemoveAll(tmpDir)
 	}
 
 	return &TestApp{
-		Server:  ts,
-		Store:   st,
-		Hub:     hub,
-		cleanup: cleanup,
+		Server:   ts,
+		Store:    st,
+		Hub:      hub,
+		Notifier: notifier,
+		Sender:   sender,
+		state:    derive.NewMulti(),
+		hubDelay: cfg.hubDelay,
+		cleanup:  cleanup,
 	}
 }
 
@@ -131,12 +175,44 @@ func (app *TestApp) InsertTestEvent(t *testing.T, eventType, playerName string)
 	return id
 }
 
+// PublishEvent handles ev the way the ingester handles a parsed event: it
+// is stored, derived state is updated and notifications are queued, and
+// then it is broadcast to SSE subscribers, after the hub delay if one is
+// set. Returns the ID of the stored event. Safe to call from any goroutine.
+func (app *TestApp) PublishEvent(ev *event.Event) (int64, error) {
+	id, inserted, err := app.Store.InsertEvent(context.Background(), ev)
+	if err != nil {
+		return 0, err
+	}
+	if !inserted {
+		return 0, fmt.Errorf("event %q was not inserted (duplicate?)", ev.DedupeKey)
+	}
+	ev.ID = id
+
+	if derived := app.state.Update(ev); derived != nil && app.Notifier != nil {
+		app.Notifier.Enqueue(derived)
+	}
+	if app.hubDelay > 0 {
+		time.Sleep(app.hubDelay)
+	}
+	app.Hub.Publish(ev)
+	return id, nil
+}
+
 // testAppConfig holds configuration for test app.
 type testAppConfig struct {
 	authEnabled bool
 	username    string
 	password    string
 	sseSecret   []byte
+
+	// Chaos: faults injected to exercise backpressure, backoff and
+	// degradation
+	storeLatency     time.Duration
+	requestTimeout   time.Duration
+	notifierFailures []notify.SendResult // nil: no notifier
+	hubDelay         time.Duration
+	hubBufferSize    int
 }
 
 // TestAppOption configures a test app.
@@ -150,3 +226,117 @@ func WithAuth(username, password string) TestAppOption {
 		cfg.password = password
 	}
 }
+
+// WithStoreLatency delays every event query by d, as a slow disk or a long
+// write transaction holding the database would.
+func WithStoreLatency(d time.Duration) TestAppOption {
+	return func(cfg *testAppConfig) {
+		cfg.storeLatency = d
+	}
+}
+
+// WithRequestTimeout sets the API's request timeout.
+func WithRequestTimeout(d time.Duration) TestAppOption {
+	return func(cfg *testAppConfig) {
+		cfg.requestTimeout = d
+	}
+}
+
+// WithNotifierFailures runs a notifier whose sends fail with results, in
+// order, before they succeed. Pass no results for a notifier that never
+// fails.
+func WithNotifierFailures(results ...notify.SendResult) TestAppOption {
+	return func(cfg *testAppConfig) {
+		cfg.notifierFailures = append([]notify.SendResult{}, results...)
+	}
+}
+
+// WithHubDelay delays broadcasting each event published with PublishEvent
+// by d after it is stored, widening the window in which SSE clients can
+// see an event in the database before they receive it live.
+func WithHubDelay(d time.Duration) TestAppOption {
+	return func(cfg *testAppConfig) {
+		cfg.hubDelay = d
+	}
+}
+
+// WithHubSubscriberBuffer sets how many events the hub buffers for each
+// subscriber; small buffers make slow subscribers drop events sooner.
+func WithHubSubscriberBuffer(n int) TestAppOption {
+	return func(cfg *testAppConfig) {
+		cfg.hubBufferSize = n
+	}
+}
+
+// slowStore delays every query of the wrapped store. It gives up when the
+// request's context ends, like a query waiting on a busy database.
+type slowStore struct {
+	app.EventStore
+	latency time.Duration
+}
+
+func (s slowStore) wait(ctx context.Context) error {
+	t := time.NewTimer(s.latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s slowStore) QueryEvents(ctx context.Context, filter store.QueryFilter) (store.QueryResult, error) {
+	if err := s.wait(ctx); err != nil {
+		return store.QueryResult{}, err
+	}
+	return s.EventStore.QueryEvents(ctx, filter)
+}
+
+func (s slowStore) EventChanges(ctx context.Context, sinceCursor string, limit int) (store.ChangesResult, error) {
+	if err := s.wait(ctx); err != nil {
+		return store.ChangesResult{}, err
+	}
+	return s.EventStore.EventChanges(ctx, sinceCursor, limit)
+}
+
+// chaosRetryAfter is the Retry-After of the chaos sender's retryable
+// failures: longer than the notifier's batch delay, so that batches queued
+// after a failure come due while it is still backing off.
+const chaosRetryAfter = 1500 * time.Millisecond
+
+// chaosSender is a notify.Sender that fails with scripted results before
+// it succeeds, and records every attempt.
+type chaosSender struct {
+	mu       sync.Mutex
+	failures []notify.SendResult
+	attempts []sendAttempt
+}
+
+// sendAttempt is one call of chaosSender.Send.
+type sendAttempt struct {
+	at      time.Time
+	payload notify.DiscordPayload
+	result  notify.SendResult
+}
+
+func (s *chaosSender) Send(ctx context.Context, payload notify.DiscordPayload) (notify.SendResult, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := notify.SendOK
+	if len(s.failures) > 0 {
+		result, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.attempts = append(s.attempts, sendAttempt{at: time.Now(), payload: payload, result: result})
+	if result == notify.SendRetryable {
+		return result, chaosRetryAfter
+	}
+	return result, 0
+}
+
+// Attempts returns the send attempts so far, in order.
+func (s *chaosSender) Attempts() []sendAttempt {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sendAttempt(nil), s.attempts...)
+}
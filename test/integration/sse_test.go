@@ -0,0 +1,324 @@
+//go:build integration
+
+package integration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graaaaa/vrclog-companion/internal/event"
+)
+
+// sseEvent is an event read from the SSE stream with its SSE id, the
+// cursor to reconnect from.
+type sseEvent struct {
+	id string
+	ev event.Event
+}
+
+// sseConn is an open connection to the SSE stream, read in the background.
+type sseConn struct {
+	events chan sseEvent // closed when the connection ends
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// openSSE connects to the event stream at streamURL, resuming after
+// lastEventID if it is set, and waits for the connected comment, by which
+// the server has replayed what was missed and receives live events.
+func openSSE(t *testing.T, streamURL, lastEventID string) *sseConn {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, streamURL, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to connect to stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		t.Fatalf("expected status 200 for stream, got %d", resp.StatusCode)
+	}
+
+	c := &sseConn{events: make(chan sseEvent, 1024), cancel: cancel, done: make(chan struct{})}
+	connected := make(chan struct{})
+	go func() {
+		defer close(c.done)
+		defer close(c.events)
+		defer resp.Body.Close()
+		r := bufio.NewReader(resp.Body)
+		var id, data string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == ": connected":
+				close(connected)
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && data != "":
+				var ev event.Event
+				if err := json.Unmarshal([]byte(data), &ev); err != nil {
+					t.Errorf("invalid event data %q: %v", data, err)
+					return
+				}
+				select {
+				case c.events <- sseEvent{id: id, ev: ev}:
+				case <-ctx.Done():
+					return
+				}
+				id, data = "", ""
+			}
+		}
+	}()
+
+	select {
+	case <-connected:
+	case <-c.done:
+		t.Fatal("stream ended before the connected comment")
+	case <-time.After(5 * time.Second):
+		c.close()
+		t.Fatal("timed out waiting for the connected comment")
+	}
+	return c
+}
+
+// close drops the connection from the client side.
+func (c *sseConn) close() {
+	c.cancel()
+	<-c.done
+}
+
+// producer stores and publishes events like the ingester, with
+// TestApp.PublishEvent.
+type producer struct {
+	app  *TestApp
+	base time.Time
+
+	mu  sync.Mutex
+	ids []int64
+}
+
+func newProducer(app *TestApp) *producer {
+	return &producer{app: app, base: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+// produce inserts and publishes n events, pausing interval between them.
+// Events published to a connected client need a pause: the hub drops
+// events for subscribers that fall more than a few behind.
+func (p *producer) produce(t *testing.T, n int, interval time.Duration) {
+	for range n {
+		p.mu.Lock()
+		seq := len(p.ids)
+		name := fmt.Sprintf("Player%d", seq)
+		ev := &event.Event{
+			Type:       event.TypePlayerJoin,
+			Ts:         p.base.Add(time.Duration(seq) * time.Second),
+			PlayerName: &name,
+			DedupeKey:  fmt.Sprintf("sse-reconnect-%d", seq),
+			IngestedAt: time.Now().UTC(),
+		}
+		id, err := p.app.PublishEvent(ev)
+		if err != nil {
+			p.mu.Unlock()
+			t.Errorf("failed to publish event %d: %v", seq, err)
+			return
+		}
+		p.ids = append(p.ids, id)
+		p.mu.Unlock()
+		if interval > 0 {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// produced returns the IDs of the events produced so far, in order.
+func (p *producer) produced() []int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]int64(nil), p.ids...)
+}
+
+// receiver collects the events of successive connections and checks that
+// each event arrives once, in order.
+type receiver struct {
+	t      *testing.T
+	seen   map[int64]bool
+	order  []int64
+	lastID string
+	lastTs time.Time
+}
+
+func newReceiver(t *testing.T) *receiver {
+	return &receiver{t: t, seen: make(map[int64]bool)}
+}
+
+// add records e, failing the test if it is a duplicate or out of order.
+func (r *receiver) add(e sseEvent) {
+	r.t.Helper()
+	if r.seen[e.ev.ID] {
+		r.t.Errorf("event %d received twice", e.ev.ID)
+	}
+	if e.ev.Ts.Before(r.lastTs) {
+		r.t.Errorf("event %d (%v) received after a later event (%v)", e.ev.ID, e.ev.Ts, r.lastTs)
+	}
+	if e.id == "" {
+		r.t.Errorf("event %d has no SSE id", e.ev.ID)
+	}
+	r.seen[e.ev.ID] = true
+	r.order = append(r.order, e.ev.ID)
+	r.lastID, r.lastTs = e.id, e.ev.Ts
+}
+
+// read adds events from c until it has n more, c ends or timeout passes.
+// Returns false if c ended.
+func (r *receiver) read(c *sseConn, n int, timeout time.Duration) bool {
+	r.t.Helper()
+	deadline := time.After(timeout)
+	for range n {
+		select {
+		case e, ok := <-c.events:
+			if !ok {
+				return false
+			}
+			r.add(e)
+		case <-deadline:
+			return true
+		}
+	}
+	return true
+}
+
+// checkComplete fails the test unless exactly the events with ids were
+// received, in that order.
+func (r *receiver) checkComplete(ids []int64) {
+	r.t.Helper()
+	var missing []int64
+	for _, id := range ids {
+		if !r.seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		r.t.Errorf("%d of %d events lost: %v", len(missing), len(ids), missing)
+	}
+	if len(r.order) != len(ids) {
+		r.t.Errorf("received %d events, want %d", len(r.order), len(ids))
+	}
+}
+
+// TestSSE_ReconnectUnderConcurrentInserts drops and reopens the stream
+// over and over while events are inserted and published, resuming each
+// time with Last-Event-ID, and checks that every event arrives exactly once
+// and in order.
+func TestSSE_ReconnectUnderConcurrentInserts(t *testing.T) {
+	// Events are stored a moment before they are broadcast, as the ingester
+	// updates state and queues notifications in between
+	app := NewTestApp(t, WithHubDelay(150*time.Microsecond))
+	defer app.Close()
+
+	const total = 400
+	p := newProducer(app)
+	r := newReceiver(t)
+
+	conn := openSSE(t, app.URL()+"/api/v1/stream", "")
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		p.produce(t, total, 150*time.Microsecond)
+	}()
+
+	// Drop the connection after every 10 events, and leave it down for a
+	// moment so that events are missed and must be replayed
+	for reconnects := 0; ; reconnects++ {
+		r.read(conn, 10, 2*time.Second)
+		conn.close()
+		select {
+		case <-produced:
+		default:
+			time.Sleep(time.Millisecond)
+			conn = openSSE(t, app.URL()+"/api/v1/stream", r.lastID)
+			continue
+		}
+		t.Logf("%d reconnects", reconnects)
+		break
+	}
+
+	// Catch up on the rest after the producer finished
+	conn = openSSE(t, app.URL()+"/api/v1/stream", r.lastID)
+	defer conn.close()
+	r.read(conn, total-len(r.order), 5*time.Second)
+	r.checkComplete(p.produced())
+}
+
+// TestSSE_ReconnectAfterServerDrop has the server drop the connection,
+// inserts events while the client is away, and checks that reconnecting
+// with Last-Event-ID replays them before live events, without duplicates.
+func TestSSE_ReconnectAfterServerDrop(t *testing.T) {
+	app := NewTestApp(t)
+	defer app.Close()
+
+	p := newProducer(app)
+	r := newReceiver(t)
+
+	conn := openSSE(t, app.URL()+"/api/v1/stream", "")
+	p.produce(t, 20, 2*time.Millisecond)
+	r.read(conn, 20, 5*time.Second)
+
+	// A network failure as the client sees it: the connection just ends
+	app.Server.CloseClientConnections()
+	if r.read(conn, 1, 5*time.Second) {
+		t.Fatal("stream still open after the server dropped the connection")
+	}
+
+	// Missed while disconnected: more than a page of replay
+	p.produce(t, 150, 0)
+
+	conn = openSSE(t, app.URL()+"/api/v1/stream", r.lastID)
+	defer conn.close()
+	r.read(conn, 150, 5*time.Second)
+
+	// Live events continue after the replay
+	p.produce(t, 30, 2*time.Millisecond)
+	r.read(conn, 30, 5*time.Second)
+
+	r.checkComplete(p.produced())
+}
+
+// TestSSE_ReconnectWithQueryParam resumes with the last_event_id query
+// parameter, for clients that cannot set headers.
+func TestSSE_ReconnectWithQueryParam(t *testing.T) {
+	app := NewTestApp(t)
+	defer app.Close()
+
+	p := newProducer(app)
+	r := newReceiver(t)
+
+	conn := openSSE(t, app.URL()+"/api/v1/stream", "")
+	p.produce(t, 5, 0)
+	r.read(conn, 5, 5*time.Second)
+	conn.close()
+
+	p.produce(t, 5, 0)
+	conn = openSSE(t, app.URL()+"/api/v1/stream?last_event_id="+r.lastID, "")
+	defer conn.close()
+	r.read(conn, 5, 5*time.Second)
+
+	r.checkComplete(p.produced())
+}